@@ -0,0 +1,382 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: safeshell/v1/safeshell.proto
+
+package safeshellv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SafeShell_CreateCheckpoint_FullMethodName   = "/safeshell.v1.SafeShell/CreateCheckpoint"
+	SafeShell_ListCheckpoints_FullMethodName    = "/safeshell.v1.SafeShell/ListCheckpoints"
+	SafeShell_GetCheckpoint_FullMethodName      = "/safeshell.v1.SafeShell/GetCheckpoint"
+	SafeShell_DiffCheckpoint_FullMethodName     = "/safeshell.v1.SafeShell/DiffCheckpoint"
+	SafeShell_Rollback_FullMethodName           = "/safeshell.v1.SafeShell/Rollback"
+	SafeShell_CompressCheckpoint_FullMethodName = "/safeshell.v1.SafeShell/CompressCheckpoint"
+	SafeShell_DeleteCheckpoint_FullMethodName   = "/safeshell.v1.SafeShell/DeleteCheckpoint"
+)
+
+// SafeShellClient is the client API for SafeShell service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SafeShell exposes the checkpoint lifecycle to other programs, e.g. agent
+// orchestrators that want to checkpoint and roll back a workspace without
+// shelling out to the CLI.
+type SafeShellClient interface {
+	// CreateCheckpoint backs up paths, streaming progress while it runs and
+	// ending with the created checkpoint.
+	CreateCheckpoint(ctx context.Context, in *CreateCheckpointRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CreateCheckpointResponse], error)
+	// ListCheckpoints returns checkpoints, newest first. File lists are omitted.
+	ListCheckpoints(ctx context.Context, in *ListCheckpointsRequest, opts ...grpc.CallOption) (*ListCheckpointsResponse, error)
+	// GetCheckpoint returns a checkpoint including its files.
+	GetCheckpoint(ctx context.Context, in *GetCheckpointRequest, opts ...grpc.CallOption) (*Checkpoint, error)
+	// DiffCheckpoint compares a checkpoint with the current filesystem.
+	DiffCheckpoint(ctx context.Context, in *DiffCheckpointRequest, opts ...grpc.CallOption) (*DiffCheckpointResponse, error)
+	// Rollback restores files from a checkpoint, streaming progress while it
+	// runs and ending with a result.
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RollbackResponse], error)
+	// CompressCheckpoint compresses a checkpoint's backups.
+	CompressCheckpoint(ctx context.Context, in *CompressCheckpointRequest, opts ...grpc.CallOption) (*CompressCheckpointResponse, error)
+	// DeleteCheckpoint removes a checkpoint.
+	DeleteCheckpoint(ctx context.Context, in *DeleteCheckpointRequest, opts ...grpc.CallOption) (*DeleteCheckpointResponse, error)
+}
+
+type safeShellClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSafeShellClient(cc grpc.ClientConnInterface) SafeShellClient {
+	return &safeShellClient{cc}
+}
+
+func (c *safeShellClient) CreateCheckpoint(ctx context.Context, in *CreateCheckpointRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CreateCheckpointResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SafeShell_ServiceDesc.Streams[0], SafeShell_CreateCheckpoint_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[CreateCheckpointRequest, CreateCheckpointResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SafeShell_CreateCheckpointClient = grpc.ServerStreamingClient[CreateCheckpointResponse]
+
+func (c *safeShellClient) ListCheckpoints(ctx context.Context, in *ListCheckpointsRequest, opts ...grpc.CallOption) (*ListCheckpointsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCheckpointsResponse)
+	err := c.cc.Invoke(ctx, SafeShell_ListCheckpoints_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *safeShellClient) GetCheckpoint(ctx context.Context, in *GetCheckpointRequest, opts ...grpc.CallOption) (*Checkpoint, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Checkpoint)
+	err := c.cc.Invoke(ctx, SafeShell_GetCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *safeShellClient) DiffCheckpoint(ctx context.Context, in *DiffCheckpointRequest, opts ...grpc.CallOption) (*DiffCheckpointResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DiffCheckpointResponse)
+	err := c.cc.Invoke(ctx, SafeShell_DiffCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *safeShellClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RollbackResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SafeShell_ServiceDesc.Streams[1], SafeShell_Rollback_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RollbackRequest, RollbackResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SafeShell_RollbackClient = grpc.ServerStreamingClient[RollbackResponse]
+
+func (c *safeShellClient) CompressCheckpoint(ctx context.Context, in *CompressCheckpointRequest, opts ...grpc.CallOption) (*CompressCheckpointResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompressCheckpointResponse)
+	err := c.cc.Invoke(ctx, SafeShell_CompressCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *safeShellClient) DeleteCheckpoint(ctx context.Context, in *DeleteCheckpointRequest, opts ...grpc.CallOption) (*DeleteCheckpointResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteCheckpointResponse)
+	err := c.cc.Invoke(ctx, SafeShell_DeleteCheckpoint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SafeShellServer is the server API for SafeShell service.
+// All implementations must embed UnimplementedSafeShellServer
+// for forward compatibility.
+//
+// SafeShell exposes the checkpoint lifecycle to other programs, e.g. agent
+// orchestrators that want to checkpoint and roll back a workspace without
+// shelling out to the CLI.
+type SafeShellServer interface {
+	// CreateCheckpoint backs up paths, streaming progress while it runs and
+	// ending with the created checkpoint.
+	CreateCheckpoint(*CreateCheckpointRequest, grpc.ServerStreamingServer[CreateCheckpointResponse]) error
+	// ListCheckpoints returns checkpoints, newest first. File lists are omitted.
+	ListCheckpoints(context.Context, *ListCheckpointsRequest) (*ListCheckpointsResponse, error)
+	// GetCheckpoint returns a checkpoint including its files.
+	GetCheckpoint(context.Context, *GetCheckpointRequest) (*Checkpoint, error)
+	// DiffCheckpoint compares a checkpoint with the current filesystem.
+	DiffCheckpoint(context.Context, *DiffCheckpointRequest) (*DiffCheckpointResponse, error)
+	// Rollback restores files from a checkpoint, streaming progress while it
+	// runs and ending with a result.
+	Rollback(*RollbackRequest, grpc.ServerStreamingServer[RollbackResponse]) error
+	// CompressCheckpoint compresses a checkpoint's backups.
+	CompressCheckpoint(context.Context, *CompressCheckpointRequest) (*CompressCheckpointResponse, error)
+	// DeleteCheckpoint removes a checkpoint.
+	DeleteCheckpoint(context.Context, *DeleteCheckpointRequest) (*DeleteCheckpointResponse, error)
+	mustEmbedUnimplementedSafeShellServer()
+}
+
+// UnimplementedSafeShellServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSafeShellServer struct{}
+
+func (UnimplementedSafeShellServer) CreateCheckpoint(*CreateCheckpointRequest, grpc.ServerStreamingServer[CreateCheckpointResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method CreateCheckpoint not implemented")
+}
+func (UnimplementedSafeShellServer) ListCheckpoints(context.Context, *ListCheckpointsRequest) (*ListCheckpointsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCheckpoints not implemented")
+}
+func (UnimplementedSafeShellServer) GetCheckpoint(context.Context, *GetCheckpointRequest) (*Checkpoint, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCheckpoint not implemented")
+}
+func (UnimplementedSafeShellServer) DiffCheckpoint(context.Context, *DiffCheckpointRequest) (*DiffCheckpointResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffCheckpoint not implemented")
+}
+func (UnimplementedSafeShellServer) Rollback(*RollbackRequest, grpc.ServerStreamingServer[RollbackResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedSafeShellServer) CompressCheckpoint(context.Context, *CompressCheckpointRequest) (*CompressCheckpointResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompressCheckpoint not implemented")
+}
+func (UnimplementedSafeShellServer) DeleteCheckpoint(context.Context, *DeleteCheckpointRequest) (*DeleteCheckpointResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCheckpoint not implemented")
+}
+func (UnimplementedSafeShellServer) mustEmbedUnimplementedSafeShellServer() {}
+func (UnimplementedSafeShellServer) testEmbeddedByValue()                   {}
+
+// UnsafeSafeShellServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SafeShellServer will
+// result in compilation errors.
+type UnsafeSafeShellServer interface {
+	mustEmbedUnimplementedSafeShellServer()
+}
+
+func RegisterSafeShellServer(s grpc.ServiceRegistrar, srv SafeShellServer) {
+	// If the following call pancis, it indicates UnimplementedSafeShellServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SafeShell_ServiceDesc, srv)
+}
+
+func _SafeShell_CreateCheckpoint_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CreateCheckpointRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SafeShellServer).CreateCheckpoint(m, &grpc.GenericServerStream[CreateCheckpointRequest, CreateCheckpointResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SafeShell_CreateCheckpointServer = grpc.ServerStreamingServer[CreateCheckpointResponse]
+
+func _SafeShell_ListCheckpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCheckpointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafeShellServer).ListCheckpoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafeShell_ListCheckpoints_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafeShellServer).ListCheckpoints(ctx, req.(*ListCheckpointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SafeShell_GetCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafeShellServer).GetCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafeShell_GetCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafeShellServer).GetCheckpoint(ctx, req.(*GetCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SafeShell_DiffCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafeShellServer).DiffCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafeShell_DiffCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafeShellServer).DiffCheckpoint(ctx, req.(*DiffCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SafeShell_Rollback_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RollbackRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SafeShellServer).Rollback(m, &grpc.GenericServerStream[RollbackRequest, RollbackResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SafeShell_RollbackServer = grpc.ServerStreamingServer[RollbackResponse]
+
+func _SafeShell_CompressCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompressCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafeShellServer).CompressCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafeShell_CompressCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafeShellServer).CompressCheckpoint(ctx, req.(*CompressCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SafeShell_DeleteCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SafeShellServer).DeleteCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SafeShell_DeleteCheckpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SafeShellServer).DeleteCheckpoint(ctx, req.(*DeleteCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SafeShell_ServiceDesc is the grpc.ServiceDesc for SafeShell service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SafeShell_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "safeshell.v1.SafeShell",
+	HandlerType: (*SafeShellServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCheckpoints",
+			Handler:    _SafeShell_ListCheckpoints_Handler,
+		},
+		{
+			MethodName: "GetCheckpoint",
+			Handler:    _SafeShell_GetCheckpoint_Handler,
+		},
+		{
+			MethodName: "DiffCheckpoint",
+			Handler:    _SafeShell_DiffCheckpoint_Handler,
+		},
+		{
+			MethodName: "CompressCheckpoint",
+			Handler:    _SafeShell_CompressCheckpoint_Handler,
+		},
+		{
+			MethodName: "DeleteCheckpoint",
+			Handler:    _SafeShell_DeleteCheckpoint_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateCheckpoint",
+			Handler:       _SafeShell_CreateCheckpoint_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Rollback",
+			Handler:       _SafeShell_Rollback_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "safeshell/v1/safeshell.proto",
+}
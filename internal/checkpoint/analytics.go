@@ -0,0 +1,157 @@
+package checkpoint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Growth periods for Analyze
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Usage is what a group of checkpoints holds, sizes before compression
+type Usage struct {
+	Key         string
+	Checkpoints int
+	Files       int
+	Size        int64
+}
+
+func (u *Usage) add(e *IndexEntry) {
+	u.Checkpoints++
+	u.Files += e.FileCount
+	u.Size += e.TotalSize
+}
+
+// Analytics aggregates the checkpoints in the index
+type Analytics struct {
+	BySession []Usage       // Largest first
+	ByTag     []Usage       // Largest first
+	ByCommand []Usage       // By program, most checkpoints first
+	Largest   []*IndexEntry // Checkpoints, largest first
+	Growth    []Usage       // Checkpoints created per period, oldest first
+
+	// Compressed checkpoints, their size before and after
+	Compressed       int
+	CompressedBefore int64
+	CompressedAfter  int64
+}
+
+// CompressionRatio is how many times smaller compressed checkpoints are
+// than what they hold, 0 if none are
+func (a *Analytics) CompressionRatio() float64 {
+	if a.CompressedAfter == 0 {
+		return 0
+	}
+	return float64(a.CompressedBefore) / float64(a.CompressedAfter)
+}
+
+// Analyze aggregates index entries, counting growth per period: PeriodDay,
+// PeriodWeek or PeriodMonth
+func Analyze(entries []*IndexEntry, period string) *Analytics {
+	a := &Analytics{}
+	sessions := make(map[string]*Usage)
+	tags := make(map[string]*Usage)
+	commands := make(map[string]*Usage)
+	growth := make(map[string]*Usage)
+	use := func(m map[string]*Usage, key string) *Usage {
+		if m[key] == nil {
+			m[key] = &Usage{Key: key}
+		}
+		return m[key]
+	}
+
+	for _, e := range entries {
+		use(sessions, e.SessionID).add(e)
+		for _, tag := range e.Tags {
+			use(tags, tag).add(e)
+		}
+		program, _, _ := strings.Cut(e.Command, " ")
+		use(commands, program).add(e)
+		use(growth, periodOf(e.Timestamp, period)).add(e)
+		if e.Compressed && e.CompressedSize > 0 {
+			a.Compressed++
+			a.CompressedBefore += e.TotalSize
+			a.CompressedAfter += e.CompressedSize
+		}
+	}
+
+	bySize := func(u []Usage) {
+		sort.Slice(u, func(i, j int) bool {
+			if u[i].Size == u[j].Size {
+				return u[i].Key < u[j].Key
+			}
+			return u[i].Size > u[j].Size
+		})
+	}
+	a.BySession = usages(sessions)
+	bySize(a.BySession)
+	a.ByTag = usages(tags)
+	bySize(a.ByTag)
+	a.ByCommand = usages(commands)
+	sort.Slice(a.ByCommand, func(i, j int) bool {
+		if a.ByCommand[i].Checkpoints == a.ByCommand[j].Checkpoints {
+			return a.ByCommand[i].Key < a.ByCommand[j].Key
+		}
+		return a.ByCommand[i].Checkpoints > a.ByCommand[j].Checkpoints
+	})
+	// Period keys sort in time order
+	a.Growth = usages(growth)
+	sort.Slice(a.Growth, func(i, j int) bool { return a.Growth[i].Key < a.Growth[j].Key })
+
+	a.Largest = append([]*IndexEntry(nil), entries...)
+	sort.SliceStable(a.Largest, func(i, j int) bool { return a.Largest[i].TotalSize > a.Largest[j].TotalSize })
+	return a
+}
+
+func usages(m map[string]*Usage) []Usage {
+	u := make([]Usage, 0, len(m))
+	for _, usage := range m {
+		u = append(u, *usage)
+	}
+	return u
+}
+
+// periodOf names the period t is in, such that names sort in time order:
+// 2024-12-01 for a day, 2024-W48 for a week and 2024-12 for a month
+func periodOf(t time.Time, period string) string {
+	t = t.Local()
+	switch period {
+	case PeriodWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case PeriodMonth:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// BackedUpFile is a file in a checkpoint
+type BackedUpFile struct {
+	Path         string
+	Size         int64
+	CheckpointID string
+}
+
+// LargestFiles returns the n largest files backed up by checkpoints,
+// largest first
+func LargestFiles(checkpoints []*Checkpoint, n int) []BackedUpFile {
+	var files []BackedUpFile
+	for _, cp := range checkpoints {
+		for _, f := range cp.Manifest.Files {
+			if !f.IsDir {
+				files = append(files, BackedUpFile{Path: f.OriginalPath, Size: f.Size, CheckpointID: cp.ID})
+			}
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	if n >= 0 && len(files) > n {
+		files = files[:n]
+	}
+	return files
+}
@@ -0,0 +1,44 @@
+package checkpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	day := time.Date(2024, 12, 30, 12, 0, 0, 0, time.Local)
+	entries := []*IndexEntry{
+		{ID: "a", Timestamp: day, Command: "rm -rf build", FileCount: 10, TotalSize: 1000, SessionID: "s1", Tags: []string{"ci"}},
+		{ID: "b", Timestamp: day.Add(time.Hour), Command: "rm a.txt", FileCount: 1, TotalSize: 50, SessionID: "s2"},
+		{ID: "c", Timestamp: day.Add(48 * time.Hour), Command: "mv x y", FileCount: 2, TotalSize: 400, SessionID: "s2", Tags: []string{"ci", "keep"},
+			Compressed: true, CompressedSize: 100},
+	}
+
+	a := Analyze(entries, PeriodDay)
+	if len(a.BySession) != 2 || a.BySession[0].Key != "s1" || a.BySession[1].Size != 450 || a.BySession[1].Checkpoints != 2 {
+		t.Errorf("Unexpected sessions: %+v", a.BySession)
+	}
+	if len(a.ByTag) != 2 || a.ByTag[0].Key != "ci" || a.ByTag[0].Size != 1400 {
+		t.Errorf("Unexpected tags: %+v", a.ByTag)
+	}
+	if len(a.ByCommand) != 2 || a.ByCommand[0].Key != "rm" || a.ByCommand[0].Checkpoints != 2 {
+		t.Errorf("Unexpected commands: %+v", a.ByCommand)
+	}
+	if a.Largest[0].ID != "a" || a.Largest[2].ID != "b" {
+		t.Errorf("Unexpected largest: %s, %s", a.Largest[0].ID, a.Largest[2].ID)
+	}
+	if a.Compressed != 1 || a.CompressionRatio() != 4 {
+		t.Errorf("Expected one checkpoint compressed 4x, got %d at %v", a.Compressed, a.CompressionRatio())
+	}
+	if len(a.Growth) != 2 || a.Growth[0].Key != "2024-12-30" || a.Growth[0].Checkpoints != 2 || a.Growth[1].Key != "2025-01-01" {
+		t.Errorf("Unexpected daily growth: %+v", a.Growth)
+	}
+
+	// 2024-12-30 is in the first ISO week of 2025, as is 2025-01-01
+	if g := Analyze(entries, PeriodWeek).Growth; len(g) != 1 || g[0].Key != "2025-W01" {
+		t.Errorf("Unexpected weekly growth: %+v", g)
+	}
+	if g := Analyze(entries, PeriodMonth).Growth; len(g) != 2 || g[0].Key != "2024-12" || g[1].Key != "2025-01" {
+		t.Errorf("Unexpected monthly growth: %+v", g)
+	}
+}
@@ -0,0 +1,133 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// ErrNotFound is returned by a Backend when a checkpoint is not stored there
+var ErrNotFound = errors.New("checkpoint not found in remote storage")
+
+// Backend stores packed checkpoints outside the local checkpoints directory.
+// Each checkpoint is a single object holding its manifest and files.
+type Backend interface {
+	// Put stores the packed checkpoint read from r (size bytes long)
+	Put(id string, r io.Reader, size int64) error
+	// Get opens the packed checkpoint, returning ErrNotFound if it is missing
+	Get(id string) (io.ReadCloser, error)
+	// List returns the IDs of all stored checkpoints, sorted
+	List() ([]string, error)
+	// Delete removes a stored checkpoint
+	Delete(id string) error
+}
+
+// packedExt is the file extension of a packed checkpoint object
+const packedExt = ".tar.gz"
+
+// NewBackend creates the backend described by the remote config
+func NewBackend(rc config.RemoteConfig) (Backend, error) {
+	switch rc.Type {
+	case "s3":
+		return NewS3Backend(rc)
+	case "local":
+		if rc.Path == "" {
+			return nil, fmt.Errorf("remote.path is required for the local backend")
+		}
+		return NewLocalBackend(expandPath(rc.Path))
+	case "":
+		return nil, fmt.Errorf("no remote storage configured (set remote.type in config.yaml)")
+	default:
+		return nil, fmt.Errorf("unknown remote type: %s (use s3 or local)", rc.Type)
+	}
+}
+
+// DefaultBackend creates the backend from the user's config
+func DefaultBackend() (Backend, error) {
+	return NewBackend(config.Get().Remote)
+}
+
+// LocalBackend stores packed checkpoints in a directory, such as a network mount
+type LocalBackend struct {
+	dir string
+}
+
+// NewLocalBackend creates a backend rooted at dir, creating it if needed
+func NewLocalBackend(dir string) (*LocalBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create remote directory: %w", err)
+	}
+	return &LocalBackend{dir: dir}, nil
+}
+
+func (b *LocalBackend) path(id string) string {
+	return filepath.Join(b.dir, id+packedExt)
+}
+
+func (b *LocalBackend) Put(id string, r io.Reader, size int64) error {
+	// Write to a temp file first so a failed upload never leaves a partial object
+	tmp, err := os.CreateTemp(b.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.path(id))
+}
+
+func (b *LocalBackend) Get(id string) (io.ReadCloser, error) {
+	f, err := os.Open(b.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (b *LocalBackend) List() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, packedExt) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, packedExt))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (b *LocalBackend) Delete(id string) error {
+	err := os.Remove(b.path(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// expandPath replaces a leading ~ with the user's home directory
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[1:])
+		}
+	}
+	return path
+}
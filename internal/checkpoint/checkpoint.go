@@ -1,7 +1,9 @@
 package checkpoint
 
 import (
+	"context"
 	"crypto/md5"
+	"expvar"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,6 +14,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/hooks"
+	"github.com/qhkm/safeshell/internal/logging"
+	"github.com/qhkm/safeshell/internal/oplog"
 )
 
 // GetSessionID returns a session identifier for grouping checkpoints.
@@ -31,49 +36,245 @@ func GetSessionID() string {
 	return fmt.Sprintf("%x", hash[:4])
 }
 
+// createStats counts checkpoint creation, for the debug endpoints
+var createStats = expvar.NewMap("checkpoint_create")
+
 type Checkpoint struct {
 	ID        string
 	Dir       string
 	FilesDir  string
 	Manifest  *Manifest
 	CreatedAt time.Time
+
+	// Evicted is what creating this checkpoint did to older ones to stay
+	// within max_storage_mb and max_checkpoints, if anything
+	Evicted []*Eviction
 }
 
 // Create creates a new checkpoint for the given files before executing a command
-func Create(command string, targetPaths []string) (*Checkpoint, error) {
-	// Check storage limit before creating checkpoint
-	if exceeds, currentMB, limitMB := CheckTotalStorage(); exceeds {
-		fmt.Fprintf(os.Stderr, "Warning: Storage limit exceeded (%dMB / %dMB). Run 'safeshell clean' to free space.\n", currentMB, limitMB)
+func (s *Store) Create(command string, targetPaths []string) (*Checkpoint, error) {
+	return s.CreateWithProgress(command, targetPaths, nil)
+}
+
+// CreateWithProgress is Create, reporting each target path as it is backed up
+func (s *Store) CreateWithProgress(command string, targetPaths []string, progress ProgressFunc) (*Checkpoint, error) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
+	return s.create(context.Background(), Origin{WorkingDir: workingDir, SessionID: GetSessionID()}, command, targetPaths, createOptions{progress: progress})
+}
 
-	// Generate unique ID
-	timestamp := time.Now().Format("2006-01-02T150405")
-	shortUUID := uuid.New().String()[:8]
-	id := fmt.Sprintf("%s-%s", timestamp, shortUUID)
+// CreateIncluding is Create, backing up only the files matching one of the
+// include patterns instead of include_paths. Patterns are matched like
+// exclude_paths, and a file in a directory that matches is included.
+func (s *Store) CreateIncluding(command string, targetPaths, include []string) (*Checkpoint, error) {
+	return s.CreateContext(context.Background(), command, targetPaths, include)
+}
 
-	// Get working directory
+// CreateContext is CreateIncluding, stopping when ctx is done. The
+// checkpoint is then removed, as it would be missing backups.
+func (s *Store) CreateContext(ctx context.Context, command string, targetPaths, include []string) (*Checkpoint, error) {
 	workingDir, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
+	return s.create(ctx, Origin{WorkingDir: workingDir, SessionID: GetSessionID()}, command, targetPaths, createOptions{include: include})
+}
+
+// CreateResumable is CreateContext, but stopped when ctx is done, or by a
+// kill, what was backed up is kept for ResumeCreate to finish the
+// checkpoint, e.g. of a huge directory. It then returns a ResumableError.
+// The checkpoint gets name, unless it is empty.
+func (s *Store) CreateResumable(ctx context.Context, name, command string, targetPaths, include []string) (*Checkpoint, error) {
+	if name != "" {
+		if err := s.checkName(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return s.create(ctx, Origin{WorkingDir: workingDir, SessionID: GetSessionID()}, command, targetPaths, createOptions{include: include, name: name, resumable: true})
+}
+
+// Origin describes the process a checkpoint is created for
+type Origin struct {
+	WorkingDir string // relative target paths are resolved against it
+	SessionID  string
+}
+
+// CreateFor is Create on behalf of another process, e.g. a daemon client,
+// using its working directory and session instead of our own
+func (s *Store) CreateFor(origin Origin, command string, targetPaths []string) (*Checkpoint, error) {
+	if !filepath.IsAbs(origin.WorkingDir) {
+		return nil, fmt.Errorf("working directory must be absolute: %q", origin.WorkingDir)
+	}
+	return s.create(context.Background(), origin, command, targetPaths, createOptions{})
+}
+
+// CreateForWriter is CreateFor, stopping when ctx is done, for a command
+// about to write to the targets in place, such as dd of=, truncate, shred
+// or a shell redirection. Files are backed up as clones or copies, never as
+// hard links, which the writes would reach.
+func (s *Store) CreateForWriter(ctx context.Context, origin Origin, command string, targetPaths []string) (*Checkpoint, error) {
+	if !filepath.IsAbs(origin.WorkingDir) {
+		return nil, fmt.Errorf("working directory must be absolute: %q", origin.WorkingDir)
+	}
+	return s.create(ctx, origin, command, targetPaths, createOptions{copies: true})
+}
+
+// createOptions are how create goes about a checkpoint
+type createOptions struct {
+	include   []string // only files matching one are backed up; nil for include_paths
+	name      string
+	progress  ProgressFunc
+	resumable bool   // stopped by ctx, keep what was backed up for resuming
+	resume    string // ID of the interrupted checkpoint to finish, keeping what it backed up
+	copies    bool   // back files up as clones or copies, never hard links
+}
+
+// create backs up targetPaths, unless ctx is done first
+func (s *Store) create(ctx context.Context, origin Origin, command string, targetPaths []string, opts createOptions) (cp *Checkpoint, err error) {
+	start := time.Now()
+	include, progress := opts.include, opts.progress
+	requested := targetPaths
+
+	if include == nil {
+		include = config.Get().IncludePaths
+	}
+	for _, pattern := range include {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+	}
+
+	// Check storage limit before creating checkpoint
+	var evicted []*Eviction
+	if e, err := s.enforceMaxStorage(); err != nil {
+		return nil, err
+	} else if e != nil {
+		evicted = append(evicted, e)
+	}
+
+	id := opts.resume
+	if id == "" {
+		id = newID()
+	}
+	workingDir := origin.WorkingDir
 
 	// Create checkpoint directory
-	checkpointDir := filepath.Join(config.GetCheckpointsDir(), id)
+	checkpointDir := s.checkpointDir(id)
 	filesDir := filepath.Join(checkpointDir, "files")
+
+	targetPaths = scopeTargets(targetPaths, workingDir)
+
+	hookEnv := hooks.Env{CheckpointID: id, CheckpointDir: checkpointDir, Command: command}
+	for _, p := range targetPaths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(workingDir, p)
+		}
+		hookEnv.Paths = append(hookEnv.Paths, filepath.Clean(p))
+	}
+	if err := hooks.Run(hooks.PreCheckpoint, hookEnv); err != nil {
+		return nil, err
+	}
+	// Runs even if the checkpoint fails, so pre/post hooks stay paired
+	defer func() {
+		if err := hooks.Run(hooks.PostCheckpoint, hookEnv); err != nil {
+			logging.Warn(fmt.Sprintf("Warning: %v", err))
+		}
+	}()
+
 	if err := os.MkdirAll(filesDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
 	}
+	// Until the manifest is saved, the checkpoint may be missing backups
+	if err := markIncomplete(checkpointDir, IncompleteCopy); err != nil {
+		os.RemoveAll(checkpointDir)
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	complete := false
+	var snap *FSSnapshot
+	var journal *createJournal
+	defer func() {
+		journal.close()
+		switch {
+		case complete:
+		case opts.resume != "" || journal != nil && opts.resumable && ctx.Err() != nil:
+			// Kept for resuming
+			if ctx.Err() != nil {
+				err = &ResumableError{ID: id, Err: err}
+			}
+		default:
+			if snap != nil {
+				removeFSSnapshot(snap, checkpointDir)
+			}
+			removeTree(checkpointDir)
+		}
+	}()
 
 	// Create manifest with session ID
 	manifest := NewManifest(id, command, workingDir)
-	manifest.SessionID = GetSessionID()
+	manifest.SessionID = origin.SessionID
+	if len(include) > 0 {
+		manifest.Include = include
+	}
+	filter := newBackupFilter(include)
 
 	// Track sensitive files for warning
 	var sensitiveFiles []SensitiveFileInfo
 	var skippedLargeFiles []string
+	hydrate := hydratePlaceholders()
+
+	// A filesystem snapshot holds the backups instead of copies, but for
+	// those of a checkpoint resumed
+	if opts.resume == "" {
+		snap = s.takeFSSnapshot(id, checkpointDir, hookEnv.Paths)
+	}
+	manifest.FSSnapshot = snap
+	backupPathOf := func(path string) string {
+		if snap != nil {
+			return snap.backupPath(checkpointDir, path)
+		}
+		return filepath.Join(filesDir, strings.TrimPrefix(path, "/"))
+	}
+	// Snapshots only hold the stubs of cloud placeholders
+	hydrate = hydrate && snap == nil
+
+	// Copies are journaled, for an interrupted checkpoint to be resumed
+	if snap == nil {
+		state := createState{Command: command, Targets: requested, Include: opts.include, Name: opts.name, WorkingDir: workingDir, SessionID: origin.SessionID}
+		journal, err = openJournal(checkpointDir, state, opts.resume != "")
+		if err != nil && opts.resume != "" {
+			return nil, err
+		} else if err != nil {
+			logging.Debug("not resumable", "checkpoint", id, "error", err)
+		}
+		// Backups made before keep the modes of their directories
+		if opts.resume != "" {
+			makeWritable(filesDir)
+		}
+	}
+
+	// Count what there is to copy, for progress to tell how far along it is
+	var counter *progressCounter
+	if progress = progress.orDefault(); progress != nil && snap == nil {
+		progress(Progress{Phase: PhaseScan})
+		files, size := scanTargets(ctx, hookEnv.Paths, filter)
+		counter = newProgressCounter(progress, Progress{Phase: PhaseBackup, Total: files, TotalBytes: size})
+	}
 
 	// Backup each target path
-	for _, targetPath := range targetPaths {
+	for i, targetPath := range targetPaths {
+		if err := interrupted(ctx); err != nil {
+			return nil, err
+		}
+		if counter == nil {
+			progress.Report(Progress{Phase: PhaseBackup, Done: i, Total: len(targetPaths), Path: targetPath})
+		}
+
 		// Resolve to absolute path
 		absPath := targetPath
 		if !filepath.IsAbs(targetPath) {
@@ -82,12 +283,16 @@ func Create(command string, targetPaths []string) (*Checkpoint, error) {
 
 		// Validate path is safe to backup
 		if err := ValidatePath(absPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			logging.Warn(fmt.Sprintf("Warning: %v", err))
 			continue
 		}
 
 		// Check if path exists
-		info, err := os.Stat(absPath)
+		var info os.FileInfo
+		err := withRetry(func() (err error) {
+			info, err = os.Stat(absPath)
+			return err
+		})
 		if os.IsNotExist(err) {
 			// Path doesn't exist, skip it
 			continue
@@ -96,33 +301,54 @@ func Create(command string, targetPaths []string) (*Checkpoint, error) {
 			return nil, fmt.Errorf("failed to stat %s: %w", absPath, err)
 		}
 
+		manifest.addParents(absPath)
+
 		// Calculate backup path (preserve directory structure)
-		relPath := strings.TrimPrefix(absPath, "/")
-		backupPath := filepath.Join(filesDir, relPath)
+		backupPath := backupPathOf(absPath)
+
+		if filter.skips(absPath, info.IsDir()) {
+			logging.Debug("not backed up", "path", absPath)
+			continue
+		}
 
 		if info.IsDir() {
-			// Backup directory recursively
-			if err := BackupDir(absPath, backupPath); err != nil {
-				// Log warning but continue
-				fmt.Fprintf(os.Stderr, "Warning: failed to backup directory %s: %v\n", absPath, err)
-				continue
+			// Backup directory recursively, unless the snapshot holds it
+			if snap == nil {
+				if err := backupDir(ctx, absPath, backupPath, filter, counter, journal, opts.copies); err != nil {
+					if err := interrupted(ctx); err != nil {
+						return nil, err
+					}
+					// Log warning but continue
+					logging.Warn(fmt.Sprintf("Warning: failed to backup directory %s: %v", absPath, err))
+					continue
+				}
 			}
 			manifest.AddFile(absPath, backupPath, info.Mode(), 0, true)
 
 			// Also add individual files within the directory (respecting exclusions)
 			filepath.Walk(absPath, func(path string, fi os.FileInfo, err error) error {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				if err != nil {
 					return nil // Skip errors
 				}
 
 				// Skip excluded paths and symlinks
-				if shouldExclude(path) {
+				if shouldExclude(path) || filter.skips(path, fi.IsDir()) {
 					if fi.IsDir() {
 						return filepath.SkipDir
 					}
 					return nil
 				}
-				if isSymlink(path) || fi.IsDir() {
+				if isSymlink(path) {
+					return nil
+				}
+				if fi.IsDir() {
+					// Record subdirectories so rollback can restore their modes
+					if path != absPath {
+						manifest.AddFile(path, backupPathOf(path), fi.Mode(), 0, true)
+					}
 					return nil
 				}
 
@@ -137,12 +363,21 @@ func Create(command string, targetPaths []string) (*Checkpoint, error) {
 					return nil // Skip large files
 				}
 
-				relFilePath := strings.TrimPrefix(path, "/")
-				backupFilePath := filepath.Join(filesDir, relFilePath)
-				manifest.AddFile(path, backupFilePath, fi.Mode(), fi.Size(), false)
+				// BackupDir left out cloud placeholders unless hydrating
+				if cloudOnly(fi) && !hydrate {
+					manifest.Placeholders = append(manifest.Placeholders, path)
+					return nil
+				}
+
+				manifest.AddFile(path, backupPathOf(path), fi.Mode(), fi.Size(), false).recordTimes(fi)
+				logging.Debug("backed up", "path", path, "size", fi.Size())
 				return nil
 			})
 		} else {
+			if filter.skips(absPath, false) {
+				continue
+			}
+
 			// Check for sensitive files
 			if isSensitive, pattern := IsSensitiveFile(absPath); isSensitive {
 				sensitiveFiles = append(sensitiveFiles, SensitiveFileInfo{Path: absPath, Pattern: pattern})
@@ -154,39 +389,93 @@ func Create(command string, targetPaths []string) (*Checkpoint, error) {
 				continue // Skip large files
 			}
 
-			// Backup single file
-			if err := BackupFile(absPath, backupPath); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to backup file %s: %v\n", absPath, err)
-				continue
+			// Cloud placeholders are hydrated into a copy, or skipped
+			backup := BackupFile
+			if opts.copies {
+				backup = backupCopy
+			}
+			if cloudOnly(info) {
+				if !hydrate {
+					manifest.Placeholders = append(manifest.Placeholders, absPath)
+					continue
+				}
+				backup = hydrateFile
+			}
+
+			// Backup single file, unless the snapshot holds it
+			if snap == nil {
+				if !journal.reuse(absPath, info, backupPath) {
+					if err := backup(absPath, backupPath); err != nil {
+						logging.Warn(fmt.Sprintf("Warning: failed to backup file %s: %v", absPath, err))
+						continue
+					}
+					journal.record(absPath, info)
+				}
+				counter.add(absPath, info.Size())
 			}
-			manifest.AddFile(absPath, backupPath, info.Mode(), info.Size(), false)
+			manifest.AddFile(absPath, backupPath, info.Mode(), info.Size(), false).recordTimes(info)
+			logging.Debug("backed up", "path", absPath, "size", info.Size())
 		}
 	}
 
+	if err := interrupted(ctx); err != nil {
+		return nil, err
+	}
+	if counter != nil {
+		counter.finish()
+	} else {
+		progress.Report(Progress{Phase: PhaseBackup, Done: len(targetPaths), Total: len(targetPaths)})
+	}
+	manifest.Git = filter.gitStates()
+	if opts.resume != "" {
+		pruneBackups(filesDir, manifest)
+	}
+	manifest.Name = opts.name
+
 	// Warn about sensitive files
 	if len(sensitiveFiles) > 0 {
-		fmt.Fprintf(os.Stderr, "\n⚠️  Warning: Backing up %d sensitive file(s):\n", len(sensitiveFiles))
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n⚠️  Warning: Backing up %d sensitive file(s):\n", len(sensitiveFiles))
 		for _, sf := range sensitiveFiles {
-			fmt.Fprintf(os.Stderr, "   • %s (matched: %s)\n", sf.Path, sf.Pattern)
+			fmt.Fprintf(&b, "   • %s (matched: %s)\n", sf.Path, sf.Pattern)
 		}
-		fmt.Fprintf(os.Stderr, "   Consider adding these to your exclusions if they contain secrets.\n\n")
+		b.WriteString("   Consider adding these to your exclusions if they contain secrets.\n")
+		logging.Warn(b.String())
 	}
 
 	// Warn about skipped large files
 	if len(skippedLargeFiles) > 0 {
-		fmt.Fprintf(os.Stderr, "\n⚠️  Warning: Skipped %d large file(s):\n", len(skippedLargeFiles))
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n⚠️  Warning: Skipped %d large file(s):\n", len(skippedLargeFiles))
 		for _, f := range skippedLargeFiles {
-			fmt.Fprintf(os.Stderr, "   • %s\n", f)
+			fmt.Fprintf(&b, "   • %s\n", f)
+		}
+		b.WriteString("   Increase max_file_size_mb in config to include these files.\n")
+		logging.Warn(b.String())
+	}
+
+	// Warn about skipped cloud placeholders
+	if len(manifest.Placeholders) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n⚠️  Warning: Skipped %d cloud file(s) not downloaded to this computer:\n", len(manifest.Placeholders))
+		for _, p := range manifest.Placeholders {
+			fmt.Fprintf(&b, "   • %s\n", p)
 		}
-		fmt.Fprintf(os.Stderr, "   Increase max_file_size_mb in config to include these files.\n\n")
+		b.WriteString("   Their content is still in the cloud. Set cloud_placeholders to hydrate to download and back them up.\n")
+		logging.Warn(b.String())
 	}
 
 	// Save manifest
 	if err := manifest.Save(checkpointDir); err != nil {
 		return nil, fmt.Errorf("failed to save manifest: %w", err)
 	}
+	journal.finish(checkpointDir)
+	if err := markComplete(checkpointDir); err != nil {
+		return nil, fmt.Errorf("failed to save manifest: %w", err)
+	}
+	complete = true
 
-	cp := &Checkpoint{
+	cp = &Checkpoint{
 		ID:        id,
 		Dir:       checkpointDir,
 		FilesDir:  filesDir,
@@ -195,14 +484,71 @@ func Create(command string, targetPaths []string) (*Checkpoint, error) {
 	}
 
 	// Add to index for faster future lookups
-	GetIndex().Add(cp)
+	recent := s.Index().ListEntries()
+	s.Index().Add(cp)
+	var advice strings.Builder
+	adviseExclusions(&advice, cp, recent)
+	if advice.Len() > 0 {
+		logging.Info(strings.TrimSuffix(advice.String(), "\n"))
+	}
 
+	s.created(cp, start, evicted)
 	return cp, nil
 }
 
+// interrupted returns the error of an operation stopped because ctx is
+// done, or nil if it isn't
+func interrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("interrupted: %w", err)
+	}
+	return nil
+}
+
+// newID generates a unique checkpoint ID, which sorts by creation time
+func newID() string {
+	timestamp := time.Now().Format("2006-01-02T150405")
+	shortUUID := uuid.New().String()[:8]
+	return fmt.Sprintf("%s-%s", timestamp, shortUUID)
+}
+
+// created records a checkpoint added to the index in the stats and the
+// operations log, and evicts what max_checkpoints no longer allows, adding
+// it to what was evicted before creating it
+func (s *Store) created(cp *Checkpoint, start time.Time, evicted []*Eviction) {
+	manifest := cp.Manifest
+	fileCount, totalSize := countFiles(manifest)
+	elapsed := new(expvar.Int)
+	elapsed.Set(time.Since(start).Milliseconds())
+	createStats.Add("count", 1)
+	createStats.Add("files", int64(fileCount))
+	createStats.Add("bytes", totalSize)
+	createStats.Add("total_ms", elapsed.Value())
+	createStats.Set("last_ms", elapsed)
+
+	oplog.Append(oplog.Entry{
+		Op:           oplog.OpCheckpoint,
+		CheckpointID: cp.ID,
+		Command:      manifest.Command,
+		Files:        fileCount,
+		Bytes:        totalSize,
+		Session:      manifest.SessionID,
+	})
+
+	// A record of the whole store, in case backups are damaged later
+	if _, err := s.SnapshotIfDue(); err != nil {
+		logging.Warn(fmt.Sprintf("Warning: failed to snapshot checkpoints: %v", err))
+	}
+
+	if e := s.enforceMaxCheckpoints(cp); e != nil {
+		evicted = append(evicted, e)
+	}
+	cp.Evicted = evicted
+}
+
 // List returns all checkpoints sorted by creation time (newest first)
-func List() ([]*Checkpoint, error) {
-	checkpointsDir := config.GetCheckpointsDir()
+func (s *Store) List() ([]*Checkpoint, error) {
+	checkpointsDir := s.CheckpointsDir()
 	entries, err := os.ReadDir(checkpointsDir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -242,8 +588,8 @@ func List() ([]*Checkpoint, error) {
 }
 
 // Get retrieves a specific checkpoint by ID
-func Get(id string) (*Checkpoint, error) {
-	checkpointDir := filepath.Join(config.GetCheckpointsDir(), id)
+func (s *Store) Get(id string) (*Checkpoint, error) {
+	checkpointDir := s.checkpointDir(id)
 	manifest, err := LoadManifest(checkpointDir)
 	if err != nil {
 		return nil, fmt.Errorf("checkpoint not found: %s", id)
@@ -260,31 +606,84 @@ func Get(id string) (*Checkpoint, error) {
 
 // GetLatest returns the most recent checkpoint
 // Optimized to use the index for accurate timestamp comparison
-func GetLatest() (*Checkpoint, error) {
-	idx := GetIndex()
-	entries := idx.ListEntries() // Already sorted by timestamp (newest first)
+func (s *Store) GetLatest() (*Checkpoint, error) {
+	entries := s.Index().ListEntries() // Already sorted by timestamp (newest first)
 
 	if len(entries) == 0 {
 		return nil, fmt.Errorf("no checkpoints found")
 	}
 
-	return Get(entries[0].ID)
+	return s.Get(entries[0].ID)
 }
 
-// Delete removes a checkpoint
-func Delete(id string) error {
-	checkpointDir := filepath.Join(config.GetCheckpointsDir(), id)
+// Delete removes a checkpoint.
+// Checkpoints younger than the policy's minimum retention cannot be deleted,
+// nor can those whose age isn't known while there is one.
+func (s *Store) Delete(id string) error {
+	if minDays := config.MinRetentionDays(); minDays > 0 {
+		created, err := s.createdAt(id)
+		if err != nil {
+			return fmt.Errorf("checkpoint %s may be protected by policy (minimum retention %d days), and its age is unknown: %w", id, minDays, err)
+		}
+		if time.Since(created) < time.Duration(minDays)*24*time.Hour {
+			return fmt.Errorf("checkpoint %s is protected by policy (minimum retention %d days)", id, minDays)
+		}
+	}
+
+	// What was deleted, for the operations log
+	logged := oplog.Entry{Op: oplog.OpDelete, CheckpointID: id}
+	if entry := s.Index().GetEntry(id); entry != nil {
+		logged.Command, logged.Session = entry.Command, entry.SessionID
+	}
+
+	checkpointDir := s.checkpointDir(id)
+	if m, err := readManifest(checkpointDir); err == nil && m.FSSnapshot != nil {
+		// Left behind rather than keeping the checkpoint, as it may need privileges to delete
+		if err := removeFSSnapshot(m.FSSnapshot, checkpointDir); err != nil {
+			logging.Warn(fmt.Sprintf("Warning: failed to delete the %s snapshot %s of checkpoint %s: %v", m.FSSnapshot.Backend, m.FSSnapshot.Name, id, err))
+		}
+	}
 	if err := os.RemoveAll(checkpointDir); err != nil {
 		return err
 	}
 	// Remove from index
-	GetIndex().Remove(id)
+	s.Index().Remove(id)
+	oplog.Append(logged)
 	return nil
 }
 
+// createdAt returns when checkpoint id was created, from the index or else
+// its manifest
+func (s *Store) createdAt(id string) (time.Time, error) {
+	if entry := s.Index().GetEntry(id); entry != nil {
+		return entry.Timestamp, nil
+	}
+	m, err := readManifest(s.checkpointDir(id))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if m.Timestamp.IsZero() {
+		return time.Time{}, fmt.Errorf("manifest has no timestamp")
+	}
+	return m.Timestamp, nil
+}
+
+// countFiles returns the number of files (excluding directories) and their total size
+func countFiles(m *Manifest) (int, int64) {
+	count := 0
+	var size int64
+	for _, f := range m.Files {
+		if !f.IsDir {
+			count++
+			size += f.Size
+		}
+	}
+	return count, size
+}
+
 // ListBySession returns checkpoints grouped by session ID
-func ListBySession() (map[string][]*Checkpoint, error) {
-	checkpoints, err := List()
+func (s *Store) ListBySession() (map[string][]*Checkpoint, error) {
+	checkpoints, err := s.List()
 	if err != nil {
 		return nil, err
 	}
@@ -301,9 +700,44 @@ func ListBySession() (map[string][]*Checkpoint, error) {
 	return grouped, nil
 }
 
+// SessionKeepers returns the IDs of the newest n checkpoints of every
+// session. checkpoints must be sorted newest first, as List returns them.
+func SessionKeepers(checkpoints []*Checkpoint, n int) map[string]bool {
+	keep := make(map[string]bool)
+	if n <= 0 {
+		return keep
+	}
+
+	perSession := make(map[string]int)
+	for _, cp := range checkpoints {
+		sessionID := cp.Manifest.SessionID
+		if sessionID == "" {
+			sessionID = "default"
+		}
+		if perSession[sessionID] < n {
+			perSession[sessionID]++
+			keep[cp.ID] = true
+		}
+	}
+	return keep
+}
+
+// Keepers returns the IDs of the checkpoints clean must leave alone
+// whatever their age: the pinned ones, and the newest keepPerSession of
+// every session. checkpoints must be sorted newest first.
+func Keepers(checkpoints []*Checkpoint, keepPerSession int) map[string]bool {
+	keep := SessionKeepers(checkpoints, keepPerSession)
+	for _, cp := range checkpoints {
+		if cp.Manifest.Pinned {
+			keep[cp.ID] = true
+		}
+	}
+	return keep
+}
+
 // GetCurrentSession returns checkpoints from the current session only
-func GetCurrentSession() ([]*Checkpoint, error) {
-	checkpoints, err := List()
+func (s *Store) GetCurrentSession() ([]*Checkpoint, error) {
+	checkpoints, err := s.List()
 	if err != nil {
 		return nil, err
 	}
@@ -320,8 +754,8 @@ func GetCurrentSession() ([]*Checkpoint, error) {
 }
 
 // AddTag adds a tag to a checkpoint
-func AddTag(id string, tag string) error {
-	cp, err := Get(id)
+func (s *Store) AddTag(id string, tag string) error {
+	cp, err := s.Get(id)
 	if err != nil {
 		return err
 	}
@@ -338,13 +772,13 @@ func AddTag(id string, tag string) error {
 		return err
 	}
 	// Update index
-	GetIndex().Update(cp)
+	s.Index().Update(cp)
 	return nil
 }
 
 // RemoveTag removes a tag from a checkpoint
-func RemoveTag(id string, tag string) error {
-	cp, err := Get(id)
+func (s *Store) RemoveTag(id string, tag string) error {
+	cp, err := s.Get(id)
 	if err != nil {
 		return err
 	}
@@ -361,13 +795,13 @@ func RemoveTag(id string, tag string) error {
 		return err
 	}
 	// Update index
-	GetIndex().Update(cp)
+	s.Index().Update(cp)
 	return nil
 }
 
 // SetNote sets the note for a checkpoint
-func SetNote(id string, note string) error {
-	cp, err := Get(id)
+func (s *Store) SetNote(id string, note string) error {
+	cp, err := s.Get(id)
 	if err != nil {
 		return err
 	}
@@ -377,13 +811,30 @@ func SetNote(id string, note string) error {
 		return err
 	}
 	// Update index
-	GetIndex().Update(cp)
+	s.Index().Update(cp)
+	return nil
+}
+
+// SetPinned pins a checkpoint, so that clean and retention never remove
+// it, or unpins it
+func (s *Store) SetPinned(id string, pinned bool) error {
+	cp, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	cp.Manifest.Pinned = pinned
+	if err := cp.Manifest.Save(cp.Dir); err != nil {
+		return err
+	}
+	// Update index
+	s.Index().Update(cp)
 	return nil
 }
 
 // ListByTag returns all checkpoints with a specific tag
-func ListByTag(tag string) ([]*Checkpoint, error) {
-	checkpoints, err := List()
+func (s *Store) ListByTag(tag string) ([]*Checkpoint, error) {
+	checkpoints, err := s.List()
 	if err != nil {
 		return nil, err
 	}
@@ -410,88 +861,101 @@ type SearchOptions struct {
 	After    time.Time
 }
 
-func Search(opts SearchOptions) ([]*Checkpoint, error) {
-	checkpoints, err := List()
-	if err != nil {
-		return nil, err
+// Search filters on the index, so only matching checkpoints' manifests are
+// loaded
+func (s *Store) Search(opts SearchOptions) ([]*Checkpoint, error) {
+	idx := s.Index()
+
+	var paths map[string][]string
+	if opts.FileName != "" {
+		var err error
+		if paths, err = idx.FilePaths(); err != nil {
+			return nil, fmt.Errorf("failed to read path index: %w", err)
+		}
 	}
 
 	var results []*Checkpoint
 
-	for _, cp := range checkpoints {
-		match := true
-
+	for _, e := range idx.ListEntries() {
 		// Filter by tag
 		if opts.Tag != "" {
 			tagFound := false
-			for _, t := range cp.Manifest.Tags {
+			for _, t := range e.Tags {
 				if strings.EqualFold(t, opts.Tag) {
 					tagFound = true
 					break
 				}
 			}
 			if !tagFound {
-				match = false
+				continue
 			}
 		}
 
 		// Filter by command
-		if match && opts.Command != "" {
-			if !strings.Contains(strings.ToLower(cp.Manifest.Command), strings.ToLower(opts.Command)) {
-				match = false
-			}
+		if opts.Command != "" && !strings.Contains(strings.ToLower(e.Command), strings.ToLower(opts.Command)) {
+			continue
 		}
 
 		// Filter by file name
-		if match && opts.FileName != "" {
+		if opts.FileName != "" {
 			fileFound := false
 			searchLower := strings.ToLower(opts.FileName)
-			for _, f := range cp.Manifest.Files {
-				if strings.Contains(strings.ToLower(f.OriginalPath), searchLower) {
+			for _, p := range paths[e.ID] {
+				if strings.Contains(strings.ToLower(p), searchLower) {
 					fileFound = true
 					break
 				}
 			}
 			if !fileFound {
-				match = false
+				continue
 			}
 		}
 
 		// Filter by date range
-		if match && !opts.After.IsZero() {
-			if cp.CreatedAt.Before(opts.After) {
-				match = false
-			}
+		if !opts.After.IsZero() && e.Timestamp.Before(opts.After) {
+			continue
 		}
-
-		if match && !opts.Before.IsZero() {
-			if cp.CreatedAt.After(opts.Before) {
-				match = false
-			}
+		if !opts.Before.IsZero() && e.Timestamp.After(opts.Before) {
+			continue
 		}
 
-		if match {
-			results = append(results, cp)
+		cp, err := s.Get(e.ID)
+		if err != nil {
+			continue // Deleted since the index was loaded
 		}
+		results = append(results, cp)
 	}
 
 	return results, nil
 }
 
-// Clean removes checkpoints older than the specified duration
-func Clean(olderThan time.Duration) (int, error) {
-	checkpoints, err := List()
+// Clean removes checkpoints older than the specified duration, keeping
+// pinned checkpoints and the newest keep_per_session of each session
+func (s *Store) Clean(olderThan time.Duration) (int, error) {
+	return s.CleanKeepingSessions(olderThan, config.Get().KeepPerSession)
+}
+
+// CleanKeepingSessions is Clean with an explicit number of checkpoints to
+// keep per session, regardless of age
+func (s *Store) CleanKeepingSessions(olderThan time.Duration, keepPerSession int) (int, error) {
+	checkpoints, err := s.List()
 	if err != nil {
 		return 0, err
 	}
 
+	// Never clean more aggressively than the policy allows
+	if minRetention := time.Duration(config.MinRetentionDays()) * 24 * time.Hour; olderThan < minRetention {
+		olderThan = minRetention
+	}
+
 	cutoff := time.Now().Add(-olderThan)
+	keep := Keepers(checkpoints, keepPerSession)
 	deleted := 0
 
 	for _, cp := range checkpoints {
-		if cp.CreatedAt.Before(cutoff) {
-			if err := Delete(cp.ID); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to delete checkpoint %s: %v\n", cp.ID, err)
+		if cp.CreatedAt.Before(cutoff) && !keep[cp.ID] {
+			if err := s.Delete(cp.ID); err != nil {
+				logging.Warn(fmt.Sprintf("Warning: failed to delete checkpoint %s: %v", cp.ID, err))
 				continue
 			}
 			deleted++
@@ -502,8 +966,14 @@ func Clean(olderThan time.Duration) (int, error) {
 }
 
 // Compress compresses a checkpoint to save disk space
-func Compress(id string) (int64, int64, error) {
-	cp, err := Get(id)
+func (s *Store) Compress(id string) (int64, int64, error) {
+	return s.CompressContext(context.Background(), id)
+}
+
+// CompressContext is Compress, stopping when ctx is done. The checkpoint is
+// then left uncompressed.
+func (s *Store) CompressContext(ctx context.Context, id string) (int64, int64, error) {
+	cp, err := s.Get(id)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -511,9 +981,18 @@ func Compress(id string) (int64, int64, error) {
 	if cp.Manifest.Compressed {
 		return 0, cp.Manifest.CompressedSize, fmt.Errorf("checkpoint already compressed")
 	}
+	if cp.Manifest.FSSnapshot != nil {
+		return 0, 0, fmt.Errorf("checkpoint is a %s snapshot, which can't be compressed", cp.Manifest.FSSnapshot.Backend)
+	}
+
+	cfg := config.Get()
+	algorithm := cfg.CompressionAlgorithm
+	if algorithm == "" {
+		algorithm = CompressionGzip
+	}
 
 	filesDir := GetFilesDir(cp.Dir)
-	archivePath := GetArchivePath(cp.Dir)
+	archivePath := GetArchivePath(cp.Dir, algorithm)
 
 	// Get original size
 	originalSize, err := GetDiskUsage(filesDir)
@@ -522,29 +1001,36 @@ func Compress(id string) (int64, int64, error) {
 	}
 
 	// Compress
-	compressedSize, err := CompressDir(filesDir, archivePath)
+	var counter *progressCounter
+	if defaultProgress != nil {
+		files, _ := countFiles(cp.Manifest)
+		counter = newProgressCounter(defaultProgress, Progress{Phase: PhaseCompress, Total: files, TotalBytes: originalSize})
+	}
+	compressedSize, err := compressDir(ctx, filesDir, archivePath, algorithm, cfg.CompressionLevel, counter)
 	if err != nil {
 		return originalSize, 0, fmt.Errorf("failed to compress: %w", err)
 	}
+	counter.finish()
 
 	// Update manifest
 	cp.Manifest.Compressed = true
 	cp.Manifest.CompressedSize = compressedSize
 	cp.Manifest.CompressedAt = time.Now()
+	cp.Manifest.CompressionAlgorithm = algorithm
 
 	if err := cp.Manifest.Save(cp.Dir); err != nil {
 		return originalSize, compressedSize, fmt.Errorf("failed to update manifest: %w", err)
 	}
 
 	// Update index
-	GetIndex().Update(cp)
+	s.Index().Update(cp)
 
 	return originalSize, compressedSize, nil
 }
 
 // Decompress decompresses a checkpoint for access
-func Decompress(id string) error {
-	cp, err := Get(id)
+func (s *Store) Decompress(id string) error {
+	cp, err := s.Get(id)
 	if err != nil {
 		return err
 	}
@@ -554,10 +1040,10 @@ func Decompress(id string) error {
 	}
 
 	filesDir := GetFilesDir(cp.Dir)
-	archivePath := GetArchivePath(cp.Dir)
+	archivePath := GetArchivePath(cp.Dir, cp.Manifest.CompressionAlgorithm)
 
 	// Decompress
-	if err := DecompressDir(archivePath, filesDir); err != nil {
+	if err := DecompressDir(archivePath, filesDir, cp.Manifest.CompressionAlgorithm); err != nil {
 		return fmt.Errorf("failed to decompress: %w", err)
 	}
 
@@ -569,20 +1055,23 @@ func Decompress(id string) error {
 	// Update manifest
 	cp.Manifest.Compressed = false
 	cp.Manifest.CompressedSize = 0
+	cp.Manifest.CompressionAlgorithm = ""
+	// The extracted files are not in the object store
+	cp.Manifest.FormatVersion = 0
 
 	if err := cp.Manifest.Save(cp.Dir); err != nil {
 		return fmt.Errorf("failed to update manifest: %w", err)
 	}
 
 	// Update index
-	GetIndex().Update(cp)
+	s.Index().Update(cp)
 
 	return nil
 }
 
 // CompressOlderThan compresses checkpoints older than the specified duration
-func CompressOlderThan(olderThan time.Duration) (int, int64, error) {
-	checkpoints, err := List()
+func (s *Store) CompressOlderThan(olderThan time.Duration) (int, int64, error) {
+	checkpoints, err := s.List()
 	if err != nil {
 		return 0, 0, err
 	}
@@ -592,10 +1081,10 @@ func CompressOlderThan(olderThan time.Duration) (int, int64, error) {
 	var totalSaved int64
 
 	for _, cp := range checkpoints {
-		if cp.CreatedAt.Before(cutoff) && !cp.Manifest.Compressed {
-			originalSize, compressedSize, err := Compress(cp.ID)
+		if cp.CreatedAt.Before(cutoff) && !cp.Manifest.Compressed && cp.Manifest.FSSnapshot == nil {
+			originalSize, compressedSize, err := s.Compress(cp.ID)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to compress checkpoint %s: %v\n", cp.ID, err)
+				logging.Warn(fmt.Sprintf("Warning: failed to compress checkpoint %s: %v", cp.ID, err))
 				continue
 			}
 			compressed++
@@ -607,9 +1096,9 @@ func CompressOlderThan(olderThan time.Duration) (int, int64, error) {
 }
 
 // EnsureDecompressed ensures a checkpoint is decompressed before access
-func EnsureDecompressed(cp *Checkpoint) error {
+func (s *Store) EnsureDecompressed(cp *Checkpoint) error {
 	if cp.Manifest.Compressed {
-		return Decompress(cp.ID)
+		return s.Decompress(cp.ID)
 	}
 	return nil
 }
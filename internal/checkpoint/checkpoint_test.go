@@ -1,8 +1,12 @@
 package checkpoint
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/qhkm/safeshell/internal/config"
@@ -17,6 +21,7 @@ func setupTestEnv(t *testing.T) (string, func()) {
 
 	// Set up config to use temp directory
 	os.Setenv("HOME", tmpDir)
+	config.Reset()
 	config.Init()
 
 	// Reset index to ensure fresh state for each test
@@ -122,9 +127,40 @@ func TestCreateCheckpointNonExistentFile(t *testing.T) {
 	}
 }
 
+func TestCreateForWriterCopies(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	testDir := filepath.Join(tmpDir, "testdata")
+	file := filepath.Join(testDir, "disk.img")
+	dir := filepath.Join(testDir, "logs")
+	os.WriteFile(file, []byte("original"), 0644)
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "app.log"), []byte("original"), 0644)
+
+	cp, err := store.CreateForWriter(context.Background(), Origin{WorkingDir: testDir}, "truncate -s 0 disk.img", []string{file, dir})
+	if err != nil {
+		t.Fatalf("CreateForWriter failed: %v", err)
+	}
+
+	// Writing in place, as truncate does, must not reach the backups
+	os.Truncate(file, 0)
+	os.Truncate(filepath.Join(dir, "app.log"), 0)
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		if data, err := os.ReadFile(f.BackupPath); err != nil || string(data) != "original" {
+			t.Errorf("Expected the backup of %s to hold the original, got %q (%v)", f.OriginalPath, data, err)
+		}
+	}
+}
+
 func TestListCheckpoints(t *testing.T) {
 	tmpDir, cleanup := setupTestEnv(t)
 	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
 
 	// Create test files
 	testFile1 := filepath.Join(tmpDir, "testdata", "file1.txt")
@@ -133,18 +169,18 @@ func TestListCheckpoints(t *testing.T) {
 	os.WriteFile(testFile2, []byte("content2"), 0644)
 
 	// Create multiple checkpoints
-	_, err := Create("rm file1.txt", []string{testFile1})
+	_, err := store.Create("rm file1.txt", []string{testFile1})
 	if err != nil {
 		t.Fatalf("Failed to create checkpoint 1: %v", err)
 	}
 
-	_, err = Create("rm file2.txt", []string{testFile2})
+	_, err = store.Create("rm file2.txt", []string{testFile2})
 	if err != nil {
 		t.Fatalf("Failed to create checkpoint 2: %v", err)
 	}
 
 	// List checkpoints
-	checkpoints, err := List()
+	checkpoints, err := store.List()
 	if err != nil {
 		t.Fatalf("Failed to list checkpoints: %v", err)
 	}
@@ -232,3 +268,512 @@ func TestDeleteCheckpoint(t *testing.T) {
 		t.Error("Checkpoint should not exist after deletion")
 	}
 }
+
+func TestDeleteRespectsPolicyMinRetention(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Install a policy requiring 3 days of retention
+	policyPath := filepath.Join(tmpDir, "policy.yaml")
+	os.WriteFile(policyPath, []byte("min_retention_days: 3\n"), 0644)
+
+	oldPolicyPath := config.PolicyPath
+	config.PolicyPath = policyPath
+	defer func() {
+		config.PolicyPath = oldPolicyPath
+		config.Init()
+	}()
+	config.Init()
+
+	if config.Get().RetentionDays < 3 {
+		t.Errorf("Expected retention to be raised to policy minimum, got %d", config.Get().RetentionDays)
+	}
+
+	testFile := filepath.Join(tmpDir, "testdata", "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+	cp, err := Create("rm test.txt", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	if err := Delete(cp.ID); err == nil {
+		t.Error("Delete should refuse checkpoints younger than the policy minimum")
+	}
+
+	// Without an index entry, the manifest tells the age
+	GetIndex().Remove(cp.ID)
+	if err := Delete(cp.ID); err == nil {
+		t.Error("Delete should refuse a checkpoint missing from the index by its manifest")
+	}
+	// And without either, the checkpoint is kept
+	manifest := filepath.Join(cp.Dir, "manifest.json")
+	os.WriteFile(manifest, []byte("{"), 0644)
+	if err := Delete(cp.ID); err == nil {
+		t.Error("Delete should refuse a checkpoint whose age is unknown")
+	}
+	if _, err := os.Stat(cp.Dir); err != nil {
+		t.Errorf("Expected the checkpoint kept: %v", err)
+	}
+
+	if deleted, _ := Clean(0); deleted != 0 {
+		t.Errorf("Clean should not delete checkpoints within policy retention, deleted %d", deleted)
+	}
+}
+
+func TestCleanKeepsNewestPerSession(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+	defer os.Unsetenv("SAFESHELL_SESSION")
+
+	testFile := filepath.Join(tmpDir, "testdata", "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+
+	newest := make(map[string]string)
+	for _, session := range []string{"alpha", "alpha", "beta", "alpha"} {
+		os.Setenv("SAFESHELL_SESSION", session)
+		cp, err := store.Create("rm test.txt", []string{testFile})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		newest[session] = cp.ID
+	}
+
+	deleted, err := store.CleanKeepingSessions(0, 1)
+	if err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 checkpoints deleted, got %d", deleted)
+	}
+
+	remaining, _ := store.List()
+	if len(remaining) != 2 {
+		t.Fatalf("Expected 2 checkpoints left, got %d", len(remaining))
+	}
+	for _, cp := range remaining {
+		if newest[cp.Manifest.SessionID] != cp.ID {
+			t.Errorf("Kept %s, which is not the newest of session %s", cp.ID, cp.Manifest.SessionID)
+		}
+	}
+}
+
+func TestCleanKeepsPinned(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	testFile := filepath.Join(tmpDir, "testdata", "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+
+	pinned, err := store.Create("rm test.txt", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if _, err := store.Create("rm test.txt", []string{testFile}); err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if err := store.SetPinned(pinned.ID, true); err != nil {
+		t.Fatalf("Failed to pin checkpoint: %v", err)
+	}
+
+	deleted, err := store.CleanKeepingSessions(0, 0)
+	if err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 checkpoint deleted, got %d", deleted)
+	}
+	remaining, _ := store.List()
+	if len(remaining) != 1 || remaining[0].ID != pinned.ID || !remaining[0].Manifest.Pinned {
+		t.Errorf("Expected only the pinned checkpoint left, got %v", remaining)
+	}
+	if entry := store.Index().GetEntry(pinned.ID); entry == nil || !entry.Pinned {
+		t.Error("Expected the index entry to be pinned")
+	}
+}
+
+func TestCreateRunsHooks(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testFile := filepath.Join(tmpDir, "testdata", "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+	post := filepath.Join(tmpDir, "post.out")
+
+	hooks := &config.Get().Hooks
+	defer func() { *hooks = config.HooksConfig{} }()
+	hooks.PostCheckpoint = `echo "$SAFESHELL_CHECKPOINT_ID" > ` + post
+
+	cp, err := Create("rm test.txt", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if data, _ := os.ReadFile(post); strings.TrimSpace(string(data)) != cp.ID {
+		t.Errorf("post_checkpoint hook should see ID %s, got %q", cp.ID, data)
+	}
+
+	// A failing pre hook aborts the checkpoint
+	before, _ := List()
+	hooks.PreCheckpoint = "exit 1"
+	if _, err := Create("rm test.txt", []string{testFile}); err == nil {
+		t.Error("Expected failing pre_checkpoint hook to abort the checkpoint")
+	}
+	if after, _ := List(); len(after) != len(before) {
+		t.Errorf("Aborted checkpoint should not be saved, had %d now %d", len(before), len(after))
+	}
+}
+
+func TestCreateForOrigin(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "testdata")
+	os.WriteFile(filepath.Join(testDir, "a.txt"), []byte("a"), 0644)
+
+	cp, err := CreateFor(Origin{WorkingDir: testDir, SessionID: "client"}, "rm a.txt", []string{"a.txt"})
+	if err != nil {
+		t.Fatalf("CreateFor failed: %v", err)
+	}
+	if cp.Manifest.WorkingDir != testDir || cp.Manifest.SessionID != "client" {
+		t.Errorf("Expected origin to be recorded, got %q / %q", cp.Manifest.WorkingDir, cp.Manifest.SessionID)
+	}
+	if len(cp.Manifest.Files) != 1 || cp.Manifest.Files[0].OriginalPath != filepath.Join(testDir, "a.txt") {
+		t.Errorf("Expected a.txt resolved against the origin, got %v", cp.Manifest.Files)
+	}
+
+	if _, err := CreateFor(Origin{WorkingDir: "relative"}, "rm a.txt", []string{"a.txt"}); err == nil {
+		t.Error("Expected error for relative working directory")
+	}
+}
+
+func TestIndexReloadSeesOtherProcesses(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testFile := filepath.Join(tmpDir, "testdata", "a.txt")
+	os.WriteFile(testFile, []byte("a"), 0644)
+	cp, err := Create("rm a.txt", []string{testFile})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Nothing changed on disk: the loaded index stays as is
+	idx := GetIndex()
+	if err := idx.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if idx.GetEntry(cp.ID) == nil {
+		t.Fatal("Expected checkpoint in index")
+	}
+
+	// Another process deletes the checkpoint and rewrites the index
+	other := NewStore(config.GetSafeShellDir()).Index()
+	os.RemoveAll(cp.Dir)
+	other.Remove(cp.ID)
+
+	if err := idx.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if idx.GetEntry(cp.ID) != nil {
+		t.Error("Expected reloaded index to drop the deleted checkpoint")
+	}
+}
+
+func TestSearchByFileUsesPathIndex(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	testDir := filepath.Join(tmpDir, "testdata")
+	main := filepath.Join(testDir, "main.go")
+	readme := filepath.Join(testDir, "README.md")
+	os.WriteFile(main, []byte("package main"), 0644)
+	os.WriteFile(readme, []byte("# readme"), 0644)
+
+	goCp, _ := store.Create("rm main.go", []string{main})
+	docCp, _ := store.Create("rm README.md", []string{readme})
+	store.AddTag(docCp.ID, "docs")
+
+	results, err := store.Search(SearchOptions{FileName: "MAIN.GO"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != goCp.ID {
+		t.Fatalf("Expected only %s, got %d result(s)", goCp.ID, len(results))
+	}
+
+	// A lost path index is rebuilt from the manifests
+	os.Remove(pathIndexPath(store.CheckpointsDir()))
+	results, _ = store.Search(SearchOptions{FileName: "readme", Tag: "docs"})
+	if len(results) != 1 || results[0].ID != docCp.ID {
+		t.Fatalf("Expected only %s, got %d result(s)", docCp.ID, len(results))
+	}
+	if _, err := os.Stat(pathIndexPath(store.CheckpointsDir())); err != nil {
+		t.Errorf("Expected the path index to be recreated: %v", err)
+	}
+
+	// Deleted checkpoints drop out of the results
+	store.Delete(goCp.ID)
+	if results, _ := store.Search(SearchOptions{FileName: "main.go"}); len(results) != 0 {
+		t.Errorf("Expected no results after delete, got %d", len(results))
+	}
+}
+
+func TestCreateRecordsParentModes(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	shared := filepath.Join(project, "shared")
+	os.MkdirAll(shared, 0755)
+	file := filepath.Join(shared, "a.txt")
+	os.WriteFile(file, []byte("a"), 0644)
+	SetDirMode(shared, 0775|os.ModeSetgid)
+
+	cp, err := Create("rm project/shared/a.txt", []string{file})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	modes := cp.Manifest.ParentModes
+	if got := modes[shared] &^ os.ModeDir; got != 0775|os.ModeSetgid {
+		t.Errorf("Expected %s recorded with setgid, got %v", shared, got)
+	}
+	for _, dir := range []string{project, tmpDir, "/"} {
+		if _, ok := modes[dir]; !ok {
+			t.Errorf("Expected %s to be recorded", dir)
+		}
+	}
+	if _, ok := modes[file]; ok {
+		t.Error("The target itself is not a parent")
+	}
+}
+
+func TestCreateCloudPlaceholders(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	defer func() { cloudOnly = isPlaceholder }()
+	cloudOnly = func(info os.FileInfo) bool { return info.Name() == "online-only.txt" }
+
+	dir := filepath.Join(tmpDir, "testdata", "OneDrive")
+	os.MkdirAll(dir, 0755)
+	local := filepath.Join(dir, "local.txt")
+	cloud := filepath.Join(dir, "online-only.txt")
+	os.WriteFile(local, []byte("local"), 0644)
+	os.WriteFile(cloud, []byte("cloud"), 0644)
+
+	backupOf := func(cp *Checkpoint, path string) *FileEntry {
+		for i, f := range cp.Manifest.Files {
+			if f.OriginalPath == path {
+				return &cp.Manifest.Files[i]
+			}
+		}
+		return nil
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		cp, err := Create("rm -rf OneDrive", []string{dir})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		if backupOf(cp, local) == nil {
+			t.Error("Expected the local file to be backed up")
+		}
+		if backupOf(cp, cloud) != nil {
+			t.Error("Expected the placeholder not to be backed up")
+		}
+		if _, err := os.Stat(filepath.Join(cp.FilesDir, strings.TrimPrefix(cloud, "/"))); !os.IsNotExist(err) {
+			t.Error("Expected no backup copy of the placeholder")
+		}
+		if len(cp.Manifest.Placeholders) != 1 || cp.Manifest.Placeholders[0] != cloud {
+			t.Errorf("Expected the placeholder to be recorded, got %v", cp.Manifest.Placeholders)
+		}
+	})
+
+	t.Run("hydrate", func(t *testing.T) {
+		config.Get().CloudPlaceholders = PlaceholdersHydrate
+		defer func() { config.Get().CloudPlaceholders = PlaceholdersSkip }()
+
+		cp, err := Create("rm online-only.txt", []string{cloud})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		entry := backupOf(cp, cloud)
+		if entry == nil {
+			t.Fatal("Expected the placeholder to be backed up")
+		}
+		original, _ := os.Stat(cloud)
+		backup, err := os.Stat(entry.BackupPath)
+		if err != nil || os.SameFile(original, backup) {
+			t.Error("Expected the placeholder to be copied, not linked")
+		}
+		if len(cp.Manifest.Placeholders) != 0 {
+			t.Errorf("Expected nothing skipped, got %v", cp.Manifest.Placeholders)
+		}
+	})
+}
+
+func TestCreateScopesToProject(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	work := filepath.Join(tmpDir, "testdata", "work")
+	project := filepath.Join(work, "repo", "services", "api")
+	os.MkdirAll(project, 0755)
+	os.WriteFile(filepath.Join(work, "repo", "go.mod"), []byte("module repo"), 0644)
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(work, "notes.txt"), []byte("notes"), 0644)
+
+	backedUp := func(cp *Checkpoint) map[string]bool {
+		files := make(map[string]bool)
+		for _, f := range cp.Manifest.Files {
+			if !f.IsDir {
+				files[strings.TrimPrefix(f.OriginalPath, work+string(filepath.Separator))] = true
+			}
+		}
+		return files
+	}
+	origin := Origin{WorkingDir: project, SessionID: "test"}
+
+	cp, err := CreateFor(origin, "rm -rf ../../..", []string{"../../..", "../.."})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if files := backedUp(cp); !files["notes.txt"] {
+		t.Errorf("Expected everything backed up with scope_to_project off, got %v", files)
+	}
+
+	config.Get().ScopeToProject = true
+	defer func() { config.Get().ScopeToProject = false }()
+
+	cp, err = CreateFor(origin, "rm -rf ../../..", []string{"../../..", "../.."})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	files := backedUp(cp)
+	if files["notes.txt"] {
+		t.Error("Expected files outside the project not to be backed up")
+	}
+	if !files[filepath.Join("repo", "go.mod")] || !files[filepath.Join("repo", "services", "api", "main.go")] {
+		t.Errorf("Expected the project to be backed up, got %v", files)
+	}
+	if len(cp.Manifest.Files) != len(uniquePaths(cp)) {
+		t.Error("Expected the project to be backed up once")
+	}
+
+	// Targets inside the project are left alone
+	cp, err = CreateFor(origin, "rm main.go", []string{"main.go"})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if files := backedUp(cp); len(files) != 1 {
+		t.Errorf("Expected only main.go backed up, got %v", files)
+	}
+}
+
+func uniquePaths(cp *Checkpoint) map[string]bool {
+	paths := make(map[string]bool)
+	for _, f := range cp.Manifest.Files {
+		paths[f.OriginalPath] = true
+	}
+	return paths
+}
+
+func TestCreateWithProgress(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	dir := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0644)
+	os.WriteFile(filepath.Join(dir, "src", "b.txt"), []byte("beta"), 0644)
+	single := filepath.Join(tmpDir, "testdata", "c.txt")
+	os.WriteFile(single, []byte("gamma"), 0644)
+
+	// Reports from backup workers are serialized
+	var events []Progress
+	_, err := store.CreateWithProgress("rm -rf project c.txt", []string{dir, single}, func(p Progress) {
+		events = append(events, p)
+	})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	if len(events) < 3 || events[0].Phase != PhaseScan {
+		t.Fatalf("Expected a scan, then backup progress, got %+v", events)
+	}
+	size := int64(len("alpha") + len("beta") + len("gamma"))
+	if start := events[1]; start.Phase != PhaseBackup || start.Done != 0 || start.Total != 3 || start.TotalBytes != size {
+		t.Errorf("Expected the backup to start with the scanned totals, got %+v", start)
+	}
+	if last := events[len(events)-1]; last.Done != 3 || last.Total != 3 || last.Bytes != size {
+		t.Errorf("Expected the backup to end with every file, got %+v", last)
+	}
+
+	// The default receives the progress of operations given none
+	var compressed Progress
+	ReportProgressTo(func(p Progress) { compressed = p })
+	defer ReportProgressTo(nil)
+	cp, err := store.Create("rm c.txt", []string{single})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if _, _, err := store.Compress(cp.ID); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if compressed.Phase != PhaseCompress || compressed.Done != 1 || compressed.Total != 1 {
+		t.Errorf("Expected the compression reported to the default, got %+v", compressed)
+	}
+}
+
+func TestCreateInterrupted(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	dir := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(dir, 0755)
+	for i := 0; i < 20; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("%02d.txt", i)), []byte("data"), 0644)
+	}
+
+	// Interrupted once the checkpoint directory is there
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	origin := Origin{WorkingDir: tmpDir}
+	_, err := store.create(ctx, origin, "rm -rf project", []string{dir}, createOptions{progress: func(p Progress) {
+		if p.Phase == PhaseBackup {
+			cancel()
+		}
+	}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the checkpoint interrupted, got %v", err)
+	}
+	if entries, _ := os.ReadDir(store.CheckpointsDir()); len(entries) != 0 {
+		t.Errorf("Expected the partial checkpoint removed, got %d entries", len(entries))
+	}
+	if entries := store.Index().ListEntries(); len(entries) != 0 {
+		t.Errorf("Expected nothing indexed, got %d entries", len(entries))
+	}
+
+	cp, err := store.Create("rm -rf project", []string{dir})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if _, _, err := store.CompressContext(ctx, cp.ID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the compression interrupted, got %v", err)
+	}
+	cp, _ = store.Get(cp.ID)
+	if cp.Manifest.Compressed {
+		t.Error("Expected an interrupted compression to leave the checkpoint uncompressed")
+	}
+	if archives, _ := filepath.Glob(filepath.Join(cp.Dir, "files.tar*")); len(archives) != 0 {
+		t.Errorf("Expected no partial archive left, got %v", archives)
+	}
+	if err := Verify(cp); err != nil {
+		t.Errorf("Expected the checkpoint intact, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// devicePair is the devices of a file and of the directory its backup goes
+// in
+type devicePair struct{ src, dst uint64 }
+
+// cloneUnsupported holds the device pairs cloning has failed between, as on
+// ext4 or tmpfs, or across filesystems. Each attempt costs an open, a
+// create, the clone and an unlink, so it isn't made there again.
+var cloneUnsupported sync.Map // devicePair -> struct{}
+
+// tryClone is cloneFile for src, described by info, unless cloning from its
+// device to dst's directory's has failed before
+func tryClone(src, dst string, info os.FileInfo) error {
+	dir, err := os.Stat(filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	pair := devicePair{device(info), device(dir)}
+	if _, ok := cloneUnsupported.Load(pair); ok {
+		return errors.ErrUnsupported
+	}
+	err = cloneFile(src, dst, info.Mode())
+	if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, syscall.EXDEV) {
+		cloneUnsupported.Store(pair, struct{}{})
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+package checkpoint
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as a copy-on-write clone of src with clonefile, on
+// APFS. It fails if dst exists or the filesystem doesn't support clones.
+func cloneFile(src, dst string, mode os.FileMode) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
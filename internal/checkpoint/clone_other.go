@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package checkpoint
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile creates dst as a copy-on-write clone of src. Clones aren't
+// supported on this platform, so files are always copied.
+func cloneFile(src, dst string, mode os.FileMode) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,399 @@
+package checkpoint
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// StoreFormat is the newest checkpoint storage format. Format 2 records the
+// SHA-256 of every file in the manifest. Uncompressed files are hard links
+// into an object store shared by all checkpoints, so identical content is
+// stored once; compressed checkpoints use zstd. Checkpoints are created in
+// the faster format 0 and converted by 'safeshell store compact'.
+const StoreFormat = 2
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.ObjectsDir(), hash[:2], hash)
+}
+
+// NeedsCompaction reports whether a checkpoint is in an older storage
+// format, or was compressed with another algorithm after compaction
+func NeedsCompaction(cp *Checkpoint) bool {
+	m := cp.Manifest
+	if m.FSSnapshot != nil {
+		return false // The snapshot holds the backups
+	}
+	return m.FormatVersion < StoreFormat || m.Compressed && m.CompressionAlgorithm != CompressionZstd
+}
+
+// Compact rewrites a checkpoint in the newest storage format. The new layout
+// is validated against the recorded hashes before the old one is removed; on
+// error the checkpoint is left as it was. Returns the bytes saved, which can
+// be negative when files shared with the originals had to be copied.
+func (s *Store) Compact(cp *Checkpoint) (int64, error) {
+	if !NeedsCompaction(cp) {
+		return 0, nil
+	}
+
+	var saved int64
+	var err error
+	if cp.Manifest.Compressed {
+		saved, err = compactArchive(cp)
+	} else {
+		saved, err = s.compactFiles(cp)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cp.Manifest.FormatVersion = StoreFormat
+	if err := cp.Manifest.Save(cp.Dir); err != nil {
+		return saved, fmt.Errorf("failed to update manifest: %w", err)
+	}
+	s.Index().Update(cp)
+	return saved, nil
+}
+
+// backupRel returns a manifest entry's path inside the files directory
+func backupRel(cp *Checkpoint, f FileEntry) (string, error) {
+	rel, err := filepath.Rel(GetFilesDir(cp.Dir), f.BackupPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("backup path %s is outside the checkpoint", f.BackupPath)
+	}
+	return rel, nil
+}
+
+// setHashes records the hash of every file in the manifest. hashes maps
+// paths inside the files directory to content hashes.
+func setHashes(cp *Checkpoint, hashes map[string]string) error {
+	for i, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		rel, err := backupRel(cp, f)
+		if err != nil {
+			return err
+		}
+		hash, ok := hashes[filepath.ToSlash(rel)]
+		if !ok {
+			return fmt.Errorf("backup of %s is missing", f.OriginalPath)
+		}
+		cp.Manifest.Files[i].Hash = hash
+	}
+	return nil
+}
+
+// treeHashes returns the hash of every regular file below dir
+func treeHashes(dir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := contentHash(path)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	return hashes, err
+}
+
+// validate checks that every file in the manifest has its recorded hash in
+// a rewritten layout, given the hashes of the files it holds
+func validate(cp *Checkpoint, hashes map[string]string) error {
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		rel, err := backupRel(cp, f)
+		if err != nil {
+			return err
+		}
+		if hashes[filepath.ToSlash(rel)] != f.Hash {
+			return fmt.Errorf("validation failed: %s does not match its hash", f.OriginalPath)
+		}
+	}
+	return nil
+}
+
+// compactFiles replaces the files directory with hard links into the object
+// store
+func (s *Store) compactFiles(cp *Checkpoint) (int64, error) {
+	filesDir := GetFilesDir(cp.Dir)
+	newDir := filesDir + ".compact"
+	os.RemoveAll(newDir)
+
+	var saved int64
+	var dirs dirModes
+	hashes := make(map[string]string)
+	err := filepath.Walk(filesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(filesDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(newDir, rel)
+
+		if info.IsDir() {
+			return dirs.mkdir(target, info.Mode())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		hash, err := contentHash(path)
+		if err != nil {
+			return err
+		}
+		object, delta, err := s.storeObject(path, info, hash)
+		if err != nil {
+			return err
+		}
+		saved += delta
+		if err := os.Link(object, target); err != nil {
+			return fmt.Errorf("failed to link object: %w", err)
+		}
+		hashes[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err == nil {
+		err = dirs.apply()
+	}
+	if err == nil {
+		err = setHashes(cp, hashes)
+	}
+	if err == nil {
+		// Read the new layout back, through the object store
+		var stored map[string]string
+		if stored, err = treeHashes(newDir); err == nil {
+			err = validate(cp, stored)
+		}
+	}
+	if err != nil {
+		removeTree(newDir)
+		return 0, err
+	}
+
+	oldDir := filesDir + ".old"
+	if err := os.Rename(filesDir, oldDir); err != nil {
+		removeTree(newDir)
+		return 0, fmt.Errorf("failed to replace files: %w", err)
+	}
+	if err := os.Rename(newDir, filesDir); err != nil {
+		os.Rename(oldDir, filesDir)
+		removeTree(newDir)
+		return 0, fmt.Errorf("failed to replace files: %w", err)
+	}
+	if err := removeTree(oldDir); err != nil {
+		return saved, fmt.Errorf("failed to remove old layout: %w", err)
+	}
+	return saved, nil
+}
+
+// storeObject makes sure the object store holds the content of path, and
+// returns the object and how many bytes replacing path with it saves. A file
+// only we link to becomes the object itself; one that may still be linked
+// from the user's files is copied, so in-place edits can't reach the store.
+func (s *Store) storeObject(path string, info os.FileInfo, hash string) (string, int64, error) {
+	object := s.objectPath(hash)
+	exclusive := linkCount(info) == 1
+
+	if _, err := os.Stat(object); err == nil {
+		if exclusive {
+			return object, info.Size(), nil
+		}
+		return object, 0, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(object), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create object store: %w", err)
+	}
+	if exclusive {
+		if err := os.Link(path, object); err == nil {
+			return object, 0, os.Chmod(object, 0444)
+		}
+	}
+
+	tmp := object + ".tmp"
+	if err := copyFile(path, tmp); err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+	if err := os.Chmod(tmp, 0444); err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, object); err != nil {
+		os.Remove(tmp)
+		return "", 0, fmt.Errorf("failed to store object: %w", err)
+	}
+	return object, -info.Size(), nil
+}
+
+// compactArchive records hashes for a compressed checkpoint and re-encodes
+// its archive with zstd
+func compactArchive(cp *Checkpoint) (int64, error) {
+	algorithm := cp.Manifest.CompressionAlgorithm
+	archivePath := GetArchivePath(cp.Dir, algorithm)
+	tmpDir := filepath.Join(cp.Dir, "files.compact")
+	removeTree(tmpDir)
+
+	if err := DecompressDir(archivePath, tmpDir, algorithm); err != nil {
+		removeTree(tmpDir)
+		return 0, err
+	}
+	hashes, err := treeHashes(tmpDir)
+	if err == nil {
+		err = setHashes(cp, hashes)
+	}
+	if err != nil {
+		removeTree(tmpDir)
+		return 0, err
+	}
+
+	if algorithm == CompressionZstd {
+		// Already current: only the hashes were missing
+		removeTree(tmpDir)
+		return 0, nil
+	}
+
+	level := 0
+	if cfg := config.Get(); cfg.CompressionAlgorithm == CompressionZstd {
+		level = cfg.CompressionLevel
+	}
+	newPath := GetArchivePath(cp.Dir, CompressionZstd)
+	newSize, err := CompressDir(tmpDir, newPath, CompressionZstd, level)
+	removeTree(tmpDir)
+	if err == nil {
+		var stored map[string]string
+		if stored, err = archiveHashes(newPath, CompressionZstd); err == nil {
+			err = validate(cp, stored)
+		}
+	}
+	if err != nil {
+		os.Remove(newPath)
+		return 0, err
+	}
+
+	oldSize := cp.Manifest.CompressedSize
+	if info, err := os.Stat(archivePath); err == nil {
+		oldSize = info.Size()
+	}
+	cp.Manifest.CompressionAlgorithm = CompressionZstd
+	cp.Manifest.CompressedSize = newSize
+	if err := cp.Manifest.Save(cp.Dir); err != nil {
+		os.Remove(newPath)
+		return 0, fmt.Errorf("failed to update manifest: %w", err)
+	}
+	if err := os.Remove(archivePath); err != nil {
+		return oldSize - newSize, fmt.Errorf("failed to remove old archive: %w", err)
+	}
+	return oldSize - newSize, nil
+}
+
+// archiveHashes returns the hash of every regular file in an archive
+func archiveHashes(archivePath, algorithm string) (map[string]string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	r, err := newDecompressReader(f, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	hashes := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		hashes[filepath.ToSlash(filepath.Clean(header.Name))] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+}
+
+// PruneObjects removes objects no checkpoint links to anymore, returning
+// how many were removed and the bytes freed
+func (s *Store) PruneObjects() (int, int64, error) {
+	removed := 0
+	var freed int64
+	err := filepath.Walk(s.ObjectsDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || linkCount(info) != 1 {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
+
+// contentHash returns the SHA-256 of a file, as recorded in manifests
+func contentHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// removeTree is os.RemoveAll for trees that may contain read-only
+// directories
+func removeTree(path string) error {
+	makeWritable(path)
+	return os.RemoveAll(path)
+}
+
+// makeWritable makes the directories in the tree at path writable by us
+func makeWritable(path string) {
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(p, info.Mode().Perm()|0700)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,156 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompactDedupsFiles(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	a := filepath.Join(tmpDir, "testdata", "a.txt")
+	b := filepath.Join(tmpDir, "testdata", "b.txt")
+	os.WriteFile(a, []byte("same content"), 0644)
+	os.WriteFile(b, []byte("same content"), 0600)
+
+	cp1, _ := Create("rm a.txt", []string{a})
+	cp2, _ := Create("rm b.txt", []string{b})
+	// Deleted originals leave the backups as the only links
+	os.Remove(a)
+	os.Remove(b)
+
+	for _, cp := range []*Checkpoint{cp1, cp2} {
+		if !NeedsCompaction(cp) {
+			t.Fatalf("New checkpoint %s should need compaction", cp.ID)
+		}
+		if _, err := Compact(cp); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+	}
+
+	cp1, _ = Get(cp1.ID)
+	cp2, _ = Get(cp2.ID)
+	if NeedsCompaction(cp1) || cp1.Manifest.FormatVersion != StoreFormat {
+		t.Errorf("Expected format %d, got %d", StoreFormat, cp1.Manifest.FormatVersion)
+	}
+	f1, f2 := cp1.Manifest.Files[0], cp2.Manifest.Files[0]
+	if f1.Hash == "" || f1.Hash != f2.Hash {
+		t.Errorf("Expected equal hashes, got %q and %q", f1.Hash, f2.Hash)
+	}
+
+	// Both backups are the same object
+	info1, err1 := os.Stat(f1.BackupPath)
+	info2, err2 := os.Stat(f2.BackupPath)
+	if err1 != nil || err2 != nil || !os.SameFile(info1, info2) {
+		t.Fatalf("Expected both backups to share one object (%v, %v)", err1, err2)
+	}
+	if data, _ := os.ReadFile(f2.BackupPath); string(data) != "same content" {
+		t.Errorf("Unexpected backup content %q", data)
+	}
+	if f2.Mode.Perm() != 0600 {
+		t.Errorf("Expected the manifest to keep mode 0600, got %v", f2.Mode.Perm())
+	}
+
+	// The object stays while a checkpoint uses it
+	Delete(cp1.ID)
+	if removed, _, _ := PruneObjects(); removed != 0 {
+		t.Errorf("Expected no objects pruned, got %d", removed)
+	}
+	Delete(cp2.ID)
+	if removed, _, _ := PruneObjects(); removed != 1 {
+		t.Errorf("Expected 1 object pruned, got %d", removed)
+	}
+}
+
+func TestCompactCopiesFilesSharedWithOriginals(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	a := filepath.Join(tmpDir, "testdata", "a.txt")
+	os.WriteFile(a, []byte("still here"), 0644)
+	cp, _ := Create("chmod 600 a.txt", []string{a})
+
+	saved, err := Compact(cp)
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if saved != -int64(len("still here")) {
+		t.Errorf("Expected the copy to cost %d bytes, got %d", len("still here"), -saved)
+	}
+
+	// Editing the original in place must not reach the checkpoint
+	os.WriteFile(a, []byte("edited"), 0644)
+	cp, _ = Get(cp.ID)
+	if data, _ := os.ReadFile(cp.Manifest.Files[0].BackupPath); string(data) != "still here" {
+		t.Errorf("Expected backup to keep its content, got %q", data)
+	}
+}
+
+func TestCompactArchive(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("archived"), 0644)
+	cp, _ := Create("rm -rf project", []string{dir})
+	if _, _, err := Compress(cp.ID); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	cp, _ = Get(cp.ID)
+	if cp.Manifest.CompressionAlgorithm != CompressionGzip {
+		t.Fatalf("Expected gzip archive, got %q", cp.Manifest.CompressionAlgorithm)
+	}
+
+	if _, err := Compact(cp); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	cp, _ = Get(cp.ID)
+	if cp.Manifest.CompressionAlgorithm != CompressionZstd || NeedsCompaction(cp) {
+		t.Errorf("Expected a current zstd checkpoint, got %q format %d", cp.Manifest.CompressionAlgorithm, cp.Manifest.FormatVersion)
+	}
+	if _, err := os.Stat(GetArchivePath(cp.Dir, CompressionGzip)); !os.IsNotExist(err) {
+		t.Error("Expected the gzip archive to be removed")
+	}
+
+	if err := Decompress(cp.ID); err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	cp, _ = Get(cp.ID)
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		if hash, err := contentHash(f.BackupPath); err != nil || hash != f.Hash {
+			t.Errorf("%s does not match its recorded hash (%v)", f.OriginalPath, err)
+		}
+	}
+}
+
+func TestCompactLeavesBrokenCheckpoint(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	a := filepath.Join(tmpDir, "testdata", "a.txt")
+	b := filepath.Join(tmpDir, "testdata", "b.txt")
+	os.WriteFile(a, []byte("a"), 0644)
+	os.WriteFile(b, []byte("b"), 0644)
+	cp, _ := Create("rm a.txt b.txt", []string{a, b})
+	os.Remove(cp.Manifest.Files[0].BackupPath)
+
+	if _, err := Compact(cp); err == nil {
+		t.Fatal("Expected compaction to fail with a missing backup")
+	}
+	cp, _ = Get(cp.ID)
+	if !NeedsCompaction(cp) {
+		t.Error("Failed checkpoint should keep its old format")
+	}
+	if _, err := os.Stat(cp.Manifest.Files[1].BackupPath); err != nil {
+		t.Errorf("Remaining backup should be untouched: %v", err)
+	}
+	if _, err := os.Stat(GetFilesDir(cp.Dir) + ".compact"); !os.IsNotExist(err) {
+		t.Error("Expected the partial layout to be cleaned up")
+	}
+}
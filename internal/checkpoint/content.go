@@ -0,0 +1,81 @@
+package checkpoint
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindFile returns the manifest entry for a file in cp. path may be
+// absolute, relative to the current directory or to the directory the
+// checkpoint was created in, or a unique suffix such as "src/main.go".
+func FindFile(cp *Checkpoint, path string) (FileEntry, error) {
+	candidates := []string{filepath.Clean(path)}
+	if !filepath.IsAbs(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			candidates = append(candidates, abs)
+		}
+		if cp.Manifest.WorkingDir != "" {
+			candidates = append(candidates, filepath.Join(cp.Manifest.WorkingDir, path))
+		}
+	}
+	for _, c := range candidates {
+		for _, f := range cp.Manifest.Files {
+			if !f.IsDir && f.OriginalPath == c {
+				return f, nil
+			}
+		}
+	}
+
+	var matches []FileEntry
+	suffix := string(filepath.Separator) + filepath.Clean(path)
+	for _, f := range cp.Manifest.Files {
+		if !f.IsDir && strings.HasSuffix(f.OriginalPath, suffix) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return FileEntry{}, fmt.Errorf("%s is not in checkpoint %s", path, cp.ID)
+	case 1:
+		return matches[0], nil
+	}
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = m.OriginalPath
+	}
+	return FileEntry{}, fmt.Errorf("%s matches several files in checkpoint %s:\n  %s", path, cp.ID, strings.Join(paths, "\n  "))
+}
+
+// StreamFile copies the backed-up content of a file in cp to w. Compressed
+// checkpoints are read from the archive without extracting anything else.
+func StreamFile(cp *Checkpoint, f FileEntry, w io.Writer) error {
+	if f.IsDir {
+		return fmt.Errorf("%s is a directory", f.OriginalPath)
+	}
+
+	if !cp.Manifest.Compressed {
+		if err := MountFSSnapshot(cp); err != nil {
+			return fmt.Errorf("failed to mount snapshot: %w", err)
+		}
+		file, err := os.Open(f.BackupPath)
+		if err != nil {
+			return fmt.Errorf("failed to open backup of %s: %w", f.OriginalPath, err)
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	}
+
+	name, err := filepath.Rel(cp.FilesDir, f.BackupPath)
+	if err != nil || strings.HasPrefix(name, "..") {
+		return fmt.Errorf("backup path %s is outside the checkpoint", f.BackupPath)
+	}
+	algorithm := cp.Manifest.CompressionAlgorithm
+	if err := ExtractArchiveFile(GetArchivePath(cp.Dir, algorithm), algorithm, name, w); err != nil {
+		return fmt.Errorf("failed to read %s from archive: %w", f.OriginalPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package checkpoint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindFile(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(filepath.Join(project, "src"), 0755)
+	os.MkdirAll(filepath.Join(project, "docs"), 0755)
+	mainGo := filepath.Join(project, "src", "main.go")
+	os.WriteFile(mainGo, []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(project, "src", "README.md"), []byte("src"), 0644)
+	os.WriteFile(filepath.Join(project, "docs", "README.md"), []byte("docs"), 0644)
+
+	cp, err := CreateFor(Origin{WorkingDir: project}, "rm -rf src docs", []string{"src", "docs"})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	for _, path := range []string{mainGo, "src/main.go", "main.go"} {
+		f, err := FindFile(cp, path)
+		if err != nil {
+			t.Errorf("FindFile(%q) failed: %v", path, err)
+		} else if f.OriginalPath != mainGo {
+			t.Errorf("FindFile(%q) found %s", path, f.OriginalPath)
+		}
+	}
+
+	if _, err := FindFile(cp, "README.md"); err == nil || !strings.Contains(err.Error(), "several") {
+		t.Errorf("Expected an ambiguous match error, got %v", err)
+	}
+	if _, err := FindFile(cp, "src"); err == nil {
+		t.Error("Directories should not be found")
+	}
+	if _, err := FindFile(cp, "missing.go"); err == nil {
+		t.Error("Expected an error for a file not in the checkpoint")
+	}
+}
+
+func TestStreamFile(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "testdata", "notes.txt")
+	os.WriteFile(file, []byte("original notes"), 0644)
+	cp, err := Create("rm notes.txt", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	os.Remove(file)
+
+	read := func() string {
+		t.Helper()
+		cp, err := Get(cp.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		f, err := FindFile(cp, file)
+		if err != nil {
+			t.Fatalf("FindFile failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := StreamFile(cp, f, &buf); err != nil {
+			t.Fatalf("StreamFile failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	if got := read(); got != "original notes" {
+		t.Errorf("Expected original content, got %q", got)
+	}
+
+	// Compressed checkpoints are read from the archive in place
+	if _, _, err := Compress(cp.ID); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if got := read(); got != "original notes" {
+		t.Errorf("Expected original content from the archive, got %q", got)
+	}
+	if _, err := os.Stat(GetFilesDir(cp.Dir)); !os.IsNotExist(err) {
+		t.Error("Reading a file should not extract the archive")
+	}
+}
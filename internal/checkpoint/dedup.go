@@ -0,0 +1,165 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DuplicateContent is content backed up more than once
+type DuplicateContent struct {
+	Hash   string
+	Size   int64
+	Copies int      // Backups with this content
+	Stored int      // Of those, the ones taking space of their own
+	Paths  []string // Original paths, each once
+}
+
+// Wasted is the space taken by stored copies beyond the first
+func (d DuplicateContent) Wasted() int64 {
+	return int64(d.Stored-1) * d.Size
+}
+
+// DedupStats is how much space exact-duplicate backups take. Sizes are
+// before compression.
+type DedupStats struct {
+	Checkpoints int
+	Files       int
+	Bytes       int64              // Size of every backup
+	StoredBytes int64              // Size of the backups taking space of their own
+	UniqueBytes int64              // Size with each content stored once
+	Duplicates  []DuplicateContent // Most space wasted first
+	Unreadable  []string           // Backups that couldn't be hashed
+}
+
+// Reclaimable is the space storing each content once would save
+func (s *DedupStats) Reclaimable() int64 {
+	return s.StoredBytes - s.UniqueBytes
+}
+
+// FindDuplicates hashes the backups of checkpoints, using the hashes stored
+// in manifests where there are some, and reports the content found more
+// than once. Hard links to the same file, such as backups of a file that
+// didn't change between checkpoints and the object store, are stored once.
+func FindDuplicates(checkpoints []*Checkpoint) *DedupStats {
+	type content struct {
+		DuplicateContent
+		files []os.FileInfo // Distinct files on disk holding it
+		paths map[string]bool
+	}
+	contents := make(map[string]*content)
+	stats := &DedupStats{Checkpoints: len(checkpoints)}
+
+	for _, cp := range checkpoints {
+		archived, err := archivedHashes(cp)
+		if err != nil {
+			stats.Unreadable = append(stats.Unreadable, fmt.Sprintf("%s: %v", cp.ID, err))
+			continue
+		}
+
+		for _, f := range cp.Manifest.Files {
+			if f.IsDir {
+				continue
+			}
+			hash, info, err := backupHash(cp, f, archived)
+			if err != nil {
+				stats.Unreadable = append(stats.Unreadable, fmt.Sprintf("%s: %s: %v", cp.ID, f.OriginalPath, err))
+				continue
+			}
+			stats.Files++
+			stats.Bytes += f.Size
+
+			c := contents[hash]
+			if c == nil {
+				c = &content{DuplicateContent: DuplicateContent{Hash: hash, Size: f.Size}, paths: make(map[string]bool)}
+				contents[hash] = c
+				stats.UniqueBytes += f.Size
+			}
+			c.Copies++
+			if info == nil || !sameAsAny(info, c.files) {
+				c.Stored++
+				stats.StoredBytes += f.Size
+				if info != nil {
+					c.files = append(c.files, info)
+				}
+			}
+			if !c.paths[f.OriginalPath] {
+				c.paths[f.OriginalPath] = true
+				c.Paths = append(c.Paths, f.OriginalPath)
+			}
+		}
+	}
+
+	for _, c := range contents {
+		if c.Copies > 1 {
+			sort.Strings(c.Paths)
+			stats.Duplicates = append(stats.Duplicates, c.DuplicateContent)
+		}
+	}
+	sort.Slice(stats.Duplicates, func(i, j int) bool {
+		a, b := stats.Duplicates[i], stats.Duplicates[j]
+		if a.Wasted() != b.Wasted() {
+			return a.Wasted() > b.Wasted()
+		}
+		if a.Copies != b.Copies {
+			return a.Copies > b.Copies
+		}
+		return a.Hash < b.Hash
+	})
+	return stats
+}
+
+// archivedHashes returns the hashes of the files in a compressed
+// checkpoint's archive, by path inside it. It is nil for uncompressed
+// checkpoints, and when the manifest has every hash already.
+func archivedHashes(cp *Checkpoint) (map[string]string, error) {
+	if !cp.Manifest.Compressed {
+		return nil, nil
+	}
+	for _, f := range cp.Manifest.Files {
+		if !f.IsDir && f.Hash == "" {
+			algorithm := cp.Manifest.CompressionAlgorithm
+			return archiveHashes(GetArchivePath(cp.Dir, algorithm), algorithm)
+		}
+	}
+	return nil, nil
+}
+
+// backupHash returns the content hash of a file's backup and, for
+// uncompressed checkpoints, the backup's file info
+func backupHash(cp *Checkpoint, f FileEntry, archived map[string]string) (string, os.FileInfo, error) {
+	if cp.Manifest.Compressed {
+		if f.Hash != "" {
+			return f.Hash, nil, nil
+		}
+		rel, err := backupRel(cp, f)
+		if err != nil {
+			return "", nil, err
+		}
+		hash, ok := archived[filepath.ToSlash(rel)]
+		if !ok {
+			return "", nil, fmt.Errorf("missing from archive")
+		}
+		return hash, nil, nil
+	}
+
+	info, err := os.Stat(f.BackupPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("backup missing")
+	}
+	if f.Hash != "" {
+		return f.Hash, info, nil
+	}
+	hash, err := contentHash(f.BackupPath)
+	return hash, info, err
+}
+
+func sameAsAny(info os.FileInfo, files []os.FileInfo) bool {
+	for _, other := range files {
+		if os.SameFile(info, other) {
+			return true
+		}
+	}
+	return false
+}
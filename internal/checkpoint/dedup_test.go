@@ -0,0 +1,67 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	shared := filepath.Join(dir, "shared.txt")
+	unique := filepath.Join(dir, "unique.txt")
+	same := filepath.Join(dir, "same.txt")
+	os.WriteFile(shared, []byte("shared"), 0644)
+	os.WriteFile(unique, []byte("unique!"), 0644)
+
+	// The backups of an unchanged file are links to the same file
+	first, err := Create("first", []string{shared, unique})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	second, err := Create("second", []string{shared})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Rewritten with the same content, it is stored again
+	os.Remove(shared)
+	os.WriteFile(shared, []byte("shared"), 0644)
+	os.WriteFile(same, []byte("shared"), 0644)
+	third, err := Create("third", []string{shared, same})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	// Archived copies are read from the archive
+	if _, _, err := Compress(third.ID); err != nil {
+		t.Fatalf("Failed to compress checkpoint: %v", err)
+	}
+	if third, err = Get(third.ID); err != nil {
+		t.Fatalf("Failed to reload checkpoint: %v", err)
+	}
+
+	stats := FindDuplicates([]*Checkpoint{first, second, third})
+
+	if len(stats.Unreadable) != 0 {
+		t.Fatalf("Expected every backup to be read, got %v", stats.Unreadable)
+	}
+	if stats.Checkpoints != 3 || stats.Files != 5 {
+		t.Errorf("Expected 5 files in 3 checkpoints, got %d in %d", stats.Files, stats.Checkpoints)
+	}
+	if len(stats.Duplicates) != 1 {
+		t.Fatalf("Expected one duplicated content, got %+v", stats.Duplicates)
+	}
+	d := stats.Duplicates[0]
+	if d.Copies != 4 || d.Stored != 3 {
+		t.Errorf("Expected 4 copies, 3 stored, got %d and %d", d.Copies, d.Stored)
+	}
+	if len(d.Paths) != 2 || d.Paths[0] != same || d.Paths[1] != shared {
+		t.Errorf("Expected paths %s and %s, got %v", same, shared, d.Paths)
+	}
+	if got := stats.Reclaimable(); got != 2*int64(len("shared")) {
+		t.Errorf("Reclaimable() = %d, want %d", got, 2*len("shared"))
+	}
+}
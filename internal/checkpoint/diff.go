@@ -0,0 +1,389 @@
+package checkpoint
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// Diff statuses
+const (
+	DiffDeleted   = "deleted"
+	DiffModified  = "modified"
+	DiffUnchanged = "unchanged"
+)
+
+// FileDiff describes how a backed-up file compares to the filesystem
+type FileDiff struct {
+	Path        string
+	Status      string // DiffDeleted, DiffModified or DiffUnchanged, or DiffRenamed
+	BackupSize  int64
+	CurrentSize int64
+	BackupPath  string
+	RenamedTo   string // Where DetectRenames found the file's content
+}
+
+// Compare checks each file in the checkpoint against its current state
+func Compare(cp *Checkpoint) []FileDiff {
+	var diffs []FileDiff
+	MountFSSnapshot(cp) // If it fails, the backups show as missing
+
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		diffs = append(diffs, compareFile(cp, f))
+	}
+
+	return diffs
+}
+
+// compareFile checks a file of cp against its current state
+func compareFile(cp *Checkpoint, f FileEntry) FileDiff {
+	diff := FileDiff{
+		Path:       f.OriginalPath,
+		BackupSize: f.Size,
+		BackupPath: f.BackupPath,
+	}
+
+	// Treat stat errors as deleted
+	info, err := os.Stat(f.OriginalPath)
+	if err != nil {
+		diff.Status = DiffDeleted
+	} else {
+		diff.CurrentSize = info.Size()
+
+		// Compare content (using hash for efficiency)
+		if backupMatches(cp, f) {
+			diff.Status = DiffUnchanged
+		} else {
+			diff.Status = DiffModified
+		}
+	}
+	return diff
+}
+
+// WindowDiff is how a file backed up in a time window compares to the
+// filesystem. It is compared to its backup in the oldest checkpoint of the
+// window, which holds the file as it was before. Checkpoints are all those
+// of the window that backed it up, oldest first: they were taken before
+// the commands that touched it.
+type WindowDiff struct {
+	FileDiff
+	Checkpoints []*Checkpoint
+}
+
+// CompareSince checks every file backed up by the checkpoints created since
+// since against its current state, sorted by path
+func (s *Store) CompareSince(since time.Time) ([]WindowDiff, error) {
+	checkpoints, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	first := make(map[string]FileEntry)
+	byPath := make(map[string][]*Checkpoint)
+	// Oldest first
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		cp := checkpoints[i]
+		if cp.CreatedAt.Before(since) {
+			continue
+		}
+		for _, f := range cp.Manifest.Files {
+			if f.IsDir {
+				continue
+			}
+			if _, ok := first[f.OriginalPath]; !ok {
+				first[f.OriginalPath] = f
+				paths = append(paths, f.OriginalPath)
+			}
+			byPath[f.OriginalPath] = append(byPath[f.OriginalPath], cp)
+		}
+	}
+	sort.Strings(paths)
+
+	diffs := make([]WindowDiff, 0, len(paths))
+	for _, path := range paths {
+		cps := byPath[path]
+		diffs = append(diffs, WindowDiff{FileDiff: compareFile(cps[0], first[path]), Checkpoints: cps})
+	}
+	return diffs, nil
+}
+
+// Effect counts the backed-up files a command deleted, moved or changed
+type Effect struct {
+	Deleted int
+	Moved   int
+	Changed int
+}
+
+// QuickEffect tells what happened to the files of a checkpoint since it was
+// taken from their size, mode and modification time, without reading them,
+// so it is cheap enough to run after every wrapped command. A file put back
+// with its old modification time (mv -p, cp -p) goes unnoticed unless its
+// size or mode changed; Compare catches it.
+func QuickEffect(m *Manifest) Effect {
+	return ScanOutcome(m, 0, nil).Effect()
+}
+
+// backupMatches reports whether the file at f.OriginalPath still has its
+// backed-up content. Compressed backups are hashed from the archive.
+func backupMatches(cp *Checkpoint, f FileEntry) bool {
+	if !cp.Manifest.Compressed {
+		return filesMatch(f.BackupPath, f.OriginalPath)
+	}
+
+	info, err := os.Stat(f.OriginalPath)
+	if err != nil || info.Size() != f.Size {
+		return false
+	}
+	backup := versionHash(cp, f)
+	current, err := contentHash(f.OriginalPath)
+	return err == nil && backup != "" && backup == current
+}
+
+func filesMatch(path1, path2 string) bool {
+	// Quick check: compare file sizes first (much faster than hashing)
+	info1, err1 := os.Stat(path1)
+	info2, err2 := os.Stat(path2)
+
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	// Different sizes = definitely different files
+	if info1.Size() != info2.Size() {
+		return false
+	}
+
+	// Same size = need to compare content via hash
+	hash1, err1 := fileHash(path1)
+	hash2, err2 := fileHash(path2)
+
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	return hash1 == hash2
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// DiffGroup clusters changed files sharing a directory and file type
+type DiffGroup struct {
+	Dir      string // relative to DiffSummary.Root
+	Ext      string // extension including the dot, or "" for none
+	Deleted  int
+	Modified int
+	Bytes    int64    // backup size of the group's files
+	Examples []string // a few file names, relative to Dir
+}
+
+// Files returns the number of changed files in the group
+func (g DiffGroup) Files() int {
+	return g.Deleted + g.Modified
+}
+
+// DiffSummary is a compact description of a checkpoint diff, suitable for
+// including in an LLM prompt
+type DiffSummary struct {
+	Root      string // common directory of the changed files
+	Deleted   int
+	Modified  int
+	Unchanged int
+	Bytes     int64 // total size that a rollback would restore
+	Groups    []DiffGroup
+}
+
+const (
+	// summaryDepth is how many directory levels below Root form a group
+	summaryDepth = 2
+	// summaryExamples is the number of example files kept per group
+	summaryExamples = 3
+)
+
+// SummarizeDiffs clusters changed files by directory and file type. Groups
+// are ordered by number of changed files, largest first.
+func SummarizeDiffs(diffs []FileDiff) *DiffSummary {
+	s := &DiffSummary{}
+
+	var changed []FileDiff
+	for _, d := range diffs {
+		switch d.Status {
+		case DiffDeleted:
+			s.Deleted++
+		case DiffModified:
+			s.Modified++
+		default:
+			s.Unchanged++
+			continue
+		}
+		s.Bytes += d.BackupSize
+		changed = append(changed, d)
+	}
+
+	s.Root = commonDir(changed)
+
+	groups := make(map[string]*DiffGroup)
+	for _, d := range changed {
+		rel, err := filepath.Rel(s.Root, d.Path)
+		if err != nil {
+			rel = d.Path
+		}
+		dir := filepath.Dir(rel)
+		parts := strings.Split(filepath.ToSlash(dir), "/")
+		if len(parts) > summaryDepth {
+			dir = filepath.Join(parts[:summaryDepth]...)
+		}
+		ext := strings.ToLower(filepath.Ext(d.Path))
+
+		key := dir + "\x00" + ext
+		g, ok := groups[key]
+		if !ok {
+			g = &DiffGroup{Dir: dir, Ext: ext}
+			groups[key] = g
+		}
+		if d.Status == DiffDeleted {
+			g.Deleted++
+		} else {
+			g.Modified++
+		}
+		g.Bytes += d.BackupSize
+		if len(g.Examples) < summaryExamples {
+			example, err := filepath.Rel(filepath.Join(s.Root, dir), d.Path)
+			if err != nil {
+				example = filepath.Base(d.Path)
+			}
+			g.Examples = append(g.Examples, example)
+		}
+	}
+
+	for _, g := range groups {
+		s.Groups = append(s.Groups, *g)
+	}
+	sort.Slice(s.Groups, func(i, j int) bool {
+		a, b := s.Groups[i], s.Groups[j]
+		if a.Files() != b.Files() {
+			return a.Files() > b.Files()
+		}
+		if a.Dir != b.Dir {
+			return a.Dir < b.Dir
+		}
+		return a.Ext < b.Ext
+	})
+
+	return s
+}
+
+// commonDir returns the deepest directory containing every diff's path
+func commonDir(diffs []FileDiff) string {
+	if len(diffs) == 0 {
+		return ""
+	}
+
+	common := filepath.Dir(diffs[0].Path)
+	for _, d := range diffs[1:] {
+		dir := filepath.Dir(d.Path)
+		for common != dir && !strings.HasPrefix(dir, common+string(os.PathSeparator)) {
+			parent := filepath.Dir(common)
+			if parent == common {
+				break
+			}
+			common = parent
+		}
+	}
+	return common
+}
+
+// Render formats the summary as plain text of at most maxBytes bytes
+// (roughly maxBytes/4 tokens). Groups that don't fit are counted in a
+// trailing line instead of being listed. maxBytes <= 0 means no limit.
+func (s *DiffSummary) Render(maxBytes int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d deleted, %d modified, %d unchanged (%s to restore)\n",
+		s.Deleted, s.Modified, s.Unchanged, output.FormatBytes(s.Bytes)))
+	if len(s.Groups) == 0 {
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("Under %s:\n", s.Root))
+
+	for i, g := range s.Groups {
+		line := g.String() + "\n"
+
+		// Leave room for the line reporting any groups after this one
+		need := sb.Len() + len(line)
+		if i < len(s.Groups)-1 {
+			need += len(omittedLine(s.Groups[i+1:]))
+		}
+		if maxBytes > 0 && need > maxBytes {
+			sb.WriteString(omittedLine(s.Groups[i:]))
+			break
+		}
+		sb.WriteString(line)
+	}
+
+	out := sb.String()
+	if maxBytes > 0 && len(out) > maxBytes {
+		out = out[:maxBytes]
+	}
+	return out
+}
+
+func omittedLine(groups []DiffGroup) string {
+	files := 0
+	for _, g := range groups {
+		files += g.Files()
+	}
+	return fmt.Sprintf("  ... %d more group(s), %d file(s)\n", len(groups), files)
+}
+
+// String formats the group as a single summary line
+func (g DiffGroup) String() string {
+	dir := filepath.ToSlash(g.Dir)
+	if dir == "." {
+		dir = ""
+	} else {
+		dir += "/"
+	}
+	ext := "*" + g.Ext
+	if g.Ext == "" {
+		ext = "(no extension)"
+	}
+
+	var counts []string
+	if g.Deleted > 0 {
+		counts = append(counts, fmt.Sprintf("%d deleted", g.Deleted))
+	}
+	if g.Modified > 0 {
+		counts = append(counts, fmt.Sprintf("%d modified", g.Modified))
+	}
+
+	examples := strings.Join(g.Examples, ", ")
+	if more := g.Files() - len(g.Examples); more > 0 {
+		examples += fmt.Sprintf(", +%d more", more)
+	}
+
+	return fmt.Sprintf("  %s%s: %s (%s) e.g. %s",
+		dir, ext, strings.Join(counts, ", "), output.FormatBytes(g.Bytes), examples)
+}
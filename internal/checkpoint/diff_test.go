@@ -0,0 +1,234 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	files := map[string]string{"keep.txt": "same", "edit.txt": "before", "gone.txt": "bye"}
+	var paths []string
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0644)
+		paths = append(paths, p)
+	}
+
+	cp, err := Create("test", paths)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Backups are hard links, so replace the file rather than editing in place
+	os.Remove(filepath.Join(dir, "edit.txt"))
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("after!"), 0644)
+	os.Remove(filepath.Join(dir, "gone.txt"))
+
+	statuses := make(map[string]string)
+	for _, d := range Compare(cp) {
+		statuses[filepath.Base(d.Path)] = d.Status
+	}
+
+	expected := map[string]string{"keep.txt": DiffUnchanged, "edit.txt": DiffModified, "gone.txt": DiffDeleted}
+	for name, status := range expected {
+		if statuses[name] != status {
+			t.Errorf("Expected %s to be %s, got %s", name, status, statuses[name])
+		}
+	}
+}
+
+func TestCompareSince(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	dir := filepath.Join(tmpDir, "testdata")
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("first"), 0644)
+	os.WriteFile(b, []byte("bee"), 0644)
+
+	old, err := store.Create("before the window", []string{a})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	since := time.Now()
+	first, err := store.Create("sed -i a.txt", []string{a})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	os.Remove(a)
+	os.WriteFile(a, []byte("second"), 0644)
+	second, err := store.Create("rm a.txt b.txt", []string{a, b})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	os.Remove(b)
+
+	diffs, err := store.CompareSince(since)
+	if err != nil {
+		t.Fatalf("CompareSince failed: %v", err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(diffs))
+	}
+
+	// a.txt is compared to its oldest backup in the window, not the one
+	// before it
+	if diffs[0].Path != a || diffs[0].Status != DiffModified || len(diffs[0].Checkpoints) != 2 ||
+		diffs[0].Checkpoints[0].ID != first.ID || diffs[0].Checkpoints[1].ID != second.ID {
+		t.Errorf("Expected a.txt modified by %s and %s, got %+v", first.ID, second.ID, diffs[0])
+	}
+	if diffs[1].Path != b || diffs[1].Status != DiffDeleted || len(diffs[1].Checkpoints) != 1 || diffs[1].Checkpoints[0].ID != second.ID {
+		t.Errorf("Expected b.txt deleted after %s, got %+v", second.ID, diffs[1])
+	}
+	for _, d := range diffs {
+		for _, cp := range d.Checkpoints {
+			if cp.ID == old.ID {
+				t.Errorf("Checkpoint %s is older than the window", old.ID)
+			}
+		}
+	}
+}
+
+func TestQuickEffect(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	var paths []string
+	for _, name := range []string{"keep.txt", "edit.txt", "same-size.txt", "script.sh", "gone1.txt", "gone2.txt"} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte("content"), 0644)
+		paths = append(paths, p)
+	}
+
+	cp, err := Create("test", paths)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, "edit.txt"))
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("new content"), 0644)
+	os.Remove(filepath.Join(dir, "same-size.txt"))
+	os.WriteFile(filepath.Join(dir, "same-size.txt"), []byte("CONTENT"), 0644)
+	later := cp.Manifest.Timestamp.Add(time.Second)
+	os.Chtimes(filepath.Join(dir, "same-size.txt"), later, later)
+	os.Chmod(filepath.Join(dir, "script.sh"), 0755)
+	os.Remove(filepath.Join(dir, "gone1.txt"))
+	os.Remove(filepath.Join(dir, "gone2.txt"))
+
+	if got, want := QuickEffect(cp.Manifest), (Effect{Deleted: 2, Changed: 3}); got != want {
+		t.Errorf("QuickEffect() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCompareCompressed(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	keep := filepath.Join(dir, "keep.txt")
+	edit := filepath.Join(dir, "edit.txt")
+	os.WriteFile(keep, []byte("same"), 0644)
+	os.WriteFile(edit, []byte("before"), 0644)
+
+	cp, err := Create("test", []string{keep, edit})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if _, _, err := Compress(cp.ID); err != nil {
+		t.Fatalf("Failed to compress checkpoint: %v", err)
+	}
+	cp, _ = Get(cp.ID)
+
+	// Same size, different content
+	os.Remove(edit)
+	os.WriteFile(edit, []byte("after!"), 0644)
+
+	statuses := make(map[string]string)
+	for _, d := range Compare(cp) {
+		statuses[filepath.Base(d.Path)] = d.Status
+	}
+	if statuses["keep.txt"] != DiffUnchanged || statuses["edit.txt"] != DiffModified {
+		t.Errorf("Unexpected statuses for compressed checkpoint: %v", statuses)
+	}
+}
+
+func TestSummarizeDiffs(t *testing.T) {
+	var diffs []FileDiff
+	for i := 0; i < 5; i++ {
+		diffs = append(diffs, FileDiff{Path: fmt.Sprintf("/repo/src/api/h%d.go", i), Status: DiffDeleted, BackupSize: 100})
+	}
+	diffs = append(diffs,
+		FileDiff{Path: "/repo/src/api/v1/deep/x.go", Status: DiffModified, BackupSize: 10},
+		FileDiff{Path: "/repo/README.md", Status: DiffModified, BackupSize: 50},
+		FileDiff{Path: "/repo/Makefile", Status: DiffDeleted, BackupSize: 20},
+		FileDiff{Path: "/repo/docs/a.md", Status: DiffUnchanged, BackupSize: 5},
+	)
+
+	s := SummarizeDiffs(diffs)
+
+	if s.Root != "/repo" {
+		t.Errorf("Expected root /repo, got %s", s.Root)
+	}
+	if s.Deleted != 6 || s.Modified != 2 || s.Unchanged != 1 || s.Bytes != 580 {
+		t.Errorf("Unexpected totals: %+v", s)
+	}
+	if len(s.Groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %+v", s.Groups)
+	}
+
+	// Deep paths fold into their directory two levels below the root
+	top := s.Groups[0]
+	if top.Dir != filepath.Join("src", "api") || top.Ext != ".go" || top.Deleted != 5 || top.Modified != 1 {
+		t.Errorf("Unexpected largest group: %+v", top)
+	}
+	if len(top.Examples) != summaryExamples {
+		t.Errorf("Expected %d examples, got %v", summaryExamples, top.Examples)
+	}
+	if !strings.Contains(top.String(), "+3 more") {
+		t.Errorf("Group line should mention remaining files: %s", top.String())
+	}
+
+	full := s.Render(0)
+	for _, want := range []string{"6 deleted, 2 modified, 1 unchanged", "src/api/*.go", "*.md", "(no extension)"} {
+		if !strings.Contains(full, want) {
+			t.Errorf("Rendered summary missing %q:\n%s", want, full)
+		}
+	}
+}
+
+func TestSummaryRenderBudget(t *testing.T) {
+	var diffs []FileDiff
+	for i := 0; i < 50; i++ {
+		diffs = append(diffs, FileDiff{
+			Path:   fmt.Sprintf("/repo/pkg%02d/sub/file.ext%02d", i, i),
+			Status: DiffDeleted,
+		})
+	}
+	s := SummarizeDiffs(diffs)
+
+	for _, budget := range []int{1, 100, 300, 1000} {
+		out := s.Render(budget)
+		if len(out) > budget {
+			t.Errorf("Render(%d) returned %d bytes", budget, len(out))
+		}
+	}
+
+	out := s.Render(500)
+	if !strings.Contains(out, "more group(s)") {
+		t.Errorf("Truncated summary should report omitted groups:\n%s", out)
+	}
+	if strings.Contains(s.Render(0), "more group(s)") {
+		t.Error("Unlimited summary should list every group")
+	}
+}
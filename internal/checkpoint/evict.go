@@ -0,0 +1,173 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// What happens to the oldest checkpoints beyond max_checkpoints, or when
+// the store is over max_storage_mb. Over max_storage_mb, creating a
+// checkpoint may also just warn, or be refused.
+const (
+	EvictDelete   = "delete"
+	EvictCompress = "compress"
+	StorageWarn   = "warn"
+	StorageRefuse = "refuse"
+)
+
+// ErrStorageLimit is returned by Create when the store is over
+// max_storage_mb and max_storage_action is refuse
+var ErrStorageLimit = errors.New("storage limit exceeded")
+
+// Eviction is what creating a checkpoint did to older ones to stay within
+// a limit
+type Eviction struct {
+	Action string   `json:"action"` // EvictDelete or EvictCompress
+	IDs    []string `json:"ids"`
+	Limit  string   `json:"limit"` // max_checkpoints or max_storage_mb
+	Max    int      `json:"max"`
+}
+
+// evictable returns the checkpoints that may be evicted to make room,
+// oldest first: all but the pinned ones, the newest keep_per_session of
+// each session and the one with ID except
+func (s *Store) evictable(except string) ([]*Checkpoint, error) {
+	checkpoints, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	keep := Keepers(checkpoints, config.Get().KeepPerSession)
+	var evictable []*Checkpoint
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		if cp := checkpoints[i]; cp.ID != except && !keep[cp.ID] {
+			evictable = append(evictable, cp)
+		}
+	}
+	return evictable, nil
+}
+
+// evictOne deletes or compresses cp, returning how many bytes that freed.
+// Checkpoints the policy's minimum retention protects are not deleted.
+func (s *Store) evictOne(cp *Checkpoint, action string) (int64, error) {
+	if action == EvictCompress {
+		originalSize, compressedSize, err := s.Compress(cp.ID)
+		if err != nil {
+			logging.Warn(fmt.Sprintf("Warning: failed to compress checkpoint %s: %v", cp.ID, err))
+			return 0, err
+		}
+		return originalSize - compressedSize, nil
+	}
+	size, _ := GetDiskUsage(cp.Dir)
+	if err := s.Delete(cp.ID); err != nil {
+		logging.Debug("not evicted", "checkpoint", cp.ID, "error", err)
+		return 0, err
+	}
+	return size, nil
+}
+
+// enforceMaxStorage makes room before a checkpoint is created if the store
+// is over max_storage_mb, as max_storage_action says: compress or delete
+// the oldest checkpoints until it is under, refuse with ErrStorageLimit, or
+// just warn. It returns nil if nothing was evicted.
+func (s *Store) enforceMaxStorage() (*Eviction, error) {
+	exceeds, currentMB, limitMB := s.CheckTotalStorage()
+	if !exceeds {
+		return nil, nil
+	}
+
+	action := config.Get().MaxStorageAction
+	switch action {
+	case StorageRefuse:
+		return nil, fmt.Errorf("%w (%dMB / %dMB): run 'safeshell clean' to free space", ErrStorageLimit, currentMB, limitMB)
+	case EvictDelete, EvictCompress:
+	default:
+		logging.Warn(fmt.Sprintf("Warning: Storage limit exceeded (%dMB / %dMB). Run 'safeshell clean' to free space.", currentMB, limitMB))
+		return nil, nil
+	}
+
+	evictable, err := s.evictable("")
+	if err != nil {
+		return nil, err
+	}
+	e := &Eviction{Action: action, Limit: "max_storage_mb", Max: limitMB}
+	over := (currentMB - int64(limitMB)) * 1024 * 1024
+	for _, cp := range evictable {
+		if over <= 0 {
+			break
+		}
+		if action == EvictCompress && (cp.Manifest.Compressed || cp.Manifest.FSSnapshot != nil) {
+			continue
+		}
+		freed, err := s.evictOne(cp, action)
+		if err != nil {
+			continue
+		}
+		over -= freed
+		e.IDs = append(e.IDs, cp.ID)
+	}
+	if over > 0 {
+		verb := "deleting"
+		if action == EvictCompress {
+			verb = "compressing"
+		}
+		logging.Warn(fmt.Sprintf("Warning: Storage limit still exceeded (%dMB / %dMB) after %s old checkpoints. Run 'safeshell clean' or unpin some.", int64(limitMB)+over/(1024*1024), limitMB, verb))
+	}
+
+	if len(e.IDs) == 0 {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// enforceMaxCheckpoints keeps the store within max_checkpoints once cp has
+// been created. The oldest checkpoints beyond it are deleted or, if
+// max_checkpoints_action is compress, compressed. It returns nil if nothing
+// was evicted.
+func (s *Store) enforceMaxCheckpoints(cp *Checkpoint) *Eviction {
+	cfg := config.Get()
+	if cfg.MaxCheckpoints <= 0 || len(s.Index().ListEntries()) <= cfg.MaxCheckpoints {
+		return nil
+	}
+	evictable, err := s.evictable(cp.ID)
+	if err != nil {
+		return nil
+	}
+
+	action := cfg.MaxCheckpointsAction
+	if action != EvictCompress {
+		action = EvictDelete
+	}
+	e := &Eviction{Action: action, Limit: "max_checkpoints", Max: cfg.MaxCheckpoints}
+	excess := len(s.Index().ListEntries()) - cfg.MaxCheckpoints
+	if action == EvictCompress {
+		// Compressed checkpoints still count, so every one beyond the
+		// newest max_checkpoints is compressed
+		excess = min(excess, len(evictable))
+		for _, old := range evictable[:excess] {
+			if old.Manifest.Compressed {
+				continue
+			}
+			if _, err := s.evictOne(old, action); err == nil {
+				e.IDs = append(e.IDs, old.ID)
+			}
+		}
+	} else {
+		for _, old := range evictable {
+			if excess <= 0 {
+				break
+			}
+			if _, err := s.evictOne(old, action); err == nil {
+				e.IDs = append(e.IDs, old.ID)
+				excess--
+			}
+		}
+	}
+
+	if len(e.IDs) == 0 {
+		return nil
+	}
+	return e
+}
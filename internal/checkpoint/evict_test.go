@@ -0,0 +1,97 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func TestCreateEvictsOldest(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	config.Get().MaxCheckpoints = 2
+	defer func() { config.Get().MaxCheckpoints = 100 }()
+
+	testFile := filepath.Join(tmpDir, "testdata", "test.txt")
+	os.WriteFile(testFile, []byte("hello"), 0644)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		cp, err := store.Create("rm test.txt", []string{testFile})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		ids = append(ids, cp.ID)
+		if i == 0 {
+			// The oldest is pinned, so the second goes first
+			store.SetPinned(cp.ID, true)
+		}
+		if i < 2 && len(cp.Evicted) != 0 {
+			t.Errorf("Expected nothing evicted within max_checkpoints, got %v", cp.Evicted[0].IDs)
+		}
+		if i == 2 && (len(cp.Evicted) != 1 || len(cp.Evicted[0].IDs) != 1 || cp.Evicted[0].IDs[0] != ids[1]) {
+			t.Errorf("Expected %s evicted, got %+v", ids[1], cp.Evicted)
+		}
+	}
+
+	remaining, _ := store.List()
+	if len(remaining) != 2 || remaining[0].ID != ids[2] || remaining[1].ID != ids[0] {
+		t.Errorf("Expected the pinned and newest checkpoints left, got %d", len(remaining))
+	}
+
+	// compress keeps them all, compressing those beyond the newest two
+	config.Get().MaxCheckpointsAction = EvictCompress
+	defer func() { config.Get().MaxCheckpointsAction = EvictDelete }()
+	store.SetPinned(ids[0], false)
+	cp, err := store.Create("rm test.txt", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if len(cp.Evicted) != 1 || cp.Evicted[0].Action != EvictCompress || len(cp.Evicted[0].IDs) != 1 || cp.Evicted[0].IDs[0] != ids[0] {
+		t.Errorf("Expected %s compressed, got %+v", ids[0], cp.Evicted)
+	}
+	if remaining, _ := store.List(); len(remaining) != 3 {
+		t.Errorf("Expected 3 checkpoints left, got %d", len(remaining))
+	}
+}
+
+func TestCreateEnforcesMaxStorage(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	config.Get().MaxStorageMB = 1
+	defer func() {
+		config.Get().MaxStorageMB = 5000
+		config.Get().MaxStorageAction = EvictCompress
+	}()
+
+	testFile := filepath.Join(tmpDir, "testdata", "big.bin")
+	os.WriteFile(testFile, make([]byte, 3*1024*1024), 0644)
+	old, err := store.Create("rm big.bin", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	config.Get().MaxStorageAction = StorageRefuse
+	if _, err := store.Create("rm big.bin", []string{testFile}); !errors.Is(err, ErrStorageLimit) {
+		t.Fatalf("Expected the checkpoint to be refused, got %v", err)
+	}
+
+	config.Get().MaxStorageAction = EvictDelete
+	cp, err := store.Create("rm big.bin", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if len(cp.Evicted) != 1 || cp.Evicted[0].Limit != "max_storage_mb" || len(cp.Evicted[0].IDs) != 1 || cp.Evicted[0].IDs[0] != old.ID {
+		t.Errorf("Expected %s deleted to make room, got %+v", old.ID, cp.Evicted)
+	}
+	if remaining, _ := store.List(); len(remaining) != 1 || remaining[0].ID != cp.ID {
+		t.Errorf("Expected only the new checkpoint left, got %d", len(remaining))
+	}
+}
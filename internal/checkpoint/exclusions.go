@@ -0,0 +1,282 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// Checkpoints much larger than usual are analyzed for directories that
+// should probably be excluded, such as build output or caches that
+// DefaultExclusions doesn't know about. Accepted suggestions are added to
+// exclude_paths; declined ones are remembered and not suggested again.
+
+const (
+	// oversizedBytes makes a checkpoint oversized regardless of history
+	oversizedBytes = 100 << 20
+	// oversizedFactor times the median of recent checkpoints is oversized
+	oversizedFactor = 10
+	// oversizedHistory is how many recent checkpoints the median is taken over
+	oversizedHistory = 20
+	// minSuggestionBytes is the least a pattern's directories must
+	// contribute to be suggested
+	minSuggestionBytes = 10 << 20
+	// minSuggestionShare is the share of a checkpoint an unfamiliar
+	// directory must contribute to be suggested
+	minSuggestionShare = 0.25
+)
+
+// generatedDirNames are directory names that usually hold regenerable
+// content, on top of DefaultExclusions
+var generatedDirNames = []string{
+	"coverage", "htmlcov", ".nyc_output", // Test coverage
+	".next", ".nuxt", ".svelte-kit", ".angular", ".turbo", ".parcel-cache", // Frontend build caches
+	"_build", "obj", "bin", ".gradle", "Pods", // Build outputs
+	".tox", ".mypy_cache", ".ruff_cache", ".terraform", "bower_components", // Tool caches
+	"tmp", "temp", "logs",
+}
+
+// ExclusionSuggestion proposes an exclude_paths pattern for directories that
+// made a checkpoint unusually large
+type ExclusionSuggestion struct {
+	Pattern string   `json:"pattern"` // e.g. "coverage/*"
+	Dirs    []string `json:"dirs"`    // matching directories in the checkpoint
+	Files   int      `json:"files"`
+	Size    int64    `json:"size"`
+	Share   float64  `json:"share"` // fraction of the checkpoint's size, the largest when merged
+}
+
+// IsOversized reports whether a checkpoint of size bytes is unusually
+// large: over 100 MB, or ten times the median of recent checkpoints
+func IsOversized(size int64, recent []*IndexEntry) bool {
+	if size >= oversizedBytes {
+		return true
+	}
+	if size < minSuggestionBytes || len(recent) < 5 {
+		return false
+	}
+	if len(recent) > oversizedHistory {
+		recent = recent[:oversizedHistory]
+	}
+	sizes := make([]int64, len(recent))
+	for i, e := range recent {
+		sizes[i] = e.TotalSize
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	return size > oversizedFactor*sizes[len(sizes)/2]
+}
+
+// SuggestExclusions finds the directories below cp's targets that
+// contributed most to its size and look regenerable, biggest first
+func SuggestExclusions(cp *Checkpoint) []ExclusionSuggestion {
+	_, total := countFiles(cp.Manifest)
+	if total == 0 {
+		return nil
+	}
+
+	dirs := make(map[string]bool)
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			dirs[f.OriginalPath] = true
+		}
+	}
+
+	type dirStat struct {
+		files int
+		size  int64
+	}
+	stats := make(map[string]*dirStat)
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		// Directories strictly below a checkpointed directory
+		for dir := filepath.Dir(f.OriginalPath); dirs[dir] && dirs[filepath.Dir(dir)]; dir = filepath.Dir(dir) {
+			s := stats[dir]
+			if s == nil {
+				s = &dirStat{}
+				stats[dir] = s
+			}
+			s.files++
+			s.size += f.Size
+		}
+	}
+
+	var candidates []string
+	for dir, s := range stats {
+		large := s.size >= minSuggestionBytes && float64(s.size)/float64(total) >= minSuggestionShare
+		if large || isGeneratedDir(filepath.Base(dir)) {
+			candidates = append(candidates, dir)
+		}
+	}
+	// Outermost first, so nested candidates are folded into their parent
+	sort.Strings(candidates)
+
+	skip := make(map[string]bool)
+	for _, p := range config.Get().ExcludePaths {
+		skip[p] = true
+	}
+	for _, p := range DismissedExclusions() {
+		skip[p] = true
+	}
+
+	byPattern := make(map[string]*ExclusionSuggestion)
+	var chosen []string
+	for _, dir := range candidates {
+		if isBelowAny(dir, chosen) {
+			continue
+		}
+		pattern := filepath.Base(dir) + "/*"
+		if skip[pattern] {
+			continue
+		}
+		chosen = append(chosen, dir)
+		sug := byPattern[pattern]
+		if sug == nil {
+			sug = &ExclusionSuggestion{Pattern: pattern}
+			byPattern[pattern] = sug
+		}
+		sug.Dirs = append(sug.Dirs, dir)
+		sug.Files += stats[dir].files
+		sug.Size += stats[dir].size
+	}
+
+	suggestions := make([]ExclusionSuggestion, 0, len(byPattern))
+	for _, sug := range byPattern {
+		if sug.Size < minSuggestionBytes {
+			continue
+		}
+		sug.Share = float64(sug.Size) / float64(total)
+		suggestions = append(suggestions, *sug)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Size > suggestions[j].Size
+	})
+	return suggestions
+}
+
+// RecentExclusionSuggestions merges the suggestions for every oversized
+// checkpoint created since the given time
+func (s *Store) RecentExclusionSuggestions(since time.Time) []ExclusionSuggestion {
+	entries := s.Index().ListEntries() // Newest first
+	byPattern := make(map[string]*ExclusionSuggestion)
+	var order []string
+	for i, e := range entries {
+		if e.Timestamp.Before(since) || !IsOversized(e.TotalSize, entries[i+1:]) {
+			continue
+		}
+		cp, err := s.Get(e.ID)
+		if err != nil {
+			continue
+		}
+		for _, s := range SuggestExclusions(cp) {
+			merged := byPattern[s.Pattern]
+			if merged == nil {
+				merged = &ExclusionSuggestion{Pattern: s.Pattern}
+				byPattern[s.Pattern] = merged
+				order = append(order, s.Pattern)
+			}
+			for _, dir := range s.Dirs {
+				if !containsPath(merged.Dirs, dir) {
+					merged.Dirs = append(merged.Dirs, dir)
+				}
+			}
+			merged.Files += s.Files
+			merged.Size += s.Size
+			if s.Share > merged.Share {
+				merged.Share = s.Share
+			}
+		}
+	}
+
+	suggestions := make([]ExclusionSuggestion, 0, len(order))
+	for _, p := range order {
+		suggestions = append(suggestions, *byPattern[p])
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Size > suggestions[j].Size
+	})
+	return suggestions
+}
+
+func isGeneratedDir(name string) bool {
+	for _, n := range generatedDirNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isBelowAny(dir string, parents []string) bool {
+	for _, p := range parents {
+		if strings.HasPrefix(dir, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// adviseExclusions prints exclusion suggestions for an oversized checkpoint
+func adviseExclusions(w io.Writer, cp *Checkpoint, recent []*IndexEntry) {
+	_, total := countFiles(cp.Manifest)
+	if !IsOversized(total, recent) {
+		return
+	}
+	suggestions := SuggestExclusions(cp)
+	if len(suggestions) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n💡 This checkpoint is unusually large (%s):\n", output.FormatBytes(total))
+	for _, s := range suggestions {
+		fmt.Fprintf(w, "   • %s contributed %s (%.0f%%). Exclude it: safeshell exclude add '%s'\n",
+			strings.TrimSuffix(s.Pattern, "*"), output.FormatBytes(s.Size), s.Share*100, s.Pattern)
+	}
+	fmt.Fprintf(w, "   Review all suggestions with: safeshell exclude suggest %s\n\n", cp.ID)
+}
+
+func exclusionFeedbackPath() string {
+	return filepath.Join(config.GetSafeShellDir(), "exclusion-feedback.json")
+}
+
+// exclusionFeedback records the suggested patterns the user declined
+type exclusionFeedback struct {
+	Dismissed []string `json:"dismissed"`
+}
+
+// DismissedExclusions returns the patterns the user declined
+func DismissedExclusions() []string {
+	data, err := os.ReadFile(exclusionFeedbackPath())
+	if err != nil {
+		return nil
+	}
+	var fb exclusionFeedback
+	if err := json.Unmarshal(data, &fb); err != nil {
+		return nil
+	}
+	return fb.Dismissed
+}
+
+// DismissExclusion stops pattern from being suggested again
+func DismissExclusion(pattern string) error {
+	dismissed := DismissedExclusions()
+	for _, p := range dismissed {
+		if p == pattern {
+			return nil
+		}
+	}
+	data, err := json.MarshalIndent(exclusionFeedback{Dismissed: append(dismissed, pattern)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(exclusionFeedbackPath(), data, 0644)
+}
@@ -0,0 +1,198 @@
+package checkpoint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func TestMatchExcludePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.tmp", "/p/a.tmp", true},
+		{"*.tmp", "/p/a.txt", false},
+		{"coverage/*", "/p/coverage", true},
+		{"coverage/*", "/p/src/coverage", true},
+		{"coverage/*", "/p/coverage.txt", false},
+		{".git/objects/*", "/p/.git/objects", true},
+		{".git/objects/*", "/p/objects", false},
+		{"cache-*/*", "/p/cache-v2", true},
+		{"src/**/*.pb.go", "/p/src/a/b/x.pb.go", true},
+		{"src/**/*.pb.go", "/p/lib/a/x.pb.go", false},
+		{"**/fixtures", "/p/x/fixtures", true},
+		{"/p/gen/**", "/p/gen/a/b", true},
+		{"/p/gen/**", "/q/gen/a", false},
+	}
+	for _, tt := range tests {
+		if got := matchExcludePattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchExcludePattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCreateHonorsExcludePaths(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	cfg := config.Get()
+	saved := cfg.ExcludePaths
+	cfg.ExcludePaths = []string{"*.log", "reports/*"}
+	defer func() { cfg.ExcludePaths = saved }()
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(filepath.Join(project, "reports"), 0755)
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("main"), 0644)
+	os.WriteFile(filepath.Join(project, "debug.log"), []byte("log"), 0644)
+	os.WriteFile(filepath.Join(project, "reports", "r.html"), []byte("report"), 0644)
+
+	cp, err := Create("rm -rf project", []string{project})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	for _, f := range cp.Manifest.Files {
+		if strings.HasSuffix(f.OriginalPath, ".log") || strings.Contains(f.OriginalPath, "reports") {
+			t.Errorf("%s should be excluded", f.OriginalPath)
+		}
+	}
+	if _, err := FindFile(cp, "main.go"); err != nil {
+		t.Errorf("main.go should be backed up: %v", err)
+	}
+}
+
+func TestDisabledExclusions(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	cfg := config.Get()
+	saved := cfg.DisabledExclusions
+	cfg.DisabledExclusions = []string{"vendor", ".safeshell"}
+	defer func() { cfg.DisabledExclusions = saved }()
+
+	if shouldExclude("/p/vendor") {
+		t.Error("vendor should be backed up once its exclusion is disabled")
+	}
+	if !shouldExclude("/p/__pycache__") {
+		t.Error("__pycache__ should still be excluded")
+	}
+	if !shouldExclude("/p/.safeshell") {
+		t.Error(".safeshell should always be excluded")
+	}
+}
+
+func TestCreateIncluding(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(filepath.Join(project, "migrations"), 0755)
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("main"), 0644)
+	os.WriteFile(filepath.Join(project, "data.bin"), []byte("data"), 0644)
+	os.WriteFile(filepath.Join(project, "migrations", "001.up"), []byte("up"), 0644)
+
+	cp, err := store.CreateIncluding("rm -rf project", []string{project}, []string{"*.go", "migrations/*"})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	for _, name := range []string{"main.go", "001.up"} {
+		if _, err := FindFile(cp, name); err != nil {
+			t.Errorf("%s should be backed up: %v", name, err)
+		}
+	}
+	if _, err := FindFile(cp, "data.bin"); err == nil {
+		t.Error("data.bin should not be backed up")
+	}
+	if _, err := os.Stat(filepath.Join(cp.FilesDir, strings.TrimPrefix(project, "/"), "data.bin")); err == nil {
+		t.Error("data.bin should not be copied")
+	}
+	if len(cp.Manifest.Include) != 2 {
+		t.Errorf("Expected the include patterns in the manifest, got %v", cp.Manifest.Include)
+	}
+
+	if _, err := store.CreateIncluding("rm -rf project", []string{project}, []string{"[*.go"}); err == nil {
+		t.Error("Expected an invalid pattern to be refused")
+	}
+}
+
+// makeSizedFile creates a sparse file of the given size
+func makeSizedFile(t *testing.T, path string, size int64) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, size); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuggestExclusions(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	makeSizedFile(t, filepath.Join(project, "coverage", "lcov", "report.html"), 12<<20)
+	makeSizedFile(t, filepath.Join(project, "pkg", "coverage", "more.html"), 3<<20)
+	makeSizedFile(t, filepath.Join(project, "assets", "video.mp4"), 90<<20)
+	makeSizedFile(t, filepath.Join(project, "src", "main.go"), 1<<20)
+
+	var out bytes.Buffer
+	cp, err := Create("rm -rf project", []string{project})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	suggestions := SuggestExclusions(cp)
+	if len(suggestions) != 2 {
+		t.Fatalf("Expected 2 suggestions, got %+v", suggestions)
+	}
+	// Unfamiliar directories are suggested only for a large share
+	if s := suggestions[0]; s.Pattern != "assets/*" || s.Size != 90<<20 || s.Files != 1 {
+		t.Errorf("Unexpected first suggestion: %+v", s)
+	}
+	// Both coverage directories are folded into one pattern
+	if s := suggestions[1]; s.Pattern != "coverage/*" || s.Size != 15<<20 || len(s.Dirs) != 2 {
+		t.Errorf("Unexpected second suggestion: %+v", s)
+	}
+
+	adviseExclusions(&out, cp, nil)
+	if !strings.Contains(out.String(), "safeshell exclude add 'coverage/*'") {
+		t.Errorf("Expected advice for an oversized checkpoint, got %q", out.String())
+	}
+
+	// Declined and already excluded patterns are not suggested again
+	if err := DismissExclusion("assets/*"); err != nil {
+		t.Fatalf("DismissExclusion failed: %v", err)
+	}
+	cfg := config.Get()
+	saved := cfg.ExcludePaths
+	cfg.ExcludePaths = append([]string{"coverage/*"}, saved...)
+	defer func() { cfg.ExcludePaths = saved }()
+	if s := SuggestExclusions(cp); len(s) != 0 {
+		t.Errorf("Expected no suggestions, got %+v", s)
+	}
+}
+
+func TestIsOversized(t *testing.T) {
+	var recent []*IndexEntry
+	for i := 0; i < 5; i++ {
+		recent = append(recent, &IndexEntry{TotalSize: 1 << 20})
+	}
+	if IsOversized(5<<20, recent) {
+		t.Error("5 MB is too small to be oversized")
+	}
+	if !IsOversized(20<<20, recent) {
+		t.Error("20x the median should be oversized")
+	}
+	if IsOversized(20<<20, recent[:4]) {
+		t.Error("Too little history to compare against")
+	}
+	if !IsOversized(200<<20, nil) {
+		t.Error("Over 100 MB is always oversized")
+	}
+}
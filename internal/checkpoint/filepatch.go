@@ -0,0 +1,101 @@
+package checkpoint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrBinaryFile is returned for files that cannot be restored by hunk
+var ErrBinaryFile = errors.New("binary file")
+
+// FilePatch is the line diff from a modified file's current content back to
+// its checkpointed content, split into hunks that can be restored one at a
+// time: '-' lines are current content, '+' lines checkpointed content.
+type FilePatch struct {
+	Path  string
+	Hunks []Hunk
+
+	current []byte
+	edits   []edit
+}
+
+// NewFilePatch diffs the current content of f against its backup in cp.
+// Binary files return ErrBinaryFile.
+func NewFilePatch(cp *Checkpoint, f FileEntry) (*FilePatch, error) {
+	var backup bytes.Buffer
+	if err := StreamFile(cp, f, &backup); err != nil {
+		return nil, err
+	}
+	current, err := os.ReadFile(f.OriginalPath)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(backup.Bytes()) || isBinary(current) {
+		return nil, fmt.Errorf("%s: %w", f.OriginalPath, ErrBinaryFile)
+	}
+
+	edits := lineDiff(splitLines(current), splitLines(backup.Bytes()))
+	return &FilePatch{
+		Path:    f.OriginalPath,
+		Hunks:   splitHunks(edits),
+		current: current,
+		edits:   edits,
+	}, nil
+}
+
+// Restore rewrites the file with the selected hunks restored to their
+// checkpointed content, leaving the rest as it is. The file is replaced
+// rather than written in place, as later checkpoints may hold hard links
+// to it.
+func (p *FilePatch) Restore(selected []bool) error {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return err
+	}
+	current, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, p.current) {
+		return fmt.Errorf("%s has changed since it was diffed", p.Path)
+	}
+
+	restore := make([]bool, len(p.edits))
+	for i, h := range p.Hunks {
+		if i < len(selected) && selected[i] {
+			for j := h.start; j < h.stop; j++ {
+				restore[j] = true
+			}
+		}
+	}
+
+	var content bytes.Buffer
+	for i, e := range p.edits {
+		switch {
+		case e.op == ' ',
+			e.op == '-' && !restore[i],
+			e.op == '+' && restore[i]:
+			content.WriteString(e.line)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.Path), ".safeshell-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.Path)
+}
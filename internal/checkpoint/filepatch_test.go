@@ -0,0 +1,70 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilePatchRestore(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "testdata", "file.txt")
+	var original []string
+	for i := 1; i <= 20; i++ {
+		original = append(original, fmt.Sprintf("line %d\n", i))
+	}
+	os.WriteFile(file, []byte(strings.Join(original, "")), 0644)
+
+	cp, err := Create("test", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Two changes far enough apart to be separate hunks
+	changed := append([]string(nil), original...)
+	changed[1] = "line two\n"
+	changed[17] = "line eighteen\n"
+	os.Remove(file)
+	os.WriteFile(file, []byte(strings.Join(changed, "")), 0755)
+
+	patch, err := NewFilePatch(cp, cp.Manifest.Files[0])
+	if err != nil {
+		t.Fatalf("NewFilePatch failed: %v", err)
+	}
+	if len(patch.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(patch.Hunks))
+	}
+	if lines := patch.Hunks[0].Lines(); !slices.Contains(lines, "-line two") || !slices.Contains(lines, "+line 2") {
+		t.Errorf("Expected the first hunk to restore line 2, got %q", lines)
+	}
+
+	if err := patch.Restore([]bool{true, false}); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	expected := append([]string(nil), original...)
+	expected[17] = "line eighteen\n"
+	if data, _ := os.ReadFile(file); string(data) != strings.Join(expected, "") {
+		t.Errorf("Expected only the first hunk restored, got:\n%s", data)
+	}
+	if info, _ := os.Stat(file); info.Mode().Perm() != 0755 {
+		t.Errorf("Expected the current mode kept, got %v", info.Mode().Perm())
+	}
+
+	// The patch was made against content that is gone now
+	if err := patch.Restore([]bool{false, true}); err == nil {
+		t.Error("Expected a stale patch to be refused")
+	}
+
+	binary := filepath.Join(tmpDir, "testdata", "file.bin")
+	os.WriteFile(binary, []byte{0, 1, 2}, 0644)
+	cp, _ = Create("test", []string{binary})
+	if _, err := NewFilePatch(cp, cp.Manifest.Files[0]); !errors.Is(err, ErrBinaryFile) {
+		t.Errorf("Expected ErrBinaryFile, got %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of problem Fsck finds
+const (
+	FsckManifest   = "manifest"   // missing or unreadable
+	FsckFilesDir   = "files"      // no backups on disk, neither files dir nor archive
+	FsckCompressed = "compressed" // Compressed disagrees with what is on disk
+	FsckIndex      = "index"      // entry missing, dangling or out of date
+	FsckIncomplete = "incomplete" // creation didn't finish
+)
+
+// FsckProblem is something wrong with a checkpoint, or the index entry of
+// one, and what repairing it does
+type FsckProblem struct {
+	ID      string
+	Kind    string
+	Problem string
+	Repair  string
+}
+
+// RebuiltCommand is the command of a checkpoint whose manifest Fsck rebuilt,
+// which it can't know
+const RebuiltCommand = "(unknown: manifest rebuilt by fsck)"
+
+// Fsck checks every checkpoint in the store and the index against what is
+// on disk: manifests that are missing or can't be read, checkpoints with
+// neither a files directory nor an archive, Compressed flags that disagree
+// with which of them there is, and index entries missing, dangling or out
+// of date. With repair, it fixes them: manifests are rebuilt from the
+// backups on disk, without what only the manifest knew, and the index is
+// rebuilt. Lost backups can't be brought back.
+func (s *Store) Fsck(repair bool) ([]FsckProblem, error) {
+	dirs, err := os.ReadDir(s.CheckpointsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var problems []FsckProblem
+	ids := make(map[string]bool)
+	manifests := make(map[string]*Manifest)
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		ids[d.Name()] = true
+		m, found, err := s.fsckCheckpoint(d.Name(), repair)
+		problems = append(problems, found...)
+		if err != nil {
+			return problems, err
+		}
+		if m != nil {
+			manifests[d.Name()] = m
+		}
+	}
+
+	// Read as it is: loading it may rebuild it
+	found := fsckIndex(newIndex(s.CheckpointsDir()).path(), ids, manifests)
+	problems = append(problems, found...)
+	if repair && len(found) > 0 {
+		if err := s.Index().Rebuild(); err != nil {
+			return problems, fmt.Errorf("failed to rebuild index: %w", err)
+		}
+	}
+	return problems, nil
+}
+
+// fsckCheckpoint checks the checkpoint id, repairing it if asked to, and
+// returns its manifest as it is once done, nil if it has none
+func (s *Store) fsckCheckpoint(id string, repair bool) (*Manifest, []FsckProblem, error) {
+	dir := s.checkpointDir(id)
+	filesDir := GetFilesDir(dir)
+	_, filesErr := os.Stat(filesDir)
+	hasFiles := filesErr == nil
+	algorithm, archive, hasArchive := findArchive(dir)
+	var problems []FsckProblem
+
+	if how := Incomplete(dir); how != "" {
+		return s.fsckIncomplete(id, how, repair)
+	}
+
+	m, err := LoadManifest(dir)
+	if err != nil {
+		p := FsckProblem{ID: id, Kind: FsckManifest, Problem: fmt.Sprintf("manifest unreadable: %v", err)}
+		if os.IsNotExist(err) {
+			p.Problem = "manifest missing"
+		}
+		if !hasFiles && !hasArchive {
+			p.Repair = "nothing to rebuild it from: no backups on disk"
+			return nil, append(problems, p), nil
+		}
+		p.Repair = "rebuilt from the backups on disk"
+		problems = append(problems, p)
+		if !repair {
+			return nil, problems, nil
+		}
+		if !hasFiles {
+			// The archive is unpacked to list what it holds
+			if err := DecompressDir(archive, filesDir, algorithm); err != nil {
+				return nil, problems, fmt.Errorf("%s: %w", id, err)
+			}
+			if err := os.Remove(archive); err != nil {
+				return nil, problems, fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		if m, err = rebuildManifest(id, dir); err != nil {
+			return nil, problems, fmt.Errorf("%s: failed to rebuild manifest: %w", id, err)
+		}
+		return m, problems, nil
+	}
+
+	changed := false
+	switch {
+	case !hasFiles && !hasArchive:
+		problems = append(problems, FsckProblem{ID: id, Kind: FsckFilesDir,
+			Problem: "no files directory or archive: its backups are lost",
+			Repair:  "empty files directory created, for the checkpoint to be listed and deleted as usual"})
+		if repair {
+			if err := os.MkdirAll(filesDir, 0755); err != nil {
+				return m, problems, err
+			}
+			if m.Compressed {
+				clearCompressed(m)
+				changed = true
+			}
+		}
+	case m.Compressed && !hasArchive:
+		problems = append(problems, FsckProblem{ID: id, Kind: FsckCompressed,
+			Problem: "marked compressed, but there is no archive",
+			Repair:  "marked uncompressed, as its files directory is there"})
+		if repair {
+			clearCompressed(m)
+			changed = true
+		}
+	case !m.Compressed && hasArchive && !hasFiles:
+		// Compressing removes the files directory before saving the manifest
+		problems = append(problems, FsckProblem{ID: id, Kind: FsckCompressed,
+			Problem: "not marked compressed, but there is only an archive",
+			Repair:  "marked compressed"})
+		if repair {
+			m.Compressed = true
+			m.CompressionAlgorithm = algorithm
+			if info, err := os.Stat(archive); err == nil {
+				m.CompressedSize = info.Size()
+			}
+			changed = true
+		}
+	case m.Compressed && hasFiles:
+		// Left over by an interrupted decompression: the archive holds it all
+		problems = append(problems, FsckProblem{ID: id, Kind: FsckCompressed,
+			Problem: "compressed, but a files directory was left next to the archive",
+			Repair:  "files directory removed"})
+		if repair {
+			if err := os.RemoveAll(filesDir); err != nil {
+				return m, problems, err
+			}
+		}
+	case !m.Compressed && hasArchive:
+		// Left over by an interrupted compression, maybe incomplete
+		problems = append(problems, FsckProblem{ID: id, Kind: FsckCompressed,
+			Problem: "not compressed, but an archive was left next to the files directory",
+			Repair:  "archive removed"})
+		if repair {
+			if err := os.Remove(archive); err != nil {
+				return m, problems, err
+			}
+		}
+	}
+
+	if repair && changed {
+		if err := m.Save(dir); err != nil {
+			return m, problems, err
+		}
+	}
+	return m, problems, nil
+}
+
+// fsckIncomplete checks the checkpoint id, whose creation didn't finish:
+// either safeshell was killed before removing the marker, with the manifest
+// saved, or it holds partial backups, or the originals Trash moved so far
+func (s *Store) fsckIncomplete(id, how string, repair bool) (*Manifest, []FsckProblem, error) {
+	dir := s.checkpointDir(id)
+	p := FsckProblem{ID: id, Kind: FsckIncomplete}
+	m, err := readManifest(dir)
+	switch {
+	case err == nil:
+		p.Problem = "marked as being created, but its manifest was saved"
+		p.Repair = "marked as created"
+	case how == IncompleteMove:
+		p.Problem = "creation did not finish, holding the files moved into it so far"
+		p.Repair = "manifest rebuilt from them, for rollback to put them back"
+	default:
+		// Left alone: it may be being created right now
+		p.Problem = "creation did not finish, or is running: its backups may be partial"
+		p.Repair = "none, 'safeshell gc' removes it"
+		if _, err := resumableState(id, dir); err == nil {
+			p.Repair = fmt.Sprintf("none, 'safeshell checkpoint resume %s' finishes it or 'safeshell gc' removes it", id)
+		}
+		return nil, []FsckProblem{p}, nil
+	}
+	if !repair {
+		return nil, []FsckProblem{p}, nil
+	}
+	if m == nil {
+		if m, err = rebuildManifest(id, dir); err != nil {
+			return nil, []FsckProblem{p}, fmt.Errorf("%s: failed to rebuild manifest: %w", id, err)
+		}
+	}
+	if err := markComplete(dir); err != nil {
+		return nil, []FsckProblem{p}, err
+	}
+	return m, []FsckProblem{p}, nil
+}
+
+// findArchive returns the algorithm and path of the archive in a
+// checkpoint directory, if there is one
+func findArchive(dir string) (string, string, bool) {
+	for _, algorithm := range CompressionAlgorithms {
+		path := GetArchivePath(dir, algorithm)
+		if _, err := os.Stat(path); err == nil {
+			return algorithm, path, true
+		}
+	}
+	return "", "", false
+}
+
+func clearCompressed(m *Manifest) {
+	m.Compressed = false
+	m.CompressedSize = 0
+	m.CompressionAlgorithm = ""
+	m.FormatVersion = 0
+}
+
+// rebuildManifest writes a manifest for the checkpoint in dir listing the
+// backups in its files directory. The time comes from the ID, which holds
+// it, or the directory; the command, session and hashes are lost. Only
+// files are listed: which directories were backed up rather than only
+// holding backups can't be told, and rollback would set the modes of the
+// latter, such as /tmp, to those of their copies.
+func rebuildManifest(id, dir string) (*Manifest, error) {
+	m := NewManifest(id, RebuiltCommand, "")
+	m.Note = "Manifest rebuilt by fsck from the backups on disk"
+	const idTime = "2006-01-02T150405"
+	if t, err := time.ParseInLocation(idTime, id[:min(len(id), len(idTime))], time.Local); err == nil {
+		m.Timestamp = t
+	} else if info, err := os.Stat(dir); err == nil {
+		m.Timestamp = info.ModTime()
+	}
+
+	filesDir := GetFilesDir(dir)
+	err := filepath.Walk(filesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(filesDir, path)
+		if err != nil {
+			return err
+		}
+		original := rel
+		if !filepath.IsAbs(original) {
+			original = string(filepath.Separator) + rel
+		}
+		m.AddFile(original, path, info.Mode(), info.Size(), false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, m.Save(dir)
+}
+
+// fsckIndex compares the index file at path with the checkpoints in the
+// store and their manifests, by ID
+func fsckIndex(path string, ids map[string]bool, manifests map[string]*Manifest) []FsckProblem {
+	const repair = "index rebuilt"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && len(manifests) == 0 {
+			return nil
+		}
+		return []FsckProblem{{Kind: FsckIndex, Problem: fmt.Sprintf("index unreadable: %v", err), Repair: repair}}
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return []FsckProblem{{Kind: FsckIndex, Problem: fmt.Sprintf("index damaged: %v", err), Repair: repair}}
+	}
+
+	var problems []FsckProblem
+	for id := range idx.Entries {
+		if !ids[id] {
+			problems = append(problems, FsckProblem{ID: id, Kind: FsckIndex, Problem: "indexed, but no checkpoint has it", Repair: repair})
+		}
+	}
+	for id, m := range manifests {
+		e := idx.Entries[id]
+		fileCount, totalSize := countFiles(m)
+		switch {
+		case e == nil:
+			problems = append(problems, FsckProblem{ID: id, Kind: FsckIndex, Problem: "missing from the index", Repair: repair})
+		case e.FileCount != fileCount || e.TotalSize != totalSize || e.Compressed != m.Compressed ||
+			e.CompressedSize != m.CompressedSize || e.RolledBack != m.RolledBack || e.Pinned != m.Pinned ||
+			e.Name != m.Name || e.Command != m.Command || !slices.Equal(e.Tags, m.Tags):
+			problems = append(problems, FsckProblem{ID: id, Kind: FsckIndex, Problem: "index entry disagrees with the manifest", Repair: repair})
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].ID < problems[j].ID })
+	return problems
+}
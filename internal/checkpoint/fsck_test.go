@@ -0,0 +1,105 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFsck(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	create := func(name string) *Checkpoint {
+		file := filepath.Join(tmpDir, "testdata", name)
+		os.WriteFile(file, []byte(name), 0644)
+		cp, err := store.Create("rm "+name, []string{file})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		return cp
+	}
+	intact := create("intact.txt")
+	lostManifest := create("lost-manifest.txt")
+	flagged := create("flagged.txt")
+	unflagged := create("unflagged.txt")
+	dangling := create("dangling.txt")
+	lostFiles := create("lost-files.txt")
+
+	os.Remove(filepath.Join(store.checkpointDir(lostManifest.ID), "manifest.json"))
+	flagged.Manifest.Compressed = true
+	flagged.Manifest.Save(store.checkpointDir(flagged.ID))
+	if _, _, err := store.Compress(unflagged.ID); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	unflagged, _ = store.Get(unflagged.ID)
+	unflagged.Manifest.Compressed = false
+	unflagged.Manifest.Save(store.checkpointDir(unflagged.ID))
+	os.RemoveAll(store.checkpointDir(dangling.ID))
+	os.RemoveAll(GetFilesDir(store.checkpointDir(lostFiles.ID)))
+
+	kinds := func(problems []FsckProblem) map[string][]string {
+		found := make(map[string][]string)
+		for _, p := range problems {
+			found[p.ID] = append(found[p.ID], p.Kind)
+		}
+		return found
+	}
+	problems, err := store.Fsck(false)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	found := kinds(problems)
+	for id, kind := range map[string]string{
+		lostManifest.ID: FsckManifest,
+		flagged.ID:      FsckCompressed,
+		unflagged.ID:    FsckCompressed,
+		dangling.ID:     FsckIndex,
+		lostFiles.ID:    FsckFilesDir,
+	} {
+		if len(found[id]) == 0 || found[id][0] != kind {
+			t.Errorf("Expected a %s problem for %s, got %v", kind, id, found[id])
+		}
+	}
+	if len(found[intact.ID]) != 0 {
+		t.Errorf("Expected no problems for the intact checkpoint, got %v", found[intact.ID])
+	}
+
+	// Without repair, nothing changes
+	if _, err := os.Stat(filepath.Join(store.checkpointDir(lostManifest.ID), "manifest.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the manifest to be still missing, got %v", err)
+	}
+
+	if _, err := store.Fsck(true); err != nil {
+		t.Fatalf("Fsck repair failed: %v", err)
+	}
+	if left, err := store.Fsck(false); err != nil || len(left) != 0 {
+		t.Errorf("Expected no problems after repair, got %+v (%v)", left, err)
+	}
+
+	rebuilt, err := store.Get(lostManifest.ID)
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint with rebuilt manifest: %v", err)
+	}
+	if rebuilt.Manifest.Command != RebuiltCommand || len(rebuilt.Manifest.Files) != 1 ||
+		rebuilt.Manifest.Files[0].OriginalPath != filepath.Join(tmpDir, "testdata", "lost-manifest.txt") {
+		t.Errorf("Expected the manifest rebuilt with its file, got %+v", rebuilt.Manifest)
+	}
+	if !rebuilt.Manifest.Timestamp.Equal(lostManifest.Manifest.Timestamp.Truncate(time.Second)) {
+		t.Errorf("Expected the time from the ID, got %v, created %v", rebuilt.Manifest.Timestamp, lostManifest.Manifest.Timestamp)
+	}
+	if cp, _ := store.Get(flagged.ID); cp.Manifest.Compressed {
+		t.Error("Expected the checkpoint without archive marked uncompressed")
+	}
+	if cp, _ := store.Get(unflagged.ID); !cp.Manifest.Compressed || cp.Manifest.CompressedSize == 0 {
+		t.Errorf("Expected the checkpoint with only an archive marked compressed, got %+v", cp.Manifest)
+	}
+	if store.Index().GetEntry(dangling.ID) != nil {
+		t.Error("Expected the dangling index entry removed")
+	}
+	if _, err := store.Get(lostFiles.ID); err != nil {
+		t.Errorf("Expected the checkpoint without files to remain, got %v", err)
+	}
+}
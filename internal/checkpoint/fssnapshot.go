@@ -0,0 +1,405 @@
+package checkpoint
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// With the fs_snapshots setting, a checkpoint of a directory takes a
+// snapshot of the filesystem holding it instead of copying its files, which
+// takes the same time however big the directory is. The manifest still
+// lists the files, with backup paths into the snapshot, reached through a
+// symlink in the checkpoint directory so everything reading backups works
+// unchanged. Deleting the checkpoint deletes the snapshot.
+
+// Filesystem snapshot backends
+const (
+	FSSnapshotsOff  = "off"
+	FSSnapshotsAuto = "auto" // The first backend able to snapshot the targets
+	FSSnapshotBtrfs = "btrfs"
+	FSSnapshotZFS   = "zfs"
+	FSSnapshotAPFS  = "apfs"
+)
+
+// FSSnapshotBackends lists the backends, in the order auto tries them
+var FSSnapshotBackends = []string{FSSnapshotBtrfs, FSSnapshotZFS, FSSnapshotAPFS}
+
+// FSSnapshot is the filesystem snapshot a checkpoint's backups are in
+type FSSnapshot struct {
+	Backend string `json:"backend"`
+	Root    string `json:"root"` // Mount point or subvolume snapshotted
+	Name    string `json:"name"` // The snapshot, as the backend's tools name it
+}
+
+// fsSnapshotLink is the symlink in a checkpoint directory to the files of
+// its snapshot
+const fsSnapshotLink = "snapshot"
+
+// snapshotter takes, mounts and deletes the snapshots of one backend
+type snapshotter interface {
+	// root returns the mount point or subvolume holding path, which a
+	// snapshot covers, or an error if it can't be snapshotted
+	root(path string) (string, error)
+	// take snapshots root. dir is a directory of the store the snapshot
+	// may be kept or mounted in.
+	take(root, dir string) (*FSSnapshot, error)
+	// files returns where the files of a snapshot taken with dir are
+	files(snap *FSSnapshot, dir string) string
+	// mount makes the snapshot's files reachable where files said, if
+	// they aren't already
+	mount(snap *FSSnapshot, files string) error
+	// remove unmounts and deletes the snapshot
+	remove(snap *FSSnapshot, files string) error
+}
+
+// snapshotters holds the snapshotter of each backend; tests replace them
+var snapshotters = map[string]snapshotter{
+	FSSnapshotBtrfs: btrfsSnapshotter{},
+	FSSnapshotZFS:   zfsSnapshotter{},
+	FSSnapshotAPFS:  apfsSnapshotter{},
+}
+
+// FSSnapshotsDir returns where the store keeps or mounts filesystem
+// snapshots
+func (s *Store) FSSnapshotsDir() string {
+	return filepath.Join(s.dir, "fs-snapshots")
+}
+
+// takeFSSnapshot snapshots the filesystem holding targets for the
+// checkpoint id, linking it from checkpointDir. It returns nil, for the
+// files to be copied instead, if fs_snapshots is off, no target is a
+// directory, or no backend can snapshot them all at once.
+func (s *Store) takeFSSnapshot(id, checkpointDir string, targets []string) *FSSnapshot {
+	cfg := config.Get()
+	if cfg == nil || cfg.FSSnapshots == "" || cfg.FSSnapshots == FSSnapshotsOff {
+		return nil
+	}
+	var existing []string
+	hasDir := false
+	for _, t := range targets {
+		if info, err := os.Stat(t); err == nil {
+			existing = append(existing, t)
+			hasDir = hasDir || info.IsDir()
+		}
+	}
+	if !hasDir {
+		return nil
+	}
+
+	backends := []string{cfg.FSSnapshots}
+	if cfg.FSSnapshots == FSSnapshotsAuto {
+		backends = FSSnapshotBackends
+	}
+	for _, backend := range backends {
+		sn, ok := snapshotters[backend]
+		if !ok {
+			continue
+		}
+		root, err := commonRoot(sn, existing)
+		if err != nil {
+			logging.Debug("no filesystem snapshot", "backend", backend, "error", err)
+			if cfg.FSSnapshots != FSSnapshotsAuto {
+				logging.Warn(fmt.Sprintf("Warning: can't take a %s snapshot, copying files instead: %v", backend, err))
+			}
+			continue
+		}
+
+		dir := filepath.Join(s.FSSnapshotsDir(), id)
+		if err := os.MkdirAll(s.FSSnapshotsDir(), 0755); err != nil {
+			logging.Warn(fmt.Sprintf("Warning: failed to take a %s snapshot, copying files instead: %v", backend, err))
+			return nil
+		}
+		snap, err := sn.take(root, dir)
+		if err != nil {
+			logging.Warn(fmt.Sprintf("Warning: failed to take a %s snapshot, copying files instead: %v", backend, err))
+			return nil
+		}
+		files := sn.files(snap, dir)
+		if err := os.Symlink(files, filepath.Join(checkpointDir, fsSnapshotLink)); err != nil {
+			sn.remove(snap, files)
+			logging.Warn(fmt.Sprintf("Warning: failed to link the %s snapshot, copying files instead: %v", backend, err))
+			return nil
+		}
+		logging.Debug("took filesystem snapshot", "backend", backend, "root", root, "name", snap.Name)
+		return snap
+	}
+	return nil
+}
+
+// commonRoot returns the root sn snapshots for every path, or an error if
+// they are not all under the same one
+func commonRoot(sn snapshotter, paths []string) (string, error) {
+	var root string
+	for _, p := range paths {
+		r, err := sn.root(p)
+		if err != nil {
+			return "", err
+		}
+		if root != "" && r != root {
+			return "", fmt.Errorf("%s and %s are on different filesystems", root, r)
+		}
+		root = r
+	}
+	return root, nil
+}
+
+// backupPath returns where path is in the snapshot, through the link in
+// checkpointDir
+func (snap *FSSnapshot) backupPath(checkpointDir, path string) string {
+	rel, err := filepath.Rel(snap.Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		// Firmlinked into the volume, as /Users is on macOS
+		rel = strings.TrimPrefix(path, "/")
+	}
+	return filepath.Join(checkpointDir, fsSnapshotLink, rel)
+}
+
+// snapshotterOf returns the snapshotter of snap and where its files are
+// for the checkpoint in checkpointDir
+func snapshotterOf(snap *FSSnapshot, checkpointDir string) (snapshotter, string, error) {
+	sn, ok := snapshotters[snap.Backend]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown snapshot backend %q", snap.Backend)
+	}
+	files, err := os.Readlink(filepath.Join(checkpointDir, fsSnapshotLink))
+	if err != nil {
+		return nil, "", fmt.Errorf("snapshot link missing: %w", err)
+	}
+	return sn, files, nil
+}
+
+// MountFSSnapshot makes the backups of a checkpoint kept in a filesystem
+// snapshot readable, mounting the snapshot if need be, as APFS ones are
+// after a restart. It does nothing for other checkpoints.
+func MountFSSnapshot(cp *Checkpoint) error {
+	snap := cp.Manifest.FSSnapshot
+	if snap == nil {
+		return nil
+	}
+	sn, files, err := snapshotterOf(snap, cp.Dir)
+	if err != nil {
+		return err
+	}
+	return sn.mount(snap, files)
+}
+
+// removeFSSnapshot deletes the filesystem snapshot of the checkpoint in
+// checkpointDir
+func removeFSSnapshot(snap *FSSnapshot, checkpointDir string) error {
+	sn, files, err := snapshotterOf(snap, checkpointDir)
+	if err != nil {
+		return err
+	}
+	return sn.remove(snap, files)
+}
+
+// runTool runs one of the filesystem's tools, returning its output; a
+// variable so tests can fake them
+var runTool = func(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return string(out), nil
+}
+
+// btrfsSnapshotter takes read-only snapshots of btrfs subvolumes, kept in
+// the store, which must be on the same filesystem
+type btrfsSnapshotter struct{}
+
+// btrfsSubvolumeInode is the inode number of the root of every subvolume
+const btrfsSubvolumeInode = 256
+
+func (btrfsSnapshotter) root(path string) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", errors.ErrUnsupported
+	}
+	if fsType, err := runTool("stat", "-f", "-c", "%T", path); err != nil {
+		return "", err
+	} else if strings.TrimSpace(fsType) != "btrfs" {
+		return "", fmt.Errorf("%s is not on btrfs", path)
+	}
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && inode(info) == btrfsSubvolumeInode {
+			return dir, nil
+		}
+		if dir == filepath.Dir(dir) {
+			return "", fmt.Errorf("no btrfs subvolume holds %s", path)
+		}
+	}
+}
+
+func (btrfsSnapshotter) take(root, dir string) (*FSSnapshot, error) {
+	if _, err := runTool("btrfs", "subvolume", "snapshot", "-r", root, dir); err != nil {
+		return nil, err
+	}
+	return &FSSnapshot{Backend: FSSnapshotBtrfs, Root: root, Name: dir}, nil
+}
+
+func (btrfsSnapshotter) files(snap *FSSnapshot, dir string) string {
+	return snap.Name
+}
+
+func (btrfsSnapshotter) mount(snap *FSSnapshot, files string) error {
+	return nil
+}
+
+func (btrfsSnapshotter) remove(snap *FSSnapshot, files string) error {
+	_, err := runTool("btrfs", "subvolume", "delete", snap.Name)
+	return err
+}
+
+// zfsSnapshotter takes ZFS snapshots of datasets, whose files are in the
+// dataset's .zfs/snapshot directory
+type zfsSnapshotter struct{}
+
+func (zfsSnapshotter) root(path string) (string, error) {
+	out, err := runTool("zfs", "list", "-H", "-o", "name,mountpoint", "-t", "filesystem")
+	if err != nil {
+		return "", err
+	}
+	_, mountpoint := zfsDataset(out, path)
+	if mountpoint == "" {
+		return "", fmt.Errorf("%s is not on a mounted ZFS dataset", path)
+	}
+	return mountpoint, nil
+}
+
+// zfsDataset returns the dataset holding path and its mount point, from
+// the output of zfs list -H -o name,mountpoint
+func zfsDataset(list, path string) (string, string) {
+	var dataset, mountpoint string
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		name, mnt, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || !filepath.IsAbs(mnt) || len(mnt) <= len(mountpoint) {
+			continue
+		}
+		if rel, err := filepath.Rel(mnt, path); err == nil && !strings.HasPrefix(rel, "..") {
+			dataset, mountpoint = name, mnt
+		}
+	}
+	return dataset, mountpoint
+}
+
+func (zfsSnapshotter) take(root, dir string) (*FSSnapshot, error) {
+	out, err := runTool("zfs", "list", "-H", "-o", "name,mountpoint", "-t", "filesystem")
+	if err != nil {
+		return nil, err
+	}
+	dataset, _ := zfsDataset(out, root)
+	if dataset == "" {
+		return nil, fmt.Errorf("%s is not on a mounted ZFS dataset", root)
+	}
+	name := dataset + "@safeshell-" + filepath.Base(dir)
+	if _, err := runTool("zfs", "snapshot", name); err != nil {
+		return nil, err
+	}
+	return &FSSnapshot{Backend: FSSnapshotZFS, Root: root, Name: name}, nil
+}
+
+func (zfsSnapshotter) files(snap *FSSnapshot, dir string) string {
+	_, name, _ := strings.Cut(snap.Name, "@")
+	return filepath.Join(snap.Root, ".zfs", "snapshot", name)
+}
+
+func (zfsSnapshotter) mount(snap *FSSnapshot, files string) error {
+	return nil
+}
+
+func (zfsSnapshotter) remove(snap *FSSnapshot, files string) error {
+	_, err := runTool("zfs", "destroy", snap.Name)
+	return err
+}
+
+// apfsSnapshotter takes APFS local snapshots with tmutil, mounted in the
+// store on demand. macOS deletes local snapshots itself when it runs low
+// on space, or after a day.
+type apfsSnapshotter struct{}
+
+func (apfsSnapshotter) root(path string) (string, error) {
+	if runtime.GOOS != "darwin" {
+		return "", errors.ErrUnsupported
+	}
+	out, err := runTool("df", "-P", path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var fields []string
+	if len(lines) >= 2 {
+		fields = strings.Fields(lines[len(lines)-1])
+	}
+	if len(fields) < 6 {
+		return "", fmt.Errorf("unexpected df output for %s", path)
+	}
+	mountpoint := strings.Join(fields[5:], " ")
+
+	mounts, err := runTool("mount")
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(mounts, " on "+mountpoint+" (apfs") {
+		return "", fmt.Errorf("%s is not on APFS", path)
+	}
+	return mountpoint, nil
+}
+
+func (apfsSnapshotter) take(root, dir string) (*FSSnapshot, error) {
+	out, err := runTool("tmutil", "localsnapshot")
+	if err != nil {
+		return nil, err
+	}
+	date := tmutilSnapshotDate(out)
+	if date == "" {
+		return nil, fmt.Errorf("unexpected tmutil output: %s", strings.TrimSpace(out))
+	}
+	return &FSSnapshot{Backend: FSSnapshotAPFS, Root: root, Name: "com.apple.TimeMachine." + date + ".local"}, nil
+}
+
+// tmutilSnapshotDate returns the date naming the snapshot tmutil
+// localsnapshot created, from its output
+func tmutilSnapshotDate(out string) string {
+	const prefix = "Created local snapshot with date: "
+	for _, line := range strings.Split(out, "\n") {
+		if date, ok := strings.CutPrefix(strings.TrimSpace(line), prefix); ok {
+			return strings.TrimSpace(date)
+		}
+	}
+	return ""
+}
+
+func (apfsSnapshotter) files(snap *FSSnapshot, dir string) string {
+	return dir
+}
+
+func (apfsSnapshotter) mount(snap *FSSnapshot, files string) error {
+	if entries, err := os.ReadDir(files); err == nil && len(entries) > 0 {
+		return nil // Mounted already
+	}
+	if err := os.MkdirAll(files, 0755); err != nil {
+		return err
+	}
+	_, err := runTool("mount_apfs", "-o", "rdonly,nobrowse", "-s", snap.Name, snap.Root, files)
+	return err
+}
+
+func (apfsSnapshotter) remove(snap *FSSnapshot, files string) error {
+	runTool("umount", files) // Only mounted if it was read from
+	os.Remove(files)
+	date := strings.TrimSuffix(strings.TrimPrefix(snap.Name, "com.apple.TimeMachine."), ".local")
+	_, err := runTool("tmutil", "deletelocalsnapshots", date)
+	return err
+}
@@ -0,0 +1,159 @@
+package checkpoint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// copySnapshotter snapshots by copying root, for filesystems without
+// snapshots
+type copySnapshotter struct {
+	roots   string
+	removed []string
+}
+
+func (c *copySnapshotter) root(path string) (string, error) {
+	return c.roots, nil
+}
+
+func (c *copySnapshotter) take(root, dir string) (*FSSnapshot, error) {
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), 0755)
+		}
+		return copyFile(path, filepath.Join(dir, rel))
+	})
+	return &FSSnapshot{Backend: FSSnapshotBtrfs, Root: root, Name: dir}, err
+}
+
+func (c *copySnapshotter) files(snap *FSSnapshot, dir string) string {
+	return dir
+}
+
+func (c *copySnapshotter) mount(snap *FSSnapshot, files string) error {
+	return nil
+}
+
+func (c *copySnapshotter) remove(snap *FSSnapshot, files string) error {
+	c.removed = append(c.removed, snap.Name)
+	return os.RemoveAll(files)
+}
+
+func TestFSSnapshot(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	fake := &copySnapshotter{roots: filepath.Join(tmpDir, "testdata")}
+	saved := snapshotters[FSSnapshotBtrfs]
+	snapshotters[FSSnapshotBtrfs] = fake
+	config.Get().FSSnapshots = FSSnapshotBtrfs
+	defer func() {
+		snapshotters[FSSnapshotBtrfs] = saved
+		config.Get().FSSnapshots = FSSnapshotsOff
+	}()
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	file := filepath.Join(project, "src", "main.go")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	os.WriteFile(file, []byte("package main"), 0644)
+
+	cp, err := store.Create("rm -rf project", []string{project})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	snap := cp.Manifest.FSSnapshot
+	if snap == nil || snap.Name != filepath.Join(store.FSSnapshotsDir(), cp.ID) {
+		t.Fatalf("Expected the checkpoint to take a snapshot, got %+v", snap)
+	}
+	if entries, _ := os.ReadDir(cp.FilesDir); len(entries) != 0 {
+		t.Errorf("Expected no files copied, got %d", len(entries))
+	}
+
+	var entry *FileEntry
+	for i, f := range cp.Manifest.Files {
+		if f.OriginalPath == file {
+			entry = &cp.Manifest.Files[i]
+		}
+	}
+	if entry == nil || entry.BackupPath != filepath.Join(cp.Dir, fsSnapshotLink, "project", "src", "main.go") {
+		t.Fatalf("Expected the file recorded with a path into the snapshot, got %+v", entry)
+	}
+
+	os.WriteFile(file, []byte("package changed"), 0644)
+	var content bytes.Buffer
+	if err := StreamFile(cp, *entry, &content); err != nil || content.String() != "package main" {
+		t.Errorf("Expected the snapshot to hold the old content, got %q, %v", content.String(), err)
+	}
+	if err := Verify(cp); err != nil {
+		t.Errorf("Expected the snapshot verified, got %v", err)
+	}
+	if _, _, err := store.Compress(cp.ID); err == nil {
+		t.Error("Expected compressing a snapshot checkpoint to fail")
+	}
+	if NeedsCompaction(cp) {
+		t.Error("Expected a snapshot checkpoint not to need compaction")
+	}
+
+	// A single file is copied as before
+	single, err := store.Create("rm main.go", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if single.Manifest.FSSnapshot != nil {
+		t.Error("Expected a checkpoint of a single file not to take a snapshot")
+	}
+
+	if err := store.Delete(cp.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(fake.removed) != 1 || fake.removed[0] != snap.Name {
+		t.Errorf("Expected deleting the checkpoint to delete its snapshot, got %v", fake.removed)
+	}
+	if _, err := os.Stat(snap.Name); !os.IsNotExist(err) {
+		t.Errorf("Expected the snapshot gone, got %v", err)
+	}
+}
+
+func TestZFSDataset(t *testing.T) {
+	list := strings.Join([]string{
+		"tank\t/tank",
+		"tank/home\t/home",
+		"tank/home/dev\t/home/dev",
+		"tank/legacy\tlegacy",
+		"",
+	}, "\n")
+	tests := []struct {
+		path, dataset, mountpoint string
+	}{
+		{"/home/dev/project", "tank/home/dev", "/home/dev"},
+		{"/home/other", "tank/home", "/home"},
+		{"/home/developer", "tank/home", "/home"},
+		{"/tank/data", "tank", "/tank"},
+		{"/var/lib", "", ""},
+	}
+	for _, tt := range tests {
+		dataset, mountpoint := zfsDataset(list, tt.path)
+		if dataset != tt.dataset || mountpoint != tt.mountpoint {
+			t.Errorf("zfsDataset(%q) = %q, %q, want %q, %q", tt.path, dataset, mountpoint, tt.dataset, tt.mountpoint)
+		}
+	}
+}
+
+func TestTmutilSnapshotDate(t *testing.T) {
+	if date := tmutilSnapshotDate("NOTE: some volumes\nCreated local snapshot with date: 2026-10-16-143022\n"); date != "2026-10-16-143022" {
+		t.Errorf("Expected the snapshot date, got %q", date)
+	}
+	if date := tmutilSnapshotDate("Failed to create APFS snapshot\n"); date != "" {
+		t.Errorf("Expected no date, got %q", date)
+	}
+}
@@ -0,0 +1,167 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of garbage GC removes
+const (
+	GarbageNoManifest = "no-manifest" // checkpoint directory without a valid manifest
+	GarbageIncomplete = "incomplete"  // checkpoint whose creation didn't finish
+	GarbageLeftover   = "leftover"    // archive or files directory left by an interrupted (de)compression
+	GarbageTemp       = "temp"        // temporary file or directory of an interrupted write
+	GarbageIndex      = "index"       // index entry whose checkpoint directory is gone
+)
+
+// GCGrace is how long GC leaves garbage alone, as what looks like garbage
+// may be a checkpoint being created or compressed right now
+const GCGrace = time.Hour
+
+// Garbage is something in the store GC removes
+type Garbage struct {
+	Kind string
+	ID   string // Checkpoint it belongs to, if any
+	Path string // Empty for index entries
+	Size int64  // Space removing it frees
+}
+
+// GC removes what interrupted operations leave in the store: checkpoints
+// whose creation didn't finish, but for those holding the originals Trash
+// moved, checkpoint directories without a valid manifest, archives left
+// next to extracted files and the other way round, temporary files and
+// index entries whose directories are gone. Only garbage older than GCGrace
+// is removed. With dryRun, it is only returned. Checkpoints without a
+// manifest can be kept by running Fsck with repair first, which rebuilds
+// it; so do incomplete checkpoints holding originals.
+func (s *Store) GC(dryRun bool) ([]Garbage, error) {
+	var garbage []Garbage
+	cutoff := time.Now().Add(-GCGrace)
+	add := func(kind, id, path string) {
+		if modified, ok := lastModified(path); ok && modified.Before(cutoff) {
+			garbage = append(garbage, Garbage{Kind: kind, ID: id, Path: path, Size: reclaimable(path)})
+		}
+	}
+
+	dirs, err := os.ReadDir(s.CheckpointsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, d := range dirs {
+		name := d.Name()
+		path := filepath.Join(s.CheckpointsDir(), name)
+		switch {
+		case strings.HasPrefix(name, ".pull-"), strings.HasSuffix(name, ".tmp"):
+			add(GarbageTemp, "", path)
+			continue
+		case !d.IsDir() || strings.HasPrefix(name, "."):
+			continue
+		}
+
+		switch Incomplete(path) {
+		case IncompleteCopy:
+			// With its manifest saved, it only needs marking as created
+			if _, err := readManifest(path); err != nil {
+				add(GarbageIncomplete, name, path)
+			}
+			continue
+		case IncompleteMove:
+			// It holds originals, for Fsck to make them rollbackable
+			continue
+		}
+		m, err := LoadManifest(path)
+		if err != nil {
+			add(GarbageNoManifest, name, path)
+			continue
+		}
+		add(GarbageTemp, name, filepath.Join(path, "files.compact"))
+		filesDir := GetFilesDir(path)
+		_, err = os.Stat(filesDir)
+		hasFiles := err == nil
+		for _, algorithm := range CompressionAlgorithms {
+			archive := GetArchivePath(path, algorithm)
+			if _, err := os.Stat(archive); err != nil {
+				continue
+			}
+			// Either the archive is incomplete, or a newer one replaces it
+			if !m.Compressed && hasFiles || m.Compressed && algorithm != m.CompressionAlgorithm {
+				add(GarbageLeftover, name, archive)
+			}
+		}
+		// The archive holds it all; the files are a partial extraction
+		if m.Compressed && hasFiles {
+			if _, err := os.Stat(GetArchivePath(path, m.CompressionAlgorithm)); err == nil {
+				add(GarbageLeftover, name, filesDir)
+			}
+		}
+	}
+
+	// Temporary files of writes to the index, objects and snapshots
+	for _, dir := range []string{s.ObjectsDir(), s.SnapshotsDir()} {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() &&
+				(strings.HasSuffix(path, ".tmp") || strings.HasPrefix(info.Name(), ".snapshot-")) {
+				add(GarbageTemp, "", path)
+			}
+			return nil
+		})
+	}
+
+	idx := s.Index()
+	for _, e := range idx.ListEntries() {
+		if _, err := os.Stat(s.checkpointDir(e.ID)); os.IsNotExist(err) {
+			garbage = append(garbage, Garbage{Kind: GarbageIndex, ID: e.ID})
+		}
+	}
+
+	sort.SliceStable(garbage, func(i, j int) bool { return garbage[i].ID < garbage[j].ID })
+	if dryRun {
+		return garbage, nil
+	}
+	for _, g := range garbage {
+		if g.Kind == GarbageIndex {
+			idx.Remove(g.ID)
+			continue
+		}
+		if err := removeTree(g.Path); err != nil {
+			return garbage, err
+		}
+		if (g.Kind == GarbageNoManifest || g.Kind == GarbageIncomplete) && idx.GetEntry(g.ID) != nil {
+			idx.Remove(g.ID)
+		}
+	}
+	return garbage, nil
+}
+
+// lastModified returns when anything under path was last modified, false
+// if there is nothing there
+func lastModified(path string) (time.Time, bool) {
+	var last time.Time
+	found := false
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil {
+			found = true
+			if info.ModTime().After(last) {
+				last = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return last, found
+}
+
+// reclaimable returns the space removing path frees: the size of the
+// files under it, but for those hard-linked elsewhere too
+func reclaimable(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && linkCount(info) <= 1 {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,107 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGC(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	create := func(name string) *Checkpoint {
+		file := filepath.Join(tmpDir, "testdata", name)
+		os.WriteFile(file, []byte(name), 0644)
+		cp, err := store.Create("rm "+name, []string{file})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		return cp
+	}
+	age := func(path string) {
+		old := time.Now().Add(-2 * GCGrace)
+		filepath.Walk(path, func(p string, _ os.FileInfo, err error) error {
+			if err == nil {
+				os.Chtimes(p, old, old)
+			}
+			return nil
+		})
+	}
+
+	intact := create("intact.txt")
+	noManifest := create("no-manifest.txt")
+	os.Remove(filepath.Join(noManifest.Dir, "manifest.json"))
+	age(noManifest.Dir)
+	leftover := create("leftover.txt")
+	archive := GetArchivePath(leftover.Dir, CompressionGzip)
+	os.WriteFile(archive, []byte("partial"), 0644)
+	age(archive)
+	tmp := filepath.Join(store.ObjectsDir(), "ab", "cdef.tmp")
+	os.MkdirAll(filepath.Dir(tmp), 0755)
+	os.WriteFile(tmp, []byte("temp"), 0644)
+	age(tmp)
+	dangling := create("dangling.txt")
+	os.RemoveAll(dangling.Dir)
+
+	// Trash killed part way holds the only copy of what it moved
+	trashed := filepath.Join(store.CheckpointsDir(), "2000-01-01T000000-trashing")
+	os.MkdirAll(GetFilesDir(trashed), 0755)
+	os.WriteFile(filepath.Join(GetFilesDir(trashed), "moved.txt"), []byte("moved"), 0644)
+	markIncomplete(trashed, IncompleteMove)
+	age(trashed)
+
+	// Recent garbage may be a checkpoint being created
+	creating := filepath.Join(store.CheckpointsDir(), "2099-01-01T000000-creating")
+	os.MkdirAll(GetFilesDir(creating), 0755)
+
+	garbage, err := store.GC(true)
+	if err != nil {
+		t.Fatalf("GC dry run failed: %v", err)
+	}
+	found := make(map[string]Garbage)
+	for _, g := range garbage {
+		found[g.Kind+" "+g.ID] = g
+	}
+	for _, key := range []string{
+		GarbageNoManifest + " " + noManifest.ID,
+		GarbageLeftover + " " + leftover.ID,
+		GarbageTemp + " ",
+		GarbageIndex + " " + dangling.ID,
+	} {
+		if _, ok := found[key]; !ok {
+			t.Errorf("Expected garbage %q, got %+v", key, garbage)
+		}
+	}
+	if len(garbage) != 4 {
+		t.Errorf("Expected 4 pieces of garbage, got %+v", garbage)
+	}
+	if g := found[GarbageLeftover+" "+leftover.ID]; g.Path != archive || g.Size != int64(len("partial")) {
+		t.Errorf("Expected the leftover archive and its size, got %+v", g)
+	}
+	if _, err := os.Stat(noManifest.Dir); err != nil {
+		t.Errorf("Expected a dry run to remove nothing, got %v", err)
+	}
+
+	if _, err := store.GC(false); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	for _, path := range []string{noManifest.Dir, archive, tmp} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed, got %v", path, err)
+		}
+	}
+	for _, path := range []string{intact.Dir, GetFilesDir(leftover.Dir), creating, trashed} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s kept, got %v", path, err)
+		}
+	}
+	if store.Index().GetEntry(dangling.ID) != nil {
+		t.Error("Expected the dangling index entry removed")
+	}
+	if left, _ := store.GC(true); len(left) != 0 {
+		t.Errorf("Expected no garbage left, got %+v", left)
+	}
+}
@@ -0,0 +1,173 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// GitState records the tracked files a git_aware checkpoint left out
+// because they had no changes: git has them in the repository at Repo as of
+// Commit, and rollback gets them back from there
+type GitState struct {
+	Repo   string   `json:"repo"`
+	Commit string   `json:"commit"`
+	Files  []string `json:"files"`
+}
+
+// gitClean finds the tracked files with no changes since HEAD, reading the
+// state of each repository once
+type gitClean struct {
+	roots repoRoots
+	repos map[string]*gitRepo // root -> its state, nil if git can't tell
+}
+
+type gitRepo struct {
+	commit  string
+	clean   map[string]bool // tracked files with no changes since commit
+	skipped map[string]bool // those left out of the checkpoint
+}
+
+func newGitClean() *gitClean {
+	return &gitClean{roots: make(repoRoots), repos: make(map[string]*gitRepo)}
+}
+
+// skips reports whether the file at p is tracked and unchanged, so git can
+// give it back, and records it as left out if so
+func (g *gitClean) skips(p string) bool {
+	root := g.roots.find(filepath.Dir(p))
+	if root == "" {
+		return false
+	}
+	repo, ok := g.repos[root]
+	if !ok {
+		repo = loadGitRepo(root)
+		g.repos[root] = repo
+	}
+	if repo == nil || !repo.clean[p] {
+		return false
+	}
+	repo.skipped[p] = true
+	return true
+}
+
+// states returns what was left out of each repository
+func (g *gitClean) states() []GitState {
+	var states []GitState
+	for root, repo := range g.repos {
+		if repo == nil || len(repo.skipped) == 0 {
+			continue
+		}
+		state := GitState{Repo: root, Commit: repo.commit}
+		for p := range repo.skipped {
+			state.Files = append(state.Files, p)
+		}
+		sort.Strings(state.Files)
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Repo < states[j].Repo })
+	return states
+}
+
+// loadGitRepo asks git for the HEAD commit of the repository at root and
+// its tracked files with no changes, staged or not, since then. It returns
+// nil if git can't tell, e.g. it isn't installed or nothing is committed
+// yet, and then everything is backed up.
+func loadGitRepo(root string) *gitRepo {
+	commit, err := runGit(root, nil, "rev-parse", "HEAD")
+	if err != nil {
+		logging.Debug("backing up all tracked files", "repo", root, "error", err)
+		return nil
+	}
+	tracked, err := runGit(root, nil, "ls-files", "-z")
+	if err != nil {
+		logging.Debug("backing up all tracked files", "repo", root, "error", err)
+		return nil
+	}
+	changed, err := runGit(root, nil, "diff", "--name-only", "-z", "HEAD")
+	if err != nil {
+		logging.Debug("backing up all tracked files", "repo", root, "error", err)
+		return nil
+	}
+
+	repo := &gitRepo{
+		commit:  strings.TrimSpace(commit),
+		clean:   make(map[string]bool),
+		skipped: make(map[string]bool),
+	}
+	for _, rel := range strings.Split(tracked, "\x00") {
+		if rel != "" {
+			repo.clean[filepath.Join(root, filepath.FromSlash(rel))] = true
+		}
+	}
+	for _, rel := range strings.Split(changed, "\x00") {
+		if rel != "" {
+			delete(repo.clean, filepath.Join(root, filepath.FromSlash(rel)))
+		}
+	}
+	return repo
+}
+
+// runGit runs git in the repository at root, with stdin if not nil
+func runGit(root string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// GitFiles returns the files left out of the checkpoint because git has
+// them, as recorded in Git
+func (m *Manifest) GitFiles() []string {
+	var files []string
+	for _, state := range m.Git {
+		files = append(files, state.Files...)
+	}
+	return files
+}
+
+// RestoreFromGit writes files, some of GitFiles, back as they were at the
+// commit recorded for them, returning how many it restored. The index is
+// left alone.
+func RestoreFromGit(m *Manifest, files []string) (int, error) {
+	want := make(map[string]bool)
+	for _, f := range files {
+		want[f] = true
+	}
+
+	restored := 0
+	for _, state := range m.Git {
+		var pathspecs []string
+		for _, f := range state.Files {
+			if want[f] {
+				rel, err := filepath.Rel(state.Repo, f)
+				if err != nil {
+					return restored, err
+				}
+				pathspecs = append(pathspecs, filepath.ToSlash(rel))
+			}
+		}
+		if len(pathspecs) == 0 {
+			continue
+		}
+		// The list goes on stdin, as it can be longer than a command line
+		stdin := strings.NewReader(strings.Join(pathspecs, "\x00"))
+		if _, err := runGit(state.Repo, stdin, "restore", "--source="+state.Commit, "--worktree", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+			return restored, err
+		}
+		restored += len(pathspecs)
+	}
+	return restored, nil
+}
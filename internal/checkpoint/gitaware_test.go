@@ -0,0 +1,68 @@
+package checkpoint
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func TestCreateGitAware(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+	config.Get().GitAware = true
+	defer func() { config.Get().GitAware = false }()
+
+	repo := filepath.Join(tmpDir, "testdata", "repo")
+	os.MkdirAll(filepath.Join(repo, "src"), 0755)
+	os.WriteFile(filepath.Join(repo, "src", "clean.go"), []byte("clean"), 0644)
+	os.WriteFile(filepath.Join(repo, "dirty.go"), []byte("dirty"), 0644)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	os.WriteFile(filepath.Join(repo, "dirty.go"), []byte("changed"), 0644)
+	os.WriteFile(filepath.Join(repo, "new.go"), []byte("new"), 0644)
+
+	cp, err := store.Create("rm -rf repo", []string{repo})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	clean := filepath.Join(repo, "src", "clean.go")
+	for _, name := range []string{"dirty.go", "new.go"} {
+		if _, err := FindFile(cp, filepath.Join(repo, name)); err != nil {
+			t.Errorf("%s should be backed up: %v", name, err)
+		}
+	}
+	if _, err := FindFile(cp, clean); err == nil {
+		t.Error("clean.go should be left to git")
+	}
+	if len(cp.Manifest.Git) != 1 || cp.Manifest.Git[0].Commit == "" || !slices.Equal(cp.Manifest.GitFiles(), []string{clean}) {
+		t.Fatalf("Expected clean.go recorded with the commit, got %+v", cp.Manifest.Git)
+	}
+
+	selected, _ := SelectFiles(cp, []string{"src/"}, repo)
+	if !slices.Equal(selected, []string{clean}) {
+		t.Errorf("Expected src/ to select clean.go, got %v", selected)
+	}
+
+	os.RemoveAll(filepath.Join(repo, "src"))
+	if n, err := RestoreFromGit(cp.Manifest, []string{clean}); err != nil || n != 1 {
+		t.Fatalf("RestoreFromGit() = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(clean); string(data) != "clean" {
+		t.Errorf("Expected clean.go back from git, got %q", data)
+	}
+}
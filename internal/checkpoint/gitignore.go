@@ -0,0 +1,190 @@
+package checkpoint
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a line of a .gitignore
+type ignoreRule struct {
+	base     string   // directory of the .gitignore; the rule is relative to it
+	pattern  []string // slash-separated segments
+	negate   bool     // !pattern: not ignored after all
+	dirOnly  bool     // pattern/: only matches directories
+	anchored bool     // pattern with a slash: matched from base, not by name
+}
+
+// gitIgnores answers whether git ignores a path, reading the .gitignore
+// files of each repository, and .git/info/exclude, as it goes
+type gitIgnores struct {
+	roots repoRoots
+	rules map[string][]ignoreRule // ignore file -> its rules
+	dirs  map[string]bool         // directory -> whether it is ignored
+}
+
+func newGitIgnores() *gitIgnores {
+	return &gitIgnores{
+		roots: make(repoRoots),
+		rules: make(map[string][]ignoreRule),
+		dirs:  make(map[string]bool),
+	}
+}
+
+// ignores reports whether p, a directory if isDir, is ignored by the
+// repository it is in, or is in an ignored directory. Paths outside any
+// repository are never ignored.
+func (g *gitIgnores) ignores(p string, isDir bool) bool {
+	p = filepath.Clean(p)
+	root := g.roots.find(filepath.Dir(p))
+	if root == "" || p == root {
+		return false
+	}
+	for dir := filepath.Dir(p); dir != root; dir = filepath.Dir(dir) {
+		if g.dirIgnored(root, dir) {
+			return true
+		}
+	}
+	return g.match(root, p, isDir)
+}
+
+// dirIgnored is match for a directory, remembering the answer
+func (g *gitIgnores) dirIgnored(root, dir string) bool {
+	ignored, ok := g.dirs[dir]
+	if !ok {
+		ignored = g.match(root, dir, true)
+		g.dirs[dir] = ignored
+	}
+	return ignored
+}
+
+// match applies the rules of root's .git/info/exclude and of the
+// .gitignore files from root down to p's directory, the last matching rule
+// winning like in git
+func (g *gitIgnores) match(root, p string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == root {
+			break
+		}
+	}
+	rules := g.load(filepath.Join(root, ".git", "info", "exclude"), root)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules = append(rules, g.load(filepath.Join(dirs[i], ".gitignore"), dirs[i])...)
+	}
+
+	ignored := false
+	for _, r := range rules {
+		if r.matches(p, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// load returns the rules in file, which apply under base
+func (g *gitIgnores) load(file, base string) []ignoreRule {
+	if rules, ok := g.rules[file]; ok {
+		return rules
+	}
+	rules := parseGitIgnore(file, base)
+	g.rules[file] = rules
+	return rules
+}
+
+// repoRoots maps directories to the git repository they are in
+type repoRoots map[string]string
+
+// find returns the repository dir is in: the nearest directory up holding
+// .git, or "" if there is none
+func (r repoRoots) find(dir string) string {
+	if root, ok := r[dir]; ok {
+		return root
+	}
+	root := ""
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = r.find(parent)
+	}
+	r[dir] = root
+	return root
+}
+
+// parseGitIgnore reads the rules of a .gitignore file. A missing or
+// unreadable file has none.
+func parseGitIgnore(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: base}
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			r.negate, line = true, rest
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			r.dirOnly, line = true, rest
+		}
+		r.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		r.pattern = strings.Split(line, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// matches reports whether the rule applies to p itself
+func (r ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	if !r.anchored {
+		matched, _ := path.Match(r.pattern[0], filepath.Base(p))
+		return matched
+	}
+	return matchWhole(r.pattern, strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// matchWhole is matchSegments, but only matches if the pattern covers every
+// segment: a directory's pattern does not match the files under it
+func matchWhole(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(segments); i >= 0; i-- {
+				if matchWhole(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
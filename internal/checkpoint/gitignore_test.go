@@ -0,0 +1,81 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func TestGitIgnores(t *testing.T) {
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git", "info"), 0755)
+	os.MkdirAll(filepath.Join(repo, "src", "gen"), 0755)
+	os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("# build output\n*.log\n!keep.log\nbuild/\n/dist\ndocs/**/*.html\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "src", ".gitignore"), []byte("gen/\n"), 0644)
+	os.WriteFile(filepath.Join(repo, ".git", "info", "exclude"), []byte("*.swp\n"), 0644)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"debug.log", false, true},
+		{"src/debug.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build/x.o", false, true},
+		{"dist", true, true},
+		{"src/dist", true, false},
+		{"docs/a/b/page.html", false, true},
+		{"page.html", false, false},
+		{"src/gen/x.go", false, true},
+		{"gen/x.go", false, false},
+		{"main.go.swp", false, true},
+		{"main.go", false, false},
+	}
+	g := newGitIgnores()
+	for _, tt := range tests {
+		if got := g.ignores(filepath.Join(repo, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("ignores(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if g.ignores(filepath.Join(t.TempDir(), "debug.log"), false) {
+		t.Error("Paths outside a repository should never be ignored")
+	}
+}
+
+func TestCreateRespectsGitignore(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+	config.Get().RespectGitignore = true
+	defer func() { config.Get().RespectGitignore = false }()
+
+	repo := filepath.Join(tmpDir, "testdata", "repo")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	os.MkdirAll(filepath.Join(repo, "bin"), 0755)
+	os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("bin/\n*.log\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("main"), 0644)
+	os.WriteFile(filepath.Join(repo, "debug.log"), []byte("log"), 0644)
+	os.WriteFile(filepath.Join(repo, "bin", "app"), []byte("app"), 0644)
+
+	cp, err := store.Create("rm -rf repo", []string{repo})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if _, err := FindFile(cp, filepath.Join(repo, "main.go")); err != nil {
+		t.Errorf("main.go should be backed up: %v", err)
+	}
+	for _, name := range []string{"debug.log", "bin/app"} {
+		if _, err := FindFile(cp, filepath.Join(repo, name)); err == nil {
+			t.Errorf("%s should not be backed up", name)
+		}
+		if _, err := os.Stat(filepath.Join(cp.FilesDir, repo, name)); err == nil {
+			t.Errorf("%s should not be copied", name)
+		}
+	}
+}
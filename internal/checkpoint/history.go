@@ -0,0 +1,84 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Version is one backed-up copy of a file
+type Version struct {
+	Number     int // 1 for the oldest
+	Checkpoint *Checkpoint
+	Entry      FileEntry
+	Hash       string // SHA-256 of the backed-up content, empty if unreadable
+}
+
+// History returns every backed-up version of the file at path, oldest
+// first. The path index narrows down which manifests are loaded.
+func (s *Store) History(path string) ([]Version, error) {
+	path = filepath.Clean(path)
+	idx := s.Index()
+	paths, err := idx.FilePaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read path index: %w", err)
+	}
+
+	var versions []Version
+	for _, e := range idx.ListEntries() {
+		if !containsPath(paths[e.ID], path) {
+			continue
+		}
+		cp, err := s.Get(e.ID)
+		if err != nil {
+			continue // Deleted since the index was loaded
+		}
+		for _, f := range cp.Manifest.Files {
+			if f.IsDir || f.OriginalPath != path {
+				continue
+			}
+			versions = append(versions, Version{
+				Checkpoint: cp,
+				Entry:      f,
+				Hash:       versionHash(cp, f),
+			})
+			break
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Checkpoint.Manifest.Timestamp.Before(versions[j].Checkpoint.Manifest.Timestamp)
+	})
+	for i := range versions {
+		versions[i].Number = i + 1
+	}
+	return versions, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// versionHash returns the content hash of a backed-up file
+func versionHash(cp *Checkpoint, f FileEntry) string {
+	if f.Hash != "" {
+		return f.Hash
+	}
+	h := sha256.New()
+	if err := StreamFile(cp, f, h); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// HashFile returns the SHA-256 of a file's content, comparable with
+// Version.Hash
+func HashFile(path string) (string, error) {
+	return contentHash(path)
+}
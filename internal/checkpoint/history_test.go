@@ -0,0 +1,70 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "testdata", "main.go")
+	other := filepath.Join(tmpDir, "testdata", "other.go")
+	os.WriteFile(other, []byte("other"), 0644)
+
+	var ids []string
+	for _, content := range []string{"v1", "version 2", "v3"} {
+		// Replace rather than rewrite, so earlier hard-linked backups keep their content
+		os.Remove(file)
+		os.WriteFile(file, []byte(content), 0644)
+		cp, err := Create("edit main.go", []string{file})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		ids = append(ids, cp.ID)
+	}
+	if _, err := Create("edit other.go", []string{other}); err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// A compressed version is hashed from the archive
+	if _, _, err := Compress(ids[1]); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	versions, err := History(file)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d", len(versions))
+	}
+
+	current, err := HashFile(file)
+	if err != nil {
+		t.Fatalf("HashFile failed: %v", err)
+	}
+	for i, v := range versions {
+		if v.Number != i+1 || v.Checkpoint.ID != ids[i] {
+			t.Errorf("Version %d: expected checkpoint %s, got #%d %s", i+1, ids[i], v.Number, v.Checkpoint.ID)
+		}
+		if v.Hash == "" {
+			t.Errorf("Version %d has no hash", i+1)
+		}
+	}
+	if versions[1].Entry.Size != int64(len("version 2")) {
+		t.Errorf("Expected version 2 size %d, got %d", len("version 2"), versions[1].Entry.Size)
+	}
+	if versions[0].Hash == versions[1].Hash || versions[1].Hash == versions[2].Hash {
+		t.Error("Expected each version to have a different hash")
+	}
+	if versions[2].Hash != current {
+		t.Error("Expected the latest version to match the current file")
+	}
+
+	if versions, _ := History(filepath.Join(tmpDir, "testdata", "missing.go")); len(versions) != 0 {
+		t.Errorf("Expected no versions of a file never checkpointed, got %d", len(versions))
+	}
+}
@@ -0,0 +1,51 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrIncomplete is the error loading the manifest of a checkpoint whose
+// creation didn't finish, which may be missing backups
+var ErrIncomplete = errors.New("checkpoint creation did not finish")
+
+// How an incomplete checkpoint was being created
+const (
+	IncompleteCopy = "copy" // its backups are copies, so removing it loses nothing
+	IncompleteMove = "move" // Trash moved the originals into it
+)
+
+// incompleteMarker is the file in a checkpoint directory while the
+// checkpoint is being created. It holds IncompleteCopy or IncompleteMove.
+const incompleteMarker = ".incomplete"
+
+// markIncomplete marks the checkpoint in dir as being created
+func markIncomplete(dir, how string) error {
+	return os.WriteFile(filepath.Join(dir, incompleteMarker), []byte(how+"\n"), 0644)
+}
+
+// markComplete marks the checkpoint in dir as created, once its manifest
+// is saved
+func markComplete(dir string) error {
+	err := os.Remove(filepath.Join(dir, incompleteMarker))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Incomplete returns how the checkpoint in dir was being created if it
+// hasn't been yet, either because it is being created right now or because
+// safeshell was killed while creating it, or "" if it has
+func Incomplete(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, incompleteMarker))
+	if err != nil {
+		return ""
+	}
+	if how := strings.TrimSpace(string(data)); how == IncompleteMove {
+		return how
+	}
+	return IncompleteCopy
+}
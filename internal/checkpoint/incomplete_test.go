@@ -0,0 +1,106 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIncomplete(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	create := func(name string) *Checkpoint {
+		file := filepath.Join(tmpDir, "testdata", name)
+		os.WriteFile(file, []byte(name), 0644)
+		cp, err := store.Create("cp "+name, []string{file})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		return cp
+	}
+	old := time.Now().Add(-2 * GCGrace)
+	age := func(path string) {
+		filepath.Walk(path, func(p string, _ os.FileInfo, err error) error {
+			if err == nil {
+				os.Chtimes(p, old, old)
+			}
+			return nil
+		})
+	}
+
+	complete := create("complete.txt")
+	if how := Incomplete(complete.Dir); how != "" {
+		t.Fatalf("Expected a created checkpoint to be complete, got %q", how)
+	}
+
+	// Killed while copying: the manifest was never saved
+	copying := create("copying.txt")
+	store.Index().Remove(copying.ID)
+	os.Remove(filepath.Join(copying.Dir, "manifest.json"))
+	markIncomplete(copying.Dir, IncompleteCopy)
+	age(copying.Dir)
+
+	// Killed after saving the manifest
+	saved := create("saved.txt")
+	store.Index().Remove(saved.ID)
+	markIncomplete(saved.Dir, IncompleteCopy)
+
+	// Killed while moving targets into the checkpoint
+	moved := filepath.Join(tmpDir, "testdata", "moved.txt")
+	os.WriteFile(moved, []byte("moved"), 0644)
+	moving, err := store.Trash("rm moved.txt", []string{moved})
+	if err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	store.Index().Remove(moving.ID)
+	os.Remove(filepath.Join(moving.Dir, "manifest.json"))
+	markIncomplete(moving.Dir, IncompleteMove)
+	age(moving.Dir)
+
+	if _, err := LoadManifest(saved.Dir); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected an incomplete checkpoint not to be found, got %v", err)
+	}
+	store.Index().Rebuild()
+	if latest, err := store.GetLatest(); err != nil || latest.ID != complete.ID {
+		t.Errorf("Expected the latest checkpoint to be the complete one, got %v", err)
+	}
+	if checkpoints, _ := store.List(); len(checkpoints) != 1 {
+		t.Errorf("Expected only the complete checkpoint listed, got %d", len(checkpoints))
+	}
+
+	garbage, err := store.GC(false)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(garbage) != 1 || garbage[0].Kind != GarbageIncomplete || garbage[0].ID != copying.ID {
+		t.Errorf("Expected GC to remove only the partial copy, got %+v", garbage)
+	}
+
+	problems, err := store.Fsck(true)
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	incomplete := make(map[string]bool)
+	for _, p := range problems {
+		if p.Kind == FsckIncomplete {
+			incomplete[p.ID] = true
+		}
+	}
+	if len(incomplete) != 2 || !incomplete[saved.ID] || !incomplete[moving.ID] {
+		t.Errorf("Expected Fsck to repair the saved and moving checkpoints, got %+v", problems)
+	}
+	if cp, err := store.Get(saved.ID); err != nil || cp.Manifest.Command != "cp saved.txt" {
+		t.Errorf("Expected the saved manifest kept, got %v", err)
+	}
+	cp, err := store.Get(moving.ID)
+	if err != nil || len(cp.Manifest.Files) != 1 || cp.Manifest.Files[0].OriginalPath != moved {
+		t.Errorf("Expected the moved file recorded in a rebuilt manifest, got %v", err)
+	}
+	if store.Index().GetEntry(saved.ID) == nil || store.Index().GetEntry(moving.ID) == nil {
+		t.Error("Expected the repaired checkpoints indexed")
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"sort"
 	"sync"
 	"time"
-
-	"github.com/qhkm/safeshell/internal/config"
 )
 
 // IndexEntry contains lightweight checkpoint metadata for fast lookups
@@ -20,52 +18,54 @@ type IndexEntry struct {
 	FileCount      int       `json:"file_count"`
 	TotalSize      int64     `json:"total_size"`
 	SessionID      string    `json:"session_id,omitempty"`
+	Name           string    `json:"name,omitempty"`
+	Pinned         bool      `json:"pinned,omitempty"`
 	Tags           []string  `json:"tags,omitempty"`
 	RolledBack     bool      `json:"rolled_back"`
 	Compressed     bool      `json:"compressed,omitempty"`
 	CompressedSize int64     `json:"compressed_size,omitempty"`
 }
 
+// DiskSize is the space the checkpoint's backups take, like
+// Manifest.DiskSize, from the sizes recorded in the index
+func (e *IndexEntry) DiskSize() int64 {
+	if e.Compressed {
+		return e.CompressedSize
+	}
+	return e.TotalSize
+}
+
 // Index provides fast checkpoint lookups without loading full manifests
 type Index struct {
 	Entries      map[string]*IndexEntry `json:"entries"`
 	NextSequence int64                  `json:"next_sequence"` // Monotonic counter for ordering
 	UpdatedAt    time.Time              `json:"updated_at"`
 	mu           sync.RWMutex
+	dir          string    // checkpoints directory the index describes
+	fileStamp    fileStamp // index file as last read or written
 }
 
-var (
-	globalIndex     *Index
-	globalIndexOnce sync.Once
-	globalIndexMu   sync.Mutex
-)
-
-// GetIndex returns the global checkpoint index, loading or rebuilding as needed
-func GetIndex() *Index {
-	globalIndexMu.Lock()
-	defer globalIndexMu.Unlock()
-
-	globalIndexOnce.Do(func() {
-		globalIndex = &Index{
-			Entries: make(map[string]*IndexEntry),
-		}
-		globalIndex.Load()
-	})
-	return globalIndex
+func newIndex(dir string) *Index {
+	return &Index{Entries: make(map[string]*IndexEntry), dir: dir}
 }
 
-// ResetIndex resets the global index (for testing)
-func ResetIndex() {
-	globalIndexMu.Lock()
-	defer globalIndexMu.Unlock()
+// fileStamp identifies a version of the index file on disk
+type fileStamp struct {
+	modTime int64 // nanoseconds
+	size    int64
+}
 
-	globalIndexOnce = sync.Once{}
-	globalIndex = nil
+func (idx *Index) stat() fileStamp {
+	info, err := os.Stat(idx.path())
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime().UnixNano(), info.Size()}
 }
 
-// indexPath returns the path to the index file
-func indexPath() string {
-	return filepath.Join(config.GetCheckpointsDir(), ".index.json")
+// path returns the path to the index file
+func (idx *Index) path() string {
+	return filepath.Join(idx.dir, ".index.json")
 }
 
 // Load reads the index from disk
@@ -73,7 +73,7 @@ func (idx *Index) Load() error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	data, err := os.ReadFile(indexPath())
+	data, err := os.ReadFile(idx.path())
 	if err != nil {
 		if os.IsNotExist(err) {
 			// No index yet, rebuild it
@@ -82,10 +82,12 @@ func (idx *Index) Load() error {
 		return err
 	}
 
+	idx.Entries = make(map[string]*IndexEntry)
 	if err := json.Unmarshal(data, idx); err != nil {
 		// Corrupted index, rebuild
 		return idx.rebuildLocked()
 	}
+	idx.fileStamp = idx.stat()
 
 	// Check if index is stale (compare with directory)
 	if idx.isStale() {
@@ -97,8 +99,7 @@ func (idx *Index) Load() error {
 
 // isStale checks if the index needs rebuilding
 func (idx *Index) isStale() bool {
-	checkpointsDir := config.GetCheckpointsDir()
-	entries, err := os.ReadDir(checkpointsDir)
+	entries, err := os.ReadDir(idx.dir)
 	if err != nil {
 		return true
 	}
@@ -119,8 +120,7 @@ func (idx *Index) rebuildLocked() error {
 	idx.Entries = make(map[string]*IndexEntry)
 	idx.NextSequence = 0
 
-	checkpointsDir := config.GetCheckpointsDir()
-	entries, err := os.ReadDir(checkpointsDir)
+	entries, err := os.ReadDir(idx.dir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -130,27 +130,22 @@ func (idx *Index) rebuildLocked() error {
 
 	// Collect all entries first
 	var tempEntries []*IndexEntry
+	paths := make(map[string][]string)
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
 
 		id := entry.Name()
-		checkpointDir := filepath.Join(checkpointsDir, id)
+		checkpointDir := filepath.Join(idx.dir, id)
 		manifest, err := LoadManifest(checkpointDir)
 		if err != nil {
 			continue // Skip invalid checkpoints
 		}
 
 		// Count files and total size
-		fileCount := 0
-		var totalSize int64
-		for _, f := range manifest.Files {
-			if !f.IsDir {
-				fileCount++
-				totalSize += f.Size
-			}
-		}
+		fileCount, totalSize := countFiles(manifest)
+		paths[id] = manifestPaths(manifest)
 
 		tempEntries = append(tempEntries, &IndexEntry{
 			ID:             id,
@@ -159,6 +154,8 @@ func (idx *Index) rebuildLocked() error {
 			FileCount:      fileCount,
 			TotalSize:      totalSize,
 			SessionID:      manifest.SessionID,
+			Name:           manifest.Name,
+			Pinned:         manifest.Pinned,
 			Tags:           manifest.Tags,
 			RolledBack:     manifest.RolledBack,
 			Compressed:     manifest.Compressed,
@@ -182,6 +179,7 @@ func (idx *Index) rebuildLocked() error {
 	}
 
 	idx.UpdatedAt = time.Now()
+	writePaths(idx.dir, paths) // FilePaths recovers from a missing path index
 	return idx.saveLocked()
 }
 
@@ -193,11 +191,27 @@ func (idx *Index) saveLocked() error {
 	}
 
 	// Ensure checkpoints directory exists
-	if err := os.MkdirAll(config.GetCheckpointsDir(), 0755); err != nil {
+	if err := os.MkdirAll(idx.dir, 0755); err != nil {
 		return err
 	}
 
-	return os.WriteFile(indexPath(), data, 0644)
+	if err := os.WriteFile(idx.path(), data, 0644); err != nil {
+		return err
+	}
+	idx.fileStamp = idx.stat()
+	return nil
+}
+
+// Reload re-reads the index if another process has written it since it was
+// last read or written. Long-lived processes call it before using the index.
+func (idx *Index) Reload() error {
+	idx.mu.RLock()
+	current := idx.fileStamp == idx.stat()
+	idx.mu.RUnlock()
+	if current {
+		return nil
+	}
+	return idx.Load()
 }
 
 // Save saves the index to disk
@@ -212,13 +226,11 @@ func (idx *Index) Add(cp *Checkpoint) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	fileCount := 0
-	var totalSize int64
-	for _, f := range cp.Manifest.Files {
-		if !f.IsDir {
-			fileCount++
-			totalSize += f.Size
-		}
+	fileCount, totalSize := countFiles(cp.Manifest)
+
+	// Files never change after creation, so only new checkpoints are recorded
+	if _, exists := idx.Entries[cp.ID]; !exists {
+		appendPaths(idx.dir, pathRecord{ID: cp.ID, Paths: manifestPaths(cp.Manifest)})
 	}
 
 	// Assign monotonic sequence number for proper ordering
@@ -233,6 +245,8 @@ func (idx *Index) Add(cp *Checkpoint) {
 		FileCount:      fileCount,
 		TotalSize:      totalSize,
 		SessionID:      cp.Manifest.SessionID,
+		Name:           cp.Manifest.Name,
+		Pinned:         cp.Manifest.Pinned,
 		Tags:           cp.Manifest.Tags,
 		RolledBack:     cp.Manifest.RolledBack,
 		Compressed:     cp.Manifest.Compressed,
@@ -1,6 +1,8 @@
 package checkpoint
 
 import (
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 	"time"
@@ -57,6 +59,34 @@ func TestIndexEntrySortingWithTiebreaker(t *testing.T) {
 	}
 }
 
+func TestIndexDiskSize(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	docs := filepath.Join(tmpDir, "testdata", "docs")
+	os.MkdirAll(docs, 0755)
+	os.WriteFile(filepath.Join(docs, "a.txt"), []byte("some text"), 0644)
+	os.WriteFile(filepath.Join(docs, "b.txt"), []byte("more"), 0644)
+	cp, err := Create("rm -r docs", []string{docs})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// What the backups take, without walking them
+	walked, _ := GetDiskUsage(cp.FilesDir)
+	if got := GetIndex().GetEntry(cp.ID).DiskSize(); got != walked || cp.Manifest.DiskSize() != walked {
+		t.Errorf("Expected %d bytes, got %d in the index and %d in the manifest", walked, got, cp.Manifest.DiskSize())
+	}
+
+	_, compressed, err := Compress(cp.ID)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if got := GetIndex().GetEntry(cp.ID).DiskSize(); got != compressed {
+		t.Errorf("Expected the archive's %d bytes once compressed, got %d", compressed, got)
+	}
+}
+
 // Benchmarks to show sort.Slice performance vs bubble sort
 
 func generateTestEntries(n int) []*IndexEntry {
@@ -0,0 +1,22 @@
+package checkpoint
+
+import "os"
+
+// linkCount returns the number of hard links to a file, or 0 if unknown.
+// os.FileInfo does not expose it on Windows, so files are never assumed to
+// be exclusively ours.
+func linkCount(info os.FileInfo) uint64 {
+	return 0
+}
+
+// device returns the device a file is on, or 0 if unknown, as it always
+// is on Windows
+func device(info os.FileInfo) uint64 {
+	return 0
+}
+
+// inode returns the inode number of a file, or 0 if unknown, as it always
+// is on Windows
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
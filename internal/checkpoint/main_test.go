@@ -0,0 +1,27 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// TestMain points HOME at a temporary directory before any test runs: those
+// not calling setupTestEnv still load config, and would otherwise create it
+// and checkpoints in the real ~/.safeshell, or clean real ones.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "safeshell-test-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create temp dir: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("USERPROFILE", home)
+	config.Reset()
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
@@ -13,6 +13,12 @@ type FileEntry struct {
 	Mode         os.FileMode `json:"mode"`
 	Size         int64       `json:"size"`
 	IsDir        bool        `json:"is_dir"`
+	Hash         string      `json:"hash,omitempty"` // SHA-256 of the content, from format 2
+
+	// Times of the file when backed up, in Unix nanoseconds. 0 in older
+	// checkpoints and for directories.
+	ModTime    int64 `json:"mtime,omitempty"`
+	AccessTime int64 `json:"atime,omitempty"`
 }
 
 type Manifest struct {
@@ -23,11 +29,56 @@ type Manifest struct {
 	WorkingDir     string      `json:"working_dir"`
 	Files          []FileEntry `json:"files"`
 	RolledBack     bool        `json:"rolled_back"`
+	Name           string      `json:"name,omitempty"`   // Unique, set with CreateNamed or SetName
+	Pinned         bool        `json:"pinned,omitempty"` // Never removed by clean or retention
 	Tags           []string    `json:"tags,omitempty"`
 	Note           string      `json:"note,omitempty"`
 	Compressed     bool        `json:"compressed,omitempty"`
 	CompressedSize int64       `json:"compressed_size,omitempty"`
 	CompressedAt   time.Time   `json:"compressed_at,omitempty"`
+
+	// CompressionAlgorithm is the algorithm used for the archive.
+	// Empty for archives created before it was configurable (gzip).
+	CompressionAlgorithm string `json:"compression_algorithm,omitempty"`
+
+	// FormatVersion is the storage format; see StoreFormat. Zero for
+	// checkpoints that have not been compacted.
+	FormatVersion int `json:"format_version,omitempty"`
+
+	// ParentModes holds the modes of the directories above each target, so
+	// rollback can recreate missing parents as they were
+	ParentModes map[string]os.FileMode `json:"parent_modes,omitempty"`
+
+	// UndoOf is set on the checkpoint taken automatically before a
+	// rollback: the ID of the checkpoint rolled back. Recreated lists the
+	// files that rollback restored where nothing existed, which rolling
+	// this checkpoint back removes again.
+	UndoOf    string   `json:"undo_of,omitempty"`
+	Recreated []string `json:"recreated,omitempty"`
+
+	// Include holds the patterns the checkpoint was limited to: only files
+	// matching one of them were backed up
+	Include []string `json:"include,omitempty"`
+
+	// Git lists the tracked files git_aware left out as unchanged, for
+	// rollback to get back from git
+	Git []GitState `json:"git,omitempty"`
+
+	// Placeholders are cloud files that were skipped because their content
+	// was not on disk; the cloud service still holds it
+	Placeholders []string `json:"placeholders,omitempty"`
+
+	// Trashed lists the targets Trash moved into the checkpoint whole
+	// instead of copying, for rollback to rename back
+	Trashed []string `json:"trashed,omitempty"`
+
+	// FSSnapshot is the filesystem snapshot the backups are in, if the
+	// checkpoint took one instead of copying files
+	FSSnapshot *FSSnapshot `json:"fs_snapshot,omitempty"`
+
+	// Outcome is what the wrapped command did to the files, recorded once
+	// it ran
+	Outcome *Outcome `json:"outcome,omitempty"`
 }
 
 func NewManifest(id, command, workingDir string) *Manifest {
@@ -41,7 +92,7 @@ func NewManifest(id, command, workingDir string) *Manifest {
 	}
 }
 
-func (m *Manifest) AddFile(originalPath, backupPath string, mode os.FileMode, size int64, isDir bool) {
+func (m *Manifest) AddFile(originalPath, backupPath string, mode os.FileMode, size int64, isDir bool) *FileEntry {
 	m.Files = append(m.Files, FileEntry{
 		OriginalPath: originalPath,
 		BackupPath:   backupPath,
@@ -49,6 +100,39 @@ func (m *Manifest) AddFile(originalPath, backupPath string, mode os.FileMode, si
 		Size:         size,
 		IsDir:        isDir,
 	})
+	return &m.Files[len(m.Files)-1]
+}
+
+// addParents records the modes of the directories above path, up to the root
+func (m *Manifest) addParents(path string) {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, ok := m.ParentModes[dir]; ok {
+			// Its parents were recorded with it
+			return
+		}
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return
+		}
+		if m.ParentModes == nil {
+			m.ParentModes = make(map[string]os.FileMode)
+		}
+		m.ParentModes[dir] = info.Mode()
+		if filepath.Dir(dir) == dir {
+			return
+		}
+	}
+}
+
+// DiskSize is the space the checkpoint's backups take: the size of its
+// archive if it is compressed, and otherwise of the files backed up. It
+// comes from the manifest, without walking the backups.
+func (m *Manifest) DiskSize() int64 {
+	if m.Compressed {
+		return m.CompressedSize
+	}
+	_, size := countFiles(m)
+	return size
 }
 
 func (m *Manifest) Save(checkpointDir string) error {
@@ -60,7 +144,17 @@ func (m *Manifest) Save(checkpointDir string) error {
 	return os.WriteFile(manifestPath, data, 0644)
 }
 
+// LoadManifest reads the manifest of the checkpoint in checkpointDir. It
+// fails with ErrIncomplete if the checkpoint isn't created yet.
 func LoadManifest(checkpointDir string) (*Manifest, error) {
+	if Incomplete(checkpointDir) != "" {
+		return nil, ErrIncomplete
+	}
+	return readManifest(checkpointDir)
+}
+
+// readManifest is LoadManifest for checkpoints created or not
+func readManifest(checkpointDir string) (*Manifest, error) {
 	manifestPath := filepath.Join(checkpointDir, "manifest.json")
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
@@ -0,0 +1,65 @@
+package checkpoint
+
+import (
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// macOS keeps Finder flags (com.apple.FinderInfo), tags
+// (com.apple.metadata:_kMDItemUserTags), resource forks
+// (com.apple.ResourceFork) and quarantine state (com.apple.quarantine) in
+// extended attributes. Hard-linked backups share them with the original;
+// copies and archives only keep them when preserve_macos_metadata is set.
+
+// macOSMetadataPrefix selects the extended attributes that are preserved
+const macOSMetadataPrefix = "com.apple."
+
+// paxXattrPrefix is how tar stores extended attributes in PAX records
+const paxXattrPrefix = "SCHILY.xattr."
+
+func preserveMetadata() bool {
+	return config.Get().PreserveMacOSMetadata
+}
+
+// copyMetadata copies the macOS metadata of src to dst
+func copyMetadata(src, dst string) error {
+	if !preserveMetadata() {
+		return nil
+	}
+	attrs, err := readMetadata(src)
+	if err != nil {
+		return err
+	}
+	return writeMetadata(dst, attrs)
+}
+
+// metadataRecords returns the macOS metadata of path as tar PAX records
+func metadataRecords(path string) (map[string]string, error) {
+	if !preserveMetadata() {
+		return nil, nil
+	}
+	attrs, err := readMetadata(path)
+	if err != nil || len(attrs) == 0 {
+		return nil, err
+	}
+	records := make(map[string]string, len(attrs))
+	for name, value := range attrs {
+		records[paxXattrPrefix+name] = string(value)
+	}
+	return records, nil
+}
+
+// applyMetadataRecords restores the macOS metadata held in tar PAX records
+func applyMetadataRecords(path string, records map[string]string) error {
+	attrs := make(map[string][]byte)
+	for key, value := range records {
+		if name, ok := strings.CutPrefix(key, paxXattrPrefix); ok && strings.HasPrefix(name, macOSMetadataPrefix) {
+			attrs[name] = []byte(value)
+		}
+	}
+	if len(attrs) == 0 || !preserveMetadata() {
+		return nil
+	}
+	return writeMetadata(path, attrs)
+}
@@ -0,0 +1,86 @@
+package checkpoint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readMetadata returns the com.apple.* extended attributes of path
+func readMetadata(path string) (map[string][]byte, error) {
+	names, err := listXattrs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, name := range names {
+		if !strings.HasPrefix(name, macOSMetadataPrefix) {
+			continue
+		}
+		value, err := getXattr(path, name)
+		if errors.Is(err, unix.ENOATTR) {
+			continue // Removed since it was listed
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of %s: %w", name, path, err)
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// writeMetadata sets extended attributes on path
+func writeMetadata(path string, attrs map[string][]byte) error {
+	for name, value := range attrs {
+		if err := unix.Setxattr(path, name, value, 0); err != nil {
+			return fmt.Errorf("failed to set %s on %s: %w", name, path, err)
+		}
+	}
+	return nil
+}
+
+func listXattrs(path string) ([]string, error) {
+	for {
+		size, err := unix.Listxattr(path, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Listxattr(path, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue // Grew between the calls
+		}
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,76 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+const testTagsAttr = "com.apple.metadata:_kMDItemUserTags"
+
+func checkMetadata(t *testing.T, path string, want []byte) {
+	t.Helper()
+	attrs, err := readMetadata(path)
+	if err != nil {
+		t.Fatalf("readMetadata failed: %v", err)
+	}
+	if string(attrs[testTagsAttr]) != string(want) {
+		t.Errorf("%s: expected tags %q, got %q", path, want, attrs[testTagsAttr])
+	}
+	if _, ok := attrs["org.example.note"]; ok {
+		t.Errorf("%s: only com.apple.* attributes should be read", path)
+	}
+}
+
+func TestMacOSMetadataPreserved(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	config.Get().PreserveMacOSMetadata = true
+	defer func() { config.Get().PreserveMacOSMetadata = false }()
+
+	srcDir := filepath.Join(tmpDir, "testdata", "src")
+	os.MkdirAll(srcDir, 0755)
+	src := filepath.Join(srcDir, "tagged.txt")
+	os.WriteFile(src, []byte("tagged"), 0644)
+	tags := []byte("bplist00\xa1\x01URed\n6")
+	if err := unix.Setxattr(src, testTagsAttr, tags, 0); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+	unix.Setxattr(src, "org.example.note", []byte("not ours"), 0)
+
+	dst := filepath.Join(tmpDir, "testdata", "copy.txt")
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile failed: %v", err)
+	}
+	checkMetadata(t, dst, tags)
+
+	archive := GetArchivePath(tmpDir, CompressionZstd)
+	if _, err := CompressDir(srcDir, archive, CompressionZstd, 0); err != nil {
+		t.Fatalf("CompressDir failed: %v", err)
+	}
+	outDir := filepath.Join(tmpDir, "testdata", "out")
+	if err := DecompressDir(archive, outDir, CompressionZstd); err != nil {
+		t.Fatalf("DecompressDir failed: %v", err)
+	}
+	checkMetadata(t, filepath.Join(outDir, "tagged.txt"), tags)
+}
+
+func TestMacOSMetadataOffByDefault(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	src := filepath.Join(tmpDir, "testdata", "tagged.txt")
+	os.WriteFile(src, []byte("tagged"), 0644)
+	if err := unix.Setxattr(src, testTagsAttr, []byte("tags"), 0); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+
+	dst := filepath.Join(tmpDir, "testdata", "copy.txt")
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile failed: %v", err)
+	}
+	checkMetadata(t, dst, nil)
+}
@@ -0,0 +1,13 @@
+//go:build !darwin
+
+package checkpoint
+
+// readMetadata returns the macOS metadata of path. Other platforms have
+// none.
+func readMetadata(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func writeMetadata(path string, attrs map[string][]byte) error {
+	return nil
+}
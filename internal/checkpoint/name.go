@@ -0,0 +1,86 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// validName is what a checkpoint name may look like: short, and easy to
+// type on a command line
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// CreateNamed is CreateIncluding, giving the checkpoint a name it can be
+// referred to by wherever an ID is taken. The name must not be taken.
+func (s *Store) CreateNamed(name, command string, targetPaths, include []string) (*Checkpoint, error) {
+	return s.CreateNamedContext(context.Background(), name, command, targetPaths, include)
+}
+
+// CreateNamedContext is CreateNamed, stopping when ctx is done like
+// CreateContext
+func (s *Store) CreateNamedContext(ctx context.Context, name, command string, targetPaths, include []string) (*Checkpoint, error) {
+	if err := s.checkName(name, ""); err != nil {
+		return nil, err
+	}
+	cp, err := s.CreateContext(ctx, command, targetPaths, include)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetName(cp.ID, name); err != nil {
+		return cp, err
+	}
+	cp.Manifest.Name = name
+	return cp, nil
+}
+
+// SetName names a checkpoint, or removes its name if name is empty. Names
+// are unique: one taken by another checkpoint is refused.
+func (s *Store) SetName(id string, name string) error {
+	if name != "" {
+		if err := s.checkName(name, id); err != nil {
+			return err
+		}
+	}
+	cp, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	cp.Manifest.Name = name
+	if err := cp.Manifest.Save(cp.Dir); err != nil {
+		return err
+	}
+	// Update index
+	s.Index().Update(cp)
+	return nil
+}
+
+// checkName returns an error if name isn't a valid name, or is the name or
+// ID of a checkpoint other than id
+func (s *Store) checkName(name, id string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid checkpoint name %q: use up to 64 letters, digits, '.', '_' and '-', starting with a letter or digit", name)
+	}
+	for _, e := range s.Index().ListEntries() {
+		if e.ID == id {
+			continue
+		}
+		if e.Name == name {
+			return fmt.Errorf("checkpoint name %q is already taken by %s", name, e.ID)
+		}
+		if e.ID == name {
+			return fmt.Errorf("checkpoint name %q is the ID of another checkpoint", name)
+		}
+	}
+	return nil
+}
+
+// CreateNamed creates a checkpoint with a name
+func CreateNamed(name, command string, targetPaths, include []string) (*Checkpoint, error) {
+	return DefaultStore().CreateNamed(name, command, targetPaths, include)
+}
+
+// SetName names a checkpoint, or removes its name if name is empty
+func SetName(id string, name string) error {
+	return DefaultStore().SetName(id, name)
+}
@@ -0,0 +1,56 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateNamed(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "testdata", "db.sql")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	os.WriteFile(file, []byte("create table t;"), 0644)
+
+	cp, err := CreateNamed("pre-migration", "migrate", []string{file}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if got, err := Resolve("pre-migration"); err != nil || got != cp.ID {
+		t.Errorf("Resolve(pre-migration) = %q, %v; want %s", got, err, cp.ID)
+	}
+	if entry := DefaultStore().Index().GetEntry(cp.ID); entry == nil || entry.Name != "pre-migration" {
+		t.Errorf("Expected the name in the index, got %+v", entry)
+	}
+
+	// Names are unique, and can't pass for another checkpoint's ID
+	if _, err := CreateNamed("pre-migration", "migrate", []string{file}, nil); err == nil {
+		t.Error("Expected a taken name to be refused")
+	}
+	other, err := Create("other", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if err := SetName(other.ID, cp.ID); err == nil {
+		t.Error("Expected another checkpoint's ID to be refused as a name")
+	}
+	for _, name := range []string{"@1", "-x", "has space", "a/b"} {
+		if err := SetName(other.ID, name); err == nil {
+			t.Errorf("Expected name %q to be refused", name)
+		}
+	}
+
+	// Renaming frees the old name
+	if err := SetName(cp.ID, "before-migration"); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	if err := SetName(other.ID, "pre-migration"); err != nil {
+		t.Errorf("Expected the old name to be free, got %v", err)
+	}
+	loaded, err := Get(cp.ID)
+	if err != nil || loaded.Manifest.Name != "before-migration" {
+		t.Errorf("Expected the name in the manifest, got %v", err)
+	}
+}
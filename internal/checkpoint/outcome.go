@@ -0,0 +1,120 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outcome is what the wrapped command a checkpoint was taken for did to the
+// files backed up, found by looking at them again once it ran. Files not
+// listed were left as they were.
+type Outcome struct {
+	ExitCode int      `json:"exit_code"`
+	Deleted  []string `json:"deleted,omitempty"`
+
+	// Moved maps each file the command moved, such as mv's sources, to
+	// where it is now
+	Moved map[string]string `json:"moved,omitempty"`
+
+	// Changed files have another size or were modified; ModeChanged files
+	// only have other permissions
+	Changed     []string `json:"changed,omitempty"`
+	ModeChanged []string `json:"mode_changed,omitempty"`
+
+	touched map[string]bool // every file listed, built by Touched
+}
+
+// ScanOutcome looks at the files of m to tell what the command that exited
+// with exitCode did to them, the way QuickEffect does. moves maps targets
+// the command may have moved to where they would be: a file missing from
+// under one is moved if it is found there, and deleted otherwise.
+func ScanOutcome(m *Manifest, exitCode int, moves map[string]string) *Outcome {
+	o := &Outcome{ExitCode: exitCode}
+	for _, f := range m.Files {
+		if f.IsDir {
+			continue
+		}
+		info, err := os.Lstat(f.OriginalPath)
+		switch {
+		case err != nil:
+			if to, ok := movedTo(f.OriginalPath, moves); ok {
+				if o.Moved == nil {
+					o.Moved = make(map[string]string)
+				}
+				o.Moved[f.OriginalPath] = to
+			} else {
+				o.Deleted = append(o.Deleted, f.OriginalPath)
+			}
+		case !LooksUnchanged(f, info, m.Timestamp):
+			if info.Size() == f.Size && !info.ModTime().After(m.Timestamp) {
+				o.ModeChanged = append(o.ModeChanged, f.OriginalPath)
+			} else {
+				o.Changed = append(o.Changed, f.OriginalPath)
+			}
+		}
+	}
+	return o
+}
+
+// movedTo returns where path is now if it is under one of the targets in
+// moves and was found where that one went
+func movedTo(path string, moves map[string]string) (string, bool) {
+	for from, to := range moves {
+		if path != from && !strings.HasPrefix(path, from+string(filepath.Separator)) {
+			continue
+		}
+		moved := to + strings.TrimPrefix(path, from)
+		if _, err := os.Lstat(moved); err == nil {
+			return moved, true
+		}
+	}
+	return "", false
+}
+
+// LooksUnchanged reports whether a backed-up file, now with info, has kept
+// its size and mode and wasn't modified since the checkpoint was taken
+func LooksUnchanged(f FileEntry, info os.FileInfo, since time.Time) bool {
+	return info.Size() == f.Size && info.Mode() == f.Mode && !info.ModTime().After(since)
+}
+
+// Effect counts the files in o
+func (o *Outcome) Effect() Effect {
+	return Effect{
+		Deleted: len(o.Deleted),
+		Moved:   len(o.Moved),
+		Changed: len(o.Changed) + len(o.ModeChanged),
+	}
+}
+
+// Touched reports whether the command did anything to path
+func (o *Outcome) Touched(path string) bool {
+	if o.touched == nil {
+		o.touched = make(map[string]bool)
+		for p := range o.Moved {
+			o.touched[p] = true
+		}
+		for _, list := range [][]string{o.Deleted, o.Changed, o.ModeChanged} {
+			for _, p := range list {
+				o.touched[p] = true
+			}
+		}
+	}
+	return o.touched[path]
+}
+
+// RecordOutcome scans the files of the checkpoint in dir once its command
+// exited with exitCode, as ScanOutcome does, and saves what it did in the
+// manifest
+func RecordOutcome(dir string, exitCode int, moves map[string]string) (*Outcome, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	m.Outcome = ScanOutcome(m, exitCode, moves)
+	if err := m.Save(dir); err != nil {
+		return nil, err
+	}
+	return m.Outcome, nil
+}
@@ -0,0 +1,64 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanOutcome(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(src, 0755)
+	for _, name := range []string{"keep.txt", "edit.txt", "script.sh", "gone.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("content"), 0644)
+	}
+	os.WriteFile(filepath.Join(src, "a.go"), []byte("package a"), 0644)
+
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+	cp, err := store.Create("test", []string{dir})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("new content"), 0644)
+	os.Chmod(filepath.Join(dir, "script.sh"), 0755)
+	os.Remove(filepath.Join(dir, "gone.txt"))
+	dst := filepath.Join(tmpDir, "moved")
+	os.Rename(src, dst)
+
+	o := ScanOutcome(cp.Manifest, 1, map[string]string{src: dst})
+	if o.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", o.ExitCode)
+	}
+	if len(o.Deleted) != 1 || o.Deleted[0] != filepath.Join(dir, "gone.txt") {
+		t.Errorf("Expected gone.txt deleted, got %v", o.Deleted)
+	}
+	if to := o.Moved[filepath.Join(src, "a.go")]; to != filepath.Join(dst, "a.go") {
+		t.Errorf("Expected a.go moved to %s, got %v", filepath.Join(dst, "a.go"), o.Moved)
+	}
+	if len(o.Changed) != 1 || o.Changed[0] != filepath.Join(dir, "edit.txt") {
+		t.Errorf("Expected edit.txt changed, got %v", o.Changed)
+	}
+	if len(o.ModeChanged) != 1 || o.ModeChanged[0] != filepath.Join(dir, "script.sh") {
+		t.Errorf("Expected script.sh with new permissions, got %v", o.ModeChanged)
+	}
+	if o.Touched(filepath.Join(dir, "keep.txt")) || !o.Touched(filepath.Join(src, "a.go")) {
+		t.Error("Expected only keep.txt to be left alone")
+	}
+	if got, want := o.Effect(), (Effect{Deleted: 1, Moved: 1, Changed: 2}); got != want {
+		t.Errorf("Effect() = %+v, want %+v", got, want)
+	}
+
+	// Recorded in the manifest
+	if _, err := RecordOutcome(cp.Dir, 0, nil); err != nil {
+		t.Fatalf("RecordOutcome failed: %v", err)
+	}
+	m, _ := LoadManifest(cp.Dir)
+	if m.Outcome == nil || len(m.Outcome.Deleted) != 2 {
+		t.Errorf("Expected the outcome saved, with a.go deleted without moves, got %+v", m.Outcome)
+	}
+}
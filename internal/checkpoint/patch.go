@@ -0,0 +1,323 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patchContext is the number of unchanged lines around each hunk
+const patchContext = 3
+
+// maxEditDistance bounds the work spent finding a minimal diff. Files
+// further apart are diffed as a full replacement, which is still a valid
+// patch.
+const maxEditDistance = 1000
+
+// WritePatch writes the given diffs as a unified diff in git format, from
+// the checkpointed content to the current files, so 'git apply' replays the
+// changes and 'git apply -R' undoes them. Paths are relative to baseDir;
+// files outside it cannot be expressed in a patch and are returned instead.
+// Unchanged files are left out and binary files are only named.
+func WritePatch(w io.Writer, cp *Checkpoint, diffs []FileDiff, baseDir string) ([]string, error) {
+	entries := make(map[string]FileEntry, len(cp.Manifest.Files))
+	for _, f := range cp.Manifest.Files {
+		entries[f.OriginalPath] = f
+	}
+
+	out := bufio.NewWriter(w)
+	var skipped []string
+	for _, d := range diffs {
+		if d.Status == DiffUnchanged {
+			continue
+		}
+		f, ok := entries[d.Path]
+		if !ok || f.IsDir {
+			continue
+		}
+		rel, err := filepath.Rel(baseDir, d.Path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			skipped = append(skipped, d.Path)
+			continue
+		}
+		if err := writeFilePatch(out, cp, f, d.Status, filepath.ToSlash(rel)); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, out.Flush()
+}
+
+// writeFilePatch writes the patch for one deleted or modified file
+func writeFilePatch(w *bufio.Writer, cp *Checkpoint, f FileEntry, status, name string) error {
+	var backup bytes.Buffer
+	if err := StreamFile(cp, f, &backup); err != nil {
+		return err
+	}
+	oldMode := gitMode(f.Mode)
+
+	var current []byte
+	var newMode string
+	if status == DiffModified {
+		info, err := os.Stat(f.OriginalPath)
+		if err != nil {
+			return err
+		}
+		if current, err = os.ReadFile(f.OriginalPath); err != nil {
+			return err
+		}
+		newMode = gitMode(info.Mode())
+	}
+
+	fmt.Fprintf(w, "diff --git a/%s b/%s\n", name, name)
+	newName := "b/" + name
+	if status == DiffDeleted {
+		fmt.Fprintf(w, "deleted file mode %s\n", oldMode)
+		newName = "/dev/null"
+	} else if oldMode != newMode {
+		fmt.Fprintf(w, "old mode %s\nnew mode %s\n", oldMode, newMode)
+	}
+
+	if isBinary(backup.Bytes()) || isBinary(current) {
+		fmt.Fprintf(w, "Binary files a/%s and %s differ\n", name, newName)
+		return nil
+	}
+	if bytes.Equal(backup.Bytes(), current) {
+		return nil // Only the mode changed
+	}
+
+	fmt.Fprintf(w, "--- a/%s\n+++ %s\n", name, newName)
+	writeHunks(w, lineDiff(splitLines(backup.Bytes()), splitLines(current)))
+	return nil
+}
+
+// gitMode returns the file mode as git records it
+func gitMode(mode os.FileMode) string {
+	if mode&0111 != 0 {
+		return "100755"
+	}
+	return "100644"
+}
+
+// isBinary uses git's heuristic: a NUL byte near the start
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splitLines splits data after each newline. A last line without one is
+// kept as is, so it differs from the same text with a newline.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
+
+// edit is one line of a line diff: ' ' kept, '-' removed or '+' added
+type edit struct {
+	op   byte
+	line string
+}
+
+// lineDiff returns an edit script turning a into b
+func lineDiff(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b)-prefix-suffix)
+	for _, l := range a[:prefix] {
+		edits = append(edits, edit{' ', l})
+	}
+	edits = append(edits, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', l})
+	}
+	return edits
+}
+
+// myersDiff finds the shortest edit script with Myers' algorithm, falling
+// back to replacing a with b beyond maxEditDistance
+func myersDiff(a, b []string) []edit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k = x - y; trace[d]
+	// holds diagonals -d-1..d+1 before step d, for backtracking
+	limit := min(n+m, maxEditDistance)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Down: insert from b
+			} else {
+				x = v[offset+k-1] + 1 // Right: delete from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+
+	edits := make([]edit, 0, n+m)
+	for _, l := range a {
+		edits = append(edits, edit{'-', l})
+	}
+	for _, l := range b {
+		edits = append(edits, edit{'+', l})
+	}
+	return edits
+}
+
+func backtrack(a, b []string, trace [][]int) []edit {
+	x, y := len(a), len(b)
+	var edits []edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d] // v[d+1+k] is diagonal k
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k] < v[d+k+2]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+1+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{'+', b[prevY]})
+			} else {
+				edits = append(edits, edit{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Hunk is one block of nearby changed lines in a line diff, with the
+// unchanged lines around it
+type Hunk struct {
+	// Header is the @@ line giving the hunk's position on either side
+	Header string
+
+	edits       []edit
+	start, stop int // Range of the hunk in the whole edit script
+}
+
+// Lines returns the hunk's lines in unified diff form, without newlines
+func (h Hunk) Lines() []string {
+	lines := make([]string, 0, len(h.edits))
+	for _, e := range h.edits {
+		line := string(e.op) + e.line
+		if !strings.HasSuffix(line, "\n") {
+			lines = append(lines, line, "\\ No newline at end of file")
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	return lines
+}
+
+// splitHunks groups the changes in edits into hunks
+func splitHunks(edits []edit) []Hunk {
+	// Lines of a and b before each edit
+	oldPos := make([]int, len(edits)+1)
+	newPos := make([]int, len(edits)+1)
+	for i, e := range edits {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if e.op != '+' {
+			oldPos[i+1]++
+		}
+		if e.op != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		start := max(i-patchContext, 0)
+		end := i + 1
+		for j := end; j < len(edits) && j-end <= 2*patchContext; j++ {
+			if edits[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		stop := min(end+patchContext, len(edits))
+
+		hunks = append(hunks, Hunk{
+			Header: fmt.Sprintf("@@ -%s +%s @@",
+				hunkRange(oldPos[start], oldPos[stop]-oldPos[start]),
+				hunkRange(newPos[start], newPos[stop]-newPos[start])),
+			edits: edits[start:stop],
+			start: start,
+			stop:  stop,
+		})
+		i = stop
+	}
+	return hunks
+}
+
+// writeHunks writes the changes in edits as unified diff hunks
+func writeHunks(w *bufio.Writer, edits []edit) {
+	for _, h := range splitHunks(edits) {
+		w.WriteString(h.Header + "\n")
+		for _, line := range h.Lines() {
+			w.WriteString(line + "\n")
+		}
+	}
+}
+
+// hunkRange formats the start line and count of one side of a hunk
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
@@ -0,0 +1,171 @@
+package checkpoint
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// applyEdits rebuilds both sides of a line diff
+func applyEdits(edits []edit) (string, string) {
+	var a, b strings.Builder
+	for _, e := range edits {
+		if e.op != '+' {
+			a.WriteString(e.line)
+		}
+		if e.op != '-' {
+			b.WriteString(e.line)
+		}
+	}
+	return a.String(), b.String()
+}
+
+func TestLineDiff(t *testing.T) {
+	var long []string
+	for i := 0; i < 3*maxEditDistance; i++ {
+		long = append(long, fmt.Sprintf("line %d\n", i))
+	}
+	var reversed []string
+	for i := len(long) - 1; i >= 0; i-- {
+		reversed = append(reversed, long[i])
+	}
+
+	tests := []struct {
+		name    string
+		a, b    []string
+		changes int // -1 to skip the minimality check
+	}{
+		{"empty", nil, nil, 0},
+		{"insert", []string{"a\n", "c\n"}, []string{"a\n", "b\n", "c\n"}, 1},
+		{"delete", []string{"a\n", "b\n", "c\n"}, []string{"a\n", "c\n"}, 1},
+		{"replace", []string{"a\n", "b\n", "c\n"}, []string{"a\n", "x\n", "c\n"}, 2},
+		{"from empty", nil, []string{"a\n", "b\n"}, 2},
+		{"missing newline", []string{"a\n", "b"}, []string{"a\n", "b\n"}, 2},
+		{"interleaved", []string{"a\n", "b\n", "c\n", "a\n", "b\n", "b\n", "a\n"}, []string{"c\n", "b\n", "a\n", "b\n", "a\n", "c\n"}, 5},
+		{"beyond limit", long, reversed, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := lineDiff(tt.a, tt.b)
+			a, b := applyEdits(edits)
+			if a != strings.Join(tt.a, "") || b != strings.Join(tt.b, "") {
+				t.Fatalf("Edit script does not rebuild the inputs")
+			}
+			if tt.changes < 0 {
+				return
+			}
+			changes := 0
+			for _, e := range edits {
+				if e.op != ' ' {
+					changes++
+				}
+			}
+			if changes != tt.changes {
+				t.Errorf("Expected %d changed lines, got %d", tt.changes, changes)
+			}
+		})
+	}
+}
+
+func TestWritePatch(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	edit := filepath.Join(dir, "src", "edit.txt")
+	gone := filepath.Join(dir, "gone.txt")
+	same := filepath.Join(dir, "same.txt")
+	os.MkdirAll(filepath.Dir(edit), 0755)
+
+	var original []string
+	for i := 1; i <= 12; i++ {
+		original = append(original, fmt.Sprintf("line %d\n", i))
+	}
+	os.WriteFile(edit, []byte(strings.Join(original, "")), 0644)
+	os.WriteFile(gone, []byte("bye\nno newline"), 0644)
+	os.WriteFile(same, []byte("same\n"), 0644)
+
+	cp, err := Create("test", []string{edit, gone, same})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Backups are hard links, so replace the file rather than editing in place
+	changed := append([]string(nil), original...)
+	changed[1] = "line two\n"
+	changed = append(changed[:10], changed[11:]...)
+	os.Remove(edit)
+	os.WriteFile(edit, []byte(strings.Join(changed, "")), 0755)
+	os.Remove(gone)
+
+	var buf bytes.Buffer
+	skipped, err := WritePatch(&buf, cp, Compare(cp), dir)
+	if err != nil {
+		t.Fatalf("WritePatch failed: %v", err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("Expected no skipped files, got %v", skipped)
+	}
+
+	expected := `diff --git a/src/edit.txt b/src/edit.txt
+old mode 100644
+new mode 100755
+--- a/src/edit.txt
++++ b/src/edit.txt
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+@@ -8,5 +8,4 @@
+ line 8
+ line 9
+ line 10
+-line 11
+ line 12
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-bye
+-no newline
+\ No newline at end of file
+`
+	got := buf.String()
+	if got != expected {
+		t.Errorf("Unexpected patch:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	// Files outside the base directory are reported, not written
+	buf.Reset()
+	skipped, _ = WritePatch(&buf, cp, Compare(cp), filepath.Join(dir, "src"))
+	if len(skipped) != 1 || skipped[0] != gone {
+		t.Errorf("Expected %s to be skipped, got %v", gone, skipped)
+	}
+
+	// git can reverse the patch to get the checkpointed files back
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	buf.Reset()
+	WritePatch(&buf, cp, Compare(cp), dir)
+	cmd := exec.Command("git", "apply", "-R")
+	cmd.Dir = dir
+	cmd.Stdin = &buf
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply -R failed: %v\n%s", err, out)
+	}
+	for _, d := range Compare(cp) {
+		if d.Status != DiffUnchanged {
+			t.Errorf("Expected %s restored by the patch, got %s", d.Path, d.Status)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// The path index records the paths each checkpoint backs up, so searching
+// by file doesn't load every manifest. It is kept out of the main index,
+// which every command loads, in an append-only file of JSON records: one
+// per checkpoint, the last one for an ID winning. Records of deleted
+// checkpoints are ignored, and dropped when the file is rewritten.
+
+type pathRecord struct {
+	ID    string   `json:"id"`
+	Paths []string `json:"paths"`
+}
+
+// pathIndexPath returns the path index of a checkpoints directory
+func pathIndexPath(dir string) string {
+	return filepath.Join(dir, ".paths.jsonl")
+}
+
+// manifestPaths returns the original paths in a manifest
+func manifestPaths(m *Manifest) []string {
+	paths := make([]string, 0, len(m.Files))
+	for _, f := range m.Files {
+		paths = append(paths, f.OriginalPath)
+	}
+	return paths
+}
+
+// appendPaths adds records to the path index with a single write, so
+// concurrent writers don't interleave
+func appendPaths(dir string, records ...pathRecord) error {
+	var data []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	f, err := os.OpenFile(pathIndexPath(dir), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+// writePaths replaces the path index
+func writePaths(dir string, paths map[string][]string) error {
+	tmp := pathIndexPath(dir) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for id, p := range paths {
+		if err := enc.Encode(pathRecord{ID: id, Paths: p}); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, pathIndexPath(dir))
+}
+
+// FilePaths returns the original paths backed up by each indexed
+// checkpoint, by ID. Checkpoints missing from the path index, e.g. ones
+// created by an older version, are read from their manifests and added.
+func (idx *Index) FilePaths() (map[string][]string, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	paths := make(map[string][]string, len(idx.Entries))
+	records := 0
+	corrupt := false
+
+	f, err := os.Open(pathIndexPath(idx.dir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if f != nil {
+		dec := json.NewDecoder(f)
+		for dec.More() {
+			var r pathRecord
+			if err := dec.Decode(&r); err != nil {
+				// Torn write or damage: rewrite from what was readable
+				corrupt = true
+				break
+			}
+			records++
+			if _, ok := idx.Entries[r.ID]; ok {
+				paths[r.ID] = r.Paths
+			}
+		}
+		f.Close()
+	}
+
+	var missing []pathRecord
+	for id := range idx.Entries {
+		if _, ok := paths[id]; ok {
+			continue
+		}
+		m, err := LoadManifest(filepath.Join(idx.dir, id))
+		if err != nil {
+			continue
+		}
+		paths[id] = manifestPaths(m)
+		missing = append(missing, pathRecord{ID: id, Paths: paths[id]})
+	}
+
+	// Rewrite once more than half the records are stale
+	if corrupt || records+len(missing) > 2*len(paths) {
+		err = writePaths(idx.dir, paths)
+	} else if len(missing) > 0 {
+		err = appendPaths(idx.dir, missing...)
+	}
+	return paths, err
+}
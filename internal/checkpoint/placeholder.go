@@ -0,0 +1,41 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// Cloud sync clients (iCloud Drive and other File Provider apps on macOS,
+// OneDrive on Windows) leave files whose content is only in the cloud as
+// placeholders. Reading one downloads it, and a hard link to one backs up
+// nothing but a stub. The cloud_placeholders setting picks between skipping
+// them, which is the default as the cloud still holds their content, and
+// hydrating them into a real copy.
+
+// Cloud placeholder policies
+const (
+	PlaceholdersSkip    = "skip"
+	PlaceholdersHydrate = "hydrate"
+)
+
+// cloudOnly is isPlaceholder, a variable so tests can fake placeholders on
+// platforms without them
+var cloudOnly = isPlaceholder
+
+// hydratePlaceholders reports whether placeholders are downloaded and
+// backed up rather than skipped
+func hydratePlaceholders() bool {
+	cfg := config.Get()
+	return cfg != nil && cfg.CloudPlaceholders == PlaceholdersHydrate
+}
+
+// hydrateFile backs up a placeholder by copying it, which downloads its
+// content; a hard link would share the stub
+func hydrateFile(srcPath, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+	return withRetry(func() error { return copyFile(srcPath, dstPath) })
+}
@@ -0,0 +1,16 @@
+package checkpoint
+
+import (
+	"os"
+	"syscall"
+)
+
+// sfDataless is set on APFS files whose content has been evicted to a File
+// Provider, such as iCloud Drive
+const sfDataless = 0x40000000
+
+// isPlaceholder reports whether info is a file whose content is not on disk
+func isPlaceholder(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && !info.IsDir() && st.Flags&sfDataless != 0
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !windows
+
+package checkpoint
+
+import "os"
+
+// isPlaceholder reports whether info is a file whose content is not on
+// disk. Other platforms have no cloud placeholders.
+func isPlaceholder(info os.FileInfo) bool {
+	return false
+}
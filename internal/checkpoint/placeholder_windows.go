@@ -0,0 +1,23 @@
+package checkpoint
+
+import (
+	"os"
+	"syscall"
+)
+
+// Attributes of files whose content is not on disk, as OneDrive sets on
+// files that are available online only
+const (
+	fileAttributeOffline            = 0x1000
+	fileAttributeRecallOnOpen       = 0x40000
+	fileAttributeRecallOnDataAccess = 0x400000
+)
+
+// isPlaceholder reports whether info is a file whose content is not on disk
+func isPlaceholder(info os.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok || info.IsDir() {
+		return false
+	}
+	return data.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
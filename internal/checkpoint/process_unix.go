@@ -0,0 +1,18 @@
+//go:build !windows
+
+package checkpoint
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processRunning reports whether the process pid is running
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// Signal 0 only checks the process is there, and may be signalled
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,17 @@
+package checkpoint
+
+import "os"
+
+// processRunning reports whether the process pid is running
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// Opening a process fails once it has exited
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,141 @@
+package checkpoint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Progress phases
+const (
+	PhaseScan       = "scan" // counting what there is to back up
+	PhaseBackup     = "backup"
+	PhaseCompress   = "compress"
+	PhaseDecompress = "decompress"
+	PhaseRestore    = "restore"
+)
+
+// Progress reports how far a long-running operation has got
+type Progress struct {
+	Phase string
+	Done  int
+	Total int
+	Path  string // item being processed, if any
+
+	// Bytes is the size of the Done items, TotalBytes that of all of them;
+	// 0 if unknown
+	Bytes      int64
+	TotalBytes int64
+}
+
+// ProgressFunc receives progress updates. A nil ProgressFunc ignores them,
+// unless ReportProgressTo set a default.
+type ProgressFunc func(Progress)
+
+// defaultProgress receives the progress of operations not given a
+// ProgressFunc of their own
+var defaultProgress ProgressFunc
+
+// ReportProgressTo makes operations not given a ProgressFunc report to f,
+// for the command line to show how far they have got. A nil f stops it.
+func ReportProgressTo(f ProgressFunc) {
+	defaultProgress = f
+}
+
+// orDefault returns f, or the default if f is nil
+func (f ProgressFunc) orDefault() ProgressFunc {
+	if f != nil {
+		return f
+	}
+	return defaultProgress
+}
+
+// Report calls f, or the default if f is nil
+func (f ProgressFunc) Report(p Progress) {
+	if f = f.orDefault(); f != nil {
+		f(p)
+	}
+}
+
+// progressInterval is how often a progressCounter reports, at most
+const progressInterval = 100 * time.Millisecond
+
+// progressCounter adds up the files an operation has processed, from any
+// goroutine, and reports the totals every progressInterval. A nil
+// progressCounter counts nothing.
+type progressCounter struct {
+	mu       sync.Mutex
+	progress ProgressFunc
+	p        Progress
+	reported time.Time
+}
+
+// newProgressCounter returns a counter reporting p and what is added to it
+// to progress, or nil if progress is nil
+func newProgressCounter(progress ProgressFunc, p Progress) *progressCounter {
+	if progress == nil {
+		return nil
+	}
+	progress(p)
+	return &progressCounter{progress: progress, p: p, reported: time.Now()}
+}
+
+// add counts the file at path, of size bytes, as processed
+func (c *progressCounter) add(path string, size int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.p.Done++
+	c.p.Bytes += size
+	c.p.Path = path
+	if time.Since(c.reported) >= progressInterval {
+		c.reported = time.Now()
+		c.progress(c.p)
+	}
+}
+
+// finish reports the final totals, with Total set to Done: the files
+// skipped along the way, or found since the scan, make them differ
+func (c *progressCounter) finish() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.p.Path = ""
+	c.p.Total, c.p.TotalBytes = c.p.Done, c.p.Bytes
+	c.progress(c.p)
+}
+
+// scanTargets counts the files backing up paths copies, and their size,
+// skipping what backupDir skips. It stops early when ctx is done.
+func scanTargets(ctx context.Context, paths []string, filter *backupFilter) (int, int64) {
+	files, size := 0, int64(0)
+	hydrate := hydratePlaceholders()
+	for _, p := range paths {
+		filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				return nil
+			}
+			if skip, skipDir := shouldSkipPath(path, info); skip || filter.skips(path, info.IsDir()) {
+				if skipDir || info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() && (hydrate || !cloudOnly(info)) {
+				files++
+				size += info.Size()
+			}
+			return nil
+		})
+	}
+	return files, size
+}
@@ -0,0 +1,141 @@
+package checkpoint
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Push packs a local checkpoint and stores it in the backend
+func (s *Store) Push(b Backend, id string) error {
+	cp, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if snap := cp.Manifest.FSSnapshot; snap != nil {
+		return fmt.Errorf("checkpoint %s is a %s snapshot, which can't leave this machine", id, snap.Backend)
+	}
+
+	tmp, err := os.CreateTemp("", "safeshell-push-*"+packedExt)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := pack(cp.Dir, tmp); err != nil {
+		return fmt.Errorf("failed to pack checkpoint: %w", err)
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := b.Put(cp.ID, tmp, size); err != nil {
+		return fmt.Errorf("failed to upload checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Pull downloads a checkpoint from the backend into the store's checkpoints directory
+func (s *Store) Pull(b Backend, id string) (*Checkpoint, error) {
+	if _, err := s.Get(id); err == nil {
+		return nil, fmt.Errorf("checkpoint %s already exists locally", id)
+	}
+
+	rc, err := b.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	checkpointsDir := s.CheckpointsDir()
+	stagingDir, err := os.MkdirTemp(checkpointsDir, ".pull-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	if err := unpack(rc, stagingDir); err != nil {
+		return nil, fmt.Errorf("failed to unpack checkpoint: %w", err)
+	}
+
+	manifest, err := LoadManifest(stagingDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", id, err)
+	}
+	if manifest.ID != id {
+		return nil, fmt.Errorf("invalid checkpoint %s: manifest is for %s", id, manifest.ID)
+	}
+
+	// Backup paths are absolute and point into the machine that created
+	// the checkpoint; rebase them onto this machine's checkpoints directory.
+	checkpointDir := filepath.Join(checkpointsDir, id)
+	rebaseBackupPaths(manifest, checkpointDir)
+	if err := manifest.Save(stagingDir); err != nil {
+		return nil, fmt.Errorf("failed to update manifest: %w", err)
+	}
+
+	if err := os.Rename(stagingDir, checkpointDir); err != nil {
+		return nil, fmt.Errorf("failed to install checkpoint: %w", err)
+	}
+
+	cp := &Checkpoint{
+		ID:        manifest.ID,
+		Dir:       checkpointDir,
+		FilesDir:  GetFilesDir(checkpointDir),
+		Manifest:  manifest,
+		CreatedAt: manifest.Timestamp,
+	}
+	s.Index().Add(cp)
+
+	return cp, nil
+}
+
+// rebaseBackupPaths points backup paths at checkpointDir, keeping their
+// location relative to the original checkpoint's files directory
+func rebaseBackupPaths(m *Manifest, checkpointDir string) {
+	marker := string(os.PathSeparator) + m.ID + string(os.PathSeparator)
+	for i, f := range m.Files {
+		idx := strings.Index(f.BackupPath, marker)
+		if idx < 0 {
+			continue
+		}
+		rel := f.BackupPath[idx+len(marker):]
+		m.Files[i].BackupPath = filepath.Join(checkpointDir, rel)
+	}
+}
+
+// pack writes checkpointDir (manifest and files or archive) as a tar.gz stream
+func pack(checkpointDir string, w io.Writer) error {
+	gzWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	if err := writeTar(context.Background(), tarWriter, checkpointDir, nil); err != nil {
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzWriter.Close()
+}
+
+// unpack extracts a packed checkpoint into dstDir
+func unpack(r io.Reader, dstDir string) error {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+
+	return extractTar(tar.NewReader(gzReader), dstDir)
+}
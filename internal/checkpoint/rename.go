@@ -0,0 +1,114 @@
+package checkpoint
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DiffRenamed is the status DetectRenames gives a deleted file whose
+// content is now at another path
+const DiffRenamed = "renamed"
+
+// DetectRenames finds where deleted files went. A file that isn't in the
+// checkpoint but has the content of a deleted one, in a directory the
+// checkpoint backed up or next to the deleted file, is taken to be that
+// file moved: its diff becomes DiffRenamed with RenamedTo set. Files are
+// identified by the SHA-256 of their content, the hash stored in manifests,
+// and only candidates of the right size are read. Empty files are never
+// matched, as every one of them would.
+//
+// A rollback still restores renamed files at their old path, and leaves the
+// moved copy alone.
+func DetectRenames(cp *Checkpoint, diffs []FileDiff) []FileDiff {
+	entries := make(map[string]FileEntry)
+	var roots []string
+	for _, f := range cp.Manifest.Files {
+		entries[f.OriginalPath] = f
+		if f.IsDir {
+			roots = append(roots, f.OriginalPath)
+		}
+	}
+
+	// Deleted files by size, and where to look for them
+	bySize := make(map[int64][]int)
+	dirs := make(map[string]bool)
+	for i, d := range diffs {
+		if d.Status == DiffDeleted && d.BackupSize > 0 {
+			bySize[d.BackupSize] = append(bySize[d.BackupSize], i)
+			dirs[filepath.Dir(d.Path)] = true
+		}
+	}
+	if len(bySize) == 0 {
+		return diffs
+	}
+
+	candidates := make(map[string]int64)
+	consider := func(path string, d fs.DirEntry) {
+		if _, ok := entries[path]; ok || !d.Type().IsRegular() {
+			return
+		}
+		info, err := d.Info()
+		if err != nil {
+			return
+		}
+		if _, ok := bySize[info.Size()]; ok {
+			candidates[path] = info.Size()
+		}
+	}
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && shouldExclude(path) {
+				return filepath.SkipDir
+			}
+			consider(path, d)
+			return nil
+		})
+	}
+	for dir := range dirs {
+		list, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, d := range list {
+			consider(filepath.Join(dir, d.Name()), d)
+		}
+	}
+
+	// Match in path order, so the result doesn't depend on map order
+	paths := make([]string, 0, len(candidates))
+	for path := range candidates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hashes := make(map[int]string)
+	for _, path := range paths {
+		size := candidates[path]
+		waiting := bySize[size]
+		if len(waiting) == 0 {
+			continue
+		}
+		hash, err := contentHash(path)
+		if err != nil {
+			continue
+		}
+		for n, i := range waiting {
+			if _, ok := hashes[i]; !ok {
+				hashes[i] = versionHash(cp, entries[diffs[i].Path])
+			}
+			if hashes[i] != "" && hashes[i] == hash {
+				diffs[i].Status = DiffRenamed
+				diffs[i].RenamedTo = path
+				diffs[i].CurrentSize = size
+				bySize[size] = append(waiting[:n:n], waiting[n+1:]...)
+				break
+			}
+		}
+	}
+	return diffs
+}
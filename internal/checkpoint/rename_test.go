@@ -0,0 +1,58 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectRenames(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(src, 0755)
+	os.WriteFile(filepath.Join(src, "a.go"), []byte("package a"), 0644)
+	os.WriteFile(filepath.Join(src, "b.go"), []byte("package b"), 0644)
+	os.WriteFile(filepath.Join(src, "empty"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644)
+
+	cp, err := Create("test", []string{src, filepath.Join(dir, "notes.txt")})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Moved within the directory backed up, and next to a file backed up
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.Rename(filepath.Join(src, "a.go"), filepath.Join(src, "sub", "moved.go"))
+	os.Rename(filepath.Join(dir, "notes.txt"), filepath.Join(dir, "notes-old.txt"))
+	// Deleted, with a new file of the same size but other content
+	os.Remove(filepath.Join(src, "b.go"))
+	os.WriteFile(filepath.Join(src, "c.go"), []byte("package c"), 0644)
+	// Empty files all look alike
+	os.Remove(filepath.Join(src, "empty"))
+	os.WriteFile(filepath.Join(src, "empty2"), nil, 0644)
+
+	diffs := make(map[string]FileDiff)
+	for _, d := range DetectRenames(cp, Compare(cp)) {
+		diffs[d.Path] = d
+	}
+
+	expected := map[string]struct{ status, to string }{
+		filepath.Join(src, "a.go"):      {DiffRenamed, filepath.Join(src, "sub", "moved.go")},
+		filepath.Join(dir, "notes.txt"): {DiffRenamed, filepath.Join(dir, "notes-old.txt")},
+		filepath.Join(src, "b.go"):      {DiffDeleted, ""},
+		filepath.Join(src, "empty"):     {DiffDeleted, ""},
+	}
+	for path, want := range expected {
+		d, ok := diffs[path]
+		if !ok {
+			t.Errorf("Expected a diff for %s", path)
+			continue
+		}
+		if d.Status != want.status || d.RenamedTo != want.to {
+			t.Errorf("%s: expected %s %q, got %s %q", filepath.Base(path), want.status, want.to, d.Status, d.RenamedTo)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// SFTPTarget is a remote directory reachable over SSH/SFTP
+type SFTPTarget struct {
+	User string
+	Host string
+	Port string
+	// Path is absolute, or relative to the remote user's home directory
+	Path string
+}
+
+// ParseSFTPTarget parses ssh://[user@]host[:port]/path (sftp:// is also
+// accepted). A path starting with /~/ is relative to the remote home.
+func ParseSFTPTarget(raw string) (*SFTPTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %w", raw, err)
+	}
+	if u.Scheme != "ssh" && u.Scheme != "sftp" {
+		return nil, fmt.Errorf("invalid target %q: expected ssh://[user@]host[:port]/path", raw)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid target %q: missing host", raw)
+	}
+
+	p := u.Path
+	switch {
+	case p == "" || p == "/":
+		return nil, fmt.Errorf("invalid target %q: missing remote path", raw)
+	case p == "/~" || strings.HasPrefix(p, "/~/"):
+		p = strings.TrimPrefix(strings.TrimPrefix(p, "/~"), "/")
+		if p == "" {
+			p = "."
+		}
+	}
+
+	return &SFTPTarget{
+		User: u.User.Username(),
+		Host: u.Hostname(),
+		Port: u.Port(),
+		Path: path.Clean(p),
+	}, nil
+}
+
+// Destination returns the [user@]host argument for ssh/sftp
+func (t *SFTPTarget) Destination() string {
+	if t.User != "" {
+		return t.User + "@" + t.Host
+	}
+	return t.Host
+}
+
+func (t *SFTPTarget) String() string {
+	s := "ssh://" + t.Destination()
+	if t.Port != "" {
+		s += ":" + t.Port
+	}
+	if strings.HasPrefix(t.Path, "/") {
+		return s + t.Path
+	}
+	return s + "/~/" + t.Path
+}
+
+// SFTPRunner executes an sftp batch script against a target
+type SFTPRunner func(t *SFTPTarget, batch string) error
+
+// Replicator mirrors checkpoints to an SFTP target. Progress is recorded
+// in a journal so an interrupted run resumes where it left off: checkpoints
+// already mirrored (and unchanged since) are skipped, and a checkpoint whose
+// transfer failed is sent again in full.
+type Replicator struct {
+	Target *SFTPTarget
+	// Run executes batches; defaults to the system sftp client, so the user's
+	// ssh config, agent and known_hosts apply
+	Run SFTPRunner
+	// JournalPath defaults to a per-target file under ~/.safeshell/replicate
+	JournalPath string
+	// Store holds the checkpoints to replicate; defaults to DefaultStore
+	Store *Store
+}
+
+// ReplicateResult summarizes a replication run
+type ReplicateResult struct {
+	Sent    []string
+	Skipped []string
+}
+
+// replicationJournal records which checkpoints a target already holds
+type replicationJournal struct {
+	Target      string                   `json:"target"`
+	Checkpoints map[string]*journalEntry `json:"checkpoints"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+}
+
+type journalEntry struct {
+	Fingerprint string      `json:"fingerprint"`
+	Entry       *IndexEntry `json:"entry"`
+	SentAt      time.Time   `json:"sent_at"`
+}
+
+// NewReplicator creates a replicator for the target using the system sftp client
+func NewReplicator(t *SFTPTarget) *Replicator {
+	return &Replicator{
+		Target:      t,
+		Run:         runSFTP,
+		JournalPath: defaultJournalPath(t),
+		Store:       DefaultStore(),
+	}
+}
+
+func defaultJournalPath(t *SFTPTarget) string {
+	sum := sha256.Sum256([]byte(t.String()))
+	return filepath.Join(config.GetSafeShellDir(), "replicate", hex.EncodeToString(sum[:8])+".json")
+}
+
+func (r *Replicator) store() *Store {
+	if r.Store == nil {
+		return DefaultStore()
+	}
+	return r.Store
+}
+
+// Replicate mirrors the given checkpoints, then uploads an index describing
+// every checkpoint the target holds
+func (r *Replicator) Replicate(ids []string) (*ReplicateResult, error) {
+	journal, err := r.loadJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplicateResult{}
+	for _, id := range ids {
+		cp, err := r.store().Get(id)
+		if err != nil {
+			return result, err
+		}
+
+		fingerprint, err := dirFingerprint(cp.Dir)
+		if err != nil {
+			return result, fmt.Errorf("failed to read checkpoint %s: %w", id, err)
+		}
+		if prev, ok := journal.Checkpoints[id]; ok && prev.Fingerprint == fingerprint {
+			result.Skipped = append(result.Skipped, id)
+			continue
+		}
+
+		batch, err := r.checkpointBatch(cp)
+		if err != nil {
+			return result, err
+		}
+		if err := r.Run(r.Target, batch); err != nil {
+			return result, fmt.Errorf("failed to replicate %s: %w", id, err)
+		}
+
+		entry := r.store().Index().GetEntry(id)
+		journal.Checkpoints[id] = &journalEntry{
+			Fingerprint: fingerprint,
+			Entry:       entry,
+			SentAt:      time.Now(),
+		}
+		if err := r.saveJournal(journal); err != nil {
+			return result, err
+		}
+		result.Sent = append(result.Sent, id)
+	}
+
+	if err := r.putIndex(journal); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// checkpointBatch builds the sftp commands that upload one checkpoint
+// directory (manifest plus files or archive)
+func (r *Replicator) checkpointBatch(cp *Checkpoint) (string, error) {
+	var b strings.Builder
+	writeMkdirs(&b, r.Target.Path)
+
+	remoteDir := path.Join(r.Target.Path, cp.ID)
+	err := filepath.WalkDir(cp.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(cp.Dir, p)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(remoteDir, filepath.ToSlash(rel))
+
+		switch {
+		case d.IsDir():
+			fmt.Fprintf(&b, "-mkdir %s\n", sftpQuote(remote))
+		case d.Type().IsRegular():
+			fmt.Fprintf(&b, "put -p %s %s\n", sftpQuote(p), sftpQuote(remote))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read checkpoint %s: %w", cp.ID, err)
+	}
+	return b.String(), nil
+}
+
+// putIndex uploads an index of every checkpoint recorded in the journal
+func (r *Replicator) putIndex(journal *replicationJournal) error {
+	idx := &Index{Entries: make(map[string]*IndexEntry), UpdatedAt: time.Now()}
+	for id, j := range journal.Checkpoints {
+		if j.Entry == nil {
+			continue
+		}
+		idx.Entries[id] = j.Entry
+		if j.Entry.Sequence >= idx.NextSequence {
+			idx.NextSequence = j.Entry.Sequence + 1
+		}
+	}
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "safeshell-index-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	writeMkdirs(&b, r.Target.Path)
+	fmt.Fprintf(&b, "put %s %s\n", sftpQuote(tmp.Name()), sftpQuote(path.Join(r.Target.Path, ".index.json")))
+	if err := r.Run(r.Target, b.String()); err != nil {
+		return fmt.Errorf("failed to upload index: %w", err)
+	}
+	return nil
+}
+
+func (r *Replicator) loadJournal() (*replicationJournal, error) {
+	journal := &replicationJournal{
+		Target:      r.Target.String(),
+		Checkpoints: make(map[string]*journalEntry),
+	}
+
+	data, err := os.ReadFile(r.JournalPath)
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replication journal: %w", err)
+	}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("invalid replication journal %s: %w", r.JournalPath, err)
+	}
+	if journal.Checkpoints == nil {
+		journal.Checkpoints = make(map[string]*journalEntry)
+	}
+	return journal, nil
+}
+
+func (r *Replicator) saveJournal(journal *replicationJournal) error {
+	journal.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.JournalPath), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	return os.WriteFile(r.JournalPath, data, 0644)
+}
+
+// dirFingerprint summarizes a checkpoint directory's contents so changes
+// (compression, new tags in the manifest) trigger a resend
+func dirFingerprint(dir string) (string, error) {
+	var lines []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		lines = append(lines, fmt.Sprintf("%s %d %d", rel, info.Size(), info.ModTime().UnixNano()))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeMkdirs emits a -mkdir for each component of dir. The leading dash
+// tells sftp to ignore the error when the directory already exists.
+func writeMkdirs(b *strings.Builder, dir string) {
+	current := ""
+	if strings.HasPrefix(dir, "/") {
+		current = "/"
+	}
+	for _, part := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		current = path.Join(current, part)
+		fmt.Fprintf(b, "-mkdir %s\n", sftpQuote(current))
+	}
+}
+
+// sftpQuote quotes a path for an sftp batch file
+func sftpQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// runSFTP executes batch with the system sftp client
+func runSFTP(t *SFTPTarget, batch string) error {
+	tmp, err := os.CreateTemp("", "safeshell-sftp-*.batch")
+	if err != nil {
+		return fmt.Errorf("failed to create batch file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(batch); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	args := []string{"-b", tmp.Name(), "-o", "BatchMode=yes"}
+	if t.Port != "" {
+		args = append(args, "-P", t.Port)
+	}
+	args = append(args, t.Destination())
+
+	out, err := exec.Command("sftp", args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return fmt.Errorf("sftp: %w", err)
+		}
+		return fmt.Errorf("sftp: %w: %s", err, msg)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSFTPTarget(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    SFTPTarget
+		wantErr bool
+	}{
+		{raw: "ssh://backup@db1/srv/safeshell", want: SFTPTarget{User: "backup", Host: "db1", Path: "/srv/safeshell"}},
+		{raw: "sftp://db1:2222/srv/safeshell/", want: SFTPTarget{Host: "db1", Port: "2222", Path: "/srv/safeshell"}},
+		{raw: "ssh://dev@db1/~/checkpoints", want: SFTPTarget{User: "dev", Host: "db1", Path: "checkpoints"}},
+		{raw: "ssh://db1/~", want: SFTPTarget{Host: "db1", Path: "."}},
+		{raw: "https://db1/srv", wantErr: true},
+		{raw: "ssh:///srv", wantErr: true},
+		{raw: "ssh://db1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSFTPTarget(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSFTPTarget(%q) should fail", tt.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSFTPTarget(%q) failed: %v", tt.raw, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParseSFTPTarget(%q) = %+v, want %+v", tt.raw, *got, tt.want)
+		}
+	}
+}
+
+func TestSFTPQuote(t *testing.T) {
+	if got := sftpQuote(`/tmp/my "dir"\x`); got != `"/tmp/my \"dir\"\\x"` {
+		t.Errorf("Unexpected quoting: %s", got)
+	}
+}
+
+func TestReplicateResume(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var ids []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		testFile := filepath.Join(tmpDir, "testdata", name)
+		os.WriteFile(testFile, []byte(name), 0644)
+		cp, err := Create("rm "+name, []string{testFile})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		ids = append(ids, cp.ID)
+	}
+
+	target, _ := ParseSFTPTarget("ssh://backup@db1/srv/safeshell")
+	var batches []string
+	failOn := ids[1]
+	r := &Replicator{
+		Target:      target,
+		JournalPath: filepath.Join(tmpDir, "journal.json"),
+		Run: func(_ *SFTPTarget, batch string) error {
+			if failOn != "" && strings.Contains(batch, failOn) {
+				return errors.New("connection reset")
+			}
+			batches = append(batches, batch)
+			return nil
+		},
+	}
+
+	// First run is interrupted on the second checkpoint
+	result, err := r.Replicate(ids)
+	if err == nil {
+		t.Fatal("Expected replicate to fail")
+	}
+	if len(result.Sent) != 1 || result.Sent[0] != ids[0] {
+		t.Fatalf("Expected first checkpoint to be sent, got %+v", result)
+	}
+	first, _ := Get(ids[0])
+	if !strings.Contains(batches[0], `put -p "`+filepath.Join(first.Dir, "manifest.json")+`" "/srv/safeshell/`+ids[0]+`/manifest.json"`) {
+		t.Errorf("Batch should upload the manifest:\n%s", batches[0])
+	}
+	if !strings.Contains(batches[0], `-mkdir "/srv"`) {
+		t.Errorf("Batch should create the target directory:\n%s", batches[0])
+	}
+
+	// Resuming skips the checkpoint already mirrored and uploads the index
+	failOn = ""
+	batches = nil
+	result, err = r.Replicate(ids)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != ids[0] || len(result.Sent) != 1 || result.Sent[0] != ids[1] {
+		t.Errorf("Unexpected resume result: %+v", result)
+	}
+	last := batches[len(batches)-1]
+	if !strings.Contains(last, `"/srv/safeshell/.index.json"`) {
+		t.Errorf("Last batch should upload the index:\n%s", last)
+	}
+}
@@ -0,0 +1,174 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCheckpoint is returned when there is no checkpoint where a reference
+// or time points
+var ErrNoCheckpoint = errors.New("no checkpoint")
+
+// AmbiguousError is returned when a short reference matches more than one
+// checkpoint
+type AmbiguousError struct {
+	Ref string
+	IDs []string
+}
+
+func (e *AmbiguousError) Error() string {
+	ids := e.IDs
+	more := ""
+	if len(ids) > 5 {
+		ids, more = ids[:5], fmt.Sprintf(" and %d more", len(e.IDs)-5)
+	}
+	return fmt.Sprintf("%q matches %d checkpoints: %s%s", e.Ref, len(e.IDs), strings.Join(ids, ", "), more)
+}
+
+// Resolve returns the ID of the checkpoint ref refers to: a full ID, a
+// checkpoint's name, a unique prefix of an ID or of the random part at its
+// end ("a1b2" for 2024-12-12T143022-a1b2c3d4), or @N for the Nth latest (@1
+// is the latest).
+// A ref matching nothing is returned as is, for Get to report.
+func (s *Store) Resolve(ref string) (string, error) {
+	entries := s.Index().ListEntries()
+
+	if n, ok := strings.CutPrefix(ref, "@"); ok {
+		i, err := strconv.Atoi(n)
+		if err != nil || i < 1 {
+			return "", fmt.Errorf("invalid checkpoint reference %s (use @1 for the latest, @2 for the one before...)", ref)
+		}
+		if i > len(entries) {
+			return "", fmt.Errorf("%w %s: there are only %d", ErrNoCheckpoint, ref, len(entries))
+		}
+		return entries[i-1].ID, nil
+	}
+
+	var matches []string
+	for _, e := range entries {
+		if e.ID == ref || (e.Name != "" && e.Name == ref) {
+			return e.ID, nil
+		}
+		suffix := e.ID[strings.LastIndex(e.ID, "-")+1:]
+		if ref != "" && (strings.HasPrefix(e.ID, ref) || strings.HasPrefix(suffix, ref)) {
+			matches = append(matches, e.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ref, nil
+	case 1:
+		return matches[0], nil
+	}
+	return "", &AmbiguousError{Ref: ref, IDs: matches}
+}
+
+// ResolveAt returns the ID of the latest checkpoint created at or before
+// when, a time such as "2 hours ago", "yesterday 17:00" or "2024-12-12
+// 14:30"
+func (s *Store) ResolveAt(when string) (string, error) {
+	t, err := parseTime(when, time.Now())
+	if err != nil {
+		return "", err
+	}
+	for _, e := range s.Index().ListEntries() {
+		if !e.Timestamp.After(t) {
+			return e.ID, nil
+		}
+	}
+	return "", fmt.Errorf("%w from before %s", ErrNoCheckpoint, t.Format("2006-01-02 15:04:05"))
+}
+
+// Resolve returns the ID of the checkpoint ref refers to
+func Resolve(ref string) (string, error) {
+	return DefaultStore().Resolve(ref)
+}
+
+// ResolveAt returns the ID of the latest checkpoint as of when
+func ResolveAt(when string) (string, error) {
+	return DefaultStore().ResolveAt(when)
+}
+
+// timeUnits are the units parseTime understands in "N units ago"
+var timeUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour,
+}
+
+// timeLayouts are the absolute times parseTime understands, in local time
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime parses a time relative to now ("2 hours ago", "90m ago",
+// "3d", "yesterday", "yesterday 17:00", "14:30") or absolute ("2024-12-12
+// 14:30")
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid time %q (use e.g. \"2 hours ago\", \"yesterday 17:00\" or \"2024-12-12 14:30\")", s)
+	lower := strings.ToLower(s)
+
+	switch lower {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	// A time of day, today or yesterday
+	day, clock, dayGiven := now, lower, false
+	if rest, ok := strings.CutPrefix(lower, "yesterday "); ok {
+		day, clock, dayGiven = now.AddDate(0, 0, -1), rest, true
+	} else if rest, ok := strings.CutPrefix(lower, "today "); ok {
+		clock, dayGiven = rest, true
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if c, err := time.Parse(layout, clock); err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(), c.Hour(), c.Minute(), c.Second(), 0, now.Location()), nil
+		}
+	}
+	if dayGiven {
+		return time.Time{}, invalid
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	// A duration back from now: "2 hours ago", "2h ago", "1h30m", "3d"
+	ago := strings.TrimSpace(strings.TrimSuffix(lower, " ago"))
+	if d, err := time.ParseDuration(ago); err == nil {
+		return now.Add(-d), nil
+	}
+	fields := strings.Fields(ago)
+	if len(fields) == 1 {
+		// "3d", "2w": a number glued to a unit
+		i := strings.IndexFunc(ago, func(r rune) bool { return r < '0' || r > '9' })
+		if i > 0 {
+			fields = []string{ago[:i], ago[i:]}
+		}
+	}
+	if len(fields) == 2 {
+		n, err := strconv.Atoi(fields[0])
+		unit, ok := timeUnits[strings.TrimSuffix(fields[1], "s")]
+		if !ok {
+			unit, ok = timeUnits[fields[1]]
+		}
+		if err == nil && ok && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	return time.Time{}, invalid
+}
@@ -0,0 +1,81 @@
+package checkpoint
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+	base := time.Date(2024, 12, 12, 14, 30, 0, 0, time.Local)
+	for i, id := range []string{"2024-12-12T143000-a1b2c3d4", "2024-12-12T153000-a1ffffff", "2024-12-12T163000-b2c3d4e5"} {
+		store.Index().Add(&Checkpoint{ID: id, Manifest: &Manifest{ID: id, Timestamp: base.Add(time.Duration(i) * time.Hour)}})
+	}
+
+	resolves := map[string]string{
+		"2024-12-12T153000-a1ffffff": "2024-12-12T153000-a1ffffff",
+		"a1b":                        "2024-12-12T143000-a1b2c3d4", // Prefix of the random part
+		"2024-12-12T16":              "2024-12-12T163000-b2c3d4e5", // Prefix of the ID
+		"@1":                         "2024-12-12T163000-b2c3d4e5",
+		"@3":                         "2024-12-12T143000-a1b2c3d4",
+		"zzz":                        "zzz", // Left for Get to report
+	}
+	for ref, want := range resolves {
+		if got, err := store.Resolve(ref); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+
+	var ambiguous *AmbiguousError
+	if _, err := store.Resolve("a1"); !errors.As(err, &ambiguous) || len(ambiguous.IDs) != 2 {
+		t.Errorf("Expected a1 to be ambiguous between 2 checkpoints, got %v", err)
+	}
+	for _, ref := range []string{"@0", "@4", "@x"} {
+		if _, err := store.Resolve(ref); err == nil {
+			t.Errorf("Expected Resolve(%q) to fail", ref)
+		}
+	}
+
+	if got, err := store.ResolveAt("2024-12-12 15:45"); err != nil || got != "2024-12-12T153000-a1ffffff" {
+		t.Errorf("ResolveAt = %q, %v; want the checkpoint of 15:30", got, err)
+	}
+	if _, err := store.ResolveAt("2024-12-12 14:00"); err == nil {
+		t.Error("Expected no checkpoint before the first one")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 12, 12, 14, 30, 0, 0, time.Local)
+	times := map[string]time.Time{
+		"now":                  now,
+		"2 hours ago":          now.Add(-2 * time.Hour),
+		"1 minute ago":         now.Add(-time.Minute),
+		"90m ago":              now.Add(-90 * time.Minute),
+		"1h30m":                now.Add(-90 * time.Minute),
+		"3d":                   now.AddDate(0, 0, -3),
+		"2 weeks ago":          now.AddDate(0, 0, -14),
+		"yesterday":            now.AddDate(0, 0, -1),
+		"Yesterday 17:00":      time.Date(2024, 12, 11, 17, 0, 0, 0, time.Local),
+		"09:15":                time.Date(2024, 12, 12, 9, 15, 0, 0, time.Local),
+		"2024-12-01 08:00":     time.Date(2024, 12, 1, 8, 0, 0, 0, time.Local),
+		"2024-12-01T08:00:05":  time.Date(2024, 12, 1, 8, 0, 5, 0, time.Local),
+		"2024-12-01T08:00:00Z": time.Date(2024, 12, 1, 8, 0, 0, 0, time.UTC),
+	}
+	for s, want := range times {
+		got, err := parseTime(s, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseTime(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"", "soon", "2 fortnights ago", "yesterday noon"} {
+		if _, err := parseTime(s, now); err == nil {
+			t.Errorf("Expected parseTime(%q) to fail", s)
+		}
+	}
+}
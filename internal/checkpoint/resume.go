@@ -0,0 +1,269 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A checkpoint being copied keeps a journal of what it backs up, so one
+// interrupted part way through a huge directory can be finished by
+// ResumeCreate instead of started over. Both files go once its manifest is
+// saved.
+const (
+	createStateFile = ".create.json" // what the checkpoint was asked to back up
+	copiedLog       = ".copied"      // the files backed up so far, a JSON line each
+)
+
+// journalFlush is how often the files backed up are written to the journal,
+// at most. Those backed up since are backed up again on resume.
+const journalFlush = time.Second
+
+// createState is what a checkpoint being created was asked to back up
+type createState struct {
+	Command    string   `json:"command"`
+	Targets    []string `json:"targets"`
+	Include    []string `json:"include"` // null for include_paths
+	Name       string   `json:"name,omitempty"`
+	WorkingDir string   `json:"working_dir"`
+	SessionID  string   `json:"session_id,omitempty"`
+	PID        int      `json:"pid"` // of the process creating it
+}
+
+// copiedFile is a file backed up, as it was then
+type copiedFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Unix nanoseconds
+}
+
+// ResumableError is the error of a checkpoint interrupted while backing up,
+// kept for ResumeCreate to finish
+type ResumableError struct {
+	ID  string
+	Err error
+}
+
+func (e *ResumableError) Error() string {
+	return fmt.Sprintf("%v; the files backed up so far are kept, 'safeshell checkpoint resume %s' finishes the checkpoint", e.Err, e.ID)
+}
+
+func (e *ResumableError) Unwrap() error { return e.Err }
+
+// createJournal records the files a checkpoint being created backs up.
+// Resuming, it holds those backed up before, to reuse. A nil createJournal
+// records nothing and reuses nothing.
+type createJournal struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	flushed time.Time
+	before  map[string]copiedFile // nil unless resuming
+}
+
+// openJournal records state in the checkpoint directory dir, and returns a
+// journal for the files backed up into it. Resuming, the files backed up
+// before are read back.
+func openJournal(dir string, state createState, resume bool) (*createJournal, error) {
+	j := &createJournal{flushed: time.Now()}
+	if resume {
+		before, err := readCopied(filepath.Join(dir, copiedLog))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read what was backed up: %w", err)
+		}
+		j.before = before
+	}
+
+	state.PID = os.Getpid()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, createStateFile), data, 0644); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, copiedLog), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j.f, j.w = f, bufio.NewWriter(f)
+	return j, nil
+}
+
+// readCopied reads a copiedLog, later lines replacing earlier ones for the
+// same file. A line cut short by a kill is ignored.
+func readCopied(path string) (map[string]copiedFile, error) {
+	copied := make(map[string]copiedFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return copied, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var c copiedFile
+		if json.Unmarshal(line, &c) == nil && c.Path != "" {
+			copied[c.Path] = c
+		}
+	}
+	return copied, nil
+}
+
+// reuse reports whether the backup at backupPath, made before the
+// checkpoint was resumed, can be kept: the file at path, described by info,
+// is as it was then, and the backup has the same content. A backup that
+// can't is removed, so backing up again doesn't write through a hard link
+// to the file.
+func (j *createJournal) reuse(path string, info os.FileInfo, backupPath string) bool {
+	if j == nil || j.before == nil {
+		return false
+	}
+	if c, ok := j.before[path]; ok && c.Size == info.Size() && c.ModTime == info.ModTime().UnixNano() {
+		if backup, err := os.Lstat(backupPath); err == nil && backup.Mode().IsRegular() && backup.Size() == c.Size {
+			want, err := contentHash(path)
+			if err == nil {
+				if got, err := contentHash(backupPath); err == nil && got == want {
+					return true
+				}
+			}
+		}
+	}
+	os.Remove(backupPath)
+	return false
+}
+
+// record adds the file at path, described by info, as backed up
+func (j *createJournal) record(path string, info os.FileInfo) {
+	if j == nil {
+		return
+	}
+	line, err := json.Marshal(copiedFile{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()})
+	if err != nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.w.Write(append(line, '\n'))
+	if time.Since(j.flushed) >= journalFlush {
+		j.w.Flush()
+		j.flushed = time.Now()
+	}
+}
+
+// close writes out what is recorded and closes the journal. It can be
+// called more than once.
+func (j *createJournal) close() {
+	if j == nil || j.f == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.w.Flush()
+	j.f.Close()
+	j.f = nil
+}
+
+// finish closes the journal and removes it from the checkpoint directory
+// dir, once the checkpoint no longer needs resuming
+func (j *createJournal) finish(dir string) {
+	if j == nil {
+		return
+	}
+	j.close()
+	os.Remove(filepath.Join(dir, copiedLog))
+	os.Remove(filepath.Join(dir, createStateFile))
+}
+
+// pruneBackups removes the backups in filesDir that manifest doesn't list,
+// made before resuming of files since deleted or no longer backed up
+func pruneBackups(filesDir string, manifest *Manifest) {
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, f := range manifest.Files {
+		listed[f.BackupPath] = true
+	}
+	filepath.Walk(filesDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !listed[path] {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// resumableState returns what the checkpoint id, in dir, was asked to back
+// up, or an error if it can't be resumed
+func resumableState(id, dir string) (*createState, error) {
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("%w %s to resume", ErrNoCheckpoint, id)
+	}
+	switch Incomplete(dir) {
+	case "":
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("%w %s to resume", ErrNoCheckpoint, id)
+		}
+		return nil, fmt.Errorf("checkpoint %s is already created", id)
+	case IncompleteMove:
+		return nil, fmt.Errorf("checkpoint %s holds files moved into it, run 'safeshell fsck --repair' to make them rollbackable", id)
+	}
+	if _, err := readManifest(dir); err == nil {
+		return nil, fmt.Errorf("checkpoint %s only needs marking as created, run 'safeshell fsck --repair'", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, createStateFile))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %s can't be resumed: it has no journal of what it backs up", id)
+	}
+	var state createState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("checkpoint %s can't be resumed: %w", id, err)
+	}
+	if state.PID != os.Getpid() && processRunning(state.PID) {
+		return nil, fmt.Errorf("checkpoint %s is still being created, by process %d", id, state.PID)
+	}
+	return &state, nil
+}
+
+// ResumeCreate finishes creating the checkpoint id, interrupted while
+// backing up. Backups made before are kept for the files that haven't
+// changed since, checked by hash; the others are backed up again. Stopped
+// when ctx is done, it can be resumed again.
+func (s *Store) ResumeCreate(ctx context.Context, id string) (*Checkpoint, error) {
+	state, err := resumableState(id, s.checkpointDir(id))
+	if err != nil {
+		return nil, err
+	}
+	if state.Name != "" {
+		if err := s.checkName(state.Name, id); err != nil {
+			return nil, err
+		}
+	}
+	origin := Origin{WorkingDir: state.WorkingDir, SessionID: state.SessionID}
+	return s.create(ctx, origin, state.Command, state.Targets, createOptions{include: state.Include, name: state.Name, resume: id})
+}
+
+// LatestResumable returns the ID of the latest checkpoint ResumeCreate can
+// finish
+func (s *Store) LatestResumable() (string, error) {
+	dirs, err := os.ReadDir(s.CheckpointsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	// IDs start with the time they were created at
+	for i := len(dirs) - 1; i >= 0; i-- {
+		id := dirs[i].Name()
+		if !dirs[i].IsDir() {
+			continue
+		}
+		if _, err := resumableState(id, s.checkpointDir(id)); err == nil {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("%w to resume", ErrNoCheckpoint)
+}
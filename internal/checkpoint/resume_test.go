@@ -0,0 +1,104 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumeCreate(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	project := filepath.Join(tmpDir, "testdata", "project")
+	os.MkdirAll(filepath.Join(project, "src"), 0755)
+	path := func(name string) string { return filepath.Join(project, name) }
+	for _, name := range []string{"kept.txt", "changed.txt", "corrupt.txt", "new.txt", filepath.Join("src", "deleted.txt")} {
+		os.WriteFile(path(name), []byte("content of "+name), 0644)
+	}
+
+	// Interrupted, what was backed up is kept
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	origin := Origin{WorkingDir: tmpDir}
+	_, err := store.create(ctx, origin, "manual checkpoint", []string{project}, createOptions{name: "big", resumable: true, progress: func(p Progress) {
+		if p.Phase == PhaseBackup {
+			cancel()
+		}
+	}})
+	var resumable *ResumableError
+	if !errors.As(err, &resumable) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a resumable error, got %v", err)
+	}
+	id := resumable.ID
+	dir := store.checkpointDir(id)
+	if Incomplete(dir) != IncompleteCopy {
+		t.Fatal("Expected the interrupted checkpoint kept, marked incomplete")
+	}
+	if latest, err := store.LatestResumable(); err != nil || latest != id {
+		t.Errorf("Expected %s resumable, got %q, %v", id, latest, err)
+	}
+
+	// As if killed part way through: some files backed up, some not
+	filesDir := GetFilesDir(dir)
+	backup := func(name string) string { return filepath.Join(filesDir, path(name)) }
+	journal, err := openJournal(dir, createState{Command: "manual checkpoint", Targets: []string{project}, Name: "big", WorkingDir: tmpDir}, false)
+	if err != nil {
+		t.Fatalf("openJournal failed: %v", err)
+	}
+	for _, name := range []string{"kept.txt", "changed.txt", "corrupt.txt", filepath.Join("src", "deleted.txt")} {
+		os.MkdirAll(filepath.Dir(backup(name)), 0755)
+		copyFile(path(name), backup(name))
+		info, _ := os.Stat(path(name))
+		journal.record(path(name), info)
+	}
+	journal.close()
+	kept, _ := os.Stat(backup("kept.txt"))
+
+	os.Remove(path("changed.txt"))
+	os.WriteFile(path("changed.txt"), []byte("changed since"), 0644)
+	os.Remove(backup("corrupt.txt"))
+	os.WriteFile(backup("corrupt.txt"), []byte("CONTENT OF corrupt.txt"), 0644)
+	os.Remove(path(filepath.Join("src", "deleted.txt")))
+
+	cp, err := store.ResumeCreate(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ResumeCreate failed: %v", err)
+	}
+	if cp.ID != id || cp.Manifest.Name != "big" {
+		t.Errorf("Expected checkpoint %s named big, got %s named %q", id, cp.ID, cp.Manifest.Name)
+	}
+	if after, err := os.Stat(backup("kept.txt")); err != nil || !os.SameFile(kept, after) {
+		t.Errorf("Expected the backup of an unchanged file reused, got %v", err)
+	}
+	for _, name := range []string{"kept.txt", "changed.txt", "corrupt.txt", "new.txt"} {
+		want, _ := os.ReadFile(path(name))
+		if got, err := os.ReadFile(backup(name)); err != nil || string(got) != string(want) {
+			t.Errorf("Expected the backup of %s to be %q, got %q, %v", name, want, got, err)
+		}
+	}
+	if _, err := os.Stat(backup(filepath.Join("src", "deleted.txt"))); !os.IsNotExist(err) {
+		t.Errorf("Expected the backup of a file deleted since removed, got %v", err)
+	}
+	for _, name := range []string{createStateFile, copiedLog, incompleteMarker} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed once created, got %v", name, err)
+		}
+	}
+	if err := Verify(cp); err != nil {
+		t.Errorf("Expected the resumed checkpoint intact, got %v", err)
+	}
+	if e := store.Index().GetEntry(id); e == nil || e.Name != "big" {
+		t.Errorf("Expected the resumed checkpoint indexed, got %+v", e)
+	}
+
+	if _, err := store.ResumeCreate(context.Background(), id); err == nil {
+		t.Error("Expected resuming a created checkpoint to fail")
+	}
+	if _, err := store.LatestResumable(); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("Expected nothing left to resume, got %v", err)
+	}
+}
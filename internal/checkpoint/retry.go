@@ -0,0 +1,29 @@
+package checkpoint
+
+import "time"
+
+// Network filesystems (NFS, SMB) and synced folders (Dropbox, OneDrive)
+// sometimes fail an open or stat for a moment while the file is busy or its
+// handle goes stale. Such errors are retried a few times, waiting a little
+// longer each time, before a file is given up on.
+
+// retryAttempts is how often a transient failure is tried in all
+const retryAttempts = 4
+
+// retryBackoff is the wait before the first retry; it doubles after each.
+// It is a variable so tests don't have to wait.
+var retryBackoff = 50 * time.Millisecond
+
+// withRetry runs op until it succeeds, fails with an error that isn't
+// transient, or has been tried retryAttempts times
+func withRetry(op func() error) error {
+	wait := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt == retryAttempts || !isTransient(err) {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package checkpoint
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTransient reports whether err is a failure that may pass if retried
+func isTransient(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.EAGAIN, syscall.EINTR, syscall.EBUSY, syscall.ESTALE, syscall.ETIMEDOUT:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = 0
+
+	stale := &os.PathError{Op: "open", Path: "/mnt/nfs/a", Err: syscall.ESTALE}
+
+	tests := []struct {
+		name     string
+		failures []error
+		calls    int
+		fails    bool
+	}{
+		{"succeeds after transient errors", []error{stale, fmt.Errorf("copy: %w", stale)}, 3, false},
+		{"gives up after the last attempt", []error{stale, stale, stale, stale, stale}, retryAttempts, true},
+		{"does not retry other errors", []error{&os.PathError{Op: "open", Path: "a", Err: syscall.ENOENT}}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.calls {
+				t.Errorf("Expected %d calls, got %d", tt.calls, calls)
+			}
+			if (err != nil) != tt.fails {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if err != nil && err != tt.failures[calls-1] {
+				t.Errorf("Expected the last error to be returned, got %v", err)
+			}
+		})
+	}
+}
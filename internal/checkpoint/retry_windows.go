@@ -0,0 +1,29 @@
+package checkpoint
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows errors that sync clients, antivirus scanners and network shares
+// cause while they hold a file or reconnect
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+	errorUnexpNetErr      syscall.Errno = 59
+	errorNetnameDeleted   syscall.Errno = 64
+	errorSemTimeout       syscall.Errno = 121
+)
+
+// isTransient reports whether err is a failure that may pass if retried
+func isTransient(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case errorSharingViolation, errorLockViolation, errorUnexpNetErr, errorNetnameDeleted, errorSemTimeout:
+		return true
+	}
+	return false
+}
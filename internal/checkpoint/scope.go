@@ -0,0 +1,65 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// ProjectScope returns what to back up for target, a path run on from
+// workingDir. With scope_to_project set, a directory above the project
+// workingDir is in is narrowed down to the project; anything else is
+// returned as is.
+func ProjectScope(target, workingDir string) string {
+	cfg := config.Get()
+	if cfg == nil || !cfg.ScopeToProject {
+		return target
+	}
+	root := projectRoot(workingDir, cfg.ProjectMarkers)
+	if root == "" || root == target || !underPath(target, root) {
+		return target
+	}
+	return root
+}
+
+// projectRoot returns the nearest directory from dir up holding one of
+// markers, or "" if there is none
+func projectRoot(dir string, markers []string) string {
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		for _, marker := range markers {
+			// .git is a file in worktrees and submodules
+			if _, err := os.Lstat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// scopeTargets applies ProjectScope to each target, warning about the ones
+// narrowed down. A path reached from several targets is backed up once.
+func scopeTargets(targets []string, workingDir string) []string {
+	scoped := make([]string, 0, len(targets))
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		abs := target
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(workingDir, abs)
+		}
+		abs = filepath.Clean(abs)
+		if root := ProjectScope(abs, workingDir); root != abs {
+			logging.Warn(fmt.Sprintf("Warning: %s is above the project root, backing up only %s", abs, root))
+			abs = root
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			scoped = append(scoped, abs)
+		}
+	}
+	return scoped
+}
@@ -0,0 +1,101 @@
+package checkpoint
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SelectFiles returns the original paths of the checkpoint's files that
+// patterns pick, in manifest order, and the patterns that picked none.
+//
+// A pattern is a file, a directory (every file under it, with or without a
+// trailing slash) or a glob, where ** spans any number of directories
+// ("src/**/*.go"). Relative patterns are taken from dir. A relative path
+// that picks nothing from dir is matched against the end of each path
+// instead, so "main.go" picks src/main.go. Files git_aware left out are
+// picked too, for rollback to get back from git.
+func SelectFiles(cp *Checkpoint, patterns []string, dir string) (selected []string, unmatched []string) {
+	var files []string
+	for _, f := range cp.Manifest.Files {
+		if !f.IsDir {
+			files = append(files, f.OriginalPath)
+		}
+	}
+	files = append(files, cp.Manifest.GitFiles()...)
+
+	picked := make(map[string]bool)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		abs := pattern
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(dir, abs)
+		}
+		abs = filepath.Clean(abs)
+		glob := strings.ContainsAny(pattern, "*?[")
+
+		found := false
+		for _, f := range files {
+			if glob && matchGlob(abs, f) || !glob && underPath(abs, f) {
+				picked[f] = true
+				found = true
+			}
+		}
+		if !found && !glob && !filepath.IsAbs(pattern) {
+			suffix := string(filepath.Separator) + filepath.Clean(pattern)
+			for _, f := range files {
+				if strings.HasSuffix(f, suffix) {
+					picked[f] = true
+					found = true
+				}
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+
+	for _, f := range files {
+		if picked[f] {
+			selected = append(selected, f)
+			delete(picked, f) // Listed once, even if in the manifest twice
+		}
+	}
+	return selected, unmatched
+}
+
+// underPath reports whether p is base or inside it
+func underPath(base, p string) bool {
+	return p == base || strings.HasPrefix(p, strings.TrimSuffix(base, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// matchGlob matches p against pattern a path segment at a time. ** matches
+// any number of segments, and a pattern matching a directory matches the
+// files under it.
+func matchGlob(pattern, p string) bool {
+	return matchSegments(strings.Split(filepath.ToSlash(pattern), "/"), strings.Split(filepath.ToSlash(p), "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(segments); i >= 0; i-- {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package checkpoint
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectFiles(t *testing.T) {
+	root := filepath.FromSlash("/p")
+	abs := func(p string) string { return filepath.Join(root, filepath.FromSlash(p)) }
+	cp := &Checkpoint{Manifest: &Manifest{Files: []FileEntry{
+		{OriginalPath: abs("src"), IsDir: true},
+		{OriginalPath: abs("src/main.go")},
+		{OriginalPath: abs("src/pkg/util.go")},
+		{OriginalPath: abs("src/pkg/util_test.txt")},
+		{OriginalPath: abs("configs/app.yaml")},
+		{OriginalPath: abs("configs2/other.yaml")},
+		{OriginalPath: abs("README.md")},
+	}}}
+
+	tests := []struct {
+		name      string
+		patterns  []string
+		want      []string
+		unmatched []string
+	}{
+		{"exact relative", []string{"README.md"}, []string{"README.md"}, nil},
+		{"exact absolute", []string{abs("src/main.go")}, []string{"src/main.go"}, nil},
+		{"directory", []string{"configs"}, []string{"configs/app.yaml"}, nil},
+		{"directory with slash", []string{"configs/"}, []string{"configs/app.yaml"}, nil},
+		{"glob", []string{"src/*.go"}, []string{"src/main.go"}, nil},
+		{"double star", []string{"src/**/*.go"}, []string{"src/main.go", "src/pkg/util.go"}, nil},
+		{"glob naming directories", []string{"config*"}, []string{"configs/app.yaml", "configs2/other.yaml"}, nil},
+		{"suffix", []string{"pkg/util.go"}, []string{"src/pkg/util.go"}, nil},
+		{"suffix needs whole names", []string{"ain.go"}, nil, []string{"ain.go"}},
+		{"manifest order, no duplicates", []string{"README.md", "src/", "src/main.go"}, []string{"src/main.go", "src/pkg/util.go", "src/pkg/util_test.txt", "README.md"}, nil},
+		{"unmatched", []string{"src/*.rs", " ", "README.md"}, []string{"README.md"}, []string{"src/*.rs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []string
+			for _, p := range tt.want {
+				want = append(want, abs(p))
+			}
+			got, unmatched := SelectFiles(cp, tt.patterns, root)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("selected %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(unmatched, tt.unmatched) {
+				t.Errorf("unmatched %v, want %v", unmatched, tt.unmatched)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package checkpoint
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+const (
+	// SnapshotInterval is how often creating a checkpoint also snapshots
+	// the store
+	SnapshotInterval = 24 * time.Hour
+
+	// SnapshotKeep is how many snapshots are kept; older ones are removed
+	SnapshotKeep = 7
+
+	snapshotPrefix = "snapshot-"
+	snapshotExt    = ".tar.zst"
+	snapshotLayout = "2006-01-02T150405"
+)
+
+// Snapshot is a record of the store, the index and every manifest, taken
+// at some time. Backups are not in it: it is small, and tells what existed
+// and what was lost even if the backups themselves are damaged.
+type Snapshot struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// SnapshotsDir returns where snapshots are written: snapshot_dir in
+// config, or snapshots in the safeshell directory
+func (s *Store) SnapshotsDir() string {
+	if cfg := config.Get(); cfg != nil && cfg.SnapshotDir != "" {
+		return cfg.SnapshotDir
+	}
+	return filepath.Join(s.dir, "snapshots")
+}
+
+// Snapshots returns the snapshots in SnapshotsDir, newest first
+func (s *Store) Snapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.SnapshotsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), snapshotPrefix)
+		if !ok || !strings.HasSuffix(stamp, snapshotExt) {
+			continue
+		}
+		t, err := time.ParseInLocation(snapshotLayout, strings.TrimSuffix(stamp, snapshotExt), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Path: filepath.Join(s.SnapshotsDir(), e.Name()), Time: t, Size: info.Size()})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.After(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// TakeSnapshot writes the index and every manifest to a zstd-compressed tar
+// in SnapshotsDir, then removes all but the newest SnapshotKeep snapshots
+func (s *Store) TakeSnapshot() (Snapshot, error) {
+	dir := s.SnapshotsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, snapshotPrefix+now.Format(snapshotLayout)+snapshotExt)
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.writeSnapshot(tmp); err != nil {
+		tmp.Close()
+		return Snapshot{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return Snapshot{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshots, err := s.Snapshots()
+	if err == nil && len(snapshots) > SnapshotKeep {
+		for _, old := range snapshots[SnapshotKeep:] {
+			os.Remove(old.Path)
+		}
+	}
+	return Snapshot{Path: path, Time: now, Size: info.Size()}, nil
+}
+
+// writeSnapshot writes the snapshot archive to w
+func (s *Store) writeSnapshot(w io.Writer) error {
+	zw, err := newCompressWriter(w, CompressionZstd, 0)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	add := func(name, path string) error {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	if err := add(".index.json", s.Index().path()); err != nil {
+		return err
+	}
+	if err := add(".paths.jsonl", pathIndexPath(s.CheckpointsDir())); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.CheckpointsDir())
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := add(e.Name()+"/manifest.json", filepath.Join(s.CheckpointsDir(), e.Name(), "manifest.json")); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// SnapshotIfDue takes a snapshot if snapshots are on and the newest is
+// older than SnapshotInterval. It reports whether it took one.
+func (s *Store) SnapshotIfDue() (bool, error) {
+	if cfg := config.Get(); cfg == nil || !cfg.Snapshots {
+		return false, nil
+	}
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return false, err
+	}
+	if len(snapshots) > 0 && time.Since(snapshots[0].Time) < SnapshotInterval {
+		return false, nil
+	}
+	_, err = s.TakeSnapshot()
+	return err == nil, err
+}
+
+// RestoreSnapshot brings back the manifests in a snapshot of checkpoints
+// whose manifest is missing or can't be read, and rebuilds the index. Their
+// backups are not in the snapshot: Verify tells which are lost. It returns
+// the IDs of the checkpoints brought back.
+func (s *Store) RestoreSnapshot(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := newDecompressReader(f, CompressionZstd)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var restored []string
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		id, ok := strings.CutSuffix(header.Name, "/manifest.json")
+		if !ok || header.Typeflag != tar.TypeReg || id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+			continue
+		}
+		dir := s.checkpointDir(id)
+		if _, err := LoadManifest(dir); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return restored, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return restored, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644); err != nil {
+			return restored, err
+		}
+		restored = append(restored, id)
+	}
+
+	if len(restored) > 0 {
+		if err := s.Index().Rebuild(); err != nil {
+			return restored, err
+		}
+	}
+	return restored, nil
+}
+
+// Snapshots returns the snapshots of the default store, newest first
+func Snapshots() ([]Snapshot, error) {
+	return DefaultStore().Snapshots()
+}
+
+// TakeSnapshot snapshots the default store
+func TakeSnapshot() (Snapshot, error) {
+	return DefaultStore().TakeSnapshot()
+}
+
+// RestoreSnapshot brings back the default store's lost manifests from a
+// snapshot
+func RestoreSnapshot(path string) ([]string, error) {
+	return DefaultStore().RestoreSnapshot(path)
+}
@@ -0,0 +1,53 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "testdata", "file.txt")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	os.WriteFile(file, []byte("content"), 0644)
+
+	// Creating a checkpoint snapshots the store once a day
+	cp, err := Create("test", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if snapshots, err := Snapshots(); err != nil || len(snapshots) == 0 {
+		t.Fatalf("Expected a snapshot, got %v", err)
+	}
+	snapshot, err := TakeSnapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if took, err := DefaultStore().SnapshotIfDue(); took || err != nil {
+		t.Errorf("Expected no snapshot to be due, got %v, %v", took, err)
+	}
+
+	// A lost manifest comes back; one still there is left alone
+	if err := os.Remove(filepath.Join(cp.Dir, "manifest.json")); err != nil {
+		t.Fatal(err)
+	}
+	DefaultStore().Index().Rebuild()
+	restored, err := RestoreSnapshot(snapshot.Path)
+	if err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	if !slices.Contains(restored, cp.ID) {
+		t.Errorf("Expected %s to be restored, got %v", cp.ID, restored)
+	}
+	loaded, err := Get(cp.ID)
+	if err != nil || loaded.Manifest.Command != "test" {
+		t.Errorf("Expected the checkpoint back, got %v", err)
+	}
+	if DefaultStore().Index().GetEntry(cp.ID) == nil {
+		t.Error("Expected the checkpoint back in the index")
+	}
+}
@@ -3,13 +3,17 @@ package checkpoint
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/qhkm/safeshell/internal/config"
+	"golang.org/x/sync/errgroup"
 )
 
 // DefaultExclusions contains directory names that are excluded by default.
@@ -56,15 +60,142 @@ var DefaultExclusions = []string{
 
 // shouldExclude checks if a path should be excluded from backup
 func shouldExclude(path string) bool {
+	// Protected paths are always backed up
+	if config.IsProtectedPath(path) {
+		return false
+	}
+
 	base := filepath.Base(path)
 	for _, excluded := range DefaultExclusions {
-		if base == excluded {
+		if base == excluded && !DefaultExclusionDisabled(excluded) {
+			return true
+		}
+	}
+	for _, pattern := range config.Get().ExcludePaths {
+		if matchExcludePattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultExclusionDisabled reports whether name, one of DefaultExclusions,
+// is listed in disabled_exclusions, so it is backed up like anything else.
+// SafeShell's own directory is always excluded.
+func DefaultExclusionDisabled(name string) bool {
+	if name == ".safeshell" {
+		return false
+	}
+	for _, disabled := range config.Get().DisabledExclusions {
+		if disabled == name {
 			return true
 		}
 	}
 	return false
 }
 
+// matchExcludePattern reports whether path matches an exclude_paths
+// pattern. A pattern with ** or starting with / or ~/ is matched against
+// the full path, ** spanning any number of directories: "src/**/*.pb.go",
+// "~/work/**/fixtures". Otherwise "dir/*" matches directories whose path
+// ends in dir, such as .git/objects, along with everything in them, and
+// other patterns are matched against the file name.
+func matchExcludePattern(pattern, path string) bool {
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			pattern = filepath.Join(home, rest)
+		}
+	}
+	pattern = filepath.ToSlash(pattern)
+	if strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "**") {
+		if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "**/") {
+			pattern = "**/" + pattern
+		}
+		return matchGlob(pattern, path)
+	}
+	dir, ok := strings.CutSuffix(pattern, "/*")
+	if !ok {
+		matched, _ := filepath.Match(pattern, filepath.Base(path))
+		return matched
+	}
+
+	parts := strings.Split(dir, "/")
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	if len(segments) < len(parts) {
+		return false
+	}
+	segments = segments[len(segments)-len(parts):]
+	for i, part := range parts {
+		if matched, _ := filepath.Match(part, segments[i]); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// backupFilter is what a checkpoint leaves out besides exclusions: files
+// not matching its include patterns, with respect_gitignore those git
+// ignores, and with git_aware tracked files with no changes
+type backupFilter struct {
+	include []string
+	git     *gitIgnores
+	clean   *gitClean
+}
+
+func newBackupFilter(include []string) *backupFilter {
+	f := &backupFilter{include: include}
+	if config.Get().RespectGitignore {
+		f.git = newGitIgnores()
+	}
+	if config.Get().GitAware {
+		f.clean = newGitClean()
+	}
+	return f
+}
+
+// skips reports whether path, a directory if isDir, is left out. Protected
+// paths never are.
+func (f *backupFilter) skips(path string, isDir bool) bool {
+	if f == nil || config.IsProtectedPath(path) {
+		return false
+	}
+	if f.git != nil && f.git.ignores(path, isDir) {
+		return true
+	}
+	if isDir {
+		return false
+	}
+	return !included(f.include, path) || f.clean != nil && f.clean.skips(path)
+}
+
+// gitStates returns the tracked files left out with git_aware
+func (f *backupFilter) gitStates() []GitState {
+	if f.clean == nil {
+		return nil
+	}
+	return f.clean.states()
+}
+
+// included reports whether a file is backed up under include patterns:
+// any file if there are none, otherwise one matching a pattern or in a
+// directory that does
+func included(include []string, path string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		for p := path; ; p = filepath.Dir(p) {
+			if matchExcludePattern(pattern, p) {
+				return true
+			}
+			if filepath.Dir(p) == p {
+				break
+			}
+		}
+	}
+	return false
+}
+
 // isSymlink checks if a path is a symbolic link
 func isSymlink(path string) bool {
 	info, err := os.Lstat(path)
@@ -145,6 +276,11 @@ func CheckFileSize(path string) (bool, int64, int) {
 		return false, 0, 0
 	}
 
+	// Protected paths are never skipped for size
+	if config.IsProtectedPath(path) {
+		return false, 0, cfg.MaxFileSizeMB
+	}
+
 	info, err := os.Stat(path)
 	if err != nil {
 		return false, 0, cfg.MaxFileSizeMB
@@ -156,13 +292,13 @@ func CheckFileSize(path string) (bool, int64, int) {
 
 // CheckTotalStorage checks if current storage exceeds the limit
 // Returns (exceedsLimit, currentMB, limitMB)
-func CheckTotalStorage() (bool, int64, int) {
+func (s *Store) CheckTotalStorage() (bool, int64, int) {
 	cfg := config.Get()
 	if cfg == nil || cfg.MaxStorageMB <= 0 {
 		return false, 0, 0
 	}
 
-	currentSize, err := GetDiskUsage(config.GetCheckpointsDir())
+	currentSize, err := GetDiskUsage(s.CheckpointsDir())
 	if err != nil {
 		return false, 0, cfg.MaxStorageMB
 	}
@@ -221,15 +357,28 @@ func ValidatePath(path string) error {
 	return nil
 }
 
-// BackupFile creates a backup of a file using hard links when possible.
-// Falls back to copy if hard link fails (e.g., cross-filesystem).
+// BackupFile creates a backup of a file as a copy-on-write clone where the
+// filesystem supports it, which later writes to the file can't reach, or
+// else using hard links when possible. Falls back to copy if hard link
+// fails (e.g., cross-filesystem).
+// Transient filesystem errors are retried.
 func BackupFile(srcPath, dstPath string) error {
+	return withRetry(func() error { return backupFile(srcPath, dstPath) })
+}
+
+func backupFile(srcPath, dstPath string) error {
 	// Ensure destination directory exists
 	dstDir := filepath.Dir(dstPath)
 	if err := os.MkdirAll(dstDir, 0755); err != nil {
 		return fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
+	// A clone takes no extra space either, and unlike a hard link, later
+	// writes to the file don't reach it
+	if info, err := os.Stat(srcPath); err == nil && tryClone(srcPath, dstPath, info) == nil {
+		return finishCopy(srcPath, dstPath, info.Mode())
+	}
+
 	// Try hard link first (efficient, no extra disk space)
 	err := os.Link(srcPath, dstPath)
 	if err == nil {
@@ -240,6 +389,17 @@ func BackupFile(srcPath, dstPath string) error {
 	return copyFile(srcPath, dstPath)
 }
 
+// backupCopy backs up a file as a copy-on-write clone or a copy, never a
+// hard link: a command writing to the file in place, like truncate or a
+// shell redirection, would change a hard link's backup along with it.
+// Transient filesystem errors are retried.
+func backupCopy(srcPath, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return withRetry(func() error { return copyFile(srcPath, dstPath) })
+}
+
 // copyBufferSize is 32KB - optimal for most filesystems
 const copyBufferSize = 32 * 1024
 
@@ -275,26 +435,99 @@ func copyFile(src, dst string) error {
 		return fmt.Errorf("failed to stat source file: %w", err)
 	}
 
-	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, srcInfo.Mode())
+	// A copy-on-write clone is instant and takes no space until one of the
+	// files changes, where the filesystem supports it: APFS, btrfs, XFS
+	if err := tryClone(src, dst, srcInfo); err != nil {
+		if err := writeCopy(srcFile, dst, srcInfo.Mode()); err != nil {
+			return err
+		}
+	}
+
+	return finishCopy(src, dst, srcInfo.Mode())
+}
+
+// finishCopy gives dst, a copy or clone of src, the mode and metadata of src
+func finishCopy(src, dst string, mode os.FileMode) error {
+	// OpenFile applies the umask and leaves an existing file's mode alone.
+	// Set the mode after writing, which clears setuid and setgid.
+	if err := os.Chmod(dst, mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+
+	return copyMetadata(src, dst)
+}
+
+// writeCopy writes the content of src to dst, creating or truncating it.
+// Between files, io.Copy uses copy_file_range on Linux, which copies in the
+// kernel and clones where it can, e.g. on NFS.
+func writeCopy(src *os.File, dst string, mode os.FileMode) error {
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer dstFile.Close()
 
-	// Use buffered copy for better performance
+	// The buffer is only used when the kernel can't copy
 	buf := getCopyBuffer()
 	defer putCopyBuffer(buf)
 
-	if _, err := io.CopyBuffer(dstFile, srcFile, buf); err != nil {
+	if _, err := io.CopyBuffer(dstFile, src, buf); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := dstFile.Close(); err != nil {
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
-
 	return nil
 }
 
 // BackupDir recursively backs up a directory, skipping excluded paths and symlinks
 func BackupDir(srcPath, dstPath string) error {
-	return filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+	return backupDir(context.Background(), srcPath, dstPath, nil, nil, nil, false)
+}
+
+// maxBackupWorkers caps the default number of backup workers: beyond it,
+// the disk rather than the CPU is what limits
+const maxBackupWorkers = 8
+
+// backupWorkers returns how many files backupDir backs up at once:
+// backup_workers, or one per CPU up to maxBackupWorkers if it is 0
+func backupWorkers() int {
+	if cfg := config.Get(); cfg != nil && cfg.BackupWorkers > 0 {
+		return cfg.BackupWorkers
+	}
+	return min(runtime.NumCPU(), maxBackupWorkers)
+}
+
+// backupDir is BackupDir, also skipping what filter leaves out, counting
+// the files backed up in counter and recording them in journal, or reusing
+// its backups from before. Directories are created as the walk finds them,
+// and the files in them backed up by backupWorkers goroutines. It stops
+// when ctx is done. With copies, files are backed up with backupCopy.
+func backupDir(ctx context.Context, srcPath, dstPath string, filter *backupFilter, counter *progressCounter, journal *createJournal, copies bool) error {
+	var dirs dirModes
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(backupWorkers())
+	backup := func(fn func(string, string) error, path, targetPath string, info os.FileInfo) error {
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil // The walk reports why
+			}
+			if !journal.reuse(path, info, targetPath) {
+				if err := fn(path, targetPath); err != nil {
+					return err
+				}
+				journal.record(path, info)
+			}
+			counter.add(path, info.Size())
+			return nil
+		})
+		return nil
+	}
+	walkErr := filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+		// A file failed to back up, or backing up was stopped
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			// Skip permission errors gracefully
 			if os.IsPermission(err) {
@@ -320,16 +553,45 @@ func BackupDir(srcPath, dstPath string) error {
 
 		targetPath := filepath.Join(dstPath, relPath)
 
+		if filter.skips(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if info.IsDir() {
-			return os.MkdirAll(targetPath, info.Mode())
+			return dirs.mkdir(targetPath, info.Mode())
+		}
+		if cloudOnly(info) {
+			if hydratePlaceholders() {
+				return backup(hydrateFile, path, targetPath, info)
+			}
+			return nil
 		}
 
-		return BackupFile(path, targetPath)
+		if copies {
+			return backup(backupCopy, path, targetPath, info)
+		}
+		return backup(BackupFile, path, targetPath, info)
 	})
+	// Wait for the files even if the walk failed, before directories
+	// are made read-only
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	return dirs.apply()
 }
 
-// RestoreFile restores a file from backup to its original location
+// RestoreFile restores a file from backup to its original location,
+// retrying transient filesystem errors
 func RestoreFile(backupPath, originalPath string) error {
+	return withRetry(func() error { return restoreFile(backupPath, originalPath) })
+}
+
+func restoreFile(backupPath, originalPath string) error {
 	// Ensure original directory exists
 	originalDir := filepath.Dir(originalPath)
 	if err := os.MkdirAll(originalDir, 0755); err != nil {
@@ -347,9 +609,11 @@ func RestoreFile(backupPath, originalPath string) error {
 	return copyFile(backupPath, originalPath)
 }
 
-// RestoreDir restores a directory from backup
+// RestoreDir restores a directory from backup, including the permissions of
+// every directory in it
 func RestoreDir(backupPath, originalPath string) error {
-	return filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
+	var dirs dirModes
+	err := filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -362,11 +626,49 @@ func RestoreDir(backupPath, originalPath string) error {
 		targetPath := filepath.Join(originalPath, relPath)
 
 		if info.IsDir() {
-			return os.MkdirAll(targetPath, info.Mode())
+			return dirs.mkdir(targetPath, info.Mode())
 		}
 
 		return RestoreFile(path, targetPath)
 	})
+	if err != nil {
+		return err
+	}
+	return dirs.apply()
+}
+
+// SetDirMode sets a directory's permissions, including the setuid, setgid
+// and sticky bits. Unlike Mkdir, Chmod ignores the umask and keeps setgid.
+func SetDirMode(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+}
+
+// dirModes records the directories created while copying a tree. They are
+// created writable and get their real mode only once the copy is done, so a
+// read-only directory can't block its own contents.
+type dirModes []dirMode
+
+type dirMode struct {
+	path string
+	mode os.FileMode
+}
+
+func (d *dirModes) mkdir(path string, mode os.FileMode) error {
+	if err := os.MkdirAll(path, mode.Perm()|0700); err != nil {
+		return err
+	}
+	*d = append(*d, dirMode{path, mode})
+	return nil
+}
+
+// apply sets the recorded modes, children before their parents
+func (d dirModes) apply() error {
+	for i := len(d) - 1; i >= 0; i-- {
+		if err := SetDirMode(d[i].path, d[i].mode); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %w", d[i].path, err)
+		}
+	}
+	return nil
 }
 
 // GetDiskUsage returns the total size of a directory
@@ -384,8 +686,100 @@ func GetDiskUsage(path string) (int64, error) {
 	return size, err
 }
 
-// CompressDir compresses a directory into a .tar.gz file and removes the original
-func CompressDir(srcDir, archivePath string) (int64, error) {
+// Compression algorithms for checkpoint archives
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionNone = "none"
+)
+
+// CompressionAlgorithms lists the supported compression algorithms
+var CompressionAlgorithms = []string{CompressionGzip, CompressionZstd, CompressionNone}
+
+// ValidateCompression checks that an algorithm and level are supported.
+// A level of 0 selects the algorithm's default.
+func ValidateCompression(algorithm string, level int) error {
+	switch algorithm {
+	case CompressionGzip:
+		if level != 0 && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+			return fmt.Errorf("gzip compression level must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+		}
+	case CompressionZstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("zstd compression level must be between 1 and 22")
+		}
+	case CompressionNone:
+	default:
+		return fmt.Errorf("unknown compression algorithm: %s (use %s)", algorithm, strings.Join(CompressionAlgorithms, ", "))
+	}
+	return nil
+}
+
+// archiveName returns the archive file name for an algorithm.
+// An empty algorithm means gzip, which is what older checkpoints used.
+func archiveName(algorithm string) string {
+	switch algorithm {
+	case CompressionZstd:
+		return "files.tar.zst"
+	case CompressionNone:
+		return "files.tar"
+	default:
+		return "files.tar.gz"
+	}
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressWriter wraps w with the compressor for algorithm
+func newCompressWriter(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch algorithm {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionZstd:
+		encLevel := zstd.SpeedDefault
+		if level != 0 {
+			encLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(encLevel))
+	default:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// newDecompressReader wraps r with the decompressor for algorithm
+func newDecompressReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	default:
+		return gzip.NewReader(r)
+	}
+}
+
+// CompressDir archives a directory using the given algorithm and level and removes the original
+func CompressDir(srcDir, archivePath, algorithm string, level int) (int64, error) {
+	return compressDir(context.Background(), srcDir, archivePath, algorithm, level, nil)
+}
+
+// compressDir is CompressDir, counting the files archived in counter. When
+// ctx is done, it stops and removes the partial archive.
+func compressDir(ctx context.Context, srcDir, archivePath, algorithm string, level int, counter *progressCounter) (int64, error) {
+	if err := ValidateCompression(algorithm, level); err != nil {
+		return 0, err
+	}
+
 	// Create the archive file
 	archiveFile, err := os.Create(archivePath)
 	if err != nil {
@@ -393,19 +787,61 @@ func CompressDir(srcDir, archivePath string) (int64, error) {
 	}
 	defer archiveFile.Close()
 
-	// Create gzip writer
-	gzWriter := gzip.NewWriter(archiveFile)
-	defer gzWriter.Close()
+	// Create compression writer
+	compWriter, err := newCompressWriter(archiveFile, algorithm, level)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s writer: %w", algorithm, err)
+	}
+	defer compWriter.Close()
 
 	// Create tar writer
-	tarWriter := tar.NewWriter(gzWriter)
+	tarWriter := tar.NewWriter(compWriter)
 	defer tarWriter.Close()
 
 	// Walk the source directory and add files to archive
-	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+	if err := writeTar(ctx, tarWriter, srcDir, counter); err != nil {
+		if ctx.Err() != nil {
+			archiveFile.Close()
+			os.Remove(archivePath)
+			return 0, interrupted(ctx)
+		}
+		return 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	// Close writers to flush data
+	if err := tarWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := compWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	archiveFile.Close()
+
+	// Get compressed size
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	compressedSize := info.Size()
+
+	// Remove original directory
+	if err := os.RemoveAll(srcDir); err != nil {
+		return compressedSize, fmt.Errorf("failed to remove original directory: %w", err)
+	}
+
+	return compressedSize, nil
+}
+
+// writeTar adds the contents of srcDir to tarWriter, with paths relative to
+// srcDir, counting the files added in counter, until ctx is done
+func writeTar(ctx context.Context, tarWriter *tar.Writer, srcDir string, counter *progressCounter) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
 		// Get relative path
 		relPath, err := filepath.Rel(srcDir, path)
@@ -424,6 +860,11 @@ func CompressDir(srcDir, archivePath string) (int64, error) {
 			return err
 		}
 		header.Name = relPath
+		if !info.IsDir() {
+			if header.PAXRecords, err = metadataRecords(path); err != nil {
+				return err
+			}
+		}
 
 		// Write header
 		if err := tarWriter.WriteHeader(header); err != nil {
@@ -441,60 +882,75 @@ func CompressDir(srcDir, archivePath string) (int64, error) {
 			if _, err := io.Copy(tarWriter, file); err != nil {
 				return err
 			}
+			counter.add(path, info.Size())
 		}
 
 		return nil
 	})
+}
 
+// DecompressDir extracts an archive created with the given algorithm into a directory
+func DecompressDir(archivePath, dstDir, algorithm string) error {
+	// Open archive file
+	archiveFile, err := os.Open(archivePath)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create archive: %w", err)
+		return fmt.Errorf("failed to open archive: %w", err)
 	}
+	defer archiveFile.Close()
 
-	// Close writers to flush data
-	tarWriter.Close()
-	gzWriter.Close()
-	archiveFile.Close()
-
-	// Get compressed size
-	info, err := os.Stat(archivePath)
+	// Create decompression reader
+	compReader, err := newDecompressReader(archiveFile, algorithm)
 	if err != nil {
-		return 0, err
-	}
-	compressedSize := info.Size()
-
-	// Remove original directory
-	if err := os.RemoveAll(srcDir); err != nil {
-		return compressedSize, fmt.Errorf("failed to remove original directory: %w", err)
+		return fmt.Errorf("failed to create decompression reader: %w", err)
 	}
+	defer compReader.Close()
 
-	return compressedSize, nil
+	return extractTar(tar.NewReader(compReader), dstDir)
 }
 
-// DecompressDir decompresses a .tar.gz file into a directory
-func DecompressDir(archivePath, dstDir string) error {
-	// Open archive file
+// ExtractArchiveFile copies a single file from an archive to w, without
+// extracting anything else. name is relative to the archived directory.
+// Returns os.ErrNotExist if the archive has no such file.
+func ExtractArchiveFile(archivePath, algorithm, name string, w io.Writer) error {
 	archiveFile, err := os.Open(archivePath)
 	if err != nil {
 		return fmt.Errorf("failed to open archive: %w", err)
 	}
 	defer archiveFile.Close()
 
-	// Create gzip reader
-	gzReader, err := gzip.NewReader(archiveFile)
+	compReader, err := newDecompressReader(archiveFile, algorithm)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return fmt.Errorf("failed to create decompression reader: %w", err)
 	}
-	defer gzReader.Close()
+	defer compReader.Close()
 
-	// Create tar reader
-	tarReader := tar.NewReader(gzReader)
+	name = filepath.ToSlash(filepath.Clean(name))
+	tarReader := tar.NewReader(compReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return os.ErrNotExist
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
 
+		if header.Typeflag == tar.TypeReg && filepath.ToSlash(filepath.Clean(header.Name)) == name {
+			_, err := io.Copy(w, tarReader)
+			return err
+		}
+	}
+}
+
+// extractTar extracts all entries of tarReader into dstDir
+func extractTar(tarReader *tar.Reader, dstDir string) error {
 	// Ensure destination directory exists
 	if err := os.MkdirAll(dstDir, 0755); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
 	// Extract files
+	var dirs dirModes
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -512,7 +968,7 @@ func DecompressDir(archivePath, dstDir string) error {
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
+			if err := dirs.mkdir(targetPath, header.FileInfo().Mode()); err != nil {
 				return fmt.Errorf("failed to create directory: %w", err)
 			}
 		case tar.TypeReg:
@@ -532,22 +988,29 @@ func DecompressDir(archivePath, dstDir string) error {
 				return fmt.Errorf("failed to write file: %w", err)
 			}
 			file.Close()
+
+			if err := applyMetadataRecords(targetPath, header.PAXRecords); err != nil {
+				return err
+			}
 		}
 	}
 
-	return nil
+	return dirs.apply()
 }
 
 // IsCompressed checks if a checkpoint directory has been compressed
 func IsCompressed(checkpointDir string) bool {
-	archivePath := filepath.Join(checkpointDir, "files.tar.gz")
-	_, err := os.Stat(archivePath)
-	return err == nil
+	for _, algorithm := range CompressionAlgorithms {
+		if _, err := os.Stat(GetArchivePath(checkpointDir, algorithm)); err == nil {
+			return true
+		}
+	}
+	return false
 }
 
-// GetArchivePath returns the path to the compressed archive
-func GetArchivePath(checkpointDir string) string {
-	return filepath.Join(checkpointDir, "files.tar.gz")
+// GetArchivePath returns the path to the archive created with algorithm
+func GetArchivePath(checkpointDir, algorithm string) string {
+	return filepath.Join(checkpointDir, archiveName(algorithm))
 }
 
 // GetFilesDir returns the path to the files directory
@@ -1,9 +1,12 @@
 package checkpoint
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
 )
 
 func TestBackupFile(t *testing.T) {
@@ -516,3 +519,199 @@ func TestEmptyDirectoryBackup(t *testing.T) {
 		t.Error("Destination directory should exist")
 	}
 }
+
+func TestCompressDirAlgorithms(t *testing.T) {
+	for _, algorithm := range CompressionAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "files")
+			os.MkdirAll(filepath.Join(srcDir, "sub"), 0755)
+			os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("file a"), 0644)
+			os.WriteFile(filepath.Join(srcDir, "sub", "b.txt"), []byte("file b"), 0600)
+
+			archivePath := GetArchivePath(tmpDir, algorithm)
+			if _, err := CompressDir(srcDir, archivePath, algorithm, 0); err != nil {
+				t.Fatalf("CompressDir failed: %v", err)
+			}
+			if _, err := os.Stat(srcDir); !os.IsNotExist(err) {
+				t.Error("Source directory should be removed after compression")
+			}
+			if !IsCompressed(tmpDir) {
+				t.Error("IsCompressed should detect the archive")
+			}
+
+			if err := DecompressDir(archivePath, srcDir, algorithm); err != nil {
+				t.Fatalf("DecompressDir failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(srcDir, "sub", "b.txt"))
+			if err != nil || string(content) != "file b" {
+				t.Errorf("Expected restored content 'file b', got '%s' (%v)", content, err)
+			}
+			info, _ := os.Stat(filepath.Join(srcDir, "sub", "b.txt"))
+			if info != nil && info.Mode().Perm() != 0600 {
+				t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestDecompressLegacyGzipArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "files")
+	os.MkdirAll(srcDir, 0755)
+	os.WriteFile(filepath.Join(srcDir, "old.txt"), []byte("legacy"), 0644)
+
+	// Archives from before the algorithm was recorded are files.tar.gz
+	archivePath := filepath.Join(tmpDir, "files.tar.gz")
+	if _, err := CompressDir(srcDir, archivePath, CompressionGzip, 0); err != nil {
+		t.Fatalf("CompressDir failed: %v", err)
+	}
+
+	if GetArchivePath(tmpDir, "") != archivePath {
+		t.Errorf("Empty algorithm should map to %s, got %s", archivePath, GetArchivePath(tmpDir, ""))
+	}
+	if err := DecompressDir(GetArchivePath(tmpDir, ""), srcDir, ""); err != nil {
+		t.Fatalf("DecompressDir failed on legacy archive: %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(srcDir, "old.txt"))
+	if string(content) != "legacy" {
+		t.Errorf("Expected 'legacy', got '%s'", content)
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	if err := ValidateCompression("brotli", 0); err == nil {
+		t.Error("Unknown algorithm should be rejected")
+	}
+	if err := ValidateCompression(CompressionGzip, 10); err == nil {
+		t.Error("gzip level 10 should be rejected")
+	}
+	if err := ValidateCompression(CompressionZstd, 19); err != nil {
+		t.Errorf("zstd level 19 should be accepted: %v", err)
+	}
+}
+
+// makeModeTree creates a tree whose directories use setgid, sticky and
+// read-only modes, which Mkdir alone can't reproduce
+func makeModeTree(t *testing.T, root string) map[string]os.FileMode {
+	t.Helper()
+	modes := map[string]os.FileMode{
+		"shared":          0775 | os.ModeSetgid,
+		"shared/tmp":      0777 | os.ModeSticky,
+		"shared/readonly": 0555,
+	}
+	for _, dir := range []string{"shared", "shared/tmp", "shared/readonly"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+		os.WriteFile(filepath.Join(root, dir, "file.txt"), []byte(dir), 0644)
+	}
+	for _, dir := range []string{"shared/readonly", "shared/tmp", "shared"} {
+		if err := SetDirMode(filepath.Join(root, dir), modes[dir]); err != nil {
+			t.Fatalf("Failed to set mode of %s: %v", dir, err)
+		}
+	}
+	t.Cleanup(func() {
+		// Let the temp dir cleanup remove read-only directories
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				os.Chmod(path, 0755)
+			}
+			return nil
+		})
+	})
+	return modes
+}
+
+func checkDirModes(t *testing.T, root string, modes map[string]os.FileMode) {
+	t.Helper()
+	for dir, want := range modes {
+		info, err := os.Stat(filepath.Join(root, dir))
+		if err != nil {
+			t.Errorf("%s should exist: %v", dir, err)
+			continue
+		}
+		if got := info.Mode() &^ os.ModeDir; got != want {
+			t.Errorf("%s: expected mode %v, got %v", dir, want, got)
+		}
+		if _, err := os.Stat(filepath.Join(root, dir, "file.txt")); err != nil {
+			t.Errorf("%s/file.txt should exist: %v", dir, err)
+		}
+	}
+}
+
+func TestRestoreDirPreservesModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "source")
+	modes := makeModeTree(t, srcDir)
+
+	backupDir := filepath.Join(tmpDir, "backup")
+	if err := BackupDir(srcDir, backupDir); err != nil {
+		t.Fatalf("BackupDir failed: %v", err)
+	}
+	checkDirModes(t, backupDir, modes)
+
+	restoreDir := filepath.Join(tmpDir, "restored")
+	t.Cleanup(func() { os.Chmod(filepath.Join(restoreDir, "shared", "readonly"), 0755) })
+	if err := RestoreDir(backupDir, restoreDir); err != nil {
+		t.Fatalf("RestoreDir failed: %v", err)
+	}
+	checkDirModes(t, restoreDir, modes)
+}
+
+func TestDecompressPreservesDirModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "files")
+	modes := makeModeTree(t, srcDir)
+
+	archivePath := GetArchivePath(tmpDir, "zstd")
+	if _, err := CompressDir(srcDir, archivePath, "zstd", 0); err != nil {
+		t.Fatalf("CompressDir failed: %v", err)
+	}
+	if err := DecompressDir(archivePath, srcDir, "zstd"); err != nil {
+		t.Fatalf("DecompressDir failed: %v", err)
+	}
+	checkDirModes(t, srcDir, modes)
+}
+
+func TestBackupDirWorkers(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	defer func() { config.Get().BackupWorkers = 0 }()
+
+	srcDir := filepath.Join(tmpDir, "testdata", "many")
+	for i := 0; i < 200; i++ {
+		dir := filepath.Join(srcDir, fmt.Sprintf("dir%d", i%10))
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte(fmt.Sprint(i)), 0644)
+	}
+	// Read-only directories get their mode once every file is in
+	os.Chmod(filepath.Join(srcDir, "dir3"), 0555)
+	defer os.Chmod(filepath.Join(srcDir, "dir3"), 0755)
+
+	for _, workers := range []int{1, 4} {
+		config.Get().BackupWorkers = workers
+		dstDir := filepath.Join(tmpDir, fmt.Sprintf("backup%d", workers))
+		if err := BackupDir(srcDir, dstDir); err != nil {
+			t.Fatalf("BackupDir with %d workers failed: %v", workers, err)
+		}
+		for i := 0; i < 200; i++ {
+			path := filepath.Join(dstDir, fmt.Sprintf("dir%d", i%10), fmt.Sprintf("file%d.txt", i))
+			if content, err := os.ReadFile(path); err != nil || string(content) != fmt.Sprint(i) {
+				t.Errorf("Expected file%d.txt backed up with %d workers, got %q, %v", i, workers, content, err)
+			}
+		}
+		if info, err := os.Stat(filepath.Join(dstDir, "dir3")); err != nil || info.Mode().Perm() != 0555 {
+			t.Errorf("Expected dir3 read-only in the backup, got %v", err)
+		}
+		os.Chmod(filepath.Join(dstDir, "dir3"), 0755)
+	}
+
+	// A file that can't be backed up fails the backup
+	config.Get().BackupWorkers = 4
+	dstDir := filepath.Join(tmpDir, "failed")
+	os.MkdirAll(filepath.Join(dstDir, "dir7", "file7.txt", "in-the-way"), 0755)
+	if err := BackupDir(srcDir, dstDir); err == nil {
+		t.Error("Expected BackupDir to fail")
+	}
+}
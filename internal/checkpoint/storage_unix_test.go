@@ -0,0 +1,96 @@
+//go:build !windows
+
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// withUmask sets the process umask until the test ends
+func withUmask(t *testing.T, mask int) {
+	old := syscall.Umask(mask)
+	t.Cleanup(func() { syscall.Umask(old) })
+}
+
+func TestCopyFileIgnoresUmask(t *testing.T) {
+	withUmask(t, 077)
+	tmpDir := t.TempDir()
+
+	src := filepath.Join(tmpDir, "tool.sh")
+	os.WriteFile(src, []byte("#!/bin/sh\n"), 0755)
+	want := 0755 | os.ModeSetgid
+	os.Chmod(src, want)
+
+	dst := filepath.Join(tmpDir, "copy.sh")
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile failed: %v", err)
+	}
+	if info, _ := os.Stat(dst); info.Mode() != want {
+		t.Errorf("Expected mode %v, got %v", want, info.Mode())
+	}
+
+	// An existing destination takes the source's mode too
+	os.Chmod(dst, 0600)
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile failed: %v", err)
+	}
+	if info, _ := os.Stat(dst); info.Mode() != want {
+		t.Errorf("Expected mode %v after overwrite, got %v", want, info.Mode())
+	}
+}
+
+func TestCloneFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.txt")
+	os.WriteFile(src, []byte("content"), 0644)
+
+	// Either a clone with the same content, or nothing, for copyFile to copy
+	dst := filepath.Join(tmpDir, "clone.txt")
+	if err := cloneFile(src, dst, 0644); err == nil {
+		if content, _ := os.ReadFile(dst); string(content) != "content" {
+			t.Errorf("Expected the clone to hold the content, got %q", content)
+		}
+	} else if _, statErr := os.Lstat(dst); !os.IsNotExist(statErr) {
+		t.Errorf("Expected a failed clone (%v) to leave nothing, got %v", err, statErr)
+	}
+
+	// An existing destination is left alone
+	existing := filepath.Join(tmpDir, "existing.txt")
+	os.WriteFile(existing, []byte("kept"), 0644)
+	if err := cloneFile(src, existing, 0644); err == nil {
+		t.Error("Expected cloning over an existing file to fail")
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "kept" {
+		t.Errorf("Expected the existing file kept, got %q", content)
+	}
+}
+
+func TestTryCloneRemembersUnsupported(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.txt")
+	os.WriteFile(src, []byte("content"), 0644)
+	info, _ := os.Stat(src)
+	dir, _ := os.Stat(tmpDir)
+	pair := devicePair{device(info), device(dir)}
+	cloneUnsupported.Delete(pair)
+	t.Cleanup(func() { cloneUnsupported.Delete(pair) })
+
+	// Where the filesystem can't clone, as on ext4 or tmpfs, it is remembered
+	err := tryClone(src, filepath.Join(tmpDir, "clone.txt"), info)
+	if _, known := cloneUnsupported.Load(pair); known != errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected the devices remembered only when unsupported, got %v", err)
+	}
+
+	// And no clone is attempted again: one over an existing file would fail
+	// with EEXIST
+	cloneUnsupported.Store(pair, struct{}{})
+	existing := filepath.Join(tmpDir, "existing.txt")
+	os.WriteFile(existing, []byte("kept"), 0644)
+	if err := tryClone(src, existing, info); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported without an attempt, got %v", err)
+	}
+}
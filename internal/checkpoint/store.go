@@ -0,0 +1,312 @@
+package checkpoint
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/oplog"
+)
+
+// Store is the checkpoints, index and object store under one safeshell
+// directory. Each Store owns its own index, so a process can work with
+// several stores at once, e.g. to sync or migrate between them.
+type Store struct {
+	dir string
+
+	indexOnce sync.Once
+	index     *Index
+}
+
+// NewStore returns the store under a safeshell directory. Nothing is read
+// or created until it is used.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the safeshell directory the store lives in
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// CheckpointsDir returns the directory holding one directory per checkpoint
+func (s *Store) CheckpointsDir() string {
+	return filepath.Join(s.dir, "checkpoints")
+}
+
+// ObjectsDir returns the content-addressed object store
+func (s *Store) ObjectsDir() string {
+	return filepath.Join(s.dir, "objects")
+}
+
+// Index returns the store's checkpoint index, loading or rebuilding it on
+// first use
+func (s *Store) Index() *Index {
+	s.indexOnce.Do(func() {
+		s.index = newIndex(s.CheckpointsDir())
+		s.index.Load()
+	})
+	return s.index
+}
+
+// checkpointDir returns the directory of the checkpoint with the given ID
+func (s *Store) checkpointDir(id string) string {
+	return filepath.Join(s.CheckpointsDir(), id)
+}
+
+var (
+	defaultStore   *Store
+	defaultStoreMu sync.Mutex
+)
+
+// DefaultStore returns the store in the configured safeshell directory. A
+// new store is opened when the configured directory changes.
+func DefaultStore() *Store {
+	dir := config.GetSafeShellDir()
+
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	if defaultStore == nil || defaultStore.dir != dir {
+		defaultStore = NewStore(dir)
+	}
+	return defaultStore
+}
+
+// The package-level functions below operate on the default store.
+
+// GetIndex returns the default store's checkpoint index
+func GetIndex() *Index {
+	return DefaultStore().Index()
+}
+
+// ResetIndex drops the default store, so its index is loaded again on next
+// use (for testing)
+func ResetIndex() {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	defaultStore = nil
+}
+
+// ObjectsDir returns the default store's object store
+func ObjectsDir() string {
+	return DefaultStore().ObjectsDir()
+}
+
+// Create creates a new checkpoint for the given files before executing a command
+func Create(command string, targetPaths []string) (*Checkpoint, error) {
+	return DefaultStore().Create(command, targetPaths)
+}
+
+// CreateWithProgress is Create, reporting each target path as it is backed up
+func CreateWithProgress(command string, targetPaths []string, progress ProgressFunc) (*Checkpoint, error) {
+	return DefaultStore().CreateWithProgress(command, targetPaths, progress)
+}
+
+// CreateIncluding is Create, backing up only the files matching one of the
+// include patterns instead of include_paths
+func CreateIncluding(command string, targetPaths, include []string) (*Checkpoint, error) {
+	return DefaultStore().CreateIncluding(command, targetPaths, include)
+}
+
+// CreateContext is CreateIncluding, stopping when ctx is done, without
+// leaving a checkpoint behind
+func CreateContext(ctx context.Context, command string, targetPaths, include []string) (*Checkpoint, error) {
+	return DefaultStore().CreateContext(ctx, command, targetPaths, include)
+}
+
+// CreateResumable is CreateContext, keeping what was backed up when
+// stopped for ResumeCreate to finish. The checkpoint gets name, if set.
+func CreateResumable(ctx context.Context, name, command string, targetPaths, include []string) (*Checkpoint, error) {
+	return DefaultStore().CreateResumable(ctx, name, command, targetPaths, include)
+}
+
+// ResumeCreate finishes creating the checkpoint id, interrupted while
+// backing up, reusing the backups of files unchanged since
+func ResumeCreate(ctx context.Context, id string) (*Checkpoint, error) {
+	return DefaultStore().ResumeCreate(ctx, id)
+}
+
+// LatestResumable returns the ID of the latest checkpoint ResumeCreate can
+// finish
+func LatestResumable() (string, error) {
+	return DefaultStore().LatestResumable()
+}
+
+// CreateFor is Create on behalf of another process, e.g. a daemon client,
+// using its working directory and session instead of our own
+func CreateFor(origin Origin, command string, targetPaths []string) (*Checkpoint, error) {
+	return DefaultStore().CreateFor(origin, command, targetPaths)
+}
+
+// CreateForWriter is CreateFor for a command about to write to the targets
+// in place, backing them up as clones or copies rather than hard links
+func CreateForWriter(ctx context.Context, origin Origin, command string, targetPaths []string) (*Checkpoint, error) {
+	return DefaultStore().CreateForWriter(ctx, origin, command, targetPaths)
+}
+
+// Trash moves targets into a new checkpoint instead of backing them up,
+// for a command that would delete them
+func Trash(command string, targets []string) (*Checkpoint, error) {
+	return DefaultStore().Trash(command, targets)
+}
+
+// Untrash renames what Trash moved into cp back where it was
+func Untrash(cp *Checkpoint) error {
+	return DefaultStore().Untrash(cp)
+}
+
+// List returns all checkpoints sorted by creation time (newest first)
+func List() ([]*Checkpoint, error) {
+	return DefaultStore().List()
+}
+
+// Get retrieves a specific checkpoint by ID
+func Get(id string) (*Checkpoint, error) {
+	return DefaultStore().Get(id)
+}
+
+// GetLatest returns the most recent checkpoint
+func GetLatest() (*Checkpoint, error) {
+	return DefaultStore().GetLatest()
+}
+
+// Delete removes a checkpoint
+func Delete(id string) error {
+	return DefaultStore().Delete(id)
+}
+
+// ListBySession returns checkpoints grouped by session ID
+func ListBySession() (map[string][]*Checkpoint, error) {
+	return DefaultStore().ListBySession()
+}
+
+// GetCurrentSession returns checkpoints from the current session only
+func GetCurrentSession() ([]*Checkpoint, error) {
+	return DefaultStore().GetCurrentSession()
+}
+
+// AddTag adds a tag to a checkpoint
+func AddTag(id string, tag string) error {
+	return DefaultStore().AddTag(id, tag)
+}
+
+// RemoveTag removes a tag from a checkpoint
+func RemoveTag(id string, tag string) error {
+	return DefaultStore().RemoveTag(id, tag)
+}
+
+// SetNote sets the note for a checkpoint
+func SetNote(id string, note string) error {
+	return DefaultStore().SetNote(id, note)
+}
+
+// SetPinned pins or unpins a checkpoint
+func SetPinned(id string, pinned bool) error {
+	return DefaultStore().SetPinned(id, pinned)
+}
+
+// ListByTag returns all checkpoints with a specific tag
+func ListByTag(tag string) ([]*Checkpoint, error) {
+	return DefaultStore().ListByTag(tag)
+}
+
+// Search finds checkpoints matching the given criteria
+func Search(opts SearchOptions) ([]*Checkpoint, error) {
+	return DefaultStore().Search(opts)
+}
+
+// CompareSince checks the files backed up by the default store's
+// checkpoints created since since against their current state
+func CompareSince(since time.Time) ([]WindowDiff, error) {
+	return DefaultStore().CompareSince(since)
+}
+
+// Clean removes checkpoints older than the specified duration, keeping
+// pinned checkpoints and the newest keep_per_session of each session
+func Clean(olderThan time.Duration) (int, error) {
+	return DefaultStore().Clean(olderThan)
+}
+
+// CleanKeepingSessions is Clean with an explicit number of checkpoints to
+// keep per session, regardless of age
+func CleanKeepingSessions(olderThan time.Duration, keepPerSession int) (int, error) {
+	return DefaultStore().CleanKeepingSessions(olderThan, keepPerSession)
+}
+
+// Compress compresses a checkpoint to save disk space
+func Compress(id string) (int64, int64, error) {
+	return DefaultStore().Compress(id)
+}
+
+// CompressContext is Compress, stopping when ctx is done
+func CompressContext(ctx context.Context, id string) (int64, int64, error) {
+	return DefaultStore().CompressContext(ctx, id)
+}
+
+// Decompress decompresses a checkpoint for access
+func Decompress(id string) error {
+	return DefaultStore().Decompress(id)
+}
+
+// CompressOlderThan compresses checkpoints older than the specified duration
+func CompressOlderThan(olderThan time.Duration) (int, int64, error) {
+	return DefaultStore().CompressOlderThan(olderThan)
+}
+
+// EnsureDecompressed ensures a checkpoint is decompressed before access
+func EnsureDecompressed(cp *Checkpoint) error {
+	return DefaultStore().EnsureDecompressed(cp)
+}
+
+// CheckTotalStorage checks if the default store exceeds the storage limit
+func CheckTotalStorage() (bool, int64, int) {
+	return DefaultStore().CheckTotalStorage()
+}
+
+// Compact rewrites a checkpoint in the newest storage format
+func Compact(cp *Checkpoint) (int64, error) {
+	return DefaultStore().Compact(cp)
+}
+
+// PruneObjects removes objects no checkpoint links to anymore
+func PruneObjects() (int, int64, error) {
+	return DefaultStore().PruneObjects()
+}
+
+// Push packs a local checkpoint and stores it in the backend
+func Push(b Backend, id string) error {
+	return DefaultStore().Push(b, id)
+}
+
+// Pull downloads a checkpoint from the backend into the local store
+func Pull(b Backend, id string) (*Checkpoint, error) {
+	return DefaultStore().Pull(b, id)
+}
+
+// History returns every backed-up version of the file at path, oldest first
+func History(path string) ([]Version, error) {
+	return DefaultStore().History(path)
+}
+
+// Fsck checks the default store, and repairs it if asked to
+func Fsck(repair bool) ([]FsckProblem, error) {
+	return DefaultStore().Fsck(repair)
+}
+
+// GC removes what interrupted operations left in the default store
+func GC(dryRun bool) ([]Garbage, error) {
+	return DefaultStore().GC(dryRun)
+}
+
+// Timeline returns what happened to protected files, oldest first
+func Timeline(f TimelineFilter) ([]oplog.Entry, error) {
+	return DefaultStore().Timeline(f)
+}
+
+// RecentExclusionSuggestions merges the suggestions for every oversized
+// checkpoint created since the given time
+func RecentExclusionSuggestions(since time.Time) []ExclusionSuggestion {
+	return DefaultStore().RecentExclusionSuggestions(since)
+}
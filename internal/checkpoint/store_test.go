@@ -0,0 +1,88 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStoresAreIndependent(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "testdata", "file.txt")
+	os.WriteFile(file, []byte("content"), 0644)
+
+	a := NewStore(filepath.Join(tmpDir, "store-a"))
+	b := NewStore(filepath.Join(tmpDir, "store-b"))
+
+	cp, err := a.Create("rm file.txt", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if _, err := b.Get(cp.ID); err == nil {
+		t.Error("Expected checkpoint to be missing from the other store")
+	}
+	if entries := b.Index().ListEntries(); len(entries) != 0 {
+		t.Errorf("Expected empty index in the other store, got %d entries", len(entries))
+	}
+	if GetIndex().GetEntry(cp.ID) != nil {
+		t.Error("Expected checkpoint to be missing from the default store")
+	}
+
+	// Move the checkpoint across through a backend
+	backend, err := NewLocalBackend(filepath.Join(tmpDir, "remote"))
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	if err := a.Push(backend, cp.ID); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	os.MkdirAll(b.CheckpointsDir(), 0755)
+	pulled, err := b.Pull(backend, cp.ID)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if filepath.Dir(pulled.Dir) != b.CheckpointsDir() {
+		t.Errorf("Expected pulled checkpoint in %s, got %s", b.CheckpointsDir(), pulled.Dir)
+	}
+	if latest, err := b.GetLatest(); err != nil || latest.ID != cp.ID {
+		t.Errorf("Expected %s as latest in the other store, got %v (%v)", cp.ID, latest, err)
+	}
+}
+
+func TestStoresConcurrentCreate(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	stores := []*Store{
+		NewStore(filepath.Join(tmpDir, "store-a")),
+		NewStore(filepath.Join(tmpDir, "store-b")),
+	}
+
+	const perStore = 5
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		for j := 0; j < perStore; j++ {
+			file := filepath.Join(tmpDir, "testdata", fmt.Sprintf("%d-%d.txt", i, j))
+			os.WriteFile(file, []byte(file), 0644)
+
+			wg.Add(1)
+			go func(s *Store) {
+				defer wg.Done()
+				if _, err := s.Create("rm", []string{file}); err != nil {
+					t.Errorf("Failed to create checkpoint: %v", err)
+				}
+			}(s)
+		}
+	}
+	wg.Wait()
+
+	for i, s := range stores {
+		if entries := s.Index().ListEntries(); len(entries) != perStore {
+			t.Errorf("Store %d: expected %d index entries, got %d", i, perStore, len(entries))
+		}
+	}
+}
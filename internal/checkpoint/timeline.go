@@ -0,0 +1,85 @@
+package checkpoint
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/oplog"
+)
+
+// TimelineFilter narrows down a timeline. Zero fields don't.
+type TimelineFilter struct {
+	Since, Until time.Time
+	Session      string
+	Path         string // absolute; events of checkpoints holding it or files under it
+}
+
+// Timeline returns what happened to the files safeshell protects, oldest
+// first, from the operations log: the commands wrapped and the checkpoints
+// created, rolled back and deleted. A checkpoint created by a wrapped
+// command is part of the command's event, which gets its file count and
+// size. Events logged before sessions were recorded get their checkpoint's
+// from the index. Only checkpoints still in the store can match a path.
+func (s *Store) Timeline(f TimelineFilter) ([]oplog.Entry, error) {
+	entries, err := oplog.Read(f.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := s.Index()
+	var paths map[string][]string
+	if f.Path != "" {
+		if paths, err = idx.FilePaths(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Checkpoints created by wrapped commands, to fold into their events
+	created := make(map[string]oplog.Entry)
+	for _, e := range entries {
+		if e.Op == oplog.OpCheckpoint {
+			created[e.CheckpointID] = e
+		}
+	}
+	wrapped := make(map[string]bool)
+	for i, e := range entries {
+		if cp, ok := created[e.CheckpointID]; ok && e.Op == oplog.OpWrap {
+			wrapped[e.CheckpointID] = true
+			entries[i].Files, entries[i].Bytes = cp.Files, cp.Bytes
+		}
+	}
+
+	var events []oplog.Entry
+	for _, e := range entries {
+		if e.Op == oplog.OpCheckpoint && wrapped[e.CheckpointID] {
+			continue
+		}
+		if !f.Until.IsZero() && e.Time.After(f.Until) {
+			continue
+		}
+		if e.Session == "" && e.CheckpointID != "" {
+			if entry := idx.GetEntry(e.CheckpointID); entry != nil {
+				e.Session = entry.SessionID
+			}
+		}
+		if f.Session != "" && e.Session != f.Session {
+			continue
+		}
+		if f.Path != "" && !holdsPath(paths[e.CheckpointID], f.Path) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// holdsPath reports whether any of paths is path or inside it
+func holdsPath(paths []string, path string) bool {
+	path = filepath.Clean(path)
+	for _, p := range paths {
+		if underPath(path, p) {
+			return true
+		}
+	}
+	return false
+}
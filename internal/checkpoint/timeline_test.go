@@ -0,0 +1,75 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/oplog"
+)
+
+func TestTimeline(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	t.Setenv("SAFESHELL_SESSION", "mine")
+	// The operations log may hold other tests' events
+	start := time.Now()
+
+	docs := filepath.Join(tmpDir, "testdata", "docs")
+	os.MkdirAll(docs, 0755)
+	os.WriteFile(filepath.Join(docs, "a.txt"), []byte("a"), 0644)
+	other := filepath.Join(tmpDir, "testdata", "other.txt")
+	os.WriteFile(other, []byte("other"), 0644)
+
+	wrapped, err := Create("rm -r docs", []string{docs})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	ok := 0
+	oplog.Append(oplog.Entry{Op: oplog.OpWrap, CheckpointID: wrapped.ID, Command: "rm -r docs", ExitCode: &ok, Session: "mine"})
+	manual, err := Create("manual", []string{other})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	oplog.Append(oplog.Entry{Op: oplog.OpWrap, Command: "ls", ExitCode: &ok, Session: "theirs"})
+
+	events, err := Timeline(TimelineFilter{Since: start})
+	if err != nil {
+		t.Fatalf("Timeline failed: %v", err)
+	}
+	// The wrapped command's checkpoint is part of its event
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %+v", events)
+	}
+	if events[0].Op != oplog.OpWrap || events[0].CheckpointID != wrapped.ID || events[0].Files != 1 {
+		t.Errorf("Expected the wrapped command with its checkpoint first, got %+v", events[0])
+	}
+	if events[1].Op != oplog.OpCheckpoint || events[1].CheckpointID != manual.ID {
+		t.Errorf("Expected the manual checkpoint second, got %+v", events[1])
+	}
+
+	tests := []struct {
+		name     string
+		filter   TimelineFilter
+		expected int
+	}{
+		{"session", TimelineFilter{Session: "mine"}, 2},
+		{"other session", TimelineFilter{Session: "theirs"}, 1},
+		{"directory", TimelineFilter{Path: docs}, 1},
+		{"file inside", TimelineFilter{Path: filepath.Join(docs, "a.txt")}, 1},
+		{"parent", TimelineFilter{Path: filepath.Join(tmpDir, "testdata")}, 2},
+		{"sibling", TimelineFilter{Path: filepath.Join(tmpDir, "testdata", "doc")}, 0},
+		{"until", TimelineFilter{Until: time.Now().Add(-time.Hour)}, 0},
+	}
+	for _, tt := range tests {
+		tt.filter.Since = start
+		events, err := Timeline(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: Timeline failed: %v", tt.name, err)
+		}
+		if len(events) != tt.expected {
+			t.Errorf("%s: expected %d events, got %+v", tt.name, tt.expected, events)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package checkpoint
+
+import (
+	"os"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// A restored file is a new copy, so it gets the time of the restore. That
+// is what make and similar tools expect, as a restored source is then newer
+// than anything built from it; with preserve_times set, files get back the
+// times recorded when they were backed up instead.
+
+func preserveTimes() bool {
+	cfg := config.Get()
+	return cfg != nil && cfg.PreserveTimes
+}
+
+// recordTimes keeps the times of info in the entry
+func (f *FileEntry) recordTimes(info os.FileInfo) {
+	f.ModTime = info.ModTime().UnixNano()
+	if atime := accessTime(info); !atime.IsZero() {
+		f.AccessTime = atime.UnixNano()
+	}
+}
+
+// RestoreTimes gives path the times recorded for f, if preserve_times is
+// set. Entries from before times were recorded are left alone, and a
+// missing access time is taken to be the modification time.
+func RestoreTimes(path string, f FileEntry) error {
+	if !preserveTimes() || f.ModTime == 0 {
+		return nil
+	}
+	mtime := time.Unix(0, f.ModTime)
+	atime := mtime
+	if f.AccessTime != 0 {
+		atime = time.Unix(0, f.AccessTime)
+	}
+	return os.Chtimes(path, atime, mtime)
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package checkpoint
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime returns the zero time; the access time is only read on Linux
+// and macOS
+func accessTime(info os.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,218 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/hooks"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// Trash is Create for a command that only deletes its targets, like rm:
+// instead of being backed up for the command to delete, the targets are
+// moved into the checkpoint and the command needn't run. Nothing is copied,
+// so deleting is instant however much the targets hold, and rolling back
+// renames them back. Everything moves, excluded and large files too.
+//
+// Targets that don't exist are skipped. If one can't be moved, e.g. because
+// it is on another filesystem than the store, those already moved are put
+// back and the error is returned, for the caller to fall back to Create.
+func (s *Store) Trash(command string, targets []string) (*Checkpoint, error) {
+	start := time.Now()
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	var evicted []*Eviction
+	if e, err := s.enforceMaxStorage(); err != nil {
+		return nil, err
+	} else if e != nil {
+		evicted = append(evicted, e)
+	}
+
+	id := newID()
+	checkpointDir := s.checkpointDir(id)
+	filesDir := filepath.Join(checkpointDir, "files")
+
+	var absTargets []string
+	for _, target := range targets {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(workingDir, target)
+		}
+		absTargets = append(absTargets, filepath.Clean(target))
+	}
+
+	hookEnv := hooks.Env{CheckpointID: id, CheckpointDir: checkpointDir, Command: command, Paths: absTargets}
+	if err := hooks.Run(hooks.PreCheckpoint, hookEnv); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := hooks.Run(hooks.PostCheckpoint, hookEnv); err != nil {
+			logging.Warn(fmt.Sprintf("Warning: %v", err))
+		}
+	}()
+
+	if err := os.MkdirAll(filesDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	// If killed before the manifest is saved, the checkpoint holds the
+	// targets moved so far: Fsck rebuilds it for them to be rolled back
+	if err := markIncomplete(checkpointDir, IncompleteMove); err != nil {
+		os.RemoveAll(checkpointDir)
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	manifest := NewManifest(id, command, workingDir)
+	manifest.SessionID = GetSessionID()
+	cp := &Checkpoint{
+		ID:        id,
+		Dir:       checkpointDir,
+		FilesDir:  filesDir,
+		Manifest:  manifest,
+		CreatedAt: manifest.Timestamp,
+	}
+
+	for _, absPath := range absTargets {
+		if err := trashTarget(cp, absPath); err != nil {
+			return nil, s.abandonTrash(cp, err)
+		}
+	}
+
+	if err := manifest.Save(checkpointDir); err != nil {
+		return nil, s.abandonTrash(cp, fmt.Errorf("failed to save manifest: %w", err))
+	}
+	if err := markComplete(checkpointDir); err != nil {
+		return nil, s.abandonTrash(cp, fmt.Errorf("failed to save manifest: %w", err))
+	}
+	s.Index().Add(cp)
+	s.created(cp, start, evicted)
+	return cp, nil
+}
+
+// trashTarget moves absPath into cp and records what it holds. A target
+// that doesn't exist, e.g. because one moved before held it, is skipped.
+func trashTarget(cp *Checkpoint, absPath string) error {
+	if err := ValidatePath(absPath); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(absPath); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", absPath, err)
+	}
+
+	backupPath := filepath.Join(cp.FilesDir, strings.TrimPrefix(absPath, "/"))
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	cp.Manifest.addParents(absPath)
+	if err := os.Rename(absPath, backupPath); err != nil {
+		return fmt.Errorf("failed to move %s into checkpoint: %w", absPath, err)
+	}
+	cp.Manifest.Trashed = append(cp.Manifest.Trashed, absPath)
+
+	// Recorded like a backup, so listing, diffing and rolling back by
+	// copying work as for any checkpoint. Symlinks are moved but, as
+	// always, not recorded.
+	return filepath.Walk(backupPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		original := absPath + strings.TrimPrefix(path, backupPath)
+		switch {
+		case fi.IsDir():
+			cp.Manifest.AddFile(original, path, fi.Mode(), 0, true)
+		case fi.Mode().IsRegular():
+			cp.Manifest.AddFile(original, path, fi.Mode(), fi.Size(), false).recordTimes(fi)
+			logging.Debug("moved into checkpoint", "path", original, "size", fi.Size())
+		}
+		return nil
+	})
+}
+
+// abandonTrash puts back what Trash moved into cp before failing with err,
+// and removes cp. If something can't be put back, cp is kept, holding it.
+func (s *Store) abandonTrash(cp *Checkpoint, err error) error {
+	if untrashErr := untrash(cp.Manifest, cp.FilesDir); untrashErr != nil {
+		cp.Manifest.Save(cp.Dir)
+		markComplete(cp.Dir)
+		s.Index().Add(cp)
+		return fmt.Errorf("%w; checkpoint %s holds what couldn't be put back: %v", err, cp.ID, untrashErr)
+	}
+	os.RemoveAll(cp.Dir)
+	return err
+}
+
+// Untrashable reports whether the targets Trash moved into cp can be renamed
+// back: nothing is in their place, and the checkpoint holds them as moved,
+// not compressed or compacted
+func Untrashable(cp *Checkpoint) bool {
+	m := cp.Manifest
+	if len(m.Trashed) == 0 || m.Compressed || m.FormatVersion != 0 {
+		return false
+	}
+	for _, target := range m.Trashed {
+		if _, err := os.Lstat(target); !os.IsNotExist(err) {
+			return false
+		}
+		if _, err := os.Lstat(filepath.Join(cp.FilesDir, strings.TrimPrefix(target, "/"))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Untrash renames the targets Trash moved into cp back where they were,
+// recreating missing parents, and drops them from its manifest as it no
+// longer holds them. On error, those not renamed back are kept.
+func (s *Store) Untrash(cp *Checkpoint) error {
+	err := untrash(cp.Manifest, cp.FilesDir)
+	if saveErr := cp.Manifest.Save(cp.Dir); saveErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to update manifest: %w", saveErr))
+	}
+	s.Index().Update(cp)
+	return err
+}
+
+// untrash is Untrash, updating m but not saving it
+func untrash(m *Manifest, filesDir string) error {
+	var errs []error
+	var kept []string
+	// Last moved first, in case one was moved out of another's place
+	for i := len(m.Trashed) - 1; i >= 0; i-- {
+		target := m.Trashed[i]
+		backupPath := filepath.Join(filesDir, strings.TrimPrefix(target, "/"))
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err == nil {
+			err = os.Rename(backupPath, target)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to move %s back: %w", target, err))
+			kept = append([]string{target}, kept...)
+		}
+	}
+
+	files := m.Files[:0]
+	for _, file := range m.Files {
+		if !withinAny(file.OriginalPath, m.Trashed) || withinAny(file.OriginalPath, kept) {
+			files = append(files, file)
+		}
+	}
+	m.Files, m.Trashed = files, kept
+	return errors.Join(errs...)
+}
+
+// withinAny reports whether path is one of paths or inside one
+func withinAny(path string, paths []string) bool {
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	dir := filepath.Join(tmpDir, "testdata", "build")
+	os.MkdirAll(filepath.Join(dir, "obj"), 0755)
+	os.WriteFile(filepath.Join(dir, "obj", "a.o"), []byte("object"), 0644)
+	file := filepath.Join(tmpDir, "testdata", "notes.txt")
+	os.WriteFile(file, []byte("notes"), 0600)
+	missing := filepath.Join(tmpDir, "testdata", "missing")
+
+	cp, err := store.Trash("rm -rf build notes.txt missing", []string{dir, file, missing})
+	if err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	for _, p := range []string{dir, file} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be moved into the checkpoint", p)
+		}
+	}
+	if len(cp.Manifest.Trashed) != 2 {
+		t.Errorf("Expected 2 trashed targets, got %v", cp.Manifest.Trashed)
+	}
+	if n, size := countFiles(cp.Manifest); n != 2 || size != int64(len("object")+len("notes")) {
+		t.Errorf("Expected 2 files of 11 bytes recorded, got %d of %d", n, size)
+	}
+	content, err := os.ReadFile(filepath.Join(cp.FilesDir, dir, "obj", "a.o"))
+	if err != nil || string(content) != "object" {
+		t.Errorf("Expected a.o in the checkpoint, got %q, %v", content, err)
+	}
+
+	if !Untrashable(cp) {
+		t.Fatal("Expected the targets to be renamed back")
+	}
+	if err := store.Untrash(cp); err != nil {
+		t.Fatalf("Untrash failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "obj", "a.o")); string(content) != "object" {
+		t.Error("Expected a.o back in place")
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected notes.txt back with its mode, got %v", err)
+	}
+	if len(cp.Manifest.Files) != 0 || len(cp.Manifest.Trashed) != 0 || Untrashable(cp) {
+		t.Errorf("Expected the checkpoint to hold nothing after Untrash, got %+v", cp.Manifest.Files)
+	}
+}
+
+func TestTrashPutsBackOnFailure(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	store := NewStore(filepath.Join(tmpDir, ".safeshell"))
+
+	file := filepath.Join(tmpDir, "testdata", "keep.txt")
+	os.WriteFile(file, []byte("keep"), 0644)
+
+	// System directories are never moved
+	if _, err := store.Trash("rm -f keep.txt /etc/passwd", []string{file, "/etc/passwd"}); err == nil {
+		t.Fatal("Expected Trash to fail")
+	}
+	if content, _ := os.ReadFile(file); string(content) != "keep" {
+		t.Error("Expected keep.txt to be put back")
+	}
+	if checkpoints, _ := store.List(); len(checkpoints) != 0 {
+		t.Errorf("Expected no checkpoint to be left, got %d", len(checkpoints))
+	}
+}
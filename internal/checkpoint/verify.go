@@ -0,0 +1,90 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+)
+
+// Verify checks that every backup in a checkpoint is still intact: present,
+// of its recorded size and, from format 2, of its recorded hash. Compressed
+// checkpoints are read in full, so a damaged archive is found too. All
+// problems found are returned, joined.
+func Verify(cp *Checkpoint) error {
+	if cp.Manifest.Compressed {
+		return verifyArchive(cp)
+	}
+	if err := MountFSSnapshot(cp); err != nil {
+		return fmt.Errorf("failed to mount snapshot: %w", err)
+	}
+
+	var errs []error
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		info, err := os.Stat(f.BackupPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: backup missing", f.OriginalPath))
+			continue
+		}
+		// Backups are hard links, so editing the original in place reaches them
+		if info.Size() != f.Size {
+			errs = append(errs, fmt.Errorf("%s: backup is %d bytes, expected %d", f.OriginalPath, info.Size(), f.Size))
+			continue
+		}
+		if f.Hash == "" {
+			continue
+		}
+		if hash, err := contentHash(f.BackupPath); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.OriginalPath, err))
+		} else if hash != f.Hash {
+			errs = append(errs, fmt.Errorf("%s: backup does not match its hash", f.OriginalPath))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func verifyArchive(cp *Checkpoint) error {
+	algorithm := cp.Manifest.CompressionAlgorithm
+	hashes, err := archiveHashes(GetArchivePath(cp.Dir, algorithm), algorithm)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			continue
+		}
+		rel, err := backupRel(cp, f)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		hash, ok := hashes[filepath.ToSlash(rel)]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%s: missing from archive", f.OriginalPath))
+		case f.Hash != "" && hash != f.Hash:
+			errs = append(errs, fmt.Errorf("%s: archived copy does not match its hash", f.OriginalPath))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VerifySample verifies up to n checkpoints chosen at random, returning the
+// problems found by checkpoint ID, and how many were checked
+func VerifySample(checkpoints []*Checkpoint, n int) (map[string]error, int) {
+	problems := make(map[string]error)
+	n = min(n, len(checkpoints))
+	for _, i := range rand.Perm(len(checkpoints))[:n] {
+		cp := checkpoints[i]
+		if err := Verify(cp); err != nil {
+			problems[cp.ID] = err
+		}
+	}
+	return problems, n
+}
@@ -0,0 +1,77 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	c := filepath.Join(dir, "c.txt")
+	os.WriteFile(a, []byte("alpha"), 0644)
+	os.WriteFile(b, []byte("beta"), 0644)
+	os.WriteFile(c, []byte("gamma"), 0644)
+
+	intact, _ := Create("rm a.txt b.txt", []string{a, b})
+	if err := Verify(intact); err != nil {
+		t.Fatalf("Expected intact checkpoint, got %v", err)
+	}
+
+	// Appending to the original reaches the hard-linked backup
+	appended, _ := Create("rm c.txt", []string{c})
+	f, _ := os.OpenFile(c, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(" and more")
+	f.Close()
+	if err := Verify(appended); err == nil || !strings.Contains(err.Error(), "c.txt") {
+		t.Errorf("Expected c.txt to fail verification, got %v", err)
+	}
+
+	// Same size, different content is caught once hashes are recorded
+	os.Remove(b)
+	os.WriteFile(b, []byte("beta"), 0644)
+	hashed, _ := Create("rm b.txt", []string{b})
+	os.Remove(b)
+	if _, err := Compact(hashed); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	hashed, _ = Get(hashed.ID)
+	backup := hashed.Manifest.Files[0].BackupPath
+	os.Chmod(backup, 0644)
+	os.WriteFile(backup, []byte("BETA"), 0644)
+	if err := Verify(hashed); err == nil || !strings.Contains(err.Error(), "hash") {
+		t.Errorf("Expected a hash mismatch, got %v", err)
+	}
+
+	// Compressed checkpoints are checked through the archive
+	os.WriteFile(b, []byte("delta"), 0644)
+	compressed, _ := Create("rm b.txt", []string{b})
+	if _, _, err := Compress(compressed.ID); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	compressed, _ = Get(compressed.ID)
+	if err := Verify(compressed); err != nil {
+		t.Errorf("Expected intact compressed checkpoint, got %v", err)
+	}
+	archive := GetArchivePath(compressed.Dir, compressed.Manifest.CompressionAlgorithm)
+	data, _ := os.ReadFile(archive)
+	os.WriteFile(archive, data[:len(data)/2], 0644)
+	if err := Verify(compressed); err == nil {
+		t.Error("Expected a truncated archive to fail verification")
+	}
+
+	all := []*Checkpoint{intact, appended, hashed, compressed}
+	problems, checked := VerifySample(all, 10)
+	if checked != len(all) || len(problems) != 3 || problems[intact.ID] != nil {
+		t.Errorf("Expected 3 problems in %d checkpoints, got %d in %d: %v", len(all), len(problems), checked, problems)
+	}
+	if _, checked := VerifySample(all, 2); checked != 2 {
+		t.Errorf("Expected a sample of 2, got %d", checked)
+	}
+}
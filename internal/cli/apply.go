@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/rollback"
+	"github.com/qhkm/safeshell/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyLast      bool
+	applyPatchMode bool
+	applyFile      string
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [checkpoint-id]",
+	Short: "Restore changes from a checkpoint, optionally hunk by hunk",
+	Long: `Restores the checkpointed content of files changed since a checkpoint.
+
+With --patch-mode each change is shown as a diff hunk and you choose whether
+to restore it, like 'git checkout -p'. This is useful when a command rewrote
+a file and only part of its change should be reverted. The lines shown with
+'-' are the current content, '+' the checkpointed content.
+
+Deleted and binary files can only be restored whole.
+
+Options:
+  --patch-mode  Choose hunk by hunk what to restore (needs a terminal;
+                with --yes, restores everything)
+  --file        Only restore this file
+
+Answers in patch mode:
+  y  restore this hunk
+  n  keep the current lines
+  a  restore this and all later hunks in the file
+  d  keep this and all later hunks in the file
+  q  quit; hunks chosen so far are restored
+
+Examples:
+  safeshell apply --last --patch-mode
+  safeshell apply --last -p --file src/main.go
+  safeshell apply 2024-12-12T143022-a1b2c3 --file config.json`,
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runApply,
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().BoolVarP(&applyLast, "last", "l", false, "Apply the most recent checkpoint")
+	addAtFlag(applyCmd)
+	applyCmd.Flags().BoolVarP(&applyPatchMode, "patch-mode", "p", false, "Choose hunk by hunk what to restore")
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", "", "Only restore this file")
+	applyCmd.RegisterFlagCompletionFunc("file", completeCheckpointPath)
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	var cp *checkpoint.Checkpoint
+	var err error
+
+	if applyLast || checkpointAt != "" {
+		if cp, err = latestCheckpoint(); err != nil {
+			return err
+		}
+	} else if len(args) > 0 {
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
+		}
+	} else {
+		return errors.New(i18n.T("rollback.specify"))
+	}
+
+	if cp.Manifest.RolledBack {
+		return errAlreadyRolledBack()
+	}
+
+	diffs := checkpoint.Compare(cp)
+	if applyFile != "" {
+		if diffs, err = filterDiffs(diffs, applyFile); err != nil {
+			return err
+		}
+	}
+
+	var changed []checkpoint.FileDiff
+	for _, d := range diffs {
+		if d.Status != checkpoint.DiffUnchanged {
+			changed = append(changed, d)
+		}
+	}
+	if len(changed) == 0 {
+		printInfo("No changes to restore")
+		return nil
+	}
+
+	if !applyPatchMode || assumeYes {
+		var paths []string
+		for _, d := range changed {
+			paths = append(paths, d.Path)
+		}
+		return rollback.RollbackSelective(cp, paths)
+	}
+
+	if !util.CanPrompt() {
+		return errors.New("--patch-mode needs a terminal, use --yes to restore everything")
+	}
+	return applyPatches(cp, changed)
+}
+
+// applyPatches asks about each hunk of the changed files, restores the
+// chosen hunks, then restores the files that can only be restored whole
+func applyPatches(cp *checkpoint.Checkpoint, changed []checkpoint.FileDiff) error {
+	entries := make(map[string]checkpoint.FileEntry, len(cp.Manifest.Files))
+	for _, f := range cp.Manifest.Files {
+		entries[f.OriginalPath] = f
+	}
+	cwd, _ := os.Getwd()
+	reader := bufio.NewReader(os.Stdin)
+
+	var whole []string
+	hunks, files := 0, 0
+	quit := false
+
+	for _, d := range changed {
+		if quit {
+			break
+		}
+		name := d.Path
+		if rel, err := filepath.Rel(cwd, d.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+
+		var patch *checkpoint.FilePatch
+		if d.Status == checkpoint.DiffModified {
+			var err error
+			patch, err = checkpoint.NewFilePatch(cp, entries[d.Path])
+			if err != nil && !errors.Is(err, checkpoint.ErrBinaryFile) {
+				return err
+			}
+			if patch != nil && len(patch.Hunks) == 0 {
+				continue // Only the mode changed
+			}
+		}
+
+		if patch == nil {
+			kind := "deleted"
+			if d.Status == checkpoint.DiffModified {
+				kind = "binary"
+			}
+			switch askHunk(reader, fmt.Sprintf("Restore %s file %s [y,n,q]? ", kind, name), "ynq") {
+			case 'y':
+				whole = append(whole, d.Path)
+			case 'q':
+				quit = true
+			}
+			continue
+		}
+
+		color.New(color.Bold).Printf("--- a/%s (current)\n+++ b/%s (checkpoint)\n", name, name)
+		selected := make([]bool, len(patch.Hunks))
+		rest := byte(0) // 'a' or 'd' once chosen for the rest of the file
+		for i, h := range patch.Hunks {
+			answer := rest
+			if answer == 0 {
+				printHunk(h)
+				answer = askHunk(reader, fmt.Sprintf("(%d/%d) Restore this hunk [y,n,a,d,q]? ", i+1, len(patch.Hunks)), "ynadq")
+			}
+			if answer == 'q' {
+				quit = true
+				break
+			}
+			if answer == 'a' || answer == 'd' {
+				rest = answer
+			}
+			selected[i] = answer == 'y' || answer == 'a'
+		}
+
+		n := 0
+		for _, s := range selected {
+			if s {
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		if err := patch.Restore(selected); err != nil {
+			return err
+		}
+		hunks += n
+		files++
+	}
+
+	if len(whole) > 0 {
+		if err := rollback.RollbackSelective(cp, whole); err != nil {
+			return err
+		}
+	}
+	if hunks > 0 {
+		printSuccess(fmt.Sprintf("Restored %d hunk(s) in %d file(s)", hunks, files))
+	} else if len(whole) == 0 {
+		printInfo("Nothing restored")
+	}
+	return nil
+}
+
+// printHunk prints a hunk in the colors git uses
+func printHunk(h checkpoint.Hunk) {
+	color.New(color.FgCyan).Println(h.Header)
+	for _, line := range h.Lines() {
+		switch line[0] {
+		case '-':
+			color.New(color.FgRed).Println(line)
+		case '+':
+			color.New(color.FgGreen).Println(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+// askHunk prompts until one of the letters in valid is answered. End of input
+// counts as quitting.
+func askHunk(reader *bufio.Reader, prompt, valid string) byte {
+	for {
+		fmt.Print(prompt)
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return 'q'
+		}
+		input = strings.ToLower(strings.TrimSpace(input))
+		if len(input) == 1 && strings.Contains(valid, input) {
+			return input[0]
+		}
+		for _, c := range valid {
+			fmt.Printf("  %c - %s\n", c, askHelp[c])
+		}
+	}
+}
+
+var askHelp = map[rune]string{
+	'y': "restore",
+	'n': "keep the current content",
+	'a': "restore this and all later hunks in the file",
+	'd': "keep this and all later hunks in the file",
+	'q': "quit; what was chosen so far is restored",
+}
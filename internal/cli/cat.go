@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+var catLast bool
+
+var catCmd = &cobra.Command{
+	Use:   "cat <checkpoint-id> <path>",
+	Short: "Print a file from a checkpoint",
+	Long: `Writes the backed-up content of a single file to stdout, without
+rolling anything back. Compressed checkpoints are read in place.
+
+The path can be absolute, relative to the current directory or to the
+directory the checkpoint was created in, or a unique end of the path.
+
+Options:
+  --last    Read from the most recent checkpoint (then only <path> is given)
+
+Examples:
+  safeshell cat 2024-12-12T143022-a1b2c3 src/main.go
+  safeshell cat --last config.json | jq .
+  safeshell cat --last src/main.go | diff - src/main.go`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeCatArgs,
+	RunE:              runCat,
+}
+
+func init() {
+	rootCmd.AddCommand(catCmd)
+	catCmd.Flags().BoolVarP(&catLast, "last", "l", false, "Read from the most recent checkpoint")
+	addAtFlag(catCmd)
+}
+
+func runCat(cmd *cobra.Command, args []string) error {
+	var cp *checkpoint.Checkpoint
+	var err error
+	var path string
+
+	if catLast || checkpointAt != "" {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: safeshell cat --last|--at <time> <path>")
+		}
+		path = args[0]
+		if cp, err = latestCheckpoint(); err != nil {
+			return err
+		}
+	} else {
+		if len(args) != 2 {
+			return fmt.Errorf("usage: safeshell cat <checkpoint-id> <path>")
+		}
+		path = args[1]
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
+		}
+	}
+
+	file, err := checkpoint.FindFile(cp, path)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := checkpoint.StreamFile(cp, file, out); err != nil {
+		return err
+	}
+	return out.Flush()
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkpointName    string
+	checkpointReason  string
+	checkpointInclude []string
+)
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint",
+	Short: "Create checkpoints by hand",
+}
+
+var checkpointCreateCmd = &cobra.Command{
+	Use:   "create <path>...",
+	Short: "Checkpoint files before doing something risky",
+	Long: `Backs up files and directories now, without running a command.
+
+Checkpoints are created automatically before wrapped commands like rm and
+mv. Create one by hand before changes those don't cover, such as a database
+migration or a large refactor.
+
+A checkpoint can be given a name to refer to it by instead of its ID, in
+rollback, diff, compress and every other command taking a checkpoint ID.
+Names are unique; 'safeshell tag --name' names or renames a checkpoint
+later.
+
+Stopped with Ctrl-C, or killed, the files backed up so far are kept:
+'safeshell checkpoint resume' finishes the checkpoint, backing up only
+what it hadn't yet or what changed since.
+
+Options:
+  --name     Name the checkpoint: letters, digits, '.', '_' and '-'
+  --reason   What the checkpoint is for, shown where the command of
+             automatic checkpoints is (default: "manual checkpoint")
+  --include  Only back up files matching this pattern, or in a directory
+             that does (repeatable). Patterns work like exclude_paths and
+             replace include_paths from the config
+
+Examples:
+  safeshell checkpoint create --name pre-migration .
+  safeshell checkpoint create --reason "before refactor" src/ go.mod
+  safeshell checkpoint create --include '*.go' --include '*.sql' .
+  safeshell rollback pre-migration`,
+	Args:        cobra.MinimumNArgs(1),
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runCheckpointCreate,
+}
+
+var checkpointResumeCmd = &cobra.Command{
+	Use:   "resume [id]",
+	Short: "Finish a checkpoint whose creation was interrupted",
+	Long: `Finishes creating a checkpoint that 'safeshell checkpoint create' was
+interrupted creating, by Ctrl-C or a kill. Without an ID, the latest one.
+
+The backups it made are kept for the files that haven't changed since,
+once their content is checked against the files by hash; the others are
+backed up again. A checkpoint of a huge directory needn't start over.
+
+Interrupted checkpoints don't show in 'safeshell list' until finished, and
+'safeshell gc' removes them an hour after they were last worked on.
+
+Examples:
+  safeshell checkpoint resume
+  safeshell checkpoint resume 2024-12-12T143522-d4e5f6a7`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runCheckpointResume,
+}
+
+func init() {
+	rootCmd.AddCommand(checkpointCmd)
+	checkpointCmd.AddCommand(checkpointCreateCmd)
+	checkpointCmd.AddCommand(checkpointResumeCmd)
+	checkpointCreateCmd.Flags().StringVar(&checkpointName, "name", "", "Name to refer to the checkpoint by")
+	checkpointCreateCmd.Flags().StringVar(&checkpointReason, "reason", "manual checkpoint", "What the checkpoint is for")
+	checkpointCreateCmd.Flags().StringArrayVar(&checkpointInclude, "include", nil, "Only back up files matching this pattern (repeatable)")
+}
+
+func runCheckpointCreate(cmd *cobra.Command, args []string) error {
+	defer showProgress()()
+
+	for _, path := range args {
+		if _, err := os.Lstat(path); err != nil {
+			return err
+		}
+	}
+
+	// Ctrl-C stops it, keeping what was copied so far for resuming
+	ctx, stop := interruptible()
+	defer stop()
+	cp, err := checkpoint.CreateResumable(ctx, checkpointName, checkpointReason, args, checkpointInclude)
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	return printCreated(cp)
+}
+
+func runCheckpointResume(cmd *cobra.Command, args []string) error {
+	defer showProgress()()
+
+	var id string
+	var err error
+	if len(args) > 0 {
+		id = args[0]
+	} else if id, err = checkpoint.LatestResumable(); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	cp, err := checkpoint.ResumeCreate(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to resume checkpoint: %w", err)
+	}
+	return printCreated(cp)
+}
+
+// printCreated tells that cp was created, and how to roll back to it
+func printCreated(cp *checkpoint.Checkpoint) error {
+	fileCount := 0
+	for _, f := range cp.Manifest.Files {
+		if !f.IsDir {
+			fileCount++
+		}
+	}
+	msg := fmt.Sprintf("Checkpoint created: %s (%d files)", cp.ID, fileCount)
+	if cp.Manifest.Name != "" {
+		msg = fmt.Sprintf("Checkpoint %s created: %s (%d files)", cp.Manifest.Name, cp.ID, fileCount)
+	}
+	printSuccess(msg)
+	if humanOutput() {
+		ref := cp.Manifest.Name
+		if ref == "" {
+			ref = cp.ID
+		}
+		printInfo(fmt.Sprintf("Restore with: safeshell rollback %s", ref))
+	}
+	return printResult(newCheckpointJSON(cp, fileCount))
+}
@@ -2,20 +2,26 @@ package cli
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
 	"github.com/qhkm/safeshell/internal/config"
-	"github.com/qhkm/safeshell/internal/util"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cleanOlderThan  string
-	cleanDryRun     bool
-	cleanCompress   bool
-	cleanKeepCount  int
+	cleanOlderThan   string
+	cleanDryRun      bool
+	cleanCompress    bool
+	cleanKeepCount   int
+	cleanKeepSession int
+	cleanReportFile  string
+	cleanReportEmail bool
+	cleanVerify      int
 )
 
 var cleanCmd = &cobra.Command{
@@ -24,12 +30,26 @@ var cleanCmd = &cobra.Command{
 	Long: `Removes or compresses checkpoints older than the specified duration.
 
 By default, uses the retention period from config (default: 7 days).
+Pinned checkpoints ('safeshell pin') are never deleted.
 
 Options:
   --older-than    Duration threshold for cleanup (e.g., 7d, 24h)
   --compress      Compress instead of delete (saves 60-80% space)
   --keep          Keep at least N most recent checkpoints
+  --keep-per-session
+                  Never delete the newest N checkpoints of each session
+                  (default: keep_per_session from config)
   --dry-run       Show what would be done without doing it
+  --verify-sample Before deleting, verify N random checkpoints of those that
+                  will remain. If any is corrupt nothing is deleted, so the
+                  older copies are still there to recover from.
+  --report-file   Write a report of the run (--report-file=PATH, default
+                  ~/.safeshell/reports/clean-report.txt)
+  --report-email-style
+                  Format the report as an email, ready for 'sendmail -t'
+
+The last reported run is summarized by 'safeshell schedule'. Dry runs are
+never reported.
 
 Examples:
   safeshell clean                      # Delete checkpoints older than config retention
@@ -37,8 +57,14 @@ Examples:
   safeshell clean --compress           # Compress old checkpoints instead of deleting
   safeshell clean --older-than 1d --compress  # Compress checkpoints older than 1 day
   safeshell clean --keep 10            # Delete all but the 10 most recent
-  safeshell clean --dry-run            # Show what would be deleted`,
-	RunE: runClean,
+  safeshell clean --older-than 1d --keep-per-session 1  # Keep each session's last state
+  safeshell clean --dry-run            # Show what would be deleted
+  safeshell clean --verify-sample 5    # Spot-check 5 remaining checkpoints first
+  safeshell clean --report-file        # Record the run for 'safeshell schedule'
+  safeshell clean --report-file=/tmp/clean.eml --report-email-style
+  safeshell clean --dry-run --json     # The report of the run, for scripts`,
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runClean,
 }
 
 func init() {
@@ -46,9 +72,43 @@ func init() {
 	cleanCmd.Flags().BoolVarP(&cleanDryRun, "dry-run", "d", false, "Show what would be done without doing it")
 	cleanCmd.Flags().BoolVarP(&cleanCompress, "compress", "c", false, "Compress old checkpoints instead of deleting")
 	cleanCmd.Flags().IntVarP(&cleanKeepCount, "keep", "k", 0, "Keep at least N most recent checkpoints")
+	cleanCmd.Flags().IntVar(&cleanKeepSession, "keep-per-session", -1, "Never delete the newest N checkpoints of each session")
+	cleanCmd.Flags().StringVar(&cleanReportFile, "report-file", "", "Write a report of the run to this file")
+	cleanCmd.Flags().Lookup("report-file").NoOptDefVal = defaultReportFile
+	cleanCmd.Flags().BoolVar(&cleanReportEmail, "report-email-style", false, "Format the report as an email")
+	cleanCmd.Flags().IntVar(&cleanVerify, "verify-sample", 0, "Verify N random remaining checkpoints before deleting")
 }
 
 func runClean(cmd *cobra.Command, args []string) error {
+	action := "delete"
+	if cleanCompress {
+		action = "compress"
+	}
+	report := newCleanReport(action)
+	report.DryRun = cleanDryRun
+	bytesBefore, _ := checkpoint.GetDiskUsage(config.GetCheckpointsDir())
+
+	err := clean(report)
+	report.finish(err, bytesBefore)
+
+	if (cleanReportFile != "" || cleanReportEmail) && !cleanDryRun {
+		if reportErr := writeCleanReport(report, cleanReportFile, cleanReportEmail); reportErr != nil {
+			printWarning(fmt.Sprintf("Could not write clean report: %v", reportErr))
+		}
+	}
+	// A failed run is reported too, the error saying what went wrong
+	if printErr := printResult(report); err == nil {
+		err = printErr
+	}
+	return err
+}
+
+func clean(report *cleanReport) error {
+	keepPerSession := cleanKeepSession
+	if keepPerSession < 0 {
+		keepPerSession = config.Get().KeepPerSession
+	}
+
 	var duration time.Duration
 
 	if cleanOlderThan != "" {
@@ -65,31 +125,51 @@ func runClean(cmd *cobra.Command, args []string) error {
 
 	// Handle --keep option
 	if cleanKeepCount > 0 {
-		return cleanKeepN(cleanKeepCount, cleanDryRun, cleanCompress)
+		return cleanKeepN(cleanKeepCount, keepPerSession, cleanDryRun, cleanCompress, report)
 	}
 
 	// Handle --compress option
 	if cleanCompress {
-		return cleanWithCompress(duration, cleanDryRun)
+		return cleanWithCompress(duration, cleanDryRun, report)
 	}
 
-	if cleanDryRun {
-		// Dry run - just show what would be deleted
-		checkpoints, err := checkpoint.List()
-		if err != nil {
-			return err
+	checkpoints, err := checkpoint.List()
+	if err != nil {
+		return err
+	}
+
+	if minRetention := time.Duration(config.MinRetentionDays()) * 24 * time.Hour; duration < minRetention {
+		duration = minRetention
+	}
+
+	cutoff := time.Now().Add(-duration)
+	keep := checkpoint.Keepers(checkpoints, keepPerSession)
+
+	if cleanVerify > 0 {
+		var remaining []*checkpoint.Checkpoint
+		for _, cp := range checkpoints {
+			if !cp.CreatedAt.Before(cutoff) || keep[cp.ID] {
+				remaining = append(remaining, cp)
+			}
 		}
+		if err := verifyRemaining(remaining, report); err != nil {
+			return fmt.Errorf("%w; nothing was deleted", err)
+		}
+	}
 
-		cutoff := time.Now().Add(-duration)
+	if cleanDryRun {
+		// Dry run - just show what would be deleted
 		toDelete := 0
 
 		for _, cp := range checkpoints {
-			if cp.CreatedAt.Before(cutoff) {
-				fmt.Printf("Would delete: %s (%s)\n", cp.ID, util.FormatTimeAgo(cp.CreatedAt))
+			if cp.CreatedAt.Before(cutoff) && !keep[cp.ID] {
+				fmt.Printf("Would delete: %s (%s)\n", cp.ID, output.FormatTimeAgo(cp.CreatedAt))
 				toDelete++
 			}
 		}
 
+		report.Processed = toDelete
+
 		if toDelete == 0 {
 			fmt.Println("No checkpoints to delete.")
 		} else {
@@ -98,10 +178,11 @@ func runClean(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	deleted, err := checkpoint.Clean(duration)
+	deleted, err := checkpoint.CleanKeepingSessions(duration, keepPerSession)
 	if err != nil {
 		return fmt.Errorf("failed to clean checkpoints: %w", err)
 	}
+	report.Processed = deleted
 
 	if deleted == 0 {
 		fmt.Println("No checkpoints to clean.")
@@ -112,50 +193,59 @@ func runClean(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func cleanWithCompress(duration time.Duration, dryRun bool) error {
+func cleanWithCompress(duration time.Duration, dryRun bool, report *cleanReport) error {
 	checkpoints, err := checkpoint.List()
 	if err != nil {
 		return err
 	}
 
+	// Compressing keeps every checkpoint, so a corrupt one is only reported
+	if err := verifyRemaining(checkpoints, report); err != nil {
+		printWarning(err.Error())
+		report.warn(err.Error())
+	}
+
 	cutoff := time.Now().Add(-duration)
 	toCompress := 0
 	var totalOriginal, totalCompressed int64
 
 	for _, cp := range checkpoints {
-		if cp.CreatedAt.Before(cutoff) && !cp.Manifest.Compressed {
+		if cp.CreatedAt.Before(cutoff) && !cp.Manifest.Compressed && cp.Manifest.FSSnapshot == nil {
 			if dryRun {
-				fmt.Printf("Would compress: %s (%s)\n", cp.ID, util.FormatTimeAgo(cp.CreatedAt))
+				fmt.Printf("Would compress: %s (%s)\n", cp.ID, output.FormatTimeAgo(cp.CreatedAt))
 				toCompress++
 			} else {
 				fmt.Printf("Compressing: %s...\n", cp.ID)
 				originalSize, compressedSize, err := checkpoint.Compress(cp.ID)
 				if err != nil {
 					color.Yellow("  Warning: %v\n", err)
+					report.warn(fmt.Sprintf("compress %s: %v", cp.ID, err))
 					continue
 				}
 				totalOriginal += originalSize
 				totalCompressed += compressedSize
 				toCompress++
 				ratio := float64(compressedSize) / float64(originalSize) * 100
-				fmt.Printf("  %s → %s (%.1f%%)\n", util.FormatBytes(originalSize), util.FormatBytes(compressedSize), ratio)
+				fmt.Printf("  %s → %s (%.1f%%)\n", output.FormatBytes(originalSize), output.FormatBytes(compressedSize), ratio)
 			}
 		}
 	}
 
+	report.Processed = toCompress
+
 	if toCompress == 0 {
 		fmt.Println("No checkpoints to compress.")
 	} else if dryRun {
 		fmt.Printf("\nWould compress %d checkpoint(s). Run without --dry-run to compress.\n", toCompress)
 	} else {
 		saved := totalOriginal - totalCompressed
-		color.Green("✓ Compressed %d checkpoint(s), saved %s\n", toCompress, util.FormatBytes(saved))
+		color.Green("✓ Compressed %d checkpoint(s), saved %s\n", toCompress, output.FormatBytes(saved))
 	}
 
 	return nil
 }
 
-func cleanKeepN(keepCount int, dryRun bool, compress bool) error {
+func cleanKeepN(keepCount, keepPerSession int, dryRun bool, compress bool, report *cleanReport) error {
 	checkpoints, err := checkpoint.List()
 	if err != nil {
 		return err
@@ -168,8 +258,26 @@ func cleanKeepN(keepCount int, dryRun bool, compress bool) error {
 
 	// Checkpoints are sorted newest first, so we skip the first N
 	toProcess := checkpoints[keepCount:]
+	keep := checkpoint.Keepers(checkpoints, keepPerSession)
 	processed := 0
 
+	remaining := checkpoints
+	if !compress {
+		remaining = append([]*checkpoint.Checkpoint(nil), checkpoints[:keepCount]...)
+		for _, cp := range toProcess {
+			if keep[cp.ID] {
+				remaining = append(remaining, cp)
+			}
+		}
+	}
+	if err := verifyRemaining(remaining, report); err != nil {
+		if !compress {
+			return fmt.Errorf("%w; nothing was deleted", err)
+		}
+		printWarning(err.Error())
+		report.warn(err.Error())
+	}
+
 	action := "delete"
 	if compress {
 		action = "compress"
@@ -179,9 +287,12 @@ func cleanKeepN(keepCount int, dryRun bool, compress bool) error {
 		if compress && cp.Manifest.Compressed {
 			continue // Already compressed
 		}
+		if !compress && keep[cp.ID] {
+			continue // Last state of its session
+		}
 
 		if dryRun {
-			fmt.Printf("Would %s: %s (%s)\n", action, cp.ID, util.FormatTimeAgo(cp.CreatedAt))
+			fmt.Printf("Would %s: %s (%s)\n", action, cp.ID, output.FormatTimeAgo(cp.CreatedAt))
 			processed++
 		} else {
 			if compress {
@@ -189,11 +300,13 @@ func cleanKeepN(keepCount int, dryRun bool, compress bool) error {
 				_, _, err := checkpoint.Compress(cp.ID)
 				if err != nil {
 					color.Yellow("  Warning: %v\n", err)
+					report.warn(fmt.Sprintf("compress %s: %v", cp.ID, err))
 					continue
 				}
 			} else {
 				if err := checkpoint.Delete(cp.ID); err != nil {
 					color.Yellow("Warning: failed to delete %s: %v\n", cp.ID, err)
+					report.warn(fmt.Sprintf("delete %s: %v", cp.ID, err))
 					continue
 				}
 			}
@@ -201,6 +314,8 @@ func cleanKeepN(keepCount int, dryRun bool, compress bool) error {
 		}
 	}
 
+	report.Processed = processed
+
 	if processed == 0 {
 		fmt.Printf("No checkpoints to %s.\n", action)
 	} else if dryRun {
@@ -216,6 +331,34 @@ func cleanKeepN(keepCount int, dryRun bool, compress bool) error {
 	return nil
 }
 
+// verifyRemaining checks a random sample of --verify-sample checkpoints out
+// of those a clean leaves behind. Corruption is best found while the older
+// checkpoints are still there to recover from, so it is returned as an error.
+func verifyRemaining(remaining []*checkpoint.Checkpoint, report *cleanReport) error {
+	if cleanVerify <= 0 {
+		return nil
+	}
+
+	problems, checked := checkpoint.VerifySample(remaining, cleanVerify)
+	report.Verified = checked
+	if len(problems) == 0 {
+		printInfo(fmt.Sprintf("Verified %d remaining checkpoint(s)", checked))
+		return nil
+	}
+
+	for id := range problems {
+		report.Corrupt = append(report.Corrupt, id)
+	}
+	sort.Strings(report.Corrupt)
+	for _, id := range report.Corrupt {
+		color.Red("Corrupt: %s\n", id)
+		for _, line := range strings.Split(problems[id].Error(), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return fmt.Errorf("%d of %d verified checkpoint(s) are corrupt, inspect them with 'safeshell show' or delete them", len(problems), checked)
+}
+
 // parseDuration parses a duration string with support for days (d) and weeks (w)
 func parseDuration(s string) (time.Duration, error) {
 	if len(s) == 0 {
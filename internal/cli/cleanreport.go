@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// defaultReportFile is the --report-file value used when the flag is given
+// without a path
+const defaultReportFile = "default"
+
+// cleanReport describes one clean run, for cron users who never see its
+// output. The last run is also saved as JSON for 'safeshell schedule'.
+type cleanReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Command    string    `json:"command"`
+	Action     string    `json:"action"` // delete or compress
+	DryRun     bool      `json:"dry_run,omitempty"`
+	Processed  int       `json:"processed"`
+	BytesFreed int64     `json:"bytes_freed"`
+	Remaining  int       `json:"remaining"`
+	Verified   int       `json:"verified,omitempty"`
+	Corrupt    []string  `json:"corrupt,omitempty"`
+	Warnings   []string  `json:"warnings,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	ReportFile string    `json:"report_file,omitempty"`
+}
+
+func cleanReportDir() string {
+	return filepath.Join(config.GetSafeShellDir(), "reports")
+}
+
+// lastCleanRunPath is where the last run's summary is recorded
+func lastCleanRunPath() string {
+	return filepath.Join(cleanReportDir(), "clean-last.json")
+}
+
+func newCleanReport(action string) *cleanReport {
+	return &cleanReport{
+		StartedAt: time.Now(),
+		Command:   "safeshell " + strings.Join(os.Args[1:], " "),
+		Action:    action,
+	}
+}
+
+func (r *cleanReport) warn(msg string) {
+	r.Warnings = append(r.Warnings, msg)
+}
+
+// Status is a one-line outcome, also used as the email subject
+func (r *cleanReport) Status() string {
+	if r.Error != "" {
+		return "FAILED: " + r.Error
+	}
+	verb := "deleted"
+	if r.Action == "compress" {
+		verb = "compressed"
+	}
+	s := fmt.Sprintf("%s %d checkpoint(s)", verb, r.Processed)
+	if r.BytesFreed > 0 {
+		s += fmt.Sprintf(", freed %s", output.FormatBytes(r.BytesFreed))
+	}
+	if len(r.Corrupt) > 0 {
+		s += fmt.Sprintf(", %d corrupt", len(r.Corrupt))
+	}
+	if len(r.Warnings) > 0 {
+		s += fmt.Sprintf(", %d warning(s)", len(r.Warnings))
+	}
+	return s
+}
+
+// Render formats the report as plain text, or as an RFC 5322 message that
+// can be piped to 'sendmail -t' when emailStyle is set
+func (r *cleanReport) Render(emailStyle bool) string {
+	var sb strings.Builder
+	if emailStyle {
+		host, _ := os.Hostname()
+		fmt.Fprintf(&sb, "Subject: [safeshell] Cleanup on %s: %s\n", host, r.Status())
+		fmt.Fprintf(&sb, "Date: %s\n", r.FinishedAt.Format(time.RFC1123Z))
+		sb.WriteString("Content-Type: text/plain; charset=utf-8\n\n")
+	}
+
+	sb.WriteString("SafeShell cleanup report\n\n")
+	fmt.Fprintf(&sb, "Started:   %s\n", r.StartedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&sb, "Finished:  %s\n", r.FinishedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&sb, "Command:   %s\n", r.Command)
+	fmt.Fprintf(&sb, "Result:    %s\n", r.Status())
+	fmt.Fprintf(&sb, "Remaining: %d checkpoint(s)\n", r.Remaining)
+	if r.Verified > 0 {
+		fmt.Fprintf(&sb, "Verified:  %d checkpoint(s), %d corrupt\n", r.Verified, len(r.Corrupt))
+	}
+
+	if len(r.Corrupt) > 0 {
+		sb.WriteString("\nCorrupt checkpoints:\n")
+		for _, id := range r.Corrupt {
+			fmt.Fprintf(&sb, "  - %s\n", id)
+		}
+	}
+
+	if len(r.Warnings) > 0 {
+		sb.WriteString("\nWarnings:\n")
+		for _, w := range r.Warnings {
+			fmt.Fprintf(&sb, "  - %s\n", w)
+		}
+	}
+	return sb.String()
+}
+
+// finish fills in the outcome of the run
+func (r *cleanReport) finish(runErr error, bytesBefore int64) {
+	r.FinishedAt = time.Now()
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	if bytesAfter, err := checkpoint.GetDiskUsage(config.GetCheckpointsDir()); err == nil && bytesBefore > bytesAfter {
+		r.BytesFreed = bytesBefore - bytesAfter
+	}
+	if checkpoints, err := checkpoint.List(); err == nil {
+		r.Remaining = len(checkpoints)
+	}
+}
+
+// writeCleanReport writes the report to path and records it as the last run
+func writeCleanReport(r *cleanReport, path string, emailStyle bool) error {
+
+	if path == "" || path == defaultReportFile {
+		path = filepath.Join(cleanReportDir(), "clean-report.txt")
+	}
+	r.ReportFile = path
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(r.Render(emailStyle)), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cleanReportDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	return os.WriteFile(lastCleanRunPath(), data, 0644)
+}
+
+// loadLastCleanRun returns the last recorded clean run, or nil if none
+func loadLastCleanRun() *cleanReport {
+	data, err := os.ReadFile(lastCleanRunPath())
+	if err != nil {
+		return nil
+	}
+	var r cleanReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil
+	}
+	return &r
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "Generate a shell completion script",
+	Long: `Prints a script that makes the shell complete safeshell commands, flags,
+checkpoint IDs, tags and the paths backed up in a checkpoint on TAB.
+
+Examples:
+  # bash (needs the bash-completion package)
+  safeshell completion bash > ~/.local/share/bash-completion/completions/safeshell
+
+  # zsh (the directory must be in $fpath, before compinit runs)
+  safeshell completion zsh > "${fpath[1]}/_safeshell"
+
+  # fish
+  safeshell completion fish > ~/.config/fish/completions/safeshell.fish
+
+Start a new shell afterwards.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE:      runCompletion,
+	// Generating the script doesn't need config
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	switch args[0] {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(os.Stdout)
+	case "fish":
+		return rootCmd.GenFishCompletion(os.Stdout, true)
+	}
+	return fmt.Errorf("unsupported shell %q (use bash, zsh or fish)", args[0])
+}
+
+// The functions below complete arguments and flags. They read the
+// checkpoint index rather than every manifest, so TAB stays fast with many
+// checkpoints.
+
+// completeCheckpointID completes the checkpoint ID of commands taking one,
+// unless --last already chose it
+func completeCheckpointID(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || lastFlag(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return checkpointIDs(args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeCheckpointIDs completes every argument of commands taking any
+// number of checkpoint IDs
+func completeCheckpointIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return checkpointIDs(args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// checkpointIDs returns the IDs and names starting with prefix, newest
+// first, each described by its command and age. Those in args are left out.
+func checkpointIDs(args []string, prefix string) []string {
+	var ids []string
+	for _, e := range checkpoint.DefaultStore().Index().ListEntries() {
+		desc := fmt.Sprintf("%s (%s)", e.Command, output.FormatTimeAgo(e.Timestamp))
+		if e.Name != "" && strings.HasPrefix(e.Name, prefix) && !slices.Contains(args, e.Name) {
+			ids = append(ids, e.Name+"\t"+desc)
+		}
+		if !strings.HasPrefix(e.ID, prefix) || slices.Contains(args, e.ID) {
+			continue
+		}
+		ids = append(ids, e.ID+"\t"+desc)
+	}
+	return ids
+}
+
+// completeTags completes the tags used on any checkpoint
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, e := range checkpoint.DefaultStore().Index().ListEntries() {
+		for _, tag := range e.Tags {
+			if !seen[tag] && strings.HasPrefix(tag, toComplete) && !slices.Contains(args, tag) {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTagArgs completes tag's checkpoint ID, then tags
+func completeTagArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 && !lastFlag(cmd) {
+		return completeCheckpointID(cmd, args, toComplete)
+	}
+	return completeTags(cmd, args, toComplete)
+}
+
+// completeCatArgs completes cat's checkpoint ID, then a path in it
+func completeCatArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 && !lastFlag(cmd) {
+		return completeCheckpointID(cmd, args, toComplete)
+	}
+	if len(args) == 2 || (len(args) == 1 && lastFlag(cmd)) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeCheckpointPath(cmd, args, toComplete)
+}
+
+// completeMountArgs completes mount's checkpoint ID, then a directory
+func completeMountArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeCheckpointID(cmd, args, toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// completeCheckpointPath completes a path backed up in the checkpoint given
+// as the first argument, or the latest with --last, a directory at a time
+func completeCheckpointPath(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cp := completionCheckpoint(cmd, args)
+	if cp == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	paths := make([]string, 0, len(cp.Manifest.Files))
+	for _, f := range cp.Manifest.Files {
+		if f.IsDir {
+			paths = append(paths, f.OriginalPath+string(filepath.Separator))
+		} else {
+			paths = append(paths, f.OriginalPath)
+		}
+	}
+	matches := pathCompletions(paths, toComplete)
+	directive := cobra.ShellCompDirectiveNoFileComp
+	for _, m := range matches {
+		// Keep descending instead of ending the word at a directory
+		if strings.HasSuffix(m, string(filepath.Separator)) {
+			directive |= cobra.ShellCompDirectiveNoSpace
+			break
+		}
+	}
+	return matches, directive
+}
+
+// completeCheckpointPaths is completeCheckpointPath for a comma-separated
+// list such as rollback --files, completing its last element
+func completeCheckpointPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	i := strings.LastIndex(toComplete, ",")
+	paths, directive := completeCheckpointPath(cmd, args, toComplete[i+1:])
+	for j := range paths {
+		paths[j] = toComplete[:i+1] + paths[j]
+	}
+	return paths, directive
+}
+
+// completionCheckpoint loads the checkpoint path completions are from
+func completionCheckpoint(cmd *cobra.Command, args []string) *checkpoint.Checkpoint {
+	var cp *checkpoint.Checkpoint
+	var err error
+	switch {
+	case lastFlag(cmd):
+		cp, err = latestCheckpoint()
+	case len(args) > 0:
+		cp, err = getCheckpoint(args[0])
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return cp
+}
+
+// pathCompletions returns the paths starting with prefix, cut after the
+// next directory separator so each TAB descends one directory
+func pathCompletions(paths []string, prefix string) []string {
+	seen := make(map[string]bool)
+	var matches []string
+	for _, p := range paths {
+		if !strings.HasPrefix(p, prefix) || p == prefix {
+			continue
+		}
+		if i := strings.IndexRune(p[len(prefix):], filepath.Separator); i >= 0 {
+			p = p[:len(prefix)+i+1]
+		}
+		if !seen[p] {
+			seen[p] = true
+			matches = append(matches, p)
+		}
+	}
+	return matches
+}
+
+// lastFlag reports whether the command has a --last or --at flag that is
+// set, standing in for the checkpoint ID
+func lastFlag(cmd *cobra.Command) bool {
+	if at, err := cmd.Flags().GetString("at"); err == nil && at != "" {
+		return true
+	}
+	last, err := cmd.Flags().GetBool("last")
+	return err == nil && last
+}
@@ -2,10 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
-	"github.com/qhkm/safeshell/internal/util"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -19,7 +20,11 @@ var (
 var compressCmd = &cobra.Command{
 	Use:   "compress [checkpoint-id]",
 	Short: "Compress checkpoints to save disk space",
-	Long: `Compress checkpoint files into .tar.gz archives to save disk space.
+	Long: `Compress checkpoint files into archives to save disk space.
+
+The archive format is set by compression_algorithm (gzip, zstd, or none)
+and compression_level. Checkpoints compressed with any format can be
+decompressed regardless of the current setting.
 
 Compressed checkpoints are automatically decompressed when you rollback.
 Typical space savings: 60-80% for text files.
@@ -34,19 +39,51 @@ Examples:
   safeshell compress 2024-12-12T143022-a1b2c3  # Compress specific checkpoint
   safeshell compress --all                     # Compress all checkpoints
   safeshell compress --older-than 3d           # Compress checkpoints older than 3 days
-  safeshell compress --last --decompress       # Decompress most recent checkpoint`,
-	RunE: runCompress,
+  safeshell compress --last --decompress       # Decompress most recent checkpoint
+  safeshell compress --all --json              # Report sizes per checkpoint, for scripts`,
+	Annotations:       map[string]string{resultAnnotation: ""},
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runCompress,
+}
+
+// compressJSON is what compress prints in JSON
+type compressJSON struct {
+	Action      string           `json:"action"` // compress or decompress
+	Checkpoints []compressedJSON `json:"checkpoints"`
+	Saved       int64            `json:"saved"`
+	Warnings    []string         `json:"warnings,omitempty"`
+}
+
+// compressedJSON is a checkpoint compress acted on. Sizes are 0 when
+// decompressing.
+type compressedJSON struct {
+	ID             string `json:"id"`
+	OriginalSize   int64  `json:"original_size"`
+	CompressedSize int64  `json:"compressed_size"`
+}
+
+func newCompressJSON(action string) *compressJSON {
+	return &compressJSON{Action: action, Checkpoints: []compressedJSON{}}
+}
+
+// add records a checkpoint compressed from originalSize to compressedSize
+func (r *compressJSON) add(id string, originalSize, compressedSize int64) {
+	r.Checkpoints = append(r.Checkpoints, compressedJSON{ID: id, OriginalSize: originalSize, CompressedSize: compressedSize})
+	r.Saved += originalSize - compressedSize
 }
 
 func init() {
 	rootCmd.AddCommand(compressCmd)
 	compressCmd.Flags().BoolVarP(&compressLast, "last", "l", false, "Compress most recent checkpoint")
+	addAtFlag(compressCmd)
 	compressCmd.Flags().BoolVarP(&compressAll, "all", "a", false, "Compress all uncompressed checkpoints")
 	compressCmd.Flags().StringVar(&compressOlderThan, "older-than", "", "Compress checkpoints older than duration")
 	compressCmd.Flags().BoolVarP(&decompressFlag, "decompress", "d", false, "Decompress instead of compress")
 }
 
 func runCompress(cmd *cobra.Command, args []string) error {
+	defer showProgress()()
+
 	// Handle --older-than
 	if compressOlderThan != "" {
 		duration, err := parseDuration(compressOlderThan)
@@ -55,37 +92,25 @@ func runCompress(cmd *cobra.Command, args []string) error {
 		}
 
 		fmt.Printf("Compressing checkpoints older than %s...\n", compressOlderThan)
-		count, saved, err := checkpoint.CompressOlderThan(duration)
-		if err != nil {
-			return err
-		}
-
-		if count == 0 {
-			fmt.Println("No checkpoints to compress.")
-		} else {
-			color.Green("✓ Compressed %d checkpoint(s), saved %s\n", count, util.FormatBytes(saved))
-		}
-		return nil
+		return compressCheckpoints(duration)
 	}
 
 	// Handle --all
 	if compressAll {
-		return compressAllCheckpoints()
+		return compressCheckpoints(0)
 	}
 
 	// Handle specific checkpoint or --last
 	var cp *checkpoint.Checkpoint
 	var err error
 
-	if compressLast {
-		cp, err = checkpoint.GetLatest()
-		if err != nil {
-			return fmt.Errorf("no checkpoints found")
+	if compressLast || checkpointAt != "" {
+		if cp, err = latestCheckpoint(); err != nil {
+			return err
 		}
 	} else if len(args) > 0 {
-		cp, err = checkpoint.Get(args[0])
-		if err != nil {
-			return fmt.Errorf("checkpoint not found: %s", args[0])
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
 		}
 	} else {
 		return fmt.Errorf("please specify a checkpoint ID, use --last, --all, or --older-than")
@@ -99,14 +124,18 @@ func runCompress(cmd *cobra.Command, args []string) error {
 }
 
 func compressCheckpoint(cp *checkpoint.Checkpoint) error {
+	result := newCompressJSON("compress")
 	if cp.Manifest.Compressed {
-		color.Yellow("Checkpoint %s is already compressed (%s)\n", cp.ID, util.FormatBytes(cp.Manifest.CompressedSize))
-		return nil
+		color.Yellow("Checkpoint %s is already compressed (%s)\n", cp.ID, output.FormatBytes(cp.Manifest.CompressedSize))
+		return printResult(result)
 	}
 
 	fmt.Printf("Compressing checkpoint %s...\n", cp.ID)
 
-	originalSize, compressedSize, err := checkpoint.Compress(cp.ID)
+	// Ctrl-C stops it, leaving the checkpoint uncompressed
+	ctx, stop := interruptible()
+	defer stop()
+	originalSize, compressedSize, err := checkpoint.CompressContext(ctx, cp.ID)
 	if err != nil {
 		return err
 	}
@@ -115,17 +144,19 @@ func compressCheckpoint(cp *checkpoint.Checkpoint) error {
 	ratio := float64(compressedSize) / float64(originalSize) * 100
 
 	color.Green("✓ Compressed checkpoint %s\n", cp.ID)
-	fmt.Printf("  Original:   %s\n", util.FormatBytes(originalSize))
-	fmt.Printf("  Compressed: %s (%.1f%%)\n", util.FormatBytes(compressedSize), ratio)
-	fmt.Printf("  Saved:      %s\n", util.FormatBytes(saved))
+	fmt.Printf("  Original:   %s\n", output.FormatBytes(originalSize))
+	fmt.Printf("  Compressed: %s (%.1f%%)\n", output.FormatBytes(compressedSize), ratio)
+	fmt.Printf("  Saved:      %s\n", output.FormatBytes(saved))
 
-	return nil
+	result.add(cp.ID, originalSize, compressedSize)
+	return printResult(result)
 }
 
 func decompressCheckpoint(cp *checkpoint.Checkpoint) error {
+	result := newCompressJSON("decompress")
 	if !cp.Manifest.Compressed {
 		color.Yellow("Checkpoint %s is not compressed\n", cp.ID)
-		return nil
+		return printResult(result)
 	}
 
 	fmt.Printf("Decompressing checkpoint %s...\n", cp.ID)
@@ -135,20 +166,23 @@ func decompressCheckpoint(cp *checkpoint.Checkpoint) error {
 	}
 
 	color.Green("✓ Decompressed checkpoint %s\n", cp.ID)
-	return nil
+	result.Checkpoints = append(result.Checkpoints, compressedJSON{ID: cp.ID})
+	return printResult(result)
 }
 
-func compressAllCheckpoints() error {
+// compressCheckpoints compresses the uncompressed checkpoints older than
+// olderThan, or all of them if it is 0
+func compressCheckpoints(olderThan time.Duration) error {
 	checkpoints, err := checkpoint.List()
 	if err != nil {
 		return err
 	}
 
-	compressed := 0
-	var totalSaved int64
+	result := newCompressJSON("compress")
+	cutoff := time.Now().Add(-olderThan)
 
 	for _, cp := range checkpoints {
-		if cp.Manifest.Compressed {
+		if cp.Manifest.Compressed || cp.Manifest.FSSnapshot != nil || olderThan > 0 && !cp.CreatedAt.Before(cutoff) {
 			continue
 		}
 
@@ -156,23 +190,21 @@ func compressAllCheckpoints() error {
 		originalSize, compressedSize, err := checkpoint.Compress(cp.ID)
 		if err != nil {
 			color.Yellow("  Warning: %v\n", err)
+			result.Warnings = append(result.Warnings, fmt.Sprintf("compress %s: %v", cp.ID, err))
 			continue
 		}
-
-		saved := originalSize - compressedSize
-		totalSaved += saved
-		compressed++
+		result.add(cp.ID, originalSize, compressedSize)
 
 		ratio := float64(compressedSize) / float64(originalSize) * 100
-		fmt.Printf("  %s → %s (%.1f%%)\n", util.FormatBytes(originalSize), util.FormatBytes(compressedSize), ratio)
+		fmt.Printf("  %s → %s (%.1f%%)\n", output.FormatBytes(originalSize), output.FormatBytes(compressedSize), ratio)
 	}
 
 	fmt.Println()
-	if compressed == 0 {
+	if len(result.Checkpoints) == 0 {
 		fmt.Println("No checkpoints to compress.")
 	} else {
-		color.Green("✓ Compressed %d checkpoint(s), total saved: %s\n", compressed, util.FormatBytes(totalSaved))
+		color.Green("✓ Compressed %d checkpoint(s), total saved: %s\n", len(result.Checkpoints), output.FormatBytes(result.Saved))
 	}
 
-	return nil
+	return printResult(result)
 }
@@ -2,10 +2,18 @@ package cli
 
 import (
 	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+	"github.com/qhkm/safeshell/internal/wrapper"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -21,16 +29,84 @@ Use 'set' to modify a setting.
 
 Available settings:
   retention_days       Days before 'safeshell clean' removes checkpoints (default: 7)
-  max_checkpoints      Maximum number of checkpoints to keep (default: 100)
+  keep_per_session     Newest checkpoints per session that clean never deletes (default: 0)
+  max_checkpoints      Maximum number of checkpoints to keep; creating one
+                       beyond it evicts the oldest that aren't pinned, 0 is
+                       no limit (default: 100)
+  max_checkpoints_action
+                       What happens to those: delete or compress them
+                       (default: delete)
   max_storage_mb       Total storage limit in MB (default: 5000)
+  max_storage_action   What creating a checkpoint does when the store is over
+                       it: compress or delete the oldest unpinned checkpoints
+                       until it is under, refuse to create one, or just warn
+                       (default: compress)
   max_file_size_mb     Skip files larger than this in MB (default: 100)
+  backup_workers       Files backed up at once when checkpointing a directory;
+                       more is faster on SSDs, 1 backs up one at a time
+                       (default: 0, one per CPU up to 8)
   warn_sensitive_files Warn when backing up sensitive files (default: true)
+  confirm_high_risk_mb Make wrapped high-risk commands (rm, rsync --delete,
+                       find -delete, ...) whose targets hold at least this many
+                       MB ask before running, once checkpointed; SAFESHELL_YES=1
+                       or 'wrap --yes' answers for scripts and agents, 0 is off
+                       (default: 0)
+  cloud_placeholders   Files only in the cloud (iCloud, OneDrive): skip them,
+                       or hydrate to download and back them up (default: skip)
+  fs_snapshots         Checkpoint directories by taking a filesystem snapshot
+                       instead of copying files: btrfs, zfs, apfs, auto for
+                       whichever the directory is on, or off; falls back to
+                       copying when no snapshot can be taken (default: off)
+  rm_strategy          How wrapped rm is checkpointed: copy its targets and run
+                       it, or trash to move them into the checkpoint instead,
+                       for an instant rm and a rollback that only renames them
+                       back; falls back to copy across filesystems (default: copy)
+  scope_to_project     Back up only the project (nearest directory up with
+                       .git, go.mod, package.json, ...) when a command
+                       targets a directory above it, e.g. rm -rf .. (default: false)
+  respect_gitignore    Don't back up files git ignores, following the
+                       .gitignore files of the repository (default: false)
+  git_aware            Don't back up tracked files with no changes since the
+                       last commit; rollback gets them back from git (default: false)
+  compression_algorithm Archive format for compressed checkpoints: gzip, zstd, none (default: gzip)
+  compression_level    Compression level, 0 for the algorithm default (default: 0)
+  preserve_macos_metadata
+                       Keep Finder tags, flags, resource forks and quarantine
+                       state of copied files on macOS (default: false)
+  preserve_times       Give restored files the modification and access times
+                       they had when backed up, instead of the time of the
+                       restore (default: false)
+  snapshots            Also write a daily snapshot of the index and every
+                       manifest, for 'safeshell snapshot restore' (default: true)
+  snapshot_dir         Where snapshots go, e.g. another disk (default:
+                       snapshots in safeshell_dir)
+  wrapper_messages     Where wrapped commands print safeshell's messages: stderr,
+                       log (~/.safeshell/wrapper.log) or off; SAFESHELL_MESSAGES
+                       overrides it (default: stderr)
+  log_level            How much safeshell tells: quiet (warnings only), normal or
+                       verbose (also each file backed up or restored);
+                       SAFESHELL_LOG_LEVEL overrides it (default: normal)
+  log_file             Also log everything to safeshell.log in safeshell_dir
+                       (default: false)
+  language             Language for messages: auto, en, es (default: auto, follows LANG)
+  diff_tool            Tool for 'diff --content': builtin, delta, difft, git (default: builtin)
+  mcp_require_checkpoint_minutes
+                       Refuse checkpoint_delete over MCP unless another checkpoint
+                       of the same paths is at most this old; 0 is off (default: 0)
+  hooks.pre_checkpoint, hooks.post_checkpoint, hooks.pre_rollback, hooks.post_rollback
+                       Shell commands run around checkpoints and rollbacks
+  hooks.timeout_seconds Seconds before a hook is killed (default: 60)
+  real_commands.<cmd>  Binary a wrapped command runs, instead of the first one on PATH
 
 Examples:
   safeshell config                          # Show all settings
   safeshell config get retention_days       # Get single value
   safeshell config set retention_days 3     # Set to 3 days
-  safeshell config set max_storage_mb 2000  # Set storage limit to 2GB`,
+  safeshell config set max_storage_mb 2000  # Set storage limit to 2GB
+  safeshell config set compression_algorithm zstd  # Faster, smaller archives
+  safeshell config set diff_tool delta      # Use delta for content diffs
+  safeshell config set hooks.pre_rollback "docker compose stop web"
+  safeshell config set real_commands.rm /run/current-system/sw/bin/rm`,
 	RunE: runConfig,
 }
 
@@ -40,12 +116,41 @@ func init() {
 
 // configKeys defines valid config keys with descriptions
 var configKeys = map[string]string{
-	"retention_days":       "Days before cleanup removes checkpoints",
-	"max_checkpoints":      "Maximum number of checkpoints to keep",
-	"max_storage_mb":       "Total storage limit in MB",
-	"max_file_size_mb":     "Skip files larger than this (MB)",
-	"warn_sensitive_files": "Warn when backing up sensitive files",
-	"safeshell_dir":        "SafeShell data directory",
+	"retention_days":          "Days before cleanup removes checkpoints",
+	"keep_per_session":        "Newest checkpoints per session that cleanup keeps",
+	"max_checkpoints":         "Maximum number of checkpoints to keep (0 = no limit)",
+	"max_checkpoints_action":  "Delete or compress the oldest checkpoints beyond max_checkpoints",
+	"max_storage_mb":          "Total storage limit in MB",
+	"max_storage_action":      "Over max_storage_mb: compress, delete, refuse or warn",
+	"max_file_size_mb":        "Skip files larger than this (MB)",
+	"backup_workers":          "Files backed up at once (0 = one per CPU, up to 8)",
+	"warn_sensitive_files":    "Warn when backing up sensitive files",
+	"confirm_high_risk_mb":    "Ask before high-risk commands on targets this large (MB, 0 = off)",
+	"cloud_placeholders":      "Cloud-only files: skip, or hydrate to download and back up",
+	"fs_snapshots":            "Snapshot directories instead of copying: off, auto, btrfs, zfs or apfs",
+	"rm_strategy":             "Wrapped rm: copy targets then run it, or trash to move them into the checkpoint",
+	"scope_to_project":        "Back up only the project when a command targets a directory above it",
+	"respect_gitignore":       "Don't back up files git ignores",
+	"git_aware":               "Don't back up unchanged tracked files, rollback gets them from git",
+	"safeshell_dir":           "SafeShell data directory",
+	"compression_algorithm":   "Archive format for compressed checkpoints (gzip, zstd, none)",
+	"compression_level":       "Compression level (0 = algorithm default)",
+	"preserve_macos_metadata": "Keep macOS Finder metadata (com.apple.* extended attributes)",
+	"preserve_times":          "Give restored files the times they had when backed up",
+	"snapshots":               "Write a daily snapshot of the index and manifests",
+	"snapshot_dir":            "Where snapshots go (default: snapshots in safeshell_dir)",
+	"wrapper_messages":        "Where wrapped commands print messages (stderr, log, off)",
+	"log_level":               "How much safeshell tells (quiet, normal, verbose)",
+	"log_file":                "Also log everything to safeshell.log",
+	"language":                "Language for messages (auto follows LANG)",
+	"diff_tool":               "Tool for content diffs (builtin, delta, difft, git)",
+	"hooks.pre_checkpoint":    "Command run before a checkpoint (failure aborts it)",
+	"hooks.post_checkpoint":   "Command run after a checkpoint",
+	"hooks.pre_rollback":      "Command run before a rollback (failure aborts it)",
+	"hooks.post_rollback":     "Command run after a rollback",
+	"hooks.timeout_seconds":   "Seconds before a hook is killed",
+
+	"mcp_require_checkpoint_minutes": "Refuse checkpoint_delete over MCP without a checkpoint this recent (0 = off)",
 }
 
 func runConfig(cmd *cobra.Command, args []string) error {
@@ -71,7 +176,7 @@ func runConfig(cmd *cobra.Command, args []string) error {
 
 	default:
 		// Treat as 'get' if it looks like a key
-		if _, ok := configKeys[action]; ok {
+		if _, ok := lookupConfigKey(action); ok {
 			return getConfig(action)
 		}
 		return fmt.Errorf("unknown action: %s (use 'get' or 'set')", action)
@@ -87,16 +192,65 @@ func showAllConfig() error {
 	// Storage settings
 	bold.Println("\nStorage:")
 	fmt.Printf("  max_storage_mb:       %v\n", viper.Get("max_storage_mb"))
+	fmt.Printf("  max_storage_action:   %v\n", viper.Get("max_storage_action"))
 	fmt.Printf("  max_file_size_mb:     %v\n", viper.Get("max_file_size_mb"))
+	fmt.Printf("  backup_workers:       %v\n", viper.Get("backup_workers"))
 	fmt.Printf("  max_checkpoints:      %v\n", viper.Get("max_checkpoints"))
+	fmt.Printf("  max_checkpoints_action: %v\n", viper.Get("max_checkpoints_action"))
+	fmt.Printf("  compression_algorithm: %v\n", viper.Get("compression_algorithm"))
+	fmt.Printf("  compression_level:    %v\n", viper.Get("compression_level"))
+	fmt.Printf("  preserve_macos_metadata: %v\n", viper.Get("preserve_macos_metadata"))
+	fmt.Printf("  preserve_times:       %v\n", viper.Get("preserve_times"))
+	fmt.Printf("  cloud_placeholders:   %v\n", viper.Get("cloud_placeholders"))
+	fmt.Printf("  fs_snapshots:         %v\n", viper.Get("fs_snapshots"))
+	fmt.Printf("  rm_strategy:          %v\n", viper.Get("rm_strategy"))
+	fmt.Printf("  snapshots:            %v\n", viper.Get("snapshots"))
+	if dir := viper.GetString("snapshot_dir"); dir != "" {
+		fmt.Printf("  snapshot_dir:         %v\n", dir)
+	}
 
 	// Cleanup settings
 	bold.Println("\nCleanup:")
 	fmt.Printf("  retention_days:       %v\n", viper.Get("retention_days"))
+	fmt.Printf("  keep_per_session:     %v\n", viper.Get("keep_per_session"))
 
 	// Security settings
 	bold.Println("\nSecurity:")
 	fmt.Printf("  warn_sensitive_files: %v\n", viper.Get("warn_sensitive_files"))
+	fmt.Printf("  confirm_high_risk_mb: %v\n", viper.Get("confirm_high_risk_mb"))
+	fmt.Printf("  scope_to_project:     %v\n", viper.Get("scope_to_project"))
+	fmt.Printf("  respect_gitignore:    %v\n", viper.Get("respect_gitignore"))
+	fmt.Printf("  git_aware:            %v\n", viper.Get("git_aware"))
+
+	// Display
+	bold.Println("\nDisplay:")
+	fmt.Printf("  language:             %v\n", viper.Get("language"))
+	fmt.Printf("  diff_tool:            %v\n", viper.Get("diff_tool"))
+	fmt.Printf("  wrapper_messages:     %v\n", viper.Get("wrapper_messages"))
+	fmt.Printf("  log_level:            %v\n", viper.Get("log_level"))
+	fmt.Printf("  log_file:             %v\n", viper.Get("log_file"))
+
+	// Hooks
+	bold.Println("\nHooks:")
+	for _, event := range []string{"pre_checkpoint", "post_checkpoint", "pre_rollback", "post_rollback"} {
+		if cmd := viper.GetString("hooks." + event); cmd != "" {
+			fmt.Printf("  %-20s %s\n", event+":", cmd)
+		}
+	}
+	fmt.Printf("  timeout_seconds:     %v\n", viper.Get("hooks.timeout_seconds"))
+
+	// Real command overrides
+	if overrides := viper.GetStringMapString("real_commands"); len(overrides) > 0 {
+		bold.Println("\nReal commands:")
+		names := make([]string, 0, len(overrides))
+		for name := range overrides {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %-20s %s\n", name+":", overrides[name])
+		}
+	}
 
 	// Paths
 	bold.Println("\nPaths:")
@@ -110,6 +264,16 @@ func showAllConfig() error {
 			fmt.Printf("  - %s\n", e)
 		}
 	}
+	if includes := viper.GetStringSlice("include_paths"); len(includes) > 0 {
+		bold.Println("\nInclude patterns:")
+		for _, e := range includes {
+			fmt.Printf("  - %s\n", e)
+		}
+	}
+	if disabled := viper.GetStringSlice("disabled_exclusions"); len(disabled) > 0 {
+		bold.Println("\nBuilt-in exclusions backed up anyway:")
+		fmt.Printf("  %s\n", strings.Join(disabled, ", "))
+	}
 
 	// Wrapped commands
 	wrapped := viper.GetStringSlice("wrapped_commands")
@@ -117,6 +281,75 @@ func showAllConfig() error {
 		bold.Println("\nWrapped commands:")
 		fmt.Printf("  %s\n", strings.Join(wrapped, ", "))
 	}
+	if custom := config.Get().CustomCommands; len(custom) > 0 {
+		bold.Println("\nCustom commands:")
+		for _, c := range custom {
+			targets := c.Targets
+			if targets == "" {
+				targets = "all-positional"
+			}
+			fmt.Printf("  - %s: %s\n", c.Name, targets)
+		}
+	}
+	if protected := config.Get().ProtectedPaths; len(protected) > 0 {
+		bold.Println("\nProtected paths:")
+		for _, p := range protected {
+			action := p.Action
+			if action == "" {
+				action = config.ProtectCheckpoint
+			}
+			fmt.Printf("  - %s: %s\n", p.Path, action)
+		}
+	}
+	if rules := config.Get().Rules; len(rules) > 0 {
+		bold.Println("\nRules:")
+		for i, r := range rules {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			fmt.Printf("  - %s: %s\n", name, r.Action)
+		}
+	}
+
+	// MCP tool exposure
+	enabledTools := viper.GetStringSlice("mcp_enabled_tools")
+	disabledTools := viper.GetStringSlice("mcp_disabled_tools")
+	requireMinutes := viper.GetInt("mcp_require_checkpoint_minutes")
+	if len(enabledTools) > 0 || len(disabledTools) > 0 || requireMinutes > 0 {
+		bold.Println("\nMCP tools:")
+		if len(enabledTools) > 0 {
+			fmt.Printf("  mcp_enabled_tools:    %s\n", strings.Join(enabledTools, ", "))
+		}
+		if len(disabledTools) > 0 {
+			fmt.Printf("  mcp_disabled_tools:   %s\n", strings.Join(disabledTools, ", "))
+		}
+		if requireMinutes > 0 {
+			fmt.Printf("  mcp_require_checkpoint_minutes: %d\n", requireMinutes)
+		}
+	}
+
+	// Organization policy
+	if policy := config.GetPolicy(); policy != nil {
+		bold.Println("\nOrganization policy:")
+		if policy.MinRetentionDays > 0 {
+			fmt.Printf("  min_retention_days:   %d\n", policy.MinRetentionDays)
+		}
+		if len(policy.ProtectedPaths) > 0 {
+			var paths []string
+			for _, p := range policy.ProtectedPaths {
+				paths = append(paths, p.Path)
+			}
+			fmt.Printf("  protected_paths:      %s\n", strings.Join(paths, ", "))
+		}
+		if len(policy.Rules) > 0 {
+			fmt.Printf("  rules:                %d (checked before yours)\n", len(policy.Rules))
+		}
+		if len(policy.DisabledFeatures) > 0 {
+			fmt.Printf("  disabled_features:    %s\n", strings.Join(policy.DisabledFeatures, ", "))
+		}
+		color.HiBlack("  Enforced by %s (cannot be overridden)", config.PolicyPath)
+	}
 
 	fmt.Println()
 	color.HiBlack("Config file: %s/config.yaml", viper.Get("safeshell_dir"))
@@ -129,7 +362,7 @@ func showAllConfig() error {
 
 func getConfig(key string) error {
 	// Validate key
-	if _, ok := configKeys[key]; !ok {
+	if _, ok := lookupConfigKey(key); !ok {
 		return fmt.Errorf("unknown config key: %s\n\nValid keys: %s",
 			key, strings.Join(getValidKeys(), ", "))
 	}
@@ -141,7 +374,7 @@ func getConfig(key string) error {
 
 func setConfig(key, value string) error {
 	// Validate key
-	desc, ok := configKeys[key]
+	desc, ok := lookupConfigKey(key)
 	if !ok {
 		return fmt.Errorf("unknown config key: %s\n\nValid keys: %s",
 			key, strings.Join(getValidKeys(), ", "))
@@ -157,7 +390,7 @@ func setConfig(key, value string) error {
 	var err error
 
 	switch key {
-	case "retention_days", "max_checkpoints", "max_storage_mb", "max_file_size_mb":
+	case "retention_days", "keep_per_session", "max_checkpoints", "max_storage_mb", "max_file_size_mb", "backup_workers", "hooks.timeout_seconds", "mcp_require_checkpoint_minutes", "confirm_high_risk_mb":
 		parsedValue, err = strconv.Atoi(value)
 		if err != nil {
 			return fmt.Errorf("%s must be a number", key)
@@ -165,8 +398,104 @@ func setConfig(key, value string) error {
 		if parsedValue.(int) < 0 {
 			return fmt.Errorf("%s must be non-negative", key)
 		}
+		if minDays := config.MinRetentionDays(); key == "retention_days" && parsedValue.(int) < minDays {
+			return fmt.Errorf("retention_days cannot be lower than %d (enforced by %s)", minDays, config.PolicyPath)
+		}
+
+	case "compression_algorithm":
+		lower := strings.ToLower(value)
+		if err := checkpoint.ValidateCompression(lower, 0); err != nil {
+			return err
+		}
+		parsedValue = lower
+
+	case "compression_level":
+		level, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+		if err := checkpoint.ValidateCompression(viper.GetString("compression_algorithm"), level); err != nil {
+			return err
+		}
+		parsedValue = level
 
-	case "warn_sensitive_files":
+	case "max_checkpoints_action":
+		lower := strings.ToLower(value)
+		if lower != checkpoint.EvictDelete && lower != checkpoint.EvictCompress {
+			return fmt.Errorf("unsupported max_checkpoints_action: %s (use delete or compress)", value)
+		}
+		parsedValue = lower
+
+	case "max_storage_action":
+		lower := strings.ToLower(value)
+		if lower != checkpoint.EvictCompress && lower != checkpoint.EvictDelete && lower != checkpoint.StorageRefuse && lower != checkpoint.StorageWarn {
+			return fmt.Errorf("unsupported max_storage_action: %s (use compress, delete, refuse or warn)", value)
+		}
+		parsedValue = lower
+
+	case "cloud_placeholders":
+		lower := strings.ToLower(value)
+		if lower != checkpoint.PlaceholdersSkip && lower != checkpoint.PlaceholdersHydrate {
+			return fmt.Errorf("unsupported cloud_placeholders: %s (use skip or hydrate)", value)
+		}
+		parsedValue = lower
+
+	case "fs_snapshots":
+		lower := strings.ToLower(value)
+		if lower != checkpoint.FSSnapshotsOff && lower != checkpoint.FSSnapshotsAuto && !slices.Contains(checkpoint.FSSnapshotBackends, lower) {
+			return fmt.Errorf("unsupported fs_snapshots: %s (use off, auto, %s)", value, strings.Join(checkpoint.FSSnapshotBackends, ", "))
+		}
+		parsedValue = lower
+
+	case "rm_strategy":
+		lower := strings.ToLower(value)
+		if lower != wrapper.RmCopy && lower != wrapper.RmTrash {
+			return fmt.Errorf("unsupported rm_strategy: %s (use copy or trash)", value)
+		}
+		parsedValue = lower
+
+	case "wrapper_messages":
+		lower := strings.ToLower(value)
+		if lower != wrapper.MessagesStderr && lower != wrapper.MessagesLog && lower != wrapper.MessagesOff {
+			return fmt.Errorf("unsupported wrapper_messages: %s (use stderr, log or off)", value)
+		}
+		parsedValue = lower
+
+	case "log_level":
+		lower := strings.ToLower(value)
+		if _, err := logging.ParseLevel(lower); err != nil {
+			return err
+		}
+		parsedValue = lower
+
+	case "language":
+		lower := strings.ToLower(value)
+		if lower != "auto" && !i18n.IsSupported(lower) {
+			return fmt.Errorf("unsupported language: %s (use auto, %s)", value, strings.Join(i18n.Locales(), ", "))
+		}
+		parsedValue = lower
+
+	case "diff_tool":
+		lower := strings.ToLower(value)
+		valid := false
+		for _, t := range diffTools {
+			if t == lower {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unsupported diff_tool: %s (use %s)", value, strings.Join(diffTools, ", "))
+		}
+		parsedValue = lower
+
+	case "snapshot_dir":
+		if value != "" && !filepath.IsAbs(value) {
+			return fmt.Errorf("%s must be an absolute path", key)
+		}
+		parsedValue = value
+
+	case "warn_sensitive_files", "preserve_macos_metadata", "preserve_times", "scope_to_project", "respect_gitignore", "git_aware", "log_file", "snapshots":
 		lower := strings.ToLower(value)
 		if lower == "true" || lower == "1" || lower == "yes" {
 			parsedValue = true
@@ -177,6 +506,11 @@ func setConfig(key, value string) error {
 		}
 
 	default:
+		if strings.HasPrefix(key, realCommandsPrefix) {
+			if !filepath.IsAbs(value) {
+				return fmt.Errorf("%s must be an absolute path", key)
+			}
+		}
 		parsedValue = value
 	}
 
@@ -192,10 +526,24 @@ func setConfig(key, value string) error {
 	return nil
 }
 
+// realCommandsPrefix starts the keys of the real_commands map, which are
+// named after the commands they override
+const realCommandsPrefix = "real_commands."
+
+// lookupConfigKey returns the description of a valid config key
+func lookupConfigKey(key string) (string, bool) {
+	if name, ok := strings.CutPrefix(key, realCommandsPrefix); ok && name != "" && !strings.Contains(name, ".") {
+		return "Binary run for " + name, true
+	}
+	desc, ok := configKeys[key]
+	return desc, ok
+}
+
 func getValidKeys() []string {
 	keys := make([]string, 0, len(configKeys))
 	for k := range configKeys {
 		keys = append(keys, k)
 	}
+	keys = append(keys, realCommandsPrefix+"<cmd>")
 	return keys
 }
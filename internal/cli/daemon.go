@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/daemon"
+	"github.com/qhkm/safeshell/internal/debugserver"
+	"github.com/spf13/cobra"
+)
+
+var daemonDebugAddr string
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a background daemon that makes wrapped commands faster",
+	Long: `Runs a daemon that keeps config and the checkpoint index in memory.
+Wrapped commands (rm, mv, ...) hand their checkpoint to it over a unix
+socket instead of loading everything themselves, so they start almost
+instantly. When the daemon is not running, they work as before.
+
+Config edits and checkpoints created by other safeshell commands are picked
+up automatically. Hooks run inside the daemon, and warnings about sensitive
+or oversized files go to its output rather than your terminal.
+
+The daemon runs in the foreground; start it in the background with your
+service manager or with 'safeshell daemon &'.
+
+Options:
+  --debug-addr    Serve pprof profiles (/debug/pprof/) and expvar counters
+                  (/debug/vars) on this loopback address, and start a gops
+                  agent, to diagnose slow checkpoints or memory use
+
+Examples:
+  safeshell daemon &          # Start the daemon
+  safeshell daemon --debug-addr 127.0.0.1:6060 &
+  safeshell daemon status     # Check whether it is running
+  safeshell daemon stop       # Stop it`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	RunE:  runDaemonStatus,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	RunE:  runDaemonStop,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	daemonCmd.Flags().StringVar(&daemonDebugAddr, "debug-addr", "", "Serve pprof/expvar debug endpoints on this loopback address")
+}
+
+// startDebugServer starts the debug endpoints if addr is set. Messages go
+// to stderr, which is safe for servers speaking on stdout.
+func startDebugServer(addr string) (*debugserver.Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	server, err := debugserver.Start(addr)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Debug endpoints on http://%s/debug/pprof/ and /debug/vars\n", server.Addr)
+	return server, nil
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	// Load the index up front so the first wrapped command is fast too
+	checkpoint.GetIndex()
+
+	debug, err := startDebugServer(daemonDebugAddr)
+	if err != nil {
+		return err
+	}
+	if debug != nil {
+		defer debug.Close()
+	}
+
+	socket := daemon.SocketPath()
+	server, err := daemon.Listen(socket)
+	if err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		server.Close()
+	}()
+
+	color.Green("✓ Daemon listening on %s\n", socket)
+	fmt.Println("Press Ctrl+C to stop.")
+
+	if err := server.Serve(); err != nil {
+		return err
+	}
+	fmt.Println("Stopped.")
+	return nil
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	resp, err := daemon.Ping(daemon.SocketPath())
+	if err == daemon.ErrNotRunning {
+		printInfo("Daemon is not running (start it with 'safeshell daemon &')")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Daemon is running (pid %d, started %s)", resp.PID, resp.StartedAt))
+	return nil
+}
+
+func runDaemonStop(cmd *cobra.Command, args []string) error {
+	err := daemon.Stop(daemon.SocketPath())
+	if err == daemon.ErrNotRunning {
+		printInfo("Daemon is not running")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	printSuccess("Daemon stopped")
+	return nil
+}
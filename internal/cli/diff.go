@@ -2,23 +2,30 @@ package cli
 
 import (
 	"bufio"
-	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/qhkm/safeshell/internal/util"
 	"github.com/spf13/cobra"
 )
 
 var (
-	diffLast    bool
-	diffContent bool
-	diffFile    string
+	diffLast     bool
+	diffContent  bool
+	diffFile     string
+	diffSummary  bool
+	diffMaxBytes int
+	diffPatch    bool
+	diffSince    string
 )
 
 var diffCmd = &cobra.Command{
@@ -30,66 +37,141 @@ This helps you understand what a rollback would do before executing it.
 
 Options:
   --content    Show actual content differences for modified text files
+               (uses diff_tool from config: delta, difft or git, if installed)
   --file       Show diff for a specific file only
+  --summary    Print a compact plain-text summary, clustering changes by
+               directory and file type (for pasting into LLM prompts)
+  --max-bytes  Size cap for --summary output (default 2000, ~500 tokens)
+  --patch      Print the changes made since the checkpoint as a unified
+               diff that 'git apply' accepts, with paths relative to the
+               directory the checkpoint was created in. 'git apply -R'
+               undoes the changes.
+  --since      Report on every file backed up by the checkpoints of the
+               last duration (e.g. 2h, 1d), against how it was before
+               them, with the checkpoints taken before each change.
+               Works with --file, --summary and --content.
 
 Examples:
   safeshell diff --last                        # Compare with most recent checkpoint
   safeshell diff --last --content              # Show content changes
   safeshell diff --last --file src/main.go     # Diff specific file
-  safeshell diff 2024-12-12T143022             # Compare with specific checkpoint`,
-	RunE: runDiff,
+  safeshell diff 2024-12-12T143022             # Compare with specific checkpoint
+  safeshell diff --last --summary --max-bytes 800
+  safeshell diff --last --patch > changes.patch
+  safeshell diff --since 2h                    # What wrapped commands touched in the last 2 hours
+  safeshell diff --last --json                 # Every file and its status, for scripts`,
+	Annotations:       map[string]string{resultAnnotation: ""},
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runDiff,
+}
+
+// diffJSON is what diff prints in JSON
+type diffJSON struct {
+	Checkpoint  string         `json:"checkpoint"`
+	Command     string         `json:"command"`
+	CreatedAt   time.Time      `json:"created_at"`
+	RolledBack  bool           `json:"rolled_back,omitempty"`
+	Deleted     int            `json:"deleted"`
+	Modified    int            `json:"modified"`
+	Renamed     int            `json:"renamed"`
+	Unchanged   int            `json:"unchanged"`
+	RestoreSize int64          `json:"restore_size"`
+	Files       []diffFileJSON `json:"files"`
+}
+
+type diffFileJSON struct {
+	Path        string `json:"path"`
+	Status      string `json:"status"` // deleted, modified, renamed or unchanged
+	BackupSize  int64  `json:"backup_size"`
+	CurrentSize int64  `json:"current_size"`
+	RenamedTo   string `json:"renamed_to,omitempty"`
 }
 
 func init() {
 	rootCmd.AddCommand(diffCmd)
 	diffCmd.Flags().BoolVarP(&diffLast, "last", "l", false, "Compare with most recent checkpoint")
+	addAtFlag(diffCmd)
 	diffCmd.Flags().BoolVarP(&diffContent, "content", "c", false, "Show actual content differences")
 	diffCmd.Flags().StringVarP(&diffFile, "file", "f", "", "Show diff for specific file only")
-}
-
-type FileDiff struct {
-	Path         string
-	Status       string // "deleted", "modified", "unchanged"
-	BackupSize   int64
-	CurrentSize  int64
-	BackupPath   string
+	diffCmd.Flags().BoolVarP(&diffSummary, "summary", "s", false, "Print a compact summary grouped by directory and file type")
+	diffCmd.Flags().IntVar(&diffMaxBytes, "max-bytes", 2000, "Maximum size of --summary output in bytes")
+	diffCmd.Flags().BoolVarP(&diffPatch, "patch", "p", false, "Print changes since the checkpoint as a unified diff")
+	diffCmd.Flags().StringVar(&diffSince, "since", "", "Report on all checkpoints of the last duration (e.g. 2h, 1d)")
+	diffCmd.MarkFlagsMutuallyExclusive("since", "last")
+	diffCmd.MarkFlagsMutuallyExclusive("since", "at")
+	diffCmd.RegisterFlagCompletionFunc("file", completeCheckpointPath)
 }
 
 func runDiff(cmd *cobra.Command, args []string) error {
 	var cp *checkpoint.Checkpoint
 	var err error
 
-	if diffLast {
-		cp, err = checkpoint.GetLatest()
-		if err != nil {
-			return fmt.Errorf("no checkpoints found")
+	if diffSince != "" {
+		return runDiffSince(args)
+	}
+	if diffLast || checkpointAt != "" {
+		if cp, err = latestCheckpoint(); err != nil {
+			return err
 		}
 	} else if len(args) > 0 {
-		cp, err = checkpoint.Get(args[0])
-		if err != nil {
-			return fmt.Errorf("checkpoint not found: %s", args[0])
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
 		}
 	} else {
-		return fmt.Errorf("please specify a checkpoint ID or use --last")
+		return errors.New(i18n.T("rollback.specify"))
+	}
+
+	if !humanOutput() && (diffSummary || diffPatch || diffContent) {
+		return errors.New("--json can't be combined with --summary, --patch or --content")
 	}
 
 	// Analyze differences
-	diffs := analyzeDiffs(cp)
+	diffs := checkpoint.Compare(cp)
+
+	// Plain, size-capped output meant to be embedded in prompts
+	if diffSummary {
+		header := fmt.Sprintf("Checkpoint %s (%s)\n", cp.ID, cp.Manifest.Command)
+		budget := diffMaxBytes
+		if budget > 0 {
+			budget = max(budget-len(header), 1)
+		}
+		fmt.Print(header + checkpoint.SummarizeDiffs(diffs).Render(budget))
+		return nil
+	}
+
+	if diffPatch {
+		if diffFile != "" {
+			if diffs, err = filterDiffs(diffs, diffFile); err != nil {
+				return err
+			}
+		}
+		skipped, err := checkpoint.WritePatch(os.Stdout, cp, diffs, cp.Manifest.WorkingDir)
+		for _, path := range skipped {
+			fmt.Fprintf(os.Stderr, "Warning: %s is outside %s, left out of the patch\n", path, cp.Manifest.WorkingDir)
+		}
+		return err
+	}
+
+	diffs = checkpoint.DetectRenames(cp, diffs)
+	if !humanOutput() {
+		return printDiffJSON(cp, diffs)
+	}
 
 	// Print header
 	fmt.Println()
-	color.New(color.FgCyan, color.Bold).Printf("Checkpoint: %s\n", cp.ID)
-	fmt.Printf("Command:    %s\n", cp.Manifest.Command)
-	fmt.Printf("Time:       %s\n", cp.Manifest.Timestamp.Format("2006-01-02 15:04:05"))
+	color.New(color.FgCyan, color.Bold).Println(i18n.T("checkpoint.header", cp.ID))
+	fmt.Println(i18n.T("checkpoint.command", cp.Manifest.Command))
+	fmt.Println(i18n.T("checkpoint.time", cp.Manifest.Timestamp.Format("2006-01-02 15:04:05")))
 	fmt.Println()
 
 	if cp.Manifest.RolledBack {
-		color.Yellow("⚠ This checkpoint has already been rolled back\n\n")
+		color.Yellow("%s\n\n", i18n.T("diff.already_rolled_back"))
 	}
 
 	// Count by status
 	deleted := 0
 	modified := 0
+	renamed := 0
 	unchanged := 0
 	var totalRestoreSize int64
 
@@ -101,43 +183,41 @@ func runDiff(cmd *cobra.Command, args []string) error {
 		case "modified":
 			modified++
 			totalRestoreSize += d.BackupSize
+		case "renamed":
+			renamed++
+			totalRestoreSize += d.BackupSize
 		case "unchanged":
 			unchanged++
 		}
 	}
 
 	// Summary
-	color.New(color.FgWhite, color.Bold).Println("Summary:")
+	color.New(color.FgWhite, color.Bold).Println(i18n.T("diff.summary"))
 	if deleted > 0 {
-		color.Red("  • %d file(s) deleted - will be restored\n", deleted)
+		color.Red("%s", i18n.T("diff.deleted", deleted))
 	}
 	if modified > 0 {
-		color.Yellow("  • %d file(s) modified - will be reverted\n", modified)
+		color.Yellow("%s", i18n.T("diff.modified", modified))
+	}
+	if renamed > 0 {
+		color.Cyan("%s", i18n.T("diff.renamed", renamed))
 	}
 	if unchanged > 0 {
-		color.Green("  • %d file(s) unchanged - no action needed\n", unchanged)
+		color.Green("%s", i18n.T("diff.unchanged", unchanged))
 	}
-	fmt.Printf("  • Total restore size: %s\n", util.FormatBytes(totalRestoreSize))
+	fmt.Println(i18n.T("diff.total_size", output.FormatBytes(totalRestoreSize)))
 	fmt.Println()
 
 	// Filter by specific file if requested
 	if diffFile != "" {
-		var filteredDiffs []FileDiff
-		absFile, _ := filepath.Abs(diffFile)
-		for _, d := range diffs {
-			if d.Path == diffFile || d.Path == absFile || strings.HasSuffix(d.Path, "/"+diffFile) {
-				filteredDiffs = append(filteredDiffs, d)
-			}
+		if diffs, err = filterDiffs(diffs, diffFile); err != nil {
+			return err
 		}
-		if len(filteredDiffs) == 0 {
-			return fmt.Errorf("file '%s' not found in checkpoint", diffFile)
-		}
-		diffs = filteredDiffs
 	}
 
 	// Detailed file list
-	if deleted+modified > 0 {
-		color.New(color.FgWhite, color.Bold).Println("Files to restore:")
+	if deleted+modified+renamed > 0 {
+		color.New(color.FgWhite, color.Bold).Println(i18n.T("diff.files_to_restore"))
 		fmt.Println()
 
 		for _, d := range diffs {
@@ -145,123 +225,110 @@ func runDiff(cmd *cobra.Command, args []string) error {
 				continue
 			}
 
-			// Shorten path for display
-			displayPath := d.Path
-			if cwd, err := os.Getwd(); err == nil {
-				if rel, err := filepath.Rel(cwd, d.Path); err == nil && !strings.HasPrefix(rel, "..") {
-					displayPath = rel
-				}
-			}
+			displayPath := relToCwd(d.Path)
 
 			switch d.Status {
 			case "deleted":
-				color.Red("  + %s", displayPath)
-				color.New(color.FgHiBlack).Printf(" (%s)\n", util.FormatBytes(d.BackupSize))
+				color.New(color.FgRed).Printf("  + %s", displayPath)
+				color.New(color.FgHiBlack).Printf(" (%s)\n", output.FormatBytes(d.BackupSize))
 				if diffContent {
 					showFileContent(d.BackupPath, "backup")
 				}
 			case "modified":
-				color.Yellow("  ~ %s", displayPath)
-				color.New(color.FgHiBlack).Printf(" (%s → %s)\n", util.FormatBytes(d.CurrentSize), util.FormatBytes(d.BackupSize))
+				color.New(color.FgYellow).Printf("  ~ %s", displayPath)
+				color.New(color.FgHiBlack).Printf(" (%s → %s)\n", output.FormatBytes(d.CurrentSize), output.FormatBytes(d.BackupSize))
 				if diffContent {
 					showContentDiff(d.BackupPath, d.Path)
 				}
+			case "renamed":
+				color.New(color.FgCyan).Printf("  → %s", displayPath)
+				color.New(color.FgHiBlack).Printf(" (%s)\n", i18n.T("diff.renamed_to", relToCwd(d.RenamedTo)))
 			}
 		}
 		fmt.Println()
 	}
 
 	// Instructions
-	if deleted+modified > 0 {
-		fmt.Println("To restore these files, run:")
+	if deleted+modified+renamed > 0 {
+		fmt.Println(i18n.T("diff.restore_hint"))
 		color.Cyan("  safeshell rollback %s\n", cp.ID)
 		fmt.Println()
-		fmt.Println("To restore specific files only:")
+		fmt.Println(i18n.T("diff.restore_some_hint"))
 		color.Cyan("  safeshell rollback %s --files \"path/to/file\"\n", cp.ID)
 	} else {
-		color.Green("✓ All files are already in sync with checkpoint\n")
+		color.Green("%s", i18n.T("diff.in_sync"))
 	}
 
 	return nil
 }
 
-func analyzeDiffs(cp *checkpoint.Checkpoint) []FileDiff {
-	var diffs []FileDiff
-
-	for _, f := range cp.Manifest.Files {
-		if f.IsDir {
-			continue
-		}
-
-		diff := FileDiff{
-			Path:       f.OriginalPath,
-			BackupSize: f.Size,
-			BackupPath: f.BackupPath,
+// relToCwd shortens path for display when it is under the working directory
+func relToCwd(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
 		}
-
-		// Check if file exists
-		info, err := os.Stat(f.OriginalPath)
-		if os.IsNotExist(err) {
-			diff.Status = "deleted"
-			diff.CurrentSize = 0
-		} else if err != nil {
-			diff.Status = "deleted" // Treat errors as deleted
-			diff.CurrentSize = 0
-		} else {
-			diff.CurrentSize = info.Size()
-
-			// Compare content (using hash for efficiency)
-			if filesMatch(f.BackupPath, f.OriginalPath) {
-				diff.Status = "unchanged"
-			} else {
-				diff.Status = "modified"
-			}
-		}
-
-		diffs = append(diffs, diff)
 	}
-
-	return diffs
+	return path
 }
 
-func filesMatch(path1, path2 string) bool {
-	// Quick check: compare file sizes first (much faster than hashing)
-	info1, err1 := os.Stat(path1)
-	info2, err2 := os.Stat(path2)
-
-	if err1 != nil || err2 != nil {
-		return false
+func printDiffJSON(cp *checkpoint.Checkpoint, diffs []checkpoint.FileDiff) error {
+	result := diffJSON{
+		Checkpoint: cp.ID,
+		Command:    cp.Manifest.Command,
+		CreatedAt:  cp.Manifest.Timestamp,
+		RolledBack: cp.Manifest.RolledBack,
+		Files:      []diffFileJSON{},
 	}
-
-	// Different sizes = definitely different files
-	if info1.Size() != info2.Size() {
-		return false
+	for _, d := range diffs {
+		switch d.Status {
+		case checkpoint.DiffDeleted:
+			result.Deleted++
+			result.RestoreSize += d.BackupSize
+		case checkpoint.DiffModified:
+			result.Modified++
+			result.RestoreSize += d.BackupSize
+		case checkpoint.DiffRenamed:
+			result.Renamed++
+			result.RestoreSize += d.BackupSize
+		case checkpoint.DiffUnchanged:
+			result.Unchanged++
+		}
 	}
 
-	// Same size = need to compare content via hash
-	hash1, err1 := fileHash(path1)
-	hash2, err2 := fileHash(path2)
-
-	if err1 != nil || err2 != nil {
-		return false
+	// The counts are for the whole checkpoint, like in the summary above
+	// the file list
+	if diffFile != "" {
+		var err error
+		if diffs, err = filterDiffs(diffs, diffFile); err != nil {
+			return err
+		}
 	}
-
-	return hash1 == hash2
+	for _, d := range diffs {
+		result.Files = append(result.Files, diffFileJSON{
+			Path:        d.Path,
+			Status:      d.Status,
+			BackupSize:  d.BackupSize,
+			CurrentSize: d.CurrentSize,
+			RenamedTo:   d.RenamedTo,
+		})
+	}
+	return printResult(result)
 }
 
-func fileHash(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
+// filterDiffs keeps the diffs for file, given as a path or a path suffix
+func filterDiffs(diffs []checkpoint.FileDiff, file string) ([]checkpoint.FileDiff, error) {
+	var filtered []checkpoint.FileDiff
+	absFile, _ := filepath.Abs(file)
+	for _, d := range diffs {
+		if d.Path == file || d.Path == absFile || strings.HasSuffix(d.Path, "/"+file) {
+			filtered = append(filtered, d)
+		}
 	}
-	defer f.Close()
-
-	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+	if len(filtered) == 0 {
+		return nil, errors.New(i18n.T("diff.file_not_found", file))
 	}
-
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	return filtered, nil
 }
 
 // showFileContent displays the content of a file (for deleted files)
@@ -279,8 +346,13 @@ func showFileContent(path string, label string) {
 
 	fmt.Println()
 	color.New(color.FgHiBlack).Printf("    --- %s content ---\n", label)
-	for i, line := range lines {
-		color.Green("    %3d: %s\n", i+1, truncateLine(line, 80))
+	display, highlighted := highlightLines(path, lines, 80)
+	for i, line := range display {
+		if highlighted {
+			fmt.Printf("    %3d: %s\n", i+1, line)
+		} else {
+			color.Green("    %3d: %s\n", i+1, line)
+		}
 	}
 	if len(lines) == 20 {
 		color.New(color.FgHiBlack).Println("    ... (truncated)")
@@ -295,6 +367,10 @@ func showContentDiff(backupPath, currentPath string) {
 		return
 	}
 
+	if runExternalDiff(currentPath, backupPath) {
+		return
+	}
+
 	backupLines, err1 := readFileLines(backupPath, 500)
 	currentLines, err2 := readFileLines(currentPath, 500)
 
@@ -309,6 +385,10 @@ func showContentDiff(backupPath, currentPath string) {
 	// Compute diff using LCS-based algorithm
 	diff := computeDiff(currentLines, backupLines)
 
+	// Highlight whole files so multi-line constructs are colored correctly
+	currentDisplay, highlighted := highlightLines(backupPath, currentLines, 70)
+	backupDisplay, _ := highlightLines(backupPath, backupLines, 70)
+
 	changesShown := 0
 	maxChanges := 30
 
@@ -320,10 +400,18 @@ func showContentDiff(backupPath, currentPath string) {
 
 		switch d.Op {
 		case diffDelete: // Line removed (was in current, not in backup)
-			color.Red("    -%3d: %s\n", d.LineNum, truncateLine(d.Text, 70))
+			if highlighted {
+				fmt.Printf("    %s %s\n", color.RedString("-%3d:", d.LineNum), currentDisplay[d.LineNum-1])
+			} else {
+				color.Red("    -%3d: %s\n", d.LineNum, truncateLine(d.Text, 70))
+			}
 			changesShown++
 		case diffInsert: // Line added (in backup, not in current)
-			color.Green("    +%3d: %s\n", d.LineNum, truncateLine(d.Text, 70))
+			if highlighted {
+				fmt.Printf("    %s %s\n", color.GreenString("+%3d:", d.LineNum), backupDisplay[d.LineNum-1])
+			} else {
+				color.Green("    +%3d: %s\n", d.LineNum, truncateLine(d.Text, 70))
+			}
 			changesShown++
 		}
 	}
@@ -479,6 +567,20 @@ func readFileLines(path string, maxLines int) ([]string, error) {
 	return lines, scanner.Err()
 }
 
+// highlightLines truncates lines to maxLen and syntax-highlights them using
+// the language of path. Highlighting is skipped when color output is off
+// (not a terminal, or NO_COLOR is set); highlighted is false in that case.
+func highlightLines(path string, lines []string, maxLen int) (display []string, highlighted bool) {
+	display = make([]string, len(lines))
+	for i, line := range lines {
+		display[i] = truncateLine(line, maxLen)
+	}
+	if color.NoColor {
+		return display, false
+	}
+	return util.HighlightLines(path, display)
+}
+
 // truncateLine truncates a line to maxLen characters
 func truncateLine(line string, maxLen int) string {
 	if len(line) <= maxLen {
@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// diffSinceJSON is what diff --since prints in JSON
+type diffSinceJSON struct {
+	Since       time.Time           `json:"since"`
+	Checkpoints []string            `json:"checkpoints"`
+	Deleted     int                 `json:"deleted"`
+	Modified    int                 `json:"modified"`
+	Unchanged   int                 `json:"unchanged"`
+	Files       []diffSinceFileJSON `json:"files"`
+}
+
+type diffSinceFileJSON struct {
+	Path        string   `json:"path"`
+	Status      string   `json:"status"` // deleted, modified or unchanged
+	BackupSize  int64    `json:"backup_size"`
+	CurrentSize int64    `json:"current_size"`
+	Checkpoints []string `json:"checkpoints"` // oldest first
+}
+
+// runDiffSince shows what happened to every file backed up by the
+// checkpoints of the last --since, compared to how it was before them
+func runDiffSince(args []string) error {
+	if len(args) > 0 {
+		return errors.New("--since can't be combined with a checkpoint ID")
+	}
+	if diffPatch {
+		return errors.New("--since can't be combined with --patch, which needs a single checkpoint")
+	}
+	if !humanOutput() && (diffSummary || diffContent) {
+		return errors.New("--json can't be combined with --summary or --content")
+	}
+	window, err := parseDuration(diffSince)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", diffSince)
+	}
+	since := time.Now().Add(-window)
+
+	diffs, err := checkpoint.CompareSince(since)
+	if err != nil {
+		return err
+	}
+	if diffFile != "" {
+		if diffs, err = filterWindowDiffs(diffs, diffFile); err != nil {
+			return err
+		}
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	deleted, modified, unchanged := 0, 0, 0
+	for _, d := range diffs {
+		for _, cp := range d.Checkpoints {
+			if !seen[cp.ID] {
+				seen[cp.ID] = true
+				ids = append(ids, cp.ID)
+			}
+		}
+		switch d.Status {
+		case checkpoint.DiffDeleted:
+			deleted++
+		case checkpoint.DiffModified:
+			modified++
+		case checkpoint.DiffUnchanged:
+			unchanged++
+		}
+	}
+
+	if !humanOutput() {
+		result := diffSinceJSON{
+			Since:       since,
+			Checkpoints: append([]string{}, ids...),
+			Deleted:     deleted,
+			Modified:    modified,
+			Unchanged:   unchanged,
+			Files:       []diffSinceFileJSON{},
+		}
+		for _, d := range diffs {
+			f := diffSinceFileJSON{Path: d.Path, Status: d.Status, BackupSize: d.BackupSize, CurrentSize: d.CurrentSize}
+			for _, cp := range d.Checkpoints {
+				f.Checkpoints = append(f.Checkpoints, cp.ID)
+			}
+			result.Files = append(result.Files, f)
+		}
+		return printResult(result)
+	}
+
+	stamp := since.Format("2006-01-02 15:04:05")
+	if len(ids) == 0 {
+		fmt.Println(i18n.T("diff.since_none", stamp))
+		return nil
+	}
+
+	if diffSummary {
+		header := i18n.T("diff.since_header", stamp, len(ids)) + "\n"
+		budget := diffMaxBytes
+		if budget > 0 {
+			budget = max(budget-len(header), 1)
+		}
+		fileDiffs := make([]checkpoint.FileDiff, len(diffs))
+		for i, d := range diffs {
+			fileDiffs[i] = d.FileDiff
+		}
+		fmt.Print(header + checkpoint.SummarizeDiffs(fileDiffs).Render(budget))
+		return nil
+	}
+
+	fmt.Println()
+	color.New(color.FgCyan, color.Bold).Println(i18n.T("diff.since_header", stamp, len(ids)))
+	fmt.Println()
+
+	color.New(color.FgWhite, color.Bold).Println(i18n.T("diff.summary"))
+	if deleted > 0 {
+		color.Red("%s", i18n.T("diff.deleted", deleted))
+	}
+	if modified > 0 {
+		color.Yellow("%s", i18n.T("diff.modified", modified))
+	}
+	if unchanged > 0 {
+		color.Green("%s", i18n.T("diff.unchanged", unchanged))
+	}
+	fmt.Println()
+
+	if deleted+modified == 0 {
+		color.Green("%s", i18n.T("diff.since_in_sync"))
+		return nil
+	}
+
+	color.New(color.FgWhite, color.Bold).Println(i18n.T("diff.since_changed"))
+	fmt.Println()
+	var example checkpoint.WindowDiff
+	for _, d := range diffs {
+		if d.Status == checkpoint.DiffUnchanged {
+			continue
+		}
+		if example.Path == "" {
+			example = d
+		}
+
+		displayPath := relToCwd(d.Path)
+		switch d.Status {
+		case checkpoint.DiffDeleted:
+			color.New(color.FgRed).Printf("  + %s", displayPath)
+			color.New(color.FgHiBlack).Printf(" (%s)\n", output.FormatBytes(d.BackupSize))
+		case checkpoint.DiffModified:
+			color.New(color.FgYellow).Printf("  ~ %s", displayPath)
+			color.New(color.FgHiBlack).Printf(" (%s → %s)\n", output.FormatBytes(d.CurrentSize), output.FormatBytes(d.BackupSize))
+		}
+		for _, cp := range d.Checkpoints {
+			color.New(color.FgHiBlack).Printf("      %s  %s\n", cp.ID, cp.Manifest.Command)
+		}
+		if diffContent {
+			if d.Status == checkpoint.DiffDeleted {
+				showFileContent(d.BackupPath, "backup")
+			} else {
+				showContentDiff(d.BackupPath, d.Path)
+			}
+		}
+	}
+	fmt.Println()
+
+	fmt.Println(i18n.T("diff.since_restore_hint"))
+	color.Cyan("  safeshell rollback %s --files %q\n", example.Checkpoints[0].ID, relToCwd(example.Path))
+	return nil
+}
+
+// filterWindowDiffs keeps the diffs for file, like filterDiffs
+func filterWindowDiffs(diffs []checkpoint.WindowDiff, file string) ([]checkpoint.WindowDiff, error) {
+	fileDiffs := make([]checkpoint.FileDiff, len(diffs))
+	for i, d := range diffs {
+		fileDiffs[i] = d.FileDiff
+	}
+	kept, err := filterDiffs(fileDiffs, file)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	for _, d := range kept {
+		keep[d.Path] = true
+	}
+	var filtered []checkpoint.WindowDiff
+	for _, d := range diffs {
+		if keep[d.Path] {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// diffTools are the accepted values of the diff_tool setting
+var diffTools = []string{"builtin", "delta", "difft", "git"}
+
+// diffToolWarned avoids repeating the missing-tool warning for every file
+var diffToolWarned bool
+
+// diffToolCommand builds the command comparing oldPath to newPath with the
+// named external tool
+func diffToolCommand(tool, oldPath, newPath string) *exec.Cmd {
+	switch tool {
+	case "delta":
+		return exec.Command("delta", "--paging=never", oldPath, newPath)
+	case "difft":
+		return exec.Command("difft", oldPath, newPath)
+	case "git":
+		colorFlag := "--color=always"
+		if color.NoColor {
+			colorFlag = "--color=never"
+		}
+		return exec.Command("git", "--no-pager", "diff", "--no-index", colorFlag, "--", oldPath, newPath)
+	}
+	return nil
+}
+
+// runExternalDiff shows the difference between the current file and its
+// backup using the configured diff_tool. It returns false when the built-in
+// diff should be used instead: no tool is configured, the tool is not
+// installed, or it failed.
+func runExternalDiff(currentPath, backupPath string) bool {
+	tool := config.Get().DiffTool
+	if tool == "" || tool == "builtin" {
+		return false
+	}
+
+	cmd := diffToolCommand(tool, currentPath, backupPath)
+	if cmd == nil {
+		warnDiffTool(fmt.Sprintf("unknown diff_tool %q, using built-in diff", tool))
+		return false
+	}
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		warnDiffTool(fmt.Sprintf("diff_tool %s not found in PATH, using built-in diff", tool))
+		return false
+	}
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Println()
+	err := cmd.Run()
+
+	// delta and git diff exit 1 when the files differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		warnDiffTool(fmt.Sprintf("diff_tool %s failed (%v), using built-in diff", tool, err))
+		return false
+	}
+	fmt.Println()
+	return true
+}
+
+func warnDiffTool(msg string) {
+	if diffToolWarned {
+		return
+	}
+	diffToolWarned = true
+	fmt.Fprintln(os.Stderr, color.YellowString("! %s", msg))
+}
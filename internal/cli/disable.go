@@ -3,9 +3,9 @@ package cli
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/qhkm/safeshell/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -20,14 +20,21 @@ original system binaries without SafeShell protection.
 Your checkpoints and SafeShell installation remain intact.
 Run 'safeshell enable' or 'safeshell init' to re-enable protection.
 
+Options:
+  --shell   bash, fish, nu, powershell, xonsh or zsh (default: the shell
+            you run it from)
+
 Examples:
   safeshell disable     # Remove aliases from shell config
+  safeshell disable --shell powershell  # Remove them from the PowerShell profile
   safeshell enable      # Re-enable protection later`,
-	RunE: runDisable,
+	RunE:        runDisable,
+	Annotations: map[string]string{featureAnnotation: config.FeatureDisable},
 }
 
 func init() {
 	rootCmd.AddCommand(disableCmd)
+	disableCmd.Flags().StringVar(&shellName, "shell", "", "Shell to remove aliases from: "+shellNames())
 }
 
 func runDisable(cmd *cobra.Command, args []string) error {
@@ -36,27 +43,11 @@ func runDisable(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	// Detect shell (same logic as init.go)
-	shell := os.Getenv("SHELL")
-	var rcFile string
-	var shellName string
-
-	switch {
-	case strings.Contains(shell, "zsh"):
-		rcFile = filepath.Join(homeDir, ".zshrc")
-		shellName = "zsh"
-	case strings.Contains(shell, "bash"):
-		bashProfile := filepath.Join(homeDir, ".bash_profile")
-		if _, err := os.Stat(bashProfile); err == nil {
-			rcFile = bashProfile
-		} else {
-			rcFile = filepath.Join(homeDir, ".bashrc")
-		}
-		shellName = "bash"
-	default:
-		rcFile = filepath.Join(homeDir, ".bashrc")
-		shellName = "bash"
+	shell, err := detectShell()
+	if err != nil {
+		return err
 	}
+	rcFile := shell.RCFile(homeDir)
 
 	// Check if SafeShell is installed
 	if !containsSafeShell(rcFile) {
@@ -73,11 +64,11 @@ func runDisable(cmd *cobra.Command, args []string) error {
 	printSuccess(fmt.Sprintf("SafeShell aliases removed from %s", rcFile))
 	fmt.Println()
 	fmt.Println("To apply changes, run:")
-	fmt.Printf("  source %s\n", rcFile)
+	fmt.Printf("  %s\n", shell.reload(rcFile))
 	fmt.Println()
 	fmt.Println("Or restart your terminal.")
 	fmt.Println()
-	fmt.Printf("Your %s shell will now use the original system binaries.\n", shellName)
+	fmt.Printf("Your %s shell will now use the original system binaries.\n", shell.Name)
 	fmt.Println("Your checkpoints are still available via 'safeshell list'.")
 	fmt.Println()
 	fmt.Println("To re-enable protection:")
@@ -0,0 +1,283 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/qhkm/safeshell/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var excludeSuggestDays int
+
+var excludeCmd = &cobra.Command{
+	Use:   "exclude",
+	Short: "Manage paths that are never backed up",
+	Long: `Lists and edits exclude_paths, the patterns for files and directories
+that checkpoints skip, on top of the built-in exclusions (node_modules,
+.git, build output, caches...).
+
+A pattern ending in /* excludes matching directories and everything in
+them, e.g. coverage/* or .git/objects/*. Other patterns are matched against
+file names, e.g. *.log. Patterns with ** or starting with / or ~/ are
+matched against the whole path, ** spanning any number of directories,
+e.g. 'src/**/*.pb.go' or '~/work/**/fixtures'.
+
+Removing a built-in exclusion, e.g. 'exclude remove vendor', backs it up
+after all; adding it again excludes it again.
+
+When a checkpoint is unusually large, safeshell suggests directories to
+exclude. 'exclude suggest' walks through them: answer y to exclude, n to
+skip for now, or never to stop suggesting the pattern.
+
+Examples:
+  safeshell exclude                            # List exclusions
+  safeshell exclude add 'coverage/*' '*.log'
+  safeshell exclude remove '*.log'
+  safeshell exclude add '**/generated/**'      # Anywhere, at any depth
+  safeshell exclude remove vendor              # Back up vendor/ after all
+  safeshell exclude suggest                    # Review suggestions from the last 7 days
+  safeshell exclude suggest 2024-12-12T143022-a1b2c3
+  safeshell exclude suggest --yes              # Apply all suggestions`,
+	Args: cobra.NoArgs,
+	RunE: runExcludeList,
+}
+
+var excludeAddCmd = &cobra.Command{
+	Use:   "add <pattern>...",
+	Short: "Add exclusion patterns",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runExcludeAdd,
+}
+
+var excludeRemoveCmd = &cobra.Command{
+	Use:   "remove <pattern>...",
+	Short: "Remove exclusion patterns",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runExcludeRemove,
+}
+
+var excludeSuggestCmd = &cobra.Command{
+	Use:               "suggest [checkpoint-id]",
+	Short:             "Review exclusions suggested by unusually large checkpoints",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runExcludeSuggest,
+}
+
+func init() {
+	rootCmd.AddCommand(excludeCmd)
+	excludeCmd.AddCommand(excludeAddCmd)
+	excludeCmd.AddCommand(excludeRemoveCmd)
+	excludeCmd.AddCommand(excludeSuggestCmd)
+	excludeSuggestCmd.Flags().IntVar(&excludeSuggestDays, "days", 7, "Look at checkpoints from this many days")
+}
+
+func runExcludeList(cmd *cobra.Command, args []string) error {
+	bold := color.New(color.Bold)
+
+	bold.Println("exclude_paths:")
+	patterns := viper.GetStringSlice("exclude_paths")
+	if len(patterns) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, p := range patterns {
+		fmt.Printf("  %s\n", p)
+	}
+
+	var builtIn, disabled []string
+	for _, name := range checkpoint.DefaultExclusions {
+		if checkpoint.DefaultExclusionDisabled(name) {
+			disabled = append(disabled, name)
+		} else {
+			builtIn = append(builtIn, name)
+		}
+	}
+	bold.Println("\nBuilt in:")
+	fmt.Printf("  %s\n", strings.Join(builtIn, ", "))
+	if len(disabled) > 0 {
+		bold.Println("\nBuilt in, but backed up:")
+		fmt.Printf("  %s\n", strings.Join(disabled, ", "))
+	}
+
+	if dismissed := checkpoint.DismissedExclusions(); len(dismissed) > 0 {
+		bold.Println("\nNever suggested again:")
+		fmt.Printf("  %s\n", strings.Join(dismissed, ", "))
+	}
+	return nil
+}
+
+func runExcludeAdd(cmd *cobra.Command, args []string) error {
+	for _, p := range args {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+
+	patterns := viper.GetStringSlice("exclude_paths")
+	disabled := viper.GetStringSlice("disabled_exclusions")
+	var added, enabled []string
+	for _, p := range args {
+		if containsString(disabled, p) {
+			enabled = append(enabled, p)
+			continue
+		}
+		if !containsString(patterns, p) {
+			patterns = append(patterns, p)
+			added = append(added, p)
+		}
+	}
+	if len(added) == 0 && len(enabled) == 0 {
+		printInfo("Already excluded")
+		return nil
+	}
+	if len(enabled) > 0 {
+		viper.Set("disabled_exclusions", removeStrings(disabled, enabled))
+	}
+	if err := saveExcludePaths(patterns); err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Excluded %s", strings.Join(append(added, enabled...), ", ")))
+	return nil
+}
+
+func runExcludeRemove(cmd *cobra.Command, args []string) error {
+	patterns := viper.GetStringSlice("exclude_paths")
+	disabled := viper.GetStringSlice("disabled_exclusions")
+	removed := 0
+	var unknown []string
+	for _, p := range args {
+		switch {
+		case containsString(patterns, p):
+			patterns = removeStrings(patterns, []string{p})
+			removed++
+		case p == ".safeshell":
+			return fmt.Errorf("%s is always excluded, or checkpoints would back up themselves", p)
+		case containsString(checkpoint.DefaultExclusions, p):
+			// A built-in exclusion, backed up from now on
+			if !containsString(disabled, p) {
+				disabled = append(disabled, p)
+			}
+			removed++
+		default:
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("not in exclude_paths or built in: %s", strings.Join(unknown, ", "))
+	}
+	viper.Set("disabled_exclusions", disabled)
+	if err := saveExcludePaths(patterns); err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Removed %d pattern(s)", removed))
+	return nil
+}
+
+// removeStrings returns list without the strings in remove
+func removeStrings(list, remove []string) []string {
+	kept := []string{}
+	for _, s := range list {
+		if !containsString(remove, s) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func saveExcludePaths(patterns []string) error {
+	if patterns == nil {
+		patterns = []string{}
+	}
+	viper.Set("exclude_paths", patterns)
+	if err := viper.WriteConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+func runExcludeSuggest(cmd *cobra.Command, args []string) error {
+	var suggestions []checkpoint.ExclusionSuggestion
+	if len(args) == 1 {
+		cp, err := getCheckpoint(args[0])
+		if err != nil {
+			return err
+		}
+		suggestions = checkpoint.SuggestExclusions(cp)
+	} else {
+		suggestions = checkpoint.RecentExclusionSuggestions(time.Now().AddDate(0, 0, -excludeSuggestDays))
+	}
+
+	if len(suggestions) == 0 {
+		fmt.Println("No exclusions to suggest.")
+		return nil
+	}
+
+	interactive := !assumeYes && util.CanPrompt()
+	var accepted []string
+	for _, s := range suggestions {
+		fmt.Printf("\n%s contributed %s in %d file(s) (%.0f%% of a checkpoint)\n",
+			strings.TrimSuffix(s.Pattern, "*"), output.FormatBytes(s.Size), s.Files, s.Share*100)
+		for _, dir := range s.Dirs {
+			color.HiBlack("  %s", dir)
+		}
+
+		if !interactive && !assumeYes {
+			fmt.Printf("  Exclude it: safeshell exclude add '%s'\n", s.Pattern)
+			continue
+		}
+		answer := "y"
+		if interactive {
+			answer = askExclusion(s.Pattern)
+		}
+		switch answer {
+		case "y", "yes":
+			accepted = append(accepted, s.Pattern)
+		case "never":
+			if err := checkpoint.DismissExclusion(s.Pattern); err != nil {
+				return fmt.Errorf("failed to record answer: %w", err)
+			}
+		}
+	}
+
+	fmt.Println()
+	if len(accepted) == 0 {
+		return nil
+	}
+	return runExcludeAdd(cmd, accepted)
+}
+
+// askExclusion asks whether to exclude pattern: y, n or never
+func askExclusion(pattern string) string {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("  Exclude %s? [y/N/never]: ", pattern)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return "n"
+		}
+		switch response = strings.TrimSpace(strings.ToLower(response)); response {
+		case "y", "yes", "never":
+			return response
+		case "", "n", "no":
+			return "n"
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/rollback"
+	"github.com/qhkm/safeshell/internal/wrapper"
+)
+
+// Exit codes, so scripts and agents can tell failures apart. Any failure
+// without a code of its own exits with ExitError. 'safeshell wrap' exits
+// with the status of the command it ran, 128 plus the signal if one killed
+// it, or like a shell if it can't be run.
+const (
+	ExitOK           = 0
+	ExitError        = 1
+	ExitNotFound     = 2 // No such checkpoint, or no checkpoints at all
+	ExitRolledBack   = 3 // The checkpoint has already been rolled back
+	ExitStorageLimit = 4 // The disk is full, or the store over max_storage_mb
+	ExitPartial      = 5 // Some files or checkpoints failed, the others didn't
+	ExitProtected    = 6 // 'safeshell wrap': protected_paths or rules refused the command
+
+	ExitInterrupted = 130 // Ctrl-C stopped the operation before it was done
+
+	ExitCannotExecute   = 126 // 'safeshell wrap': the command can't be run
+	ExitCommandNotFound = 127 // 'safeshell wrap': the command isn't there
+)
+
+// codeError is an error that exits with a code other than ExitError
+type codeError struct {
+	code int
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+func (e *codeError) Unwrap() error { return e.err }
+
+// withExitCode makes err exit with code
+func withExitCode(code int, err error) error {
+	return &codeError{code: code, err: err}
+}
+
+// ExitCode returns the exit code for an error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var coded *codeError
+	var exitErr *exec.ExitError
+	var partial *rollback.PartialError
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &exitErr):
+		return wrapper.ExitStatus(exitErr)
+	case errors.Is(err, wrapper.ErrCommandNotFound):
+		return ExitCommandNotFound
+	case errors.Is(err, wrapper.ErrCannotExecute):
+		return ExitCannotExecute
+	case errors.Is(err, wrapper.ErrRefused):
+		return ExitProtected
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &partial):
+		return ExitPartial
+	case errors.Is(err, rollback.ErrAlreadyRolledBack):
+		return ExitRolledBack
+	case errors.Is(err, checkpoint.ErrNotFound), errors.Is(err, checkpoint.ErrNoCheckpoint):
+		return ExitNotFound
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, checkpoint.ErrStorageLimit):
+		return ExitStorageLimit
+	}
+	return ExitError
+}
+
+// errAlreadyRolledBack is the error for rolling back a checkpoint twice
+func errAlreadyRolledBack() error {
+	return withExitCode(ExitRolledBack, errors.New(i18n.T("rollback.already_rolled_back")))
+}
+
+// errorJSON is what a failed command prints with --json
+type errorJSON struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
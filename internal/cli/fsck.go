@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var fsckRepair bool
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the checkpoint store for damage, and repair it",
+	Long: `Checks every checkpoint and the index against what is on disk:
+
+  - manifests that are missing or can't be read
+  - checkpoints whose creation didn't finish
+  - checkpoints with neither a files directory nor an archive
+  - checkpoints marked compressed without an archive, or the other way
+    round, and leftovers of an interrupted compression
+  - index entries missing, pointing at no checkpoint, or out of date
+
+With --repair, fixes what it found. A lost manifest is rebuilt from the
+backups on disk: it lists every file, but the command, session and hashes
+it held are gone. Backups that are lost can't be brought back; 'safeshell
+snapshot restore' may still bring back manifests exactly as they were, so
+try it first.
+
+Exits with 1 if problems were found and not repaired, but for --output
+json, which prints them with "repaired": false.
+
+Options:
+  --repair   Fix the problems found
+
+Examples:
+  safeshell fsck
+  safeshell fsck --repair
+  safeshell fsck --json`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{outputAnnotation: ""},
+	RunE:        runFsck,
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+	fsckCmd.Flags().BoolVar(&fsckRepair, "repair", false, "Fix the problems found")
+}
+
+// fsckJSON is a problem as fsck prints it in JSON
+type fsckJSON struct {
+	ID       string `json:"id,omitempty"`
+	Kind     string `json:"kind"`
+	Problem  string `json:"problem"`
+	Repair   string `json:"repair"`
+	Repaired bool   `json:"repaired"`
+}
+
+func runFsck(cmd *cobra.Command, args []string) error {
+	// Problems found aren't a usage error
+	cmd.SilenceUsage = true
+	problems, err := checkpoint.Fsck(fsckRepair)
+
+	t := output.NewTable("CHECKPOINT", "KIND", "PROBLEM")
+	for _, p := range problems {
+		id := p.ID
+		if id == "" {
+			id = "-"
+		}
+		row := t.Add(id, p.Kind, p.Problem)
+		row.Data = fsckJSON{ID: p.ID, Kind: p.Kind, Problem: p.Problem, Repair: p.Repair, Repaired: fsckRepair}
+		if fsckRepair {
+			row.Note("repaired: "+p.Repair, color.New(color.FgGreen))
+		} else {
+			row.Note("--repair: "+p.Repair, color.New(color.FgHiBlack))
+		}
+	}
+
+	if !humanOutput() {
+		if err := printTable(t); err != nil {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("fsck stopped: %w", err)
+		}
+		return nil
+	} else if len(problems) == 0 && err == nil {
+		printSuccess("No problems found")
+		return nil
+	} else if len(problems) > 0 {
+		if err := printTable(t); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	if err != nil {
+		return fmt.Errorf("fsck stopped: %w", err)
+	}
+	if len(problems) > 0 && !fsckRepair {
+		return fmt.Errorf("%d problem(s) found; run 'safeshell fsck --repair' to fix them", len(problems))
+	}
+	if len(problems) > 0 {
+		printSuccess(fmt.Sprintf("Repaired %d problem(s)", len(problems)))
+	}
+	return nil
+}
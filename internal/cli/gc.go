@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var gcDryRun bool
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove what interrupted operations left in the checkpoint store",
+	Long: `Removes what a crash or a killed safeshell leaves in the store, and tells
+how much space it frees:
+
+  - checkpoints whose creation didn't finish, but for those holding files
+    'rm' moved into them, which 'safeshell fsck --repair' makes rollbackable.
+    'safeshell checkpoint resume' finishes one 'checkpoint create' was
+    interrupted creating instead
+  - checkpoint directories without a valid manifest
+  - archives left next to extracted files, and the other way round
+  - temporary files of interrupted writes
+  - index entries whose checkpoint directory is gone
+
+Anything modified in the last hour is left alone, as it may be a checkpoint
+being created right now. A checkpoint without a manifest still holds its
+backups: to keep it, run 'safeshell fsck --repair' first, which rebuilds
+the manifest.
+
+Options:
+  --dry-run   List what would be removed, without removing it
+
+Examples:
+  safeshell gc --dry-run
+  safeshell gc
+  safeshell gc --json`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{outputAnnotation: ""},
+	RunE:        runGC,
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "List what would be removed, without removing it")
+}
+
+// gcJSON is garbage as gc prints it in JSON
+type gcJSON struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Size    int64  `json:"size"`
+	Removed bool   `json:"removed"`
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	garbage, err := checkpoint.GC(gcDryRun)
+	if err != nil && len(garbage) == 0 {
+		return fmt.Errorf("failed to collect garbage: %w", err)
+	}
+
+	dir := checkpoint.DefaultStore().Dir()
+	var total int64
+	noManifest := 0
+	t := output.NewTable("KIND", "CHECKPOINT", "PATH", "SIZE")
+	for _, g := range garbage {
+		path := g.Path
+		if rel, err := filepath.Rel(dir, path); err == nil && path != "" {
+			path = rel
+		}
+		row := t.Add(g.Kind, orDash(g.ID), orDash(path), output.FormatBytes(g.Size))
+		row.Data = gcJSON{Kind: g.Kind, ID: g.ID, Path: g.Path, Size: g.Size, Removed: !gcDryRun && err == nil}
+		if g.Kind == checkpoint.GarbageNoManifest {
+			row.Color = color.New(color.FgYellow)
+			noManifest++
+		}
+		total += g.Size
+	}
+
+	if !humanOutput() {
+		if perr := printTable(t); perr != nil {
+			return perr
+		}
+	} else if len(garbage) == 0 {
+		fmt.Println("Nothing to collect.")
+		return nil
+	} else {
+		if perr := printTable(t); perr != nil {
+			return perr
+		}
+		fmt.Println()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to collect garbage: %w", err)
+	}
+	if !humanOutput() {
+		return nil
+	}
+
+	if gcDryRun {
+		fmt.Printf("Would reclaim %s from %d item(s). Run 'safeshell gc' to remove them.\n", output.FormatBytes(total), len(garbage))
+		if noManifest > 0 {
+			fmt.Printf("To keep the %d checkpoint(s) without a manifest, run 'safeshell fsck --repair' first.\n", noManifest)
+		}
+		return nil
+	}
+	printSuccess(fmt.Sprintf("Reclaimed %s from %d item(s)", output.FormatBytes(total), len(garbage)))
+	return nil
+}
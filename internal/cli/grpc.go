@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/grpcserver"
+	"github.com/spf13/cobra"
+)
+
+var grpcListen string
+
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "Start the gRPC API server",
+	Long: `Serves the checkpoint lifecycle over gRPC for embedding safeshell in
+larger agent orchestration systems. The service definition is in
+api/safeshell/v1/safeshell.proto.
+
+CreateCheckpoint and Rollback stream progress messages while they run;
+the other calls (list, get, diff, compress, delete) are unary.
+
+There is no authentication: anyone who can reach the address can roll back
+your files. The default only listens on localhost; prefer a unix socket
+when the orchestrator runs on the same machine.
+
+Options:
+  --listen    Address to listen on: host:port or unix:/path/to/socket
+              (default 127.0.0.1:50051)
+
+Examples:
+  safeshell grpc
+  safeshell grpc --listen unix:/tmp/safeshell.sock`,
+	RunE:        runGRPC,
+	Annotations: map[string]string{featureAnnotation: config.FeatureGRPC},
+}
+
+func init() {
+	rootCmd.AddCommand(grpcCmd)
+	grpcCmd.Flags().StringVar(&grpcListen, "listen", "127.0.0.1:50051", "Address to listen on (host:port or unix:/path)")
+}
+
+func runGRPC(cmd *cobra.Command, args []string) error {
+	network, address := "tcp", grpcListen
+	if path, ok := strings.CutPrefix(grpcListen, "unix:"); ok {
+		network, address = "unix", path
+		// Remove a stale socket left by a previous run
+		os.Remove(address)
+	}
+
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", grpcListen, err)
+	}
+
+	server := grpcserver.Register()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		server.GracefulStop()
+	}()
+
+	color.Green("✓ gRPC server listening on %s\n", grpcListen)
+	fmt.Println("Press Ctrl+C to stop.")
+
+	if err := server.Serve(lis); err != nil {
+		return fmt.Errorf("gRPC server error: %w", err)
+	}
+	fmt.Println("Stopped.")
+	return nil
+}
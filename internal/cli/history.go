@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/qhkm/safeshell/internal/rollback"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyRestore int
+
+	// Timeline filters
+	historySession string
+	historyPath    string
+	historyLast    string
+	historyAfter   string
+	historyBefore  string
+	historyLimit   int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [file]",
+	Short: "Show what happened to your files, or the backed-up versions of one",
+	Long: `Without a file, shows a timeline of what happened to the files safeshell
+protects, oldest first: the commands wrapped, with how they exited and
+the checkpoint taken before them, and the checkpoints created, rolled
+back and deleted otherwise. It is built from ~/.safeshell/operations.log.
+
+With a file, lists every checkpoint holding a copy of it, oldest first,
+with the size and content hash of each version. Restoring a version
+first checkpoints the file as it is now, so the restore can itself be
+rolled back.
+
+Options:
+  --restore   Restore the file to the given version number
+  --session   Only the current session's events, or --session=ID another's
+  --path      Only events of checkpoints holding this file or directory
+  --last      Only events within this time (e.g., 24h, 7d, 2w)
+  --after     Only events after this date (YYYY-MM-DD)
+  --before    Only events before this date (YYYY-MM-DD)
+  -n, --limit Number of the latest events to show (0 for all)
+
+Examples:
+  safeshell history                          # What happened lately
+  safeshell history --session --last 2h      # This terminal, last 2 hours
+  safeshell history --session=1587ba3a       # Another terminal's session
+  safeshell history --path ~/project/src     # Everything that touched src
+  safeshell history src/main.go
+  safeshell history src/main.go --restore 3`,
+	Args:        cobra.MaximumNArgs(1),
+	RunE:        runHistory,
+	Annotations: map[string]string{outputAnnotation: ""},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVarP(&historyRestore, "restore", "r", 0, "Restore this version number")
+	historyCmd.Flags().StringVar(&historySession, "session", "", "Only this session's events (current if no ID given)")
+	historyCmd.Flags().Lookup("session").NoOptDefVal = "current"
+	historyCmd.Flags().StringVar(&historyPath, "path", "", "Only events of checkpoints holding this path")
+	historyCmd.Flags().StringVar(&historyLast, "last", "", "Only events within this time (e.g., 24h, 7d)")
+	historyCmd.Flags().StringVar(&historyAfter, "after", "", "Only events after this date (YYYY-MM-DD)")
+	historyCmd.Flags().StringVar(&historyBefore, "before", "", "Only events before this date (YYYY-MM-DD)")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 50, "Number of the latest events to show (0 for all)")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		if historyRestore != 0 {
+			return errors.New("--restore needs a file")
+		}
+		return runTimeline()
+	}
+	for _, name := range []string{"session", "path", "last", "after", "before", "limit"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s is for the timeline, without a file", name)
+		}
+	}
+
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	versions, err := checkpoint.History(path)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no checkpoints contain %s", path)
+	}
+
+	// Hash of the file as it is now, to mark the matching version
+	current := ""
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		current, _ = checkpoint.HashFile(path)
+	}
+
+	if historyRestore != 0 {
+		if historyRestore < 1 || historyRestore > len(versions) {
+			return fmt.Errorf("no version %d (%s has versions 1-%d)", historyRestore, path, len(versions))
+		}
+		return restoreVersion(path, versions[historyRestore-1], current)
+	}
+
+	t := output.NewTable("#", "TIME", "SIZE", "HASH", "CHECKPOINT", "COMMAND")
+	for _, v := range versions {
+		hash := "-"
+		if len(v.Hash) >= 12 {
+			hash = v.Hash[:12]
+		}
+		command := v.Checkpoint.Manifest.Command
+		if len(command) > 30 {
+			command = command[:27] + "..."
+		}
+		isCurrent := current != "" && v.Hash == current
+		if isCurrent {
+			command += "  (current)"
+		}
+
+		row := t.Add(
+			strconv.Itoa(v.Number),
+			v.Checkpoint.Manifest.Timestamp.Format("2006-01-02 15:04:05"),
+			output.FormatBytes(v.Entry.Size),
+			hash,
+			v.Checkpoint.ID,
+			command,
+		)
+		row.Data = versionJSON{
+			Number:       v.Number,
+			Time:         v.Checkpoint.Manifest.Timestamp,
+			Size:         v.Entry.Size,
+			Hash:         v.Hash,
+			CheckpointID: v.Checkpoint.ID,
+			Command:      v.Checkpoint.Manifest.Command,
+			Current:      isCurrent,
+		}
+		if isCurrent {
+			row.Color = color.New(color.FgGreen)
+		}
+	}
+
+	if !humanOutput() {
+		return printTable(t)
+	}
+
+	fmt.Printf("History of %s (%d version(s))\n\n", path, len(versions))
+	if err := printTable(t); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	color.HiBlack("To restore a version: safeshell history %s --restore <#>", args[0])
+	return nil
+}
+
+// versionJSON is a version of a file as history prints it in JSON
+type versionJSON struct {
+	Number       int       `json:"number"`
+	Time         time.Time `json:"time"`
+	Size         int64     `json:"size"`
+	Hash         string    `json:"hash,omitempty"`
+	CheckpointID string    `json:"checkpoint_id"`
+	Command      string    `json:"command"`
+	Current      bool      `json:"current,omitempty"`
+}
+
+// restoreVersion restores v; the rollback checkpoints the file as it is now
+func restoreVersion(path string, v checkpoint.Version, current string) error {
+	if current != "" && current == v.Hash {
+		printInfo(fmt.Sprintf("%s already matches version %d", path, v.Number))
+		return nil
+	}
+
+	if err := rollback.RollbackSelective(v.Checkpoint, []string{path}); err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Restored version %d of %s", v.Number, path))
+	return nil
+}
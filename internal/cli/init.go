@@ -14,23 +14,40 @@ var initCmd = &cobra.Command{
 	Use:     "init",
 	Aliases: []string{"enable"},
 	Short:   "Setup shell aliases for safeshell",
-	Long: `Adds shell aliases to your shell configuration file (.zshrc or .bashrc).
-This makes rm, mv, cp, chmod, and chown automatically create checkpoints.
+	Long: `Adds shell functions to your shell configuration file (.zshrc, .bashrc,
+config.fish, config.nu, .xonshrc; aliases in the last two). This makes the
+commands in the wrapped_commands setting (by default rm, mv, cp, chmod, chown,
+rsync, dd, truncate, shred and find) automatically create checkpoints. The wrapped command's exit status
+is passed on, and 'command rm' runs the real rm. After changing
+wrapped_commands, run 'safeshell disable' and 'safeshell init' again.
 
-Use 'safeshell disable' to remove the aliases and revert to normal binaries.`,
+In PowerShell, Remove-Item, Move-Item and Copy-Item are wrapped in your
+profile instead, along with their aliases such as rm, del and mv on Windows.
+
+With --preexec (bash and zsh), a hook also checkpoints the files a command
+line is about to truncate before it runs, which functions can't see: the
+target of > important.conf, or of tee. In bash the hook is a DEBUG trap, and
+replaces any other.
+
+Use 'safeshell disable' to remove the aliases and revert to normal binaries.
+
+Options:
+  --shell    bash, fish, nu, powershell, xonsh or zsh (default: the shell
+             you run it from)
+  --preexec  Also checkpoint redirection targets (bash and zsh)
+
+Examples:
+  safeshell init
+  safeshell init --shell fish
+  safeshell init --preexec
+  safeshell init --shell powershell`,
 	RunE: runInit,
 }
 
-const aliasBlock = `
-# SafeShell - Automatic filesystem checkpoints
-# Added by 'safeshell init'
-alias rm='safeshell wrap rm'
-alias mv='safeshell wrap mv'
-alias cp='safeshell wrap cp'
-alias chmod='safeshell wrap chmod'
-alias chown='safeshell wrap chown'
-# End SafeShell
-`
+func init() {
+	initCmd.Flags().StringVar(&shellName, "shell", "", "Shell to set up: "+shellNames())
+	initCmd.Flags().BoolVar(&initPreexec, "preexec", false, "Also checkpoint files that > redirections truncate (bash and zsh)")
+}
 
 func runInit(cmd *cobra.Command, args []string) error {
 	homeDir, err := os.UserHomeDir()
@@ -38,23 +55,14 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	// Detect shell
-	shell := os.Getenv("SHELL")
-	var rcFile string
-	switch {
-	case strings.Contains(shell, "zsh"):
-		rcFile = filepath.Join(homeDir, ".zshrc")
-	case strings.Contains(shell, "bash"):
-		// Check for .bash_profile on macOS
-		bashProfile := filepath.Join(homeDir, ".bash_profile")
-		if _, err := os.Stat(bashProfile); err == nil {
-			rcFile = bashProfile
-		} else {
-			rcFile = filepath.Join(homeDir, ".bashrc")
-		}
-	default:
-		rcFile = filepath.Join(homeDir, ".bashrc")
+	shell, err := detectShell()
+	if err != nil {
+		return err
+	}
+	if initPreexec && shell.Preexec == "" {
+		return fmt.Errorf("--preexec is only supported for bash and zsh, not %s", shell.Name)
 	}
+	rcFile := shell.RCFile(homeDir)
 
 	// Check if already initialized
 	if containsSafeShell(rcFile) {
@@ -63,26 +71,33 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Append aliases to shell config
+	// Append aliases to shell config; its directory may not exist yet, as
+	// for a first config.fish or PowerShell profile
+	if err := os.MkdirAll(filepath.Dir(rcFile), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(rcFile), err)
+	}
 	f, err := os.OpenFile(rcFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open %s: %w", rcFile, err)
 	}
 	defer f.Close()
 
-	if _, err := f.WriteString(aliasBlock); err != nil {
+	if _, err := f.WriteString(shell.block()); err != nil {
 		return fmt.Errorf("failed to write aliases: %w", err)
 	}
 
 	printSuccess(fmt.Sprintf("Added SafeShell aliases to %s", rcFile))
 	fmt.Println()
 	fmt.Println("To activate, run:")
-	fmt.Printf("  source %s\n", rcFile)
+	fmt.Printf("  %s\n", shell.reload(rcFile))
 	fmt.Println()
 	fmt.Println("Or start a new terminal session.")
 	fmt.Println()
 	fmt.Println("The following commands will now create automatic checkpoints:")
-	fmt.Println("  rm, mv, cp, chmod, chown")
+	fmt.Printf("  %s\n", shell.wrapped())
+	if initPreexec {
+		fmt.Println("  and files that > redirections and tee truncate")
+	}
 	fmt.Println()
 	fmt.Println("Use 'safeshell list' to view checkpoints")
 	fmt.Println("Use 'safeshell rollback <id>' to restore files")
@@ -2,11 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
-	"github.com/qhkm/safeshell/internal/util"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -31,7 +33,8 @@ Examples:
   safeshell list                # Show recent checkpoints
   safeshell list --session      # Show only current session's checkpoints
   safeshell list --grouped      # Group by session`,
-	RunE: runList,
+	RunE:        runList,
+	Annotations: map[string]string{outputAnnotation: ""},
 }
 
 func init() {
@@ -63,6 +66,16 @@ func runList(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Apply limit
+	displayCount := len(checkpoints)
+	if !listAll && listLimit > 0 && displayCount > listLimit {
+		displayCount = listLimit
+	}
+
+	if !humanOutput() {
+		return printTable(checkpointTable(checkpoints[:displayCount], false))
+	}
+
 	if len(checkpoints) == 0 {
 		if listSession {
 			fmt.Println("No checkpoints found in current session.")
@@ -78,12 +91,6 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Apply limit
-	displayCount := len(checkpoints)
-	if !listAll && listLimit > 0 && displayCount > listLimit {
-		displayCount = listLimit
-	}
-
 	fmt.Printf("Found %d checkpoint(s)", len(checkpoints))
 	if displayCount < len(checkpoints) {
 		fmt.Printf(" (showing %d)", displayCount)
@@ -95,64 +102,8 @@ func runList(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 	fmt.Println()
 
-	// Header
-	headerColor := color.New(color.FgWhite, color.Bold)
-	headerColor.Printf("%-28s  %-20s  %-8s  %s\n", "ID", "TIME", "FILES", "COMMAND")
-	fmt.Println("─────────────────────────────────────────────────────────────────────────────────")
-
-	for i, cp := range checkpoints[:displayCount] {
-		// Format time relative to now
-		timeStr := util.FormatTimeAgo(cp.CreatedAt)
-
-		// Count files (exclude directories)
-		fileCount := 0
-		for _, f := range cp.Manifest.Files {
-			if !f.IsDir {
-				fileCount++
-			}
-		}
-
-		// Truncate command if too long
-		command := cp.Manifest.Command
-		if len(command) > 40 {
-			command = command[:37] + "..."
-		}
-
-		// Build status suffix
-		suffix := ""
-		if cp.Manifest.RolledBack {
-			suffix = " (rolled back)"
-		}
-		if cp.Manifest.Compressed {
-			suffix += " [compressed]"
-		}
-
-		// Color based on rolled back status
-		if cp.Manifest.RolledBack {
-			color.New(color.FgHiBlack).Printf("%-28s  %-20s  %-8d  %s%s\n",
-				cp.ID, timeStr, fileCount, command, suffix)
-		} else if cp.Manifest.Compressed {
-			color.New(color.FgCyan).Printf("%-28s  %-20s  %-8d  %s%s\n",
-				cp.ID, timeStr, fileCount, command, suffix)
-		} else {
-			fmt.Printf("%-28s  %-20s  %-8d  %s\n",
-				cp.ID, timeStr, fileCount, command)
-		}
-
-		// Show tags if any
-		if len(cp.Manifest.Tags) > 0 {
-			color.New(color.FgMagenta).Printf("  └─ tags: %s\n", strings.Join(cp.Manifest.Tags, ", "))
-		} else if cp.Manifest.Note != "" {
-			// Show note if no tags
-			note := cp.Manifest.Note
-			if len(note) > 50 {
-				note = note[:47] + "..."
-			}
-			color.New(color.FgHiBlack).Printf("  └─ %s\n", note)
-		} else if i == 0 {
-			// Show a hint for the first item only if no tags/note
-			color.New(color.FgHiBlack).Println("  └─ Use 'safeshell rollback --last' to restore")
-		}
+	if err := printTable(checkpointTable(checkpoints[:displayCount], true)); err != nil {
+		return err
 	}
 
 	if displayCount < len(checkpoints) {
@@ -169,6 +120,14 @@ func runListGrouped() error {
 		return fmt.Errorf("failed to list checkpoints: %w", err)
 	}
 
+	if !humanOutput() {
+		var all []*checkpoint.Checkpoint
+		for _, cps := range grouped {
+			all = append(all, cps...)
+		}
+		return printTable(checkpointTable(all, false))
+	}
+
 	if len(grouped) == 0 {
 		fmt.Println("No checkpoints found.")
 		return nil
@@ -192,33 +151,126 @@ func runListGrouped() error {
 		}
 
 		color.New(color.FgCyan, color.Bold).Printf("Session: %s\n", sessionLabel)
-		fmt.Printf("─────────────────────────────────────────────\n")
+		if err := printTable(checkpointTable(checkpoints, false)); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
 
-		for _, cp := range checkpoints {
-			timeStr := util.FormatTimeAgo(cp.CreatedAt)
+	return nil
+}
 
-			fileCount := 0
-			for _, f := range cp.Manifest.Files {
-				if !f.IsDir {
-					fileCount++
-				}
-			}
+// checkpointJSON is a checkpoint as commands print it in JSON
+type checkpointJSON struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Command    string    `json:"command"`
+	Files      int       `json:"files"`
+	Size       int64     `json:"size"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Pinned     bool      `json:"pinned,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	RolledBack bool      `json:"rolled_back,omitempty"`
+	Compressed bool      `json:"compressed,omitempty"`
+
+	Outcome *outcomeJSON `json:"outcome,omitempty"`
+}
+
+// outcomeJSON is what the wrapped command of a checkpoint did, in numbers
+type outcomeJSON struct {
+	ExitCode int `json:"exit_code"`
+	Deleted  int `json:"deleted"`
+	Moved    int `json:"moved"`
+	Changed  int `json:"changed"`
+}
 
-			command := cp.Manifest.Command
-			if len(command) > 30 {
-				command = command[:27] + "..."
+func newCheckpointJSON(cp *checkpoint.Checkpoint, files int) checkpointJSON {
+	j := checkpointJSON{
+		ID:         cp.ID,
+		CreatedAt:  cp.CreatedAt,
+		Command:    cp.Manifest.Command,
+		Files:      files,
+		Size:       cp.Manifest.DiskSize(),
+		SessionID:  cp.Manifest.SessionID,
+		Name:       cp.Manifest.Name,
+		Pinned:     cp.Manifest.Pinned,
+		Tags:       cp.Manifest.Tags,
+		Note:       cp.Manifest.Note,
+		RolledBack: cp.Manifest.RolledBack,
+		Compressed: cp.Manifest.Compressed,
+	}
+	if o := cp.Manifest.Outcome; o != nil {
+		e := o.Effect()
+		j.Outcome = &outcomeJSON{ExitCode: o.ExitCode, Deleted: e.Deleted, Moved: e.Moved, Changed: e.Changed}
+	}
+	return j
+}
+
+// checkpointTable lays out checkpoints the way list and search show them,
+// with a rollback hint under the first one if hint is set
+func checkpointTable(checkpoints []*checkpoint.Checkpoint, hint bool) *output.Table {
+	t := output.NewTable("ID", "TIME", "FILES", "SIZE", "COMMAND")
+	for i, cp := range checkpoints {
+		// Count files (exclude directories)
+		fileCount := 0
+		for _, f := range cp.Manifest.Files {
+			if !f.IsDir {
+				fileCount++
 			}
+		}
 
-			if cp.Manifest.RolledBack {
-				color.New(color.FgHiBlack).Printf("  %s  %-15s  %d files  %s (rolled back)\n",
-					cp.ID, timeStr, fileCount, command)
-			} else {
-				fmt.Printf("  %s  %-15s  %d files  %s\n",
-					cp.ID, timeStr, fileCount, command)
+		// Truncate command if too long
+		command := cp.Manifest.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+		if o := cp.Manifest.Outcome; o != nil && o.ExitCode != 0 {
+			command += fmt.Sprintf(" (exit %d)", o.ExitCode)
+		}
+		if cp.Manifest.RolledBack {
+			command += " (rolled back)"
+		}
+		if cp.Manifest.Compressed {
+			command += " [compressed]"
+		}
+
+		row := t.Add(cp.ID, output.FormatTimeAgo(cp.CreatedAt), strconv.Itoa(fileCount), output.FormatBytes(cp.Manifest.DiskSize()), command)
+		row.Data = newCheckpointJSON(cp, fileCount)
+
+		// Color based on rolled back status
+		if cp.Manifest.RolledBack {
+			row.Color = color.New(color.FgHiBlack)
+		} else if cp.Manifest.Compressed {
+			row.Color = color.New(color.FgCyan)
+		}
+
+		// Show whether it is pinned, the name and tags, else the note, else
+		// what the command did, else a hint for the first item
+		var labels []string
+		if cp.Manifest.Pinned {
+			labels = append(labels, "pinned")
+		}
+		if cp.Manifest.Name != "" {
+			labels = append(labels, "name: "+cp.Manifest.Name)
+		}
+		if len(cp.Manifest.Tags) > 0 {
+			labels = append(labels, "tags: "+strings.Join(cp.Manifest.Tags, ", "))
+		}
+		if len(labels) > 0 {
+			row.Note(strings.Join(labels, "  "), color.New(color.FgMagenta))
+		} else if cp.Manifest.Note != "" {
+			note := cp.Manifest.Note
+			if len(note) > 50 {
+				note = note[:47] + "..."
 			}
+			row.Note(note, color.New(color.FgHiBlack))
+		} else if o := cp.Manifest.Outcome; o != nil && o.Effect() != (checkpoint.Effect{}) {
+			row.Note(describeOutcome(o), color.New(color.FgHiBlack))
+		} else if hint && i == 0 {
+			row.Note("Use 'safeshell rollback --last' to restore", color.New(color.FgHiBlack))
 		}
-		fmt.Println()
 	}
-
-	return nil
+	return t
 }
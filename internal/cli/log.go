@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/oplog"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logLast       string
+	logCommand    string
+	logCheckpoint string
+	logFailed     bool
+	logLimit      int
+)
+
+var logCmd = &cobra.Command{
+	Use:     "log",
+	Aliases: []string{"audit"},
+	Short:   "Show the commands safeshell has wrapped",
+	Long: `Shows the commands run through 'safeshell wrap', newest first: when
+and where each ran, who ran it, how it exited and how long it took, and
+the checkpoint taken before it.
+
+Every wrapped command is recorded in ~/.safeshell/operations.log, one JSON
+object per line, including those protected_paths or rules refused to run.
+
+Options:
+  --last         Only commands run within this time (e.g., 24h, 7d, 2w)
+  --command      Only runs of this program (e.g., rm)
+  --checkpoint   Only the command that created this checkpoint
+  --failed       Only commands that failed or were refused
+  -n, --limit    Number of commands to show (0 for all)
+
+Examples:
+  safeshell log                          # The last 20 commands
+  safeshell log --last 24h --command rm  # rm in the last day
+  safeshell log --failed                 # What went wrong
+  safeshell audit --last 30d -n 0 --output json`,
+	RunE:        runLog,
+	Annotations: map[string]string{outputAnnotation: ""},
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	logCmd.Flags().StringVar(&logLast, "last", "", "Only commands run within this time (e.g., 24h, 7d)")
+	logCmd.Flags().StringVar(&logCommand, "command", "", "Only runs of this program")
+	logCmd.Flags().StringVar(&logCheckpoint, "checkpoint", "", "Only the command that created this checkpoint")
+	logCmd.Flags().BoolVar(&logFailed, "failed", false, "Only commands that failed or were refused")
+	logCmd.Flags().IntVarP(&logLimit, "limit", "n", 20, "Number of commands to show (0 for all)")
+}
+
+func runLog(cmd *cobra.Command, args []string) error {
+	var since time.Time
+	if logLast != "" {
+		window, err := parseDuration(logLast)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %s", logLast)
+		}
+		since = time.Now().Add(-window)
+	}
+
+	entries, err := oplog.Read(since)
+	if err != nil {
+		return fmt.Errorf("failed to read operations log: %w", err)
+	}
+
+	// Newest first
+	var matched []oplog.Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		program, _, _ := strings.Cut(e.Command, " ")
+		if e.Op != oplog.OpWrap ||
+			(logCommand != "" && program != logCommand) ||
+			(logCheckpoint != "" && e.CheckpointID != logCheckpoint) ||
+			(logFailed && !e.Failed()) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	total := len(matched)
+	if logLimit > 0 && total > logLimit {
+		matched = matched[:logLimit]
+	}
+
+	t := output.NewTable("TIME", "EXIT", "TOOK", "COMMAND", "CHECKPOINT")
+	for _, e := range matched {
+		exit := "-"
+		if e.ExitCode != nil {
+			exit = strconv.Itoa(*e.ExitCode)
+		}
+		command := e.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+		checkpointID := e.CheckpointID
+		if checkpointID == "" {
+			checkpointID = "-"
+		}
+
+		row := t.Add(
+			e.Time.Local().Format("2006-01-02 15:04:05"),
+			exit,
+			(time.Duration(e.DurationMs) * time.Millisecond).String(),
+			command,
+			checkpointID,
+		)
+		row.Data = e
+		row.Note(fmt.Sprintf("%s in %s", e.User, e.WorkingDir), color.New(color.FgHiBlack))
+		if e.Error != "" {
+			reason, _, _ := strings.Cut(e.Error, "\n")
+			row.Note(reason, color.New(color.FgRed))
+		}
+		if e.Failed() {
+			row.Color = color.New(color.FgRed)
+		}
+	}
+
+	if !humanOutput() {
+		return printTable(t)
+	}
+
+	if total == 0 {
+		fmt.Println("No wrapped commands found.")
+		return nil
+	}
+
+	fmt.Printf("Found %d command(s)", total)
+	if len(matched) < total {
+		fmt.Printf(" (showing %d)", len(matched))
+	}
+	fmt.Println()
+	fmt.Println()
+	if err := printTable(t); err != nil {
+		return err
+	}
+
+	if len(matched) < total {
+		fmt.Println()
+		fmt.Printf("Use 'safeshell log -n 0' to see all %d.\n", total)
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package cli
 import (
 	"fmt"
 
+	"github.com/qhkm/safeshell/internal/config"
 	"github.com/qhkm/safeshell/internal/mcp"
 	"github.com/spf13/cobra"
 )
 
+var mcpDebugAddr string
+
 var mcpCmd = &cobra.Command{
 	Use:   "mcp",
 	Short: "Start the MCP (Model Context Protocol) server",
@@ -30,15 +33,30 @@ To use with Claude Code, add to your MCP settings:
         "args": ["mcp"]
       }
     }
-  }`,
-	RunE: runMCP,
+  }
+
+Options:
+  --debug-addr    Serve pprof profiles (/debug/pprof/) and expvar counters
+                  (/debug/vars) on this loopback address, and start a gops
+                  agent. Messages go to stderr, not the protocol stream.`,
+	RunE:        runMCP,
+	Annotations: map[string]string{featureAnnotation: config.FeatureMCP},
 }
 
 func init() {
 	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().StringVar(&mcpDebugAddr, "debug-addr", "", "Serve pprof/expvar debug endpoints on this loopback address")
 }
 
 func runMCP(cmd *cobra.Command, args []string) error {
+	debug, err := startDebugServer(mcpDebugAddr)
+	if err != nil {
+		return err
+	}
+	if debug != nil {
+		defer debug.Close()
+	}
+
 	server := mcp.NewServer()
 
 	// Suppress any output that might interfere with MCP protocol
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/mountfs"
+	"github.com/spf13/cobra"
+)
+
+var mountLast bool
+
+var mountCmd = &cobra.Command{
+	Use:   "mount <checkpoint-id> <mountpoint>",
+	Short: "Browse a checkpoint as a read-only filesystem",
+	Long: `Mounts a checkpoint's files read-only (via FUSE) so you can browse, grep
+and copy individual files with normal tools instead of rolling back.
+
+Files appear under their original absolute paths. Compressed checkpoints
+are decompressed on demand, one file at a time as they are opened.
+
+The mount stays up until you press Ctrl+C (or run 'umount <mountpoint>').
+Requires FUSE (fuse3 on Linux, macFUSE on macOS).
+
+Options:
+  --last    Mount the most recent checkpoint
+
+Examples:
+  safeshell mount --last /tmp/cp
+  safeshell mount 2024-12-12T143022-a1b2c3 /tmp/cp
+  grep -r TODO /tmp/cp/home/me/project`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeMountArgs,
+	RunE:              runMount,
+}
+
+func init() {
+	rootCmd.AddCommand(mountCmd)
+	mountCmd.Flags().BoolVarP(&mountLast, "last", "l", false, "Mount the most recent checkpoint")
+}
+
+func runMount(cmd *cobra.Command, args []string) error {
+	var cp *checkpoint.Checkpoint
+	var err error
+	var mountpoint string
+
+	if mountLast {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: safeshell mount --last <mountpoint>")
+		}
+		mountpoint = args[0]
+		cp, err = checkpoint.GetLatest()
+		if err != nil {
+			return errNoCheckpoints()
+		}
+	} else {
+		if len(args) != 2 {
+			return fmt.Errorf("usage: safeshell mount <checkpoint-id> <mountpoint>")
+		}
+		mountpoint = args[1]
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
+		}
+	}
+
+	info, err := os.Stat(mountpoint)
+	if err != nil {
+		return fmt.Errorf("mountpoint %s: %w", mountpoint, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("mountpoint %s is not a directory", mountpoint)
+	}
+
+	tree, err := mountfs.NewTree(cp)
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+
+	server, err := mountfs.Mount(tree, mountpoint)
+	if err != nil {
+		return fmt.Errorf("failed to mount (is FUSE installed?): %w", err)
+	}
+
+	color.Green("✓ Mounted %s at %s (read-only)\n", cp.ID, mountpoint)
+	fmt.Println("Press Ctrl+C to unmount.")
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		if err := server.Unmount(); err != nil {
+			printWarning(fmt.Sprintf("Unmount failed: %v (is a shell still inside %s?)", err, mountpoint))
+		}
+	}()
+
+	server.Wait()
+	fmt.Println("Unmounted.")
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+var pinLast bool
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [checkpoint-id]",
+	Short: "Keep a checkpoint from ever being cleaned up",
+	Long: `Pins a checkpoint, so that clean, clean --keep, scheduled cleanup and
+max_checkpoints never delete it, however old it gets. Pin the known good
+states you want to be able to go back to; 'safeshell unpin' lets them age
+out again.
+
+Pinned checkpoints can still be compressed, and deleted by hand.
+
+Examples:
+  safeshell pin --last                      # Pin the most recent checkpoint
+  safeshell pin pre-migration               # Pin a checkpoint by name
+  safeshell pin --at "yesterday 17:00"      # Pin the state as of yesterday evening
+  safeshell unpin 2024-12-12T143022-a1b2c3`,
+	Args:              cobra.MaximumNArgs(1),
+	Annotations:       map[string]string{resultAnnotation: ""},
+	ValidArgsFunction: completeCheckpointID,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPin(args, true)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:               "unpin [checkpoint-id]",
+	Short:             "Let a pinned checkpoint be cleaned up again",
+	Args:              cobra.MaximumNArgs(1),
+	Annotations:       map[string]string{resultAnnotation: ""},
+	ValidArgsFunction: completeCheckpointID,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPin(args, false)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pinCmd, unpinCmd} {
+		rootCmd.AddCommand(cmd)
+		cmd.Flags().BoolVarP(&pinLast, "last", "l", false, "Apply to the most recent checkpoint")
+		addAtFlag(cmd)
+	}
+}
+
+func runPin(args []string, pinned bool) error {
+	var cp *checkpoint.Checkpoint
+	var err error
+	if pinLast || checkpointAt != "" {
+		cp, err = latestCheckpoint()
+	} else if len(args) > 0 {
+		cp, err = getCheckpoint(args[0])
+	} else {
+		return fmt.Errorf("please specify a checkpoint ID, or use --last")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := checkpoint.SetPinned(cp.ID, pinned); err != nil {
+		return fmt.Errorf("failed to update checkpoint: %w", err)
+	}
+	cp.Manifest.Pinned = pinned
+
+	if pinned {
+		printSuccess(fmt.Sprintf("Pinned %s: clean will never delete it", cp.ID))
+	} else {
+		printSuccess(fmt.Sprintf("Unpinned %s", cp.ID))
+	}
+	fileCount := 0
+	for _, f := range cp.Manifest.Files {
+		if !f.IsDir {
+			fileCount++
+		}
+	}
+	return printResult(newCheckpointJSON(cp, fileCount))
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"github.com/qhkm/safeshell/internal/wrapper"
+	"github.com/spf13/cobra"
+)
+
+var preexecCmd = &cobra.Command{
+	Use:   "preexec <command line>",
+	Short: "Checkpoint the files a shell command line is about to truncate",
+	Long: `Creates a checkpoint of the files a command line is about to empty or
+overwrite in ways wrapped commands can't see: output redirections (>, >|, &>)
+and tee without --append. Empty and missing files, and devices such as
+/dev/null, are left alone.
+
+This is called by the shell hook 'safeshell init --preexec' installs, before
+each command line runs; it never stops the command.
+
+Examples:
+  safeshell preexec -- 'echo x > important.conf'`,
+	Args:   cobra.ExactArgs(1),
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		wrapper.Preexec(args[0])
+	},
+	// Like wrap, config is loaded only when there is something to back up
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+func init() {
+	rootCmd.AddCommand(preexecCmd)
+}
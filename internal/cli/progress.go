@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// progressLabels names the phases of an operation shown with showProgress
+var progressLabels = map[string]string{
+	checkpoint.PhaseBackup:   "Backing up",
+	checkpoint.PhaseCompress: "Compressing",
+	checkpoint.PhaseRestore:  "Restoring",
+}
+
+// showProgress shows how far checkpoints, compression and rollbacks have
+// got on stderr while the command runs, unless --quiet: a bar on a
+// terminal, a line every few seconds otherwise. Operations finishing
+// within a second show nothing. The returned func stops it.
+func showProgress() func() {
+	if quiet {
+		return func() {}
+	}
+	bar := output.NewProgress(nil)
+	checkpoint.ReportProgressTo(func(p checkpoint.Progress) {
+		label, ok := progressLabels[p.Phase]
+		switch {
+		case !ok:
+			bar.Clear()
+		case p.Done >= p.Total:
+			// Done: the result is printed in its place
+			bar.Clear()
+		default:
+			bar.Update(label, p.Done, p.Total, p.Bytes, p.TotalBytes)
+		}
+	})
+	return func() {
+		checkpoint.ReportProgressTo(nil)
+		bar.Clear()
+	}
+}
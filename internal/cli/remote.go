@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pushLast bool
+	pushAll  bool
+	pullAll  bool
+	pullList bool
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push [checkpoint-id...]",
+	Short: "Upload checkpoints to remote storage",
+	Long: `Uploads checkpoints to the remote storage configured under 'remote' in
+~/.safeshell/config.yaml, so they survive the machine being destroyed.
+
+Useful for ephemeral CI machines and agent sandboxes.
+
+Options:
+  --last    Push the most recent checkpoint
+  --all     Push every local checkpoint not already in remote storage
+
+Examples:
+  safeshell push --last
+  safeshell push 2024-12-12T143022-a1b2c3
+  safeshell push --all
+
+Config (S3 or any S3-compatible store):
+  remote:
+    type: s3
+    bucket: my-checkpoints
+    prefix: ci/
+    region: us-east-1
+    endpoint: https://minio.internal:9000   # optional
+    path_style: true                        # optional, for MinIO
+  Credentials are read from AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY.
+
+Config (directory, e.g. a network mount):
+  remote:
+    type: local
+    path: /mnt/shared/safeshell`,
+	Annotations:       map[string]string{featureAnnotation: config.FeatureRemoteStorage},
+	ValidArgsFunction: completeCheckpointIDs,
+	RunE:              runPush,
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull [checkpoint-id...]",
+	Short: "Download checkpoints from remote storage",
+	Long: `Downloads checkpoints from remote storage into ~/.safeshell/checkpoints,
+after which they can be listed, diffed and rolled back like local checkpoints.
+
+Options:
+  --list    Show checkpoints available in remote storage
+  --all     Pull every remote checkpoint not already present locally
+
+Examples:
+  safeshell pull --list
+  safeshell pull 2024-12-12T143022-a1b2c3
+  safeshell pull --all`,
+	Annotations: map[string]string{featureAnnotation: config.FeatureRemoteStorage},
+	RunE:        runPull,
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(pullCmd)
+	pushCmd.Flags().BoolVarP(&pushLast, "last", "l", false, "Push the most recent checkpoint")
+	pushCmd.Flags().BoolVarP(&pushAll, "all", "a", false, "Push all checkpoints not yet in remote storage")
+	pullCmd.Flags().BoolVarP(&pullAll, "all", "a", false, "Pull all remote checkpoints not present locally")
+	pullCmd.Flags().BoolVar(&pullList, "list", false, "List checkpoints in remote storage")
+}
+
+func runPush(cmd *cobra.Command, args []string) error {
+	backend, err := checkpoint.DefaultBackend()
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	switch {
+	case pushAll:
+		remote, err := backend.List()
+		if err != nil {
+			return fmt.Errorf("failed to list remote checkpoints: %w", err)
+		}
+		existing := make(map[string]bool)
+		for _, id := range remote {
+			existing[id] = true
+		}
+
+		local, err := checkpoint.List()
+		if err != nil {
+			return err
+		}
+		for _, cp := range local {
+			if !existing[cp.ID] {
+				ids = append(ids, cp.ID)
+			}
+		}
+	case pushLast:
+		cp, err := checkpoint.GetLatest()
+		if err != nil {
+			return errNoCheckpoints()
+		}
+		ids = []string{cp.ID}
+	case len(args) > 0:
+		ids = args
+	default:
+		return fmt.Errorf("please specify a checkpoint ID, use --last, or --all")
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("Remote storage is up to date.")
+		return nil
+	}
+
+	failed := 0
+	for _, id := range ids {
+		fmt.Printf("Pushing %s...\n", id)
+		if err := checkpoint.Push(backend, id); err != nil {
+			printError(fmt.Sprintf("%s: %v", id, err))
+			failed++
+		}
+	}
+
+	pushed := len(ids) - failed
+	if pushed > 0 {
+		color.Green("✓ Pushed %d checkpoint(s)\n", pushed)
+	}
+	if failed > 0 {
+		err := fmt.Errorf("%d checkpoint(s) failed to push", failed)
+		if pushed > 0 {
+			return withExitCode(ExitPartial, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func runPull(cmd *cobra.Command, args []string) error {
+	backend, err := checkpoint.DefaultBackend()
+	if err != nil {
+		return err
+	}
+
+	if pullList {
+		return listRemote(backend)
+	}
+
+	var ids []string
+	switch {
+	case pullAll:
+		remote, err := backend.List()
+		if err != nil {
+			return fmt.Errorf("failed to list remote checkpoints: %w", err)
+		}
+		for _, id := range remote {
+			if _, err := checkpoint.Get(id); err != nil {
+				ids = append(ids, id)
+			}
+		}
+	case len(args) > 0:
+		ids = args
+	default:
+		return fmt.Errorf("please specify a checkpoint ID, use --all, or --list")
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("All remote checkpoints are already present locally.")
+		return nil
+	}
+
+	failed := 0
+	for _, id := range ids {
+		fmt.Printf("Pulling %s...\n", id)
+		if _, err := checkpoint.Pull(backend, id); err != nil {
+			printError(fmt.Sprintf("%s: %v", id, err))
+			failed++
+		}
+	}
+
+	pulled := len(ids) - failed
+	if pulled > 0 {
+		color.Green("✓ Pulled %d checkpoint(s)\n", pulled)
+	}
+	if failed > 0 {
+		err := fmt.Errorf("%d checkpoint(s) failed to pull", failed)
+		if pulled > 0 {
+			return withExitCode(ExitPartial, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func listRemote(backend checkpoint.Backend) error {
+	ids, err := backend.List()
+	if err != nil {
+		return fmt.Errorf("failed to list remote checkpoints: %w", err)
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("No checkpoints in remote storage.")
+		return nil
+	}
+
+	fmt.Printf("%d checkpoint(s) in remote storage:\n\n", len(ids))
+	for _, id := range ids {
+		status := ""
+		if _, err := checkpoint.Get(id); err == nil {
+			status = color.HiBlackString(" (local)")
+		}
+		fmt.Printf("  %s%s\n", id, status)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replicateTarget string
+	replicateLast   bool
+	replicateAll    bool
+)
+
+var replicateCmd = &cobra.Command{
+	Use:   "replicate [checkpoint-id...]",
+	Short: "Mirror checkpoints to a remote machine over SFTP",
+	Long: `Copies checkpoints (manifests, files and the index) to a directory on
+another machine over SFTP, so an off-box copy exists before risky work.
+
+Uses the system sftp client, so your ~/.ssh/config, ssh-agent and
+known_hosts apply. Authentication must not prompt (keys or agent).
+
+Progress is journaled under ~/.safeshell/replicate: re-running after an
+interruption skips checkpoints that were already mirrored and have not
+changed since, and resends the rest.
+
+Options:
+  --target  Remote location: ssh://[user@]host[:port]/path
+            (use /~/path for a path relative to the remote home)
+  --last    Replicate the most recent checkpoint
+  --all     Replicate every local checkpoint
+
+Examples:
+  safeshell replicate --target ssh://backup@db1/srv/safeshell --last
+  safeshell replicate --target ssh://db1:2222/~/checkpoints --all
+  safeshell replicate --target ssh://db1/srv/safeshell 2024-12-12T143022-a1b2c3`,
+	Annotations:       map[string]string{featureAnnotation: config.FeatureRemoteStorage},
+	ValidArgsFunction: completeCheckpointIDs,
+	RunE:              runReplicate,
+}
+
+func init() {
+	rootCmd.AddCommand(replicateCmd)
+	replicateCmd.Flags().StringVarP(&replicateTarget, "target", "t", "", "Remote location (ssh://[user@]host[:port]/path)")
+	replicateCmd.Flags().BoolVarP(&replicateLast, "last", "l", false, "Replicate the most recent checkpoint")
+	replicateCmd.Flags().BoolVarP(&replicateAll, "all", "a", false, "Replicate all local checkpoints")
+	replicateCmd.MarkFlagRequired("target")
+}
+
+func runReplicate(cmd *cobra.Command, args []string) error {
+	target, err := checkpoint.ParseSFTPTarget(replicateTarget)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	switch {
+	case replicateAll:
+		checkpoints, err := checkpoint.List()
+		if err != nil {
+			return err
+		}
+		// Oldest first, so an interrupted run has mirrored the history in order
+		for i := len(checkpoints) - 1; i >= 0; i-- {
+			ids = append(ids, checkpoints[i].ID)
+		}
+	case replicateLast:
+		cp, err := checkpoint.GetLatest()
+		if err != nil {
+			return errNoCheckpoints()
+		}
+		ids = []string{cp.ID}
+	case len(args) > 0:
+		ids = args
+	default:
+		return fmt.Errorf("please specify a checkpoint ID, use --last, or --all")
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("No checkpoints to replicate.")
+		return nil
+	}
+
+	fmt.Printf("Replicating %d checkpoint(s) to %s...\n", len(ids), target)
+	result, err := checkpoint.NewReplicator(target).Replicate(ids)
+	if result != nil {
+		for _, id := range result.Sent {
+			fmt.Printf("  %s %s\n", color.GreenString("✓"), id)
+		}
+		for _, id := range result.Skipped {
+			fmt.Printf("  %s %s\n", color.HiBlackString("="), color.HiBlackString(id+" (up to date)"))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w\nRe-run the same command to resume", err)
+	}
+
+	color.Green("✓ Replicated %d checkpoint(s), %d already up to date\n", len(result.Sent), len(result.Skipped))
+	return nil
+}
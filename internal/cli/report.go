@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/oplog"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportLast   string
+	reportFormat string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize local SafeShell activity",
+	Long: `Summarizes recent activity from the local operations log:
+checkpoints created, data protected, rollbacks performed, and data recovered.
+
+Nothing is sent anywhere - the report is built entirely from ~/.safeshell/operations.log.
+
+Options:
+  --last      Time window to summarize (e.g., 30d, 2w, 24h)
+  --format    Output format: text, markdown, or json
+
+Examples:
+  safeshell report                       # Last 30 days as text
+  safeshell report --last 7d             # Last week
+  safeshell report --format markdown     # Paste into a PR or wiki
+  safeshell report --format json         # For dashboards`,
+	RunE: runReport,
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVar(&reportLast, "last", "30d", "Time window to summarize (e.g., 30d, 24h)")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "text", "Output format: text, markdown, json")
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	window, err := parseDuration(reportLast)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", reportLast)
+	}
+
+	until := time.Now()
+	since := until.Add(-window)
+
+	entries, err := oplog.Read(since)
+	if err != nil {
+		return fmt.Errorf("failed to read operations log: %w", err)
+	}
+
+	summary := oplog.Summarize(entries, since, until)
+	suggestions := checkpoint.RecentExclusionSuggestions(since)
+
+	switch reportFormat {
+	case "json":
+		data, err := json.MarshalIndent(struct {
+			oplog.Summary
+			SuggestedExclusions []checkpoint.ExclusionSuggestion `json:"suggested_exclusions,omitempty"`
+		}{summary, suggestions}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "markdown", "md":
+		fmt.Print(renderReportMarkdown(summary, suggestions))
+	case "text":
+		fmt.Print(renderReportText(summary, suggestions))
+	default:
+		return fmt.Errorf("unknown format: %s (use text, markdown, or json)", reportFormat)
+	}
+
+	return nil
+}
+
+func renderReportText(s oplog.Summary, suggestions []checkpoint.ExclusionSuggestion) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("SafeShell activity: %s to %s\n", s.Since.Format("2006-01-02"), s.Until.Format("2006-01-02")))
+	sb.WriteString("────────────────────────────────────────\n")
+	sb.WriteString(fmt.Sprintf("Checkpoints created:    %d\n", s.CheckpointsCreated))
+	sb.WriteString(fmt.Sprintf("Files protected:        %d\n", s.FilesProtected))
+	sb.WriteString(fmt.Sprintf("Data protected:         %s\n", output.FormatBytes(s.BytesProtected)))
+	sb.WriteString(fmt.Sprintf("Rollbacks performed:    %d\n", s.Rollbacks))
+	sb.WriteString(fmt.Sprintf("Files restored:         %d\n", s.FilesRestored))
+	sb.WriteString(fmt.Sprintf("Data-loss prevented:    ~%s\n", output.FormatBytes(s.BytesRestored)))
+	sb.WriteString(fmt.Sprintf("Checkpoints deleted:    %d\n", s.CheckpointsDeleted))
+	sb.WriteString(fmt.Sprintf("Active days:            %d\n", s.ActiveDays))
+
+	if len(s.TopCommands) > 0 {
+		sb.WriteString("\nTop commands:\n")
+		for _, c := range s.TopCommands {
+			sb.WriteString(fmt.Sprintf("  %-10s %d\n", c.Command, c.Count))
+		}
+	}
+
+	if len(suggestions) > 0 {
+		sb.WriteString("\nSuggested exclusions (from unusually large checkpoints):\n")
+		for _, e := range suggestions {
+			sb.WriteString(fmt.Sprintf("  %-20s %s\n", e.Pattern, output.FormatBytes(e.Size)))
+		}
+		sb.WriteString("Review them with: safeshell exclude suggest\n")
+	}
+	return sb.String()
+}
+
+func renderReportMarkdown(s oplog.Summary, suggestions []checkpoint.ExclusionSuggestion) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## SafeShell activity (%s to %s)\n\n", s.Since.Format("2006-01-02"), s.Until.Format("2006-01-02")))
+	sb.WriteString("| Metric | Value |\n")
+	sb.WriteString("|---|---|\n")
+	sb.WriteString(fmt.Sprintf("| Checkpoints created | %d |\n", s.CheckpointsCreated))
+	sb.WriteString(fmt.Sprintf("| Files protected | %d |\n", s.FilesProtected))
+	sb.WriteString(fmt.Sprintf("| Data protected | %s |\n", output.FormatBytes(s.BytesProtected)))
+	sb.WriteString(fmt.Sprintf("| Rollbacks performed | %d |\n", s.Rollbacks))
+	sb.WriteString(fmt.Sprintf("| Files restored | %d |\n", s.FilesRestored))
+	sb.WriteString(fmt.Sprintf("| Data-loss prevented (est.) | %s |\n", output.FormatBytes(s.BytesRestored)))
+	sb.WriteString(fmt.Sprintf("| Checkpoints deleted | %d |\n", s.CheckpointsDeleted))
+	sb.WriteString(fmt.Sprintf("| Active days | %d |\n", s.ActiveDays))
+
+	if len(s.TopCommands) > 0 {
+		sb.WriteString("\n**Top commands:** ")
+		var parts []string
+		for _, c := range s.TopCommands {
+			parts = append(parts, fmt.Sprintf("`%s` (%d)", c.Command, c.Count))
+		}
+		sb.WriteString(strings.Join(parts, ", "))
+		sb.WriteString("\n")
+	}
+
+	if len(suggestions) > 0 {
+		sb.WriteString("\n**Suggested exclusions:** ")
+		var parts []string
+		for _, e := range suggestions {
+			parts = append(parts, fmt.Sprintf("`%s` (%s)", e.Pattern, output.FormatBytes(e.Size)))
+		}
+		sb.WriteString(strings.Join(parts, ", "))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
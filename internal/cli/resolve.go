@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/spf13/cobra"
+)
+
+// checkpointAt is --at, which stands for the latest checkpoint as of a time
+var checkpointAt string
+
+// addAtFlag gives a command taking a checkpoint ID --at, as an alternative
+// to the ID and --last
+func addAtFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&checkpointAt, "at", "", `Use the latest checkpoint as of a time ("2 hours ago", "yesterday 17:00")`)
+	cmd.MarkFlagsMutuallyExclusive("at", "last")
+}
+
+// getCheckpoint loads the checkpoint ref refers to: a full ID, a unique
+// prefix of one or of its random part, or @N for the Nth latest
+func getCheckpoint(ref string) (*checkpoint.Checkpoint, error) {
+	id, err := checkpoint.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := checkpoint.Get(id)
+	if err != nil {
+		return nil, withExitCode(ExitNotFound, errors.New(i18n.T("rollback.not_found", ref)))
+	}
+	return cp, nil
+}
+
+// latestCheckpoint loads the checkpoint --last or --at stands for
+func latestCheckpoint() (*checkpoint.Checkpoint, error) {
+	if checkpointAt == "" {
+		cp, err := checkpoint.GetLatest()
+		if err != nil {
+			return nil, errNoCheckpoints()
+		}
+		return cp, nil
+	}
+	id, err := checkpoint.ResolveAt(checkpointAt)
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint.Get(id)
+}
+
+// errNoCheckpoints is the error for --last when there are no checkpoints
+func errNoCheckpoints() error {
+	return withExitCode(ExitNotFound, errors.New(i18n.T("rollback.no_checkpoints")))
+}
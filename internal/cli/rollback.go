@@ -2,22 +2,29 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/qhkm/safeshell/internal/rollback"
+	"github.com/qhkm/safeshell/internal/util"
 	"github.com/spf13/cobra"
 )
 
 var (
-	rollbackLast        bool
-	rollbackFiles       string
-	rollbackInteractive bool
-	rollbackToPath      string
+	rollbackLast          bool
+	rollbackFiles         string
+	rollbackInteractive   bool
+	rollbackToPath        string
+	rollbackOnConflict    string
+	rollbackResume        bool
+	rollbackPreserveTimes bool
 )
 
 var rollbackCmd = &cobra.Command{
@@ -26,253 +33,338 @@ var rollbackCmd = &cobra.Command{
 	Long: `Restores files from a checkpoint to their original locations.
 
 You can either specify a checkpoint ID, or use --last to rollback the most recent checkpoint.
+The ID can be shortened to any unique prefix of it or of its random part
+(a1b2 for 2024-12-12T143022-a1b2c3d4), or given as @N for the Nth latest
+checkpoint (@1 is the latest, @2 the one before). Every command taking a
+checkpoint ID accepts these.
 
 Options:
-  --files    Restore only specific files (comma-separated paths)
-  -i         Interactive mode - select which files to restore
+  --files    Restore only specific files: comma-separated paths,
+             directories (everything under them) or globs, where **
+             spans directories; quote globs so the shell leaves them be
+  -i         Interactive mode - pick the files to restore in a full-screen
+             list showing which are deleted, modified or unchanged, with
+             search (/) and a diff of each file (d). Needs a terminal;
+             with --yes, restores all files
   --to       Restore files to a different directory instead of original locations
+  --on-conflict
+             What to do with files changed since the checkpoint: restore,
+             keep (the current file) or both (save the current file as
+             <file>.current, then restore). Asked per file in a terminal;
+             otherwise both, or restore with --yes.
+  --at       Use the latest checkpoint as of a time: "2 hours ago", "90m",
+             "yesterday 17:00", "14:30" or "2024-12-12 14:30"
+  --resume   Continue a rollback that was interrupted (Ctrl-C, crash, full
+             disk), restoring only the files it hadn't restored yet; with
+             --last, the most recent interrupted one
+  --preserve-times
+             Give restored files the modification and access times they had
+             when backed up, instead of now (also: preserve_times in config).
+             Build tools like make may then not rebuild from them.
 
 Examples:
   safeshell rollback --last
   safeshell rollback 2024-12-12T143022-a1b2c3
+  safeshell rollback a1b2                        # By a short ID
+  safeshell rollback @2                          # The second latest
+  safeshell rollback --at "2 hours ago"          # As things were 2 hours ago
   safeshell rollback --last --files "src/main.go,config.json"
+  safeshell rollback --last --files "src/**/*.go,configs/"
   safeshell rollback --last -i
+  safeshell rollback --last --on-conflict keep   # Never overwrite newer work
+  safeshell rollback --last --resume             # The latest interrupted rollback
   safeshell rollback --last --to ./backup/       # Restore to different directory
-  safeshell rollback --last --to ~/Desktop/old   # Restore to home directory`,
-	RunE: runRollback,
+  safeshell rollback --last --to ./old --preserve-times  # With their original times
+  safeshell rollback --last --to ~/Desktop/old   # Restore to home directory
+  safeshell rollback --last --yes --json         # Report what was restored, for scripts`,
+	Annotations:       map[string]string{resultAnnotation: ""},
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runRollback,
+}
+
+// rollbackJSON is what rollback prints in JSON
+type rollbackJSON struct {
+	Checkpoint  string            `json:"checkpoint"`
+	Restored    []string          `json:"restored,omitempty"` // Not known when resuming
+	Destination string            `json:"destination,omitempty"`
+	Kept        []string          `json:"kept,omitempty"`  // Changed since, left as they are
+	Saved       map[string]string `json:"saved,omitempty"` // Changed since, saved aside there first
+	Resumed     bool              `json:"resumed,omitempty"`
 }
 
 func init() {
 	rollbackCmd.Flags().BoolVarP(&rollbackLast, "last", "l", false, "Rollback the most recent checkpoint")
-	rollbackCmd.Flags().StringVarP(&rollbackFiles, "files", "f", "", "Restore only specific files (comma-separated)")
+	addAtFlag(rollbackCmd)
+	rollbackCmd.Flags().StringVarP(&rollbackFiles, "files", "f", "", "Restore only specific files (comma-separated paths, directories or globs)")
 	rollbackCmd.Flags().BoolVarP(&rollbackInteractive, "interactive", "i", false, "Interactive mode - select files to restore")
 	rollbackCmd.Flags().StringVarP(&rollbackToPath, "to", "t", "", "Restore to a different directory")
+	rollbackCmd.Flags().StringVar(&rollbackOnConflict, "on-conflict", "", "For files changed since the checkpoint: restore, keep or both")
+	rollbackCmd.Flags().BoolVar(&rollbackResume, "resume", false, "Continue an interrupted rollback")
+	rollbackCmd.Flags().BoolVar(&rollbackPreserveTimes, "preserve-times", false, "Restore the modification times files had when backed up")
+	rollbackCmd.RegisterFlagCompletionFunc("files", completeCheckpointPaths)
+	rollbackCmd.RegisterFlagCompletionFunc("on-conflict", cobra.FixedCompletions([]string{"restore", "keep", "both"}, cobra.ShellCompDirectiveNoFileComp))
 }
 
 func runRollback(cmd *cobra.Command, args []string) error {
+	defer showProgress()()
+
 	var cp *checkpoint.Checkpoint
 	var err error
 
-	if rollbackLast {
-		cp, err = checkpoint.GetLatest()
-		if err != nil {
-			return fmt.Errorf("no checkpoints found")
+	if rollbackPreserveTimes {
+		config.Get().PreserveTimes = true
+	}
+
+	if rollbackLast && rollbackResume {
+		// The latest checkpoint is the one taken when the rollback started
+		if cp, err = rollback.LatestInterrupted(); err != nil {
+			return err
+		}
+	} else if rollbackLast || checkpointAt != "" {
+		if cp, err = latestCheckpoint(); err != nil {
+			return err
 		}
 	} else if len(args) > 0 {
-		cp, err = checkpoint.Get(args[0])
-		if err != nil {
-			return fmt.Errorf("checkpoint not found: %s", args[0])
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
 		}
 	} else {
-		return fmt.Errorf("please specify a checkpoint ID or use --last")
+		return errors.New(i18n.T("rollback.specify"))
 	}
 
-	// Show checkpoint info
-	fmt.Println()
-	color.New(color.FgCyan, color.Bold).Printf("Checkpoint: %s\n", cp.ID)
-	fmt.Printf("Command:    %s\n", cp.Manifest.Command)
-	fmt.Printf("Time:       %s\n", cp.Manifest.Timestamp.Format("2006-01-02 15:04:05"))
-	fmt.Println()
+	printCheckpointHeader(cp)
 
 	if cp.Manifest.RolledBack {
-		return fmt.Errorf("checkpoint has already been rolled back")
+		return errAlreadyRolledBack()
+	}
+
+	// The files were chosen when the rollback started
+	if rollbackResume {
+		if rollbackFiles != "" || rollbackInteractive || rollbackToPath != "" {
+			return errors.New("--resume can't be combined with --files, -i or --to")
+		}
+		if err := rollback.Resume(cp, nil); err != nil {
+			return err
+		}
+		printSuccess(i18n.T("rollback.complete"))
+		return printResult(rollbackJSON{Checkpoint: cp.ID, Resumed: true})
 	}
 
 	// Determine which files to restore
 	var filesToRestore []string
 
-	if rollbackInteractive {
+	if rollbackInteractive && assumeYes {
+		// Same as answering 'all'
+		printInfo(i18n.T("rollback.assume_all"))
+	} else if rollbackInteractive {
+		if !util.CanPrompt() {
+			return errors.New(i18n.T("rollback.no_prompt"))
+		}
 		filesToRestore, err = interactiveFileSelect(cp)
 		if err != nil {
 			return err
 		}
 		if len(filesToRestore) == 0 {
-			printWarning("No files selected. Rollback cancelled.")
-			return nil
+			printWarning(i18n.T("rollback.none_selected"))
+			return printResult(rollbackJSON{Checkpoint: cp.ID, Restored: []string{}})
 		}
 	} else if rollbackFiles != "" {
 		// Parse comma-separated file list
 		filesToRestore = parseFileList(rollbackFiles, cp)
 		if len(filesToRestore) == 0 {
-			return fmt.Errorf("none of the specified files found in checkpoint")
+			return errors.New(i18n.T("rollback.files_not_found"))
+		}
+	}
+
+	return restoreCheckpoint(cp, filesToRestore)
+}
+
+// printCheckpointHeader shows which checkpoint is about to be rolled back
+func printCheckpointHeader(cp *checkpoint.Checkpoint) {
+	fmt.Println()
+	color.New(color.FgCyan, color.Bold).Println(i18n.T("checkpoint.header", cp.ID))
+	fmt.Println(i18n.T("checkpoint.command", cp.Manifest.Command))
+	fmt.Println(i18n.T("checkpoint.time", cp.Manifest.Timestamp.Format("2006-01-02 15:04:05")))
+	fmt.Println()
+}
+
+// restoreCheckpoint rolls back filesToRestore (all files if empty) from cp,
+// dealing with the ones changed since as --on-conflict says
+func restoreCheckpoint(cp *checkpoint.Checkpoint, filesToRestore []string) error {
+	var err error
+	result := rollbackJSON{Checkpoint: cp.ID, Destination: rollbackToPath, Restored: []string{}}
+
+	// Files changed since the checkpoint are only overwritten if wanted
+	if rollbackToPath == "" {
+		if filesToRestore, err = resolveConflicts(cp, filesToRestore, &result); err != nil {
+			return err
+		}
+		if filesToRestore != nil && len(filesToRestore) == 0 {
+			printWarning(i18n.T("rollback.none_selected"))
+			return printResult(result)
 		}
 	}
 
-	// Count files
-	fileCount := 0
 	if len(filesToRestore) > 0 {
-		fileCount = len(filesToRestore)
+		result.Restored = filesToRestore
 	} else {
 		for _, f := range cp.Manifest.Files {
 			if !f.IsDir {
-				fileCount++
+				result.Restored = append(result.Restored, f.OriginalPath)
 			}
 		}
 	}
+	fileCount := len(result.Restored)
 
 	if rollbackToPath != "" {
-		fmt.Printf("Restoring %d file(s) to %s...\n", fileCount, rollbackToPath)
+		fmt.Println(i18n.T("rollback.restoring_to", fileCount, rollbackToPath))
 	} else {
-		fmt.Printf("Restoring %d file(s)...\n", fileCount)
+		fmt.Println(i18n.T("rollback.restoring", fileCount))
 	}
 	fmt.Println()
 
-	// Perform rollback
+	// Perform rollback; Ctrl-C stops it before any file is put in place
+	ctx, stop := interruptible()
+	defer stop()
+	if len(filesToRestore) == 0 {
+		filesToRestore = nil
+	}
 	if rollbackToPath != "" {
 		// Restore to different directory
-		if len(filesToRestore) > 0 {
-			if err := rollback.RollbackSelectiveToPath(cp, filesToRestore, rollbackToPath); err != nil {
-				return err
-			}
-		} else {
-			if err := rollback.RollbackToPath(cp, rollbackToPath); err != nil {
-				return err
-			}
-		}
-	} else if len(filesToRestore) > 0 {
-		// Selective rollback
-		if err := rollback.RollbackSelective(cp, filesToRestore); err != nil {
-			return err
-		}
-	} else {
-		// Full rollback
-		if err := rollback.Rollback(cp); err != nil {
+		if err := rollback.RollbackToPathContext(ctx, cp, filesToRestore, rollbackToPath); err != nil {
 			return err
 		}
+	} else if err := rollback.RollbackContext(ctx, cp, filesToRestore); err != nil {
+		return err
 	}
 
-	printSuccess("Rollback complete!")
-	return nil
+	printSuccess(i18n.T("rollback.complete"))
+	return printResult(result)
 }
 
-func interactiveFileSelect(cp *checkpoint.Checkpoint) ([]string, error) {
-	var files []checkpoint.FileEntry
-	for _, f := range cp.Manifest.Files {
-		if !f.IsDir {
-			files = append(files, f)
+// resolveConflicts decides what happens to the files among paths (all if
+// nil) that changed since the checkpoint, saving current files aside as
+// asked, and returns the paths left to restore. It returns nil when all
+// files are still to be restored. What it did is recorded in result.
+func resolveConflicts(cp *checkpoint.Checkpoint, paths []string, result *rollbackJSON) ([]string, error) {
+	var policy rollback.Resolution
+	ask := false
+	switch rollbackOnConflict {
+	case "restore":
+		policy = rollback.Restore
+	case "keep":
+		policy = rollback.KeepCurrent
+	case "both":
+		policy = rollback.SaveBoth
+	case "":
+		if assumeYes {
+			policy = rollback.Restore
+		} else if util.CanPrompt() {
+			ask = true
+		} else {
+			policy = rollback.SaveBoth
 		}
+	default:
+		return nil, fmt.Errorf("invalid --on-conflict %q (use restore, keep or both)", rollbackOnConflict)
 	}
 
-	if len(files) == 0 {
-		return nil, fmt.Errorf("no files in checkpoint")
+	conflicts := rollback.Conflicts(cp, paths)
+	if len(conflicts) == 0 {
+		return paths, nil
 	}
 
-	// Get current working directory for relative path display
-	cwd, _ := os.Getwd()
-
-	color.New(color.FgWhite, color.Bold).Println("Select files to restore:")
-	fmt.Println("Enter file numbers (comma-separated), 'all' for all files, or 'q' to quit")
-	fmt.Println()
-
-	for i, f := range files {
-		displayPath := f.OriginalPath
-		if cwd != "" {
-			if rel, err := filepath.Rel(cwd, f.OriginalPath); err == nil && !strings.HasPrefix(rel, "..") {
-				displayPath = rel
+	color.Yellow("%s\n", i18n.T("rollback.conflicts", len(conflicts)))
+	reader := bufio.NewReader(os.Stdin)
+	keep := make(map[string]bool)
+	for _, c := range conflicts {
+		resolution := policy
+		if ask {
+			fmt.Printf("  %s (%s now, %s in checkpoint)\n", c.Path, output.FormatBytes(c.CurrentSize), output.FormatBytes(c.BackupSize))
+			answer, err := askConflict(reader)
+			if err != nil {
+				return nil, err
+			}
+			// Upper case answers apply to the remaining files too
+			if answer.all {
+				policy, ask = answer.Resolution, false
 			}
+			resolution = answer.Resolution
 		}
 
-		// Check current status
-		status := ""
-		if _, err := os.Stat(f.OriginalPath); os.IsNotExist(err) {
-			status = color.RedString(" [deleted]")
-		} else {
-			status = color.YellowString(" [modified]")
+		switch resolution {
+		case rollback.KeepCurrent:
+			keep[c.Path] = true
+			result.Kept = append(result.Kept, c.Path)
+			fmt.Println(i18n.T("rollback.conflict_kept", c.Path))
+		case rollback.SaveBoth:
+			saved, err := rollback.SaveCurrent(c.Path)
+			if err != nil {
+				return nil, err
+			}
+			if result.Saved == nil {
+				result.Saved = make(map[string]string)
+			}
+			result.Saved[c.Path] = saved
+			fmt.Println(i18n.T("rollback.conflict_saved", c.Path, saved))
 		}
-
-		fmt.Printf("  [%d] %s%s\n", i+1, displayPath, status)
 	}
-
 	fmt.Println()
-	fmt.Print("Selection: ")
-
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, err
-	}
 
-	input = strings.TrimSpace(input)
-
-	if input == "q" || input == "quit" {
-		return nil, nil
-	}
-
-	if input == "all" || input == "a" {
-		var paths []string
-		for _, f := range files {
-			paths = append(paths, f.OriginalPath)
-		}
+	if len(keep) == 0 {
 		return paths, nil
 	}
-
-	// Parse selection
-	var selectedPaths []string
-	parts := strings.Split(input, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		var idx int
-		if _, err := fmt.Sscanf(part, "%d", &idx); err == nil {
-			if idx >= 1 && idx <= len(files) {
-				selectedPaths = append(selectedPaths, files[idx-1].OriginalPath)
+	if paths == nil {
+		for _, f := range cp.Manifest.Files {
+			if !f.IsDir {
+				paths = append(paths, f.OriginalPath)
 			}
 		}
 	}
-
-	return selectedPaths, nil
-}
-
-func parseFileList(fileList string, cp *checkpoint.Checkpoint) []string {
-	// Build a map of checkpoint files for quick lookup
-	checkpointFiles := make(map[string]bool)
-	for _, f := range cp.Manifest.Files {
-		if !f.IsDir {
-			checkpointFiles[f.OriginalPath] = true
-			// Also add relative paths
-			if cwd, err := os.Getwd(); err == nil {
-				if rel, err := filepath.Rel(cwd, f.OriginalPath); err == nil {
-					checkpointFiles[rel] = true
-				}
-			}
+	remaining := []string{}
+	for _, p := range paths {
+		if !keep[p] {
+			remaining = append(remaining, p)
 		}
 	}
+	return remaining, nil
+}
 
-	var matched []string
-	parts := strings.Split(fileList, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-
-		// Try as-is
-		if checkpointFiles[part] {
-			// Convert back to absolute path if needed
-			if filepath.IsAbs(part) {
-				matched = append(matched, part)
-			} else {
-				if cwd, err := os.Getwd(); err == nil {
-					matched = append(matched, filepath.Join(cwd, part))
-				}
-			}
-			continue
-		}
+// conflictAnswer is a resolution picked at the prompt, and whether it was
+// picked for all remaining files
+type conflictAnswer struct {
+	rollback.Resolution
+	all bool
+}
 
-		// Try as absolute path
-		absPath, _ := filepath.Abs(part)
-		if checkpointFiles[absPath] {
-			matched = append(matched, absPath)
-			continue
+// askConflict asks what to do with one conflicting file
+func askConflict(reader *bufio.Reader) (conflictAnswer, error) {
+	for {
+		fmt.Print(i18n.T("rollback.conflict_prompt"))
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return conflictAnswer{}, err
 		}
-
-		// Partial match - check if path ends with the given string
-		for cpPath := range checkpointFiles {
-			if strings.HasSuffix(cpPath, "/"+part) || strings.HasSuffix(cpPath, part) {
-				matched = append(matched, cpPath)
-				break
-			}
+		input = strings.TrimSpace(input)
+		all := input != strings.ToLower(input)
+		switch strings.ToLower(input) {
+		case "r":
+			return conflictAnswer{rollback.Restore, all}, nil
+		case "k":
+			return conflictAnswer{rollback.KeepCurrent, all}, nil
+		case "b":
+			return conflictAnswer{rollback.SaveBoth, all}, nil
 		}
 	}
+}
 
+// parseFileList picks the checkpoint files named by a comma-separated list
+// of paths, directories and globs, relative ones taken from the current
+// directory
+func parseFileList(fileList string, cp *checkpoint.Checkpoint) []string {
+	cwd, _ := os.Getwd()
+	matched, unmatched := checkpoint.SelectFiles(cp, strings.Split(fileList, ","), cwd)
+	for _, pattern := range unmatched {
+		printWarning(i18n.T("rollback.pattern_unmatched", pattern))
+	}
 	return matched
 }
@@ -0,0 +1,380 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+)
+
+// selectItem is one file offered for restoring
+type selectItem struct {
+	checkpoint.FileDiff
+	display  string // path relative to the working directory when inside it
+	selected bool
+}
+
+// selectModel is the full-screen file picker of 'rollback -i'. Files are
+// listed with how they changed since the checkpoint; changed ones start
+// selected, as restoring an unchanged file does nothing.
+type selectModel struct {
+	cp    *checkpoint.Checkpoint
+	items []selectItem
+
+	search    string
+	searching bool  // keys go to the search box
+	visible   []int // items matching the search
+	cursor    int   // position in visible
+	offset    int   // first row of visible on screen
+
+	preview       []string // diff of the file under the cursor, while shown
+	previewOffset int
+
+	width, height int
+	confirmed     bool
+
+	title, help string // in place of the defaults, when embedded
+}
+
+func newSelectModel(cp *checkpoint.Checkpoint) *selectModel {
+	cwd, _ := os.Getwd()
+	m := &selectModel{cp: cp}
+	for _, d := range checkpoint.Compare(cp) {
+		display := d.Path
+		if cwd != "" {
+			if rel, err := filepath.Rel(cwd, d.Path); err == nil && !strings.HasPrefix(rel, "..") {
+				display = rel
+			}
+		}
+		m.items = append(m.items, selectItem{FileDiff: d, display: display, selected: d.Status != checkpoint.DiffUnchanged})
+	}
+	m.filter()
+	return m
+}
+
+func (m *selectModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *selectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+		return m, nil
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		if m.searching {
+			m.searchKey(msg)
+			return m, nil
+		}
+		if m.preview != nil {
+			return m, m.previewKey(msg)
+		}
+		return m, m.listKey(msg)
+	}
+	return m, nil
+}
+
+func (m *selectModel) listKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-m.rows())
+	case "pgdown":
+		m.move(m.rows())
+	case "home", "g":
+		m.move(-len(m.visible))
+	case "end", "G":
+		m.move(len(m.visible))
+	case " ", "x":
+		m.toggle()
+	case "a":
+		m.toggleAll()
+	case "/":
+		m.searching = true
+	case "esc":
+		if m.search != "" {
+			m.search = ""
+			m.filter()
+		}
+	case "d", "tab", "right":
+		m.showPreview()
+	case "enter":
+		m.confirmed = true
+		return tea.Quit
+	case "q":
+		return tea.Quit
+	}
+	return nil
+}
+
+// searchKey edits the search, narrowing the list as it is typed
+func (m *selectModel) searchKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.searching = false
+		return
+	case tea.KeyEsc:
+		m.searching = false
+		m.search = ""
+	case tea.KeyBackspace:
+		if r := []rune(m.search); len(r) > 0 {
+			m.search = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.search += string(msg.Runes)
+	default:
+		return
+	}
+	m.filter()
+}
+
+func (m *selectModel) previewKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up", "k":
+		m.previewOffset--
+	case "down", "j":
+		m.previewOffset++
+	case "pgup":
+		m.previewOffset -= m.rows()
+	case "pgdown":
+		m.previewOffset += m.rows()
+	case " ", "x":
+		m.toggle()
+	case "d", "tab", "left", "esc":
+		m.preview = nil
+		return nil
+	case "enter":
+		m.confirmed = true
+		return tea.Quit
+	case "q":
+		return tea.Quit
+	}
+	m.previewOffset = max(0, min(m.previewOffset, len(m.preview)-m.rows()))
+	return nil
+}
+
+// filter shows the files whose path contains the search, ignoring case
+func (m *selectModel) filter() {
+	search := strings.ToLower(m.search)
+	m.visible = m.visible[:0]
+	for i, item := range m.items {
+		if strings.Contains(strings.ToLower(item.display), search) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor = 0
+	m.offset = 0
+}
+
+func (m *selectModel) move(by int) {
+	m.cursor = max(0, min(m.cursor+by, len(m.visible)-1))
+	m.scroll()
+}
+
+// scroll keeps the cursor on screen
+func (m *selectModel) scroll() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if rows := m.rows(); m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+// rows is the number of list or preview lines that fit on screen
+func (m *selectModel) rows() int {
+	if m.height == 0 {
+		return 20 // No size reported yet
+	}
+	return max(1, m.height-5)
+}
+
+func (m *selectModel) current() *selectItem {
+	if len(m.visible) == 0 {
+		return nil
+	}
+	return &m.items[m.visible[m.cursor]]
+}
+
+func (m *selectModel) toggle() {
+	if item := m.current(); item != nil {
+		item.selected = !item.selected
+	}
+}
+
+// toggleAll selects every file shown, or unselects them if all are selected
+func (m *selectModel) toggleAll() {
+	all := true
+	for _, i := range m.visible {
+		all = all && m.items[i].selected
+	}
+	for _, i := range m.visible {
+		m.items[i].selected = !all
+	}
+}
+
+// showPreview diffs the file under the cursor against its backup
+func (m *selectModel) showPreview() {
+	item := m.current()
+	if item == nil {
+		return
+	}
+	m.previewOffset = 0
+	m.preview = diffPreview(m.cp, item.FileDiff)
+}
+
+// diffPreview returns the lines of a file's diff against its backup
+func diffPreview(cp *checkpoint.Checkpoint, d checkpoint.FileDiff) []string {
+	if d.Status == checkpoint.DiffUnchanged {
+		return []string{i18n.T("rollback.preview_unchanged")}
+	}
+	var patch bytes.Buffer
+	if _, err := checkpoint.WritePatch(&patch, cp, []checkpoint.FileDiff{d}, filepath.Dir(d.Path)); err != nil {
+		return []string{err.Error()}
+	}
+	return strings.Split(strings.TrimSuffix(patch.String(), "\n"), "\n")
+}
+
+// diffLineColor colors a line of the preview by what it is
+func diffLineColor(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return color.New(color.Bold).Sprint(line)
+	case strings.HasPrefix(line, "+"):
+		return color.GreenString("%s", line)
+	case strings.HasPrefix(line, "-"):
+		return color.RedString("%s", line)
+	case strings.HasPrefix(line, "@@"):
+		return color.CyanString("%s", line)
+	}
+	return line
+}
+
+func (m *selectModel) View() string {
+	var b strings.Builder
+	bold := color.New(color.Bold)
+	dim := color.New(color.FgHiBlack)
+
+	if item := m.current(); m.preview != nil && item != nil {
+		bold.Fprintln(&b, i18n.T("rollback.preview_title", item.display))
+		dim.Fprintln(&b, i18n.T("rollback.preview_direction"))
+		fmt.Fprintln(&b)
+		end := min(len(m.preview), m.previewOffset+m.rows())
+		for _, line := range m.preview[m.previewOffset:end] {
+			fmt.Fprintln(&b, diffLineColor(m.clip(line, 0)))
+		}
+		fmt.Fprintln(&b)
+		dim.Fprint(&b, i18n.T("rollback.preview_help"))
+		return b.String()
+	}
+
+	title := m.title
+	if title == "" {
+		title = i18n.T("rollback.select_title")
+	}
+	bold.Fprintln(&b, title)
+	if m.searching || m.search != "" {
+		cursor := ""
+		if m.searching {
+			cursor = "▏"
+		}
+		fmt.Fprintln(&b, i18n.T("rollback.select_search", m.search+cursor))
+	} else {
+		fmt.Fprintln(&b)
+	}
+	fmt.Fprintln(&b)
+
+	end := min(len(m.visible), m.offset+m.rows())
+	shown := end - m.offset
+	if len(m.visible) == 0 {
+		dim.Fprintln(&b, "  "+i18n.T("rollback.select_no_match"))
+		shown = 1
+	}
+	for row := m.offset; row < end; row++ {
+		item := m.items[m.visible[row]]
+		pointer := "  "
+		if row == m.cursor {
+			pointer = color.CyanString("> ")
+		}
+		box := "[ ]"
+		if item.selected {
+			box = color.GreenString("[x]")
+		}
+		fmt.Fprintf(&b, "%s%s %s %s\n", pointer, box, m.clip(item.display, 20), statusLabel(item.Status))
+	}
+	for ; shown < m.rows(); shown++ {
+		fmt.Fprintln(&b)
+	}
+
+	selected := 0
+	for _, item := range m.items {
+		if item.selected {
+			selected++
+		}
+	}
+	fmt.Fprintln(&b)
+	help := m.help
+	if help == "" {
+		help = i18n.T("rollback.select_help")
+	}
+	dim.Fprintf(&b, "%s · %s", i18n.T("rollback.select_count", selected, len(m.items)), help)
+	return b.String()
+}
+
+// clip cuts text to the screen width less margin, so it doesn't wrap
+func (m *selectModel) clip(text string, margin int) string {
+	if m.width == 0 {
+		return text
+	}
+	return truncateLine(text, max(10, m.width-margin))
+}
+
+func statusLabel(status string) string {
+	switch status {
+	case checkpoint.DiffDeleted:
+		return color.RedString(i18n.T("status.deleted"))
+	case checkpoint.DiffModified:
+		return color.YellowString(i18n.T("status.modified"))
+	}
+	return color.New(color.FgHiBlack).Sprint(i18n.T("status.unchanged"))
+}
+
+// interactiveFileSelect lets the user pick the files to restore. It returns
+// nil if they quit.
+func interactiveFileSelect(cp *checkpoint.Checkpoint) ([]string, error) {
+	m := newSelectModel(cp)
+	if len(m.items) == 0 {
+		return nil, errors.New(i18n.T("rollback.no_files"))
+	}
+
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		return nil, err
+	}
+	if !m.confirmed {
+		return nil, nil
+	}
+	return m.selectedPaths(), nil
+}
+
+func (m *selectModel) selectedPaths() []string {
+	var paths []string
+	for _, item := range m.items {
+		if item.selected {
+			paths = append(paths, item.Path)
+		}
+	}
+	return paths
+}
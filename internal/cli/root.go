@@ -2,10 +2,15 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -16,13 +21,42 @@ var (
 		Long: `SafeShell creates automatic filesystem checkpoints before destructive
 operations, enabling safe autonomous agent execution with easy rollback.
 
-Let agents run freely. Everything is reversible.`,
-		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			return config.Init()
-		},
+Let agents run freely. Everything is reversible.
+
+Exit codes:
+  0  Success
+  1  Error
+  2  Checkpoint not found, or no checkpoints at all
+  3  Checkpoint already rolled back
+  4  Out of storage: the disk is full, or the store is over max_storage_mb
+     and max_storage_action is refuse
+  5  Partial failure: some files or checkpoints failed, the others didn't
+'safeshell wrap' exits with the code of the command it ran, dies of the
+same signal if one killed it, and exits with 127 if the command can't be
+found and 126 if it can't be run. With --json, a failed command prints
+{"error": ..., "exit_code": ...} as its result.`,
+		PersistentPreRunE: loadConfig,
 	}
 
 	version = "0.1.9"
+
+	// assumeYes answers prompts without asking (--yes)
+	assumeYes bool
+
+	// outputFlag is the --output value; outputFormat is the parsed format
+	outputFlag   string
+	outputFormat = output.FormatTable
+
+	// jsonFlag is --json, short for --output json
+	jsonFlag bool
+
+	// resultOutput is where printResult writes. It is the real stdout even
+	// once checkOutput has sent everything else to stderr.
+	resultOutput io.Writer = os.Stdout
+
+	// verbose and quiet override log_level (--verbose, --quiet)
+	verbose bool
+	quiet   bool
 )
 
 func init() {
@@ -33,6 +67,13 @@ func init() {
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(cleanCmd)
 	rootCmd.AddCommand(versionCmd)
+
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to prompts instead of asking (prompts fail in CI without it)")
+	rootCmd.PersistentFlags().StringVar(&outputFlag, "output", "table", "Output format for lists: table, json or plain")
+	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "Print the result as JSON for scripts (same as --output json)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Also tell each file backed up or restored")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print warnings from checkpoints and rollbacks")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 }
 
 var versionCmd = &cobra.Command{
@@ -43,8 +84,119 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+// loadConfig loads config, the display language and logging, and enforces
+// policy
+func loadConfig(cmd *cobra.Command, args []string) error {
+	if err := config.Init(); err != nil {
+		return err
+	}
+	i18n.SetLocale(i18n.Detect(config.Get().Language))
+	if err := setupLogging(); err != nil {
+		return err
+	}
+	if err := checkOutput(cmd); err != nil {
+		return err
+	}
+	// The arguments are fine, so scripts only need the error itself
+	cmd.SilenceUsage = quiet || !humanOutput()
+	return checkFeature(cmd)
+}
+
+// setupLogging applies log_level and log_file, then --verbose or --quiet
+func setupLogging() error {
+	cfg := config.Get()
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFile, cfg.SafeShellDir); err != nil {
+		return err
+	}
+	switch {
+	case verbose:
+		logging.SetLevel(slog.LevelDebug)
+	case quiet:
+		logging.SetLevel(slog.LevelWarn)
+	}
+	return nil
+}
+
+// outputAnnotation marks commands that print through printTable and so
+// support every --output format
+const outputAnnotation = "output"
+
+// resultAnnotation marks commands that report what they did through
+// printResult, so they support --output json as well as table
+const resultAnnotation = "result"
+
+// checkOutput parses --output and --json, refusing formats the command
+// can't print
+func checkOutput(cmd *cobra.Command) error {
+	format, err := output.ParseFormat(outputFlag)
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		if cmd.Flags().Changed("output") && format != output.FormatJSON {
+			return fmt.Errorf("--json can't be combined with --output %s", format)
+		}
+		format = output.FormatJSON
+	}
+
+	_, table := cmd.Annotations[outputAnnotation]
+	_, result := cmd.Annotations[resultAnnotation]
+	switch {
+	case format == output.FormatTable || table:
+	case format == output.FormatJSON && result:
+		// Progress and messages go to stderr, so stdout is only the result
+		os.Stdout = os.Stderr
+		color.Output = color.Error
+	default:
+		return fmt.Errorf("'%s' does not support --output %s", cmd.Name(), format)
+	}
+	outputFormat = format
+	return nil
+}
+
+// printTable prints t to stdout in the --output format
+func printTable(t *output.Table) error {
+	return t.Print(os.Stdout, outputFormat)
+}
+
+// printResult prints v as the command's JSON result. People are shown the
+// result as it happens, so it prints nothing in table output.
+func printResult(v any) error {
+	if outputFormat != output.FormatJSON {
+		return nil
+	}
+	return output.PrintJSON(resultOutput, v)
+}
+
+// humanOutput reports whether output is for people rather than scripts,
+// so headings and hints around a table should be printed
+func humanOutput() bool {
+	return outputFormat == output.FormatTable
+}
+
+// featureAnnotation marks commands that can be disabled by organization policy
+const featureAnnotation = "feature"
+
+// checkFeature refuses to run commands whose feature is disabled by policy
+func checkFeature(cmd *cobra.Command) error {
+	for c := cmd; c != nil; c = c.Parent() {
+		feature, ok := c.Annotations[featureAnnotation]
+		if ok && !config.FeatureEnabled(feature) {
+			return fmt.Errorf("'%s' is disabled by organization policy (%s)", c.Name(), config.PolicyPath)
+		}
+	}
+	return nil
+}
+
+// Execute runs the command line. Its error gives the exit code through
+// ExitCode; with --json, the error is also printed as the JSON result.
 func Execute() error {
-	return rootCmd.Execute()
+	defer logging.Close()
+	err := rootCmd.Execute()
+	if err != nil && outputFormat == output.FormatJSON {
+		output.PrintJSON(resultOutput, errorJSON{Error: err.Error(), ExitCode: ExitCode(err)})
+	}
+	return err
 }
 
 // Helper functions for colored output
@@ -10,6 +10,9 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/qhkm/safeshell/internal/util"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +29,7 @@ Examples:
   safeshell schedule enable --hourly    # Enable hourly cleanup
   safeshell schedule enable --keep 10   # Keep 10 most recent checkpoints
   safeshell schedule disable            # Disable automatic cleanup`,
+	Annotations: map[string]string{featureAnnotation: config.FeatureSchedule},
 }
 
 var scheduleEnableCmd = &cobra.Command{
@@ -83,6 +87,7 @@ func runScheduleStatus(cmd *cobra.Command, args []string) error {
 			color.Green("Automatic cleanup: enabled")
 			fmt.Println()
 			fmt.Printf("Schedule: %s\n", describeCronLine(line))
+			printLastCleanRun()
 			fmt.Println()
 			fmt.Println("Disable with: safeshell schedule disable")
 			return nil
@@ -121,6 +126,9 @@ func runScheduleEnable(cmd *cobra.Command, args []string) error {
 	}
 	// Default: use retention_days from config (no extra args needed)
 
+	// Record each run so 'safeshell schedule' can show it
+	cleanArgs = append(cleanArgs, "--report-file")
+
 	cleanCmd := fmt.Sprintf("%s %s", safeshellPath, strings.Join(cleanArgs, " "))
 
 	// Build cron schedule
@@ -229,6 +237,22 @@ func runScheduleDisable(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printLastCleanRun shows when the scheduled cleanup last ran and its result
+func printLastCleanRun() {
+	last := loadLastCleanRun()
+	if last == nil {
+		fmt.Println("Last run: never (no report found yet)")
+		return
+	}
+
+	result := last.Status()
+	if last.Error != "" || len(last.Warnings) > 0 {
+		result = color.YellowString(result)
+	}
+	fmt.Printf("Last run: %s (%s)\n", output.FormatTimeAgo(last.FinishedAt), result)
+	fmt.Printf("Report:   %s\n", last.ReportFile)
+}
+
 func describeCronLine(line string) string {
 	parts := strings.Fields(line)
 	if len(parts) < 5 {
@@ -250,8 +274,17 @@ func describeCronLine(line string) string {
 	return fmt.Sprintf("Daily at %s:%s", hour, minute)
 }
 
-// promptYesNo asks the user for confirmation
+// promptYesNo asks the user for confirmation. --yes answers yes; without a
+// terminal (e.g. in CI) it answers no instead of waiting for input.
 func promptYesNo(prompt string) bool {
+	if assumeYes {
+		return true
+	}
+	if !util.CanPrompt() {
+		fmt.Printf("%s [y/N]: no (not interactive, pass --yes to confirm)\n", prompt)
+		return false
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s [y/N]: ", prompt)
 	response, err := reader.ReadString('\n')
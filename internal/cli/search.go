@@ -7,7 +7,6 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
-	"github.com/qhkm/safeshell/internal/util"
 	"github.com/spf13/cobra"
 )
 
@@ -40,13 +39,15 @@ Examples:
   safeshell search --command "rm -rf"         # Search by command
   safeshell search --after 2024-12-01         # Checkpoints after date
   safeshell search --tag backup --after 2024-12-01  # Combined search`,
-	RunE: runSearch,
+	RunE:        runSearch,
+	Annotations: map[string]string{outputAnnotation: ""},
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
 	searchCmd.Flags().StringVarP(&searchFile, "file", "f", "", "Search by file name/path")
 	searchCmd.Flags().StringVarP(&searchTag, "tag", "t", "", "Search by tag")
+	searchCmd.RegisterFlagCompletionFunc("tag", completeTags)
 	searchCmd.Flags().StringVarP(&searchCommand, "command", "c", "", "Search by command")
 	searchCmd.Flags().StringVar(&searchAfter, "after", "", "Show checkpoints after this date (YYYY-MM-DD)")
 	searchCmd.Flags().StringVar(&searchBefore, "before", "", "Show checkpoints before this date (YYYY-MM-DD)")
@@ -96,6 +97,10 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("search failed: %w", err)
 	}
 
+	if !humanOutput() {
+		return printTable(checkpointTable(results, false))
+	}
+
 	if len(results) == 0 {
 		fmt.Println("No checkpoints found matching your search criteria.")
 		return nil
@@ -103,52 +108,20 @@ func runSearch(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Found %d checkpoint(s)\n\n", len(results))
 
-	// Header
-	headerColor := color.New(color.FgWhite, color.Bold)
-	headerColor.Printf("%-28s  %-20s  %-8s  %s\n", "ID", "TIME", "FILES", "COMMAND")
-	fmt.Println("─────────────────────────────────────────────────────────────────────────────────")
-
-	for _, cp := range results {
-		timeStr := util.FormatTimeAgo(cp.CreatedAt)
-
-		// Count files
-		fileCount := 0
-		for _, f := range cp.Manifest.Files {
-			if !f.IsDir {
-				fileCount++
+	t := checkpointTable(results, false)
+	if opts.FileName != "" {
+		// Show the matching files under each checkpoint
+		for i, cp := range results {
+			for _, m := range matchingFiles(cp, opts.FileName) {
+				t.Rows[i].Note(m, color.New(color.FgHiBlack))
 			}
 		}
-
-		// Truncate command
-		command := cp.Manifest.Command
-		if len(command) > 40 {
-			command = command[:37] + "..."
-		}
-
-		// Color based on rolled back status
-		if cp.Manifest.RolledBack {
-			color.New(color.FgHiBlack).Printf("%-28s  %-20s  %-8d  %s (rolled back)\n",
-				cp.ID, timeStr, fileCount, command)
-		} else {
-			fmt.Printf("%-28s  %-20s  %-8d  %s\n",
-				cp.ID, timeStr, fileCount, command)
-		}
-
-		// Show tags if any
-		if len(cp.Manifest.Tags) > 0 {
-			color.New(color.FgMagenta).Printf("  └─ tags: %s\n", strings.Join(cp.Manifest.Tags, ", "))
-		}
-
-		// If searching by file, show matching files
-		if opts.FileName != "" {
-			showMatchingFiles(cp, opts.FileName)
-		}
 	}
-
-	return nil
+	return printTable(t)
 }
 
-func showMatchingFiles(cp *checkpoint.Checkpoint, search string) {
+// matchingFiles lists the first few files of cp matching search
+func matchingFiles(cp *checkpoint.Checkpoint, search string) []string {
 	searchLower := strings.ToLower(search)
 	var matches []string
 
@@ -161,16 +134,8 @@ func showMatchingFiles(cp *checkpoint.Checkpoint, search string) {
 		}
 	}
 
-	if len(matches) > 0 {
-		// Show first few matching files
-		shown := 0
-		for _, m := range matches {
-			if shown >= 3 {
-				color.New(color.FgHiBlack).Printf("  └─ ... and %d more files\n", len(matches)-3)
-				break
-			}
-			color.New(color.FgHiBlack).Printf("  └─ %s\n", m)
-			shown++
-		}
+	if len(matches) > 3 {
+		return append(matches[:3], fmt.Sprintf("... and %d more files", len(matches)-3))
 	}
+	return matches
 }
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// shellName is the shell init and disable work on, from --shell
+var shellName string
+
+// initPreexec also installs the hook checkpointing redirection targets,
+// from --preexec
+var initPreexec bool
+
+// wrappedCommands are the commands init makes create checkpoints, from
+// wrapped_commands and custom_commands
+func wrappedCommands() []string {
+	return config.Get().Wrapped()
+}
+
+// shellDef is how init and disable set up a shell: where its startup file
+// is, what goes in it and how to load it into a running shell
+type shellDef struct {
+	Name    string
+	RCFile  func(homeDir string) string
+	Wrap    func(command string) string // line making command go through 'safeshell wrap'
+	Block   func() string               // whole block, for shells where Wrap won't do
+	Reload  string                      // command loading the startup file, %s is its path
+	Wrapped string                      // what the block protects, if not wrappedCommands
+	Preexec string                      // hook running 'safeshell preexec' before each command line
+}
+
+var supportedShells = map[string]*shellDef{
+	"zsh": {
+		Name:    "zsh",
+		RCFile:  func(homeDir string) string { return filepath.Join(homeDir, ".zshrc") },
+		Wrap:    shFunction,
+		Reload:  "source %s",
+		Preexec: zshPreexec,
+	},
+	"bash": {
+		Name:    "bash",
+		RCFile:  bashRCFile,
+		Wrap:    shFunction,
+		Reload:  "source %s",
+		Preexec: bashPreexec,
+	},
+	"fish": {
+		Name: "fish",
+		RCFile: func(homeDir string) string {
+			return filepath.Join(xdgConfigHome(homeDir), "fish", "config.fish")
+		},
+		Wrap: func(command string) string {
+			return fmt.Sprintf("function %s --wraps %s; safeshell wrap %s $argv; end", command, command, command)
+		},
+		Reload: "source %s",
+	},
+	"nu": {
+		Name: "nu",
+		RCFile: func(homeDir string) string {
+			return filepath.Join(nushellConfigDir(homeDir), "config.nu")
+		},
+		// ^ runs the external safeshell; nushell expands globs for it
+		Wrap:   func(command string) string { return fmt.Sprintf("alias %s = ^safeshell wrap %s", command, command) },
+		Reload: "source %s",
+	},
+	"xonsh": {
+		Name:   "xonsh",
+		RCFile: func(homeDir string) string { return filepath.Join(homeDir, ".xonshrc") },
+		Wrap: func(command string) string {
+			return fmt.Sprintf("aliases['%s'] = 'safeshell wrap %s'", command, command)
+		},
+		Reload: "source %s",
+	},
+	"powershell": {
+		Name:    "powershell",
+		RCFile:  powershellProfile,
+		Block:   func() string { return powershellBlock },
+		Reload:  ". $PROFILE",
+		Wrapped: "Remove-Item, Move-Item, Copy-Item (and aliases such as rm, del, mv)",
+	},
+}
+
+// shellAliases are other names --shell takes
+var shellAliases = map[string]string{
+	"nushell": "nu",
+	"pwsh":    "powershell",
+}
+
+// shellNames lists the shells --shell takes, for help and errors
+func shellNames() string {
+	var names []string
+	for name := range supportedShells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// detectShell returns the shell named by --shell, or else the one safeshell
+// runs in: $SHELL, or PowerShell where that is unset on Windows or inside
+// PowerShell. Shells it can't tell default to bash.
+func detectShell() (*shellDef, error) {
+	name := strings.ToLower(shellName)
+	if name == "" {
+		shell := os.Getenv("SHELL")
+		name = strings.TrimSuffix(filepath.Base(shell), ".exe")
+		if shell == "" && (runtime.GOOS == "windows" || os.Getenv("PSModulePath") != "") {
+			name = "powershell"
+		}
+		if _, ok := supportedShells[name]; !ok && shellAliases[name] == "" {
+			name = "bash"
+		}
+	}
+	if alias, ok := shellAliases[name]; ok {
+		name = alias
+	}
+	def, ok := supportedShells[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported shell %q (use %s)", shellName, shellNames())
+	}
+	return def, nil
+}
+
+// block returns what init adds to the shell's startup file, between the
+// markers disable looks for
+func (s *shellDef) block() string {
+	if s.Block != nil {
+		return s.Block()
+	}
+	var b strings.Builder
+	b.WriteString("\n# SafeShell - Automatic filesystem checkpoints\n")
+	b.WriteString("# Added by 'safeshell init'\n")
+	for _, command := range wrappedCommands() {
+		b.WriteString(s.Wrap(command) + "\n")
+	}
+	if initPreexec {
+		b.WriteString(s.Preexec)
+	}
+	b.WriteString("# End SafeShell\n")
+	return b.String()
+}
+
+// reload returns the command that loads rcFile into a running shell
+func (s *shellDef) reload(rcFile string) string {
+	if strings.Contains(s.Reload, "%s") {
+		return fmt.Sprintf(s.Reload, rcFile)
+	}
+	return s.Reload
+}
+
+// wrapped returns what the block makes create checkpoints
+func (s *shellDef) wrapped() string {
+	if s.Wrapped != "" {
+		return s.Wrapped
+	}
+	return strings.Join(wrappedCommands(), ", ")
+}
+
+// shFunction is the line for zsh and bash: a function rather than an
+// alias, so the wrapped command gets the expanded arguments and its exit
+// status is passed on, and 'command rm' still bypasses it. An alias of the
+// same name would win over the function, so it is removed first; the
+// function keyword keeps the name from being expanded as one meanwhile.
+func shFunction(command string) string {
+	return fmt.Sprintf(`unalias %s 2>/dev/null; function %s { safeshell wrap %s "$@"; return $?; }`, command, command, command)
+}
+
+// zshPreexec and bashPreexec hand command lines that may truncate a file
+// to 'safeshell preexec' before they run. The test in the shell keeps
+// safeshell from starting for every other command. bash has no preexec,
+// so a DEBUG trap stands in for it, replacing any other DEBUG trap.
+const zshPreexec = `_safeshell_preexec() { [[ $1 == *'>'* || $1 == *tee* ]] && safeshell preexec -- "$1"; return 0; }
+autoload -Uz add-zsh-hook && add-zsh-hook preexec _safeshell_preexec
+`
+
+const bashPreexec = `_safeshell_preexec() { [[ -z $COMP_LINE && ( $BASH_COMMAND == *'>'* || $BASH_COMMAND == *tee* ) ]] && safeshell preexec -- "$BASH_COMMAND"; return 0; }
+trap _safeshell_preexec DEBUG
+`
+
+// bashRCFile is .bash_profile if there is one, as on macOS, and .bashrc
+// otherwise
+func bashRCFile(homeDir string) string {
+	bashProfile := filepath.Join(homeDir, ".bash_profile")
+	if _, err := os.Stat(bashProfile); err == nil {
+		return bashProfile
+	}
+	return filepath.Join(homeDir, ".bashrc")
+}
+
+// xdgConfigHome is $XDG_CONFIG_HOME, or ~/.config
+func xdgConfigHome(homeDir string) string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(homeDir, ".config")
+}
+
+// nushellConfigDir is where nushell reads config.nu: the platform's
+// config directory, which is ~/.config on Linux, ~/Library/Application
+// Support on macOS and %APPDATA% on Windows
+func nushellConfigDir(homeDir string) string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "nushell")
+	}
+	return filepath.Join(xdgConfigHome(homeDir), "nushell")
+}
+
+// powershellProfile returns the profile PowerShell loads for the current
+// user, asking PowerShell itself since Documents may have moved, e.g. to
+// OneDrive
+func powershellProfile(homeDir string) string {
+	for _, exe := range []string{"pwsh", "powershell"} {
+		out, err := exec.Command(exe, "-NoProfile", "-NonInteractive", "-Command", "$PROFILE.CurrentUserCurrentHost").Output()
+		if profile := strings.TrimSpace(string(out)); err == nil && profile != "" {
+			return profile
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(homeDir, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(xdgConfigHome(homeDir), "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+// powershellBlock wraps the cmdlets PowerShell deletes, moves and copies
+// with in functions that checkpoint the existing paths among their
+// arguments before calling the real cmdlet. Functions come before cmdlets,
+// so aliases like rm and del on Windows run them too.
+const powershellBlock = `
+# SafeShell - Automatic filesystem checkpoints
+# Added by 'safeshell init'
+function Invoke-SafeShellCheckpoint {
+    param([string]$Command, [object[]]$Arguments)
+    if ($env:SAFESHELL_DISABLE -and $env:SAFESHELL_DISABLE -notin '0', 'false') { return }
+    $paths = @(foreach ($a in $Arguments) {
+        if ($a -is [string] -and -not $a.StartsWith('-')) {
+            Resolve-Path -Path $a -ErrorAction SilentlyContinue | ForEach-Object { $_.ProviderPath }
+        }
+    })
+    if ($paths.Count -gt 0) {
+        safeshell checkpoint create --quiet --reason "$Command $Arguments" @paths | Out-Null
+    }
+}
+function Remove-Item { Invoke-SafeShellCheckpoint 'Remove-Item' $args; Microsoft.PowerShell.Management\Remove-Item @args }
+function Move-Item { Invoke-SafeShellCheckpoint 'Move-Item' $args; Microsoft.PowerShell.Management\Move-Item @args }
+function Copy-Item { Invoke-SafeShellCheckpoint 'Copy-Item' $args; Microsoft.PowerShell.Management\Copy-Item @args }
+# End SafeShell
+`
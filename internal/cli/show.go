@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showLast    bool
+	showChanged bool
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show [checkpoint-id]",
+	Short: "Show the files in a checkpoint as a tree",
+	Long: `Renders the files and directories a checkpoint holds, with their sizes
+and modes, marking entries that have since been deleted or modified. For a
+checkpoint of a wrapped command, also tells what the command did: its exit
+code and the files it deleted, moved (and where to) or changed.
+
+Options:
+  --last       Show the most recent checkpoint
+  --changed    Only show entries that differ from the current filesystem
+
+Examples:
+  safeshell show --last
+  safeshell show 2024-12-12T143022-a1b2c3
+  safeshell show --last --changed`,
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runShow,
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+	showCmd.Flags().BoolVarP(&showLast, "last", "l", false, "Show the most recent checkpoint")
+	addAtFlag(showCmd)
+	showCmd.Flags().BoolVarP(&showChanged, "changed", "c", false, "Only show deleted or modified entries")
+}
+
+// showNode is one file or directory in the rendered tree
+type showNode struct {
+	name     string
+	entry    *checkpoint.FileEntry // nil for directories not recorded in the manifest
+	status   string                // checkpoint.DiffDeleted, DiffModified or DiffUnchanged
+	movedTo  string                // where the wrapped command moved a deleted file
+	modeOnly bool                  // the wrapped command only changed its permissions
+	size     int64                 // for directories, the size of the files below
+	children map[string]*showNode
+}
+
+func (n *showNode) isDir() bool {
+	return n.entry == nil || n.entry.IsDir
+}
+
+func (n *showNode) child(name string) *showNode {
+	if n.children == nil {
+		n.children = make(map[string]*showNode)
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = &showNode{name: name}
+		n.children[name] = c
+	}
+	return c
+}
+
+// sortedChildren lists directories first, then files, each by name
+func (n *showNode) sortedChildren() []*showNode {
+	children := make([]*showNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].isDir() != children[j].isDir() {
+			return children[i].isDir()
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// prune drops unchanged entries, keeping directories that hold changes.
+// It reports whether anything is left below n.
+func (n *showNode) prune() bool {
+	for name, c := range n.children {
+		if !c.prune() {
+			delete(n.children, name)
+		}
+	}
+	return len(n.children) > 0 || (n.status != "" && n.status != checkpoint.DiffUnchanged)
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	var cp *checkpoint.Checkpoint
+	var err error
+
+	if showLast || checkpointAt != "" {
+		if cp, err = latestCheckpoint(); err != nil {
+			return err
+		}
+	} else if len(args) > 0 {
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
+		}
+	} else {
+		return errors.New(i18n.T("rollback.specify"))
+	}
+
+	statuses := make(map[string]string)
+	for _, d := range checkpoint.Compare(cp) {
+		statuses[d.Path] = d.Status
+	}
+
+	rootPath := commonDir(cp.Manifest.Files)
+	root := &showNode{name: rootPath}
+	var files, dirs, deleted, moved, modified int
+	var total int64
+
+	for i := range cp.Manifest.Files {
+		f := &cp.Manifest.Files[i]
+		rel, err := filepath.Rel(rootPath, f.OriginalPath)
+		if err != nil || rel == "." {
+			continue
+		}
+
+		node := root
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			node = node.child(part)
+			if !f.IsDir {
+				node.size += f.Size
+			}
+		}
+		node.entry = f
+
+		if f.IsDir {
+			dirs++
+			node.status = checkpoint.DiffUnchanged
+			if _, err := os.Stat(f.OriginalPath); err != nil {
+				node.status = checkpoint.DiffDeleted
+			}
+		} else {
+			files++
+			total += f.Size
+			node.status = statuses[f.OriginalPath]
+			if o := cp.Manifest.Outcome; o != nil && node.status == checkpoint.DiffDeleted {
+				node.movedTo = o.Moved[f.OriginalPath]
+			} else if o != nil && node.status == checkpoint.DiffUnchanged && slices.Contains(o.ModeChanged, f.OriginalPath) {
+				node.status, node.modeOnly = checkpoint.DiffModified, true
+			}
+		}
+		switch {
+		case node.movedTo != "":
+			moved++
+		case node.status == checkpoint.DiffDeleted:
+			deleted++
+		case node.status == checkpoint.DiffModified:
+			modified++
+		}
+	}
+
+	fmt.Printf("Checkpoint: %s\n", cp.ID)
+	if cp.Manifest.Name != "" {
+		fmt.Printf("Name:       %s\n", cp.Manifest.Name)
+	}
+	if cp.Manifest.Pinned {
+		fmt.Println("Pinned:     yes, clean never deletes it")
+	}
+	fmt.Printf("Command:    %s\n", cp.Manifest.Command)
+	if o := cp.Manifest.Outcome; o != nil {
+		fmt.Printf("Outcome:    %s\n", describeOutcome(o))
+	}
+	fmt.Printf("Created:    %s (%s)\n", cp.CreatedAt.Format("2006-01-02 15:04:05"), output.FormatTimeAgo(cp.CreatedAt))
+	if cp.Manifest.Compressed {
+		fmt.Printf("Stored:     compressed, %s\n", output.FormatBytes(cp.Manifest.CompressedSize))
+	}
+	if snap := cp.Manifest.FSSnapshot; snap != nil {
+		fmt.Printf("Stored:     %s snapshot %s\n", snap.Backend, snap.Name)
+	}
+	if len(cp.Manifest.Include) > 0 {
+		fmt.Printf("Only:       %s\n", strings.Join(cp.Manifest.Include, ", "))
+	}
+	for _, g := range cp.Manifest.Git {
+		fmt.Printf("Git:        %d unchanged tracked file(s) left out, in %s at %.12s\n", len(g.Files), g.Repo, g.Commit)
+	}
+	fmt.Println()
+
+	if showChanged && !root.prune() {
+		printSuccess("Nothing has changed since this checkpoint")
+		return nil
+	}
+
+	fmt.Println(color.CyanString(root.name))
+	printShowTree(root, "")
+
+	fmt.Println()
+	fmt.Printf("%d file(s), %d dir(s), %s", files, dirs, output.FormatBytes(total))
+	if deleted > 0 || modified > 0 {
+		fmt.Printf(" — %s, %s",
+			color.RedString("%d deleted", deleted),
+			color.YellowString("%d modified", modified))
+	}
+	if moved > 0 {
+		fmt.Printf(", %s", color.MagentaString("%d moved", moved))
+	}
+	fmt.Println()
+	if n := len(cp.Manifest.Placeholders); n > 0 {
+		printInfo(fmt.Sprintf("%d cloud-only file(s) not backed up, their content is in the cloud", n))
+	}
+	return nil
+}
+
+func printShowTree(n *showNode, prefix string) {
+	children := n.sortedChildren()
+	for i, c := range children {
+		branch, indent := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		name := c.name
+		if c.isDir() {
+			name = color.BlueString(name + "/")
+		}
+		details := output.FormatBytes(c.size)
+		if c.entry != nil {
+			details += "  " + c.entry.Mode.String()
+		}
+		line := prefix + branch + name + "  " + color.HiBlackString(details)
+		switch {
+		case c.movedTo != "":
+			line += "  " + color.MagentaString("[moved to %s]", c.movedTo)
+		case c.status == checkpoint.DiffDeleted:
+			line += "  " + color.RedString("[deleted]")
+		case c.modeOnly:
+			line += "  " + color.YellowString("[permissions changed]")
+		case c.status == checkpoint.DiffModified:
+			line += "  " + color.YellowString("[modified]")
+		}
+		fmt.Println(line)
+
+		printShowTree(c, prefix+indent)
+	}
+}
+
+// describeOutcome tells how the wrapped command of a checkpoint exited and
+// what it did to the files
+func describeOutcome(o *checkpoint.Outcome) string {
+	var parts []string
+	for _, p := range []struct {
+		n    int
+		what string
+	}{
+		{len(o.Deleted), "deleted"},
+		{len(o.Moved), "moved"},
+		{len(o.Changed), "changed"},
+		{len(o.ModeChanged), "with new permissions"},
+	} {
+		if p.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", p.n, p.what))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("exited %d, no files changed", o.ExitCode)
+	}
+	return fmt.Sprintf("exited %d: %s", o.ExitCode, strings.Join(parts, ", "))
+}
+
+// commonDir returns the deepest directory containing every entry
+func commonDir(files []checkpoint.FileEntry) string {
+	var common string
+	for _, f := range files {
+		dir := filepath.Dir(f.OriginalPath)
+		if common == "" {
+			common = dir
+			continue
+		}
+		for common != dir && !strings.HasPrefix(dir, common+string(filepath.Separator)) {
+			parent := filepath.Dir(common)
+			if parent == common {
+				break
+			}
+			common = parent
+		}
+	}
+	if common == "" {
+		return string(filepath.Separator)
+	}
+	return common
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "List snapshots of the checkpoint index and manifests",
+	Long: `Lists the snapshots kept for disaster recovery.
+
+Once a day, creating a checkpoint also writes a snapshot: the index and the
+manifest of every checkpoint, in one small zstd-compressed tar. Backups are
+not in it. If the store is damaged, a snapshot still tells which checkpoints
+existed and which files they held, and 'snapshot restore' brings back the
+manifests that were lost, so the backups that survive can be rolled back.
+
+The newest ` + fmt.Sprint(checkpoint.SnapshotKeep) + ` snapshots are kept, in snapshot_dir (default: snapshots in
+the safeshell directory); set snapshot_dir to another disk to keep them apart
+from the store. 'safeshell config set snapshots false' stops them.
+
+Examples:
+  safeshell snapshot                   # List snapshots
+  safeshell snapshot create            # Take one now
+  safeshell snapshot restore           # Bring back lost manifests from the newest
+  safeshell snapshot restore ~/.safeshell/snapshots/snapshot-2024-12-12T143022.tar.zst`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{outputAnnotation: ""},
+	RunE:        runSnapshotList,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:         "create",
+	Short:       "Snapshot the checkpoint index and manifests now",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runSnapshotCreate,
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore [snapshot]",
+	Short: "Bring back lost checkpoint manifests from a snapshot",
+	Long: `Brings back the manifests of checkpoints that are missing or damaged in the
+store, from a snapshot (default: the newest), then rebuilds the index.
+Checkpoints still intact are left alone.
+
+Backups are not in snapshots: each checkpoint brought back is checked, and
+the files whose backups were lost are listed.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runSnapshotRestore,
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) error {
+	snapshots, err := checkpoint.Snapshots()
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if len(snapshots) == 0 && humanOutput() {
+		fmt.Println("No snapshots yet. One is taken with the first checkpoint of each day.")
+		return nil
+	}
+
+	t := output.NewTable("PATH", "TIME", "SIZE")
+	for _, s := range snapshots {
+		t.Add(s.Path, output.FormatTimeAgo(s.Time), output.FormatBytes(s.Size))
+	}
+	return printTable(t)
+}
+
+// snapshotJSON is what snapshot create and restore print with --json
+type snapshotJSON struct {
+	Path     string              `json:"path"`
+	Restored []string            `json:"restored,omitempty"`
+	Lost     map[string][]string `json:"lost,omitempty"`
+}
+
+func runSnapshotCreate(cmd *cobra.Command, args []string) error {
+	s, err := checkpoint.TakeSnapshot()
+	if err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Snapshot written: %s (%s)", s.Path, output.FormatBytes(s.Size)))
+	return printResult(snapshotJSON{Path: s.Path})
+}
+
+func runSnapshotRestore(cmd *cobra.Command, args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		snapshots, err := checkpoint.Snapshots()
+		if err != nil {
+			return fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		if len(snapshots) == 0 {
+			return withExitCode(ExitNotFound, errors.New("no snapshots to restore from"))
+		}
+		path = snapshots[0].Path
+	}
+
+	restored, err := checkpoint.RestoreSnapshot(path)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	result := snapshotJSON{Path: path, Restored: restored, Lost: make(map[string][]string)}
+	if len(restored) == 0 {
+		printInfo("No checkpoints are missing; nothing to restore.")
+		return printResult(result)
+	}
+
+	printSuccess(fmt.Sprintf("Restored %d checkpoint manifest(s) from %s", len(restored), path))
+	for _, id := range restored {
+		cp, err := checkpoint.Get(id)
+		if err != nil {
+			continue
+		}
+		var lost []string
+		if err := checkpoint.Verify(cp); err != nil {
+			lost = strings.Split(err.Error(), "\n")
+			result.Lost[id] = lost
+		}
+		if len(lost) == 0 {
+			fmt.Printf("  %s  %s\n", id, color.GreenString("backups intact"))
+			continue
+		}
+		fmt.Printf("  %s  %s\n", id, color.YellowString("%d backup problem(s)", len(lost)))
+		for _, l := range lost {
+			fmt.Printf("      %s\n", l)
+		}
+	}
+	return printResult(result)
+}
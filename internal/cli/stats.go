@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsDedup  bool
+	statsTop    int
+	statsGrowth string
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how checkpoints use disk space",
+	Long: `Shows how much the checkpoints back up and how much disk space they use:
+by session and by tag, the commands that triggered the most checkpoints,
+the largest checkpoints and files, how well compressed checkpoints
+compress, and how much was checkpointed per day, week or month.
+
+With --dedup, every backup is hashed (or its hash read from the manifest)
+to find files backed up more than once with exactly the same content, and
+how much space storing each content once would save. Backups that are hard
+links to the same file already share their space and count once. This is
+what 'safeshell store compact' reclaims by moving files into a shared
+object store.
+
+Options:
+  --dedup    Report the space taken by exact-duplicate files
+  --top      Number of entries to list in each section (default 10)
+  --growth   Period to count growth by: day, week or month (default day)
+
+Examples:
+  safeshell stats
+  safeshell stats --growth week --top 5
+  safeshell stats --json                 # For dashboards
+  safeshell stats --dedup
+  safeshell stats --dedup --top 25
+  safeshell stats --dedup --json`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().BoolVar(&statsDedup, "dedup", false, "Report the space taken by exact-duplicate files")
+	statsCmd.Flags().IntVar(&statsTop, "top", 10, "Number of entries to list in each section")
+	statsCmd.Flags().StringVar(&statsGrowth, "growth", checkpoint.PeriodDay, "Period to count growth by: day, week or month")
+}
+
+// statsJSON is what stats prints in JSON
+type statsJSON struct {
+	Checkpoints int              `json:"checkpoints"`
+	Files       int              `json:"files"`
+	Size        int64            `json:"size"`
+	DiskUsed    int64            `json:"disk_used"`
+	Compression *compressionJSON `json:"compression,omitempty"`
+	Sessions    []usageJSON      `json:"sessions"`
+	Tags        []usageJSON      `json:"tags"`
+	Commands    []usageJSON      `json:"commands"`
+	Largest     []largestJSON    `json:"largest_checkpoints"`
+	LargestFile []fileJSON       `json:"largest_files"`
+	Growth      []usageJSON      `json:"growth"`
+	Dedup       *dedupJSON       `json:"dedup,omitempty"`
+}
+
+type compressionJSON struct {
+	Checkpoints int     `json:"checkpoints"`
+	Size        int64   `json:"size"`
+	Compressed  int64   `json:"compressed_size"`
+	Ratio       float64 `json:"ratio"`
+}
+
+// usageJSON is what a session, tag, command or period's checkpoints hold
+type usageJSON struct {
+	Key         string `json:"key"`
+	Checkpoints int    `json:"checkpoints"`
+	Files       int    `json:"files"`
+	Size        int64  `json:"size"`
+}
+
+type largestJSON struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Files   int    `json:"files"`
+	Size    int64  `json:"size"`
+}
+
+type fileJSON struct {
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	CheckpointID string `json:"checkpoint_id"`
+}
+
+type dedupJSON struct {
+	StoredSize  int64           `json:"stored_size"`
+	UniqueSize  int64           `json:"unique_size"`
+	Reclaimable int64           `json:"reclaimable"`
+	Duplicates  []duplicateJSON `json:"duplicates"`
+	Unreadable  []string        `json:"unreadable,omitempty"`
+}
+
+type duplicateJSON struct {
+	Hash   string   `json:"hash"`
+	Size   int64    `json:"size"`
+	Copies int      `json:"copies"`
+	Stored int      `json:"stored"`
+	Wasted int64    `json:"wasted"`
+	Paths  []string `json:"paths"`
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	switch statsGrowth {
+	case checkpoint.PeriodDay, checkpoint.PeriodWeek, checkpoint.PeriodMonth:
+	default:
+		return fmt.Errorf("invalid --growth: %s (use day, week or month)", statsGrowth)
+	}
+
+	checkpoints, err := checkpoint.List()
+	if err != nil {
+		return fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	result := statsJSON{Checkpoints: len(checkpoints)}
+	for _, cp := range checkpoints {
+		for _, f := range cp.Manifest.Files {
+			if !f.IsDir {
+				result.Files++
+				result.Size += f.Size
+			}
+		}
+	}
+	result.DiskUsed, _ = checkpoint.GetDiskUsage(config.GetCheckpointsDir())
+	analytics := checkpoint.Analyze(checkpoint.GetIndex().ListEntries(), statsGrowth)
+	largestFiles := checkpoint.LargestFiles(checkpoints, statsTop)
+
+	if !humanOutput() {
+		addAnalyticsJSON(&result, analytics, largestFiles)
+		if statsDedup {
+			result.Dedup = newDedupJSON(checkpoint.FindDuplicates(checkpoints))
+		}
+		return printResult(result)
+	}
+
+	fmt.Printf("Checkpoints: %d\n", result.Checkpoints)
+	fmt.Printf("Files:       %d (%s)\n", result.Files, output.FormatBytes(result.Size))
+	fmt.Printf("Disk used:   %s\n", output.FormatBytes(result.DiskUsed))
+	if analytics.Compressed > 0 {
+		fmt.Printf("Compressed:  %d checkpoint(s), %s in %s (%.1fx)\n", analytics.Compressed,
+			output.FormatBytes(analytics.CompressedBefore), output.FormatBytes(analytics.CompressedAfter), analytics.CompressionRatio())
+	}
+	if len(checkpoints) > 0 {
+		printAnalytics(analytics, largestFiles)
+	}
+
+	if statsDedup {
+		fmt.Println()
+		printInfo("Hashing backups...")
+		printDedup(checkpoint.FindDuplicates(checkpoints))
+	}
+	return nil
+}
+
+// addAnalyticsJSON adds a and the largest files to result
+func addAnalyticsJSON(result *statsJSON, a *checkpoint.Analytics, largestFiles []checkpoint.BackedUpFile) {
+	if a.Compressed > 0 {
+		result.Compression = &compressionJSON{
+			Checkpoints: a.Compressed,
+			Size:        a.CompressedBefore,
+			Compressed:  a.CompressedAfter,
+			Ratio:       a.CompressionRatio(),
+		}
+	}
+	result.Sessions = newUsageJSON(a.BySession)
+	result.Tags = newUsageJSON(a.ByTag)
+	result.Commands = newUsageJSON(a.ByCommand)
+	result.Growth = newUsageJSON(a.Growth)
+	result.Largest = []largestJSON{}
+	for _, e := range topN(a.Largest) {
+		result.Largest = append(result.Largest, largestJSON{ID: e.ID, Command: e.Command, Files: e.FileCount, Size: e.TotalSize})
+	}
+	result.LargestFile = []fileJSON{}
+	for _, f := range largestFiles {
+		result.LargestFile = append(result.LargestFile, fileJSON{Path: f.Path, Size: f.Size, CheckpointID: f.CheckpointID})
+	}
+}
+
+func newUsageJSON(usages []checkpoint.Usage) []usageJSON {
+	result := []usageJSON{}
+	for _, u := range usages {
+		result = append(result, usageJSON{Key: u.Key, Checkpoints: u.Checkpoints, Files: u.Files, Size: u.Size})
+	}
+	return result
+}
+
+// topN returns the first --top of s
+func topN[T any](s []T) []T {
+	if statsTop >= 0 && len(s) > statsTop {
+		return s[:statsTop]
+	}
+	return s
+}
+
+// printAnalytics shows where the space goes and how it grew, --top
+// entries of each
+func printAnalytics(a *checkpoint.Analytics, largestFiles []checkpoint.BackedUpFile) {
+	usageTable := func(title, column string, usages []checkpoint.Usage) {
+		if len(usages) == 0 {
+			return
+		}
+		fmt.Println()
+		color.New(color.FgWhite, color.Bold).Println(title)
+		t := output.NewTable(column, "CHECKPOINTS", "FILES", "SIZE")
+		for _, u := range topN(usages) {
+			key := u.Key
+			if key == "" {
+				key = "-"
+			}
+			t.Add(key, strconv.Itoa(u.Checkpoints), strconv.Itoa(u.Files), output.FormatBytes(u.Size))
+		}
+		printTable(t)
+	}
+	usageTable("By session", "SESSION", a.BySession)
+	usageTable("By tag", "TAG", a.ByTag)
+	usageTable("Top commands", "COMMAND", a.ByCommand)
+
+	fmt.Println()
+	color.New(color.FgWhite, color.Bold).Println("Largest checkpoints")
+	t := output.NewTable("ID", "SIZE", "FILES", "COMMAND")
+	for _, e := range topN(a.Largest) {
+		command := e.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+		t.Add(e.ID, output.FormatBytes(e.TotalSize), strconv.Itoa(e.FileCount), command)
+	}
+	printTable(t)
+
+	if len(largestFiles) > 0 {
+		fmt.Println()
+		color.New(color.FgWhite, color.Bold).Println("Largest files")
+		t = output.NewTable("SIZE", "PATH", "CHECKPOINT")
+		for _, f := range largestFiles {
+			t.Add(output.FormatBytes(f.Size), f.Path, f.CheckpointID)
+		}
+		printTable(t)
+	}
+
+	// The latest periods, with the total up to each
+	fmt.Println()
+	color.New(color.FgWhite, color.Bold).Printf("Growth by %s\n", statsGrowth)
+	t = output.NewTable(strings.ToUpper(statsGrowth), "CHECKPOINTS", "FILES", "SIZE", "TOTAL")
+	var total int64
+	var rows [][]string
+	for _, u := range a.Growth {
+		total += u.Size
+		rows = append(rows, []string{u.Key, strconv.Itoa(u.Checkpoints), strconv.Itoa(u.Files), output.FormatBytes(u.Size), output.FormatBytes(total)})
+	}
+	if statsTop >= 0 && len(rows) > statsTop {
+		rows = rows[len(rows)-statsTop:]
+	}
+	for _, row := range rows {
+		t.Add(row...)
+	}
+	printTable(t)
+}
+
+func newDedupJSON(stats *checkpoint.DedupStats) *dedupJSON {
+	result := &dedupJSON{
+		StoredSize:  stats.StoredBytes,
+		UniqueSize:  stats.UniqueBytes,
+		Reclaimable: stats.Reclaimable(),
+		Duplicates:  []duplicateJSON{},
+		Unreadable:  stats.Unreadable,
+	}
+	for _, d := range stats.Duplicates {
+		result.Duplicates = append(result.Duplicates, duplicateJSON{
+			Hash:   d.Hash,
+			Size:   d.Size,
+			Copies: d.Copies,
+			Stored: d.Stored,
+			Wasted: d.Wasted(),
+			Paths:  d.Paths,
+		})
+	}
+	return result
+}
+
+// printDedup shows what duplicates cost, the worst --top of them first
+func printDedup(stats *checkpoint.DedupStats) {
+	fmt.Println()
+	color.New(color.FgWhite, color.Bold).Println("Duplicate content")
+	copies := 0
+	for _, d := range stats.Duplicates {
+		copies += d.Copies
+	}
+	fmt.Printf("  Stored:      %s\n", output.FormatBytes(stats.StoredBytes))
+	fmt.Printf("  Unique:      %s\n", output.FormatBytes(stats.UniqueBytes))
+	fmt.Printf("  Duplicated:  %d file(s) sharing %d content(s)\n", copies, len(stats.Duplicates))
+	fmt.Printf("  Reclaimable: %s by storing each content once\n", output.FormatBytes(stats.Reclaimable()))
+
+	if len(stats.Unreadable) > 0 {
+		fmt.Println()
+		printWarning(fmt.Sprintf("%d backup(s) could not be read and were left out:", len(stats.Unreadable)))
+		for _, problem := range stats.Unreadable {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+
+	top := stats.Duplicates
+	if statsTop >= 0 && len(top) > statsTop {
+		top = top[:statsTop]
+	}
+	if len(top) > 0 {
+		fmt.Println()
+		t := output.NewTable("WASTED", "SIZE", "COPIES", "STORED", "PATH")
+		for _, d := range top {
+			row := t.Add(output.FormatBytes(d.Wasted()), output.FormatBytes(d.Size), strconv.Itoa(d.Copies), strconv.Itoa(d.Stored), d.Paths[0])
+			// The same content under other names
+			if others := d.Paths[1:]; len(others) > 3 {
+				row.Note(fmt.Sprintf("also: %s and %d more", strings.Join(others[:3], ", "), len(others)-3), color.New(color.FgHiBlack))
+			} else if len(others) > 0 {
+				row.Note("also: "+strings.Join(others, ", "), color.New(color.FgHiBlack))
+			}
+		}
+		printTable(t)
+	}
+
+	if stats.Reclaimable() > 0 {
+		fmt.Println()
+		fmt.Println("Identical files in uncompressed checkpoints are stored once after:")
+		color.Cyan("  safeshell store compact\n")
+	}
+}
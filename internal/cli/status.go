@@ -6,18 +6,39 @@ import (
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
 	"github.com/qhkm/safeshell/internal/config"
-	"github.com/qhkm/safeshell/internal/util"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show safeshell status and statistics",
-	RunE:  runStatus,
+	Long: `Shows the configuration in use and statistics about checkpoints.
+
+Examples:
+  safeshell status
+  safeshell status --json    # For scripts`,
+	Annotations: map[string]string{resultAnnotation: ""},
+	RunE:        runStatus,
+}
+
+// statusJSON is what status prints in JSON
+type statusJSON struct {
+	ConfigDir      string          `json:"config_dir"`
+	RetentionDays  int             `json:"retention_days"`
+	MaxCheckpoints int             `json:"max_checkpoints"`
+	Checkpoints    int             `json:"checkpoints"`
+	Files          int             `json:"files"`
+	Size           int64           `json:"size"`
+	RolledBack     int             `json:"rolled_back"`
+	Latest         *checkpointJSON `json:"latest,omitempty"`
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
 	cfg := config.Get()
+	if !humanOutput() {
+		return printStatusJSON(cfg)
+	}
 
 	// Header
 	color.New(color.FgCyan, color.Bold).Println("SafeShell Status")
@@ -29,46 +50,24 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Max checkpoints:  %d\n", cfg.MaxCheckpoints)
 	fmt.Println()
 
-	// Checkpoint statistics
-	checkpoints, err := checkpoint.List()
-	if err != nil {
-		return fmt.Errorf("failed to list checkpoints: %w", err)
-	}
-
-	fmt.Printf("Total checkpoints: %d\n", len(checkpoints))
-
-	if len(checkpoints) > 0 {
-		// Calculate total size
-		var totalSize int64
-		var totalFiles int
-		rolledBack := 0
-
-		for _, cp := range checkpoints {
-			size, _ := checkpoint.GetDiskUsage(cp.FilesDir)
-			totalSize += size
-
-			for _, f := range cp.Manifest.Files {
-				if !f.IsDir {
-					totalFiles++
-				}
-			}
-
-			if cp.Manifest.RolledBack {
-				rolledBack++
-			}
-		}
+	// Checkpoint statistics, from the sizes the index records rather than
+	// walking every checkpoint
+	entries := checkpoint.GetIndex().ListEntries()
+	fmt.Printf("Total checkpoints: %d\n", len(entries))
 
-		fmt.Printf("Total files backed up: %d\n", totalFiles)
-		fmt.Printf("Storage used: %s\n", util.FormatBytes(totalSize))
+	if len(entries) > 0 {
+		files, size, rolledBack := indexTotals(entries)
+		fmt.Printf("Total files backed up: %d\n", files)
+		fmt.Printf("Storage used: %s\n", output.FormatBytes(size))
 		fmt.Printf("Rolled back: %d\n", rolledBack)
 		fmt.Println()
 
 		// Latest checkpoint
-		latest := checkpoints[0]
+		latest := entries[0]
 		color.New(color.FgWhite, color.Bold).Println("Latest checkpoint:")
 		fmt.Printf("  ID:      %s\n", latest.ID)
-		fmt.Printf("  Command: %s\n", latest.Manifest.Command)
-		fmt.Printf("  Time:    %s\n", util.FormatTimeAgo(latest.CreatedAt))
+		fmt.Printf("  Command: %s\n", latest.Command)
+		fmt.Printf("  Time:    %s\n", output.FormatTimeAgo(latest.Timestamp))
 	} else {
 		fmt.Println()
 		fmt.Println("No checkpoints yet. Run 'safeshell init' to set up automatic checkpoints.")
@@ -76,3 +75,34 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func printStatusJSON(cfg *config.Config) error {
+	entries := checkpoint.GetIndex().ListEntries()
+	status := statusJSON{
+		ConfigDir:      cfg.SafeShellDir,
+		RetentionDays:  cfg.RetentionDays,
+		MaxCheckpoints: cfg.MaxCheckpoints,
+		Checkpoints:    len(entries),
+	}
+	status.Files, status.Size, status.RolledBack = indexTotals(entries)
+	if len(entries) > 0 {
+		if cp, err := checkpoint.Get(entries[0].ID); err == nil {
+			latest := newCheckpointJSON(cp, checkpointFileCount(cp))
+			status.Latest = &latest
+		}
+	}
+	return printResult(status)
+}
+
+// indexTotals returns how many files the indexed checkpoints back up, the
+// space they take and how many were rolled back
+func indexTotals(entries []*checkpoint.IndexEntry) (files int, size int64, rolledBack int) {
+	for _, e := range entries {
+		files += e.FileCount
+		size += e.DiskSize()
+		if e.RolledBack {
+			rolledBack++
+		}
+	}
+	return files, size, rolledBack
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var storeCompactDryRun bool
+
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Maintain the checkpoint store",
+}
+
+var storeCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Migrate checkpoints to the newest storage format",
+	Long: `Rewrites checkpoints stored in an older format into the newest one:
+
+  - every file's SHA-256 is recorded in the manifest
+  - uncompressed files move into a shared object store, so identical
+    content across checkpoints is stored once
+  - compressed checkpoints are re-encoded with zstd
+
+Each rewritten checkpoint is validated against the recorded hashes before
+its old layout is removed. Checkpoints that can't be migrated (e.g. missing
+backups) are reported and left untouched. Objects no checkpoint uses
+anymore are removed.
+
+New checkpoints are written in the faster uncompacted format; run this
+from time to time, e.g. after 'safeshell clean'.
+
+Options:
+  --dry-run    List the checkpoints that would be migrated
+
+Examples:
+  safeshell store compact
+  safeshell store compact --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runStoreCompact,
+}
+
+func init() {
+	rootCmd.AddCommand(storeCmd)
+	storeCmd.AddCommand(storeCompactCmd)
+	storeCompactCmd.Flags().BoolVar(&storeCompactDryRun, "dry-run", false, "List the checkpoints that would be migrated")
+}
+
+func runStoreCompact(cmd *cobra.Command, args []string) error {
+	checkpoints, err := checkpoint.List()
+	if err != nil {
+		return fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	var pending []*checkpoint.Checkpoint
+	for _, cp := range checkpoints {
+		if checkpoint.NeedsCompaction(cp) {
+			pending = append(pending, cp)
+		}
+	}
+
+	if storeCompactDryRun {
+		if len(pending) == 0 {
+			fmt.Println("All checkpoints are in the newest format.")
+			return nil
+		}
+		fmt.Printf("Would migrate %d of %d checkpoint(s):\n", len(pending), len(checkpoints))
+		for _, cp := range pending {
+			fmt.Printf("  %s  %s\n", cp.ID, cp.Manifest.Command)
+		}
+		return nil
+	}
+
+	if len(pending) > 0 {
+		printInfo(fmt.Sprintf("Migrating %d of %d checkpoint(s)...", len(pending), len(checkpoints)))
+	}
+
+	migrated := 0
+	var saved int64
+	failed := make(map[string]error)
+	for _, cp := range pending {
+		n, err := checkpoint.Compact(cp)
+		if err != nil {
+			failed[cp.ID] = err
+			fmt.Printf("  %s %s\n", color.RedString("✗"), cp.ID)
+			continue
+		}
+		migrated++
+		saved += n
+		fmt.Printf("  %s %s\n", color.GreenString("✓"), cp.ID)
+	}
+
+	pruned, freed, err := checkpoint.PruneObjects()
+	if err != nil {
+		printWarning(fmt.Sprintf("Failed to remove unused objects: %v", err))
+	}
+	saved += freed
+
+	fmt.Println()
+	if len(pending) == 0 {
+		fmt.Println("All checkpoints are in the newest format.")
+	} else {
+		printSuccess(fmt.Sprintf("Migrated %d checkpoint(s)", migrated))
+	}
+	if pruned > 0 {
+		fmt.Printf("Removed %d unused object(s)\n", pruned)
+	}
+	if saved >= 0 {
+		fmt.Printf("Space saved: %s\n", output.FormatBytes(saved))
+	} else {
+		// Files still linked to the originals had to be copied
+		fmt.Printf("Space used: %s more (backups no longer share files with the originals)\n", output.FormatBytes(-saved))
+	}
+
+	if len(failed) > 0 {
+		fmt.Println()
+		printWarning(fmt.Sprintf("%d checkpoint(s) could not be migrated and were left as they were:", len(failed)))
+		for _, cp := range pending {
+			if err, ok := failed[cp.ID]; ok {
+				fmt.Printf("  %s: %v\n", cp.ID, err)
+			}
+		}
+		return fmt.Errorf("%d checkpoint(s) could not be migrated", len(failed))
+	}
+	return nil
+}
@@ -12,24 +12,29 @@ import (
 var (
 	tagRemove bool
 	tagNote   string
+	tagName   string
 )
 
 var tagCmd = &cobra.Command{
 	Use:   "tag <checkpoint-id> [tag...]",
-	Short: "Add tags or notes to a checkpoint",
+	Short: "Add tags, notes or a name to a checkpoint",
 	Long: `Add tags or notes to a checkpoint for better organization.
 
 Tags help you categorize and find checkpoints later.
 Notes provide additional context about why a checkpoint was created.
+A name is unique, and can be used instead of the ID in every command
+taking a checkpoint ID; --name "" removes it.
 
 Examples:
   safeshell tag 2024-12-12T143022-a1b2c3 "before-refactor"
   safeshell tag 2024-12-12T143022-a1b2c3 important backup
   safeshell tag --last "pre-deploy"
   safeshell tag --last --note "Before major database migration"
+  safeshell tag --last --name pre-migration
   safeshell tag 2024-12-12T143022-a1b2c3 --remove old-tag`,
-	Args: cobra.MinimumNArgs(1),
-	RunE: runTag,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeTagArgs,
+	RunE:              runTag,
 }
 
 var (
@@ -40,6 +45,7 @@ func init() {
 	rootCmd.AddCommand(tagCmd)
 	tagCmd.Flags().BoolVarP(&tagRemove, "remove", "r", false, "Remove the specified tag(s)")
 	tagCmd.Flags().StringVarP(&tagNote, "note", "n", "", "Set a note for the checkpoint")
+	tagCmd.Flags().StringVar(&tagName, "name", "", "Name the checkpoint, to refer to it by instead of its ID")
 	tagCmd.Flags().BoolVarP(&tagLast, "last", "l", false, "Apply to the most recent checkpoint")
 }
 
@@ -52,7 +58,7 @@ func runTag(cmd *cobra.Command, args []string) error {
 		tags = args
 		cp, err := checkpoint.GetLatest()
 		if err != nil {
-			return fmt.Errorf("no checkpoints found")
+			return errNoCheckpoints()
 		}
 		cpID = cp.ID
 	} else {
@@ -64,10 +70,11 @@ func runTag(cmd *cobra.Command, args []string) error {
 	}
 
 	// Verify checkpoint exists
-	cp, err := checkpoint.Get(cpID)
+	cp, err := getCheckpoint(cpID)
 	if err != nil {
-		return fmt.Errorf("checkpoint not found: %s", cpID)
+		return err
 	}
+	cpID = cp.ID
 
 	// Set note if provided
 	if tagNote != "" {
@@ -77,6 +84,19 @@ func runTag(cmd *cobra.Command, args []string) error {
 		color.Green("✓ Note set for checkpoint %s\n", cpID)
 	}
 
+	// Set name if provided
+	naming := cmd.Flags().Changed("name")
+	if naming {
+		if err := checkpoint.SetName(cpID, tagName); err != nil {
+			return fmt.Errorf("failed to set name: %w", err)
+		}
+		if tagName == "" {
+			color.Yellow("- Removed name of checkpoint %s\n", cpID)
+		} else {
+			color.Green("✓ Checkpoint %s named '%s'\n", cpID, tagName)
+		}
+	}
+
 	// Process tags
 	if len(tags) > 0 {
 		for _, tag := range tags {
@@ -100,12 +120,16 @@ func runTag(cmd *cobra.Command, args []string) error {
 	}
 
 	// Show current state if no tags or note were provided
-	if len(tags) == 0 && tagNote == "" {
+	if len(tags) == 0 && tagNote == "" && !naming {
 		fmt.Println()
 		color.New(color.FgCyan, color.Bold).Printf("Checkpoint: %s\n", cp.ID)
 		fmt.Printf("Command:    %s\n", cp.Manifest.Command)
 		fmt.Printf("Time:       %s\n", cp.Manifest.Timestamp.Format("2006-01-02 15:04:05"))
 
+		if cp.Manifest.Name != "" {
+			fmt.Printf("Name:       %s\n", cp.Manifest.Name)
+		}
+
 		if cp.Manifest.Note != "" {
 			fmt.Printf("Note:       %s\n", cp.Manifest.Note)
 		}
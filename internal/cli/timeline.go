@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/oplog"
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// runTimeline shows what happened to protected files, for history without
+// a file
+func runTimeline() error {
+	var f checkpoint.TimelineFilter
+	if historyLast != "" {
+		window, err := parseDuration(historyLast)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %s", historyLast)
+		}
+		f.Since = time.Now().Add(-window)
+	}
+	if historyAfter != "" {
+		after, err := time.ParseInLocation("2006-01-02", historyAfter, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --after date format (use YYYY-MM-DD): %w", err)
+		}
+		if after.After(f.Since) {
+			f.Since = after
+		}
+	}
+	if historyBefore != "" {
+		before, err := time.ParseInLocation("2006-01-02", historyBefore, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --before date format (use YYYY-MM-DD): %w", err)
+		}
+		f.Until = before
+	}
+	f.Session = historySession
+	if f.Session == "current" {
+		f.Session = checkpoint.GetSessionID()
+	}
+	if historyPath != "" {
+		path, err := filepath.Abs(historyPath)
+		if err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+		f.Path = path
+	}
+
+	events, err := checkpoint.Timeline(f)
+	if err != nil {
+		return fmt.Errorf("failed to read operations log: %w", err)
+	}
+	total := len(events)
+	if historyLimit > 0 && total > historyLimit {
+		events = events[total-historyLimit:]
+	}
+
+	t := output.NewTable("TIME", "EVENT", "COMMAND", "FILES", "CHECKPOINT", "SESSION")
+	for _, e := range events {
+		command := e.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+		files := "-"
+		if e.Files > 0 {
+			files = fmt.Sprintf("%d (%s)", e.Files, output.FormatBytes(e.Bytes))
+		}
+		row := t.Add(
+			e.Time.Local().Format("2006-01-02 15:04:05"),
+			timelineEvent(e),
+			orDash(command),
+			files,
+			orDash(e.CheckpointID),
+			orDash(e.Session),
+		)
+		row.Data = e
+
+		switch {
+		case e.Failed():
+			row.Color = color.New(color.FgRed)
+			if e.Error != "" {
+				reason, _, _ := strings.Cut(e.Error, "\n")
+				row.Note(reason, color.New(color.FgRed))
+			}
+		case e.Op == oplog.OpRollback:
+			row.Color = color.New(color.FgGreen)
+		case e.Op == oplog.OpDelete:
+			row.Color = color.New(color.FgHiBlack)
+		}
+	}
+
+	if !humanOutput() {
+		return printTable(t)
+	}
+
+	if total == 0 {
+		fmt.Println("No events found.")
+		return nil
+	}
+
+	fmt.Printf("Found %d event(s)", total)
+	if len(events) < total {
+		fmt.Printf(" (showing the latest %d)", len(events))
+	}
+	fmt.Println()
+	fmt.Println()
+	if err := printTable(t); err != nil {
+		return err
+	}
+
+	if len(events) < total {
+		fmt.Println()
+		fmt.Printf("Use 'safeshell history -n 0' to see all %d.\n", total)
+	}
+	return nil
+}
+
+// timelineEvent names what happened in e: a command run, with how it
+// exited if it failed, or what was done to a checkpoint
+func timelineEvent(e oplog.Entry) string {
+	switch e.Op {
+	case oplog.OpWrap:
+		switch {
+		case e.ExitCode != nil && *e.ExitCode != 0:
+			return "ran (exit " + strconv.Itoa(*e.ExitCode) + ")"
+		case e.Error != "":
+			return "not run"
+		}
+		return "ran"
+	case oplog.OpCheckpoint:
+		return "checkpointed"
+	case oplog.OpRollback:
+		return "rolled back"
+	case oplog.OpDelete:
+		return "deleted"
+	}
+	return e.Op
+}
+
+// orDash returns s, or - if it is empty, for a table cell
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,513 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/output"
+	"github.com/qhkm/safeshell/internal/util"
+	"github.com/spf13/cobra"
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse checkpoints in a full-screen terminal UI",
+	Long: `Opens a full-screen browser over your checkpoints.
+
+Checkpoints:
+  ↑/↓ j/k    Move
+  enter      Open the checkpoint's files
+  /          Search by ID, command, tag or note
+  t          Add a tag (prefix it with - to remove it)
+  c          Compress, or decompress a compressed checkpoint
+  x          Delete
+  r          Roll back
+  q          Quit
+
+Files (deleted, modified or unchanged since the checkpoint):
+  space      Select for restoring; changed files start selected
+  d          Show the diff against the backup
+  /          Search
+  enter      Restore the selected files
+  esc        Back to the checkpoints
+
+Rollbacks run once the UI closes, like 'safeshell rollback', asking about
+files changed since the checkpoint unless --yes is set.
+
+Examples:
+  safeshell ui`,
+	Args: cobra.NoArgs,
+	RunE: runUI,
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
+
+// uiAction is a rollback picked in the UI, run after it closes
+type uiAction struct {
+	cp    *checkpoint.Checkpoint
+	files []string // all files if empty
+}
+
+// uiModel browses checkpoints; with one open, its files are shown by a
+// selectModel
+type uiModel struct {
+	checkpoints []*checkpoint.Checkpoint
+	visible     []int
+	cursor      int
+	offset      int
+
+	search    string
+	searching bool
+
+	files   *selectModel // the open checkpoint's files
+	opening bool         // its files are being compared
+
+	// A confirmation or tag being typed takes the keys
+	confirm string // the question, answered with y
+	onYes   func() tea.Cmd
+	tagging bool
+	tag     string
+
+	status string // result of the last operation
+	busy   bool
+
+	width, height int
+	action        *uiAction
+}
+
+// Results of work done off the UI loop
+type (
+	uiFilesMsg struct{ files *selectModel }
+	uiDoneMsg  struct {
+		status string
+		err    error
+	}
+)
+
+func runUI(cmd *cobra.Command, args []string) error {
+	if !util.CanPrompt() {
+		return errors.New("safeshell ui needs a terminal")
+	}
+	m := &uiModel{}
+	if err := m.reload(); err != nil {
+		return err
+	}
+	if len(m.checkpoints) == 0 {
+		fmt.Println("No checkpoints found.")
+		return nil
+	}
+
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		return err
+	}
+	if m.action == nil {
+		return nil
+	}
+
+	cp := m.action.cp
+	printCheckpointHeader(cp)
+	if cp.Manifest.RolledBack {
+		return errAlreadyRolledBack()
+	}
+	return restoreCheckpoint(cp, m.action.files)
+}
+
+// reload lists the checkpoints again, keeping the cursor on the same one
+func (m *uiModel) reload() error {
+	var current string
+	if cp := m.current(); cp != nil {
+		current = cp.ID
+	}
+	checkpoints, err := checkpoint.List()
+	if err != nil {
+		return err
+	}
+	m.checkpoints = checkpoints
+	m.filter()
+	for i, idx := range m.visible {
+		if m.checkpoints[idx].ID == current {
+			m.cursor = i
+		}
+	}
+	m.scroll()
+	return nil
+}
+
+func (m *uiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *uiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		if m.files != nil {
+			m.files.Update(tea.WindowSizeMsg{Width: msg.Width, Height: msg.Height - 1})
+		}
+		m.scroll()
+		return m, nil
+
+	case uiFilesMsg:
+		m.opening = false
+		m.files = msg.files
+		m.files.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height - 1})
+		return m, nil
+
+	case uiDoneMsg:
+		m.busy = false
+		m.status = msg.status
+		if msg.err != nil {
+			m.status = color.RedString("%v", msg.err)
+		}
+		if err := m.reload(); err != nil {
+			m.status = color.RedString("%v", err)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		switch {
+		case m.confirm != "":
+			return m, m.confirmKey(msg)
+		case m.tagging:
+			return m, m.tagKey(msg)
+		case m.files != nil:
+			return m, m.filesKey(msg)
+		case m.searching:
+			m.searchKey(msg)
+			return m, nil
+		}
+		return m, m.listKey(msg)
+	}
+	return m, nil
+}
+
+func (m *uiModel) listKey(msg tea.KeyMsg) tea.Cmd {
+	if m.busy || m.opening {
+		if msg.String() == "q" {
+			return tea.Quit
+		}
+		return nil
+	}
+	m.status = ""
+	cp := m.current()
+
+	switch msg.String() {
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-m.rows())
+	case "pgdown":
+		m.move(m.rows())
+	case "home", "g":
+		m.move(-len(m.visible))
+	case "end", "G":
+		m.move(len(m.visible))
+	case "/":
+		m.searching = true
+	case "esc":
+		if m.search != "" {
+			m.search = ""
+			m.filter()
+		}
+	case "q":
+		return tea.Quit
+	}
+	if cp == nil {
+		return nil
+	}
+
+	switch msg.String() {
+	case "enter", "right", "l":
+		m.opening, m.status = true, ""
+		return func() tea.Msg {
+			files := newSelectModel(cp)
+			files.title = i18n.T("ui.files_title", cp.ID, cp.Manifest.Command)
+			files.help = i18n.T("ui.files_help")
+			return uiFilesMsg{files}
+		}
+	case "t":
+		m.tagging, m.tag = true, ""
+	case "c":
+		m.busy = true
+		if cp.Manifest.Compressed {
+			m.status = i18n.T("ui.decompressing", cp.ID)
+			return func() tea.Msg {
+				if err := checkpoint.Decompress(cp.ID); err != nil {
+					return uiDoneMsg{err: err}
+				}
+				return uiDoneMsg{status: i18n.T("ui.decompressed", cp.ID)}
+			}
+		}
+		m.status = i18n.T("ui.compressing", cp.ID)
+		return func() tea.Msg {
+			original, compressed, err := checkpoint.Compress(cp.ID)
+			if err != nil {
+				return uiDoneMsg{err: err}
+			}
+			return uiDoneMsg{status: i18n.T("ui.compressed", cp.ID, output.FormatBytes(original), output.FormatBytes(compressed))}
+		}
+	case "x", "delete":
+		m.ask(i18n.T("ui.confirm_delete", cp.ID), func() tea.Cmd {
+			err := checkpoint.Delete(cp.ID)
+			return done(i18n.T("ui.deleted", cp.ID), err)
+		})
+	case "r":
+		if cp.Manifest.RolledBack {
+			m.status = color.YellowString(i18n.T("rollback.already_rolled_back"))
+			return nil
+		}
+		m.ask(i18n.T("ui.confirm_rollback", cp.ID, checkpointFileCount(cp)), func() tea.Cmd {
+			m.action = &uiAction{cp: cp}
+			return tea.Quit
+		})
+	}
+	return nil
+}
+
+// filesKey handles the keys of the files view the selectModel doesn't: going
+// back, and restoring the selected files
+func (m *uiModel) filesKey(msg tea.KeyMsg) tea.Cmd {
+	f := m.files
+	if !f.searching {
+		switch msg.String() {
+		case "q":
+			m.files = nil
+			return nil
+		case "esc", "left", "h":
+			if f.preview == nil && f.search == "" {
+				m.files = nil
+				return nil
+			}
+		case "enter":
+			paths := f.selectedPaths()
+			if len(paths) == 0 {
+				m.status = color.YellowString(i18n.T("rollback.none_selected"))
+				m.files = nil
+				return nil
+			}
+			if f.cp.Manifest.RolledBack {
+				m.status = color.YellowString(i18n.T("rollback.already_rolled_back"))
+				m.files = nil
+				return nil
+			}
+			m.ask(i18n.T("ui.confirm_restore", len(paths), f.cp.ID), func() tea.Cmd {
+				m.action = &uiAction{cp: f.cp, files: paths}
+				return tea.Quit
+			})
+			return nil
+		}
+	}
+	f.Update(msg)
+	return nil
+}
+
+func (m *uiModel) ask(question string, onYes func() tea.Cmd) {
+	m.confirm, m.onYes = question, onYes
+}
+
+func (m *uiModel) confirmKey(msg tea.KeyMsg) tea.Cmd {
+	onYes := m.onYes
+	m.confirm, m.onYes = "", nil
+	if msg.String() == "y" || msg.String() == "Y" {
+		return onYes()
+	}
+	return nil
+}
+
+// tagKey edits the tag being typed; a leading - removes the tag instead
+func (m *uiModel) tagKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.tagging = false
+	case tea.KeyBackspace:
+		if r := []rune(m.tag); len(r) > 0 {
+			m.tag = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes:
+		m.tag += string(msg.Runes)
+	case tea.KeyEnter:
+		m.tagging = false
+		cp := m.current()
+		tag := strings.TrimSpace(m.tag)
+		if cp == nil || tag == "" || tag == "-" {
+			return nil
+		}
+		if name, remove := strings.CutPrefix(tag, "-"); remove {
+			return done(i18n.T("ui.untagged", cp.ID, name), checkpoint.RemoveTag(cp.ID, name))
+		}
+		return done(i18n.T("ui.tagged", cp.ID, tag), checkpoint.AddTag(cp.ID, tag))
+	}
+	return nil
+}
+
+// done reports the outcome of an operation and has the list reloaded
+func done(status string, err error) tea.Cmd {
+	return func() tea.Msg { return uiDoneMsg{status: status, err: err} }
+}
+
+func (m *uiModel) searchKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.searching = false
+		return
+	case tea.KeyEsc:
+		m.searching = false
+		m.search = ""
+	case tea.KeyBackspace:
+		if r := []rune(m.search); len(r) > 0 {
+			m.search = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.search += string(msg.Runes)
+	default:
+		return
+	}
+	m.filter()
+}
+
+// filter shows the checkpoints whose ID, command, name, tags or note
+// contain the search, ignoring case
+func (m *uiModel) filter() {
+	search := strings.ToLower(m.search)
+	m.visible = m.visible[:0]
+	for i, cp := range m.checkpoints {
+		text := strings.ToLower(strings.Join(append([]string{cp.ID, cp.Manifest.Command, cp.Manifest.Name, cp.Manifest.Note}, cp.Manifest.Tags...), " "))
+		if strings.Contains(text, search) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor = min(m.cursor, max(0, len(m.visible)-1))
+	m.offset = 0
+	m.scroll()
+}
+
+func (m *uiModel) current() *checkpoint.Checkpoint {
+	if len(m.visible) == 0 || m.cursor >= len(m.visible) {
+		return nil
+	}
+	return m.checkpoints[m.visible[m.cursor]]
+}
+
+func (m *uiModel) move(by int) {
+	m.cursor = max(0, min(m.cursor+by, len(m.visible)-1))
+	m.scroll()
+}
+
+func (m *uiModel) scroll() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if rows := m.rows(); m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+func (m *uiModel) rows() int {
+	if m.height == 0 {
+		return 20
+	}
+	return max(1, m.height-6)
+}
+
+func (m *uiModel) View() string {
+	if m.files != nil {
+		return m.files.View() + "\n" + m.footer("")
+	}
+
+	var b strings.Builder
+	color.New(color.Bold).Fprintln(&b, i18n.T("ui.title", len(m.checkpoints)))
+	if m.searching || m.search != "" {
+		cursor := ""
+		if m.searching {
+			cursor = "▏"
+		}
+		fmt.Fprintln(&b, i18n.T("rollback.select_search", m.search+cursor))
+	} else {
+		fmt.Fprintln(&b)
+	}
+	fmt.Fprintln(&b)
+
+	end := min(len(m.visible), m.offset+m.rows())
+	shown := end - m.offset
+	if len(m.visible) == 0 {
+		color.New(color.FgHiBlack).Fprintln(&b, "  "+i18n.T("ui.no_match"))
+		shown = 1
+	}
+	for row := m.offset; row < end; row++ {
+		fmt.Fprintln(&b, m.checkpointRow(m.checkpoints[m.visible[row]], row == m.cursor))
+	}
+	for ; shown < m.rows(); shown++ {
+		fmt.Fprintln(&b)
+	}
+	fmt.Fprintln(&b)
+	b.WriteString(m.footer(i18n.T("ui.help")))
+	return b.String()
+}
+
+func (m *uiModel) checkpointRow(cp *checkpoint.Checkpoint, selected bool) string {
+	pointer := "  "
+	if selected {
+		pointer = color.CyanString("> ")
+	}
+	var flags []string
+	if cp.Manifest.RolledBack {
+		flags = append(flags, "(rolled back)")
+	}
+	if cp.Manifest.Compressed {
+		flags = append(flags, "[compressed]")
+	}
+	if cp.Manifest.Name != "" {
+		flags = append(flags, color.MagentaString("name: "+cp.Manifest.Name))
+	}
+	if len(cp.Manifest.Tags) > 0 {
+		flags = append(flags, color.MagentaString("tags: "+strings.Join(cp.Manifest.Tags, ", ")))
+	}
+	command := cp.Manifest.Command
+	if len(command) > 40 {
+		command = command[:37] + "..."
+	}
+	line := fmt.Sprintf("%-28s %-10s %5d  %-40s %s", cp.ID, output.FormatTimeAgo(cp.CreatedAt), checkpointFileCount(cp), command, strings.Join(flags, " "))
+	switch {
+	case cp.Manifest.RolledBack:
+		line = color.New(color.FgHiBlack).Sprint(line)
+	case cp.Manifest.Compressed:
+		line = color.CyanString("%s", line)
+	}
+	return pointer + line
+}
+
+// footer is the prompt being answered, else the last status, else help
+func (m *uiModel) footer(help string) string {
+	switch {
+	case m.confirm != "":
+		return color.YellowString("%s (y/n)", m.confirm)
+	case m.tagging:
+		return i18n.T("ui.tag_prompt", m.tag+"▏")
+	case m.opening:
+		return i18n.T("ui.comparing")
+	case m.status != "":
+		return m.status
+	}
+	return color.New(color.FgHiBlack).Sprint(help)
+}
+
+func checkpointFileCount(cp *checkpoint.Checkpoint) int {
+	n := 0
+	for _, f := range cp.Manifest.Files {
+		if !f.IsDir {
+			n++
+		}
+	}
+	return n
+}
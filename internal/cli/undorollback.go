@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/rollback"
+	"github.com/spf13/cobra"
+)
+
+var undoRollbackCmd = &cobra.Command{
+	Use:   "undo-rollback [checkpoint-id]",
+	Short: "Undo the most recent rollback",
+	Long: `Puts files back the way they were before a rollback.
+
+Every rollback first checkpoints the files it is about to overwrite, tagged
+` + rollback.SafetyTag + `. Undoing rolls that checkpoint back: overwritten files get
+their content back, files the rollback recreated are removed again, and the
+rolled back checkpoint can be rolled back again.
+
+Without an ID, the most recent rollback that hasn't been undone is undone.
+An undo is a rollback too, so running undo-rollback again redoes it.
+
+Examples:
+  safeshell undo-rollback
+  safeshell undo-rollback 2024-12-12T143522-d4e5f6   # A checkpoint tagged ` + rollback.SafetyTag,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeCheckpointID,
+	RunE:              runUndoRollback,
+}
+
+func init() {
+	rootCmd.AddCommand(undoRollbackCmd)
+}
+
+func runUndoRollback(cmd *cobra.Command, args []string) error {
+	defer showProgress()()
+
+	var cp *checkpoint.Checkpoint
+	var err error
+
+	if len(args) > 0 {
+		if cp, err = getCheckpoint(args[0]); err != nil {
+			return err
+		}
+		if !slices.Contains(cp.Manifest.Tags, rollback.SafetyTag) {
+			return fmt.Errorf("checkpoint %s was not taken before a rollback", cp.ID)
+		}
+		if cp.Manifest.RolledBack {
+			return errAlreadyRolledBack()
+		}
+	} else if cp, err = rollback.LatestSafety(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	color.New(color.FgCyan, color.Bold).Printf("Undoing rollback of %s\n", cp.Manifest.UndoOf)
+	fmt.Println(i18n.T("checkpoint.time", cp.Manifest.Timestamp.Format("2006-01-02 15:04:05")))
+	fmt.Println()
+
+	ctx, stop := interruptible()
+	defer stop()
+	if err := rollback.RollbackContext(ctx, cp, nil); err != nil {
+		return err
+	}
+	printSuccess("Rollback undone")
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -32,7 +33,8 @@ Your checkpoints and settings are preserved.
 Examples:
   safeshell upgrade          # Upgrade to latest version
   safeshell upgrade --check  # Just check for updates`,
-	RunE: runUpgrade,
+	RunE:        runUpgrade,
+	Annotations: map[string]string{featureAnnotation: config.FeatureUpgrade},
 }
 
 var upgradeCheckOnly bool
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchDebounce    time.Duration
+	watchMinInterval time.Duration
+	watchExclude     []string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [path...]",
+	Short: "Checkpoint directories automatically when files change",
+	Long: `Watches directories and creates checkpoints when files in them change.
+This protects against destructive edits by editors, build tools or agents
+that never go through the shell wrapper.
+
+On start, each directory gets a baseline checkpoint. After that, changed
+files are checkpointed once the directory has been quiet for the debounce
+period; files whose content did not actually change are skipped.
+
+Without arguments, the paths and per-path policies under 'watch' in
+~/.safeshell/config.yaml are used:
+
+  watch:
+    - path: ~/project
+      debounce: 5s
+      min_interval: 1m
+      exclude: ["*.log", "dist/*"]
+
+Runs until Ctrl+C.
+
+Options:
+  --debounce        Quiet period before checkpointing (default 2s)
+  --min-interval    Minimum time between checkpoints of a path
+  --exclude         Glob pattern to ignore (repeatable)
+
+Examples:
+  safeshell watch .
+  safeshell watch ~/project --debounce 10s --exclude "*.log"
+  safeshell watch                    # Use paths from config`,
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", watch.DefaultDebounce, "Quiet period before checkpointing")
+	watchCmd.Flags().DurationVar(&watchMinInterval, "min-interval", 0, "Minimum time between checkpoints of a path")
+	watchCmd.Flags().StringArrayVar(&watchExclude, "exclude", nil, "Glob pattern to ignore (repeatable)")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	var policies []watch.Policy
+	if len(args) > 0 {
+		for _, path := range args {
+			policies = append(policies, watch.Policy{
+				Path:        path,
+				Debounce:    watchDebounce,
+				MinInterval: watchMinInterval,
+				Exclude:     watchExclude,
+			})
+		}
+	} else {
+		policies = watch.PoliciesFromConfig(config.Get().Watch)
+		if len(policies) == 0 {
+			return fmt.Errorf("no paths given and no 'watch' paths in config")
+		}
+	}
+
+	w, err := watch.New(policies)
+	if err != nil {
+		return err
+	}
+	w.OnCheckpoint = func(cp *checkpoint.Checkpoint, files []string) {
+		fmt.Printf("%s %s %s\n", time.Now().Format("15:04:05"), color.GreenString("✓ %s", cp.ID), cp.Manifest.Command)
+	}
+	w.OnError = func(err error) {
+		fmt.Fprintln(os.Stderr, color.YellowString("! %v", err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, p := range policies {
+		printInfo(fmt.Sprintf("Watching %s", p.Path))
+	}
+	fmt.Println("Press Ctrl+C to stop.")
+
+	return w.Run(ctx)
+}
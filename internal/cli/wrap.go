@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"errors"
+	"os/exec"
+
 	"github.com/qhkm/safeshell/internal/wrapper"
 	"github.com/spf13/cobra"
 )
 
 var wrapCmd = &cobra.Command{
-	Use:   "wrap [--dry-run] <command> [args...]",
+	Use:   "wrap [--dry-run] [--no-checkpoint] [--yes] <command> [args...]",
 	Short: "Execute a command with automatic checkpoint",
 	Long: `Wraps a command with automatic checkpoint creation.
 This is typically called via shell aliases set up by 'safeshell init'.
@@ -18,25 +21,68 @@ Before executing the command, safeshell will:
 
 If something goes wrong, use 'safeshell rollback' to restore.
 
+When 'safeshell daemon' is running, the checkpoint is created by the daemon,
+which already has config and the checkpoint index loaded.
+
+Targets under protected_paths are always backed up in full. An entry with
+action: confirm asks before running the command, and refuses to without a
+terminal; action: deny refuses to run it. The first of the rules the
+command matches, on its flags, targets or how much they hold, may also
+allow it, ask first, deny it or require a checkpoint. Refused commands exit
+with code 6.
+
+With confirm_high_risk_mb set, high-risk commands (rm, rsync --delete,
+find -delete, ...) on targets holding at least that many MB tell how many
+files they act on and the checkpoint holding them, and ask before running;
+answering no deletes the checkpoint. Without a terminal the answer is no:
+scripts and agents answer yes with --yes or SAFESHELL_YES=1.
+
+With rm_strategy: trash, rm doesn't run: its targets are moved into the
+checkpoint, which rollback renames them back from. rm runs as usual when it
+would ask or refuse, is given options other than -r and -f, or the targets
+are on another filesystem than the checkpoints.
+
+With --no-checkpoint, or SAFESHELL_DISABLE=1 in the environment, nothing is
+backed up: protected_paths and rules still apply, but not a checkpoint they
+require. Use it for scripted bulk deletions where the time or storage isn't
+worth it. An organization policy listing no_checkpoint in disabled_features
+ignores both.
+
 Options:
-  --dry-run    Show what would be backed up without creating checkpoint or executing command
+  --dry-run        Show what would be backed up without creating checkpoint or executing command
+  --no-checkpoint  Run the command without creating a checkpoint
+  --yes, -y        Answer yes to the questions it would ask
 
 Examples:
-  safeshell wrap rm -rf ./build           # Normal execution with checkpoint
-  safeshell wrap --dry-run rm -rf ./build # Preview what would be backed up`,
+  safeshell wrap rm -rf ./build                 # Normal execution with checkpoint
+  safeshell wrap --dry-run rm -rf ./build       # Preview what would be backed up
+  safeshell wrap --no-checkpoint rm -rf ./cache # No checkpoint for this one
+  SAFESHELL_DISABLE=1 ./cleanup.sh              # No checkpoints for anything the script runs
+  safeshell wrap --yes rm -rf ./dist            # Don't ask, even if it is large`,
 	Args:               cobra.MinimumNArgs(1),
 	DisableFlagParsing: true, // Don't parse flags, pass them through to the wrapped command
 	RunE:               runWrap,
+	// Config is loaded lazily: with 'safeshell daemon' running, wrapped
+	// commands ask it for what they need instead
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
 }
 
 func runWrap(cmd *cobra.Command, args []string) error {
-	// Check for --dry-run flag (must handle manually since DisableFlagParsing is true)
-	dryRun := false
+	// Check for our own flags (must handle manually since DisableFlagParsing is true)
+	dryRun, noCheckpoint := false, wrapper.Disabled()
 	actualArgs := args
 
-	if len(args) > 0 && args[0] == "--dry-run" {
-		dryRun = true
-		actualArgs = args[1:]
+	for len(actualArgs) > 0 {
+		if actualArgs[0] == "--dry-run" {
+			dryRun = true
+		} else if actualArgs[0] == "--no-checkpoint" {
+			noCheckpoint = true
+		} else if actualArgs[0] == "--yes" || actualArgs[0] == "-y" {
+			wrapper.AssumeYes = true
+		} else {
+			break
+		}
+		actualArgs = actualArgs[1:]
 	}
 
 	if len(actualArgs) == 0 {
@@ -50,8 +96,30 @@ func runWrap(cmd *cobra.Command, args []string) error {
 	}
 
 	if dryRun {
+		if err := loadConfig(cmd, args); err != nil {
+			return err
+		}
 		return wrapper.WrapDryRun(cmdName, cmdArgs)
 	}
 
-	return wrapper.Wrap(cmdName, cmdArgs)
+	var err error
+	if noCheckpoint && wrapper.BypassAllowed() {
+		err = wrapper.Run(cmdName, cmdArgs)
+	} else {
+		stopProgress := showProgress()
+		err = wrapper.Wrap(cmdName, cmdArgs)
+		stopProgress()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// The command has said what went wrong; exit with its status, or
+		// die of the same signal
+		wrapper.DieLikeCommand(err)
+		cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	}
+	if errors.Is(err, wrapper.ErrRefused) {
+		// Nothing wrong with how it was run
+		cmd.SilenceUsage = true
+	}
+	return err
 }
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,6 +12,7 @@ import (
 type Config struct {
 	SafeShellDir       string   `mapstructure:"safeshell_dir"`
 	RetentionDays      int      `mapstructure:"retention_days"`
+	KeepPerSession     int      `mapstructure:"keep_per_session"`
 	MaxCheckpoints     int      `mapstructure:"max_checkpoints"`
 	MaxStorageMB       int      `mapstructure:"max_storage_mb"`
 	MaxFileSizeMB      int      `mapstructure:"max_file_size_mb"`
@@ -17,6 +20,181 @@ type Config struct {
 	ExcludePaths       []string `mapstructure:"exclude_paths"`
 	SensitivePatterns  []string `mapstructure:"sensitive_patterns"`
 	WrappedCommands    []string `mapstructure:"wrapped_commands"`
+
+	// ProtectedPaths are always backed up, and may make wrapped commands
+	// touching them ask first or refuse to run
+	ProtectedPaths []ProtectedPath `mapstructure:"protected_paths"`
+
+	// DisabledExclusions are built-in exclusions (vendor, build, .git...)
+	// to back up after all
+	DisabledExclusions []string `mapstructure:"disabled_exclusions"`
+
+	// IncludePaths, if set, limits checkpoints to the files matching one
+	// of these patterns, for very large directories. --include overrides it.
+	IncludePaths []string `mapstructure:"include_paths"`
+
+	// RespectGitignore leaves out files git ignores, following the
+	// .gitignore files of the repository: mostly artifacts that can be
+	// regenerated
+	RespectGitignore bool `mapstructure:"respect_gitignore"`
+
+	// GitAware leaves out tracked files with no changes since the last
+	// commit, recording the commit so rollback can get them back from git
+	GitAware bool `mapstructure:"git_aware"`
+
+	// MaxCheckpointsAction is what creating a checkpoint does to the oldest
+	// beyond MaxCheckpoints: "delete" or "compress" them
+	MaxCheckpointsAction string `mapstructure:"max_checkpoints_action"`
+
+	// MaxStorageAction is what creating a checkpoint does when the store
+	// is over MaxStorageMB: "compress" or "delete" the oldest checkpoints,
+	// "refuse" to, or just "warn"
+	MaxStorageAction string `mapstructure:"max_storage_action"`
+
+	CompressionAlgorithm string `mapstructure:"compression_algorithm"`
+	CompressionLevel     int    `mapstructure:"compression_level"`
+
+	// BackupWorkers is how many files are backed up at once when a
+	// directory is checkpointed: 0 is one per CPU, up to 8
+	BackupWorkers int `mapstructure:"backup_workers"`
+
+	// PreserveMacOSMetadata keeps Finder flags, tags, resource forks and
+	// quarantine state (com.apple.* extended attributes) when files are
+	// copied or archived
+	PreserveMacOSMetadata bool `mapstructure:"preserve_macos_metadata"`
+
+	// PreserveTimes gives restored files the modification and access times
+	// they had when backed up, instead of the time of the restore
+	PreserveTimes bool `mapstructure:"preserve_times"`
+
+	// CloudPlaceholders is what happens to files whose content is only in
+	// the cloud (iCloud, OneDrive): "skip" them, or "hydrate" them by
+	// downloading and copying
+	CloudPlaceholders string `mapstructure:"cloud_placeholders"`
+
+	// FSSnapshots makes checkpoints of directories take a filesystem
+	// snapshot instead of copying files: "off", "auto", or one of "btrfs",
+	// "zfs" and "apfs". Files are copied when no snapshot can be taken.
+	FSSnapshots string `mapstructure:"fs_snapshots"`
+
+	// ScopeToProject narrows a checkpoint of a directory above the project
+	// a command runs in down to the project, so a mistyped "rm -rf .." backs
+	// up the project instead of the whole home directory. The project is the
+	// nearest directory up from the working directory holding one of
+	// ProjectMarkers.
+	ScopeToProject bool     `mapstructure:"scope_to_project"`
+	ProjectMarkers []string `mapstructure:"project_markers"`
+
+	// WrapperMessages is where wrapped commands print safeshell's own
+	// messages: "stderr", "log" (~/.safeshell/wrapper.log) or "off".
+	// SAFESHELL_MESSAGES overrides it.
+	WrapperMessages string `mapstructure:"wrapper_messages"`
+
+	// LogLevel is how much safeshell tells about what it does: "quiet"
+	// (warnings only), "normal" or "verbose" (also each file backed up or
+	// restored). --quiet, --verbose and SAFESHELL_LOG_LEVEL override it.
+	LogLevel string `mapstructure:"log_level"`
+
+	// LogFile also logs everything, at every level, to safeshell.log in
+	// the safeshell directory
+	LogFile bool `mapstructure:"log_file"`
+
+	// Snapshots makes creating a checkpoint also write a daily snapshot of
+	// the index and every manifest, kept in SnapshotDir (default: snapshots
+	// in the safeshell directory)
+	Snapshots   bool   `mapstructure:"snapshots"`
+	SnapshotDir string `mapstructure:"snapshot_dir"`
+
+	// Language for CLI messages ("auto" follows LANG)
+	Language string `mapstructure:"language"`
+
+	// DiffTool renders 'safeshell diff --content' output: builtin, delta, difft or git
+	DiffTool string `mapstructure:"diff_tool"`
+
+	// MCP tool exposure. If MCPEnabledTools is non-empty only those tools are
+	// offered; MCPDisabledTools are then removed from the offered set.
+	MCPEnabledTools  []string `mapstructure:"mcp_enabled_tools"`
+	MCPDisabledTools []string `mapstructure:"mcp_disabled_tools"`
+
+	// MCPRequireCheckpointMinutes makes high-risk MCP tools refuse to run
+	// unless a checkpoint of the paths they affect was created in the last
+	// this many minutes. 0 turns the check off.
+	MCPRequireCheckpointMinutes int `mapstructure:"mcp_require_checkpoint_minutes"`
+
+	// Remote is where 'safeshell push' and 'safeshell pull' store checkpoints
+	Remote RemoteConfig `mapstructure:"remote"`
+
+	// Hooks are shell commands run around checkpoint and rollback operations
+	Hooks HooksConfig `mapstructure:"hooks"`
+
+	// RealCommands maps a wrapped command to the binary it runs, for systems
+	// where the first match on PATH is the wrong one
+	RealCommands map[string]string `mapstructure:"real_commands"`
+
+	// Watch lists the paths 'safeshell watch' checkpoints when run without arguments
+	Watch []WatchPolicy `mapstructure:"watch"`
+
+	// ConfirmHighRiskMB makes wrapped high-risk commands whose targets
+	// hold at least this much ask before running, once checkpointed. 0
+	// turns it off.
+	ConfirmHighRiskMB int `mapstructure:"confirm_high_risk_mb"`
+
+	// RmStrategy is how wrapped rm is checkpointed: "copy" backs its
+	// targets up and runs it, "trash" moves them into the checkpoint
+	// instead, so nothing is copied and rolling back is a rename
+	RmStrategy string `mapstructure:"rm_strategy"`
+
+	// Rules decide, from what a wrapped command is about to do, whether it
+	// runs, asks first or needs a checkpoint. The first matching one wins,
+	// the organization policy's before the user's.
+	Rules []Rule `mapstructure:"rules"`
+
+	// CustomCommands are commands safeshell has no parser for to wrap as
+	// well, each saying which of its arguments to back up
+	CustomCommands []CustomCommand `mapstructure:"custom_commands"`
+
+	// Policy is the organization policy loaded from PolicyPath, if any
+	Policy *Policy `mapstructure:"-"`
+}
+
+// RemoteConfig describes a remote checkpoint store.
+// Credentials for S3 are read from the standard AWS_* environment variables.
+type RemoteConfig struct {
+	Type      string `mapstructure:"type"` // "s3" or "local"
+	Bucket    string `mapstructure:"bucket"`
+	Prefix    string `mapstructure:"prefix"`
+	Region    string `mapstructure:"region"`
+	Endpoint  string `mapstructure:"endpoint"`   // Custom endpoint for S3-compatible stores (MinIO, R2, ...)
+	PathStyle bool   `mapstructure:"path_style"` // Use path-style URLs (bucket in path instead of host)
+	Path      string `mapstructure:"path"`       // Directory for the local backend
+}
+
+// HooksConfig holds the hook scripts, each run with sh -c
+type HooksConfig struct {
+	PreCheckpoint  string `mapstructure:"pre_checkpoint"`
+	PostCheckpoint string `mapstructure:"post_checkpoint"`
+	PreRollback    string `mapstructure:"pre_rollback"`
+	PostRollback   string `mapstructure:"post_rollback"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+}
+
+// WatchPolicy controls automatic checkpoints for one watched directory
+type WatchPolicy struct {
+	Path        string        `mapstructure:"path"`
+	Debounce    time.Duration `mapstructure:"debounce"`     // quiet period before checkpointing (default 2s)
+	MinInterval time.Duration `mapstructure:"min_interval"` // minimum time between checkpoints
+	Exclude     []string      `mapstructure:"exclude"`      // glob patterns to ignore
+}
+
+// CustomCommand is a user-defined wrapped command. Targets says which of
+// its arguments are backed up, counting only those that aren't flags:
+// "all-positional" (the default), "first-positional", "last-positional",
+// "all-but-first", "all-but-last", or a template such as "$2 $4.." or
+// "--dir" picking them by position or by the flag they follow.
+type CustomCommand struct {
+	Name    string `mapstructure:"name"`
+	Risk    string `mapstructure:"risk"` // HIGH, MEDIUM or LOW, shown by --dry-run (default HIGH)
+	Targets string `mapstructure:"targets"`
 }
 
 var cfg *Config
@@ -42,10 +220,33 @@ func Init() error {
 
 	viper.SetDefault("safeshell_dir", safeshellDir)
 	viper.SetDefault("retention_days", 7)
+	viper.SetDefault("keep_per_session", 0) // 0 = no per-session minimum
 	viper.SetDefault("max_checkpoints", 100)
+	viper.SetDefault("max_checkpoints_action", "delete")
+	viper.SetDefault("max_storage_action", "compress")
 	viper.SetDefault("max_storage_mb", 5000)       // 5GB total storage limit
 	viper.SetDefault("max_file_size_mb", 100)      // 100MB per file limit
+	viper.SetDefault("backup_workers", 0)          // 0 = one per CPU, up to 8
 	viper.SetDefault("warn_sensitive_files", true) // Warn about sensitive files
+	viper.SetDefault("cloud_placeholders", "skip")
+	viper.SetDefault("fs_snapshots", "off")
+	viper.SetDefault("wrapper_messages", "stderr")
+	viper.SetDefault("log_level", "normal")
+	viper.SetDefault("log_file", false)
+	viper.SetDefault("mcp_require_checkpoint_minutes", 0)
+	viper.SetDefault("confirm_high_risk_mb", 0) // 0 = never ask
+	viper.SetDefault("rm_strategy", "copy")
+	viper.SetDefault("snapshots", true)
+	viper.SetDefault("snapshot_dir", "")
+	viper.SetDefault("scope_to_project", false)
+	viper.SetDefault("project_markers", []string{
+		".git",
+		".hg",
+		"go.mod",
+		"package.json",
+		"Cargo.toml",
+		"pyproject.toml",
+	})
 	viper.SetDefault("exclude_paths", []string{
 		"*.tmp",
 		"*.swp",
@@ -53,6 +254,10 @@ func Init() error {
 		".git/objects/*",
 		"node_modules/*",
 	})
+	viper.SetDefault("disabled_exclusions", []string{})
+	viper.SetDefault("include_paths", []string{})
+	viper.SetDefault("respect_gitignore", false)
+	viper.SetDefault("git_aware", false)
 	viper.SetDefault("sensitive_patterns", []string{
 		".env",
 		".env.*",
@@ -74,7 +279,14 @@ func Init() error {
 		"aws_credentials",
 		".aws/credentials",
 	})
-	viper.SetDefault("wrapped_commands", []string{"rm", "mv", "cp", "chmod", "chown"})
+	viper.SetDefault("wrapped_commands", []string{"rm", "mv", "cp", "chmod", "chown", "rsync", "dd", "truncate", "shred", "find"})
+	viper.SetDefault("compression_algorithm", "gzip") // gzip, zstd, or none
+	viper.SetDefault("compression_level", 0)          // 0 = algorithm default
+	viper.SetDefault("language", "auto")              // auto, en, es
+	viper.SetDefault("diff_tool", "builtin")          // builtin, delta, difft, git
+	viper.SetDefault("preserve_macos_metadata", false)
+	viper.SetDefault("preserve_times", false)
+	viper.SetDefault("hooks.timeout_seconds", 60)
 
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -94,14 +306,32 @@ func Init() error {
 		}
 	}
 
-	cfg = &Config{}
-	if err := viper.Unmarshal(cfg); err != nil {
+	c := &Config{}
+	if err := viper.Unmarshal(c, decodeHooks); err != nil {
+		return err
+	}
+	normalizeRules(c.Rules)
+
+	// Enforce organization policy on top of user settings
+	policy, err := loadPolicy()
+	if err != nil {
 		return err
 	}
+	c.Policy = policy
+	applyPolicy(c, policy)
 
+	cfg = c
 	return nil
 }
 
+// Reset forgets the loaded config and everything viper was told, the
+// directories searched for the config file included, which Init only adds
+// to. Tests call it before Init when they point HOME elsewhere.
+func Reset() {
+	viper.Reset()
+	cfg = nil
+}
+
 func Get() *Config {
 	if cfg == nil {
 		Init()
@@ -109,6 +339,34 @@ func Get() *Config {
 	return cfg
 }
 
+// FilePath returns the location of the user config file
+func FilePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".safeshell", "config.yaml")
+}
+
+// Wrapped returns every command wrapped: WrappedCommands, then the
+// CustomCommands not among them
+func (c *Config) Wrapped() []string {
+	wrapped := append([]string(nil), c.WrappedCommands...)
+	for _, custom := range c.CustomCommands {
+		if custom.Name != "" && !slices.Contains(wrapped, custom.Name) {
+			wrapped = append(wrapped, custom.Name)
+		}
+	}
+	return wrapped
+}
+
+// CustomCommand returns the CustomCommands entry for name, if any
+func (c *Config) CustomCommand(name string) (CustomCommand, bool) {
+	for _, custom := range c.CustomCommands {
+		if custom.Name == name {
+			return custom, true
+		}
+	}
+	return CustomCommand{}, false
+}
+
 func GetSafeShellDir() string {
 	return Get().SafeShellDir
 }
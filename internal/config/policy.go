@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// PolicyPath is the location of the optional system-wide policy file.
+// Settings in the policy are enforced on top of the user's config and
+// cannot be overridden by it.
+var PolicyPath = "/etc/safeshell/policy.yaml"
+
+// Features that can be turned off organization-wide via disabled_features
+const (
+	FeatureMCP           = "mcp"
+	FeatureUpgrade       = "upgrade"
+	FeatureDisable       = "disable"
+	FeatureSchedule      = "schedule"
+	FeatureRemoteStorage = "remote_storage"
+	FeatureGRPC          = "grpc"
+	// FeatureNoCheckpoint is running wrapped commands without a checkpoint,
+	// with 'wrap --no-checkpoint' or SAFESHELL_DISABLE
+	FeatureNoCheckpoint = "no_checkpoint"
+)
+
+// Policy holds organization-enforced settings
+type Policy struct {
+	MinRetentionDays int             `mapstructure:"min_retention_days"`
+	ProtectedPaths   []ProtectedPath `mapstructure:"protected_paths"`
+	DisabledFeatures []string        `mapstructure:"disabled_features"`
+	Rules            []Rule          `mapstructure:"rules"`
+}
+
+// loadPolicy reads the policy file if present. A missing file is not an error.
+func loadPolicy() (*Policy, error) {
+	if _, err := os.Stat(PolicyPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read policy %s: %w", PolicyPath, err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(PolicyPath)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", PolicyPath, err)
+	}
+
+	p := &Policy{}
+	if err := v.Unmarshal(p, decodeHooks); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", PolicyPath, err)
+	}
+	normalizeRules(p.Rules)
+	return p, nil
+}
+
+// applyPolicy overrides user settings that conflict with the policy
+func applyPolicy(c *Config, p *Policy) {
+	if p == nil {
+		return
+	}
+
+	if c.RetentionDays < p.MinRetentionDays {
+		c.RetentionDays = p.MinRetentionDays
+	}
+
+	// The strictest matching entry wins, so the policy's can't be loosened
+	for _, path := range p.ProtectedPaths {
+		if !slices.Contains(c.ProtectedPaths, path) {
+			c.ProtectedPaths = append(c.ProtectedPaths, path)
+		}
+	}
+
+	// The first matching rule wins, so the policy's go first
+	c.Rules = append(slices.Clone(p.Rules), c.Rules...)
+}
+
+// GetPolicy returns the active organization policy, or nil if none is installed
+func GetPolicy() *Policy {
+	return Get().Policy
+}
+
+// FeatureEnabled reports whether a feature is allowed by the policy
+func FeatureEnabled(feature string) bool {
+	return Get().FeatureEnabled(feature)
+}
+
+// FeatureEnabled reports whether a feature is allowed by c's policy
+func (c *Config) FeatureEnabled(feature string) bool {
+	return c.Policy == nil || !containsString(c.Policy.DisabledFeatures, feature)
+}
+
+// MinRetentionDays returns the policy-enforced minimum retention (0 if none)
+func MinRetentionDays() int {
+	p := GetPolicy()
+	if p == nil {
+		return 0
+	}
+	return p.MinRetentionDays
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// What a wrapped command touching a protected path does
+const (
+	ProtectCheckpoint = "checkpoint-and-allow" // run it after a checkpoint, the default
+	ProtectConfirm    = "confirm"              // ask first, refuse without a terminal
+	ProtectDeny       = "deny"                 // refuse to run it
+)
+
+// protectStrictness orders the actions, for the strictest of several
+// matching entries to win
+var protectStrictness = map[string]int{
+	ProtectCheckpoint: 0,
+	ProtectConfirm:    1,
+	ProtectDeny:       2,
+}
+
+// ProtectedPath is a protected_paths entry: a path or glob, where **
+// spans any number of directories, and what wrapped commands touching it
+// do. Protected paths are always backed up in full, ignoring exclusions
+// and size limits. An entry may be just the path, for the default action.
+type ProtectedPath struct {
+	Path   string `mapstructure:"path"`
+	Action string `mapstructure:"action"` // checkpoint-and-allow, confirm or deny
+}
+
+// action returns p.Action, the default if it is unset, or deny if it is
+// unknown: a misspelt action mustn't leave the path unguarded
+func (p ProtectedPath) action() string {
+	if p.Action == "" {
+		return ProtectCheckpoint
+	}
+	if _, ok := protectStrictness[p.Action]; ok {
+		return p.Action
+	}
+	return ProtectDeny
+}
+
+// matches reports whether file is the protected path or inside it
+func (p ProtectedPath) matches(file string) bool {
+	return matchPath(p.Path, file)
+}
+
+// within reports whether the protected path is inside dir, as far as the
+// part of it before any wildcard tells
+func (p ProtectedPath) within(dir string) bool {
+	var fixed []string
+	for _, segment := range splitPath(expandHome(p.Path)) {
+		if strings.ContainsAny(segment, "*?[") {
+			break
+		}
+		fixed = append(fixed, segment)
+	}
+	prefix := filepath.FromSlash(strings.Join(fixed, "/"))
+	if !filepath.IsAbs(prefix) {
+		// Relative patterns can't be placed
+		return false
+	}
+	return strings.HasPrefix(prefix, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// Protection returns the protected_paths entry that applies to file, the
+// one with the strictest action if several do, with its action filled in.
+// With holding set, entries inside file apply too, for commands that
+// destroy whole directories.
+func (c *Config) Protection(file string, holding bool) (ProtectedPath, bool) {
+	var found ProtectedPath
+	ok := false
+	for _, p := range c.ProtectedPaths {
+		if !p.matches(file) && !(holding && p.within(file)) {
+			continue
+		}
+		p.Action = p.action()
+		if !ok || protectStrictness[p.Action] > protectStrictness[found.Action] {
+			found, ok = p, true
+		}
+	}
+	return found, ok
+}
+
+// IsProtectedPath reports whether path falls under one of the protected paths.
+// Protected paths are always backed up in full, ignoring exclusions and size limits.
+func IsProtectedPath(file string) bool {
+	for _, p := range Get().ProtectedPaths {
+		if p.matches(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath reports whether file matches pattern, a path or glob starting
+// with / or ~/, or is inside what it matches
+func matchPath(pattern, file string) bool {
+	return matchSegments(splitPath(expandHome(pattern)), splitPath(file))
+}
+
+func splitPath(p string) []string {
+	return strings.Split(filepath.ToSlash(p), "/")
+}
+
+// matchSegments matches a path against a pattern a segment at a time. **
+// matches any number of segments, and a pattern matching a directory
+// matches everything under it.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(segments); i >= 0; i-- {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return true
+}
+
+// protectedPathHook decodes a protected_paths entry given as a plain
+// string into a ProtectedPath with the default action
+func protectedPathHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() == reflect.String && to == reflect.TypeOf(ProtectedPath{}) {
+		return ProtectedPath{Path: data.(string)}, nil
+	}
+	return data, nil
+}
+
+// decodeHooks are viper's own decode hooks plus protectedPathHook
+var decodeHooks = viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+	protectedPathHook,
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+))
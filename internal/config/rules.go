@@ -0,0 +1,80 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// What a wrapped command matching a rule does
+const (
+	RuleAllow             = "allow"              // run it as usual, skipping later rules
+	RuleConfirm           = ProtectConfirm       // ask first, refuse without a terminal
+	RuleDeny              = ProtectDeny          // refuse to run it
+	RuleRequireCheckpoint = "require-checkpoint" // run it only once its targets are checkpointed
+)
+
+// riskLevels are the risk levels of commands, least risky first
+var riskLevels = []string{"LOW", "MEDIUM", "HIGH"}
+
+// Rule is a rules entry: a wrapped command meeting every condition set
+// gets Action. Conditions left unset match any command.
+type Rule struct {
+	Name     string   `mapstructure:"name"`
+	Commands []string `mapstructure:"commands"`
+	Flags    []string `mapstructure:"flags"` // each given; "-r|--recursive" for either
+	Risk     string   `mapstructure:"risk"`  // at least this risk: LOW, MEDIUM or HIGH
+	Paths    []string `mapstructure:"paths"` // a target matches one, like protected_paths
+
+	// MaxDepth matches commands with a target this many directories deep
+	// or less: / is 0, /home 1 and /home/me 2
+	MaxDepth int `mapstructure:"max_depth"`
+
+	// MinFiles and MinSizeMB match commands whose targets hold at least
+	// this many files, or this much data
+	MinFiles  int `mapstructure:"min_files"`
+	MinSizeMB int `mapstructure:"min_size_mb"`
+
+	Action  string `mapstructure:"action"`  // allow, confirm, deny or require-checkpoint
+	Message string `mapstructure:"message"` // told when the rule applies
+}
+
+// Measured reports whether the rule needs to know how much the targets
+// hold, which takes walking them
+func (r Rule) Measured() bool {
+	return r.MinFiles > 0 || r.MinSizeMB > 0
+}
+
+// MatchesCommand reports whether the rule is about command name, of risk
+// level risk
+func (r Rule) MatchesCommand(name, risk string) bool {
+	if len(r.Commands) > 0 && !slices.Contains(r.Commands, name) {
+		return false
+	}
+	return r.Risk == "" || slices.Index(riskLevels, strings.ToUpper(risk)) >= slices.Index(riskLevels, r.Risk)
+}
+
+// MatchesPath reports whether file matches the rule's paths, or there are none
+func (r Rule) MatchesPath(file string) bool {
+	if len(r.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range r.Paths {
+		if matchPath(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRules upper-cases risk levels and makes unknown actions deny,
+// so a mistyped rule errs on the safe side
+func normalizeRules(rules []Rule) {
+	for i := range rules {
+		rules[i].Risk = strings.ToUpper(rules[i].Risk)
+		switch rules[i].Action {
+		case RuleAllow, RuleConfirm, RuleDeny, RuleRequireCheckpoint:
+		default:
+			rules[i].Action = RuleDeny
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrNotRunning is returned when no daemon is listening on the socket
+var ErrNotRunning = errors.New("daemon is not running")
+
+// dialTimeout keeps a wedged daemon from delaying commands for long; a
+// missing socket fails immediately
+const dialTimeout = 200 * time.Millisecond
+
+// Call sends req to the daemon listening on socket and returns its response.
+// Errors reported by the daemon are returned as errors.
+func Call(socket string, req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to daemon: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read daemon response: %w", err)
+	}
+	if resp.Error != "" {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Ping checks that the daemon is running
+func Ping(socket string) (*Response, error) {
+	return Call(socket, Request{Op: OpPing})
+}
+
+// Settings asks the daemon for the settings deciding whether a wrapped
+// command runs: wrapped_commands, protected_paths, rules,
+// confirm_high_risk_mb and rm_strategy
+func Settings(socket string) (*Response, error) {
+	return Call(socket, Request{Op: OpSettings})
+}
+
+// Stop asks the daemon to exit
+func Stop(socket string) error {
+	_, err := Call(socket, Request{Op: OpStop})
+	return err
+}
+
+// CreateCheckpoint asks the daemon to create a checkpoint of paths, which
+// are resolved against workingDir, before running command. If name is set,
+// the checkpoint is only created if it is in wrapped_commands; the response
+// has NotWrapped set otherwise. With inPlace, the command writes to paths
+// in place, so they are backed up as copies rather than hard links.
+func CreateCheckpoint(socket, name, command string, paths []string, workingDir, sessionID string, inPlace bool) (*Response, error) {
+	return Call(socket, Request{
+		Op:         OpCheckpoint,
+		Name:       name,
+		Command:    command,
+		Paths:      paths,
+		WorkingDir: workingDir,
+		SessionID:  sessionID,
+		InPlace:    inPlace,
+	})
+}
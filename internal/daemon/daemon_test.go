@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func setupTestEnv(t *testing.T) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "safeshell-daemon-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	config.Reset()
+	config.Init()
+	checkpoint.ResetIndex()
+
+	os.MkdirAll(filepath.Join(tmpDir, "testdata"), 0755)
+
+	cleanup := func() {
+		os.RemoveAll(tmpDir)
+	}
+	return tmpDir, cleanup
+}
+
+// startServer runs a daemon on a socket in the test's home directory
+func startServer(t *testing.T) string {
+	t.Helper()
+	socket := SocketPath()
+	server, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	done := make(chan error)
+	go func() { done <- server.Serve() }()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve failed: %v", err)
+		}
+	})
+	return socket
+}
+
+func TestCreateCheckpointThroughDaemon(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	socket := startServer(t)
+
+	testDir := filepath.Join(tmpDir, "testdata")
+	os.WriteFile(filepath.Join(testDir, "a.txt"), []byte("a"), 0644)
+
+	resp, err := CreateCheckpoint(socket, "rm", "rm a.txt", []string{"a.txt"}, testDir, "client-session", false)
+	if err != nil {
+		t.Fatalf("CreateCheckpoint failed: %v", err)
+	}
+
+	cp, err := checkpoint.Get(resp.CheckpointID)
+	if err != nil {
+		t.Fatalf("Checkpoint %s not found: %v", resp.CheckpointID, err)
+	}
+	if cp.Manifest.WorkingDir != testDir || cp.Manifest.SessionID != "client-session" {
+		t.Errorf("Expected the client's origin, got %q / %q", cp.Manifest.WorkingDir, cp.Manifest.SessionID)
+	}
+	if resp.CheckpointDir != cp.Dir {
+		t.Errorf("Expected checkpoint dir %q, got %q", cp.Dir, resp.CheckpointDir)
+	}
+	if len(cp.Manifest.Files) != 1 || cp.Manifest.Files[0].OriginalPath != filepath.Join(testDir, "a.txt") {
+		t.Errorf("Unexpected files: %v", cp.Manifest.Files)
+	}
+
+	// Commands not in wrapped_commands get no checkpoint
+	resp, err = CreateCheckpoint(socket, "cat", "cat a.txt", []string{"a.txt"}, testDir, "", false)
+	if err != nil || !resp.NotWrapped || resp.CheckpointID != "" {
+		t.Errorf("Expected no checkpoint for cat, got %+v (%v)", resp, err)
+	}
+
+	// Errors come back as errors
+	if _, err := CreateCheckpoint(socket, "", "rm", nil, testDir, "", false); err == nil {
+		t.Error("Expected error for a request without paths")
+	}
+	if _, err := Call(socket, Request{Op: "bogus"}); err == nil {
+		t.Error("Expected error for an unknown op")
+	}
+}
+
+func TestSettingsThroughDaemon(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	socket := startServer(t)
+
+	// Config edited after the daemon started is picked up
+	yaml := `protected_paths:
+  - "~/work"
+  - path: "~/.ssh/**"
+    action: deny
+rules:
+  - commands: ["rm"]
+    action: confirm
+confirm_high_risk_mb: 500
+rm_strategy: trash
+custom_commands:
+  - name: tool
+    targets: last-positional
+`
+	os.WriteFile(config.FilePath(), []byte(yaml), 0644)
+	policyPath := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policyPath, []byte("disabled_features: [no_checkpoint]\n"), 0644)
+	oldPolicyPath := config.PolicyPath
+	config.PolicyPath = policyPath
+	defer func() { config.PolicyPath = oldPolicyPath }()
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(config.FilePath(), later, later)
+
+	resp, err := Settings(socket)
+	if err != nil {
+		t.Fatalf("Settings failed: %v", err)
+	}
+	if !slices.Contains(resp.Wrapped, "rm") {
+		t.Errorf("Expected rm to be wrapped, got %v", resp.Wrapped)
+	}
+	if want := []config.CustomCommand{{Name: "tool", Targets: "last-positional"}}; !slices.Equal(resp.CustomCommands, want) {
+		t.Errorf("Expected custom commands %v, got %v", want, resp.CustomCommands)
+	}
+	want := []config.ProtectedPath{{Path: "~/work"}, {Path: "~/.ssh/**", Action: config.ProtectDeny}}
+	if !slices.Equal(resp.ProtectedPaths, want) {
+		t.Errorf("Expected protected paths %v, got %v", want, resp.ProtectedPaths)
+	}
+	if len(resp.Rules) != 1 || resp.Rules[0].Action != config.RuleConfirm || !slices.Equal(resp.Rules[0].Commands, []string{"rm"}) {
+		t.Errorf("Unexpected rules: %+v", resp.Rules)
+	}
+	if resp.ConfirmHighRiskMB != 500 || resp.RmStrategy != "trash" {
+		t.Errorf("Expected 500 and trash, got %d and %q", resp.ConfirmHighRiskMB, resp.RmStrategy)
+	}
+	if !slices.Equal(resp.DisabledFeatures, []string{config.FeatureNoCheckpoint}) {
+		t.Errorf("Expected the policy's disabled features, got %v", resp.DisabledFeatures)
+	}
+}
+
+func TestSingleDaemonAndStop(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	socket := SocketPath()
+	if _, err := Ping(socket); err != ErrNotRunning {
+		t.Fatalf("Expected ErrNotRunning before start, got %v", err)
+	}
+
+	server, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	done := make(chan error)
+	go func() { done <- server.Serve() }()
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket with mode 0600, got %v (%v)", info, err)
+	}
+	if resp, err := Ping(socket); err != nil || resp.PID != os.Getpid() {
+		t.Errorf("Ping failed: %v %v", resp, err)
+	}
+	if _, err := Listen(socket); err == nil {
+		t.Error("Expected a second daemon to be refused")
+	}
+
+	if err := Stop(socket); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve failed: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Error("Expected socket to be removed on stop")
+	}
+}
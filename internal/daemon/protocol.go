@@ -0,0 +1,85 @@
+// Package daemon keeps config and the checkpoint index loaded in a
+// long-lived process, so wrapped commands can create checkpoints without
+// paying for config load, index load and directory scans on every call.
+//
+// Clients send one JSON request per connection over a unix socket and read
+// one JSON response back.
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// Request operations
+const (
+	OpPing       = "ping"
+	OpCheckpoint = "checkpoint"
+	OpSettings   = "settings"
+	OpStop       = "stop"
+)
+
+// Request is sent by a client
+type Request struct {
+	Op         string   `json:"op"`
+	Command    string   `json:"command,omitempty"`
+	Paths      []string `json:"paths,omitempty"`
+	WorkingDir string   `json:"working_dir,omitempty"`
+	SessionID  string   `json:"session_id,omitempty"`
+
+	// Name is the wrapped command, if any: the checkpoint is only created
+	// if it is in wrapped_commands
+	Name string `json:"name,omitempty"`
+
+	// InPlace is set when the command writes to the paths in place, for
+	// them to be backed up as copies rather than hard links
+	InPlace bool `json:"in_place,omitempty"`
+}
+
+// Response is the daemon's answer to a Request
+type Response struct {
+	Error         string `json:"error,omitempty"`
+	CheckpointID  string `json:"checkpoint_id,omitempty"`
+	CheckpointDir string `json:"checkpoint_dir,omitempty"` // for clients to read the manifest
+	Language      string `json:"language,omitempty"`       // configured language, for client messages
+	Messages      string `json:"messages,omitempty"`       // configured wrapper_messages
+	LogLevel      string `json:"log_level,omitempty"`      // configured log_level
+	PID           int    `json:"pid,omitempty"`
+	StartedAt     string `json:"started_at,omitempty"`
+
+	// Evicted is what creating the checkpoint did to older ones to stay
+	// within its limits, for the client to report
+	Evicted []*checkpoint.Eviction `json:"evicted,omitempty"`
+
+	// NotWrapped is set when no checkpoint was created because the
+	// request's Name is not in wrapped_commands
+	NotWrapped bool `json:"not_wrapped,omitempty"`
+
+	// RealCommands is the configured real_commands map, so clients can find
+	// the wrapped binary without loading config
+	RealCommands map[string]string `json:"real_commands,omitempty"`
+
+	// OperationsLog is where clients record the commands they wrap,
+	// without loading config to find it
+	OperationsLog string `json:"operations_log,omitempty"`
+
+	// The settings deciding whether a wrapped command runs, answering
+	// OpSettings, so clients can check it without loading config
+	Wrapped           []string               `json:"wrapped,omitempty"`
+	CustomCommands    []config.CustomCommand `json:"custom_commands,omitempty"`
+	ProtectedPaths    []config.ProtectedPath `json:"protected_paths,omitempty"`
+	Rules             []config.Rule          `json:"rules,omitempty"`
+	ConfirmHighRiskMB int                    `json:"confirm_high_risk_mb,omitempty"`
+	RmStrategy        string                 `json:"rm_strategy,omitempty"`
+	DisabledFeatures  []string               `json:"disabled_features,omitempty"` // the policy's
+}
+
+// SocketPath returns where the daemon listens. It is fixed under the home
+// directory so clients can find it without loading config.
+func SocketPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".safeshell", "daemon.sock")
+}
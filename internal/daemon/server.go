@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// Server answers client requests on a unix socket
+type Server struct {
+	listener  net.Listener
+	socket    string
+	startedAt time.Time
+
+	mu     sync.Mutex // serializes checkpoints
+	config fileStamp  // config and policy files as last loaded
+	closed chan struct{}
+	once   sync.Once
+}
+
+// fileStamp identifies the versions of the config files that were loaded
+type fileStamp [2]int64
+
+func statConfig() fileStamp {
+	var stamp fileStamp
+	for i, path := range []string{config.FilePath(), config.PolicyPath} {
+		if info, err := os.Stat(path); err == nil {
+			stamp[i] = info.ModTime().UnixNano()
+		}
+	}
+	return stamp
+}
+
+// Listen starts listening on socket. It fails if another daemon is already
+// running there and replaces a stale socket left by one that died.
+func Listen(socket string) (*Server, error) {
+	if _, err := Ping(socket); err == nil {
+		return nil, fmt.Errorf("daemon is already running on %s", socket)
+	}
+	os.Remove(socket)
+
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	// Only our user may ask for checkpoints
+	if err := os.Chmod(socket, 0600); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("failed to secure socket: %w", err)
+	}
+
+	return &Server{
+		listener:  lis,
+		socket:    socket,
+		startedAt: time.Now(),
+		config:    statConfig(),
+		closed:    make(chan struct{}),
+	}, nil
+}
+
+// Serve handles connections until Close is called or a client sends stop
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.closed:
+				return nil
+			default:
+			}
+			return fmt.Errorf("accept failed: %w", err)
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops the server and removes its socket
+func (s *Server) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.closed)
+		err = s.listener.Close()
+		os.Remove(s.socket)
+	})
+	return err
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	resp := s.respond(req)
+	json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == OpStop {
+		s.Close()
+	}
+}
+
+func (s *Server) respond(req Request) *Response {
+	resp := &Response{
+		PID:       os.Getpid(),
+		StartedAt: s.startedAt.Format(time.RFC3339),
+	}
+	switch req.Op {
+	case OpPing, OpStop:
+	case OpSettings:
+		s.mu.Lock()
+		err := s.reloadConfig()
+		s.mu.Unlock()
+		if err != nil {
+			resp.Error = err.Error()
+			break
+		}
+		c := config.Get()
+		resp.Wrapped = c.Wrapped()
+		resp.CustomCommands = c.CustomCommands
+		resp.ProtectedPaths = c.ProtectedPaths
+		resp.Rules = c.Rules
+		resp.ConfirmHighRiskMB = c.ConfirmHighRiskMB
+		resp.RmStrategy = c.RmStrategy
+		if c.Policy != nil {
+			resp.DisabledFeatures = c.Policy.DisabledFeatures
+		}
+	case OpCheckpoint:
+		cp, err := s.checkpoint(req)
+		if err == errNotWrapped {
+			resp.NotWrapped = true
+		} else if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.CheckpointID = cp.ID
+			resp.CheckpointDir = cp.Dir
+			resp.Evicted = cp.Evicted
+		}
+	default:
+		resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+	}
+	resp.Language = config.Get().Language
+	resp.Messages = config.Get().WrapperMessages
+	resp.LogLevel = config.Get().LogLevel
+	resp.RealCommands = config.Get().RealCommands
+	resp.OperationsLog = config.GetOperationsLog()
+	return resp
+}
+
+// errNotWrapped is returned by checkpoint for commands not in
+// wrapped_commands
+var errNotWrapped = errors.New("not a wrapped command")
+
+func (s *Server) checkpoint(req Request) (*checkpoint.Checkpoint, error) {
+	if len(req.Paths) == 0 {
+		return nil, fmt.Errorf("no paths to checkpoint")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	if req.Name != "" && !slices.Contains(config.Get().Wrapped(), req.Name) {
+		return nil, errNotWrapped
+	}
+	origin := checkpoint.Origin{WorkingDir: req.WorkingDir, SessionID: req.SessionID}
+	if req.InPlace {
+		return checkpoint.CreateForWriter(context.Background(), origin, req.Command, req.Paths)
+	}
+	return checkpoint.CreateFor(origin, req.Command, req.Paths)
+}
+
+// refresh picks up config edits and checkpoints created or removed by other
+// processes since the last request
+func (s *Server) refresh() error {
+	if err := s.reloadConfig(); err != nil {
+		return err
+	}
+	return checkpoint.GetIndex().Reload()
+}
+
+// reloadConfig picks up config edits since the last request
+func (s *Server) reloadConfig() error {
+	if stamp := statConfig(); stamp != s.config {
+		if err := config.Init(); err != nil {
+			return fmt.Errorf("failed to reload config: %w", err)
+		}
+		// safeshell_dir may have moved
+		checkpoint.ResetIndex()
+		s.config = stamp
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package debugserver exposes runtime diagnostics for the long-running
+// modes (daemon, MCP server): pprof profiles and expvar counters over HTTP,
+// and a gops agent, e.g. to see why checkpoint creation is slow or where
+// memory goes on a large store.
+package debugserver
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+
+	"github.com/google/gops/agent"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+var publishOnce sync.Once
+
+// publish registers the expvar variables that are computed on request
+func publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("checkpoint_index_entries", expvar.Func(func() any {
+			return len(checkpoint.GetIndex().ListEntries())
+		}))
+	})
+}
+
+// Server serves the debug endpoints until closed
+type Server struct {
+	// Addr is the address the HTTP endpoints listen on
+	Addr string
+
+	http *http.Server
+}
+
+// Start serves /debug/pprof/ and /debug/vars on addr and starts a gops
+// agent. The endpoints are unauthenticated and heap profiles contain file
+// paths, so addr must be a loopback address.
+func Start(addr string) (*Server, error) {
+	if err := checkLoopback(addr); err != nil {
+		return nil, err
+	}
+	publish()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	if err := agent.Listen(agent.Options{}); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("failed to start gops agent: %w", err)
+	}
+
+	s := &Server{Addr: lis.Addr().String(), http: &http.Server{Handler: mux}}
+	go s.http.Serve(lis)
+	return s, nil
+}
+
+// Close stops the endpoints and the gops agent
+func (s *Server) Close() error {
+	agent.Close()
+	return s.http.Close()
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid debug address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return errors.New("debug endpoints only listen on loopback addresses, e.g. 127.0.0.1:6060")
+}
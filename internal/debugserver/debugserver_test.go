@@ -0,0 +1,92 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func setupTestEnv(t *testing.T) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "safeshell-debug-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	os.Setenv("XDG_CONFIG_HOME", tmpDir) // gops agent config
+	config.Reset()
+	config.Init()
+	checkpoint.ResetIndex()
+
+	cleanup := func() {
+		os.RemoveAll(tmpDir)
+	}
+	return tmpDir, cleanup
+}
+
+func get(t *testing.T, url string) []byte {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return body
+}
+
+func TestStart(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	file := filepath.Join(tmpDir, "file.txt")
+	os.WriteFile(file, []byte("content"), 0644)
+	if _, err := checkpoint.Create("rm file.txt", []string{file}); err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	server, err := Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Close()
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(get(t, "http://"+server.Addr+"/debug/vars"), &vars); err != nil {
+		t.Fatalf("Invalid /debug/vars: %v", err)
+	}
+	for _, name := range []string{"memstats", "checkpoint_create", "checkpoint_index_entries"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("Expected %s in /debug/vars", name)
+		}
+	}
+	var created struct{ Count int }
+	json.Unmarshal(vars["checkpoint_create"], &created)
+	if created.Count < 1 {
+		t.Errorf("Expected checkpoint_create.count >= 1, got %s", vars["checkpoint_create"])
+	}
+
+	get(t, "http://"+server.Addr+"/debug/pprof/")
+	get(t, "http://"+server.Addr+"/debug/pprof/heap")
+}
+
+func TestStartRejectsNonLoopback(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	for _, addr := range []string{":6060", "0.0.0.0:6060", "example.com:6060", "6060"} {
+		if server, err := Start(addr); err == nil {
+			server.Close()
+			t.Errorf("Expected %s to be rejected", addr)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package grpcserver implements the safeshell.v1 gRPC API on top of the
+// checkpoint and rollback packages.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	safeshellv1 "github.com/qhkm/safeshell/api/safeshell/v1"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/rollback"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements safeshellv1.SafeShellServer
+type Server struct {
+	safeshellv1.UnimplementedSafeShellServer
+
+	// mu serializes operations that modify checkpoints or the filesystem
+	mu sync.Mutex
+}
+
+// New creates a Server
+func New() *Server {
+	return &Server{}
+}
+
+// Register creates a gRPC server with the SafeShell service registered
+func Register(opts ...grpc.ServerOption) *grpc.Server {
+	gs := grpc.NewServer(opts...)
+	safeshellv1.RegisterSafeShellServer(gs, New())
+	return gs
+}
+
+func (s *Server) CreateCheckpoint(req *safeshellv1.CreateCheckpointRequest, stream safeshellv1.SafeShell_CreateCheckpointServer) error {
+	if len(req.GetPaths()) == 0 {
+		return status.Error(codes.InvalidArgument, "paths is required")
+	}
+	reason := req.GetReason()
+	if reason == "" {
+		reason = "grpc checkpoint"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keep going if the client disconnects; a half-made checkpoint is worse
+	cp, err := checkpoint.CreateWithProgress(reason, req.GetPaths(), func(p checkpoint.Progress) {
+		stream.Send(&safeshellv1.CreateCheckpointResponse{
+			Event: &safeshellv1.CreateCheckpointResponse_Progress{Progress: toProgress(p)},
+		})
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create checkpoint: %v", err)
+	}
+
+	return stream.Send(&safeshellv1.CreateCheckpointResponse{
+		Event: &safeshellv1.CreateCheckpointResponse_Checkpoint{Checkpoint: toCheckpoint(cp, true)},
+	})
+}
+
+func (s *Server) ListCheckpoints(ctx context.Context, req *safeshellv1.ListCheckpointsRequest) (*safeshellv1.ListCheckpointsResponse, error) {
+	checkpoints, err := checkpoint.Search(checkpoint.SearchOptions{Tag: req.GetTag()})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list checkpoints: %v", err)
+	}
+
+	resp := &safeshellv1.ListCheckpointsResponse{}
+	for _, cp := range checkpoints {
+		if req.GetSessionId() != "" && cp.Manifest.SessionID != req.GetSessionId() {
+			continue
+		}
+		if req.GetLimit() > 0 && len(resp.Checkpoints) >= int(req.GetLimit()) {
+			break
+		}
+		resp.Checkpoints = append(resp.Checkpoints, toCheckpoint(cp, false))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetCheckpoint(ctx context.Context, req *safeshellv1.GetCheckpointRequest) (*safeshellv1.Checkpoint, error) {
+	cp, err := resolve(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toCheckpoint(cp, true), nil
+}
+
+func (s *Server) DiffCheckpoint(ctx context.Context, req *safeshellv1.DiffCheckpointRequest) (*safeshellv1.DiffCheckpointResponse, error) {
+	cp, err := resolve(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := checkpoint.Compare(cp)
+	if req.GetSummary() {
+		return &safeshellv1.DiffCheckpointResponse{
+			Summary: checkpoint.SummarizeDiffs(diffs).Render(int(req.GetMaxBytes())),
+		}, nil
+	}
+
+	resp := &safeshellv1.DiffCheckpointResponse{}
+	for _, d := range diffs {
+		resp.Files = append(resp.Files, &safeshellv1.FileDiff{
+			Path:        d.Path,
+			Status:      toDiffStatus(d.Status),
+			BackupSize:  d.BackupSize,
+			CurrentSize: d.CurrentSize,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) Rollback(req *safeshellv1.RollbackRequest, stream safeshellv1.SafeShell_RollbackServer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, err := resolve(req.GetId())
+	if err != nil {
+		return err
+	}
+	if cp.Manifest.RolledBack {
+		return status.Errorf(codes.FailedPrecondition, "checkpoint %s has already been rolled back", cp.ID)
+	}
+
+	restored, err := rollback.RollbackWithProgress(cp, func(p checkpoint.Progress) {
+		stream.Send(&safeshellv1.RollbackResponse{
+			Event: &safeshellv1.RollbackResponse_Progress{Progress: toProgress(p)},
+		})
+	})
+	// Files that couldn't be restored are in the result, next to those that were
+	var failed int
+	var partial *rollback.PartialError
+	if errors.As(err, &partial) {
+		failed = partial.Failed
+	} else if err != nil {
+		return status.Errorf(codes.Internal, "rollback failed: %v", err)
+	}
+
+	return stream.Send(&safeshellv1.RollbackResponse{
+		Event: &safeshellv1.RollbackResponse_Result{Result: &safeshellv1.RollbackResult{
+			CheckpointId:  cp.ID,
+			FilesRestored: int32(restored),
+			FilesFailed:   int32(failed),
+		}},
+	})
+}
+
+func (s *Server) CompressCheckpoint(ctx context.Context, req *safeshellv1.CompressCheckpointRequest) (*safeshellv1.CompressCheckpointResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, err := resolve(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if cp.Manifest.Compressed {
+		return nil, status.Errorf(codes.FailedPrecondition, "checkpoint %s is already compressed", cp.ID)
+	}
+
+	original, compressed, err := checkpoint.Compress(cp.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to compress checkpoint: %v", err)
+	}
+	return &safeshellv1.CompressCheckpointResponse{OriginalSize: original, CompressedSize: compressed}, nil
+}
+
+func (s *Server) DeleteCheckpoint(ctx context.Context, req *safeshellv1.DeleteCheckpointRequest) (*safeshellv1.DeleteCheckpointResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if _, err := checkpoint.Get(req.GetId()); err != nil {
+		return nil, status.Errorf(codes.NotFound, "checkpoint not found: %s", req.GetId())
+	}
+	if err := checkpoint.Delete(req.GetId()); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+	return &safeshellv1.DeleteCheckpointResponse{}, nil
+}
+
+// resolve looks up a checkpoint by ID, accepting "latest"
+func resolve(id string) (*checkpoint.Checkpoint, error) {
+	switch id {
+	case "":
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	case "latest":
+		cp, err := checkpoint.GetLatest()
+		if err != nil {
+			return nil, status.Error(codes.NotFound, "no checkpoints found")
+		}
+		return cp, nil
+	}
+
+	cp, err := checkpoint.Get(id)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "checkpoint not found: %s", id)
+	}
+	return cp, nil
+}
+
+func toProgress(p checkpoint.Progress) *safeshellv1.Progress {
+	return &safeshellv1.Progress{
+		Phase: p.Phase,
+		Done:  int32(p.Done),
+		Total: int32(p.Total),
+		Path:  p.Path,
+	}
+}
+
+func toCheckpoint(cp *checkpoint.Checkpoint, withFiles bool) *safeshellv1.Checkpoint {
+	m := cp.Manifest
+	out := &safeshellv1.Checkpoint{
+		Id:         cp.ID,
+		CreatedAt:  timestamppb.New(cp.CreatedAt),
+		Command:    m.Command,
+		WorkingDir: m.WorkingDir,
+		SessionId:  m.SessionID,
+		Tags:       m.Tags,
+		RolledBack: m.RolledBack,
+		Compressed: m.Compressed,
+	}
+
+	for _, f := range m.Files {
+		if !f.IsDir {
+			out.FileCount++
+			out.TotalSize += f.Size
+		}
+		if withFiles {
+			out.Files = append(out.Files, &safeshellv1.FileEntry{
+				OriginalPath: f.OriginalPath,
+				Size:         f.Size,
+				Mode:         uint32(f.Mode),
+				IsDir:        f.IsDir,
+			})
+		}
+	}
+	return out
+}
+
+func toDiffStatus(s string) safeshellv1.DiffStatus {
+	switch s {
+	case checkpoint.DiffDeleted:
+		return safeshellv1.DiffStatus_DIFF_STATUS_DELETED
+	case checkpoint.DiffModified:
+		return safeshellv1.DiffStatus_DIFF_STATUS_MODIFIED
+	case checkpoint.DiffUnchanged:
+		return safeshellv1.DiffStatus_DIFF_STATUS_UNCHANGED
+	}
+	return safeshellv1.DiffStatus_DIFF_STATUS_UNSPECIFIED
+}
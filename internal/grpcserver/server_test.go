@@ -0,0 +1,192 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	safeshellv1 "github.com/qhkm/safeshell/api/safeshell/v1"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func setupTestEnv(t *testing.T) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "safeshell-grpc-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	config.Reset()
+	config.Init()
+	checkpoint.ResetIndex()
+
+	os.MkdirAll(filepath.Join(tmpDir, "testdata"), 0755)
+
+	cleanup := func() {
+		os.RemoveAll(tmpDir)
+	}
+	return tmpDir, cleanup
+}
+
+// testClient starts a server on an in-memory listener and connects to it
+func testClient(t *testing.T) safeshellv1.SafeShellClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := Register()
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return safeshellv1.NewSafeShellClient(conn)
+}
+
+func TestCheckpointLifecycle(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	client := testClient(t)
+	ctx := context.Background()
+
+	a := filepath.Join(tmpDir, "testdata", "a.txt")
+	b := filepath.Join(tmpDir, "testdata", "b.txt")
+	os.WriteFile(a, []byte("alpha"), 0644)
+	os.WriteFile(b, []byte("beta"), 0644)
+
+	// Create streams the scan, then the files backed up, ending with them all
+	stream, err := client.CreateCheckpoint(ctx, &safeshellv1.CreateCheckpointRequest{Paths: []string{a, b}, Reason: "agent step 1"})
+	if err != nil {
+		t.Fatalf("CreateCheckpoint failed: %v", err)
+	}
+	var progress []*safeshellv1.Progress
+	var created *safeshellv1.Checkpoint
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("CreateCheckpoint stream failed: %v", err)
+		}
+		if p := resp.GetProgress(); p != nil {
+			progress = append(progress, p)
+		}
+		if cp := resp.GetCheckpoint(); cp != nil {
+			created = cp
+		}
+	}
+	if created == nil {
+		t.Fatal("Expected a checkpoint at the end of the stream")
+	}
+	if last := len(progress) - 1; last < 1 || progress[0].Phase != checkpoint.PhaseScan || progress[last].Done != 2 || progress[last].Total != 2 {
+		t.Errorf("Unexpected progress events: %v", progress)
+	}
+	if created.Command != "agent step 1" || created.FileCount != 2 {
+		t.Errorf("Unexpected checkpoint: %v", created)
+	}
+
+	list, err := client.ListCheckpoints(ctx, &safeshellv1.ListCheckpointsRequest{})
+	if err != nil {
+		t.Fatalf("ListCheckpoints failed: %v", err)
+	}
+	if len(list.Checkpoints) != 1 || list.Checkpoints[0].Id != created.Id || len(list.Checkpoints[0].Files) != 0 {
+		t.Errorf("Unexpected list: %v", list.Checkpoints)
+	}
+
+	// Delete one file and check the diff sees it
+	os.Remove(a)
+	diff, err := client.DiffCheckpoint(ctx, &safeshellv1.DiffCheckpointRequest{Id: "latest"})
+	if err != nil {
+		t.Fatalf("DiffCheckpoint failed: %v", err)
+	}
+	statuses := make(map[string]safeshellv1.DiffStatus)
+	for _, f := range diff.Files {
+		statuses[filepath.Base(f.Path)] = f.Status
+	}
+	if statuses["a.txt"] != safeshellv1.DiffStatus_DIFF_STATUS_DELETED || statuses["b.txt"] != safeshellv1.DiffStatus_DIFF_STATUS_UNCHANGED {
+		t.Errorf("Unexpected diff: %v", diff.Files)
+	}
+
+	rb, err := client.Rollback(ctx, &safeshellv1.RollbackRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	var result *safeshellv1.RollbackResult
+	for {
+		resp, err := rb.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Rollback stream failed: %v", err)
+		}
+		if r := resp.GetResult(); r != nil {
+			result = r
+		}
+	}
+	if result == nil || result.FilesRestored != 2 || result.FilesFailed != 0 {
+		t.Errorf("Unexpected rollback result: %v", result)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "alpha" {
+		t.Errorf("Expected a.txt restored, got %q", data)
+	}
+
+	got, err := client.GetCheckpoint(ctx, &safeshellv1.GetCheckpointRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("GetCheckpoint failed: %v", err)
+	}
+	if !got.RolledBack {
+		t.Error("Expected checkpoint to be marked rolled back")
+	}
+
+	// A second rollback is rejected once the stream is read
+	rb, _ = client.Rollback(ctx, &safeshellv1.RollbackRequest{Id: created.Id})
+	if _, err := rb.Recv(); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+
+	if _, err := client.DeleteCheckpoint(ctx, &safeshellv1.DeleteCheckpointRequest{Id: created.Id}); err != nil {
+		t.Fatalf("DeleteCheckpoint failed: %v", err)
+	}
+	if _, err := client.GetCheckpoint(ctx, &safeshellv1.GetCheckpointRequest{Id: created.Id}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound after delete, got %v", err)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	client := testClient(t)
+	ctx := context.Background()
+
+	stream, err := client.CreateCheckpoint(ctx, &safeshellv1.CreateCheckpointRequest{})
+	if err != nil {
+		t.Fatalf("CreateCheckpoint failed: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for missing paths, got %v", err)
+	}
+
+	if _, err := client.GetCheckpoint(ctx, &safeshellv1.GetCheckpointRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for missing id, got %v", err)
+	}
+	if _, err := client.GetCheckpoint(ctx, &safeshellv1.GetCheckpointRequest{Id: "latest"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound with no checkpoints, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Package hooks runs user-configured scripts around checkpoint and rollback
+// operations, e.g. to snapshot a database or pause file watchers.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// Hook events
+const (
+	PreCheckpoint  = "pre_checkpoint"
+	PostCheckpoint = "post_checkpoint"
+	PreRollback    = "pre_rollback"
+	PostRollback   = "post_rollback"
+)
+
+// activeEnv is set while a hook runs. Commands the hook itself runs through
+// safeshell (e.g. a wrapped rm) skip hooks instead of recursing.
+const activeEnv = "SAFESHELL_HOOK"
+
+// Active reports whether we are running inside a hook
+func Active() bool {
+	return os.Getenv(activeEnv) != ""
+}
+
+// Env describes the checkpoint a hook runs for
+type Env struct {
+	CheckpointID  string
+	CheckpointDir string
+	Command       string
+	Paths         []string
+}
+
+// command returns the script configured for event
+func command(event string) string {
+	h := config.Get().Hooks
+	switch event {
+	case PreCheckpoint:
+		return h.PreCheckpoint
+	case PostCheckpoint:
+		return h.PostCheckpoint
+	case PreRollback:
+		return h.PreRollback
+	case PostRollback:
+		return h.PostRollback
+	}
+	return ""
+}
+
+// Run runs the hook configured for event, if any, with sh -c. The checkpoint
+// is described by SAFESHELL_* environment variables; SAFESHELL_PATHS holds
+// one path per line. Hook output goes to stderr. A non-zero exit or timeout
+// is returned as an error: pre hooks abort the operation, post hook errors
+// are only warnings.
+func Run(event string, env Env) error {
+	script := command(event)
+	if script == "" || Active() {
+		return nil
+	}
+
+	timeout := time.Duration(config.Get().Hooks.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	killProcessGroup(cmd)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		activeEnv+"="+event,
+		"SAFESHELL_CHECKPOINT_ID="+env.CheckpointID,
+		"SAFESHELL_CHECKPOINT_DIR="+env.CheckpointDir,
+		"SAFESHELL_COMMAND="+env.Command,
+		"SAFESHELL_PATHS="+strings.Join(env.Paths, "\n"),
+	)
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s hook timed out after %s", event, timeout)
+		}
+		return fmt.Errorf("%s hook failed: %w", event, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func setupTestEnv(t *testing.T) string {
+	tmpDir := t.TempDir()
+	os.Setenv("HOME", tmpDir)
+	config.Reset()
+	config.Init()
+	return tmpDir
+}
+
+func TestRunPassesCheckpointEnv(t *testing.T) {
+	tmpDir := setupTestEnv(t)
+	out := filepath.Join(tmpDir, "hook.out")
+	config.Get().Hooks.PreRollback = `printf '%s|%s|%s|%s|%s' "$SAFESHELL_HOOK" "$SAFESHELL_CHECKPOINT_ID" "$SAFESHELL_CHECKPOINT_DIR" "$SAFESHELL_COMMAND" "$SAFESHELL_PATHS" > ` + out
+
+	err := Run(PreRollback, Env{
+		CheckpointID:  "cp-1",
+		CheckpointDir: "/cp/dir",
+		Command:       "rm -rf build",
+		Paths:         []string{"/a", "/b"},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(out)
+	if want := "pre_rollback|cp-1|/cp/dir|rm -rf build|/a\n/b"; string(data) != want {
+		t.Errorf("Unexpected hook environment: got %q, want %q", data, want)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	setupTestEnv(t)
+	hooks := &config.Get().Hooks
+
+	if err := Run(PostCheckpoint, Env{}); err != nil {
+		t.Errorf("Unconfigured hook should be a no-op, got %v", err)
+	}
+
+	hooks.PreCheckpoint = "exit 3"
+	if err := Run(PreCheckpoint, Env{}); err == nil || !strings.Contains(err.Error(), "pre_checkpoint") {
+		t.Errorf("Expected pre_checkpoint failure, got %v", err)
+	}
+
+	hooks.PreCheckpoint = "sleep 5"
+	hooks.TimeoutSeconds = 1
+	if err := Run(PreCheckpoint, Env{}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+}
+
+func TestRunSkipsNestedHooks(t *testing.T) {
+	setupTestEnv(t)
+	config.Get().Hooks.PreCheckpoint = "exit 1"
+
+	// Commands run by a hook must not trigger hooks themselves
+	t.Setenv(activeEnv, PreRollback)
+	if err := Run(PreCheckpoint, Env{}); err != nil {
+		t.Errorf("Hook should be skipped inside another hook, got %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+package hooks
+
+import "os/exec"
+
+// killProcessGroup is a no-op on Windows; only the shell is killed
+func killProcessGroup(cmd *exec.Cmd) {}
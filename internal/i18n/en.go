@@ -0,0 +1,167 @@
+package i18n
+
+var english = map[string]string{
+	// Checkpoint details
+	"checkpoint.header":  "Checkpoint: %s",
+	"checkpoint.command": "Command:    %s",
+	"checkpoint.time":    "Time:       %s",
+
+	// Wrapper
+	"wrap.checkpoint_created": "[safeshell] Checkpoint created: %s",
+	"wrap.checkpoint_failed":  "Warning: failed to create checkpoint: %v",
+	"wrap.bypass_forbidden":   "[safeshell] Organization policy requires checkpoints: ignoring --no-checkpoint and SAFESHELL_DISABLE",
+	"wrap.evicted_delete":     "[safeshell] Deleted the %d oldest checkpoint(s) to stay within %s (%d); pin checkpoints to keep them",
+	"wrap.evicted_compress":   "[safeshell] Compressed the %d oldest checkpoint(s) to stay within %s (%d)",
+	"wrap.summary":            "[safeshell] %s — restore with `safeshell rollback %s`",
+	"wrap.summary_unchanged":  "[safeshell] No checkpointed files changed (checkpoint %s)",
+	"wrap.effect_deleted":     "%d file(s) deleted",
+	"wrap.effect_changed":     "%d file(s) changed",
+	"wrap.effect_moved":       "%d file(s) moved",
+	"wrap.dryrun_title":       "Dry Run - No changes will be made",
+	"wrap.dryrun_command":     "Command: %s",
+	"wrap.not_wrapped":        "⚠ Command '%s' is not wrapped by SafeShell",
+	"wrap.not_wrapped_detail": "  This command will execute without creating a checkpoint.",
+	"wrap.wrapped_commands":   "Wrapped commands: %s",
+	"wrap.risk_level":         "Risk level: %s",
+	"wrap.no_targets":         "⚠ No target files/directories detected",
+	"wrap.no_checkpoint":      "  No checkpoint would be created.",
+	"wrap.to_backup":          "Files/directories to backup:",
+	"wrap.missing_target":     "  ✗ %s (does not exist - will be skipped)",
+	"wrap.target_error":       "  ✗ %s (error: %v)",
+	"wrap.target_dir":         "  ✓ %s/ (directory, %d files, %s)",
+	"wrap.scoped_target":      "  → %s is above the project root; only %s is backed up",
+	"wrap.device_skipped":     "[safeshell] Not backing up %s, a %s: devices can't be checkpointed, so this can't be rolled back",
+	"wrap.target_device":      "  ✗ %s (%s - devices can't be backed up)",
+	"wrap.block_device":       "block device",
+	"wrap.block_device_size":  "%s block device",
+	"wrap.char_device":        "character device",
+	"wrap.special_file":       "special file",
+	"wrap.target_file":        "  ✓ %s (%s)",
+	"wrap.paths_backed_up":    "  • %d path(s) would be backed up",
+	"wrap.total_files":        "  • %d total file(s)",
+	"wrap.total_size":         "  • %s total size",
+	"wrap.would_checkpoint":   "✓ A checkpoint would be created before executing this command",
+	"wrap.nothing_to_backup":  "⚠ No existing files to backup - no checkpoint would be created",
+	"wrap.run_for_real":       "To execute this command for real, run without --dry-run:",
+
+	// Protected paths
+	"wrap.protected":                             "%s is under protected path %s (%s)",
+	"wrap.protected_target":                      "[safeshell] %s is under protected path %s",
+	"wrap.confirm_run":                           "[safeshell] Run %s anyway?",
+	"wrap.not_interactive":                       "no (not interactive)",
+	"wrap.checkpoint_required":                   "not running without a checkpoint, which failed: %v",
+	"wrap.target_protected":                      "  ! %s is under protected path %s: %s",
+	"wrap.protected_action_deny":                 "the command would be refused",
+	"wrap.protected_action_confirm":              "you would be asked first",
+	"wrap.protected_action_checkpoint-and-allow": "it is backed up in full",
+
+	// Rules
+	"wrap.rule_matched":                   "%s matches it",
+	"wrap.rule_confirm":                   "[safeshell] Rule %s: %s",
+	"wrap.rule_dryrun":                    "  ! Rule %s applies: %s",
+	"wrap.rule_action_allow":              "the command runs as usual",
+	"wrap.rule_action_confirm":            "you would be asked first",
+	"wrap.rule_action_deny":               "the command would be refused",
+	"wrap.rule_action_require-checkpoint": "the command only runs once checkpointed",
+
+	// High-risk confirmation
+	"wrap.high_risk":                "[safeshell] %s is about to act on %d file(s), %s",
+	"wrap.high_risk_checkpoint":     "[safeshell] Checkpoint %s holds them",
+	"wrap.high_risk_no_checkpoint":  "[safeshell] No checkpoint holds them: this can't be rolled back",
+	"wrap.assumed_yes":              "yes (SAFESHELL_YES or --yes)",
+	"wrap.declined":                 "%s not run",
+	"wrap.checkpoint_delete_failed": "Warning: failed to delete checkpoint %s: %v",
+
+	// rm_strategy: trash
+	"wrap.trash_dryrun":         "  ! rm_strategy is trash: the targets would be moved into the checkpoint, without running rm",
+	"wrap.trash_restore_failed": "Warning: failed to put back what checkpoint %s holds: %v",
+
+	// Rollback
+	"rollback.no_checkpoints":      "no checkpoints found",
+	"rollback.not_found":           "checkpoint not found: %s",
+	"rollback.specify":             "please specify a checkpoint ID or use --last",
+	"rollback.already_rolled_back": "checkpoint has already been rolled back",
+	"rollback.none_selected":       "No files selected. Rollback cancelled.",
+	"rollback.files_not_found":     "none of the specified files found in checkpoint",
+	"rollback.pattern_unmatched":   "no files in the checkpoint match %s",
+	"rollback.restoring":           "Restoring %d file(s)...",
+	"rollback.restoring_to":        "Restoring %d file(s) to %s...",
+	"rollback.complete":            "Rollback complete!",
+	"rollback.decompressing":       "Decompressing checkpoint...",
+	"rollback.restored":            "Successfully restored %d files from checkpoint %s",
+	"rollback.restored_to":         "Successfully restored %d files to %s",
+	"rollback.restore_failed":      "Warning: failed to restore %s: %v",
+	"rollback.perms_failed":        "Warning: failed to restore permissions for %s: %v",
+	"rollback.times_failed":        "Warning: failed to restore modification time of %s: %v",
+	"rollback.path_conflict":       "Warning: not restoring %s: another file restores to the same path, or to one differing only by case on this filesystem",
+	"rollback.conflicts":           "%d file(s) changed since the checkpoint:",
+	"rollback.conflict_prompt":     "    [r]estore, [k]eep current, [b]oth (current saved as .current)? R/K/B for all: ",
+	"rollback.conflict_kept":       "  Keeping current %s",
+	"rollback.conflict_saved":      "  Saved current %s as %s",
+	"rollback.safety_failed":       "Warning: could not checkpoint the current files before rolling back: %v",
+	"rollback.git_failed":          "Warning: could not restore %d unchanged tracked file(s) from git: %v",
+	"rollback.safety_saved":        "Current files saved in checkpoint %s (undo with 'safeshell undo-rollback')",
+	"rollback.recreated_removed":   "Removed %d file(s) that did not exist before rollback of %s",
+	"rollback.moved_remain":        "%d file(s) the command moved are also still where it moved them",
+	"rollback.aside_failed":        "Warning: could not put back %s, it was left at %s: %v",
+	"rollback.resuming":            "Resuming an interrupted rollback, %d file(s) already restored",
+	"rollback.interrupted_found":   "An earlier rollback of this checkpoint was interrupted; starting over (use --resume to continue it instead)",
+	"rollback.journal_failed":      "Warning: could not record rollback progress, an interrupted rollback can't be resumed: %v",
+	"rollback.mkdir_failed":        "Warning: failed to create directory for %s: %v",
+	"rollback.manifest_failed":     "Warning: failed to update manifest: %v",
+	"rollback.hook_failed":         "Warning: %v",
+	"rollback.no_files":            "no files in checkpoint",
+	"rollback.select_title":        "Select files to restore:",
+	"rollback.select_help":         "space select · a all · / search · d diff · enter restore · q quit",
+	"rollback.select_count":        "%d of %d selected",
+	"rollback.select_search":       "Search: %s",
+	"rollback.select_no_match":     "No files match the search",
+	"rollback.preview_title":       "Diff of %s",
+	"rollback.preview_direction":   "Changes since the checkpoint, which restoring undoes",
+	"rollback.preview_unchanged":   "Unchanged since the checkpoint",
+	"rollback.preview_help":        "↑/↓ scroll · space select · d back · enter restore · q quit",
+	"rollback.no_prompt":           "cannot select files interactively without a terminal (CI or piped stdin); use --files \"a,b\" to choose files, or --yes to restore all",
+	"rollback.assume_all":          "--yes given, restoring all files",
+	"status.deleted":               "[deleted]",
+	"status.modified":              "[modified]",
+	"status.unchanged":             "[unchanged]",
+
+	// Diff
+	"diff.already_rolled_back": "⚠ This checkpoint has already been rolled back",
+	"diff.summary":             "Summary:",
+	"diff.deleted":             "  • %d file(s) deleted - will be restored",
+	"diff.modified":            "  • %d file(s) modified - will be reverted",
+	"diff.renamed":             "  • %d file(s) renamed or moved - will be restored at the old path",
+	"diff.renamed_to":          "renamed to %s",
+	"diff.unchanged":           "  • %d file(s) unchanged - no action needed",
+	"diff.total_size":          "  • Total restore size: %s",
+	"diff.files_to_restore":    "Files to restore:",
+	"diff.file_not_found":      "file '%s' not found in checkpoint",
+	"diff.restore_hint":        "To restore these files, run:",
+	"diff.restore_some_hint":   "To restore specific files only:",
+	"diff.in_sync":             "✓ All files are already in sync with checkpoint",
+	"diff.since_header":        "Changes since %s, by %d checkpoint(s)",
+	"diff.since_none":          "No checkpoints since %s",
+	"diff.since_changed":       "Changed files, each with the checkpoints taken before commands touched it:",
+	"diff.since_in_sync":       "✓ No file backed up since then has changed",
+	"diff.since_restore_hint":  "To restore a file as it was before, roll back its oldest checkpoint:",
+
+	// Checkpoint browser
+	"ui.title":            "SafeShell checkpoints (%d)",
+	"ui.help":             "enter files · / search · t tag · c compress · x delete · r roll back · q quit",
+	"ui.no_match":         "No checkpoints match the search",
+	"ui.files_title":      "%s — %s",
+	"ui.files_help":       "space select · / search · d diff · enter restore · esc back",
+	"ui.comparing":        "Comparing files with the checkpoint...",
+	"ui.compressing":      "Compressing %s...",
+	"ui.compressed":       "Compressed %s: %s → %s",
+	"ui.decompressing":    "Decompressing %s...",
+	"ui.decompressed":     "Decompressed %s",
+	"ui.confirm_delete":   "Delete checkpoint %s?",
+	"ui.deleted":          "Deleted %s",
+	"ui.confirm_rollback": "Roll back %s, restoring %d file(s)?",
+	"ui.confirm_restore":  "Restore %d file(s) from %s?",
+	"ui.tag_prompt":       "Tag (-tag removes it): %s",
+	"ui.tagged":           "Tagged %s with %s",
+	"ui.untagged":         "Removed tag %[2]s from %[1]s",
+}
@@ -0,0 +1,167 @@
+package i18n
+
+var spanish = map[string]string{
+	// Checkpoint details
+	"checkpoint.header":  "Punto de control: %s",
+	"checkpoint.command": "Comando:          %s",
+	"checkpoint.time":    "Hora:             %s",
+
+	// Wrapper
+	"wrap.checkpoint_created": "[safeshell] Punto de control creado: %s",
+	"wrap.checkpoint_failed":  "Aviso: no se pudo crear el punto de control: %v",
+	"wrap.bypass_forbidden":   "[safeshell] La política de la organización exige puntos de control: se ignoran --no-checkpoint y SAFESHELL_DISABLE",
+	"wrap.evicted_delete":     "[safeshell] Se eliminaron los %d puntos de control más antiguos para no superar %s (%d); fije (pin) los que quiera conservar",
+	"wrap.evicted_compress":   "[safeshell] Se comprimieron los %d puntos de control más antiguos para no superar %s (%d)",
+	"wrap.summary":            "[safeshell] %s — restaure con `safeshell rollback %s`",
+	"wrap.summary_unchanged":  "[safeshell] Ningún archivo del punto de control cambió (punto de control %s)",
+	"wrap.effect_deleted":     "%d archivo(s) eliminado(s)",
+	"wrap.effect_changed":     "%d archivo(s) modificado(s)",
+	"wrap.effect_moved":       "%d archivo(s) movido(s)",
+	"wrap.dryrun_title":       "Simulación - No se realizará ningún cambio",
+	"wrap.dryrun_command":     "Comando: %s",
+	"wrap.not_wrapped":        "⚠ SafeShell no protege el comando '%s'",
+	"wrap.not_wrapped_detail": "  Este comando se ejecutará sin crear un punto de control.",
+	"wrap.wrapped_commands":   "Comandos protegidos: %s",
+	"wrap.risk_level":         "Nivel de riesgo: %s",
+	"wrap.no_targets":         "⚠ No se detectaron archivos ni directorios afectados",
+	"wrap.no_checkpoint":      "  No se crearía ningún punto de control.",
+	"wrap.to_backup":          "Archivos/directorios a respaldar:",
+	"wrap.missing_target":     "  ✗ %s (no existe - se omitirá)",
+	"wrap.target_error":       "  ✗ %s (error: %v)",
+	"wrap.target_dir":         "  ✓ %s/ (directorio, %d archivos, %s)",
+	"wrap.scoped_target":      "  → %s está por encima de la raíz del proyecto; solo se respalda %s",
+	"wrap.device_skipped":     "[safeshell] No se respalda %s, un %s: los dispositivos no se pueden incluir en un punto de control, así que esto no se podrá deshacer",
+	"wrap.target_device":      "  ✗ %s (%s - los dispositivos no se pueden respaldar)",
+	"wrap.block_device":       "dispositivo de bloques",
+	"wrap.block_device_size":  "dispositivo de bloques de %s",
+	"wrap.char_device":        "dispositivo de caracteres",
+	"wrap.special_file":       "archivo especial",
+	"wrap.target_file":        "  ✓ %s (%s)",
+	"wrap.paths_backed_up":    "  • %d ruta(s) se respaldarían",
+	"wrap.total_files":        "  • %d archivo(s) en total",
+	"wrap.total_size":         "  • %s en total",
+	"wrap.would_checkpoint":   "✓ Se crearía un punto de control antes de ejecutar este comando",
+	"wrap.nothing_to_backup":  "⚠ No hay archivos existentes que respaldar - no se crearía un punto de control",
+	"wrap.run_for_real":       "Para ejecutar este comando de verdad, hágalo sin --dry-run:",
+
+	// Protected paths
+	"wrap.protected":                             "%s está bajo la ruta protegida %s (%s)",
+	"wrap.protected_target":                      "[safeshell] %s está bajo la ruta protegida %s",
+	"wrap.confirm_run":                           "[safeshell] ¿Ejecutar %s de todos modos?",
+	"wrap.not_interactive":                       "no (no interactivo)",
+	"wrap.checkpoint_required":                   "no se ejecuta sin un punto de control, y no se pudo crear: %v",
+	"wrap.target_protected":                      "  ! %s está bajo la ruta protegida %s: %s",
+	"wrap.protected_action_deny":                 "el comando se rechazaría",
+	"wrap.protected_action_confirm":              "se le preguntaría antes",
+	"wrap.protected_action_checkpoint-and-allow": "se respalda completo",
+
+	// Rules
+	"wrap.rule_matched":                   "%s la cumple",
+	"wrap.rule_confirm":                   "[safeshell] Regla %s: %s",
+	"wrap.rule_dryrun":                    "  ! Se aplica la regla %s: %s",
+	"wrap.rule_action_allow":              "el comando se ejecuta como siempre",
+	"wrap.rule_action_confirm":            "se le preguntaría antes",
+	"wrap.rule_action_deny":               "el comando se rechazaría",
+	"wrap.rule_action_require-checkpoint": "el comando solo se ejecuta con un punto de control",
+
+	// High-risk confirmation
+	"wrap.high_risk":                "[safeshell] %s está a punto de actuar sobre %d archivo(s), %s",
+	"wrap.high_risk_checkpoint":     "[safeshell] El punto de control %s los contiene",
+	"wrap.high_risk_no_checkpoint":  "[safeshell] Ningún punto de control los contiene: no se podrá deshacer",
+	"wrap.assumed_yes":              "sí (SAFESHELL_YES o --yes)",
+	"wrap.declined":                 "%s no se ejecutó",
+	"wrap.checkpoint_delete_failed": "Aviso: no se pudo eliminar el punto de control %s: %v",
+
+	// rm_strategy: trash
+	"wrap.trash_dryrun":         "  ! rm_strategy es trash: los destinos se moverían al punto de control, sin ejecutar rm",
+	"wrap.trash_restore_failed": "Aviso: no se pudo devolver lo que contiene el punto de control %s: %v",
+
+	// Rollback
+	"rollback.no_checkpoints":      "no se encontraron puntos de control",
+	"rollback.not_found":           "punto de control no encontrado: %s",
+	"rollback.specify":             "indique el ID de un punto de control o use --last",
+	"rollback.already_rolled_back": "este punto de control ya fue restaurado",
+	"rollback.none_selected":       "No se seleccionó ningún archivo. Restauración cancelada.",
+	"rollback.files_not_found":     "ninguno de los archivos indicados está en el punto de control",
+	"rollback.pattern_unmatched":   "ningún archivo del punto de control coincide con %s",
+	"rollback.restoring":           "Restaurando %d archivo(s)...",
+	"rollback.restoring_to":        "Restaurando %d archivo(s) en %s...",
+	"rollback.complete":            "¡Restauración completada!",
+	"rollback.decompressing":       "Descomprimiendo punto de control...",
+	"rollback.restored":            "Se restauraron %d archivos del punto de control %s",
+	"rollback.restored_to":         "Se restauraron %d archivos en %s",
+	"rollback.restore_failed":      "Aviso: no se pudo restaurar %s: %v",
+	"rollback.perms_failed":        "Aviso: no se pudieron restaurar los permisos de %s: %v",
+	"rollback.times_failed":        "Aviso: no se pudo restaurar la fecha de modificación de %s: %v",
+	"rollback.path_conflict":       "Aviso: no se restaura %s: otro archivo se restaura en la misma ruta, o en una que solo difiere en mayúsculas y minúsculas en este sistema de archivos",
+	"rollback.conflicts":           "%d archivo(s) cambiaron después del punto de control:",
+	"rollback.conflict_prompt":     "    [r]estaurar, [k]mantener la actual, [b]ambas (la actual se guarda como .current)? R/K/B para todos: ",
+	"rollback.conflict_kept":       "  Se mantiene el actual %s",
+	"rollback.conflict_saved":      "  Actual %s guardado como %s",
+	"rollback.safety_failed":       "Advertencia: no se pudo crear un punto de control de los archivos actuales antes de restaurar: %v",
+	"rollback.git_failed":          "Advertencia: no se pudieron restaurar %d archivo(s) rastreados sin cambios desde git: %v",
+	"rollback.safety_saved":        "Archivos actuales guardados en el punto de control %s (deshacer con 'safeshell undo-rollback')",
+	"rollback.recreated_removed":   "Se eliminaron %d archivo(s) que no existían antes de restaurar %s",
+	"rollback.moved_remain":        "%d archivo(s) que el comando movió siguen también donde los movió",
+	"rollback.aside_failed":        "Advertencia: no se pudo recuperar %s, quedó en %s: %v",
+	"rollback.resuming":            "Reanudando una restauración interrumpida, %d archivo(s) ya restaurados",
+	"rollback.interrupted_found":   "Una restauración anterior de este punto de control se interrumpió; empezando de nuevo (use --resume para continuarla)",
+	"rollback.journal_failed":      "Advertencia: no se pudo registrar el progreso, una restauración interrumpida no se podrá reanudar: %v",
+	"rollback.mkdir_failed":        "Aviso: no se pudo crear el directorio para %s: %v",
+	"rollback.manifest_failed":     "Aviso: no se pudo actualizar el manifiesto: %v",
+	"rollback.hook_failed":         "Aviso: %v",
+	"rollback.no_files":            "el punto de control no contiene archivos",
+	"rollback.select_title":        "Seleccione los archivos a restaurar:",
+	"rollback.select_help":         "espacio seleccionar · a todos · / buscar · d diff · enter restaurar · q salir",
+	"rollback.select_count":        "%d de %d seleccionados",
+	"rollback.select_search":       "Buscar: %s",
+	"rollback.select_no_match":     "Ningún archivo coincide con la búsqueda",
+	"rollback.preview_title":       "Diff de %s",
+	"rollback.preview_direction":   "Cambios desde el punto de control, que la restauración deshace",
+	"rollback.preview_unchanged":   "Sin cambios desde el punto de control",
+	"rollback.preview_help":        "↑/↓ desplazar · espacio seleccionar · d volver · enter restaurar · q salir",
+	"rollback.no_prompt":           "no se pueden seleccionar archivos de forma interactiva sin una terminal (CI o stdin redirigida); use --files \"a,b\" para elegir archivos, o --yes para restaurar todos",
+	"rollback.assume_all":          "--yes indicado, restaurando todos los archivos",
+	"status.deleted":               "[eliminado]",
+	"status.modified":              "[modificado]",
+	"status.unchanged":             "[sin cambios]",
+
+	// Diff
+	"diff.already_rolled_back": "⚠ Este punto de control ya fue restaurado",
+	"diff.summary":             "Resumen:",
+	"diff.deleted":             "  • %d archivo(s) eliminado(s) - se restaurarán",
+	"diff.modified":            "  • %d archivo(s) modificado(s) - se revertirán a la versión guardada",
+	"diff.renamed":             "  • %d archivo(s) renombrado(s) o movido(s) - se restaurarán en la ruta anterior",
+	"diff.renamed_to":          "renombrado a %s",
+	"diff.unchanged":           "  • %d archivo(s) sin cambios - no requieren acción",
+	"diff.total_size":          "  • Tamaño total a restaurar: %s",
+	"diff.files_to_restore":    "Archivos a restaurar:",
+	"diff.file_not_found":      "el archivo '%s' no está en el punto de control",
+	"diff.restore_hint":        "Para restaurar estos archivos, ejecute:",
+	"diff.restore_some_hint":   "Para restaurar solo algunos archivos:",
+	"diff.in_sync":             "✓ Todos los archivos coinciden con el punto de control",
+	"diff.since_header":        "Cambios desde %s, en %d punto(s) de control",
+	"diff.since_none":          "No hay puntos de control desde %s",
+	"diff.since_changed":       "Archivos cambiados, cada uno con los puntos de control creados antes de los comandos que lo tocaron:",
+	"diff.since_in_sync":       "✓ Ningún archivo respaldado desde entonces ha cambiado",
+	"diff.since_restore_hint":  "Para restaurar un archivo como estaba antes, restaure su punto de control más antiguo:",
+
+	// Checkpoint browser
+	"ui.title":            "Puntos de control de SafeShell (%d)",
+	"ui.help":             "enter archivos · / buscar · t etiquetar · c comprimir · x eliminar · r restaurar · q salir",
+	"ui.no_match":         "Ningún punto de control coincide con la búsqueda",
+	"ui.files_title":      "%s — %s",
+	"ui.files_help":       "espacio seleccionar · / buscar · d diff · enter restaurar · esc volver",
+	"ui.comparing":        "Comparando archivos con el punto de control...",
+	"ui.compressing":      "Comprimiendo %s...",
+	"ui.compressed":       "%s comprimido: %s → %s",
+	"ui.decompressing":    "Descomprimiendo %s...",
+	"ui.decompressed":     "%s descomprimido",
+	"ui.confirm_delete":   "¿Eliminar el punto de control %s?",
+	"ui.deleted":          "%s eliminado",
+	"ui.confirm_rollback": "¿Restaurar %s, recuperando %d archivo(s)?",
+	"ui.confirm_restore":  "¿Restaurar %d archivo(s) de %s?",
+	"ui.tag_prompt":       "Etiqueta (-etiqueta la quita): %s",
+	"ui.tagged":           "%s etiquetado con %s",
+	"ui.untagged":         "Etiqueta %[2]s quitada de %[1]s",
+}
@@ -0,0 +1,96 @@
+// Package i18n provides translated user-facing messages for the CLI.
+//
+// Messages are looked up by key in a per-language catalog. Keys missing from
+// the active catalog fall back to English, so a partial translation is safe.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is used when no supported language is configured or detected
+const DefaultLocale = "en"
+
+var catalogs = map[string]map[string]string{
+	"en": english,
+	"es": spanish,
+}
+
+var locale = DefaultLocale
+
+// SetLocale selects the language for subsequent messages.
+// Unsupported languages fall back to English.
+func SetLocale(lang string) {
+	lang = normalize(lang)
+	if _, ok := catalogs[lang]; !ok {
+		lang = DefaultLocale
+	}
+	locale = lang
+}
+
+// Locale returns the active language
+func Locale() string {
+	return locale
+}
+
+// Locales returns the supported languages
+func Locales() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// IsSupported reports whether lang has a catalog
+func IsSupported(lang string) bool {
+	_, ok := catalogs[normalize(lang)]
+	return ok
+}
+
+// Detect picks the language to use. An explicit configured value wins
+// (unless it is "auto" or empty), followed by LC_ALL, LC_MESSAGES and LANG.
+func Detect(configured string) string {
+	candidates := []string{configured, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, c := range candidates {
+		if c == "" || strings.EqualFold(c, "auto") {
+			continue
+		}
+		return normalize(c)
+	}
+	return DefaultLocale
+}
+
+// T returns the message for key in the active language, formatted with args
+func T(key string, args ...interface{}) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		msg, ok = english[key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// normalize reduces a locale string like "es_ES.UTF-8" to its language code
+func normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if i := strings.IndexAny(lang, "_-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "c" || lang == "posix" {
+		return DefaultLocale
+	}
+	return lang
+}
@@ -0,0 +1,64 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+
+	if got := Detect(""); got != "es" {
+		t.Errorf("Expected es from LANG, got %s", got)
+	}
+	if got := Detect("auto"); got != "es" {
+		t.Errorf("Expected auto to use LANG, got %s", got)
+	}
+	if got := Detect("en"); got != "en" {
+		t.Errorf("Configured language should win, got %s", got)
+	}
+
+	t.Setenv("LANG", "C")
+	if got := Detect(""); got != DefaultLocale {
+		t.Errorf("Expected C locale to map to %s, got %s", DefaultLocale, got)
+	}
+}
+
+func TestTFallback(t *testing.T) {
+	defer SetLocale(DefaultLocale)
+
+	SetLocale("fr_FR")
+	if Locale() != DefaultLocale {
+		t.Errorf("Unsupported locale should fall back to %s, got %s", DefaultLocale, Locale())
+	}
+
+	SetLocale("es")
+	if got := T("rollback.restoring", 3); got != "Restaurando 3 archivo(s)..." {
+		t.Errorf("Unexpected translation: %s", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("Unknown keys should return the key, got %s", got)
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for key, msg := range english {
+			translated, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing key %s", lang, key)
+				continue
+			}
+			if strings.Count(translated, "%") != strings.Count(msg, "%") {
+				t.Errorf("%s: format verbs differ for %s", lang, key)
+			}
+		}
+		for key := range catalog {
+			if _, ok := english[key]; !ok {
+				t.Errorf("%s: key %s has no English source", lang, key)
+			}
+		}
+	}
+}
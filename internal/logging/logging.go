@@ -0,0 +1,190 @@
+// Package logging is how safeshell's packages report what they do, through
+// log/slog. Messages are printed to stderr as written, for people, from the
+// level chosen by log_level, --verbose or --quiet: quiet keeps warnings
+// only, verbose adds every file backed up or restored. With log_file set,
+// every message also goes to safeshell.log in the safeshell directory with
+// its time, level and attributes.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Levels, as log_level names them
+const (
+	LevelQuiet   = "quiet" // Warnings only
+	LevelNormal  = "normal"
+	LevelVerbose = "verbose" // Also each file backed up or restored
+)
+
+// LevelEnv overrides log_level. Wrapped commands take no flags of their
+// own, so this is how to quiet them.
+const LevelEnv = "SAFESHELL_LOG_LEVEL"
+
+// FileName is the log written to the safeshell directory with log_file
+const FileName = "safeshell.log"
+
+var (
+	mu      sync.Mutex
+	level   = slog.LevelInfo
+	output  io.Writer // nil for os.Stderr, looked up on each message
+	file    *os.File
+	fileLog slog.Handler
+
+	logger = slog.New(handler{})
+)
+
+// ParseLevel returns the level log_level names
+func ParseLevel(name string) (slog.Level, error) {
+	switch name {
+	case LevelQuiet:
+		return slog.LevelWarn, nil
+	case LevelNormal, "":
+		return slog.LevelInfo, nil
+	case LevelVerbose:
+		return slog.LevelDebug, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q (use quiet, normal or verbose)", name)
+}
+
+// SetLevel sets the lowest level printed to stderr
+func SetLevel(l slog.Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = l
+}
+
+// SetOutput sends what would be printed to stderr to w instead; nil
+// restores stderr
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// Setup applies the log_level and log_file settings. LevelEnv wins over
+// levelName.
+func Setup(levelName string, logFile bool, dir string) error {
+	if env := os.Getenv(LevelEnv); env != "" {
+		levelName = env
+	}
+	l, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	SetLevel(l)
+	if logFile {
+		return OpenFile(filepath.Join(dir, FileName))
+	}
+	return nil
+}
+
+// OpenFile appends every message, whatever the level, to the file at path.
+// A file already open is kept.
+func OpenFile(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	file = f
+	fileLog = slog.NewTextHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return nil
+}
+
+// Close closes the log file, if open
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file, fileLog = nil, nil
+	return err
+}
+
+// Logger returns the logger the functions below log to
+func Logger() *slog.Logger {
+	return logger
+}
+
+// Debug logs the details shown with --verbose, such as each file backed up
+func Debug(msg string, args ...any) {
+	logger.Debug(msg, args...)
+}
+
+// Info logs what people are normally told, which --quiet hides
+func Info(msg string, args ...any) {
+	logger.Info(msg, args...)
+}
+
+// Warn logs a problem that didn't stop the operation
+func Warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
+// handler prints messages to stderr, attributes after the message as
+// key=value, and passes them on to the log file. Groups are flattened.
+type handler struct {
+	attrs []slog.Attr
+}
+
+func (h handler) Enabled(ctx context.Context, l slog.Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return l >= level || fileLog != nil
+}
+
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if r.Level >= level {
+		var b strings.Builder
+		b.WriteString(r.Message)
+		write := func(a slog.Attr) bool {
+			fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+			return true
+		}
+		for _, a := range h.attrs {
+			write(a)
+		}
+		r.Attrs(write)
+		b.WriteByte('\n')
+
+		w := output
+		if w == nil {
+			w = os.Stderr
+		}
+		io.WriteString(w, b.String())
+	}
+
+	if fileLog != nil {
+		log := fileLog
+		if len(h.attrs) > 0 {
+			log = log.WithAttrs(h.attrs)
+		}
+		return log.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return h
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useBuffer(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	t.Cleanup(func() {
+		SetOutput(nil)
+		SetLevel(slog.LevelInfo)
+		Close()
+	})
+	return &buf
+}
+
+func TestLevels(t *testing.T) {
+	buf := useBuffer(t)
+
+	Debug("backed up", "path", "/a")
+	Info("Checkpoint created")
+	Warn("Warning: failed")
+	if got := buf.String(); got != "Checkpoint created\nWarning: failed\n" {
+		t.Errorf("Unexpected normal output: %q", got)
+	}
+
+	buf.Reset()
+	SetLevel(slog.LevelWarn)
+	Info("Checkpoint created")
+	Warn("Warning: failed")
+	if got := buf.String(); got != "Warning: failed\n" {
+		t.Errorf("Expected only the warning when quiet, got %q", got)
+	}
+
+	buf.Reset()
+	SetLevel(slog.LevelDebug)
+	Logger().With("checkpoint", "x").Debug("backed up", "path", "/a", "size", 3)
+	if got := buf.String(); got != "backed up checkpoint=x path=/a size=3\n" {
+		t.Errorf("Unexpected verbose output: %q", got)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	buf := useBuffer(t)
+	dir := t.TempDir()
+
+	t.Setenv(LevelEnv, LevelQuiet)
+	if err := Setup(LevelVerbose, true, dir); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	Debug("backed up", "path", "/a")
+	Info("Checkpoint created")
+	if buf.Len() != 0 {
+		t.Errorf("Expected %s to win over log_level, got %q", LevelEnv, buf.String())
+	}
+
+	// The file gets every level, whatever is printed
+	Close()
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	for _, want := range []string{"level=DEBUG msg=\"backed up\" path=/a", "level=INFO msg=\"Checkpoint created\""} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in log file, got:\n%s", want, data)
+		}
+	}
+
+	t.Setenv(LevelEnv, "loud")
+	if err := Setup(LevelNormal, false, dir); err == nil {
+		t.Error("Expected an invalid level to fail")
+	}
+}
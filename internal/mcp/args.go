@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ArgError describes a missing or malformed tool argument.
+// It is reported to the client as a JSON-RPC "Invalid params" error
+// rather than as a failed tool call. Name may be empty when the error
+// concerns a combination of arguments.
+type ArgError struct {
+	Name    string
+	Message string
+}
+
+func (e *ArgError) Error() string {
+	if e.Name == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("argument '%s' %s", e.Name, e.Message)
+}
+
+func argError(name, format string, a ...interface{}) *ArgError {
+	return &ArgError{Name: name, Message: fmt.Sprintf(format, a...)}
+}
+
+// Args wraps the raw arguments of a tool call with typed getters.
+// Getters are lenient about representation (numbers and booleans may
+// arrive as strings) but strict about meaning.
+type Args map[string]interface{}
+
+// has reports whether name was supplied with a non-null value
+func (a Args) has(name string) bool {
+	v, ok := a[name]
+	return ok && v != nil
+}
+
+// String returns an optional string argument, or "" if absent
+func (a Args) String(name string) (string, error) {
+	if !a.has(name) {
+		return "", nil
+	}
+	switch v := a[name].(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", argError(name, "must be a string, got %s", typeName(v))
+	}
+}
+
+// RequiredString returns a string argument that must be present and non-empty
+func (a Args) RequiredString(name string) (string, error) {
+	if !a.has(name) {
+		return "", argError(name, "is required")
+	}
+	s, err := a.String(name)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(s) == "" {
+		return "", argError(name, "must not be empty")
+	}
+	return s, nil
+}
+
+// Int returns an integer argument, or def if absent.
+// Accepts JSON numbers without a fractional part and numeric strings.
+func (a Args) Int(name string, def int) (int, error) {
+	if !a.has(name) {
+		return def, nil
+	}
+	switch v := a[name].(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, argError(name, "must be a whole number, got %v", v)
+		}
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, argError(name, "must be a number, got %q", v)
+		}
+		return n, nil
+	default:
+		return 0, argError(name, "must be a number, got %s", typeName(v))
+	}
+}
+
+// PositiveInt is like Int but rejects values below 1
+func (a Args) PositiveInt(name string, def int) (int, error) {
+	n, err := a.Int(name, def)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, argError(name, "must be at least 1, got %d", n)
+	}
+	return n, nil
+}
+
+// Bool returns a boolean argument, or def if absent.
+// Accepts true/false as well as "true"/"false", "yes"/"no" and "1"/"0".
+func (a Args) Bool(name string, def bool) (bool, error) {
+	if !a.has(name) {
+		return def, nil
+	}
+	switch v := a[name].(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "1":
+			return true, nil
+		case "false", "no", "0", "":
+			return false, nil
+		}
+		return false, argError(name, "must be true or false, got %q", v)
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+		return false, argError(name, "must be true or false, got %v", v)
+	default:
+		return false, argError(name, "must be true or false, got %s", typeName(v))
+	}
+}
+
+// StringSlice returns an optional array-of-strings argument.
+// A single string is accepted as a one-element array.
+func (a Args) StringSlice(name string) ([]string, error) {
+	if !a.has(name) {
+		return nil, nil
+	}
+	switch v := a[name].(type) {
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, argError(name, "must be an array of strings, element %d is %s", i, typeName(item))
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	case []string:
+		return v, nil
+	default:
+		return nil, argError(name, "must be an array of strings, got %s", typeName(v))
+	}
+}
+
+// RequiredStringSlice returns a non-empty array-of-strings argument
+func (a Args) RequiredStringSlice(name string) ([]string, error) {
+	if !a.has(name) {
+		return nil, argError(name, "is required")
+	}
+	values, err := a.StringSlice(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, argError(name, "must contain at least one value")
+	}
+	return values, nil
+}
+
+// Enum returns a string argument restricted to allowed values, or def if absent
+func (a Args) Enum(name string, allowed []string, def string) (string, error) {
+	s, err := a.String(name)
+	if err != nil {
+		return "", err
+	}
+	if s == "" {
+		return def, nil
+	}
+	for _, v := range allowed {
+		if s == v {
+			return s, nil
+		}
+	}
+	return "", argError(name, "must be one of %s, got %q", strings.Join(allowed, ", "), s)
+}
+
+// typeName describes a decoded JSON value for error messages
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
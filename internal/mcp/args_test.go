@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func parseArgs(t *testing.T, raw string) Args {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		t.Fatalf("Invalid test JSON: %v", err)
+	}
+	return Args(m)
+}
+
+func TestArgsInt(t *testing.T) {
+	a := parseArgs(t, `{"n": 5, "s": "7", "f": 1.5, "bad": "ten", "b": true}`)
+
+	if n, err := a.Int("n", 0); err != nil || n != 5 {
+		t.Errorf("Expected 5, got %d (%v)", n, err)
+	}
+	if n, err := a.Int("s", 0); err != nil || n != 7 {
+		t.Errorf("Expected numeric string to parse as 7, got %d (%v)", n, err)
+	}
+	if n, err := a.Int("missing", 10); err != nil || n != 10 {
+		t.Errorf("Expected default 10, got %d (%v)", n, err)
+	}
+	for _, name := range []string{"f", "bad", "b"} {
+		if _, err := a.Int(name, 0); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+	if _, err := parseArgs(t, `{"n": 0}`).PositiveInt("n", 10); err == nil {
+		t.Error("Expected error for non-positive value")
+	}
+}
+
+func TestArgsBool(t *testing.T) {
+	a := parseArgs(t, `{"t": true, "s": "true", "no": "no", "one": 1, "bad": "maybe"}`)
+
+	cases := map[string]bool{"t": true, "s": true, "no": false, "one": true, "missing": false}
+	for name, want := range cases {
+		got, err := a.Bool(name, false)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %v, got %v (%v)", name, want, got, err)
+		}
+	}
+	if _, err := a.Bool("bad", false); err == nil {
+		t.Error("Expected error for 'maybe'")
+	}
+}
+
+func TestArgsStringSlice(t *testing.T) {
+	a := parseArgs(t, `{"list": ["a", "b"], "single": "c", "mixed": ["a", 1], "empty": [], "obj": {}}`)
+
+	if v, err := a.StringSlice("list"); err != nil || len(v) != 2 {
+		t.Errorf("Expected 2 elements, got %v (%v)", v, err)
+	}
+	if v, err := a.StringSlice("single"); err != nil || len(v) != 1 || v[0] != "c" {
+		t.Errorf("Expected single string as one element, got %v (%v)", v, err)
+	}
+	if _, err := a.StringSlice("mixed"); err == nil {
+		t.Error("Expected error for non-string element")
+	}
+	if _, err := a.StringSlice("obj"); err == nil {
+		t.Error("Expected error for object")
+	}
+	if _, err := a.RequiredStringSlice("empty"); err == nil {
+		t.Error("Expected error for empty required array")
+	}
+	if _, err := a.RequiredStringSlice("missing"); err == nil {
+		t.Error("Expected error for missing required array")
+	}
+}
+
+func TestArgsRequiredStringAndEnum(t *testing.T) {
+	a := parseArgs(t, `{"id": "abc", "blank": "  ", "num": {}, "format": "xml"}`)
+
+	if v, err := a.RequiredString("id"); err != nil || v != "abc" {
+		t.Errorf("Expected abc, got %q (%v)", v, err)
+	}
+
+	_, err := a.RequiredString("missing")
+	var argErr *ArgError
+	if !errors.As(err, &argErr) || argErr.Name != "missing" {
+		t.Errorf("Expected ArgError naming the argument, got %v", err)
+	}
+	if _, err := a.RequiredString("blank"); err == nil {
+		t.Error("Expected error for blank string")
+	}
+	if _, err := a.String("num"); err == nil {
+		t.Error("Expected error for object value")
+	}
+
+	if _, err := a.Enum("format", []string{"text", "json"}, "text"); err == nil {
+		t.Error("Expected error for value outside enum")
+	}
+	if v, err := a.Enum("missing", []string{"text", "json"}, "text"); err != nil || v != "text" {
+		t.Errorf("Expected default text, got %q (%v)", v, err)
+	}
+}
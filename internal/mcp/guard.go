@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+// requireRecentCheckpoint refuses a high-risk operation on paths unless a
+// checkpoint created in the last s.requireCheckpoint backs them all up,
+// nudging agents to create a checkpoint before destroying anything. The
+// checkpoint with ID except, such as the one being deleted, doesn't count.
+func (s *Server) requireRecentCheckpoint(paths []string, except string) error {
+	if s.requireCheckpoint <= 0 {
+		return nil
+	}
+	checkpoints, err := checkpoint.List()
+	if err != nil {
+		return fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	var recent []*checkpoint.Checkpoint
+	since := time.Now().Add(-s.requireCheckpoint)
+	for _, cp := range checkpoints {
+		if cp.ID != except && cp.CreatedAt.After(since) {
+			recent = append(recent, cp)
+		}
+	}
+
+	missing := uncovered(paths, recent)
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(missing) > 5 {
+		missing = append(missing[:5], fmt.Sprintf("and %d more", len(missing)-5))
+	}
+	return fmt.Errorf(`refused: no checkpoint from the last %s backs up %s.
+
+Create one first with checkpoint_create, then retry.`,
+		s.requireCheckpoint, strings.Join(missing, ", "))
+}
+
+// uncovered returns the paths none of checkpoints backs up, either as
+// themselves or inside a directory they back up
+func uncovered(paths []string, checkpoints []*checkpoint.Checkpoint) []string {
+	var missing []string
+	for _, p := range paths {
+		if !backedUp(p, checkpoints) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+func backedUp(path string, checkpoints []*checkpoint.Checkpoint) bool {
+	for _, cp := range checkpoints {
+		for _, f := range cp.Manifest.Files {
+			if f.OriginalPath == path {
+				return true
+			}
+			if f.IsDir && strings.HasPrefix(path, f.OriginalPath+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
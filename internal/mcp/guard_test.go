@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+func TestUncovered(t *testing.T) {
+	checkpoints := []*checkpoint.Checkpoint{
+		{ID: "a", Manifest: &checkpoint.Manifest{Files: []checkpoint.FileEntry{
+			{OriginalPath: "/proj/src", IsDir: true},
+			{OriginalPath: "/proj/src/main.go"},
+		}}},
+		{ID: "b", Manifest: &checkpoint.Manifest{Files: []checkpoint.FileEntry{
+			{OriginalPath: "/proj/README.md"},
+		}}},
+	}
+
+	missing := uncovered([]string{
+		"/proj/src",
+		"/proj/src/deleted.go", // inside a directory backed up
+		"/proj/README.md",
+		"/proj/srcfile",     // not inside /proj/src
+		"/proj/README.md/x", // not a directory backup
+	}, checkpoints)
+
+	if len(missing) != 2 || missing[0] != "/proj/srcfile" || missing[1] != "/proj/README.md/x" {
+		t.Errorf("Expected /proj/srcfile and /proj/README.md/x uncovered, got %v", missing)
+	}
+}
+
+func TestRequireRecentCheckpointOff(t *testing.T) {
+	s, _ := testServer("")
+	if err := s.requireRecentCheckpoint([]string{"/nowhere"}, ""); err != nil {
+		t.Errorf("Expected no check when not configured, got %v", err)
+	}
+}
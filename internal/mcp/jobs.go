@@ -0,0 +1,267 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// maxFinishedJobs bounds how many completed jobs are kept for status queries
+const maxFinishedJobs = 100
+
+// Job is a tool call running in the background
+type Job struct {
+	ID         string
+	Tool       string
+	Status     string
+	Result     string
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	args    map[string]interface{}
+	handler ToolHandler
+}
+
+// JobManager runs long tool calls (create, compress, rollback) one at a time
+// in the background, so clients with short tool timeouts can poll for the
+// result instead of abandoning the operation midway.
+//
+// Jobs run sequentially because they all modify the checkpoint store.
+// A queued job can be canceled; a running one cannot, since stopping a
+// rollback or checkpoint partway would leave files half restored or a
+// checkpoint half written.
+type JobManager struct {
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	queue  chan *Job
+	nextID int
+}
+
+func newJobManager() *JobManager {
+	m := &JobManager{
+		jobs:  make(map[string]*Job),
+		queue: make(chan *Job, 256),
+	}
+	go m.worker()
+	return m
+}
+
+// Submit queues handler(args) and returns the new job
+func (m *JobManager) Submit(tool string, handler ToolHandler, args map[string]interface{}) (*Job, error) {
+	m.mu.Lock()
+	m.nextID++
+	job := &Job{
+		ID:        fmt.Sprintf("job-%d", m.nextID),
+		Tool:      tool,
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+		args:      args,
+		handler:   handler,
+	}
+	m.jobs[job.ID] = job
+	m.pruneLocked()
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- job:
+		return job, nil
+	default:
+		m.finish(job, "", fmt.Errorf("job queue is full"))
+		return nil, fmt.Errorf("too many queued jobs, try again later")
+	}
+}
+
+// Get returns a snapshot of the job, or false if it is unknown
+func (m *JobManager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns snapshots of all known jobs, oldest first
+func (m *JobManager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel cancels a queued job
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	switch job.Status {
+	case JobQueued:
+		job.Status = JobCanceled
+		job.FinishedAt = time.Now()
+		return nil
+	case JobRunning:
+		return fmt.Errorf("job %s is already running and cannot be stopped safely; wait for it to finish", id)
+	default:
+		return fmt.Errorf("job %s has already finished (%s)", id, job.Status)
+	}
+}
+
+func (m *JobManager) worker() {
+	for job := range m.queue {
+		m.mu.Lock()
+		if job.Status != JobQueued {
+			m.mu.Unlock()
+			continue
+		}
+		job.Status = JobRunning
+		job.StartedAt = time.Now()
+		m.mu.Unlock()
+
+		result, err := job.handler(job.args)
+		m.finish(job, result, err)
+	}
+}
+
+func (m *JobManager) finish(job *Job, result string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.FinishedAt = time.Now()
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = JobSucceeded
+	job.Result = result
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs
+func (m *JobManager) pruneLocked() {
+	var finished []*Job
+	for _, job := range m.jobs {
+		if !job.FinishedAt.IsZero() {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, job.ID)
+	}
+}
+
+// async wraps a tool so that async=true queues it as a job and returns the
+// job ID immediately
+func (s *Server) async(name string, handler ToolHandler) ToolHandler {
+	return func(args map[string]interface{}) (string, error) {
+		a := Args(args)
+		runAsync, err := a.Bool("async", false)
+		if err != nil {
+			return "", err
+		}
+		if !runAsync {
+			return handler(args)
+		}
+
+		job, err := s.jobs.Submit(name, handler, args)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`Job queued.
+
+Job ID: %s
+Tool: %s
+
+Poll checkpoint_job_status with job_id="%s" for the result.`, job.ID, name, job.ID), nil
+	}
+}
+
+func (s *Server) toolJobStatus(args map[string]interface{}) (string, error) {
+	a := Args(args)
+	id, err := a.String("job_id")
+	if err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		jobs := s.jobs.List()
+		if len(jobs) == 0 {
+			return "No jobs.", nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString("| Job | Tool | Status | Created |\n")
+		sb.WriteString("|-----|------|--------|--------|\n")
+		for _, job := range jobs {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
+				job.ID, job.Tool, job.Status, job.CreatedAt.Format("15:04:05")))
+		}
+		return sb.String(), nil
+	}
+
+	job, ok := s.jobs.Get(id)
+	if !ok {
+		return "", fmt.Errorf("job not found: %s", id)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Job: %s\nTool: %s\nStatus: %s\n", job.ID, job.Tool, job.Status))
+	switch job.Status {
+	case JobQueued:
+		sb.WriteString("Waiting for earlier jobs to finish.")
+	case JobRunning:
+		sb.WriteString(fmt.Sprintf("Running for %s.", time.Since(job.StartedAt).Round(time.Second)))
+	case JobSucceeded:
+		sb.WriteString(fmt.Sprintf("Duration: %s\n\n%s", job.FinishedAt.Sub(job.StartedAt).Round(time.Millisecond), job.Result))
+	case JobFailed:
+		sb.WriteString("Error: " + job.Error)
+	case JobCanceled:
+		sb.WriteString("Canceled before it started; nothing was changed.")
+	}
+	return sb.String(), nil
+}
+
+func (s *Server) toolJobCancel(args map[string]interface{}) (string, error) {
+	id, err := Args(args).RequiredString("job_id")
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.jobs.Cancel(id); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Job %s canceled.", id), nil
+}
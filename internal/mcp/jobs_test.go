@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForStatus polls until the job reaches status or the test times out
+func waitForStatus(t *testing.T, m *JobManager, id, status string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := m.Get(id); ok && job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("Job %s did not reach %s (last status %s)", id, status, job.Status)
+	return job
+}
+
+func TestJobManagerQueueAndCancel(t *testing.T) {
+	m := newJobManager()
+
+	release := make(chan struct{})
+	blocking := func(args map[string]interface{}) (string, error) {
+		<-release
+		return "done", nil
+	}
+	failing := func(args map[string]interface{}) (string, error) {
+		return "", errors.New("boom")
+	}
+
+	first, err := m.Submit("checkpoint_create", blocking, nil)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	second, _ := m.Submit("checkpoint_compress", blocking, nil)
+	third, _ := m.Submit("checkpoint_rollback", failing, nil)
+
+	waitForStatus(t, m, first.ID, JobRunning)
+
+	if err := m.Cancel(first.ID); err == nil {
+		t.Error("Canceling a running job should fail")
+	}
+	if err := m.Cancel(second.ID); err != nil {
+		t.Errorf("Canceling a queued job failed: %v", err)
+	}
+	if err := m.Cancel("job-999"); err == nil {
+		t.Error("Canceling an unknown job should fail")
+	}
+
+	close(release)
+
+	if job := waitForStatus(t, m, first.ID, JobSucceeded); job.Result != "done" {
+		t.Errorf("Expected result 'done', got %q", job.Result)
+	}
+	if job := waitForStatus(t, m, third.ID, JobFailed); job.Error != "boom" {
+		t.Errorf("Expected error 'boom', got %q", job.Error)
+	}
+	if job, _ := m.Get(second.ID); job.Status != JobCanceled || !job.StartedAt.IsZero() {
+		t.Errorf("Canceled job should never start, got %+v", job)
+	}
+
+	if jobs := m.List(); len(jobs) != 3 || jobs[0].ID != first.ID {
+		t.Errorf("Expected 3 jobs oldest first, got %+v", jobs)
+	}
+}
+
+func TestAsyncToolReturnsJobID(t *testing.T) {
+	s, _ := testServer("")
+
+	handler := s.async("checkpoint_status", func(args map[string]interface{}) (string, error) {
+		return "finished", nil
+	})
+
+	out, err := handler(map[string]interface{}{"async": true})
+	if err != nil {
+		t.Fatalf("Async call failed: %v", err)
+	}
+	if !strings.Contains(out, "Job ID: job-1") {
+		t.Fatalf("Expected job ID in response, got:\n%s", out)
+	}
+
+	waitForStatus(t, s.jobs, "job-1", JobSucceeded)
+	status, err := s.toolJobStatus(map[string]interface{}{"job_id": "job-1"})
+	if err != nil || !strings.Contains(status, "finished") {
+		t.Errorf("Expected job result in status, got %q (%v)", status, err)
+	}
+
+	// Without async the tool runs inline
+	if out, _ := handler(map[string]interface{}{}); out != "finished" {
+		t.Errorf("Expected inline result, got %q", out)
+	}
+
+	if _, err := handler(map[string]interface{}{"async": "maybe"}); err == nil {
+		t.Error("Invalid async value should be rejected")
+	}
+}
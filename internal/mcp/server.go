@@ -3,10 +3,14 @@ package mcp
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sync"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
 )
 
 const (
@@ -20,6 +24,12 @@ type Server struct {
 	writer  io.Writer
 	mu      sync.Mutex
 	tools   map[string]ToolHandler
+	hidden  map[string]bool // registered tools removed by configuration
+	jobs    *JobManager
+
+	// requireCheckpoint is how recent a checkpoint of the affected paths
+	// high-risk tools require; 0 requires none
+	requireCheckpoint time.Duration
 }
 
 type ToolHandler func(args map[string]interface{}) (string, error)
@@ -31,6 +41,10 @@ func NewServer() *Server {
 		tools:  make(map[string]ToolHandler),
 	}
 	s.registerTools()
+
+	cfg := config.Get()
+	s.filterTools(cfg.MCPEnabledTools, cfg.MCPDisabledTools)
+	s.requireCheckpoint = time.Duration(cfg.MCPRequireCheckpointMinutes) * time.Minute
 	return s
 }
 
@@ -93,6 +107,10 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
+					"async": {
+						Type:        "boolean",
+						Description: "Run in the background and return a job ID immediately. Poll checkpoint_job_status for the result.",
+					},
 					"paths": {
 						Type:        "array",
 						Description: "List of file or directory paths to backup",
@@ -102,6 +120,15 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 						Type:        "string",
 						Description: "Reason for creating checkpoint (e.g., 'before deleting build folder')",
 					},
+					"name": {
+						Type:        "string",
+						Description: "Optional unique name to refer to the checkpoint by instead of its ID (e.g., 'pre-migration'): letters, digits, '.', '_' and '-'",
+					},
+					"include": {
+						Type:        "array",
+						Description: "Only back up files matching one of these glob patterns, or in a directory that does (e.g., ['*.go', 'migrations/*']), for very large directories. Defaults to include_paths from the config.",
+						Items:       &Items{Type: "string"},
+					},
 				},
 				Required: []string{"paths"},
 			},
@@ -113,7 +140,7 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 				Type: "object",
 				Properties: map[string]Property{
 					"limit": {
-						Type:        "string",
+						Type:        "integer",
 						Description: "Maximum number of checkpoints to return (default: 10)",
 					},
 					"session": {
@@ -129,13 +156,17 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
+					"async": {
+						Type:        "boolean",
+						Description: "Run in the background and return a job ID immediately. Poll checkpoint_job_status for the result.",
+					},
 					"id": {
 						Type:        "string",
-						Description: "Checkpoint ID to rollback to. Use 'latest' for most recent checkpoint.",
+						Description: "Checkpoint ID or name to rollback to. Use 'latest' for most recent checkpoint.",
 					},
 					"files": {
 						Type:        "array",
-						Description: "Optional: restore only specific files (array of file paths). If omitted, restores all files.",
+						Description: "Optional: restore only specific files. Each entry is a path, a directory (every file under it) or a glob where ** spans directories (e.g. \"src/**/*.go\"); relative entries are taken from the directory the checkpoint was created in. If omitted, restores all files.",
 						Items:       &Items{Type: "string"},
 					},
 				},
@@ -144,7 +175,7 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 		},
 		{
 			Name:        "checkpoint_status",
-			Description: "Get SafeShell status including total checkpoints, storage used, and configuration.",
+			Description: "Get SafeShell status including total checkpoints, storage used, and configuration, with counts and storage by session and by tag and the 5 largest checkpoints, to decide what to compress or delete.",
 			InputSchema: InputSchema{
 				Type:       "object",
 				Properties: map[string]Property{},
@@ -152,13 +183,13 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 		},
 		{
 			Name:        "checkpoint_delete",
-			Description: "Delete a specific checkpoint by ID.",
+			Description: "Delete a specific checkpoint by ID. SafeShell may be configured to refuse unless a recent checkpoint of the same paths exists; create one with checkpoint_create first.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"id": {
 						Type:        "string",
-						Description: "Checkpoint ID to delete",
+						Description: "Checkpoint ID or name to delete",
 					},
 				},
 				Required: []string{"id"},
@@ -172,7 +203,15 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 				Properties: map[string]Property{
 					"id": {
 						Type:        "string",
-						Description: "Checkpoint ID to compare. Use 'latest' for most recent checkpoint.",
+						Description: "Checkpoint ID or name to compare. Use 'latest' for most recent checkpoint.",
+					},
+					"summary": {
+						Type:        "boolean",
+						Description: "Return a compact summary clustering changes by directory and file type, with a few example files per group. Use this to keep large diffs within your context budget.",
+					},
+					"max_bytes": {
+						Type:        "integer",
+						Description: "Maximum size of the summary in bytes, roughly 4 bytes per token (default: 2000)",
 					},
 				},
 				Required: []string{"id"},
@@ -180,13 +219,13 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 		},
 		{
 			Name:        "checkpoint_tag",
-			Description: "Add or remove tags from a checkpoint for better organization.",
+			Description: "Add or remove tags from a checkpoint for better organization, set its note or name it.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"id": {
 						Type:        "string",
-						Description: "Checkpoint ID to tag. Use 'latest' for most recent checkpoint.",
+						Description: "Checkpoint ID or name to tag. Use 'latest' for most recent checkpoint.",
 					},
 					"tag": {
 						Type:        "string",
@@ -200,6 +239,10 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 						Type:        "string",
 						Description: "Set a note for the checkpoint (optional)",
 					},
+					"name": {
+						Type:        "string",
+						Description: "Give the checkpoint a unique name to refer to it by instead of its ID (optional)",
+					},
 				},
 				Required: []string{"id"},
 			},
@@ -231,9 +274,13 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
+					"async": {
+						Type:        "boolean",
+						Description: "Run in the background and return a job ID immediately. Poll checkpoint_job_status for the result.",
+					},
 					"id": {
 						Type:        "string",
-						Description: "Checkpoint ID to compress. Use 'latest' for most recent, or 'all' to compress all uncompressed checkpoints.",
+						Description: "Checkpoint ID or name to compress. Use 'latest' for most recent, or 'all' to compress all uncompressed checkpoints.",
 					},
 					"older_than": {
 						Type:        "string",
@@ -250,15 +297,50 @@ func (s *Server) handleListTools(req *JSONRPCRequest) {
 				Properties: map[string]Property{
 					"id": {
 						Type:        "string",
-						Description: "Checkpoint ID to decompress. Use 'latest' for most recent.",
+						Description: "Checkpoint ID or name to decompress. Use 'latest' for most recent.",
 					},
 				},
 				Required: []string{"id"},
 			},
 		},
+		{
+			Name:        "checkpoint_job_status",
+			Description: "Get the status and result of a background job started with async=true. Omit job_id to list all jobs.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"job_id": {
+						Type:        "string",
+						Description: "Job ID returned when the job was queued",
+					},
+				},
+			},
+		},
+		{
+			Name:        "checkpoint_job_cancel",
+			Description: "Cancel a background job that has not started yet. Running jobs cannot be stopped partway.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"job_id": {
+						Type:        "string",
+						Description: "Job ID to cancel",
+					},
+				},
+				Required: []string{"job_id"},
+			},
+		},
 	}
 
-	s.sendResult(req.ID, ListToolsResult{Tools: tools})
+	// Only advertise tools that are exposed
+	exposed := make([]Tool, 0, len(tools))
+	for _, t := range tools {
+		if _, ok := s.tools[t.Name]; ok {
+			exposed = append(exposed, t)
+		}
+	}
+
+	s.sendResult(req.ID, ListToolsResult{Tools: exposed})
 }
 
 func (s *Server) handleCallTool(req *JSONRPCRequest) {
@@ -276,12 +358,21 @@ func (s *Server) handleCallTool(req *JSONRPCRequest) {
 
 	handler, ok := s.tools[params.Name]
 	if !ok {
+		if s.hidden[params.Name] {
+			s.sendToolError(req.ID, fmt.Sprintf("Tool %s is disabled by the SafeShell configuration", params.Name))
+			return
+		}
 		s.sendToolError(req.ID, fmt.Sprintf("Unknown tool: %s", params.Name))
 		return
 	}
 
 	result, err := handler(params.Arguments)
 	if err != nil {
+		var argErr *ArgError
+		if errors.As(err, &argErr) {
+			s.sendError(req.ID, -32602, "Invalid params", argErr.Error())
+			return
+		}
 		s.sendToolError(req.ID, err.Error())
 		return
 	}
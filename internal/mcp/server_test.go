@@ -251,3 +251,67 @@ func BenchmarkHandleListTools(b *testing.B) {
 		s.Run()
 	}
 }
+
+func TestHandleCallToolInvalidArguments(t *testing.T) {
+	request := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"checkpoint_create","arguments":{"paths":[1,2]}}}` + "\n"
+	s, output := testServer(request)
+
+	s.Run()
+
+	var resp JSONRPCResponse
+	if err := json.Unmarshal(output.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if resp.Error == nil {
+		t.Fatal("Expected JSON-RPC error for invalid arguments")
+	}
+	if resp.Error.Code != -32602 {
+		t.Errorf("Expected invalid params code -32602, got %d", resp.Error.Code)
+	}
+	if data, _ := resp.Error.Data.(string); !strings.Contains(data, "paths") {
+		t.Errorf("Expected error to name the argument, got %v", resp.Error.Data)
+	}
+}
+
+func TestFilterTools(t *testing.T) {
+	request := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"checkpoint_delete","arguments":{"id":"x"}}}` + "\n"
+	s, output := testServer(request)
+	s.filterTools([]string{"checkpoint_list", "checkpoint_delete", "checkpoint_status"}, []string{"checkpoint_delete"})
+
+	if len(s.tools) != 2 {
+		t.Errorf("Expected 2 exposed tools, got %d", len(s.tools))
+	}
+
+	s.Run()
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(lines))
+	}
+
+	var listResp struct {
+		Result ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &listResp); err != nil {
+		t.Fatalf("Failed to parse tools/list response: %v", err)
+	}
+	var names []string
+	for _, tool := range listResp.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if len(names) != 2 || names[0] != "checkpoint_list" || names[1] != "checkpoint_status" {
+		t.Errorf("Expected only checkpoint_list and checkpoint_status to be listed, got %v", names)
+	}
+
+	var callResp struct {
+		Result CallToolResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &callResp); err != nil {
+		t.Fatalf("Failed to parse tools/call response: %v", err)
+	}
+	if !callResp.Result.IsError || !strings.Contains(callResp.Result.Content[0].Text, "disabled") {
+		t.Errorf("Expected disabled tool error, got %+v", callResp.Result)
+	}
+}
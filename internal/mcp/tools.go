@@ -3,52 +3,87 @@ package mcp
 import (
 	"fmt"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/qhkm/safeshell/internal/checkpoint"
 	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/output"
 	"github.com/qhkm/safeshell/internal/rollback"
-	"github.com/qhkm/safeshell/internal/util"
 )
 
 func (s *Server) registerTools() {
-	s.tools["checkpoint_create"] = s.toolCheckpointCreate
+	s.jobs = newJobManager()
+
+	s.tools["checkpoint_create"] = s.async("checkpoint_create", s.toolCheckpointCreate)
 	s.tools["checkpoint_list"] = s.toolCheckpointList
-	s.tools["checkpoint_rollback"] = s.toolCheckpointRollback
+	s.tools["checkpoint_rollback"] = s.async("checkpoint_rollback", s.toolCheckpointRollback)
 	s.tools["checkpoint_status"] = s.toolCheckpointStatus
 	s.tools["checkpoint_delete"] = s.toolCheckpointDelete
 	s.tools["checkpoint_diff"] = s.toolCheckpointDiff
 	s.tools["checkpoint_tag"] = s.toolCheckpointTag
 	s.tools["checkpoint_search"] = s.toolCheckpointSearch
-	s.tools["checkpoint_compress"] = s.toolCheckpointCompress
+	s.tools["checkpoint_compress"] = s.async("checkpoint_compress", s.toolCheckpointCompress)
 	s.tools["checkpoint_decompress"] = s.toolCheckpointDecompress
+	s.tools["checkpoint_job_status"] = s.toolJobStatus
+	s.tools["checkpoint_job_cancel"] = s.toolJobCancel
 }
 
-func (s *Server) toolCheckpointCreate(args map[string]interface{}) (string, error) {
-	// Parse paths
-	pathsRaw, ok := args["paths"]
-	if !ok {
-		return "", fmt.Errorf("missing required argument: paths")
+// filterTools restricts the registered tools to the exposed set. An empty
+// enabled list exposes every tool; disabled tools are always removed.
+// Unknown names are reported on stderr so typos don't silently expose tools.
+func (s *Server) filterTools(enabled, disabled []string) {
+	for _, name := range append(append([]string{}, enabled...), disabled...) {
+		if _, ok := s.tools[name]; !ok {
+			fmt.Fprintf(os.Stderr, "Warning: unknown MCP tool in config: %s\n", name)
+		}
+	}
+
+	keep := func(name string) bool {
+		for _, d := range disabled {
+			if d == name {
+				return false
+			}
+		}
+		if len(enabled) == 0 {
+			return true
+		}
+		for _, e := range enabled {
+			if e == name {
+				return true
+			}
+		}
+		return false
 	}
 
-	pathsArray, ok := pathsRaw.([]interface{})
-	if !ok {
-		return "", fmt.Errorf("paths must be an array of strings")
+	s.hidden = make(map[string]bool)
+	for name := range s.tools {
+		if !keep(name) {
+			delete(s.tools, name)
+			s.hidden[name] = true
+		}
+	}
+}
+
+func (s *Server) toolCheckpointCreate(args map[string]interface{}) (string, error) {
+	a := Args(args)
+
+	// Parse paths
+	pathsArg, err := a.RequiredStringSlice("paths")
+	if err != nil {
+		return "", err
 	}
 
 	var paths []string
 	var invalidPaths []string
-	for _, p := range pathsArray {
-		if str, ok := p.(string); ok {
-			// Validate each path
-			if err := checkpoint.ValidatePath(str); err != nil {
-				invalidPaths = append(invalidPaths, fmt.Sprintf("%s: %v", str, err))
-				continue
-			}
-			paths = append(paths, str)
+	for _, str := range pathsArg {
+		// Validate each path
+		if err := checkpoint.ValidatePath(str); err != nil {
+			invalidPaths = append(invalidPaths, fmt.Sprintf("%s: %v", str, err))
+			continue
 		}
+		paths = append(paths, str)
 	}
 
 	// Report invalid paths
@@ -61,13 +96,29 @@ func (s *Server) toolCheckpointCreate(args map[string]interface{}) (string, erro
 	}
 
 	// Get reason
-	reason := "MCP checkpoint"
-	if r, ok := args["reason"].(string); ok && r != "" {
-		reason = r
+	reason, err := a.String("reason")
+	if err != nil {
+		return "", err
+	}
+	if reason == "" {
+		reason = "MCP checkpoint"
+	}
+	name, err := a.String("name")
+	if err != nil {
+		return "", err
+	}
+	include, err := a.StringSlice("include")
+	if err != nil {
+		return "", err
 	}
 
 	// Create checkpoint
-	cp, err := checkpoint.Create(reason, paths)
+	var cp *checkpoint.Checkpoint
+	if name != "" {
+		cp, err = checkpoint.CreateNamed(name, reason, paths, include)
+	} else {
+		cp, err = checkpoint.CreateIncluding(reason, paths, include)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to create checkpoint: %w", err)
 	}
@@ -80,9 +131,14 @@ func (s *Server) toolCheckpointCreate(args map[string]interface{}) (string, erro
 		}
 	}
 
+	ref := cp.ID
+	if name != "" {
+		ref = name
+	}
+
 	return fmt.Sprintf(`Checkpoint created successfully!
 
-ID: %s
+ID: %s%s
 Time: %s
 Reason: %s
 Files backed up: %d
@@ -90,30 +146,30 @@ Paths: %s
 
 To rollback, use: checkpoint_rollback with id="%s" or id="latest"`,
 		cp.ID,
+		nameLine(cp),
 		cp.CreatedAt.Format("2006-01-02 15:04:05"),
 		reason,
 		fileCount,
 		strings.Join(paths, ", "),
-		cp.ID,
+		ref,
 	), nil
 }
 
 func (s *Server) toolCheckpointList(args map[string]interface{}) (string, error) {
-	limit := 10
-	if l, ok := args["limit"].(string); ok {
-		if parsed, err := strconv.Atoi(l); err == nil {
-			limit = parsed
-		}
+	a := Args(args)
+
+	limit, err := a.PositiveInt("limit", 10)
+	if err != nil {
+		return "", err
 	}
 
 	// Check for session filter
-	sessionOnly := false
-	if s, ok := args["session"].(bool); ok && s {
-		sessionOnly = true
+	sessionOnly, err := a.Bool("session", false)
+	if err != nil {
+		return "", err
 	}
 
 	var checkpoints []*checkpoint.Checkpoint
-	var err error
 
 	if sessionOnly {
 		checkpoints, err = checkpoint.GetCurrentSession()
@@ -150,7 +206,7 @@ func (s *Server) toolCheckpointList(args map[string]interface{}) (string, error)
 			}
 		}
 
-		timeAgo := util.FormatTimeAgo(cp.CreatedAt)
+		timeAgo := output.FormatTimeAgo(cp.CreatedAt)
 		reason := cp.Manifest.Command
 		if len(reason) > 30 {
 			reason = reason[:27] + "..."
@@ -161,8 +217,12 @@ func (s *Server) toolCheckpointList(args map[string]interface{}) (string, error)
 			status = " (rolled back)"
 		}
 
+		id := cp.ID
+		if cp.Manifest.Name != "" {
+			id += " (" + cp.Manifest.Name + ")"
+		}
 		sb.WriteString(fmt.Sprintf("| %s | %s | %d | %s%s |\n",
-			cp.ID, timeAgo, fileCount, reason, status))
+			id, timeAgo, fileCount, reason, status))
 	}
 
 	if len(checkpoints) > limit {
@@ -175,13 +235,14 @@ func (s *Server) toolCheckpointList(args map[string]interface{}) (string, error)
 }
 
 func (s *Server) toolCheckpointRollback(args map[string]interface{}) (string, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return "", fmt.Errorf("missing required argument: id")
+	a := Args(args)
+
+	id, err := a.RequiredString("id")
+	if err != nil {
+		return "", err
 	}
 
 	var cp *checkpoint.Checkpoint
-	var err error
 
 	if id == "latest" {
 		cp, err = checkpoint.GetLatest()
@@ -189,9 +250,9 @@ func (s *Server) toolCheckpointRollback(args map[string]interface{}) (string, er
 			return "", fmt.Errorf("no checkpoints found")
 		}
 	} else {
-		cp, err = checkpoint.Get(id)
+		cp, err = getCheckpoint(id)
 		if err != nil {
-			return "", fmt.Errorf("checkpoint not found: %s", id)
+			return "", err
 		}
 	}
 
@@ -200,14 +261,16 @@ func (s *Server) toolCheckpointRollback(args map[string]interface{}) (string, er
 	}
 
 	// Check for selective file restore
+	patterns, err := a.StringSlice("files")
+	if err != nil {
+		return "", err
+	}
 	var filesToRestore []string
-	if filesRaw, ok := args["files"]; ok && filesRaw != nil {
-		if filesArray, ok := filesRaw.([]interface{}); ok {
-			for _, f := range filesArray {
-				if str, ok := f.(string); ok {
-					filesToRestore = append(filesToRestore, str)
-				}
-			}
+	if len(patterns) > 0 {
+		var unmatched []string
+		filesToRestore, unmatched = checkpoint.SelectFiles(cp, patterns, cp.Manifest.WorkingDir)
+		if len(filesToRestore) == 0 {
+			return "", fmt.Errorf("no files in checkpoint %s match %s", cp.ID, strings.Join(unmatched, ", "))
 		}
 	}
 
@@ -264,10 +327,13 @@ func (s *Server) toolCheckpointStatus(args map[string]interface{}) (string, erro
 	var totalSize int64
 	var totalFiles int
 	rolledBack := 0
+	sizes := make(map[string]int64, len(checkpoints))
 
 	for _, cp := range checkpoints {
-		size, _ := checkpoint.GetDiskUsage(cp.FilesDir)
+		// The whole directory, so compressed archives count too
+		size, _ := checkpoint.GetDiskUsage(cp.Dir)
 		totalSize += size
+		sizes[cp.ID] = size
 
 		for _, f := range cp.Manifest.Files {
 			if !f.IsDir {
@@ -288,7 +354,7 @@ func (s *Server) toolCheckpointStatus(args map[string]interface{}) (string, erro
 	sb.WriteString(fmt.Sprintf("Max checkpoints: %d\n\n", cfg.MaxCheckpoints))
 	sb.WriteString(fmt.Sprintf("Total checkpoints: %d\n", len(checkpoints)))
 	sb.WriteString(fmt.Sprintf("Total files backed up: %d\n", totalFiles))
-	sb.WriteString(fmt.Sprintf("Storage used: %s\n", util.FormatBytes(totalSize)))
+	sb.WriteString(fmt.Sprintf("Storage used: %s\n", output.FormatBytes(totalSize)))
 	sb.WriteString(fmt.Sprintf("Rolled back: %d\n", rolledBack))
 
 	if len(checkpoints) > 0 {
@@ -296,26 +362,116 @@ func (s *Server) toolCheckpointStatus(args map[string]interface{}) (string, erro
 		sb.WriteString(fmt.Sprintf("\nLatest checkpoint:\n"))
 		sb.WriteString(fmt.Sprintf("  ID: %s\n", latest.ID))
 		sb.WriteString(fmt.Sprintf("  Reason: %s\n", latest.Manifest.Command))
-		sb.WriteString(fmt.Sprintf("  Time: %s\n", util.FormatTimeAgo(latest.CreatedAt)))
+		sb.WriteString(fmt.Sprintf("  Time: %s\n", output.FormatTimeAgo(latest.CreatedAt)))
+
+		bySession, byTag := usageGroups(checkpoints, sizes)
+		writeUsage(&sb, "By session", bySession)
+		writeUsage(&sb, "By tag", byTag)
+
+		sb.WriteString("\nLargest checkpoints:\n")
+		for _, cp := range largestCheckpoints(checkpoints, sizes, 5) {
+			state := ""
+			if cp.Manifest.Compressed {
+				state = " (compressed)"
+			}
+			sb.WriteString(fmt.Sprintf("  %s  %s  %s  %s%s\n",
+				cp.ID, output.FormatBytes(sizes[cp.ID]), output.FormatTimeAgo(cp.CreatedAt), cp.Manifest.Command, state))
+		}
 	}
 
 	return sb.String(), nil
 }
 
+// usage is the number and size of checkpoints in a group
+type usage struct {
+	Name  string
+	Count int
+	Size  int64
+}
+
+// usageGroups totals checkpoints by session and by tag, largest first. A
+// checkpoint counts towards each of its tags; untagged ones are grouped too.
+func usageGroups(checkpoints []*checkpoint.Checkpoint, sizes map[string]int64) (bySession, byTag []usage) {
+	sessions := make(map[string]*usage)
+	tags := make(map[string]*usage)
+	add := func(groups map[string]*usage, name string, cp *checkpoint.Checkpoint) {
+		if groups[name] == nil {
+			groups[name] = &usage{Name: name}
+		}
+		groups[name].Count++
+		groups[name].Size += sizes[cp.ID]
+	}
+
+	for _, cp := range checkpoints {
+		session := cp.Manifest.SessionID
+		if session == "" {
+			session = "default"
+		}
+		add(sessions, session, cp)
+
+		if len(cp.Manifest.Tags) == 0 {
+			add(tags, "(untagged)", cp)
+		}
+		for _, tag := range cp.Manifest.Tags {
+			add(tags, tag, cp)
+		}
+	}
+	return sortedUsage(sessions), sortedUsage(tags)
+}
+
+func sortedUsage(groups map[string]*usage) []usage {
+	sorted := make([]usage, 0, len(groups))
+	for _, u := range groups {
+		sorted = append(sorted, *u)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Size != sorted[j].Size {
+			return sorted[i].Size > sorted[j].Size
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func writeUsage(sb *strings.Builder, title string, groups []usage) {
+	sb.WriteString(fmt.Sprintf("\n%s:\n", title))
+	for _, u := range groups {
+		sb.WriteString(fmt.Sprintf("  %s: %d checkpoint(s), %s\n", u.Name, u.Count, output.FormatBytes(u.Size)))
+	}
+}
+
+// largestCheckpoints returns the n checkpoints using the most storage
+func largestCheckpoints(checkpoints []*checkpoint.Checkpoint, sizes map[string]int64, n int) []*checkpoint.Checkpoint {
+	largest := append([]*checkpoint.Checkpoint(nil), checkpoints...)
+	sort.SliceStable(largest, func(i, j int) bool {
+		return sizes[largest[i].ID] > sizes[largest[j].ID]
+	})
+	return largest[:min(n, len(largest))]
+}
+
 func (s *Server) toolCheckpointDelete(args map[string]interface{}) (string, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return "", fmt.Errorf("missing required argument: id")
+	id, err := Args(args).RequiredString("id")
+	if err != nil {
+		return "", err
 	}
 
 	// Verify checkpoint exists
-	cp, err := checkpoint.Get(id)
+	cp, err := getCheckpoint(id)
 	if err != nil {
-		return "", fmt.Errorf("checkpoint not found: %s", id)
+		return "", err
+	}
+
+	// Its backups may be the only copy left, so require a newer one
+	var paths []string
+	for _, f := range cp.Manifest.Files {
+		paths = append(paths, f.OriginalPath)
+	}
+	if err := s.requireRecentCheckpoint(paths, cp.ID); err != nil {
+		return "", err
 	}
 
 	// Delete
-	if err := checkpoint.Delete(id); err != nil {
+	if err := checkpoint.Delete(cp.ID); err != nil {
 		return "", fmt.Errorf("failed to delete checkpoint: %w", err)
 	}
 
@@ -323,13 +479,23 @@ func (s *Server) toolCheckpointDelete(args map[string]interface{}) (string, erro
 }
 
 func (s *Server) toolCheckpointDiff(args map[string]interface{}) (string, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return "", fmt.Errorf("missing required argument: id")
+	a := Args(args)
+
+	id, err := a.RequiredString("id")
+	if err != nil {
+		return "", err
+	}
+
+	summary, err := a.Bool("summary", false)
+	if err != nil {
+		return "", err
+	}
+	maxBytes, err := a.PositiveInt("max_bytes", 2000)
+	if err != nil {
+		return "", err
 	}
 
 	var cp *checkpoint.Checkpoint
-	var err error
 
 	if id == "latest" {
 		cp, err = checkpoint.GetLatest()
@@ -337,9 +503,9 @@ func (s *Server) toolCheckpointDiff(args map[string]interface{}) (string, error)
 			return "", fmt.Errorf("no checkpoints found")
 		}
 	} else {
-		cp, err = checkpoint.Get(id)
+		cp, err = getCheckpoint(id)
 		if err != nil {
-			return "", fmt.Errorf("checkpoint not found: %s", id)
+			return "", err
 		}
 	}
 
@@ -352,33 +518,37 @@ func (s *Server) toolCheckpointDiff(args map[string]interface{}) (string, error)
 		sb.WriteString("⚠ This checkpoint has already been rolled back\n\n")
 	}
 
+	diffs := checkpoint.Compare(cp)
+
+	if summary {
+		// The header above counts against the budget
+		sb.WriteString(checkpoint.SummarizeDiffs(diffs).Render(max(maxBytes-sb.Len(), 1)))
+		return sb.String(), nil
+	}
+
+	diffs = checkpoint.DetectRenames(cp, diffs)
 	deleted := 0
 	modified := 0
+	renamed := 0
 	unchanged := 0
 
 	var deletedFiles []string
 	var modifiedFiles []string
+	var renamedFiles []string
 
-	for _, f := range cp.Manifest.Files {
-		if f.IsDir {
-			continue
-		}
-
-		info, err := os.Stat(f.OriginalPath)
-		if os.IsNotExist(err) {
+	for _, d := range diffs {
+		switch d.Status {
+		case checkpoint.DiffDeleted:
 			deleted++
-			deletedFiles = append(deletedFiles, f.OriginalPath)
-		} else if err != nil {
-			deleted++
-			deletedFiles = append(deletedFiles, f.OriginalPath)
-		} else {
-			// Compare sizes as quick check
-			if info.Size() != f.Size {
-				modified++
-				modifiedFiles = append(modifiedFiles, f.OriginalPath)
-			} else {
-				unchanged++
-			}
+			deletedFiles = append(deletedFiles, d.Path)
+		case checkpoint.DiffModified:
+			modified++
+			modifiedFiles = append(modifiedFiles, d.Path)
+		case checkpoint.DiffRenamed:
+			renamed++
+			renamedFiles = append(renamedFiles, fmt.Sprintf("%s (renamed to %s)", d.Path, d.RenamedTo))
+		default:
+			unchanged++
 		}
 	}
 
@@ -389,12 +559,15 @@ func (s *Server) toolCheckpointDiff(args map[string]interface{}) (string, error)
 	if modified > 0 {
 		sb.WriteString(fmt.Sprintf("  • %d file(s) modified - will be reverted\n", modified))
 	}
+	if renamed > 0 {
+		sb.WriteString(fmt.Sprintf("  • %d file(s) renamed or moved - will be restored at the old path\n", renamed))
+	}
 	if unchanged > 0 {
 		sb.WriteString(fmt.Sprintf("  • %d file(s) unchanged - no action needed\n", unchanged))
 	}
 	sb.WriteString("\n")
 
-	if deleted+modified > 0 {
+	if deleted+modified+renamed > 0 {
 		sb.WriteString("Files to restore:\n")
 		for _, f := range deletedFiles {
 			sb.WriteString(fmt.Sprintf("  + %s (deleted)\n", f))
@@ -402,6 +575,9 @@ func (s *Server) toolCheckpointDiff(args map[string]interface{}) (string, error)
 		for _, f := range modifiedFiles {
 			sb.WriteString(fmt.Sprintf("  ~ %s (modified)\n", f))
 		}
+		for _, f := range renamedFiles {
+			sb.WriteString(fmt.Sprintf("  → %s\n", f))
+		}
 		sb.WriteString("\n")
 		sb.WriteString(fmt.Sprintf("To restore, use: checkpoint_rollback with id=\"%s\"\n", cp.ID))
 	} else {
@@ -412,9 +588,11 @@ func (s *Server) toolCheckpointDiff(args map[string]interface{}) (string, error)
 }
 
 func (s *Server) toolCheckpointTag(args map[string]interface{}) (string, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return "", fmt.Errorf("missing required argument: id")
+	a := Args(args)
+
+	id, err := a.RequiredString("id")
+	if err != nil {
+		return "", err
 	}
 
 	var cpID string
@@ -429,15 +607,41 @@ func (s *Server) toolCheckpointTag(args map[string]interface{}) (string, error)
 	}
 
 	// Verify checkpoint exists
-	cp, err := checkpoint.Get(cpID)
+	cp, err := getCheckpoint(cpID)
 	if err != nil {
-		return "", fmt.Errorf("checkpoint not found: %s", cpID)
+		return "", err
 	}
+	cpID = cp.ID
 
 	var actions []string
 
+	note, err := a.String("note")
+	if err != nil {
+		return "", err
+	}
+	tag, err := a.String("tag")
+	if err != nil {
+		return "", err
+	}
+	remove, err := a.Bool("remove", false)
+	if err != nil {
+		return "", err
+	}
+	name, err := a.String("name")
+	if err != nil {
+		return "", err
+	}
+
+	// Handle name
+	if name != "" {
+		if err := checkpoint.SetName(cpID, name); err != nil {
+			return "", fmt.Errorf("failed to set name: %w", err)
+		}
+		actions = append(actions, fmt.Sprintf("Set name: %s", name))
+	}
+
 	// Handle note
-	if note, ok := args["note"].(string); ok && note != "" {
+	if note != "" {
 		if err := checkpoint.SetNote(cpID, note); err != nil {
 			return "", fmt.Errorf("failed to set note: %w", err)
 		}
@@ -445,12 +649,7 @@ func (s *Server) toolCheckpointTag(args map[string]interface{}) (string, error)
 	}
 
 	// Handle tag
-	if tag, ok := args["tag"].(string); ok && tag != "" {
-		remove := false
-		if r, ok := args["remove"].(bool); ok {
-			remove = r
-		}
-
+	if tag != "" {
 		if remove {
 			if err := checkpoint.RemoveTag(cpID, tag); err != nil {
 				return "", fmt.Errorf("failed to remove tag: %w", err)
@@ -467,7 +666,7 @@ func (s *Server) toolCheckpointTag(args map[string]interface{}) (string, error)
 	if len(actions) == 0 {
 		// Just show current tags and note
 		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("Checkpoint: %s\n", cp.ID))
+		sb.WriteString(fmt.Sprintf("Checkpoint: %s%s\n", cp.ID, nameLine(cp)))
 		sb.WriteString(fmt.Sprintf("Command: %s\n", cp.Manifest.Command))
 
 		if cp.Manifest.Note != "" {
@@ -487,20 +686,22 @@ func (s *Server) toolCheckpointTag(args map[string]interface{}) (string, error)
 }
 
 func (s *Server) toolCheckpointSearch(args map[string]interface{}) (string, error) {
+	a := Args(args)
 	opts := checkpoint.SearchOptions{}
 
-	if file, ok := args["file"].(string); ok {
-		opts.FileName = file
+	var err error
+	if opts.FileName, err = a.String("file"); err != nil {
+		return "", err
 	}
-	if tag, ok := args["tag"].(string); ok {
-		opts.Tag = tag
+	if opts.Tag, err = a.String("tag"); err != nil {
+		return "", err
 	}
-	if cmd, ok := args["command"].(string); ok {
-		opts.Command = cmd
+	if opts.Command, err = a.String("command"); err != nil {
+		return "", err
 	}
 
 	if opts.FileName == "" && opts.Tag == "" && opts.Command == "" {
-		return "", fmt.Errorf("please provide at least one search criteria: file, tag, or command")
+		return "", &ArgError{Message: "please provide at least one search criteria: file, tag, or command"}
 	}
 
 	results, err := checkpoint.Search(opts)
@@ -525,7 +726,7 @@ func (s *Server) toolCheckpointSearch(args map[string]interface{}) (string, erro
 			}
 		}
 
-		timeAgo := util.FormatTimeAgo(cp.CreatedAt)
+		timeAgo := output.FormatTimeAgo(cp.CreatedAt)
 		command := cp.Manifest.Command
 		if len(command) > 30 {
 			command = command[:27] + "..."
@@ -549,11 +750,17 @@ func (s *Server) toolCheckpointSearch(args map[string]interface{}) (string, erro
 }
 
 func (s *Server) toolCheckpointCompress(args map[string]interface{}) (string, error) {
+	a := Args(args)
+
 	// Handle older_than parameter (takes precedence)
-	if olderThan, ok := args["older_than"].(string); ok && olderThan != "" {
+	olderThan, err := a.String("older_than")
+	if err != nil {
+		return "", err
+	}
+	if olderThan != "" {
 		duration, err := parseDuration(olderThan)
 		if err != nil {
-			return "", fmt.Errorf("invalid duration: %s", olderThan)
+			return "", argError("older_than", "must be a duration like 7d, 2w or 24h, got %q", olderThan)
 		}
 
 		count, saved, err := checkpoint.CompressOlderThan(duration)
@@ -565,13 +772,16 @@ func (s *Server) toolCheckpointCompress(args map[string]interface{}) (string, er
 			return "No checkpoints to compress.", nil
 		}
 
-		return fmt.Sprintf("Compressed %d checkpoint(s), saved %s", count, util.FormatBytes(saved)), nil
+		return fmt.Sprintf("Compressed %d checkpoint(s), saved %s", count, output.FormatBytes(saved)), nil
 	}
 
 	// Handle id parameter
-	id, _ := args["id"].(string)
+	id, err := a.String("id")
+	if err != nil {
+		return "", err
+	}
 	if id == "" {
-		return "", fmt.Errorf("please provide 'id' or 'older_than' parameter")
+		return "", argError("id", "is required when older_than is not given")
 	}
 
 	// Compress all
@@ -602,12 +812,11 @@ func (s *Server) toolCheckpointCompress(args map[string]interface{}) (string, er
 			return "No checkpoints to compress (all already compressed).", nil
 		}
 
-		return fmt.Sprintf("Compressed %d checkpoint(s), total saved: %s", compressed, util.FormatBytes(totalSaved)), nil
+		return fmt.Sprintf("Compressed %d checkpoint(s), total saved: %s", compressed, output.FormatBytes(totalSaved)), nil
 	}
 
 	// Single checkpoint
 	var cp *checkpoint.Checkpoint
-	var err error
 
 	if id == "latest" {
 		cp, err = checkpoint.GetLatest()
@@ -615,14 +824,14 @@ func (s *Server) toolCheckpointCompress(args map[string]interface{}) (string, er
 			return "", fmt.Errorf("no checkpoints found")
 		}
 	} else {
-		cp, err = checkpoint.Get(id)
+		cp, err = getCheckpoint(id)
 		if err != nil {
-			return "", fmt.Errorf("checkpoint not found: %s", id)
+			return "", err
 		}
 	}
 
 	if cp.Manifest.Compressed {
-		return fmt.Sprintf("Checkpoint %s is already compressed (%s)", cp.ID, util.FormatBytes(cp.Manifest.CompressedSize)), nil
+		return fmt.Sprintf("Checkpoint %s is already compressed (%s)", cp.ID, output.FormatBytes(cp.Manifest.CompressedSize)), nil
 	}
 
 	originalSize, compressedSize, err := checkpoint.Compress(cp.ID)
@@ -642,21 +851,20 @@ Saved: %s
 
 The checkpoint will be automatically decompressed when you rollback.`,
 		cp.ID,
-		util.FormatBytes(originalSize),
-		util.FormatBytes(compressedSize),
+		output.FormatBytes(originalSize),
+		output.FormatBytes(compressedSize),
 		ratio,
-		util.FormatBytes(saved),
+		output.FormatBytes(saved),
 	), nil
 }
 
 func (s *Server) toolCheckpointDecompress(args map[string]interface{}) (string, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return "", fmt.Errorf("missing required argument: id")
+	id, err := Args(args).RequiredString("id")
+	if err != nil {
+		return "", err
 	}
 
 	var cp *checkpoint.Checkpoint
-	var err error
 
 	if id == "latest" {
 		cp, err = checkpoint.GetLatest()
@@ -664,9 +872,9 @@ func (s *Server) toolCheckpointDecompress(args map[string]interface{}) (string,
 			return "", fmt.Errorf("no checkpoints found")
 		}
 	} else {
-		cp, err = checkpoint.Get(id)
+		cp, err = getCheckpoint(id)
 		if err != nil {
-			return "", fmt.Errorf("checkpoint not found: %s", id)
+			return "", err
 		}
 	}
 
@@ -706,3 +914,25 @@ func parseDuration(s string) (time.Duration, error) {
 	// Fall back to standard Go duration parsing (h, m, s)
 	return time.ParseDuration(s)
 }
+
+// getCheckpoint loads the checkpoint ref refers to: an ID, a name, a unique
+// prefix of an ID or @N for the Nth latest
+func getCheckpoint(ref string) (*checkpoint.Checkpoint, error) {
+	id, err := checkpoint.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := checkpoint.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint not found: %s", ref)
+	}
+	return cp, nil
+}
+
+// nameLine is the line giving cp's name after its ID, if it has one
+func nameLine(cp *checkpoint.Checkpoint) string {
+	if cp.Manifest.Name == "" {
+		return ""
+	}
+	return "\nName: " + cp.Manifest.Name
+}
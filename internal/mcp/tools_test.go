@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+func TestUsageGroups(t *testing.T) {
+	cp := func(id, session string, tags ...string) *checkpoint.Checkpoint {
+		return &checkpoint.Checkpoint{ID: id, Manifest: &checkpoint.Manifest{ID: id, SessionID: session, Tags: tags}}
+	}
+	checkpoints := []*checkpoint.Checkpoint{
+		cp("a", "s1", "keep"),
+		cp("b", "s1"),
+		cp("c", "", "keep", "release"),
+		cp("d", "s2"),
+	}
+	sizes := map[string]int64{"a": 100, "b": 10, "c": 1000, "d": 1}
+
+	bySession, byTag := usageGroups(checkpoints, sizes)
+
+	expectedSessions := []usage{{"default", 1, 1000}, {"s1", 2, 110}, {"s2", 1, 1}}
+	if len(bySession) != len(expectedSessions) {
+		t.Fatalf("Expected %d sessions, got %v", len(expectedSessions), bySession)
+	}
+	for i, u := range expectedSessions {
+		if bySession[i] != u {
+			t.Errorf("Session %d: expected %v, got %v", i, u, bySession[i])
+		}
+	}
+
+	expectedTags := []usage{{"keep", 2, 1100}, {"release", 1, 1000}, {"(untagged)", 2, 11}}
+	if len(byTag) != len(expectedTags) {
+		t.Fatalf("Expected %d tags, got %v", len(expectedTags), byTag)
+	}
+	for i, u := range expectedTags {
+		if byTag[i] != u {
+			t.Errorf("Tag %d: expected %v, got %v", i, u, byTag[i])
+		}
+	}
+
+	largest := largestCheckpoints(checkpoints, sizes, 2)
+	if len(largest) != 2 || largest[0].ID != "c" || largest[1].ID != "a" {
+		t.Errorf("Expected c and a to be the largest, got %v", largest)
+	}
+}
@@ -0,0 +1,153 @@
+//go:build !windows
+
+package mountfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Checkpoint contents never change, so the kernel may cache aggressively
+const cacheTimeout = time.Hour
+
+// Mount serves the tree read-only at mountpoint. The caller must call
+// Unmount on the returned server and then Wait for it to exit.
+func Mount(t *Tree, mountpoint string) (Server, error) {
+	timeout := cacheTimeout
+	root := &dirNode{tree: t, node: t.Root}
+
+	server, err := fs.Mount(mountpoint, root, &fs.Options{
+		AttrTimeout:  &timeout,
+		EntryTimeout: &timeout,
+		MountOptions: fuse.MountOptions{
+			FsName:  "safeshell:" + t.Checkpoint.ID,
+			Name:    "safeshell",
+			Options: []string{"ro"},
+			// Mount directly when running as root, falling back to fusermount
+			DirectMount: true,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// dirNode is a directory; the root populates the whole tree when mounted
+type dirNode struct {
+	fs.Inode
+	tree *Tree
+	node *Node
+}
+
+var (
+	_ = (fs.NodeOnAdder)((*dirNode)(nil))
+	_ = (fs.NodeGetattrer)((*dirNode)(nil))
+)
+
+func (d *dirNode) OnAdd(ctx context.Context) {
+	if d.node != d.tree.Root {
+		return
+	}
+	d.addChildren(ctx, &d.Inode, d.node)
+}
+
+func (d *dirNode) addChildren(ctx context.Context, parent *fs.Inode, n *Node) {
+	for _, child := range n.SortedChildren() {
+		if child.IsDir {
+			dir := &dirNode{tree: d.tree, node: child}
+			inode := parent.NewPersistentInode(ctx, dir, fs.StableAttr{Mode: syscall.S_IFDIR})
+			parent.AddChild(child.Name, inode, true)
+			d.addChildren(ctx, inode, child)
+			continue
+		}
+
+		file := &fileNode{tree: d.tree, node: child}
+		inode := parent.NewPersistentInode(ctx, file, fs.StableAttr{Mode: syscall.S_IFREG})
+		parent.AddChild(child.Name, inode, true)
+	}
+}
+
+func (d *dirNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	setAttr(out, d.node, d.tree.Checkpoint.CreatedAt)
+	return fs.OK
+}
+
+// fileNode is a backed-up file
+type fileNode struct {
+	fs.Inode
+	tree *Tree
+	node *Node
+}
+
+var (
+	_ = (fs.NodeGetattrer)((*fileNode)(nil))
+	_ = (fs.NodeOpener)((*fileNode)(nil))
+)
+
+func (f *fileNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	setAttr(out, f.node, f.tree.Checkpoint.CreatedAt)
+	return fs.OK
+}
+
+func (f *fileNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_APPEND|syscall.O_TRUNC) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+
+	file, err := f.tree.Open(f.node)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, syscall.ENOENT
+		}
+		return nil, 0, syscall.EIO
+	}
+	return &fileHandle{file: file}, fuse.FOPEN_KEEP_CACHE, fs.OK
+}
+
+// fileHandle reads an opened backup file
+type fileHandle struct {
+	file *os.File
+}
+
+var (
+	_ = (fs.FileReader)((*fileHandle)(nil))
+	_ = (fs.FileReleaser)((*fileHandle)(nil))
+)
+
+func (h *fileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := h.file.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), fs.OK
+}
+
+func (h *fileHandle) Release(ctx context.Context) syscall.Errno {
+	h.file.Close()
+	return fs.OK
+}
+
+// setAttr fills attributes for n, stripping write permission since the
+// mount is read-only
+func setAttr(out *fuse.AttrOut, n *Node, mtime time.Time) {
+	out.Mode = uint32(n.Mode.Perm() &^ 0222)
+	if n.IsDir {
+		out.Mode |= syscall.S_IFDIR
+		out.Nlink = 2
+	} else {
+		out.Mode |= syscall.S_IFREG
+		out.Nlink = 1
+		out.Size = uint64(n.Size)
+		out.Blocks = (out.Size + 511) / 512
+	}
+	out.Uid = uint32(os.Getuid())
+	out.Gid = uint32(os.Getgid())
+	out.SetTimes(nil, &mtime, &mtime)
+}
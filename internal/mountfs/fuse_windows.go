@@ -0,0 +1,8 @@
+package mountfs
+
+import "errors"
+
+// Mount is not available on Windows, which has no FUSE
+func Mount(t *Tree, mountpoint string) (Server, error) {
+	return nil, errors.New("mounting checkpoints is not supported on Windows")
+}
@@ -0,0 +1,9 @@
+package mountfs
+
+// Server is a mounted checkpoint
+type Server interface {
+	// Unmount detaches the filesystem; it fails while the mount is busy
+	Unmount() error
+	// Wait blocks until the filesystem is unmounted
+	Wait()
+}
@@ -0,0 +1,199 @@
+// Package mountfs exposes a checkpoint as a read-only filesystem.
+package mountfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+// Node is a file or directory in a checkpoint tree
+type Node struct {
+	Name     string
+	Mode     os.FileMode
+	Size     int64
+	IsDir    bool
+	Children map[string]*Node
+
+	backupPath string
+}
+
+// SortedChildren returns the node's children ordered by name
+func (n *Node) SortedChildren() []*Node {
+	children := make([]*Node, 0, len(n.Children))
+	for _, c := range n.Children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+// Tree lays out a checkpoint's backed-up files under their original
+// absolute paths. File contents are read from the checkpoint's files
+// directory, or extracted from its archive on first open when compressed.
+type Tree struct {
+	Root       *Node
+	Checkpoint *checkpoint.Checkpoint
+
+	archivePath string
+	algorithm   string
+
+	mu        sync.Mutex
+	cacheDir  string
+	extracted map[string]string // backup path -> extracted copy
+}
+
+// NewTree builds the tree for cp from its manifest
+func NewTree(cp *checkpoint.Checkpoint) (*Tree, error) {
+	t := &Tree{
+		Root:       &Node{Mode: os.ModeDir | 0555, IsDir: true, Children: make(map[string]*Node)},
+		Checkpoint: cp,
+		extracted:  make(map[string]string),
+	}
+
+	if cp.Manifest.Compressed {
+		t.algorithm = cp.Manifest.CompressionAlgorithm
+		t.archivePath = checkpoint.GetArchivePath(cp.Dir, t.algorithm)
+		if _, err := os.Stat(t.archivePath); err != nil {
+			return nil, fmt.Errorf("checkpoint archive is missing: %w", err)
+		}
+	}
+
+	for _, f := range cp.Manifest.Files {
+		n := t.add(f.OriginalPath, f.IsDir)
+		if n == nil {
+			continue
+		}
+		if f.Mode != 0 {
+			n.Mode = f.Mode
+		}
+		if !f.IsDir {
+			n.Size = f.Size
+			n.backupPath = f.BackupPath
+		}
+	}
+
+	return t, nil
+}
+
+// add creates the node for an absolute path and any missing parents
+func (t *Tree) add(path string, isDir bool) *Node {
+	parts := strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/")
+	if len(parts) == 0 || parts[0] == "" {
+		return nil
+	}
+
+	n := t.Root
+	for i, part := range parts {
+		child, ok := n.Children[part]
+		if !ok {
+			child = &Node{Name: part, Mode: 0444}
+			if isDir || i < len(parts)-1 {
+				child.IsDir = true
+				child.Mode = os.ModeDir | 0555
+				child.Children = make(map[string]*Node)
+			}
+			n.Children[part] = child
+		}
+		if !child.IsDir && i < len(parts)-1 {
+			// A file and a directory share a path; keep the first
+			return nil
+		}
+		n = child
+	}
+	return n
+}
+
+// Lookup finds the node at an absolute path, or nil
+func (t *Tree) Lookup(path string) *Node {
+	n := t.Root
+	for _, part := range strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if n.Children == nil {
+			return nil
+		}
+		if n = n.Children[part]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Open opens a file node for reading
+func (t *Tree) Open(n *Node) (*os.File, error) {
+	if n.IsDir {
+		return nil, fmt.Errorf("%s is a directory", n.Name)
+	}
+	if t.archivePath == "" {
+		return os.Open(n.backupPath)
+	}
+
+	path, err := t.extract(n)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// extract decompresses a single file from the archive into the cache,
+// once per file for the lifetime of the tree
+func (t *Tree) extract(n *Node) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if path, ok := t.extracted[n.backupPath]; ok {
+		return path, nil
+	}
+
+	if t.cacheDir == "" {
+		dir, err := os.MkdirTemp("", "safeshell-mount-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		t.cacheDir = dir
+	}
+
+	name, err := filepath.Rel(t.Checkpoint.FilesDir, n.backupPath)
+	if err != nil || strings.HasPrefix(name, "..") {
+		return "", fmt.Errorf("backup path %s is outside the checkpoint", n.backupPath)
+	}
+
+	tmp, err := os.CreateTemp(t.cacheDir, "file-")
+	if err != nil {
+		return "", err
+	}
+	if err := checkpoint.ExtractArchiveFile(t.archivePath, t.algorithm, name, tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	t.extracted[n.backupPath] = tmp.Name()
+	return tmp.Name(), nil
+}
+
+// Close removes files extracted from the archive
+func (t *Tree) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cacheDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(t.cacheDir)
+	t.cacheDir = ""
+	t.extracted = make(map[string]string)
+	return err
+}
@@ -0,0 +1,123 @@
+package mountfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func setupTestEnv(t *testing.T) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "safeshell-mountfs-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	config.Reset()
+	config.Init()
+	checkpoint.ResetIndex()
+
+	os.MkdirAll(filepath.Join(tmpDir, "testdata", "src"), 0755)
+
+	cleanup := func() {
+		os.RemoveAll(tmpDir)
+	}
+	return tmpDir, cleanup
+}
+
+func readNode(t *testing.T, tree *Tree, path string) string {
+	t.Helper()
+	n := tree.Lookup(path)
+	if n == nil {
+		t.Fatalf("Expected %s in tree", path)
+	}
+	f, err := tree.Open(n)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestTreeReadsFiles(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		tmpDir, cleanup := setupTestEnv(t)
+
+		dir := filepath.Join(tmpDir, "testdata")
+		os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+		os.WriteFile(filepath.Join(dir, "README.md"), []byte("# readme"), 0600)
+
+		cp, err := checkpoint.Create("rm -rf testdata", []string{dir})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		if compressed {
+			if _, _, err := checkpoint.Compress(cp.ID); err != nil {
+				t.Fatalf("Failed to compress: %v", err)
+			}
+			cp, _ = checkpoint.Get(cp.ID)
+		}
+
+		tree, err := NewTree(cp)
+		if err != nil {
+			t.Fatalf("NewTree failed: %v", err)
+		}
+
+		if got := readNode(t, tree, filepath.Join(dir, "src", "main.go")); got != "package main" {
+			t.Errorf("compressed=%v: expected 'package main', got %q", compressed, got)
+		}
+		if got := readNode(t, tree, filepath.Join(dir, "README.md")); got != "# readme" {
+			t.Errorf("compressed=%v: expected '# readme', got %q", compressed, got)
+		}
+
+		src := tree.Lookup(filepath.Join(dir, "src"))
+		if src == nil || !src.IsDir || len(src.SortedChildren()) != 1 {
+			t.Errorf("compressed=%v: expected src directory with one child, got %+v", compressed, src)
+		}
+		if n := tree.Lookup(filepath.Join(dir, "README.md")); n.Mode.Perm() != 0600 || n.Size != 8 {
+			t.Errorf("compressed=%v: expected mode 0600 and size 8, got %v %d", compressed, n.Mode, n.Size)
+		}
+		if tree.Lookup(filepath.Join(dir, "missing.txt")) != nil {
+			t.Errorf("compressed=%v: lookup of a missing file should return nil", compressed)
+		}
+
+		// Files extracted from the archive are cleaned up on close
+		cacheDir := tree.cacheDir
+		if err := tree.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		if compressed {
+			if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+				t.Errorf("Cache directory should be removed on close")
+			}
+		}
+
+		cleanup()
+	}
+}
+
+func TestExtractArchiveFileMissing(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	src := filepath.Join(tmpDir, "archive-src")
+	os.MkdirAll(src, 0755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+
+	archive := filepath.Join(tmpDir, "files.tar.gz")
+	if _, err := checkpoint.CompressDir(src, archive, checkpoint.CompressionGzip, 0); err != nil {
+		t.Fatalf("CompressDir failed: %v", err)
+	}
+
+	if err := checkpoint.ExtractArchiveFile(archive, checkpoint.CompressionGzip, "b.txt", io.Discard); !os.IsNotExist(err) {
+		t.Errorf("Expected not-exist error for missing file, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package oplog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// Operation types recorded in the log
+const (
+	OpCheckpoint = "checkpoint"
+	OpRollback   = "rollback"
+	OpDelete     = "delete"
+	OpWrap       = "wrap" // a command run by 'safeshell wrap'
+)
+
+// Entry is a single record in the operations log (one JSON object per line)
+type Entry struct {
+	Time         time.Time `json:"time"`
+	Op           string    `json:"op"`
+	CheckpointID string    `json:"checkpoint_id,omitempty"`
+	Command      string    `json:"command,omitempty"`
+	Files        int       `json:"files,omitempty"`
+	Bytes        int64     `json:"bytes,omitempty"`
+	Session      string    `json:"session,omitempty"` // of the checkpoint, or of who ran the command
+
+	// For wrap: who ran the command and where, how it exited, or why it
+	// didn't run, and how long it took
+	User       string `json:"user,omitempty"`
+	WorkingDir string `json:"cwd,omitempty"`
+	ExitCode   *int   `json:"exit_code,omitempty"` // unset if it didn't run
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// Failed reports whether a wrapped command exited with an error or didn't
+// run at all
+func (e Entry) Failed() bool {
+	return e.Error != "" || (e.ExitCode != nil && *e.ExitCode != 0)
+}
+
+// Append writes an entry to the operations log.
+// Logging is best-effort and never blocks the operation being logged.
+func Append(e Entry) error {
+	return AppendTo(config.GetOperationsLog(), e)
+}
+
+// AppendTo is Append, writing to the operations log at path, for processes
+// that know where it is without loading config
+func AppendTo(path string, e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns all log entries recorded at or after since, oldest first.
+// Malformed lines are skipped.
+func Read(since time.Time) ([]Entry, error) {
+	f, err := os.Open(config.GetOperationsLog())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// CommandCount is the number of checkpoints triggered by a command
+type CommandCount struct {
+	Command string `json:"command"`
+	Count   int    `json:"count"`
+}
+
+// Summary aggregates activity from a set of log entries
+type Summary struct {
+	Since              time.Time      `json:"since"`
+	Until              time.Time      `json:"until"`
+	CheckpointsCreated int            `json:"checkpoints_created"`
+	FilesProtected     int            `json:"files_protected"`
+	BytesProtected     int64          `json:"bytes_protected"`
+	Rollbacks          int            `json:"rollbacks"`
+	FilesRestored      int            `json:"files_restored"`
+	BytesRestored      int64          `json:"bytes_restored"`
+	CheckpointsDeleted int            `json:"checkpoints_deleted"`
+	ActiveDays         int            `json:"active_days"`
+	TopCommands        []CommandCount `json:"top_commands,omitempty"`
+}
+
+// Summarize aggregates entries into a Summary covering [since, until]
+func Summarize(entries []Entry, since, until time.Time) Summary {
+	s := Summary{Since: since, Until: until}
+	days := make(map[string]bool)
+	commands := make(map[string]int)
+
+	for _, e := range entries {
+		days[e.Time.Format("2006-01-02")] = true
+
+		switch e.Op {
+		case OpCheckpoint:
+			s.CheckpointsCreated++
+			s.FilesProtected += e.Files
+			s.BytesProtected += e.Bytes
+			if e.Command != "" {
+				commands[commandName(e.Command)]++
+			}
+		case OpRollback:
+			s.Rollbacks++
+			s.FilesRestored += e.Files
+			s.BytesRestored += e.Bytes
+		case OpDelete:
+			s.CheckpointsDeleted++
+		}
+	}
+
+	s.ActiveDays = len(days)
+	for cmd, count := range commands {
+		s.TopCommands = append(s.TopCommands, CommandCount{Command: cmd, Count: count})
+	}
+	sort.Slice(s.TopCommands, func(i, j int) bool {
+		if s.TopCommands[i].Count == s.TopCommands[j].Count {
+			return s.TopCommands[i].Command < s.TopCommands[j].Command
+		}
+		return s.TopCommands[i].Count > s.TopCommands[j].Count
+	})
+	if len(s.TopCommands) > 5 {
+		s.TopCommands = s.TopCommands[:5]
+	}
+
+	return s
+}
+
+// commandName returns the program name of a command line (e.g. "rm" for "rm -rf build")
+func commandName(command string) string {
+	for i, c := range command {
+		if c == ' ' {
+			return command[:i]
+		}
+	}
+	return command
+}
@@ -0,0 +1,118 @@
+package oplog
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func setupTestEnv(t *testing.T) func() {
+	tmpDir, err := os.MkdirTemp("", "safeshell-oplog-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	config.Reset()
+	config.Init()
+
+	return func() {
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestAppendAndRead(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	old := time.Now().Add(-48 * time.Hour)
+	Append(Entry{Time: old, Op: OpCheckpoint, Command: "rm old.txt", Files: 1, Bytes: 10})
+	Append(Entry{Op: OpCheckpoint, Command: "rm -rf build", Files: 3, Bytes: 300})
+	Append(Entry{Op: OpRollback, Files: 2, Bytes: 200})
+
+	entries, err := Read(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 recent entries, got %d", len(entries))
+	}
+
+	if entries[0].Op != OpCheckpoint || entries[1].Op != OpRollback {
+		t.Errorf("Entries should be returned oldest first, got %s, %s", entries[0].Op, entries[1].Op)
+	}
+}
+
+func TestReadMissingLog(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	entries, err := Read(time.Time{})
+	if err != nil {
+		t.Fatalf("Read should not fail on a missing log: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries, got %d", len(entries))
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+	entries := []Entry{
+		{Time: now, Op: OpCheckpoint, Command: "rm -rf build", Files: 10, Bytes: 1000},
+		{Time: now, Op: OpCheckpoint, Command: "rm a.txt", Files: 1, Bytes: 50},
+		{Time: now, Op: OpCheckpoint, Command: "mv a b", Files: 1, Bytes: 20},
+		{Time: now.Add(-25 * time.Hour), Op: OpRollback, Files: 10, Bytes: 1000},
+		{Time: now, Op: OpDelete},
+	}
+
+	s := Summarize(entries, now.Add(-30*24*time.Hour), now)
+
+	if s.CheckpointsCreated != 3 {
+		t.Errorf("Expected 3 checkpoints, got %d", s.CheckpointsCreated)
+	}
+	if s.FilesProtected != 12 || s.BytesProtected != 1070 {
+		t.Errorf("Unexpected protected totals: %d files, %d bytes", s.FilesProtected, s.BytesProtected)
+	}
+	if s.Rollbacks != 1 || s.BytesRestored != 1000 {
+		t.Errorf("Unexpected rollback totals: %d rollbacks, %d bytes", s.Rollbacks, s.BytesRestored)
+	}
+	if s.CheckpointsDeleted != 1 {
+		t.Errorf("Expected 1 deletion, got %d", s.CheckpointsDeleted)
+	}
+	if s.ActiveDays != 2 {
+		t.Errorf("Expected 2 active days, got %d", s.ActiveDays)
+	}
+	if len(s.TopCommands) == 0 || s.TopCommands[0].Command != "rm" || s.TopCommands[0].Count != 2 {
+		t.Errorf("Expected rm to be the top command, got %+v", s.TopCommands)
+	}
+}
+
+func TestAppendToAndFailed(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	ok, failed := 0, 2
+	AppendTo(config.GetOperationsLog(), Entry{Op: OpWrap, Command: "rm a.txt", ExitCode: &ok})
+	AppendTo(config.GetOperationsLog(), Entry{Op: OpWrap, Command: "rm b.txt", ExitCode: &failed})
+	AppendTo(config.GetOperationsLog(), Entry{Op: OpWrap, Command: "rm -rf ~", Error: "refused by rule #1"})
+
+	entries, err := Read(time.Time{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	for i, expected := range []bool{false, true, true} {
+		if entries[i].Failed() != expected {
+			t.Errorf("%s: expected Failed() = %v", entries[i].Command, expected)
+		}
+	}
+	if entries[2].ExitCode != nil {
+		t.Errorf("Expected no exit code for a refused command, got %d", *entries[2].ExitCode)
+	}
+}
@@ -0,0 +1,143 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Progress shows how far a long operation has got: a bar redrawn in place
+// on a terminal, or a line every few seconds otherwise, e.g. in CI logs.
+// Nothing is shown for operations finishing within its delay.
+type Progress struct {
+	// W is where progress goes, os.Stderr if nil, looked up on every
+	// update as it may be redirected in the meantime
+	W io.Writer
+
+	// Delay is how long an operation runs before its progress shows
+	Delay time.Duration
+
+	now     func() time.Time
+	label   string
+	start   time.Time
+	drawn   time.Time
+	visible bool // a bar is drawn on the current line
+}
+
+// Redraw intervals, on a terminal and otherwise
+const (
+	progressRedraw = 100 * time.Millisecond
+	progressLine   = 5 * time.Second
+)
+
+// progressWidth is the width of the bar, in characters
+const progressWidth = 24
+
+// NewProgress returns a Progress writing to w, or os.Stderr if w is nil
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{W: w, Delay: time.Second, now: time.Now}
+}
+
+// Update shows that done of total items and doneBytes of totalBytes have
+// been processed by the step named label. A total of 0 is unknown.
+func (p *Progress) Update(label string, done, total int, doneBytes, totalBytes int64) {
+	now := p.now()
+	if label != p.label {
+		p.Clear()
+		p.label, p.start, p.drawn = label, now, time.Time{}
+	}
+	if now.Sub(p.start) < p.Delay {
+		return
+	}
+	w, tty := p.writer()
+	interval := progressLine
+	if tty {
+		interval = progressRedraw
+	}
+	if !p.drawn.IsZero() && now.Sub(p.drawn) < interval {
+		return
+	}
+	p.drawn = now
+
+	// Bytes tell how far along better, as files differ in size
+	fraction := -1.0
+	switch {
+	case totalBytes > 0:
+		fraction = float64(doneBytes) / float64(totalBytes)
+	case total > 0:
+		fraction = float64(done) / float64(total)
+	}
+	fraction = min(fraction, 1)
+
+	var b strings.Builder
+	if total > 0 {
+		fmt.Fprintf(&b, "%d/%d files", done, total)
+	} else {
+		fmt.Fprintf(&b, "%d files", done)
+	}
+	if totalBytes > 0 {
+		fmt.Fprintf(&b, ", %s/%s", FormatBytes(doneBytes), FormatBytes(totalBytes))
+	}
+	if fraction > 0 {
+		elapsed := now.Sub(p.start)
+		eta := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		fmt.Fprintf(&b, ", ETA %s", FormatETA(eta))
+	}
+
+	if !tty {
+		if fraction >= 0 {
+			fmt.Fprintf(w, "%s: %d%% (%s)\n", label, int(fraction*100), b.String())
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", label, b.String())
+		}
+		return
+	}
+	bar := strings.Repeat(" ", progressWidth)
+	percent := "    "
+	if fraction >= 0 {
+		filled := int(fraction * progressWidth)
+		bar = strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+		percent = fmt.Sprintf("%3d%%", int(fraction*100))
+	}
+	// \033[K clears what a longer line before left
+	fmt.Fprintf(w, "\r%s [%s] %s  %s\033[K", label, bar, percent, b.String())
+	p.visible = true
+}
+
+// Clear removes the bar from the terminal, for the operation's result to
+// be printed in its place
+func (p *Progress) Clear() {
+	if p.visible {
+		w, _ := p.writer()
+		fmt.Fprint(w, "\r\033[K")
+		p.visible = false
+	}
+	p.label = ""
+}
+
+// writer returns where progress goes and whether it is a terminal
+func (p *Progress) writer() (io.Writer, bool) {
+	w := p.W
+	if w == nil {
+		w = os.Stderr
+	}
+	f, ok := w.(*os.File)
+	return w, ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// FormatETA formats the time left for an operation (e.g., "1m05s")
+func FormatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := NewProgress(&buf)
+	p.now = func() time.Time { return now }
+
+	// Nothing within the delay
+	p.Update("Backing up", 0, 100, 0, 1000)
+	now = now.Add(500 * time.Millisecond)
+	p.Update("Backing up", 10, 100, 100, 1000)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected nothing shown within the delay, got %q", buf.String())
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	p.Update("Backing up", 25, 100, 500, 1000)
+	want := "Backing up: 50% (25/100 files, 500 B/1000 B, ETA 2s)\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	// Lines are printed every few seconds when not on a terminal
+	now = now.Add(time.Second)
+	p.Update("Backing up", 30, 100, 600, 1000)
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("Expected one line within the interval, got %q", buf.String())
+	}
+	now = now.Add(progressLine)
+	p.Update("Backing up", 90, 100, 900, 1000)
+	if !strings.HasSuffix(buf.String(), "Backing up: 90% (90/100 files, 900 B/1000 B, ETA 1s)\n") {
+		t.Errorf("Expected a second line, got %q", buf.String())
+	}
+
+	// A new step starts its own delay
+	buf.Reset()
+	p.Update("Restoring", 1, 0, 0, 0)
+	if buf.Len() != 0 {
+		t.Errorf("Expected a new step to wait for the delay, got %q", buf.String())
+	}
+	now = now.Add(2 * time.Second)
+	p.Update("Restoring", 3, 0, 0, 0)
+	if buf.String() != "Restoring: 3 files\n" {
+		t.Errorf("Expected a count without a total, got %q", buf.String())
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{400 * time.Millisecond, "0s"},
+		{45 * time.Second, "45s"},
+		{65 * time.Second, "1m05s"},
+		{2*time.Hour + 3*time.Minute, "2h03m"},
+	}
+	for _, tt := range tests {
+		if got := FormatETA(tt.d); got != tt.want {
+			t.Errorf("FormatETA(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package output renders what commands print: human-readable sizes and
+// times, and tables that come out aligned for people, or as JSON or
+// tab-separated lines for scripts.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// Format selects how a table is printed
+type Format string
+
+const (
+	// FormatTable prints aligned columns under a header, for people
+	FormatTable Format = "table"
+	// FormatJSON prints an array with one object per row
+	FormatJSON Format = "json"
+	// FormatPlain prints tab-separated cells without header or color
+	FormatPlain Format = "plain"
+)
+
+// ParseFormat returns the format named s
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatTable, FormatJSON, FormatPlain:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (use table, json or plain)", s)
+}
+
+// Note is a line printed under a row in table output
+type Note struct {
+	Text  string
+	Color *color.Color
+}
+
+// Row is one line of a table
+type Row struct {
+	Cells []string
+
+	// Data is what JSON output shows for the row. Without it, the row is an
+	// object of its cells keyed by column name.
+	Data any
+
+	// Color and Notes only show in table output
+	Color *color.Color
+	Notes []Note
+}
+
+// Note adds a line to print under the row
+func (r *Row) Note(text string, c *color.Color) *Row {
+	r.Notes = append(r.Notes, Note{Text: text, Color: c})
+	return r
+}
+
+// Table is a list of rows under column headers
+type Table struct {
+	Columns []string
+	Rows    []*Row
+}
+
+// NewTable starts a table with the given column headers
+func NewTable(columns ...string) *Table {
+	return &Table{Columns: columns}
+}
+
+// Add appends a row of cells, returned so it can be decorated
+func (t *Table) Add(cells ...string) *Row {
+	row := &Row{Cells: cells}
+	t.Rows = append(t.Rows, row)
+	return row
+}
+
+// Print writes the table to w in the given format
+func (t *Table) Print(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		return t.printJSON(w)
+	case FormatPlain:
+		return t.printPlain(w)
+	default:
+		return t.printTable(w)
+	}
+}
+
+func (t *Table) printTable(w io.Writer) error {
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row.Cells {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+			}
+		}
+	}
+	total := 0
+	for _, width := range widths {
+		total += width + 2
+	}
+
+	color.New(color.FgWhite, color.Bold).Fprintln(w, pad(t.Columns, widths))
+	fmt.Fprintln(w, strings.Repeat("─", max(total-2, 0)))
+	for _, row := range t.Rows {
+		line := pad(row.Cells, widths)
+		if row.Color != nil {
+			row.Color.Fprintln(w, line)
+		} else {
+			fmt.Fprintln(w, line)
+		}
+		for _, note := range row.Notes {
+			text := "  └─ " + note.Text
+			if note.Color != nil {
+				note.Color.Fprintln(w, text)
+			} else {
+				fmt.Fprintln(w, text)
+			}
+		}
+	}
+	return nil
+}
+
+// pad joins cells into columns of the given widths; the last is not padded
+func pad(cells []string, widths []int) string {
+	var sb strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			sb.WriteString("  ")
+		}
+		sb.WriteString(cell)
+		if i < len(cells)-1 && i < len(widths) {
+			sb.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+	}
+	return sb.String()
+}
+
+func (t *Table) printJSON(w io.Writer) error {
+	rows := make([]any, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		if row.Data != nil {
+			rows = append(rows, row.Data)
+			continue
+		}
+		obj := make(map[string]string, len(row.Cells))
+		for i, cell := range row.Cells {
+			if i < len(t.Columns) {
+				obj[jsonKey(t.Columns[i])] = cell
+			}
+		}
+		rows = append(rows, obj)
+	}
+
+	return PrintJSON(w, rows)
+}
+
+// PrintJSON writes v to w as indented JSON, the way tables print in JSON
+func PrintJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// jsonKey turns a column header into a snake_case key
+func jsonKey(column string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(column)), " ", "_")
+}
+
+func (t *Table) printPlain(w io.Writer) error {
+	for _, row := range t.Rows {
+		if _, err := fmt.Fprintln(w, strings.Join(row.Cells, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
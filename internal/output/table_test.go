@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestTablePrint(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	table := NewTable("ID", "FILES", "LAST COMMAND")
+	table.Add("first", "12", "rm -rf build").Note("tags: keep", nil)
+	table.Add("second-longer", "3", "mv a b").Data = struct {
+		ID    string `json:"id"`
+		Files int    `json:"files"`
+	}{"second-longer", 3}
+
+	tests := []struct {
+		format   Format
+		expected string
+	}{
+		{FormatTable, `ID             FILES  LAST COMMAND
+──────────────────────────────────
+first          12     rm -rf build
+  └─ tags: keep
+second-longer  3      mv a b
+`},
+		{FormatJSON, `[
+  {
+    "files": "12",
+    "id": "first",
+    "last_command": "rm -rf build"
+  },
+  {
+    "id": "second-longer",
+    "files": 3
+  }
+]
+`},
+		{FormatPlain, "first\t12\trm -rf build\nsecond-longer\t3\tmv a b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := table.Print(&buf, tt.format); err != nil {
+				t.Fatalf("Print failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Unexpected output:\n%s\nexpected:\n%s", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestTablePrintEmptyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	NewTable("ID").Print(&buf, FormatJSON)
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %q", buf.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"table", "json", "plain"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package rollback
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+// Conflict is a file a rollback would overwrite although it was changed
+// after the checkpoint: its current content matches neither the backup nor
+// the hash recorded when the checkpoint was taken
+type Conflict struct {
+	Path        string
+	CurrentSize int64
+	BackupSize  int64
+}
+
+// Resolution is what to do with a conflicting file
+type Resolution int
+
+const (
+	// Restore overwrites the current file with the checkpointed one
+	Restore Resolution = iota
+	// KeepCurrent leaves the current file and skips restoring it
+	KeepCurrent
+	// SaveBoth moves the current file aside, then restores
+	SaveBoth
+)
+
+// Conflicts returns the conflicting files among paths, or among all the
+// checkpoint's files if paths is nil. Files that are gone, or still match
+// the checkpoint, can be restored without losing anything.
+func Conflicts(cp *checkpoint.Checkpoint, paths []string) []Conflict {
+	var wanted map[string]bool
+	if paths != nil {
+		wanted = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			wanted[p] = true
+		}
+	}
+	hashes := make(map[string]string, len(cp.Manifest.Files))
+	for _, f := range cp.Manifest.Files {
+		hashes[f.OriginalPath] = f.Hash
+	}
+
+	var conflicts []Conflict
+	for _, d := range checkpoint.Compare(cp) {
+		if d.Status != checkpoint.DiffModified || (wanted != nil && !wanted[d.Path]) {
+			continue
+		}
+		// The backup itself may have changed through its hard link, in
+		// which case the current file can still be the checkpointed state
+		if hash := hashes[d.Path]; hash != "" {
+			if current, err := checkpoint.HashFile(d.Path); err == nil && current == hash {
+				continue
+			}
+		}
+		conflicts = append(conflicts, Conflict{Path: d.Path, CurrentSize: d.CurrentSize, BackupSize: d.BackupSize})
+	}
+	return conflicts
+}
+
+// SaveCurrent moves the file at path to a free name next to it
+// (path.current, path.current.1, ...) so a rollback can restore path
+// without losing it, and returns the new name. Moving rather than copying
+// keeps the hard links of later checkpoints pointing at it intact.
+func SaveCurrent(path string) (string, error) {
+	saved := path + ".current"
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(saved); os.IsNotExist(err) {
+			break
+		}
+		saved = fmt.Sprintf("%s.current.%d", path, i)
+	}
+	if err := os.Rename(path, saved); err != nil {
+		return "", fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return saved, nil
+}
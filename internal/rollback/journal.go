@@ -0,0 +1,163 @@
+package rollback
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// journalFile is where a rollback records its progress, in the directory of
+// the checkpoint rolled back. It is removed once the rollback is done, so
+// one found later belongs to a rollback that was interrupted.
+const journalFile = "rollback.journal"
+
+// Journal operations, one line each
+const (
+	opStart    = "start"    // Paths holds the files being restored, all if empty
+	opStaged   = "staged"   // the backup of Path was copied to Temp
+	opAside    = "aside"    // the file at Path was moved to Aside
+	opRestored = "restored" // Path is restored
+	opReverted = "reverted" // the rollback failed; Path is as it was
+)
+
+type journalEntry struct {
+	Op    string   `json:"op"`
+	Path  string   `json:"path,omitempty"`
+	Temp  string   `json:"temp,omitempty"`
+	Aside string   `json:"aside,omitempty"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+// journal appends entries to a checkpoint's journal file. A nil journal
+// records nothing, so a rollback goes ahead if the journal can't be written.
+type journal struct {
+	path string
+	file *os.File
+}
+
+// startJournal starts recording a rollback of paths (all files if nil).
+// When resuming, the files the interrupted rollback restored are carried
+// over from its journal, which this one replaces.
+func startJournal(cp *checkpoint.Checkpoint, paths []string, restored map[string]bool) *journal {
+	path := filepath.Join(cp.Dir, journalFile)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		logging.Warn(i18n.T("rollback.journal_failed", err))
+		return nil
+	}
+	j := &journal{path: path, file: file}
+	j.record(journalEntry{Op: opStart, Paths: paths})
+	for p := range restored {
+		j.record(journalEntry{Op: opRestored, Path: p})
+	}
+	return j
+}
+
+func (j *journal) record(e journalEntry) {
+	if j == nil {
+		return
+	}
+	data, _ := json.Marshal(e)
+	j.file.Write(append(data, '\n'))
+}
+
+// close stops recording; with done set, the journal is removed
+func (j *journal) close(done bool) {
+	if j == nil {
+		return
+	}
+	j.file.Close()
+	if done {
+		os.Remove(j.path)
+	}
+}
+
+// interrupted is what the journal of an interrupted rollback says
+type interrupted struct {
+	paths    []string // the files it was restoring, all if nil
+	restored map[string]bool
+	last     map[string]journalEntry // latest staged or aside entry by path
+	order    []string
+}
+
+// loadJournal reads the journal an interrupted rollback of cp left, or
+// returns nil if there is none
+func loadJournal(cp *checkpoint.Checkpoint) (*interrupted, error) {
+	file, err := os.Open(filepath.Join(cp.Dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollback journal: %w", err)
+	}
+	defer file.Close()
+
+	left := &interrupted{restored: make(map[string]bool), last: make(map[string]journalEntry)}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // A line cut short by the interruption
+		}
+		switch e.Op {
+		case opStart:
+			left.paths = e.Paths
+		case opStaged, opAside:
+			if !seen[e.Path] {
+				seen[e.Path] = true
+				left.order = append(left.order, e.Path)
+			}
+			prev := left.last[e.Path]
+			if e.Op == opAside {
+				e.Temp = prev.Temp
+			}
+			left.last[e.Path] = e
+		case opRestored:
+			left.restored[e.Path] = true
+		case opReverted:
+			delete(left.last, e.Path)
+			delete(left.restored, e.Path)
+		}
+	}
+	return left, scanner.Err()
+}
+
+// recover puts right what the interrupted rollback was doing: copies it
+// made are removed, and files it moved aside are put back unless their
+// replacement made it into place, in which case they count as restored
+func (left *interrupted) recover() {
+	for _, path := range left.order {
+		e, ok := left.last[path]
+		if !ok {
+			continue
+		}
+		placed := left.restored[path]
+		if e.Temp != "" {
+			if _, err := os.Lstat(e.Temp); err == nil {
+				os.Remove(e.Temp)
+			} else if os.IsNotExist(err) {
+				placed = true // Renamed into place before the interruption
+			}
+		}
+		if e.Aside != "" {
+			if _, err := os.Lstat(e.Aside); err == nil {
+				if placed {
+					os.Remove(e.Aside)
+				} else if err := os.Rename(e.Aside, path); err != nil {
+					logging.Warn(i18n.T("rollback.aside_failed", path, e.Aside, err))
+				}
+			}
+		}
+		if placed {
+			left.restored[path] = true
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package rollback
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+// restoreOrder returns the entries of a checkpoint in the order they are
+// restored: directories before files, and each parent before its contents.
+// Manifests list entries in the order they were backed up, which depends on
+// how the command named them; restoring in a fixed order keeps rollbacks
+// of the same checkpoint identical.
+func restoreOrder(files []checkpoint.FileEntry) []checkpoint.FileEntry {
+	ordered := append([]checkpoint.FileEntry(nil), files...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].IsDir != ordered[j].IsDir {
+			return ordered[i].IsDir
+		}
+		return pathLess(ordered[i].OriginalPath, ordered[j].OriginalPath)
+	})
+	return ordered
+}
+
+// pathLess compares paths component by component, so a directory sorts
+// right before its contents ("a", "a/b", "a-b" rather than "a", "a-b",
+// "a/b")
+func pathLess(a, b string) bool {
+	sep := string(filepath.Separator)
+	return strings.ReplaceAll(a, sep, "\x00") < strings.ReplaceAll(b, sep, "\x00")
+}
+
+// restoreConflicts returns the original paths of files that would be
+// restored onto the same path as another file: the same target, or targets
+// differing only by case where the filesystem can't hold both. Restoring
+// them would silently keep whichever was written last, so none of them is.
+func restoreConflicts(files []checkpoint.FileEntry, target func(string) string) map[string]bool {
+	// Original paths by case-folded target
+	groups := make(map[string][]string)
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		key := strings.ToLower(target(f.OriginalPath))
+		if !slices.Contains(groups[key], f.OriginalPath) {
+			groups[key] = append(groups[key], f.OriginalPath)
+		}
+	}
+
+	conflicts := make(map[string]bool)
+	for _, originals := range groups {
+		if len(originals) < 2 {
+			continue
+		}
+		exact := make(map[string]int)
+		for _, o := range originals {
+			exact[target(o)]++
+		}
+		foldsCase := len(exact) > 1 && caseInsensitive(filepath.Dir(target(originals[0])))
+		for _, o := range originals {
+			if foldsCase || exact[target(o)] > 1 {
+				conflicts[o] = true
+			}
+		}
+	}
+	return conflicts
+}
+
+// caseInsensitive reports whether the filesystem holding dir treats names
+// differing only by case as the same file, as macOS and Windows do by
+// default. It is a variable so tests can run on either kind.
+var caseInsensitive = probeCaseInsensitive
+
+// probeCaseInsensitive creates a file in the nearest existing ancestor of
+// dir and looks it up by its upper-case name. If the probe can't be made
+// the filesystem is taken to be case-sensitive.
+func probeCaseInsensitive(dir string) bool {
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+
+	probe, err := os.CreateTemp(dir, ".safeshell-case-*")
+	if err != nil {
+		return false
+	}
+	probe.Close()
+	defer os.Remove(probe.Name())
+
+	info, err := os.Stat(probe.Name())
+	if err != nil {
+		return false
+	}
+	upper, err := os.Stat(filepath.Join(dir, strings.ToUpper(filepath.Base(probe.Name()))))
+	return err == nil && os.SameFile(info, upper)
+}
@@ -1,236 +1,312 @@
 package rollback
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/hooks"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+	"github.com/qhkm/safeshell/internal/oplog"
 )
 
+// ErrAlreadyRolledBack is returned when rolling back a checkpoint that has
+// already been rolled back
+var ErrAlreadyRolledBack = errors.New("checkpoint has already been rolled back")
+
+// PartialError is returned by a rollback that restored some files but
+// failed to restore others
+type PartialError struct {
+	Restored int
+	Failed   int
+	Dest     string // Where files were restored to, if not in place
+}
+
+func (e *PartialError) Error() string {
+	if e.Dest != "" {
+		return fmt.Sprintf("restored %d files to %s, %d failed", e.Restored, e.Dest, e.Failed)
+	}
+	return fmt.Sprintf("restored %d files, %d failed", e.Restored, e.Failed)
+}
+
 // Rollback restores files from a checkpoint
 func Rollback(cp *checkpoint.Checkpoint) error {
-	if cp.Manifest.RolledBack {
-		return fmt.Errorf("checkpoint %s has already been rolled back", cp.ID)
-	}
+	_, err := RollbackWithProgress(cp, nil)
+	return err
+}
 
-	// Auto-decompress if checkpoint is compressed
-	if cp.Manifest.Compressed {
-		fmt.Println("Decompressing checkpoint...")
-		if err := checkpoint.EnsureDecompressed(cp); err != nil {
-			return fmt.Errorf("failed to decompress checkpoint: %w", err)
-		}
-		// Reload checkpoint to get updated paths
-		var err error
-		cp, err = checkpoint.Get(cp.ID)
-		if err != nil {
-			return fmt.Errorf("failed to reload checkpoint: %w", err)
-		}
-	}
+// RollbackWithProgress is Rollback, reporting each file as it is restored.
+// It returns how many files were restored, which a PartialError says too.
+func RollbackWithProgress(cp *checkpoint.Checkpoint, progress checkpoint.ProgressFunc) (int, error) {
+	return rollbackInPlace(context.Background(), cp, nil, progress, false)
+}
 
-	restored := 0
-	failed := 0
+// RollbackSelective restores only specific files from a checkpoint
+func RollbackSelective(cp *checkpoint.Checkpoint, filePaths []string) error {
+	_, err := rollbackInPlace(context.Background(), cp, filePaths, nil, false)
+	return err
+}
 
-	for _, file := range cp.Manifest.Files {
-		// Skip directories (we handle files individually)
-		if file.IsDir {
-			continue
-		}
+// RollbackContext is Rollback, or RollbackSelective if filePaths is not
+// nil, stopping when ctx is done. Stopped before the files are put in
+// place, none are; once they are being, the rollback finishes.
+func RollbackContext(ctx context.Context, cp *checkpoint.Checkpoint, filePaths []string) error {
+	_, err := rollbackInPlace(ctx, cp, filePaths, nil, false)
+	return err
+}
 
-		// Check if backup exists
-		if _, err := os.Stat(file.BackupPath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Warning: backup file not found: %s\n", file.BackupPath)
-			failed++
-			continue
-		}
+// Resume carries on with an interrupted rollback of cp, restoring the files
+// it had not restored yet
+func Resume(cp *checkpoint.Checkpoint, progress checkpoint.ProgressFunc) error {
+	_, err := rollbackInPlace(context.Background(), cp, nil, progress, true)
+	return err
+}
 
-		// Restore the file
-		if err := checkpoint.RestoreFile(file.BackupPath, file.OriginalPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore %s: %v\n", file.OriginalPath, err)
-			failed++
-			continue
-		}
+// Interrupted reports whether a rollback of cp was interrupted and can be
+// resumed
+func Interrupted(cp *checkpoint.Checkpoint) bool {
+	_, err := os.Stat(filepath.Join(cp.Dir, journalFile))
+	return err == nil
+}
 
-		// Restore original permissions
-		if err := os.Chmod(file.OriginalPath, file.Mode); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore permissions for %s: %v\n", file.OriginalPath, err)
+// LatestInterrupted returns the most recent checkpoint whose rollback was
+// interrupted
+func LatestInterrupted() (*checkpoint.Checkpoint, error) {
+	checkpoints, err := checkpoint.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range checkpoints {
+		if Interrupted(cp) {
+			return cp, nil
 		}
-
-		restored++
 	}
+	return nil, fmt.Errorf("no interrupted rollback to resume")
+}
 
-	// Mark checkpoint as rolled back
-	cp.Manifest.RolledBack = true
-	if err := cp.Manifest.Save(cp.Dir); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to update manifest: %v\n", err)
+// rollbackInPlace restores paths (all files if nil) to where they were,
+// unless ctx is done first, and returns how many it restored. A rollback
+// interrupted earlier is put right first; with resume, the files it
+// restored are kept and paths is what it was restoring.
+func rollbackInPlace(ctx context.Context, cp *checkpoint.Checkpoint, paths []string, progress checkpoint.ProgressFunc, resume bool) (int, error) {
+	if cp.Manifest.RolledBack {
+		return 0, fmt.Errorf("%w: %s", ErrAlreadyRolledBack, cp.ID)
 	}
 
-	if failed > 0 {
-		return fmt.Errorf("restored %d files, %d failed", restored, failed)
+	left, err := loadJournal(cp)
+	if err != nil {
+		return 0, err
+	}
+	if resume && left == nil {
+		return 0, fmt.Errorf("no interrupted rollback of checkpoint %s to resume", cp.ID)
+	}
+	var done map[string]bool
+	if left != nil {
+		left.recover()
+		if resume {
+			paths, done = left.paths, left.restored
+			fmt.Println(i18n.T("rollback.resuming", len(done)))
+		} else {
+			fmt.Println(i18n.T("rollback.interrupted_found"))
+		}
 	}
 
-	fmt.Printf("Successfully restored %d files from checkpoint %s\n", restored, cp.ID)
-	return nil
-}
+	env := hookEnv(cp, paths)
+	if err := hooks.Run(hooks.PreRollback, env); err != nil {
+		return 0, err
+	}
+	defer runPostHook(env)
 
-// RollbackSelective restores only specific files from a checkpoint
-func RollbackSelective(cp *checkpoint.Checkpoint, filePaths []string) error {
-	if cp.Manifest.RolledBack {
-		return fmt.Errorf("checkpoint %s has already been rolled back", cp.ID)
+	// What rm moved into the checkpoint is simply renamed back
+	if paths == nil && left == nil && checkpoint.Untrashable(cp) {
+		return rollbackTrash(cp, progress)
 	}
 
 	// Auto-decompress if checkpoint is compressed
 	if cp.Manifest.Compressed {
-		fmt.Println("Decompressing checkpoint...")
+		progress.Report(checkpoint.Progress{Phase: checkpoint.PhaseDecompress})
+		fmt.Println(i18n.T("rollback.decompressing"))
 		if err := checkpoint.EnsureDecompressed(cp); err != nil {
-			return fmt.Errorf("failed to decompress checkpoint: %w", err)
+			return 0, fmt.Errorf("failed to decompress checkpoint: %w", err)
 		}
+		// Reload checkpoint to get updated paths
 		var err error
 		cp, err = checkpoint.Get(cp.ID)
 		if err != nil {
-			return fmt.Errorf("failed to reload checkpoint: %w", err)
+			return 0, fmt.Errorf("failed to reload checkpoint: %w", err)
 		}
 	}
+	// Snapshots may need mounting again, e.g. after a restart
+	if err := checkpoint.MountFSSnapshot(cp); err != nil {
+		return 0, fmt.Errorf("failed to mount the checkpoint's snapshot: %w", err)
+	}
 
 	// Build a map of files to restore for quick lookup
 	toRestore := make(map[string]bool)
-	for _, p := range filePaths {
+	for _, p := range paths {
 		toRestore[p] = true
 	}
 
-	restored := 0
-	failed := 0
+	parents := missingParents(cp)
 
-	for _, file := range cp.Manifest.Files {
-		// Skip directories
-		if file.IsDir {
-			continue
-		}
-
-		// Skip files not in our restore list
-		if !toRestore[file.OriginalPath] {
-			continue
-		}
-
-		// Check if backup exists
-		if _, err := os.Stat(file.BackupPath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Warning: backup file not found: %s\n", file.BackupPath)
-			failed++
-			continue
+	var files []checkpoint.FileEntry
+	for _, file := range restoreOrder(cp.Manifest.Files) {
+		// Skip directories, files not in our restore list and files an
+		// interrupted rollback already restored
+		if !file.IsDir && (paths == nil || toRestore[file.OriginalPath]) && !done[file.OriginalPath] {
+			files = append(files, file)
 		}
-
-		// Restore the file
-		if err := checkpoint.RestoreFile(file.BackupPath, file.OriginalPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore %s: %v\n", file.OriginalPath, err)
-			failed++
-			continue
+	}
+	// Files the command left alone and still as checkpointed, e.g. those an
+	// rm -rf stopped part way didn't get to, needn't be restored, nor
+	// checkpointed again first
+	outcome := cp.Manifest.Outcome
+	if outcome != nil && paths == nil {
+		files = slices.DeleteFunc(files, func(file checkpoint.FileEntry) bool {
+			info, err := os.Lstat(file.OriginalPath)
+			return err == nil && !outcome.Touched(file.OriginalPath) && checkpoint.LooksUnchanged(file, info, cp.Manifest.Timestamp)
+		})
+	}
+	// Files git_aware left out come back from git
+	var gitFiles []string
+	for _, p := range cp.Manifest.GitFiles() {
+		if paths == nil || toRestore[p] {
+			gitFiles = append(gitFiles, p)
 		}
-
-		// Restore original permissions
-		if err := os.Chmod(file.OriginalPath, file.Mode); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore permissions for %s: %v\n", file.OriginalPath, err)
+	}
+	// Resuming, the files were checkpointed when the rollback started
+	var safety *checkpoint.Checkpoint
+	if !resume {
+		overwritten := files
+		for _, p := range gitFiles {
+			overwritten = append(overwritten, checkpoint.FileEntry{OriginalPath: p})
 		}
-
-		restored++
+		safety = checkpointBeforeRollback(cp, overwritten)
 	}
 
-	// Note: We don't mark the checkpoint as rolled back for selective restores
-	// since not all files were restored
-
-	if failed > 0 {
-		return fmt.Errorf("restored %d files, %d failed", restored, failed)
+	j := startJournal(cp, paths, done)
+	restoredFiles, failed, err := restoreFiles(ctx, files, inPlace, progress, j)
+	// What earlier runs restored must not be forgotten if this one fails
+	j.close(err == nil || len(done) == 0)
+	settleSafety(safety, err == nil)
+	if err != nil {
+		return 0, err
 	}
-
-	fmt.Printf("Successfully restored %d files from checkpoint %s\n", restored, cp.ID)
-	return nil
-}
-
-// RollbackToPath restores all files from a checkpoint to a different directory
-func RollbackToPath(cp *checkpoint.Checkpoint, destPath string) error {
-	// Auto-decompress if checkpoint is compressed
-	if cp.Manifest.Compressed {
-		fmt.Println("Decompressing checkpoint...")
-		if err := checkpoint.EnsureDecompressed(cp); err != nil {
-			return fmt.Errorf("failed to decompress checkpoint: %w", err)
-		}
-		var err error
-		cp, err = checkpoint.Get(cp.ID)
+	restored := len(restoredFiles) + len(done)
+	if len(gitFiles) > 0 {
+		n, err := checkpoint.RestoreFromGit(cp.Manifest, gitFiles)
 		if err != nil {
-			return fmt.Errorf("failed to reload checkpoint: %w", err)
+			logging.Warn(i18n.T("rollback.git_failed", len(gitFiles)-n, err))
+			failed += len(gitFiles) - n
 		}
+		restored += n
 	}
-
-	// Create destination directory if it doesn't exist
-	if err := os.MkdirAll(destPath, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	var restoredBytes int64
+	var restoredPaths []string
+	for _, file := range restoredFiles {
+		restoredBytes += file.Size
+		restoredPaths = append(restoredPaths, file.OriginalPath)
 	}
 
-	restored := 0
-	failed := 0
-
-	for _, file := range cp.Manifest.Files {
-		// Skip directories
-		if file.IsDir {
-			continue
-		}
+	if paths == nil {
+		restoreDirModes(cp, nil)
+		restoreParentModes(cp, parents)
+		undoRecreated(cp)
+	} else if restored > 0 {
+		// Only the directories leading to restored files are touched
+		restoreDirModes(cp, append(restoredPaths, keys(done)...))
+		restoreParentModes(cp, parents)
+	}
 
-		// Check if backup exists
-		if _, err := os.Stat(file.BackupPath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Warning: backup file not found: %s\n", file.BackupPath)
-			failed++
-			continue
-		}
+	progress.Report(checkpoint.Progress{Phase: checkpoint.PhaseRestore, Done: restored + failed, Total: restored + failed, Bytes: restoredBytes, TotalBytes: restoredBytes})
+	logRollback(cp, restored, restoredBytes)
 
-		// Calculate destination path - preserve directory structure relative to working dir
-		relPath := file.OriginalPath
-		if strings.HasPrefix(file.OriginalPath, cp.Manifest.WorkingDir) {
-			relPath = strings.TrimPrefix(file.OriginalPath, cp.Manifest.WorkingDir)
-			relPath = strings.TrimPrefix(relPath, "/")
-		} else {
-			// For absolute paths outside working dir, use just the filename
-			relPath = filepath.Base(file.OriginalPath)
+	// A selective restore doesn't mark the checkpoint as rolled back, since
+	// not all files were restored
+	if paths == nil {
+		cp.Manifest.RolledBack = true
+		if err := cp.Manifest.Save(cp.Dir); err != nil {
+			logging.Warn(i18n.T("rollback.manifest_failed", err))
 		}
+	}
 
-		targetPath := filepath.Join(destPath, relPath)
+	if failed > 0 {
+		return restored, &PartialError{Restored: restored, Failed: failed}
+	}
 
-		// Create parent directory
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to create directory for %s: %v\n", targetPath, err)
-			failed++
-			continue
-		}
+	fmt.Println(i18n.T("rollback.restored", restored, cp.ID))
+	if outcome != nil && paths == nil && len(outcome.Moved) > 0 {
+		fmt.Println(i18n.T("rollback.moved_remain", len(outcome.Moved)))
+	}
+	return restored, nil
+}
 
-		// Restore the file to new location
-		if err := checkpoint.RestoreFile(file.BackupPath, targetPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore %s: %v\n", targetPath, err)
-			failed++
-			continue
+// rollbackTrash rolls back a checkpoint rm's targets were moved into by
+// renaming them back. Nothing is in their place, so there is nothing to
+// checkpoint first.
+func rollbackTrash(cp *checkpoint.Checkpoint, progress checkpoint.ProgressFunc) (int, error) {
+	parents := missingParents(cp)
+	var restored int
+	var restoredBytes int64
+	for _, file := range cp.Manifest.Files {
+		if !file.IsDir {
+			restored++
+			restoredBytes += file.Size
 		}
+	}
 
-		// Restore original permissions
-		if err := os.Chmod(targetPath, file.Mode); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore permissions for %s: %v\n", targetPath, err)
-		}
+	if err := checkpoint.Untrash(cp); err != nil {
+		return 0, err
+	}
+	restoreParentModes(cp, parents)
 
-		restored++
+	progress.Report(checkpoint.Progress{Phase: checkpoint.PhaseRestore, Done: restored, Total: restored})
+	logRollback(cp, restored, restoredBytes)
+	cp.Manifest.RolledBack = true
+	if err := cp.Manifest.Save(cp.Dir); err != nil {
+		logging.Warn(i18n.T("rollback.manifest_failed", err))
 	}
 
-	// Don't mark checkpoint as rolled back since we restored to a different location
+	fmt.Println(i18n.T("rollback.restored", restored, cp.ID))
+	return restored, nil
+}
 
-	if failed > 0 {
-		return fmt.Errorf("restored %d files to %s, %d failed", restored, destPath, failed)
+// keys returns the keys of a set
+func keys(set map[string]bool) []string {
+	var list []string
+	for k := range set {
+		list = append(list, k)
 	}
+	return list
+}
 
-	fmt.Printf("Successfully restored %d files to %s\n", restored, destPath)
-	return nil
+// RollbackToPath restores all files from a checkpoint to a different directory
+func RollbackToPath(cp *checkpoint.Checkpoint, destPath string) error {
+	return RollbackToPathContext(context.Background(), cp, nil, destPath)
 }
 
 // RollbackSelectiveToPath restores specific files to a different directory
 func RollbackSelectiveToPath(cp *checkpoint.Checkpoint, filePaths []string, destPath string) error {
+	if filePaths == nil {
+		filePaths = []string{}
+	}
+	return RollbackToPathContext(context.Background(), cp, filePaths, destPath)
+}
+
+// RollbackToPathContext restores filePaths (all files if nil) from a
+// checkpoint to a different directory, stopping when ctx is done. Stopped
+// before the files are put in place, none are.
+func RollbackToPathContext(ctx context.Context, cp *checkpoint.Checkpoint, filePaths []string, destPath string) error {
 	// Auto-decompress if checkpoint is compressed
 	if cp.Manifest.Compressed {
-		fmt.Println("Decompressing checkpoint...")
+		fmt.Println(i18n.T("rollback.decompressing"))
 		if err := checkpoint.EnsureDecompressed(cp); err != nil {
 			return fmt.Errorf("failed to decompress checkpoint: %w", err)
 		}
@@ -240,6 +316,10 @@ func RollbackSelectiveToPath(cp *checkpoint.Checkpoint, filePaths []string, dest
 			return fmt.Errorf("failed to reload checkpoint: %w", err)
 		}
 	}
+	// Snapshots may need mounting again, e.g. after a restart
+	if err := checkpoint.MountFSSnapshot(cp); err != nil {
+		return fmt.Errorf("failed to mount the checkpoint's snapshot: %w", err)
+	}
 
 	// Create destination directory if it doesn't exist
 	if err := os.MkdirAll(destPath, 0755); err != nil {
@@ -252,66 +332,131 @@ func RollbackSelectiveToPath(cp *checkpoint.Checkpoint, filePaths []string, dest
 		toRestore[p] = true
 	}
 
-	restored := 0
-	failed := 0
-
-	for _, file := range cp.Manifest.Files {
-		// Skip directories
-		if file.IsDir {
-			continue
+	var files []checkpoint.FileEntry
+	for _, file := range restoreOrder(cp.Manifest.Files) {
+		// Skip directories and files not in our restore list
+		if filePaths == nil || !file.IsDir && toRestore[file.OriginalPath] {
+			files = append(files, file)
 		}
+	}
+	target := func(path string) string { return targetPath(cp, destPath, path) }
 
-		// Skip files not in our restore list
-		if !toRestore[file.OriginalPath] {
-			continue
-		}
+	restoredFiles, failed, err := restoreFiles(ctx, files, target, nil, nil)
+	if err != nil {
+		return err
+	}
+	restored := len(restoredFiles)
+	var restoredBytes int64
+	for _, file := range restoredFiles {
+		restoredBytes += file.Size
+	}
 
-		// Check if backup exists
-		if _, err := os.Stat(file.BackupPath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Warning: backup file not found: %s\n", file.BackupPath)
-			failed++
-			continue
-		}
+	logRollback(cp, restored, restoredBytes)
 
-		// Calculate destination path - preserve directory structure relative to working dir
-		relPath := file.OriginalPath
-		if strings.HasPrefix(file.OriginalPath, cp.Manifest.WorkingDir) {
-			relPath = strings.TrimPrefix(file.OriginalPath, cp.Manifest.WorkingDir)
-			relPath = strings.TrimPrefix(relPath, "/")
-		} else {
-			relPath = filepath.Base(file.OriginalPath)
+	// Don't mark checkpoint as rolled back since we restored to a different location
+
+	if failed > 0 {
+		return &PartialError{Restored: restored, Failed: failed, Dest: destPath}
+	}
+
+	fmt.Println(i18n.T("rollback.restored_to", restored, destPath))
+	return nil
+}
+
+// restoreDirModes restores the recorded permissions of the checkpoint's
+// directories, including setgid and sticky bits, recreating empty ones that
+// are missing. It runs after the files are back so a read-only directory
+// can't block its contents. With paths set, only directories containing one
+// of them are touched.
+func restoreDirModes(cp *checkpoint.Checkpoint, paths []string) {
+	var dirs []checkpoint.FileEntry
+	for _, file := range restoreOrder(cp.Manifest.Files) {
+		if file.IsDir && (paths == nil || containsAny(file.OriginalPath, paths)) {
+			dirs = append(dirs, file)
 		}
+	}
 
-		targetPath := filepath.Join(destPath, relPath)
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir.OriginalPath, 0755); err != nil {
+			logging.Warn(i18n.T("rollback.mkdir_failed", dir.OriginalPath, err))
+		}
+	}
+	// Children before parents, in case a parent loses search permission
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := checkpoint.SetDirMode(dirs[i].OriginalPath, dirs[i].Mode); err != nil {
+			logging.Warn(i18n.T("rollback.perms_failed", dirs[i].OriginalPath, err))
+		}
+	}
+}
 
-		// Create parent directory
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to create directory for %s: %v\n", targetPath, err)
-			failed++
-			continue
+// missingParents returns the recorded parent directories of the checkpoint's
+// targets that no longer exist, parents first
+func missingParents(cp *checkpoint.Checkpoint) []string {
+	var missing []string
+	for dir := range cp.Manifest.ParentModes {
+		if _, err := os.Lstat(dir); os.IsNotExist(err) {
+			missing = append(missing, dir)
 		}
+	}
+	sort.Strings(missing)
+	return missing
+}
 
-		// Restore the file to new location
-		if err := checkpoint.RestoreFile(file.BackupPath, targetPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore %s: %v\n", targetPath, err)
-			failed++
+// restoreParentModes gives the parent directories recreated by a rollback
+// their recorded modes instead of the umask default. Parents that existed
+// before the rollback, or that it didn't need, are left alone.
+func restoreParentModes(cp *checkpoint.Checkpoint, missing []string) {
+	for i := len(missing) - 1; i >= 0; i-- {
+		dir := missing[i]
+		if _, err := os.Stat(dir); err != nil {
 			continue
 		}
-
-		// Restore original permissions
-		if err := os.Chmod(targetPath, file.Mode); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to restore permissions for %s: %v\n", targetPath, err)
+		if err := checkpoint.SetDirMode(dir, cp.Manifest.ParentModes[dir]); err != nil {
+			logging.Warn(i18n.T("rollback.perms_failed", dir, err))
 		}
+	}
+}
 
-		restored++
+// inPlace is the restore target of a file restored to where it was
+func inPlace(path string) string {
+	return path
+}
+
+// targetPath is where a file is restored to under destPath: at the same
+// place relative to the working directory, or by name if it was outside it
+func targetPath(cp *checkpoint.Checkpoint, destPath, path string) string {
+	relPath := filepath.Base(path)
+	if strings.HasPrefix(path, cp.Manifest.WorkingDir) {
+		relPath = strings.TrimPrefix(path, cp.Manifest.WorkingDir)
+		relPath = strings.TrimPrefix(relPath, "/")
 	}
+	return filepath.Join(destPath, relPath)
+}
 
-	if failed > 0 {
-		return fmt.Errorf("restored %d files to %s, %d failed", restored, destPath, failed)
+// containsAny reports whether dir is a parent of any of paths
+func containsAny(dir string, paths []string) bool {
+	prefix := dir + string(filepath.Separator)
+	for _, p := range paths {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
 	}
+	return false
+}
 
-	fmt.Printf("Successfully restored %d files to %s\n", restored, destPath)
-	return nil
+// logRollback records a rollback in the operations log
+func logRollback(cp *checkpoint.Checkpoint, restored int, restoredBytes int64) {
+	if restored == 0 {
+		return
+	}
+	oplog.Append(oplog.Entry{
+		Op:           oplog.OpRollback,
+		CheckpointID: cp.ID,
+		Command:      cp.Manifest.Command,
+		Files:        restored,
+		Bytes:        restoredBytes,
+		Session:      checkpoint.GetSessionID(),
+	})
 }
 
 // RollbackByID finds and rolls back a checkpoint by ID
@@ -331,3 +476,28 @@ func RollbackLatest() error {
 	}
 	return Rollback(cp)
 }
+
+// hookEnv describes a rollback of paths (all files if nil) to the hooks
+func hookEnv(cp *checkpoint.Checkpoint, paths []string) hooks.Env {
+	if paths == nil {
+		for _, file := range cp.Manifest.Files {
+			if !file.IsDir {
+				paths = append(paths, file.OriginalPath)
+			}
+		}
+	}
+	return hooks.Env{
+		CheckpointID:  cp.ID,
+		CheckpointDir: cp.Dir,
+		Command:       cp.Manifest.Command,
+		Paths:         paths,
+	}
+}
+
+// runPostHook runs the post_rollback hook; failures are only reported.
+// It runs even if the rollback failed, so pre/post hooks stay paired.
+func runPostHook(env hooks.Env) {
+	if err := hooks.Run(hooks.PostRollback, env); err != nil {
+		logging.Warn(i18n.T("rollback.hook_failed", err))
+	}
+}
@@ -1,9 +1,13 @@
 package rollback
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/qhkm/safeshell/internal/checkpoint"
 	"github.com/qhkm/safeshell/internal/config"
@@ -18,6 +22,7 @@ func setupTestEnv(t *testing.T) (string, func()) {
 
 	// Set up config to use temp directory
 	os.Setenv("HOME", tmpDir)
+	config.Reset()
 	config.Init()
 
 	// Reset index to ensure fresh state for each test
@@ -128,6 +133,75 @@ func TestRollbackDeletedDirectory(t *testing.T) {
 	}
 }
 
+func TestRollbackTrashed(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	parent := filepath.Join(tmpDir, "testdata", "work")
+	testDir := filepath.Join(parent, "myproject")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "main.go"), []byte("package main"), 0644)
+
+	// rm with rm_strategy: trash moves the directory into the checkpoint
+	cp, err := checkpoint.Trash("rm -rf myproject", []string{testDir})
+	if err != nil {
+		t.Fatalf("Failed to trash: %v", err)
+	}
+	backup := filepath.Join(cp.FilesDir, testDir)
+	os.Remove(parent)
+
+	if err := Rollback(cp); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(testDir, "main.go"))
+	if string(content) != "package main" {
+		t.Errorf("main.go content mismatch")
+	}
+	// Renamed back, not copied
+	if _, err := os.Lstat(backup); !os.IsNotExist(err) {
+		t.Error("Expected the checkpoint to no longer hold myproject")
+	}
+	cp, _ = checkpoint.Get(cp.ID)
+	if !cp.Manifest.RolledBack {
+		t.Error("Expected the checkpoint to be marked rolled back")
+	}
+}
+
+func TestRollbackSkipsUntouched(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "testdata", "docs")
+	os.MkdirAll(testDir, 0755)
+	gone, left := filepath.Join(testDir, "a.txt"), filepath.Join(testDir, "b.txt")
+	os.WriteFile(gone, []byte("a"), 0644)
+	os.WriteFile(left, []byte("b"), 0644)
+
+	cp, err := checkpoint.Create("rm -rf docs", []string{testDir})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	// rm stopped part way, leaving b.txt
+	os.Remove(gone)
+	if _, err := checkpoint.RecordOutcome(cp.Dir, 1, nil); err != nil {
+		t.Fatalf("Failed to record outcome: %v", err)
+	}
+	cp, _ = checkpoint.Get(cp.ID)
+	// Marked so a restore would show
+	old := cp.CreatedAt.Add(-time.Hour)
+	os.Chtimes(left, old, old)
+
+	if err := Rollback(cp); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if _, err := os.Stat(gone); err != nil {
+		t.Error("a.txt should be restored")
+	}
+	if info, _ := os.Stat(left); !info.ModTime().Equal(old) {
+		t.Error("b.txt was left alone by rm and should not have been restored")
+	}
+}
+
 func TestRollbackModifiedFile(t *testing.T) {
 	tmpDir, cleanup := setupTestEnv(t)
 	defer cleanup()
@@ -274,3 +348,476 @@ func TestRollbackPreservesPermissions(t *testing.T) {
 		t.Error("Restored file should be executable")
 	}
 }
+
+func TestRollbackPreservesTimes(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testFile := filepath.Join(tmpDir, "testdata", "main.c")
+	os.WriteFile(testFile, []byte("int main;"), 0644)
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(testFile, old, old)
+
+	cp, err := checkpoint.Create("rm main.c", []string{testFile})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	os.Remove(testFile)
+
+	mtime := func(path string) time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat restored file: %v", err)
+		}
+		return info.ModTime()
+	}
+
+	// Restored files get the time of the restore unless asked otherwise
+	dest := filepath.Join(tmpDir, "restored")
+	if err := RollbackToPath(cp, dest); err != nil {
+		t.Fatalf("RollbackToPath failed: %v", err)
+	}
+	if mtime(targetPath(cp, dest, testFile)).Equal(old) {
+		t.Error("Expected the restored file to get the time of the restore")
+	}
+
+	config.Get().PreserveTimes = true
+	defer func() { config.Get().PreserveTimes = false }()
+
+	// Restoring again gives the same result each time
+	for i := 0; i < 2; i++ {
+		if err := RollbackToPath(cp, dest); err != nil {
+			t.Fatalf("RollbackToPath failed: %v", err)
+		}
+		if got := mtime(targetPath(cp, dest, testFile)); !got.Equal(old) {
+			t.Errorf("Expected the restored file to have mtime %v, got %v", old, got)
+		}
+	}
+
+	if err := Rollback(cp); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if got := mtime(testFile); !got.Equal(old) {
+		t.Errorf("Expected the rolled back file to have mtime %v, got %v", old, got)
+	}
+}
+
+func TestRollbackRestoresDirectoryModes(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "testdata", "project")
+	shared := filepath.Join(testDir, "shared")
+	scratch := filepath.Join(shared, "tmp")
+	empty := filepath.Join(testDir, "empty")
+	os.MkdirAll(scratch, 0755)
+	os.MkdirAll(empty, 0755)
+	os.WriteFile(filepath.Join(scratch, "a.txt"), []byte("a"), 0644)
+	checkpoint.SetDirMode(scratch, 0777|os.ModeSticky)
+	checkpoint.SetDirMode(shared, 0775|os.ModeSetgid)
+	checkpoint.SetDirMode(empty, 0700)
+
+	cp, err := checkpoint.Create("rm -rf project", []string{testDir})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Drift one directory's mode and delete the rest
+	os.Chmod(testDir, 0700)
+	os.RemoveAll(shared)
+	os.RemoveAll(empty)
+
+	if err := Rollback(cp); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	for dir, want := range map[string]os.FileMode{
+		testDir: 0755,
+		shared:  0775 | os.ModeSetgid,
+		scratch: 0777 | os.ModeSticky,
+		empty:   0700,
+	} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Errorf("%s should be restored: %v", dir, err)
+			continue
+		}
+		if got := info.Mode() &^ os.ModeDir; got != want {
+			t.Errorf("%s: expected mode %v, got %v", dir, want, got)
+		}
+	}
+}
+
+func TestRollbackSelectiveRestoresParentModes(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "testdata", "project")
+	shared := filepath.Join(testDir, "shared")
+	other := filepath.Join(testDir, "other")
+	os.MkdirAll(shared, 0755)
+	os.MkdirAll(other, 0755)
+	file := filepath.Join(shared, "a.txt")
+	os.WriteFile(file, []byte("a"), 0644)
+	checkpoint.SetDirMode(shared, 0775|os.ModeSetgid)
+
+	cp, err := checkpoint.Create("rm -rf project", []string{testDir})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	os.RemoveAll(shared)
+	os.Chmod(other, 0700)
+
+	if err := RollbackSelective(cp, []string{file}); err != nil {
+		t.Fatalf("RollbackSelective failed: %v", err)
+	}
+
+	if info, err := os.Stat(shared); err != nil || info.Mode()&^os.ModeDir != 0775|os.ModeSetgid {
+		t.Errorf("Expected %s restored with setgid, got %v (%v)", shared, info, err)
+	}
+	// Directories without restored files are left alone
+	if info, _ := os.Stat(other); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected %s to keep mode 0700, got %v", other, info.Mode().Perm())
+	}
+}
+
+func TestRestoreOrder(t *testing.T) {
+	sep := string(filepath.Separator)
+	entry := func(path string, isDir bool) checkpoint.FileEntry {
+		return checkpoint.FileEntry{OriginalPath: strings.ReplaceAll(path, "/", sep), IsDir: isDir}
+	}
+	files := []checkpoint.FileEntry{
+		entry("/p/d/sub/c.txt", false),
+		entry("/p/a-b", false),
+		entry("/p/d/sub", true),
+		entry("/p/d/b.txt", false),
+		entry("/p/d", true),
+		entry("/p/a/x", false),
+	}
+
+	var got []string
+	for _, f := range restoreOrder(files) {
+		got = append(got, filepath.ToSlash(f.OriginalPath))
+	}
+	expected := "/p/d /p/d/sub /p/a/x /p/a-b /p/d/b.txt /p/d/sub/c.txt"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("Expected order %s, got %s", expected, strings.Join(got, " "))
+	}
+}
+
+func TestRollbackCaseOnlyConflict(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Only a case-sensitive filesystem can hold both, so pretend the
+	// rollback goes to one that isn't
+	defer func() { caseInsensitive = probeCaseInsensitive }()
+	caseInsensitive = func(string) bool { return true }
+
+	upper := filepath.Join(tmpDir, "testdata", "README.md")
+	lower := filepath.Join(tmpDir, "testdata", "readme.md")
+	other := filepath.Join(tmpDir, "testdata", "other.md")
+	os.WriteFile(upper, []byte("upper"), 0644)
+	os.WriteFile(lower, []byte("lower"), 0644)
+	os.WriteFile(other, []byte("other"), 0644)
+
+	cp, err := checkpoint.Create("rm *.md", []string{upper, lower, other})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	os.Remove(upper)
+	os.Remove(lower)
+	os.Remove(other)
+
+	if err := Rollback(cp); err == nil {
+		t.Error("Expected the case-only conflict to fail the rollback")
+	}
+	for _, path := range []string{upper, lower} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be restored", filepath.Base(path))
+		}
+	}
+	if content, _ := os.ReadFile(other); string(content) != "other" {
+		t.Errorf("Expected other.md restored, got %q", content)
+	}
+}
+
+func TestRollbackToPathSameTarget(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Files outside the working directory are restored by name alone
+	first := filepath.Join(tmpDir, "testdata", "x", "data.txt")
+	second := filepath.Join(tmpDir, "testdata", "y", "data.txt")
+	os.MkdirAll(filepath.Dir(first), 0755)
+	os.MkdirAll(filepath.Dir(second), 0755)
+	os.WriteFile(first, []byte("first"), 0644)
+	os.WriteFile(second, []byte("second"), 0644)
+
+	cp, err := checkpoint.Create("rm */data.txt", []string{first, second})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	if strings.HasPrefix(first, cp.Manifest.WorkingDir) {
+		t.Skip("test files are inside the working directory")
+	}
+
+	dest := filepath.Join(tmpDir, "restored")
+	if err := RollbackToPath(cp, dest); err == nil {
+		t.Error("Expected both files restoring to the same path to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "data.txt")); !os.IsNotExist(err) {
+		t.Error("Expected neither file to be restored over the other")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	edited := filepath.Join(dir, "edited.txt")
+	deleted := filepath.Join(dir, "deleted.txt")
+	same := filepath.Join(dir, "same.txt")
+	os.WriteFile(edited, []byte("checkpointed"), 0644)
+	os.WriteFile(deleted, []byte("checkpointed"), 0644)
+	os.WriteFile(same, []byte("checkpointed"), 0644)
+
+	cp, err := checkpoint.Create("test", []string{edited, deleted, same})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	// Backups are hard links, so replace the file rather than editing it
+	os.Remove(edited)
+	os.WriteFile(edited, []byte("newer work"), 0644)
+	os.Remove(deleted)
+
+	conflicts := Conflicts(cp, nil)
+	if len(conflicts) != 1 || conflicts[0].Path != edited {
+		t.Fatalf("Expected only %s to conflict, got %v", edited, conflicts)
+	}
+	if conflicts := Conflicts(cp, []string{deleted, same}); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts among unselected files, got %v", conflicts)
+	}
+
+	saved, err := SaveCurrent(edited)
+	if err != nil {
+		t.Fatalf("SaveCurrent failed: %v", err)
+	}
+	if saved != edited+".current" {
+		t.Errorf("Expected %s.current, got %s", edited, saved)
+	}
+	if err := RollbackSelective(cp, []string{edited}); err != nil {
+		t.Fatalf("RollbackSelective failed: %v", err)
+	}
+	if content, _ := os.ReadFile(edited); string(content) != "checkpointed" {
+		t.Errorf("Expected the checkpointed file restored, got %q", content)
+	}
+	if content, _ := os.ReadFile(saved); string(content) != "newer work" {
+		t.Errorf("Expected the newer work kept aside, got %q", content)
+	}
+
+	// A second save picks the next free name
+	os.Remove(edited)
+	os.WriteFile(edited, []byte("more work"), 0644)
+	if saved, _ := SaveCurrent(edited); saved != edited+".current.1" {
+		t.Errorf("Expected %s.current.1, got %s", edited, saved)
+	}
+}
+
+func TestUndoRollback(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	changed := filepath.Join(tmpDir, "testdata", "changed.txt")
+	deleted := filepath.Join(tmpDir, "testdata", "deleted.txt")
+	os.WriteFile(changed, []byte("checkpointed"), 0644)
+	os.WriteFile(deleted, []byte("gone"), 0644)
+
+	cp, err := checkpoint.Create("make clean", []string{changed, deleted})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	// Replace rather than edit, the backup is a hard link
+	os.Remove(changed)
+	os.WriteFile(changed, []byte("newer work"), 0644)
+	os.Remove(deleted)
+
+	if err := Rollback(cp); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if content, _ := os.ReadFile(changed); string(content) != "checkpointed" {
+		t.Fatalf("Rollback did not restore changed.txt: %q", content)
+	}
+
+	safety, err := LatestSafety()
+	if err != nil {
+		t.Fatalf("No checkpoint taken before the rollback: %v", err)
+	}
+	if safety.Manifest.UndoOf != cp.ID {
+		t.Errorf("Expected the safety checkpoint to undo %s, got %q", cp.ID, safety.Manifest.UndoOf)
+	}
+	if len(safety.Manifest.Recreated) != 1 || safety.Manifest.Recreated[0] != deleted {
+		t.Errorf("Expected deleted.txt to be recorded as recreated, got %v", safety.Manifest.Recreated)
+	}
+
+	if err := Rollback(safety); err != nil {
+		t.Fatalf("Undoing the rollback failed: %v", err)
+	}
+	if content, _ := os.ReadFile(changed); string(content) != "newer work" {
+		t.Errorf("Expected the newer work back, got %q", content)
+	}
+	if _, err := os.Stat(deleted); !os.IsNotExist(err) {
+		t.Error("Expected the recreated file to be removed again")
+	}
+
+	// The original checkpoint can be rolled back again
+	cp, _ = checkpoint.Get(cp.ID)
+	if cp.Manifest.RolledBack {
+		t.Error("Expected the checkpoint to no longer be marked rolled back")
+	}
+}
+
+func TestRollbackIsAllOrNothing(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	os.WriteFile(first, []byte("old first"), 0644)
+	os.WriteFile(second, []byte("old second"), 0644)
+
+	cp, err := checkpoint.Create("sed -i s/old/new/ *.txt", []string{first, second})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	for _, path := range []string{first, second} {
+		os.Remove(path)
+		os.WriteFile(path, []byte("new"), 0644)
+	}
+	// One backup can't be restored, so neither file may be
+	for _, f := range cp.Manifest.Files {
+		if f.OriginalPath == second {
+			os.Remove(f.BackupPath)
+		}
+	}
+
+	if err := Rollback(cp); err == nil {
+		t.Fatal("Expected the rollback to fail")
+	}
+	for _, path := range []string{first, second} {
+		if content, _ := os.ReadFile(path); string(content) != "new" {
+			t.Errorf("Expected %s untouched, got %q", filepath.Base(path), content)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected no staged copies left behind, got %d entries", len(entries))
+	}
+	cp, _ = checkpoint.Get(cp.ID)
+	if cp.Manifest.RolledBack {
+		t.Error("Expected a failed rollback not to be marked rolled back")
+	}
+}
+
+func TestRollbackCancelled(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	file := filepath.Join(dir, "file.txt")
+	os.WriteFile(file, []byte("old"), 0644)
+	cp, err := checkpoint.Create("sed -i s/old/new/ file.txt", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	os.Remove(file)
+	os.WriteFile(file, []byte("new"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RollbackContext(ctx, cp, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the rollback interrupted, got %v", err)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "new" {
+		t.Errorf("Expected the file untouched, got %q", content)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no staged copies left behind, got %d entries", len(entries))
+	}
+	if cp, _ = checkpoint.Get(cp.ID); cp.Manifest.RolledBack {
+		t.Error("Expected an interrupted rollback not to be marked rolled back")
+	}
+
+	// Not interrupted, it goes ahead
+	if err := RollbackContext(context.Background(), cp, nil); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "old" {
+		t.Errorf("Expected the file restored, got %q", content)
+	}
+}
+
+func TestResumeInterruptedRollback(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	var paths []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("old "+name), 0644)
+		paths = append(paths, path)
+	}
+	cp, err := checkpoint.Create("sed -i s/old/new/ *.txt", paths)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+	for _, path := range paths {
+		os.Remove(path)
+		os.WriteFile(path, []byte("new"), 0644)
+	}
+
+	// Stage every file, then stop halfway through the second rename, after
+	// the current b.txt was moved aside
+	j := startJournal(cp, nil, nil)
+	s, err := stage(context.Background(), restoreOrder(cp.Manifest.Files), inPlace, nil, j)
+	if err != nil {
+		t.Fatalf("Staging failed: %v", err)
+	}
+	if err := s.files[0].swap(j); err != nil {
+		t.Fatalf("Swap failed: %v", err)
+	}
+	b := s.files[1]
+	b.aside = b.temp + ".old"
+	j.record(journalEntry{Op: opAside, Path: b.target, Aside: b.aside})
+	os.Rename(b.target, b.aside)
+	j.close(false)
+
+	if !Interrupted(cp) {
+		t.Fatal("Expected the rollback to be resumable")
+	}
+	if err := Resume(cp, nil); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	for _, path := range paths {
+		if content, _ := os.ReadFile(path); string(content) != "old "+filepath.Base(path) {
+			t.Errorf("Expected %s restored, got %q", filepath.Base(path), content)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(paths) {
+		t.Errorf("Expected staged copies to be cleaned up, got %d entries", len(entries))
+	}
+	if Interrupted(cp) {
+		t.Error("Expected the journal to be removed")
+	}
+	cp, _ = checkpoint.Get(cp.ID)
+	if !cp.Manifest.RolledBack {
+		t.Error("Expected the checkpoint to be marked rolled back")
+	}
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+
+package rollback
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+func TestRollbackRecreatesParentsWithRecordedModes(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testDir := filepath.Join(tmpDir, "testdata")
+	project := filepath.Join(testDir, "project")
+	shared := filepath.Join(project, "shared")
+	os.MkdirAll(shared, 0755)
+	file := filepath.Join(shared, "run.sh")
+	os.WriteFile(file, []byte("#!/bin/sh\n"), 0755)
+	checkpoint.SetDirMode(shared, 0775|os.ModeSetgid)
+	checkpoint.SetDirMode(project, 0750)
+
+	cp, err := checkpoint.Create("rm -rf project", []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint: %v", err)
+	}
+
+	os.RemoveAll(project)
+	os.Chmod(testDir, 0700)
+
+	// A restrictive umask must not leak into the recreated tree
+	old := syscall.Umask(077)
+	defer syscall.Umask(old)
+
+	if err := Rollback(cp); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		project: 0750,
+		shared:  0775 | os.ModeSetgid,
+		file:    0755,
+		testDir: 0700, // existed during the rollback, so left alone
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("%s should exist: %v", path, err)
+			continue
+		}
+		if got := info.Mode() &^ os.ModeDir; got != want {
+			t.Errorf("%s: expected mode %v, got %v", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// A rollback restores its files all at once, so one that fails leaves the
+// tree as it was instead of half restored. Each backup is first copied next
+// to its target under a temporary name, on the same filesystem so it can be
+// renamed; only once every copy is made are they renamed into place.
+
+// stagedFile is a backup copied next to where it is restored
+type stagedFile struct {
+	checkpoint.FileEntry
+	target string
+	temp   string
+	aside  string // where the file it replaces was moved, until committed
+	placed bool
+}
+
+// staging is the set of copies for one rollback
+type staging struct {
+	files   []*stagedFile
+	dirs    []string // directories created for the copies, parents first
+	journal *journal
+}
+
+// restoreFiles restores files to target(path). Files that would restore onto
+// the same path are skipped and counted as failed; any other failure, or ctx
+// being done before the files are put in place, leaves every target as it
+// was. Progress is recorded in j, if set. It returns the files restored.
+func restoreFiles(ctx context.Context, files []checkpoint.FileEntry, target func(string) string, progress checkpoint.ProgressFunc, j *journal) ([]checkpoint.FileEntry, int, error) {
+	conflicts := restoreConflicts(files, target)
+	var restore []checkpoint.FileEntry
+	failed := 0
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		if conflicts[file.OriginalPath] {
+			logging.Warn(i18n.T("rollback.path_conflict", file.OriginalPath))
+			failed++
+			continue
+		}
+		restore = append(restore, file)
+	}
+
+	s, err := stage(ctx, restore, target, progress, j)
+	if err == nil {
+		err = s.commit()
+	}
+	if err != nil {
+		return nil, failed, fmt.Errorf("%w; nothing was restored", err)
+	}
+	return restore, failed, nil
+}
+
+// stage copies the backups of files next to their targets. If a copy fails,
+// or ctx is done, the ones already made are removed again.
+func stage(ctx context.Context, files []checkpoint.FileEntry, target func(string) string, progress checkpoint.ProgressFunc, j *journal) (*staging, error) {
+	s := &staging{journal: j}
+	var done, total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	for i, file := range files {
+		progress.Report(checkpoint.Progress{Phase: checkpoint.PhaseRestore, Done: i, Total: len(files), Path: file.OriginalPath, Bytes: done, TotalBytes: total})
+		if err := ctx.Err(); err != nil {
+			s.discard()
+			return nil, fmt.Errorf("interrupted: %w", err)
+		}
+		if err := s.add(file, target(file.OriginalPath)); err != nil {
+			s.discard()
+			return nil, err
+		}
+		done += file.Size
+	}
+	progress.Report(checkpoint.Progress{Phase: checkpoint.PhaseRestore, Done: len(files), Total: len(files), Bytes: done, TotalBytes: total})
+	return s, nil
+}
+
+func (s *staging) add(file checkpoint.FileEntry, target string) error {
+	backup, err := os.Stat(file.BackupPath)
+	if err != nil {
+		return fmt.Errorf("backup of %s is missing: %w", file.OriginalPath, err)
+	}
+	// Moving a directory aside would delete it with everything in it
+	if info, err := os.Lstat(target); err == nil && info.IsDir() {
+		return fmt.Errorf("failed to restore %s: a directory is in the way", target)
+	}
+
+	dir := filepath.Dir(target)
+	if err := s.mkdirAll(dir); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", target, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".safeshell-restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", target, err)
+	}
+	tmp.Close()
+	staged := &stagedFile{FileEntry: file, target: target, temp: tmp.Name()}
+	s.files = append(s.files, staged)
+	s.journal.record(journalEntry{Op: opStaged, Path: target, Temp: staged.temp})
+
+	if err := checkpoint.RestoreFile(file.BackupPath, staged.temp); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", target, err)
+	}
+	if copied, err := os.Stat(staged.temp); err != nil || copied.Size() != backup.Size() {
+		return fmt.Errorf("failed to restore %s: the copy is incomplete", target)
+	}
+	if err := os.Chmod(staged.temp, file.Mode); err != nil {
+		logging.Warn(i18n.T("rollback.perms_failed", target, err))
+	}
+	if err := checkpoint.RestoreTimes(staged.temp, file); err != nil {
+		logging.Warn(i18n.T("rollback.times_failed", target, err))
+	}
+	return nil
+}
+
+// mkdirAll creates dir and its missing parents, remembering them so they
+// can be removed if the rollback is abandoned
+func (s *staging) mkdirAll(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		s.dirs = append(s.dirs, missing[i])
+	}
+	return nil
+}
+
+// commit renames the copies into place. The files they replace are moved
+// aside first, and if a rename fails everything is moved back.
+func (s *staging) commit() error {
+	for i, f := range s.files {
+		if err := f.swap(s.journal); err != nil {
+			for j := i; j >= 0; j-- {
+				s.files[j].revert()
+			}
+			s.discard()
+			return err
+		}
+	}
+	for _, f := range s.files {
+		if f.aside != "" {
+			os.Remove(f.aside)
+		}
+	}
+	return nil
+}
+
+func (f *stagedFile) swap(j *journal) error {
+	if _, err := os.Lstat(f.target); err == nil {
+		f.aside = f.temp + ".old"
+		j.record(journalEntry{Op: opAside, Path: f.target, Aside: f.aside})
+		if err := os.Rename(f.target, f.aside); err != nil {
+			f.aside = ""
+			return fmt.Errorf("failed to restore %s: %w", f.target, err)
+		}
+	}
+	if err := os.Rename(f.temp, f.target); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", f.target, err)
+	}
+	f.placed = true
+	j.record(journalEntry{Op: opRestored, Path: f.target})
+	logging.Debug("restored", "path", f.target)
+	return nil
+}
+
+// revert puts back the file a swap replaced
+func (f *stagedFile) revert() {
+	if f.placed {
+		os.Remove(f.target)
+		f.placed = false
+	}
+	if f.aside != "" {
+		if err := os.Rename(f.aside, f.target); err != nil {
+			logging.Warn(i18n.T("rollback.aside_failed", f.target, f.aside, err))
+		}
+		f.aside = ""
+	}
+}
+
+// discard removes the copies not renamed into place, and the directories
+// created for them that are left empty
+func (s *staging) discard() {
+	for _, f := range s.files {
+		if !f.placed {
+			os.Remove(f.temp)
+		}
+		s.journal.record(journalEntry{Op: opReverted, Path: f.target})
+	}
+	for i := len(s.dirs) - 1; i >= 0; i-- {
+		os.Remove(s.dirs[i])
+	}
+}
@@ -0,0 +1,112 @@
+package rollback
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// SafetyTag marks the checkpoints taken automatically before a rollback
+const SafetyTag = "auto-pre-rollback"
+
+// checkpointBeforeRollback checkpoints the current state of the files a
+// rollback of cp is about to overwrite, so the rollback itself can be
+// undone. Files that don't exist yet are recorded as recreated, for the
+// undo to remove. A rollback goes ahead even if the checkpoint fails: it is
+// often what gets a broken tree back.
+func checkpointBeforeRollback(cp *checkpoint.Checkpoint, files []checkpoint.FileEntry) *checkpoint.Checkpoint {
+	var paths, recreated []string
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		if _, err := os.Lstat(f.OriginalPath); os.IsNotExist(err) {
+			recreated = append(recreated, f.OriginalPath)
+		} else {
+			paths = append(paths, f.OriginalPath)
+		}
+	}
+	// Undoing a rollback also removes what it recreated
+	for _, p := range cp.Manifest.Recreated {
+		if _, err := os.Lstat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 && len(recreated) == 0 {
+		return nil
+	}
+
+	safety, err := checkpoint.Create(fmt.Sprintf("rollback %s", cp.ID), paths)
+	if err == nil {
+		safety.Manifest.UndoOf = cp.ID
+		safety.Manifest.Recreated = recreated
+		if err = safety.Manifest.Save(safety.Dir); err == nil {
+			err = checkpoint.AddTag(safety.ID, SafetyTag)
+		}
+	}
+	if err != nil {
+		logging.Warn(i18n.T("rollback.safety_failed", err))
+		return nil
+	}
+	return safety
+}
+
+// settleSafety tells where the files a rollback overwrote were saved, or
+// deletes the checkpoint if the rollback failed and so changed nothing
+func settleSafety(safety *checkpoint.Checkpoint, succeeded bool) {
+	if safety == nil {
+		return
+	}
+	if !succeeded {
+		checkpoint.Delete(safety.ID)
+		return
+	}
+	fmt.Println(i18n.T("rollback.safety_saved", safety.ID))
+}
+
+// undoRecreated finishes rolling back a checkpoint taken before a rollback:
+// it removes the files that rollback recreated, and marks the checkpoint it
+// rolled back as not rolled back, so it can be rolled back again
+func undoRecreated(cp *checkpoint.Checkpoint) {
+	removed := 0
+	for _, p := range cp.Manifest.Recreated {
+		if err := os.Remove(p); err == nil {
+			removed++
+		} else if !os.IsNotExist(err) {
+			logging.Warn(i18n.T("rollback.restore_failed", p, err))
+		}
+	}
+	if removed > 0 {
+		fmt.Println(i18n.T("rollback.recreated_removed", removed, cp.Manifest.UndoOf))
+	}
+
+	if cp.Manifest.UndoOf == "" {
+		return
+	}
+	original, err := checkpoint.Get(cp.Manifest.UndoOf)
+	if err != nil || !original.Manifest.RolledBack {
+		return // Deleted since, or nothing to undo
+	}
+	original.Manifest.RolledBack = false
+	if err := original.Manifest.Save(original.Dir); err != nil {
+		logging.Warn(i18n.T("rollback.manifest_failed", err))
+	}
+}
+
+// LatestSafety returns the most recent checkpoint taken before a rollback
+// that has not been rolled back itself
+func LatestSafety() (*checkpoint.Checkpoint, error) {
+	checkpoints, err := checkpoint.ListByTag(SafetyTag)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range checkpoints {
+		if !cp.Manifest.RolledBack {
+			return cp, nil
+		}
+	}
+	return nil, fmt.Errorf("no rollback to undo")
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"os"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// HighlightStyle is the chroma style used for terminal output
+const HighlightStyle = "monokai"
+
+// HighlightLines syntax-highlights lines using the language detected from
+// filename's extension, returning one ANSI-colored string per input line.
+// The lines are highlighted together so multi-line comments and strings
+// are colored correctly. ok is false (and lines are returned as-is) when
+// the language is not recognized.
+func HighlightLines(filename string, lines []string) (highlighted []string, ok bool) {
+	lexer := lexers.Match(filename)
+	if lexer == nil || len(lines) == 0 {
+		return lines, false
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return lines, false
+	}
+
+	formatter := formatters.TTY256
+	if ct := os.Getenv("COLORTERM"); ct == "truecolor" || ct == "24bit" {
+		formatter = formatters.TTY16m
+	}
+	style := styles.Get(HighlightStyle)
+
+	highlighted = make([]string, 0, len(lines))
+	for _, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		var sb strings.Builder
+		if err := formatter.Format(&sb, style, chroma.Literator(tokens...)); err != nil {
+			return lines, false
+		}
+		highlighted = append(highlighted, strings.TrimRight(sb.String(), "\n"))
+	}
+
+	// Tokenizing must not change the line count; fall back if it did
+	if len(highlighted) < len(lines) {
+		return lines, false
+	}
+	return highlighted[:len(lines)], true
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"regexp"
+	"testing"
+)
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func TestHighlightLines(t *testing.T) {
+	lines := []string{
+		"package main",
+		"/* a comment",
+		"   spanning lines */",
+		`func main() { println("hi") }`,
+	}
+
+	highlighted, ok := HighlightLines("main.go", lines)
+	if !ok {
+		t.Fatal("Expected Go source to be highlighted")
+	}
+	if len(highlighted) != len(lines) {
+		t.Fatalf("Expected %d lines, got %d", len(lines), len(highlighted))
+	}
+
+	for i, line := range highlighted {
+		if !ansiPattern.MatchString(line) {
+			t.Errorf("Line %d has no color codes: %q", i, line)
+		}
+		if plain := ansiPattern.ReplaceAllString(line, ""); plain != lines[i] {
+			t.Errorf("Line %d text changed: got %q, want %q", i, plain, lines[i])
+		}
+	}
+}
+
+func TestHighlightLinesUnknownLanguage(t *testing.T) {
+	lines := []string{"just some text"}
+
+	highlighted, ok := HighlightLines("notes.unknownext", lines)
+	if ok {
+		t.Error("Unknown extensions should not be highlighted")
+	}
+	if len(highlighted) != 1 || highlighted[0] != lines[0] {
+		t.Errorf("Expected lines unchanged, got %q", highlighted)
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ciVars are environment variables set by common CI systems
+var ciVars = []string{
+	"CI",
+	"CONTINUOUS_INTEGRATION",
+	"GITHUB_ACTIONS",
+	"GITLAB_CI",
+	"BUILDKITE",
+	"CIRCLECI",
+	"TRAVIS",
+	"JENKINS_URL",
+	"TF_BUILD",
+	"TEAMCITY_VERSION",
+}
+
+// IsCI reports whether we are running under a CI system
+func IsCI() bool {
+	for _, name := range ciVars {
+		v := strings.ToLower(os.Getenv(name))
+		if v != "" && v != "false" && v != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// StdinIsTerminal reports whether stdin is attached to a terminal
+func StdinIsTerminal() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// CanPrompt reports whether it is safe to wait for an answer on stdin.
+// Under CI, or without a terminal, a prompt would hang or read garbage.
+func CanPrompt() bool {
+	return !IsCI() && StdinIsTerminal()
+}
@@ -0,0 +1,33 @@
+package util
+
+import "testing"
+
+func TestIsCI(t *testing.T) {
+	for _, name := range ciVars {
+		t.Setenv(name, "")
+	}
+	if IsCI() {
+		t.Fatal("Expected no CI with all variables cleared")
+	}
+
+	for _, v := range []string{"false", "0"} {
+		t.Setenv("CI", v)
+		if IsCI() {
+			t.Errorf("CI=%s should not count as CI", v)
+		}
+	}
+
+	t.Setenv("CI", "true")
+	if !IsCI() {
+		t.Error("Expected CI=true to be detected")
+	}
+
+	t.Setenv("CI", "")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if !IsCI() {
+		t.Error("Expected GITHUB_ACTIONS to be detected")
+	}
+	if CanPrompt() {
+		t.Error("Prompts must be disabled under CI")
+	}
+}
@@ -0,0 +1,319 @@
+// Package watch creates checkpoints automatically when watched files change,
+// covering edits made by tools that never go through the shell wrapper.
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// DefaultDebounce is how long a path must be quiet before changes are
+// checkpointed
+const DefaultDebounce = 2 * time.Second
+
+// Policy controls how one watched path is checkpointed
+type Policy struct {
+	Path        string
+	Debounce    time.Duration // quiet period before checkpointing
+	MinInterval time.Duration // minimum time between checkpoints
+	Exclude     []string      // glob patterns matched against names and relative paths
+}
+
+// PoliciesFromConfig converts the watch section of the config
+func PoliciesFromConfig(cfg []config.WatchPolicy) []Policy {
+	policies := make([]Policy, 0, len(cfg))
+	for _, c := range cfg {
+		path := c.Path
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		policies = append(policies, Policy{
+			Path:        path,
+			Debounce:    c.Debounce,
+			MinInterval: c.MinInterval,
+			Exclude:     c.Exclude,
+		})
+	}
+	return policies
+}
+
+// watched is the runtime state of one policy
+type watched struct {
+	Policy
+	pending map[string]bool
+	due     time.Time // when pending changes are checkpointed
+	last    time.Time // last checkpoint
+}
+
+// Watcher turns file changes into checkpoints
+type Watcher struct {
+	// OnCheckpoint is called after each checkpoint with the files it covers
+	OnCheckpoint func(cp *checkpoint.Checkpoint, files []string)
+	// OnError is called for errors that do not stop the watcher
+	OnError func(err error)
+
+	paths  []*watched
+	fsw    *fsnotify.Watcher
+	hashes map[string]string // content hash of each file when last checkpointed
+	now    func() time.Time
+}
+
+// New creates a Watcher for the given policies
+func New(policies []Policy) (*Watcher, error) {
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("nothing to watch")
+	}
+
+	w := &Watcher{
+		OnCheckpoint: func(*checkpoint.Checkpoint, []string) {},
+		OnError:      func(error) {},
+		hashes:       make(map[string]string),
+		now:          time.Now,
+	}
+	for _, p := range policies {
+		abs, err := filepath.Abs(p.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %s: %w", p.Path, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot watch %s: %w", p.Path, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("cannot watch %s: not a directory", p.Path)
+		}
+		p.Path = abs
+		if p.Debounce <= 0 {
+			p.Debounce = DefaultDebounce
+		}
+		w.paths = append(w.paths, &watched{Policy: p, pending: make(map[string]bool)})
+	}
+
+	// Match nested paths to the most specific policy
+	sort.Slice(w.paths, func(i, j int) bool {
+		return len(w.paths[i].Path) > len(w.paths[j].Path)
+	})
+	return w, nil
+}
+
+// Run takes a baseline checkpoint of every watched path, then checkpoints
+// changes until ctx is cancelled. Pending changes are flushed on exit.
+func (w *Watcher) Run(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watcher: %w", err)
+	}
+	defer fsw.Close()
+	w.fsw = fsw
+
+	for _, p := range w.paths {
+		if err := w.addTree(p.Path); err != nil {
+			return err
+		}
+		// Later checkpoints only hold changed files; the baseline holds
+		// everything as it was before the first change
+		cp, err := checkpoint.Create("watch: baseline "+p.Path, []string{p.Path})
+		if err != nil {
+			return fmt.Errorf("failed to create baseline checkpoint: %w", err)
+		}
+		p.last = w.now()
+		w.OnCheckpoint(cp, []string{p.Path})
+	}
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.flush(time.Time{})
+			return nil
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			w.handle(ev)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.OnError(err)
+		case <-timer.C:
+			w.flush(w.now())
+		}
+		w.resetTimer(timer)
+	}
+}
+
+// policyFor returns the policy covering path, or nil
+func (w *Watcher) policyFor(path string) *watched {
+	for _, p := range w.paths {
+		if path == p.Path || strings.HasPrefix(path, p.Path+string(filepath.Separator)) {
+			return p
+		}
+	}
+	return nil
+}
+
+// excluded reports whether path is ignored under p
+func (p *watched) excluded(path string) bool {
+	rel, err := filepath.Rel(p.Path, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		for _, name := range checkpoint.DefaultExclusions {
+			if part == name {
+				return true
+			}
+		}
+	}
+	for _, pattern := range p.Exclude {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addTree watches dir and all directories below it
+func (w *Watcher) addTree(dir string) error {
+	p := w.policyFor(dir)
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p != nil && p.excluded(path) {
+			return filepath.SkipDir
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return fmt.Errorf("cannot watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) handle(ev fsnotify.Event) {
+	p := w.policyFor(ev.Name)
+	if p == nil || p.excluded(ev.Name) {
+		return
+	}
+
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+			// Files may already exist in a directory moved into place
+			if err := w.addTree(ev.Name); err != nil {
+				w.OnError(err)
+			}
+			filepath.WalkDir(ev.Name, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && !p.excluded(path) {
+					p.pending[path] = true
+				}
+				return nil
+			})
+			w.schedule(p)
+			return
+		}
+	}
+
+	p.pending[ev.Name] = true
+	w.schedule(p)
+}
+
+// schedule pushes p's checkpoint back until the path has been quiet for the
+// debounce period, without checkpointing more often than MinInterval
+func (w *Watcher) schedule(p *watched) {
+	p.due = w.now().Add(p.Debounce)
+	if earliest := p.last.Add(p.MinInterval); p.due.Before(earliest) {
+		p.due = earliest
+	}
+}
+
+func (w *Watcher) resetTimer(timer *time.Timer) {
+	var next time.Time
+	for _, p := range w.paths {
+		if len(p.pending) > 0 && (next.IsZero() || p.due.Before(next)) {
+			next = p.due
+		}
+	}
+	timer.Stop()
+	if !next.IsZero() {
+		timer.Reset(next.Sub(w.now()))
+	}
+}
+
+// flush checkpoints pending changes that are due by now. A zero now
+// flushes everything.
+func (w *Watcher) flush(now time.Time) {
+	for _, p := range w.paths {
+		if len(p.pending) == 0 || (!now.IsZero() && now.Before(p.due)) {
+			continue
+		}
+		files := w.changedFiles(p)
+		p.pending = make(map[string]bool)
+		if len(files) == 0 {
+			continue
+		}
+
+		cp, err := checkpoint.Create(fmt.Sprintf("watch: %d file(s) changed in %s", len(files), p.Path), files)
+		if err != nil {
+			w.OnError(fmt.Errorf("failed to create checkpoint: %w", err))
+			continue
+		}
+		p.last = w.now()
+		w.OnCheckpoint(cp, files)
+	}
+}
+
+// changedFiles returns p's pending regular files whose content differs from
+// what was last checkpointed. Deleted files need no checkpoint: an earlier
+// one already holds them.
+func (w *Watcher) changedFiles(p *watched) []string {
+	var files []string
+	for path := range p.pending {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			continue
+		}
+		if w.hashes[path] == hash {
+			continue
+		}
+		w.hashes[path] = hash
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
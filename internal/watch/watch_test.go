@@ -0,0 +1,128 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func setupTestEnv(t *testing.T) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "safeshell-watch-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	config.Reset()
+	config.Init()
+	checkpoint.ResetIndex()
+
+	os.MkdirAll(filepath.Join(tmpDir, "testdata"), 0755)
+
+	cleanup := func() {
+		os.RemoveAll(tmpDir)
+	}
+	return tmpDir, cleanup
+}
+
+// replace rewrites a file without touching earlier hard-linked backups
+func replace(t *testing.T, path, content string) {
+	t.Helper()
+	os.Remove(path)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestWatcherCheckpointsChanges(t *testing.T) {
+	tmpDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(tmpDir, "testdata")
+	main := filepath.Join(dir, "main.go")
+	os.WriteFile(main, []byte("v1"), 0644)
+
+	w, err := New([]Policy{{Path: dir, Debounce: 50 * time.Millisecond, Exclude: []string{"*.log"}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	created := make(chan []string, 10)
+	w.OnCheckpoint = func(cp *checkpoint.Checkpoint, files []string) { created <- files }
+	w.OnError = func(err error) { t.Errorf("Watcher error: %v", err) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	expect := func(want ...string) {
+		t.Helper()
+		select {
+		case files := <-created:
+			if len(files) != len(want) {
+				t.Fatalf("Expected checkpoint of %v, got %v", want, files)
+			}
+			for i := range want {
+				if files[i] != want[i] {
+					t.Fatalf("Expected checkpoint of %v, got %v", want, files)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for checkpoint of %v", want)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case files := <-created:
+			t.Fatalf("Unexpected checkpoint of %v", files)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+
+	expect(dir) // baseline
+
+	// A burst of writes becomes a single checkpoint
+	replace(t, main, "v2")
+	replace(t, main, "v3")
+	expect(main)
+
+	// Same content again is deduplicated
+	replace(t, main, "v3")
+	expectNone()
+
+	// Excluded files are ignored
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte("noise"), 0644)
+	expectNone()
+
+	// New directories are watched too
+	sub := filepath.Join(dir, "pkg")
+	os.Mkdir(sub, 0755)
+	time.Sleep(50 * time.Millisecond)
+	util := filepath.Join(sub, "util.go")
+	os.WriteFile(util, []byte("package pkg"), 0644)
+	expect(util)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	cps, _ := checkpoint.List()
+	if len(cps) != 3 {
+		t.Errorf("Expected 3 checkpoints, got %d", len(cps))
+	}
+}
+
+func TestNewRejectsMissingPath(t *testing.T) {
+	if _, err := New([]Policy{{Path: "/nonexistent/safeshell-watch"}}); err == nil {
+		t.Error("Expected error for missing path")
+	}
+	if _, err := New(nil); err == nil {
+		t.Error("Expected error with no policies")
+	}
+}
@@ -0,0 +1,69 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/logging"
+	"github.com/qhkm/safeshell/internal/oplog"
+)
+
+// operationsLog is where wrapped commands are recorded, once the daemon has
+// said. Until then config says, which is only loaded if needed.
+var operationsLog string
+
+// auditEntry describes cmdName run with args, which returned err after
+// starting at start, for the operations log. The exit code is only set if
+// the command ran, or a shell would have reported one for it not running.
+func auditEntry(cmdName string, args []string, id string, start time.Time, err error) oplog.Entry {
+	e := oplog.Entry{
+		Time:         start,
+		Op:           oplog.OpWrap,
+		CheckpointID: id,
+		Command:      strings.TrimSpace(cmdName + " " + strings.Join(args, " ")),
+		User:         currentUser(),
+		Session:      checkpoint.GetSessionID(),
+		DurationMs:   time.Since(start).Milliseconds(),
+	}
+	e.WorkingDir, _ = os.Getwd()
+
+	var exitErr *exec.ExitError
+	ran := err == nil || errors.As(err, &exitErr)
+	if ran || errors.Is(err, ErrCommandNotFound) || errors.Is(err, ErrCannotExecute) {
+		code := exitCode(err)
+		e.ExitCode = &code
+	}
+	if err != nil && !ran {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// audit records a wrapped command in the operations log. It is best-effort:
+// a command is never failed for not being recorded.
+func audit(cmdName string, args []string, id string, start time.Time, err error) {
+	path := operationsLog
+	if path == "" {
+		path = config.GetOperationsLog()
+	}
+	if err := oplog.AppendTo(path, auditEntry(cmdName, args, id, start, err)); err != nil {
+		logging.Debug("command not recorded", "log", path, "error", err)
+	}
+}
+
+// currentUser returns who is running safeshell, by name
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,32 @@
+package wrapper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAuditEntry(t *testing.T) {
+	start := time.Now().Add(-time.Second)
+
+	e := auditEntry("rm", []string{"-rf", "build"}, "20240101-000000-abcd", start, nil)
+	if e.Command != "rm -rf build" || e.CheckpointID != "20240101-000000-abcd" {
+		t.Errorf("Unexpected command or checkpoint: %+v", e)
+	}
+	if e.ExitCode == nil || *e.ExitCode != 0 || e.Failed() {
+		t.Errorf("Expected exit code 0, got %+v", e)
+	}
+	if e.WorkingDir == "" || e.DurationMs < 1000 {
+		t.Errorf("Expected working dir and duration, got %+v", e)
+	}
+
+	e = auditEntry("frobnicate", nil, "", start, fmt.Errorf("%w: no such file", ErrCommandNotFound))
+	if e.ExitCode == nil || *e.ExitCode != 127 || e.Error == "" {
+		t.Errorf("Expected exit code 127 and the error, got %+v", e)
+	}
+
+	e = auditEntry("rm", []string{"-rf", "/"}, "", start, fmt.Errorf("%w by rule #1", ErrRefused))
+	if e.ExitCode != nil || !e.Failed() {
+		t.Errorf("Expected no exit code for a refused command, got %+v", e)
+	}
+}
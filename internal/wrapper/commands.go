@@ -1,11 +1,21 @@
 package wrapper
 
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
 // CommandDef defines a wrapped command and its properties
 type CommandDef struct {
 	Name        string
 	RiskLevel   string // HIGH, MEDIUM, LOW
 	Description string
 	Parser      func(args []string) ([]string, error) // Returns target paths to backup
+
+	// InPlace is set for commands writing to their targets in place, which
+	// a hard link's backup would share: they are backed up as copies
+	InPlace bool
 }
 
 var SupportedCommands = map[string]CommandDef{
@@ -27,6 +37,45 @@ var SupportedCommands = map[string]CommandDef{
 		Description: "Copy files (backup destination if overwriting)",
 		Parser:      ParseCpArgs,
 	},
+	"rsync": {
+		Name:        "rsync",
+		RiskLevel:   "HIGH",
+		Description: "Sync files (backup destination if --delete removes files)",
+		Parser:      ParseRsyncArgs,
+	},
+	"find": {
+		Name:        "find",
+		RiskLevel:   "HIGH",
+		Description: "find -delete or -exec rm (backup what it finds)",
+		Parser:      ParseFindArgs,
+	},
+	"git": {
+		Name:        "git",
+		RiskLevel:   "HIGH",
+		Description: "git clean, and checkout or restore of changed files",
+		Parser:      ParseGitArgs,
+	},
+	"dd": {
+		Name:        "dd",
+		RiskLevel:   "HIGH",
+		Description: "Convert and copy a file (backup the output file, of=)",
+		Parser:      ParseDdArgs,
+		InPlace:     true,
+	},
+	"truncate": {
+		Name:        "truncate",
+		RiskLevel:   "HIGH",
+		Description: "Shrink or extend files",
+		Parser:      ParseTruncateArgs,
+		InPlace:     true,
+	},
+	"shred": {
+		Name:        "shred",
+		RiskLevel:   "HIGH",
+		Description: "Overwrite files to hide their contents",
+		Parser:      ParseShredArgs,
+		InPlace:     true,
+	},
 	"chmod": {
 		Name:        "chmod",
 		RiskLevel:   "MEDIUM",
@@ -50,3 +99,41 @@ func GetCommand(cmd string) (CommandDef, bool) {
 	def, ok := SupportedCommands[cmd]
 	return def, ok
 }
+
+// errNotWrapped is returned when no checkpoint is created because the
+// command is not in wrapped_commands or custom_commands
+var errNotWrapped = errors.New("not a wrapped command")
+
+// IsWrapped reports whether cmd is in wrapped_commands or custom_commands,
+// so a checkpoint is created before it runs
+func IsWrapped(cmd string) bool {
+	return slices.Contains(wrapSettings().Wrapped(), cmd)
+}
+
+// commandFor returns how to find cmd's targets: its definition if safeshell
+// has one, its custom_commands entry if there is one, and otherwise every
+// argument that isn't a flag, like rm. Only the latter two need the
+// settings, which the daemon sends if it's running.
+func commandFor(cmd string) CommandDef {
+	if def, ok := SupportedCommands[cmd]; ok {
+		return def
+	}
+	if custom, ok := wrapSettings().CustomCommand(cmd); ok {
+		risk := strings.ToUpper(custom.Risk)
+		if risk == "" {
+			risk = "HIGH"
+		}
+		return CommandDef{
+			Name:        cmd,
+			RiskLevel:   risk,
+			Description: "Custom command (custom_commands)",
+			Parser:      targetParser(cmd, custom.Targets),
+		}
+	}
+	return CommandDef{
+		Name:        cmd,
+		RiskLevel:   "HIGH",
+		Description: "Custom command (backup every path argument)",
+		Parser:      ParseRmArgs,
+	}
+}
@@ -0,0 +1,43 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/output"
+)
+
+// largeHighRisk returns how much the targets of a wrapped high-risk
+// command hold, if it is at least confirm_high_risk_mb and the command
+// should ask before running; nil otherwise
+func largeHighRisk(cmdName string, def CommandDef, args, targets []string) *commandFacts {
+	if len(targets) == 0 || def.RiskLevel != "HIGH" || !IsWrapped(cmdName) {
+		return nil
+	}
+	limit := wrapSettings().ConfirmHighRiskMB
+	if limit <= 0 {
+		return nil
+	}
+	facts := newCommandFacts(cmdName, def, args, targets)
+	facts.measure()
+	if facts.size < int64(limit)*1024*1024 {
+		return nil
+	}
+	return facts
+}
+
+// confirmHighRisk tells what a large high-risk command is about to act on
+// and the checkpoint id holding it, if one could be created, and asks
+// whether to run it
+func confirmHighRisk(cmdName string, args []string, facts *commandFacts, id string) bool {
+	command := strings.TrimSpace(cmdName + " " + strings.Join(args, " "))
+	fmt.Fprintln(os.Stderr, i18n.T("wrap.high_risk", command, facts.files, output.FormatBytes(facts.size)))
+	if id != "" {
+		fmt.Fprintln(os.Stderr, i18n.T("wrap.high_risk_checkpoint", id))
+	} else {
+		fmt.Fprintln(os.Stderr, i18n.T("wrap.high_risk_no_checkpoint"))
+	}
+	return askToRun(cmdName)
+}
@@ -0,0 +1,121 @@
+package wrapper
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// findRemovers are the commands that, run by find -exec on what it finds,
+// destroy or move it
+var findRemovers = []string{"rm", "rmdir", "unlink", "shred", "truncate", "mv"}
+
+// ParseFindArgs parses find arguments and, if the expression deletes what
+// it finds (-delete, or -exec rm and the like), runs find again with that
+// action replaced by -print0 to learn what it would delete. Expressions
+// with other side effects, such as running another command, aren't run
+// twice and have no targets.
+func ParseFindArgs(args []string) ([]string, error) {
+	// find [-H] [-L] [-P] [-D opts] [-Olevel] [path...] [expression]
+	i := 0
+	for i < len(args) {
+		arg := args[i]
+		if arg == "-D" {
+			i += 2
+			continue
+		}
+		if arg != "-H" && arg != "-L" && arg != "-P" && !strings.HasPrefix(arg, "-O") {
+			break
+		}
+		i++
+	}
+	for i < len(args) && !strings.HasPrefix(args[i], "-") && args[i] != "(" && args[i] != "!" {
+		i++
+	}
+	if i > len(args) {
+		return []string{}, nil
+	}
+
+	listing, ok := findListing(args[i:])
+	if !ok {
+		return []string{}, nil
+	}
+	findPath, err := findRealCommand("find")
+	if err != nil {
+		return []string{}, nil
+	}
+	// find fails on unreadable directories too, after listing the rest
+	out, _ := exec.Command(findPath, append(slices.Clone(args[:i]), listing...)...).Output()
+
+	var found []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			found = append(found, p)
+		}
+	}
+	return outermost(found), nil
+}
+
+// findListing returns expr with its deleting actions replaced by -print0
+// and its printing ones by -true, and whether it deletes anything and can
+// be run without side effects
+func findListing(expr []string) ([]string, bool) {
+	var listing []string
+	deletes := false
+	for i := 0; i < len(expr); i++ {
+		switch arg := expr[i]; arg {
+		case "-delete":
+			listing = append(listing, "-print0")
+			deletes = true
+		case "-exec", "-execdir", "-ok", "-okdir":
+			end := i + 1
+			for end < len(expr) && expr[end] != ";" && !(expr[end] == "+" && expr[end-1] == "{}") {
+				end++
+			}
+			if i+1 >= len(expr) || !slices.Contains(findRemovers, filepath.Base(expr[i+1])) {
+				return nil, false
+			}
+			listing = append(listing, "-print0")
+			deletes = true
+			i = end
+		case "-print", "-print0", "-ls":
+			listing = append(listing, "-true")
+		case "-printf":
+			listing = append(listing, "-true")
+			i++
+		case "-fprint", "-fprint0", "-fls", "-fprintf":
+			return nil, false
+		default:
+			listing = append(listing, arg)
+		}
+	}
+	return listing, deletes
+}
+
+// outermost drops the paths inside others in the list, which backing up
+// those covers
+func outermost(paths []string) []string {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		seen[filepath.Clean(p)] = true
+	}
+	var kept []string
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		inside := false
+		for dir := p; !inside; {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			inside, dir = seen[parent], parent
+		}
+		if !inside && !slices.Contains(kept, p) {
+			kept = append(kept, p)
+		}
+	}
+	sort.Strings(kept)
+	return kept
+}
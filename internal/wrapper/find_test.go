@@ -0,0 +1,55 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFindArgs(t *testing.T) {
+	if _, err := exec.LookPath("find"); err != nil {
+		t.Skip("find not installed")
+	}
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "build", "obj"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.o"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.c"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(dir, "build", "obj", "c.o"), []byte("c"), 0644)
+	marker := filepath.Join(dir, "ran")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"delete", []string{dir, "-name", "*.o", "-delete"}, []string{filepath.Join(dir, "a.o"), filepath.Join(dir, "build", "obj", "c.o")}},
+		{"exec rm", []string{dir, "-type", "f", "-name", "*.o", "-exec", "rm", "-f", "{}", ";"}, []string{filepath.Join(dir, "a.o"), filepath.Join(dir, "build", "obj", "c.o")}},
+		{"exec rm +", []string{"-L", dir, "-name", "build", "-print", "-exec", "/bin/rm", "-rf", "{}", "+"}, []string{filepath.Join(dir, "build")}},
+		{"nested", []string{dir, "-path", "*build*", "-delete"}, []string{filepath.Join(dir, "build")}},
+		{"no delete", []string{dir, "-name", "*.o"}, []string{}},
+		{"other exec", []string{dir, "-exec", "touch", marker, ";", "-delete"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFindArgs(tt.args)
+			if err != nil {
+				t.Fatalf("ParseFindArgs returned error: %v", err)
+			}
+			if result == nil {
+				result = []string{}
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParseFindArgs(%v) = %v, want %v", tt.args, result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expressions running other commands should not be run")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.o")); err != nil {
+		t.Error("Listing the targets should not delete them")
+	}
+}
@@ -0,0 +1,232 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// gitGlobalValues are git's options before the subcommand that take a value
+// as the next argument
+var gitGlobalValues = []string{"-C", "-c", "--git-dir", "--work-tree", "--namespace", "--config-env", "--super-prefix"}
+
+// ParseGitArgs parses git arguments and returns the files the subcommand is
+// about to throw away: what 'git clean' would remove, and the files with
+// changes a 'git checkout' or 'git restore' of paths, or a forced checkout,
+// would overwrite. git itself is asked which files those are. Other
+// subcommands, and repositories git can't read, have no targets.
+func ParseGitArgs(args []string) ([]string, error) {
+	var global []string
+	dir := ""
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		global = append(global, args[i])
+		if slices.Contains(gitGlobalValues, args[i]) && i+1 < len(args) {
+			i++
+			global = append(global, args[i])
+			if args[i-1] == "-C" && filepath.IsAbs(args[i]) {
+				dir = args[i]
+			} else if args[i-1] == "-C" {
+				dir = filepath.Join(dir, args[i])
+			}
+		}
+	}
+	if i == len(args) {
+		return []string{}, nil
+	}
+	git := gitRunner{global: global, dir: dir}
+
+	switch sub, rest := args[i], args[i+1:]; sub {
+	case "clean":
+		return git.cleanTargets(rest), nil
+	case "checkout":
+		return git.checkoutTargets(rest), nil
+	case "restore":
+		return git.restoreTargets(rest), nil
+	}
+	return []string{}, nil
+}
+
+// gitRunner runs git with the global options the wrapped command was given
+type gitRunner struct {
+	global []string
+	dir    string // -C directories, joined
+}
+
+// output runs git with args, returning nothing if it fails. Messages are
+// kept in English to be read back. It is the real git, not a shim that
+// would come back here.
+func (g gitRunner) output(args ...string) string {
+	gitPath, err := findRealCommand("git")
+	if err != nil {
+		return ""
+	}
+	cmdArgs := append(append([]string{"-c", "core.quotePath=false"}, g.global...), args...)
+	cmd := exec.Command(gitPath, cmdArgs...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// cleanTargets asks 'git clean --dry-run' what 'git clean' with args would
+// remove. Without --force (or with --dry-run) git removes nothing, unless
+// clean.requireForce is off, so the dry run covers that too.
+func (g gitRunner) cleanTargets(args []string) []string {
+	dryRun := []string{"clean", "--dry-run"}
+	var pathspecs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			pathspecs = append(pathspecs, args[i+1:]...)
+			i = len(args)
+		case arg == "-e" || arg == "--exclude":
+			if i+1 < len(args) {
+				i++
+				dryRun = append(dryRun, "--exclude="+args[i])
+			}
+		case strings.HasPrefix(arg, "--exclude="):
+			dryRun = append(dryRun, arg)
+		case strings.HasPrefix(arg, "--"):
+			// --force, --quiet and --interactive would get in the way
+			if arg == "--dry-run" {
+				return []string{}
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg); j++ {
+				switch c := arg[j]; c {
+				case 'd', 'x', 'X':
+					dryRun = append(dryRun, "-"+string(c))
+				case 'f':
+					// -ff also removes nested repositories
+					dryRun = append(dryRun, "-f")
+				case 'n':
+					return []string{}
+				case 'e':
+					exclude := arg[j+1:]
+					if exclude == "" && i+1 < len(args) {
+						i++
+						exclude = args[i]
+					}
+					dryRun = append(dryRun, "--exclude="+exclude)
+					j = len(arg)
+				}
+			}
+		default:
+			pathspecs = append(pathspecs, arg)
+		}
+	}
+	dryRun = append(append(dryRun, "--"), pathspecs...)
+
+	// "Would remove path", relative to the directory git runs in
+	var targets []string
+	for _, line := range strings.Split(g.output(dryRun...), "\n") {
+		if p, ok := strings.CutPrefix(line, "Would remove "); ok {
+			targets = append(targets, filepath.Join(g.dir, unquoteGitPath(p)))
+		}
+	}
+	return targets
+}
+
+// checkoutTargets returns the changed files 'git checkout' with args would
+// overwrite: those under its pathspecs, or every changed file when
+// switching branches with --force. A plain branch switch keeps changes.
+func (g gitRunner) checkoutTargets(args []string) []string {
+	force := false
+	var operands, pathspecs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			pathspecs = append(pathspecs, args[i+1:]...)
+			i = len(args)
+		case arg == "-f" || arg == "--force":
+			force = true
+		case arg == "-b" || arg == "-B" || arg == "--orphan" || arg == "--conflict":
+			// New branches start from where the worktree is
+			if i+1 < len(args) {
+				i++
+			}
+		case strings.HasPrefix(arg, "-"):
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	// git checkout <commit> path... or git checkout path..., where the
+	// first operand is a commit if git knows it as one
+	if len(operands) > 0 {
+		if g.output("rev-parse", "--verify", "--quiet", operands[0]+"^{commit}") != "" {
+			operands = operands[1:]
+		}
+		pathspecs = append(operands, pathspecs...)
+	}
+	if len(pathspecs) == 0 && !force {
+		return []string{}
+	}
+	return g.changedFiles(pathspecs)
+}
+
+// restoreTargets returns the changed files 'git restore' with args would
+// overwrite in the worktree; --staged alone only touches the index
+func (g gitRunner) restoreTargets(args []string) []string {
+	staged, worktree := false, false
+	var pathspecs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			pathspecs = append(pathspecs, args[i+1:]...)
+			i = len(args)
+		case arg == "-S" || arg == "--staged":
+			staged = true
+		case arg == "-W" || arg == "--worktree":
+			worktree = true
+		case arg == "-s" || arg == "--source":
+			if i+1 < len(args) {
+				i++
+			}
+		case strings.HasPrefix(arg, "-"):
+		default:
+			pathspecs = append(pathspecs, arg)
+		}
+	}
+	if (staged && !worktree) || len(pathspecs) == 0 {
+		return []string{}
+	}
+	return g.changedFiles(pathspecs)
+}
+
+// changedFiles returns the files under pathspecs, all of them if none,
+// that differ from HEAD, staged or not
+func (g gitRunner) changedFiles(pathspecs []string) []string {
+	root := strings.TrimSpace(g.output("rev-parse", "--show-toplevel"))
+	if root == "" {
+		return []string{}
+	}
+	var files []string
+	out := g.output(append([]string{"diff", "--name-only", "-z", "HEAD", "--"}, pathspecs...)...)
+	for _, rel := range strings.Split(out, "\x00") {
+		if rel != "" {
+			files = append(files, filepath.Join(root, filepath.FromSlash(rel)))
+		}
+	}
+	return files
+}
+
+// unquoteGitPath undoes the C-style quoting git gives paths with unusual
+// characters
+func unquoteGitPath(p string) string {
+	if strings.HasPrefix(p, `"`) {
+		if unquoted, err := strconv.Unquote(p); err == nil {
+			return unquoted
+		}
+	}
+	return p
+}
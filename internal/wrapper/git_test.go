@@ -0,0 +1,73 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGitArgs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// git reports paths with symlinks resolved, as in /private/var on macOS
+	repo, _ := filepath.EvalSymlinks(t.TempDir())
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	os.MkdirAll(filepath.Join(repo, "src"), 0755)
+	os.WriteFile(filepath.Join(repo, "src", "main.go"), []byte("main"), 0644)
+	os.WriteFile(filepath.Join(repo, "README"), []byte("readme"), 0644)
+	os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("*.log\n"), 0644)
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("branch", "other")
+	os.WriteFile(filepath.Join(repo, "src", "main.go"), []byte("changed"), 0644)
+	os.WriteFile(filepath.Join(repo, "new.go"), []byte("new"), 0644)
+	os.MkdirAll(filepath.Join(repo, "tmp"), 0755)
+	os.WriteFile(filepath.Join(repo, "tmp", "scratch"), []byte("scratch"), 0644)
+	os.WriteFile(filepath.Join(repo, "debug.log"), []byte("log"), 0644)
+
+	main := filepath.Join(repo, "src", "main.go")
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"clean", []string{"-C", repo, "clean", "-f"}, []string{filepath.Join(repo, "new.go")}},
+		{"clean -fdx", []string{"-C", repo, "clean", "-fdx"}, []string{filepath.Join(repo, "debug.log"), filepath.Join(repo, "new.go"), filepath.Join(repo, "tmp")}},
+		{"clean pathspec", []string{"-C", repo, "clean", "-fd", "--", "tmp"}, []string{filepath.Join(repo, "tmp")}},
+		{"clean dry run", []string{"-C", repo, "clean", "-nfd"}, []string{}},
+		{"checkout -- .", []string{"-C", repo, "checkout", "--", "."}, []string{main}},
+		{"checkout path", []string{"-C", repo, "checkout", "src"}, []string{main}},
+		{"checkout commit path", []string{"-C", repo, "checkout", "HEAD", "README", "src/main.go"}, []string{main}},
+		{"checkout branch", []string{"-C", repo, "checkout", "other"}, []string{}},
+		{"checkout -f branch", []string{"-C", repo, "checkout", "-f", "other"}, []string{main}},
+		{"restore", []string{"-C", repo, "restore", "."}, []string{main}},
+		{"restore --staged", []string{"-C", repo, "restore", "--staged", "."}, []string{}},
+		{"status", []string{"-C", repo, "status"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseGitArgs(tt.args)
+			if err != nil {
+				t.Fatalf("ParseGitArgs returned error: %v", err)
+			}
+			if result == nil {
+				result = []string{}
+			}
+			for i := range result {
+				result[i] = filepath.Clean(result[i])
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParseGitArgs(%v) = %v, want %v", tt.args, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// Where the wrapper's own messages go. Some build tools fail a step that
+// writes anything to stderr, so they can be logged or dropped instead; the
+// wrapped command's streams are never touched.
+const (
+	MessagesStderr = "stderr"
+	MessagesLog    = "log"
+	MessagesOff    = "off"
+)
+
+// MessagesEnv overrides the wrapper_messages setting
+const MessagesEnv = "SAFESHELL_MESSAGES"
+
+// messages is where the wrapper writes its messages, set by useMessages
+var messages = os.Stderr
+
+// MessagesLogPath returns the file messages are appended to in log mode. It
+// is fixed under the home directory, like the daemon socket, so it can be
+// opened without loading config.
+func MessagesLogPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".safeshell", "wrapper.log")
+}
+
+// useMessages sends messages where mode says, unless the environment says
+// otherwise. A log that can't be opened falls back to stderr.
+func useMessages(mode, command string) {
+	if env := os.Getenv(MessagesEnv); env != "" {
+		mode = env
+	}
+	switch mode {
+	case MessagesLog:
+		f, err := os.OpenFile(MessagesLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), command)
+		messages = f
+	case MessagesOff:
+		if f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			messages = f
+		}
+	}
+}
+
+// withMessages runs fn with os.Stderr pointing where messages go, so the
+// warnings of checkpoint creation and the output of hooks follow them
+func withMessages(fn func()) {
+	stderr := os.Stderr
+	os.Stderr = messages
+	defer func() { os.Stderr = stderr }()
+	fn()
+}
+
+// inform tells something where messages go, unless the log level is quiet
+func inform(msg string) {
+	withMessages(func() { logging.Info(msg) })
+}
+
+// warn tells a problem where messages go
+func warn(msg string) {
+	withMessages(func() { logging.Warn(msg) })
+}
@@ -0,0 +1,68 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// exitCode is the status a shell would report for a command that returned
+// err when run
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return ExitStatus(exitErr)
+	case errors.Is(err, ErrCommandNotFound):
+		return 127
+	case errors.Is(err, ErrCannotExecute):
+		return 126
+	}
+	return 1
+}
+
+// moveDestinations maps the targets of a command that moves them, mv, to
+// where they are once it ran: into the destination directory, or renamed
+// to the destination. It is nil for other commands.
+func moveDestinations(cmdName string, args, targets []string) map[string]string {
+	if cmdName != "mv" || len(targets) == 0 {
+		return nil
+	}
+	parsed := mvOptions.parse(args)
+	var dest string
+	if dirs := parsed.values["target-directory"]; len(dirs) > 0 {
+		dest = dirs[len(dirs)-1]
+	} else if n := len(parsed.operands); n >= 2 {
+		dest = parsed.operands[n-1]
+	} else {
+		return nil
+	}
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil
+	}
+
+	// A lone source renamed to dest may have made it a directory
+	intoDir := parsed.has("target-directory") || len(targets) > 1
+	if !intoDir && !hasFlag(args, "-T|--no-target-directory") {
+		_, err := os.Lstat(filepath.Join(dest, filepath.Base(targets[0])))
+		intoDir = err == nil
+	}
+
+	moves := make(map[string]string)
+	for _, target := range targets {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			continue
+		}
+		if intoDir {
+			moves[abs] = filepath.Join(dest, filepath.Base(abs))
+		} else {
+			moves[abs] = dest
+		}
+	}
+	return moves
+}
@@ -0,0 +1,50 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMoveDestinations(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "dest")
+	os.Mkdir(dest, 0755)
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	// a already moved into dest; b renamed to a directory of its own
+	os.WriteFile(filepath.Join(dest, "a"), nil, 0644)
+	renamed := filepath.Join(dir, "renamed")
+	os.Mkdir(renamed, 0755)
+
+	tests := []struct {
+		cmd      string
+		args     []string
+		targets  []string
+		expected map[string]string
+	}{
+		{"mv", []string{a, dest}, []string{a}, map[string]string{a: filepath.Join(dest, "a")}},
+		{"mv", []string{"-t", dest, a, b}, []string{a, b}, map[string]string{a: filepath.Join(dest, "a"), b: filepath.Join(dest, "b")}},
+		{"mv", []string{b, renamed}, []string{b}, map[string]string{b: renamed}},
+		{"mv", []string{"-T", a, dest}, []string{a}, map[string]string{a: dest}},
+		{"rm", []string{a}, []string{a}, nil},
+	}
+	for _, tt := range tests {
+		if got := moveDestinations(tt.cmd, tt.args, tt.targets); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("moveDestinations(%s %q) = %v, want %v", tt.cmd, tt.args, got, tt.expected)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if got := exitCode(nil); got != 0 {
+		t.Errorf("exitCode(nil) = %d, want 0", got)
+	}
+	if got := exitCode(fmt.Errorf("%w: nope", ErrCommandNotFound)); got != 127 {
+		t.Errorf("exitCode(ErrCommandNotFound) = %d, want 127", got)
+	}
+	if got := exitCode(ErrCannotExecute); got != 126 {
+		t.Errorf("exitCode(ErrCannotExecute) = %d, want 126", got)
+	}
+}
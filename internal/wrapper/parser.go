@@ -1,100 +1,320 @@
 package wrapper
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 )
 
-// ParseRmArgs parses rm command arguments and returns target paths
-func ParseRmArgs(args []string) ([]string, error) {
-	var targets []string
+// optionSpec describes a command's options the way GNU getopt sees them:
+// which take a value, so their values aren't taken for operands. Options
+// not listed take no value, or only one given with --name=value.
+type optionSpec struct {
+	short map[byte]string // short option -> the long option it stands for
+	long  []string        // long options that take a value, without --
 
-	for _, arg := range args {
-		// Skip flags
-		if strings.HasPrefix(arg, "-") {
-			continue
+	// flags are the long options taking no value that begin like one in
+	// long, so an abbreviation of both is known to be ambiguous
+	flags []string
+
+	// exact is set for commands whose long options can't be abbreviated,
+	// like rsync, which parses them with popt rather than getopt
+	exact bool
+
+	// isOperand reports whether an argument starting with - is an operand
+	// after all, like chmod's -w mode
+	isOperand func(arg string) bool
+}
+
+// parsedArgs are a command's arguments split up by optionSpec.parse
+type parsedArgs struct {
+	operands []string
+	values   map[string][]string // long option -> the values given to it, "" if none
+}
+
+// parse splits args into operands and option values like GNU getopt: short
+// options may be combined (-rf) and take their value attached (-tDIR) or
+// as the next argument, long options may be abbreviated (--target for
+// --target-directory) and take their value after = or as the next
+// argument, "--" ends the options and "-" alone is an operand. Options may
+// follow operands, unless POSIXLY_CORRECT is set.
+func (spec optionSpec) parse(args []string) parsedArgs {
+	parsed := parsedArgs{values: make(map[string][]string)}
+	posix := os.Getenv("POSIXLY_CORRECT") != ""
+	options := true
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case !options:
+			parsed.operands = append(parsed.operands, arg)
+		case arg == "--":
+			options = false
+		case arg == "-" || !strings.HasPrefix(arg, "-") || (spec.isOperand != nil && spec.isOperand(arg)):
+			parsed.operands = append(parsed.operands, arg)
+			options = !posix
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			name = spec.longName(name)
+			if !hasValue && slices.Contains(spec.long, name) && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			parsed.values[name] = append(parsed.values[name], value)
+		default:
+			for j := 1; j < len(arg); j++ {
+				name, ok := spec.short[arg[j]]
+				if !ok {
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				parsed.values[name] = append(parsed.values[name], value)
+				break
+			}
 		}
-		targets = append(targets, arg)
 	}
+	return parsed
+}
 
-	return targets, nil
+// longName returns the long option name stands for: itself if it is one,
+// or else the only option in long or flags it begins. An ambiguous or
+// unknown name is left alone, as getopt would refuse it.
+func (spec optionSpec) longName(name string) string {
+	if spec.exact || name == "" || slices.Contains(spec.long, name) || slices.Contains(spec.flags, name) {
+		return name
+	}
+	match := ""
+	for _, option := range slices.Concat(spec.long, spec.flags) {
+		if strings.HasPrefix(option, name) {
+			if match != "" {
+				return name
+			}
+			match = option
+		}
+	}
+	if match == "" {
+		return name
+	}
+	return match
+}
+
+// has reports whether the long option name was given
+func (p parsedArgs) has(name string) bool {
+	return len(p.values[name]) > 0
+}
+
+var (
+	rmOptions = optionSpec{}
+	mvOptions = optionSpec{
+		short: map[byte]string{'S': "suffix", 't': "target-directory"},
+		long:  []string{"suffix", "target-directory"},
+		flags: []string{"strip-trailing-slashes"},
+	}
+	cpOptions = optionSpec{
+		short: map[byte]string{'S': "suffix", 't': "target-directory"},
+		long:  []string{"suffix", "target-directory", "no-preserve", "sparse"},
+		flags: []string{"strip-trailing-slashes", "symbolic-link", "no-clobber", "no-dereference", "no-target-directory"},
+	}
+	rsyncOptions = optionSpec{
+		short: map[byte]string{'e': "rsh", 'B': "block-size", 'f': "filter", 'T': "temp-dir", 'M': "remote-option", '@': "modify-window"},
+		long: []string{
+			"rsh", "rsync-path", "filter", "exclude", "exclude-from", "include", "include-from",
+			"files-from", "temp-dir", "compare-dest", "copy-dest", "link-dest", "partial-dir",
+			"backup-dir", "suffix", "chmod", "chown", "usermap", "groupmap", "block-size",
+			"max-delete", "max-size", "min-size", "max-alloc", "timeout", "contimeout", "port",
+			"sockopts", "out-format", "log-file", "log-file-format", "password-file", "bwlimit",
+			"write-batch", "only-write-batch", "read-batch", "protocol", "iconv", "checksum-choice",
+			"compress-choice", "compress-level", "skip-compress", "modify-window", "remote-option",
+			"outbuf", "stop-after", "stop-at", "info", "debug", "address", "copy-as",
+		},
+		exact: true,
+	}
+	truncateOptions = optionSpec{
+		short: map[byte]string{'s': "size", 'r': "reference"},
+		long:  []string{"size", "reference"},
+	}
+	shredOptions = optionSpec{
+		short: map[byte]string{'n': "iterations", 's': "size"},
+		long:  []string{"iterations", "size", "random-source"},
+		flags: []string{"remove"},
+	}
+	chmodOptions = optionSpec{
+		long:      []string{"reference"},
+		flags:     []string{"recursive"},
+		isOperand: isChmodMode,
+	}
+	chownOptions = optionSpec{
+		long:  []string{"reference", "from"},
+		flags: []string{"recursive"},
+	}
+)
+
+// isChmodMode reports whether arg is a mode like -w or -rwx, which chmod
+// takes even though it starts with -
+func isChmodMode(arg string) bool {
+	return len(arg) > 1 && strings.ContainsRune("rwxXstugoa,+=01234567", rune(arg[1]))
+}
+
+// ParseRmArgs parses rm command arguments and returns target paths
+func ParseRmArgs(args []string) ([]string, error) {
+	return rmOptions.parse(args).operands, nil
 }
 
 // ParseMvArgs parses mv command arguments and returns source paths to backup
 func ParseMvArgs(args []string) ([]string, error) {
-	var nonFlags []string
+	parsed := mvOptions.parse(args)
+	operands := parsed.operands
 
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			continue
-		}
-		nonFlags = append(nonFlags, arg)
+	// mv -t dest source...
+	// Every operand is a source
+	if parsed.has("target-directory") {
+		return operands, nil
 	}
 
 	// mv source... dest
 	// Backup all sources (they will be moved/deleted)
-	if len(nonFlags) >= 2 {
-		return nonFlags[:len(nonFlags)-1], nil
+	if len(operands) >= 2 {
+		return operands[:len(operands)-1], nil
 	}
 
-	return nonFlags, nil
+	return operands, nil
 }
 
 // ParseCpArgs parses cp command arguments and returns destination to backup
 func ParseCpArgs(args []string) ([]string, error) {
-	var nonFlags []string
+	parsed := cpOptions.parse(args)
+	operands := parsed.operands
 
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			continue
-		}
-		nonFlags = append(nonFlags, arg)
+	// cp -t dest source...
+	// Backup destination (files in it might be overwritten)
+	if dirs := parsed.values["target-directory"]; len(dirs) > 0 {
+		return dirs[len(dirs)-1:], nil
 	}
 
 	// cp source... dest
 	// Backup destination if it exists (might be overwritten)
-	if len(nonFlags) >= 2 {
-		return []string{nonFlags[len(nonFlags)-1]}, nil
+	if len(operands) >= 2 {
+		return []string{operands[len(operands)-1]}, nil
 	}
 
 	return []string{}, nil
 }
 
-// ParseChmodArgs parses chmod arguments and returns target paths
-func ParseChmodArgs(args []string) ([]string, error) {
-	var nonFlags []string
+// rsyncDeletes are the rsync options that delete files in the destination
+// which aren't in the source
+var rsyncDeletes = []string{
+	"del", "delete", "delete-before", "delete-during", "delete-delay", "delete-after", "delete-excluded",
+}
+
+// ParseRsyncArgs parses rsync arguments and returns what it may delete: the
+// destination tree with --delete and friends, and the sources with
+// --remove-source-files. Remote paths can't be backed up and are left out.
+func ParseRsyncArgs(args []string) ([]string, error) {
+	parsed := rsyncOptions.parse(args)
+	operands := parsed.operands
+	if len(operands) < 2 {
+		// rsync source lists the source
+		return []string{}, nil
+	}
+	sources, dest := operands[:len(operands)-1], operands[len(operands)-1]
+
+	var targets []string
+	if slices.ContainsFunc(rsyncDeletes, parsed.has) && !isRemotePath(dest) {
+		// rsync src dest copies src into dest/src; rsync src/ dest copies
+		// what is in src into dest itself
+		destIsDir := false
+		if info, err := os.Stat(dest); err == nil && info.IsDir() {
+			destIsDir = true
+		}
+		for _, src := range sources {
+			target := dest
+			if destIsDir && !strings.HasSuffix(src, "/") {
+				target = filepath.Join(dest, filepath.Base(src))
+			}
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	if parsed.has("remove-source-files") {
+		for _, src := range sources {
+			if !isRemotePath(src) {
+				targets = append(targets, src)
+			}
+		}
+	}
+	return targets, nil
+}
 
+// isRemotePath reports whether an rsync operand is on another host:
+// host:path, user@host:path or rsync://host/path
+func isRemotePath(p string) bool {
+	if strings.HasPrefix(p, "rsync://") {
+		return true
+	}
+	colon := strings.Index(p, ":")
+	slash := strings.Index(p, "/")
+	return colon > 0 && (slash < 0 || colon < slash) && filepath.VolumeName(p) == ""
+}
+
+// ParseDdArgs parses dd operands and returns the output file it writes
+// over, of=FILE; without one dd writes to stdout
+func ParseDdArgs(args []string) ([]string, error) {
+	var targets []string
 	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			continue
+		if file, ok := strings.CutPrefix(arg, "of="); ok && file != "" {
+			// Only the last of= counts
+			targets = []string{file}
 		}
-		nonFlags = append(nonFlags, arg)
 	}
+	return targets, nil
+}
 
-	// chmod mode file...
-	// Skip the mode argument, return file paths
-	if len(nonFlags) >= 2 {
-		return nonFlags[1:], nil
+// ParseTruncateArgs parses truncate arguments and returns the files it
+// resizes; the --reference file is only read
+func ParseTruncateArgs(args []string) ([]string, error) {
+	return truncateOptions.parse(args).operands, nil
+}
+
+// ParseShredArgs parses shred arguments and returns the files it
+// overwrites; "-" is stdout
+func ParseShredArgs(args []string) ([]string, error) {
+	var targets []string
+	for _, file := range shredOptions.parse(args).operands {
+		if file != "-" {
+			targets = append(targets, file)
+		}
 	}
+	return targets, nil
+}
 
-	return []string{}, nil
+// ParseChmodArgs parses chmod arguments and returns target paths
+func ParseChmodArgs(args []string) ([]string, error) {
+	return modeTargets(chmodOptions.parse(args)), nil
 }
 
 // ParseChownArgs parses chown arguments and returns target paths
 func ParseChownArgs(args []string) ([]string, error) {
-	var nonFlags []string
+	return modeTargets(chownOptions.parse(args)), nil
+}
 
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			continue
-		}
-		nonFlags = append(nonFlags, arg)
+// modeTargets returns the files of chmod or chown: the operands after the
+// mode or owner, or all of them with --reference, which replaces it
+func modeTargets(parsed parsedArgs) []string {
+	if parsed.has("reference") {
+		return parsed.operands
 	}
 
-	// chown owner[:group] file...
-	// Skip the owner argument, return file paths
-	if len(nonFlags) >= 2 {
-		return nonFlags[1:], nil
+	// chmod mode file... / chown owner[:group] file...
+	// Skip the mode or owner argument, return file paths
+	if len(parsed.operands) >= 2 {
+		return parsed.operands[1:]
 	}
 
-	return []string{}, nil
+	return []string{}
 }
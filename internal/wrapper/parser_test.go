@@ -1,6 +1,8 @@
 package wrapper
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -51,6 +53,21 @@ func TestParseRmArgs(t *testing.T) {
 			args:     []string{},
 			expected: []string{},
 		},
+		{
+			name:     "after --",
+			args:     []string{"-f", "--", "-file.txt"},
+			expected: []string{"-file.txt"},
+		},
+		{
+			name:     "dash alone is a file",
+			args:     []string{"-f", "-"},
+			expected: []string{"-"},
+		},
+		{
+			name:     "flags after files",
+			args:     []string{"dir", "-rf"},
+			expected: []string{"dir"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -105,6 +122,41 @@ func TestParseMvArgs(t *testing.T) {
 			args:     []string{"file.txt"},
 			expected: []string{"file.txt"},
 		},
+		{
+			name:     "with -t",
+			args:     []string{"-t", "dir", "a.txt", "b.txt"},
+			expected: []string{"a.txt", "b.txt"},
+		},
+		{
+			name:     "with --target-directory=",
+			args:     []string{"--target-directory=dir", "a.txt"},
+			expected: []string{"a.txt"},
+		},
+		{
+			name:     "with -S suffix",
+			args:     []string{"-b", "-S", ".bak", "a.txt", "b.txt"},
+			expected: []string{"a.txt"},
+		},
+		{
+			name:     "with abbreviated --target",
+			args:     []string{"--target", "dir", "a.txt", "b.txt"},
+			expected: []string{"a.txt", "b.txt"},
+		},
+		{
+			name:     "with abbreviated --t=",
+			args:     []string{"--t=dir", "a.txt"},
+			expected: []string{"a.txt"},
+		},
+		{
+			name:     "with abbreviated --suf",
+			args:     []string{"-b", "--suf", ".bak", "a.txt", "b.txt"},
+			expected: []string{"a.txt"},
+		},
+		{
+			name:     "with abbreviated --strip",
+			args:     []string{"--strip", "a.txt", "b.txt"},
+			expected: []string{"a.txt"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -154,6 +206,26 @@ func TestParseCpArgs(t *testing.T) {
 			args:     []string{"file.txt"},
 			expected: []string{},
 		},
+		{
+			name:     "with -t",
+			args:     []string{"-t", "dir", "a.txt", "b.txt"},
+			expected: []string{"dir"},
+		},
+		{
+			name:     "with combined -rt",
+			args:     []string{"-rtdir", "src"},
+			expected: []string{"dir"},
+		},
+		{
+			name:     "with --target-directory",
+			args:     []string{"--target-directory", "dir", "src"},
+			expected: []string{"dir"},
+		},
+		{
+			name:     "with --suffix",
+			args:     []string{"--backup", "--suffix", ".orig", "a.txt", "b.txt"},
+			expected: []string{"b.txt"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -208,6 +280,31 @@ func TestParseChmodArgs(t *testing.T) {
 			args:     []string{"755"},
 			expected: []string{},
 		},
+		{
+			name:     "with --reference=",
+			args:     []string{"--reference=a", "b"},
+			expected: []string{"b"},
+		},
+		{
+			name:     "with --reference",
+			args:     []string{"-R", "--reference", "a", "b", "c"},
+			expected: []string{"b", "c"},
+		},
+		{
+			name:     "with abbreviated --rec and --ref=",
+			args:     []string{"--rec", "--ref=a", "b"},
+			expected: []string{"b"},
+		},
+		{
+			name:     "removing mode",
+			args:     []string{"-w", "file.txt"},
+			expected: []string{"file.txt"},
+		},
+		{
+			name:     "after --",
+			args:     []string{"755", "--", "-file.txt"},
+			expected: []string{"-file.txt"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -262,6 +359,21 @@ func TestParseChownArgs(t *testing.T) {
 			args:     []string{"user"},
 			expected: []string{},
 		},
+		{
+			name:     "with --reference",
+			args:     []string{"--reference=a", "b"},
+			expected: []string{"b"},
+		},
+		{
+			name:     "with --from",
+			args:     []string{"--from", "root", "user", "file.txt"},
+			expected: []string{"file.txt"},
+		},
+		{
+			name:     "with abbreviated --fr",
+			args:     []string{"--fr", "root", "user", "file.txt"},
+			expected: []string{"file.txt"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -284,3 +396,116 @@ func TestParseChownArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRsyncArgs(t *testing.T) {
+	dest := t.TempDir()
+	file := filepath.Join(t.TempDir(), "file.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "no delete",
+			args:     []string{"-av", "src/", dest},
+			expected: []string{},
+		},
+		{
+			name:     "delete contents into dest",
+			args:     []string{"-av", "--delete", "src/", dest},
+			expected: []string{dest},
+		},
+		{
+			name:     "delete dir into dest",
+			args:     []string{"-a", "--delete-after", "src", dest},
+			expected: []string{filepath.Join(dest, "src")},
+		},
+		{
+			name:     "delete onto a file",
+			args:     []string{"--del", "other.txt", file},
+			expected: []string{file},
+		},
+		{
+			name:     "values are not operands",
+			args:     []string{"-e", "ssh -p 22", "--exclude", "*.o", "--delete", "src/", dest},
+			expected: []string{dest},
+		},
+		{
+			name:     "remote destination",
+			args:     []string{"-a", "--delete", "src/", "user@host:backup/"},
+			expected: []string{},
+		},
+		{
+			name:     "remove source files",
+			args:     []string{"--remove-source-files", "a.txt", "host:dir/"},
+			expected: []string{"a.txt"},
+		},
+		{
+			name:     "listing",
+			args:     []string{"--delete", "src/"},
+			expected: []string{},
+		},
+		{
+			// rsync doesn't take abbreviations: --backup isn't --backup-dir
+			name:     "no abbreviations",
+			args:     []string{"--backup", "--delete", "src/", dest},
+			expected: []string{dest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseRsyncArgs(tt.args)
+			if err != nil {
+				t.Fatalf("ParseRsyncArgs returned error: %v", err)
+			}
+
+			if result == nil {
+				result = []string{}
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParseRsyncArgs(%v) = %v, want %v", tt.args, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDdTruncateShredArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		parse    func([]string) ([]string, error)
+		args     []string
+		expected []string
+	}{
+		{"dd of", ParseDdArgs, []string{"if=/dev/zero", "of=disk.img", "bs=1M", "count=10"}, []string{"disk.img"}},
+		{"dd last of", ParseDdArgs, []string{"of=a.img", "of=b.img"}, []string{"b.img"}},
+		{"dd to stdout", ParseDdArgs, []string{"if=disk.img"}, []string{}},
+		{"truncate -s", ParseTruncateArgs, []string{"-s", "0", "a.log", "b.log"}, []string{"a.log", "b.log"}},
+		{"truncate --size= and --reference", ParseTruncateArgs, []string{"--size=-10", "--reference", "ref", "a.log"}, []string{"a.log"}},
+		{"truncate -s negative", ParseTruncateArgs, []string{"-s", "-5", "a.log"}, []string{"a.log"}},
+		{"shred", ParseShredArgs, []string{"-uz", "-n", "3", "secret.txt"}, []string{"secret.txt"}},
+		{"shred stdout", ParseShredArgs, []string{"-"}, []string{}},
+		{"truncate abbreviated --si and --ref", ParseTruncateArgs, []string{"--si", "0", "--ref=ref", "a.log"}, []string{"a.log"}},
+		{"shred abbreviated --it", ParseShredArgs, []string{"--it", "3", "--rem", "secret.txt"}, []string{"secret.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.parse(tt.args)
+			if err != nil {
+				t.Fatalf("returned error: %v", err)
+			}
+
+			if result == nil {
+				result = []string{}
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parse(%v) = %v, want %v", tt.args, result, tt.expected)
+			}
+		})
+	}
+}
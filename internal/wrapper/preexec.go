@@ -0,0 +1,208 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/i18n"
+)
+
+// shellToken is a word or an operator of a shell command line
+type shellToken struct {
+	text string
+	op   bool
+}
+
+// shellOperators are the operators tokenize knows, longest first
+var shellOperators = []string{
+	"&>>", ">>", ">|", "&>", ">&", "<<<", "<<", "<&", "<>", "&&", "||",
+	">", "<", "|", "&", ";", "(", ")",
+}
+
+// tokenize splits a command line into words and operators the way a shell
+// does, minus expansions: quotes group, backslashes escape, and a file
+// descriptor number right before a redirection is dropped
+func tokenize(line string) []shellToken {
+	var tokens []shellToken
+	var word strings.Builder
+	inWord, quoted := false, false
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{text: word.String()})
+		}
+		word.Reset()
+		inWord, quoted = false, false
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			word.WriteByte(line[i])
+			inWord, quoted = true, true
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(line[i+1:], c)
+			if end < 0 {
+				end = len(line) - i - 1
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord, quoted = true, true
+		case c == '$' && i+1 < len(line) && line[i+1] == '(', c == '`':
+			// A command substitution stays in the word whole
+			end := substitutionEnd(line, i)
+			word.WriteString(line[i:end])
+			i = end - 1
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case strings.IndexByte("<>&|;()", c) >= 0:
+			if (c == '<' || c == '>') && inWord && !quoted && isDigits(word.String()) {
+				// 2>file: the number is the descriptor, not a word
+				word.Reset()
+				inWord = false
+			}
+			flush()
+			for _, op := range shellOperators {
+				if strings.HasPrefix(line[i:], op) {
+					tokens = append(tokens, shellToken{text: op, op: true})
+					i += len(op) - 1
+					break
+				}
+			}
+		case c == '#' && !inWord:
+			// A comment runs to the end of the line
+			i = len(line)
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return tokens
+}
+
+// substitutionEnd returns where the $(...) or `...` at line[start] ends
+func substitutionEnd(line string, start int) int {
+	if line[start] == '`' {
+		if end := strings.IndexByte(line[start+1:], '`'); end >= 0 {
+			return start + end + 2
+		}
+		return len(line)
+	}
+	depth := 0
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(line)
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// TruncatedFiles returns the files a shell command line is about to empty
+// or overwrite in ways wrapped commands don't see: output redirections
+// (>, >|, &>) and tee without --append. Words with command substitutions
+// are left out; variables and ~ are expanded from the environment.
+func TruncatedFiles(line string) []string {
+	tokens := tokenize(line)
+	var files []string
+	commandStart := true
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.op {
+			switch t.text {
+			case ">", ">|", "&>", ">&":
+				if i+1 < len(tokens) && !tokens[i+1].op {
+					i++
+					target := tokens[i].text
+					// >&2 and >&- duplicate or close a descriptor
+					if t.text != ">&" || !(isDigits(target) || target == "-") {
+						files = appendExpanded(files, target)
+					}
+				}
+			case ">>", "&>>", "<", "<<", "<<<", "<&", "<>":
+				i++ // their word is not a command
+			default:
+				commandStart = true
+			}
+			continue
+		}
+		if !commandStart {
+			continue
+		}
+		if strings.Contains(t.text, "=") && !strings.HasPrefix(t.text, "=") {
+			continue // VAR=value before the command
+		}
+		commandStart = false
+		if filepath.Base(t.text) == "tee" {
+			var teeFiles []string
+			appending := false
+			for i+1 < len(tokens) && !tokens[i+1].op {
+				i++
+				switch arg := tokens[i].text; {
+				case arg == "-a" || arg == "--append":
+					appending = true
+				case strings.HasPrefix(arg, "-") && arg != "-":
+				default:
+					teeFiles = appendExpanded(teeFiles, arg)
+				}
+			}
+			if !appending {
+				files = append(files, teeFiles...)
+			}
+		}
+	}
+	return files
+}
+
+// appendExpanded appends word to files with ~ and variables expanded,
+// unless it needs a command run to know
+func appendExpanded(files []string, word string) []string {
+	if strings.Contains(word, "$(") || strings.Contains(word, "`") {
+		return files
+	}
+	if word == "~" || strings.HasPrefix(word, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			word = homeDir + word[1:]
+		}
+	}
+	return append(files, os.ExpandEnv(word))
+}
+
+// Preexec checkpoints the existing regular files a shell command line is
+// about to truncate, before the shell runs it. It is called by the hook
+// 'safeshell init --preexec' installs, so it never fails the command; the
+// common case of nothing to back up, as in > /dev/null, costs no config.
+func Preexec(line string) {
+	var targets []string
+	for _, file := range TruncatedFiles(line) {
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+			targets = append(targets, file)
+		}
+	}
+	if len(targets) == 0 || Disabled() && BypassAllowed() {
+		return
+	}
+	// The shell truncates the targets in place, which a hard link's backup
+	// would share, so they are backed up as copies
+	id, _, evicted, err := createCheckpoint("", line, targets, true)
+	if err != nil {
+		warn(i18n.T("wrap.checkpoint_failed", err))
+		return
+	}
+	inform(i18n.T("wrap.checkpoint_created", id))
+	for _, e := range evicted {
+		inform(i18n.T("wrap.evicted_"+e.Action, len(e.IDs), e.Limit, e.Max))
+	}
+}
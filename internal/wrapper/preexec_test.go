@@ -0,0 +1,76 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+)
+
+func TestTruncatedFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CONF", "app.conf")
+
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"echo hi > important.conf", []string{"important.conf"}},
+		{"echo hi >important.conf", []string{"important.conf"}},
+		{"echo hi >> log.txt", nil},
+		{"cmd 2> err.log >| out.log", []string{"err.log", "out.log"}},
+		{"cmd &> all.log", []string{"all.log"}},
+		{"cmd > out.txt 2>&1", []string{"out.txt"}},
+		{"cmd >&2", nil},
+		{`echo "a > b" > 'my file'`, []string{"my file"}},
+		{`echo a\>b`, nil},
+		{"cat a | tee b.txt c.txt > /dev/null", []string{"b.txt", "c.txt", "/dev/null"}},
+		{"cat a | tee -a b.txt", nil},
+		{"FOO=1 tee out.txt < in.txt", []string{"out.txt"}},
+		{"echo x > ~/notes.txt; echo y > $CONF", []string{filepath.Join(home, "notes.txt"), "app.conf"}},
+		{"echo x > $(date).log", nil},
+		{"sort < in.txt # > not.txt", nil},
+	}
+	for _, tt := range tests {
+		if got := TruncatedFiles(tt.line); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("TruncatedFiles(%q) = %q, want %q", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestPreexecSkipsNothingToBackUp(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.txt")
+	os.WriteFile(empty, nil, 0644)
+	// Nothing here to back up, so no checkpoint and no config are needed
+	Preexec("echo > /dev/null > " + empty + " > " + filepath.Join(dir, "missing.txt"))
+}
+
+func TestPreexecKeepsOriginal(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "important.conf")
+	os.WriteFile(file, []byte("original"), 0644)
+
+	line := "echo new > " + file
+	Preexec(line)
+	// What the shell does next: truncate the file in place and write to it
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new\n")
+	f.Close()
+
+	cp, err := checkpoint.GetLatest()
+	if err != nil {
+		t.Fatalf("Expected a checkpoint: %v", err)
+	}
+	if cp.Manifest.Command != line || len(cp.Manifest.Files) != 1 {
+		t.Fatalf("Expected a checkpoint of %s for %q, got %+v", file, line, cp.Manifest)
+	}
+	if data, _ := os.ReadFile(cp.Manifest.Files[0].BackupPath); string(data) != "original" {
+		t.Errorf("Expected the backup to hold the original, got %q", data)
+	}
+}
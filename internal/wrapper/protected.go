@@ -0,0 +1,102 @@
+package wrapper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/util"
+)
+
+// ErrRefused is returned when protected_paths or rules keep a wrapped
+// command from running: they say deny, say confirm and the answer was no,
+// or require a checkpoint that failed
+var ErrRefused = errors.New("refused")
+
+// protectedTarget is a target of a command and the protected_paths entry
+// applying to it
+type protectedTarget struct {
+	target string
+	entry  config.ProtectedPath
+}
+
+// protectedTargets returns the targets c protects, for a command of def.
+// High-risk commands, such as rm -r, may destroy whole directories, so a
+// directory holding a protected path counts for them too.
+func protectedTargets(c *config.Config, def CommandDef, targets []string) []protectedTarget {
+	var protected []protectedTarget
+	for _, target := range targets {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			continue
+		}
+		if entry, ok := c.Protection(abs, def.RiskLevel == "HIGH"); ok {
+			protected = append(protected, protectedTarget{target: abs, entry: entry})
+		}
+	}
+	return protected
+}
+
+// checkProtected enforces protected_paths on a wrapped command about to
+// run on targets: it returns ErrRefused if an entry says deny, or says
+// confirm and the user doesn't. It reports whether any target is
+// protected, for the command not to run without its checkpoint.
+func checkProtected(cmdName string, def CommandDef, targets []string) (bool, error) {
+	if len(targets) == 0 || !IsWrapped(cmdName) {
+		return false, nil
+	}
+	i18n.SetLocale(i18n.Detect(wrapSettings().Language))
+	protected := protectedTargets(wrapSettings(), def, targets)
+
+	var confirm []protectedTarget
+	for _, p := range protected {
+		switch p.entry.Action {
+		case config.ProtectDeny:
+			return true, fmt.Errorf("%w by protected_paths: %s", ErrRefused, i18n.T("wrap.protected", p.target, p.entry.Path, p.entry.Action))
+		case config.ProtectConfirm:
+			confirm = append(confirm, p)
+		}
+	}
+	if len(confirm) > 0 && !confirmProtected(cmdName, confirm) {
+		p := confirm[0]
+		return true, fmt.Errorf("%w by protected_paths: %s", ErrRefused, i18n.T("wrap.protected", p.target, p.entry.Path, p.entry.Action))
+	}
+	return len(protected) > 0, nil
+}
+
+// confirmProtected asks whether to run cmdName on the protected targets.
+// The question goes to stderr even when messages don't, as it needs an
+// answer.
+func confirmProtected(cmdName string, protected []protectedTarget) bool {
+	for _, p := range protected {
+		fmt.Fprintln(os.Stderr, i18n.T("wrap.protected_target", p.target, p.entry.Path))
+	}
+	return askToRun(cmdName)
+}
+
+// askToRun asks whether to run cmdName anyway. Without a terminal to ask
+// on, e.g. for an agent or in CI, the answer is no, unless 'wrap --yes' or
+// YesEnv says yes.
+func askToRun(cmdName string) bool {
+	prompt := i18n.T("wrap.confirm_run", cmdName)
+	if AssumeYes || envSet(YesEnv) {
+		fmt.Fprintf(os.Stderr, "%s [y/N]: %s\n", prompt, i18n.T("wrap.assumed_yes"))
+		return true
+	}
+	if !util.CanPrompt() {
+		fmt.Fprintf(os.Stderr, "%s [y/N]: %s\n", prompt, i18n.T("wrap.not_interactive"))
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", prompt)
+	response, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
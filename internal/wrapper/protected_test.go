@@ -0,0 +1,74 @@
+package wrapper
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func TestProtectedTargets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	c := &config.Config{ProtectedPaths: []config.ProtectedPath{
+		{Path: "~/work"},
+		{Path: "~/Documents/**", Action: config.ProtectConfirm},
+		{Path: "~/.ssh/**", Action: config.ProtectDeny},
+		{Path: "~/notes", Action: "bogus"},
+		{Path: "~/work/**/*.key", Action: config.ProtectDeny},
+	}}
+	rm, cp := SupportedCommands["rm"], SupportedCommands["cp"]
+
+	tests := []struct {
+		def    CommandDef
+		target string
+		path   string // matching entry, "" for none
+		action string
+	}{
+		{rm, filepath.Join(home, "work"), "~/work", config.ProtectCheckpoint},
+		{rm, filepath.Join(home, "work", "a", "b.txt"), "~/work", config.ProtectCheckpoint},
+		{rm, filepath.Join(home, "work", "secrets"), "~/work", config.ProtectCheckpoint},
+		// An unknown action denies
+		{rm, filepath.Join(home, "notes", "todo.txt"), "~/notes", config.ProtectDeny},
+		{rm, filepath.Join(home, "work", "a", "id.key"), "~/work/**/*.key", config.ProtectDeny},
+		{rm, filepath.Join(home, "Documents"), "~/Documents/**", config.ProtectConfirm},
+		{rm, filepath.Join(home, "Documents", "cv.pdf"), "~/Documents/**", config.ProtectConfirm},
+		{rm, filepath.Join(home, ".ssh", "id_ed25519"), "~/.ssh/**", config.ProtectDeny},
+		{rm, filepath.Join(home, "workshop"), "", ""},
+		{rm, filepath.Join(home, "Downloads"), "", ""},
+		// rm -rf ~ destroys what is protected inside it; cp into ~ doesn't
+		{rm, home, "~/.ssh/**", config.ProtectDeny},
+		{cp, home, "", ""},
+	}
+	for _, tt := range tests {
+		got := protectedTargets(c, tt.def, []string{tt.target})
+		if tt.path == "" {
+			if len(got) != 0 {
+				t.Errorf("%s %s: expected no protection, got %+v", tt.def.Name, tt.target, got)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0].entry.Path != tt.path || got[0].entry.Action != tt.action {
+			t.Errorf("%s %s: expected %s (%s), got %+v", tt.def.Name, tt.target, tt.path, tt.action, got)
+		}
+	}
+}
+
+func TestConfirmProtectedNotInteractive(t *testing.T) {
+	// Tests don't run on a terminal, so there is no one to ask
+	p := protectedTarget{target: "/tmp/x", entry: config.ProtectedPath{Path: "/tmp/x", Action: config.ProtectConfirm}}
+	if confirmProtected("rm", []protectedTarget{p}) {
+		t.Error("Expected no without a terminal")
+	}
+}
+
+func TestAskToRunAssumesYes(t *testing.T) {
+	t.Setenv(YesEnv, "1")
+	if !askToRun("rm") {
+		t.Errorf("Expected yes with %s=1", YesEnv)
+	}
+	t.Setenv(YesEnv, "0")
+	if askToRun("rm") {
+		t.Errorf("Expected no with %s=0 and no terminal", YesEnv)
+	}
+}
@@ -0,0 +1,92 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+// shimMaxSize bounds the files read when checking for shim scripts
+const shimMaxSize = 4096
+
+// realCommands holds the real_commands overrides once known. The daemon
+// sends them with its response, which spares loading config.
+var realCommands map[string]string
+
+func useRealCommands(overrides map[string]string) {
+	if overrides == nil {
+		overrides = map[string]string{}
+	}
+	realCommands = overrides
+}
+
+func commandOverrides() map[string]string {
+	if realCommands == nil {
+		useRealCommands(config.Get().RealCommands)
+	}
+	return realCommands
+}
+
+// findRealCommand finds the binary a wrapped command runs: the real_commands
+// override if one is configured, otherwise the first match on PATH that is
+// not one of safeshell's own shims
+func findRealCommand(cmdName string) (string, error) {
+	if path, ok := commandOverrides()[cmdName]; ok {
+		resolved, err := exec.LookPath(path)
+		if err != nil || !filepath.IsAbs(resolved) {
+			return "", fmt.Errorf("real_commands.%s is set to %s, which is not an executable", cmdName, path)
+		}
+		return resolved, nil
+	}
+
+	var tried []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		// Like exec.LookPath, never run binaries from relative PATH entries
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		candidate := filepath.Join(dir, cmdName)
+		path, err := exec.LookPath(candidate)
+		if err != nil {
+			tried = append(tried, candidate)
+			continue
+		}
+		if isShim(path) {
+			tried = append(tried, path+" (safeshell shim)")
+			continue
+		}
+		return path, nil
+	}
+
+	msg := fmt.Sprintf("cannot find the real %s binary", cmdName)
+	if len(tried) > 0 {
+		msg += "; tried:\n  " + strings.Join(tried, "\n  ")
+	} else {
+		msg += ": PATH has no absolute entries"
+	}
+	return "", fmt.Errorf("%s\nSet real_commands.%s in %s to its location", msg, cmdName, config.FilePath())
+}
+
+// isShim reports whether path leads back to safeshell: the safeshell binary
+// itself under another name, or a small script that runs 'safeshell wrap'
+func isShim(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if self, err := os.Executable(); err == nil {
+		if selfInfo, err := os.Stat(self); err == nil && os.SameFile(info, selfInfo) {
+			return true
+		}
+	}
+	if info.Size() > shimMaxSize {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Contains(data, []byte("safeshell wrap"))
+}
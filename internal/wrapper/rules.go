@@ -0,0 +1,168 @@
+package wrapper
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/i18n"
+)
+
+// commandFacts are what rules are matched against: a wrapped command, its
+// arguments and its targets, and how much those hold once measured
+type commandFacts struct {
+	name    string
+	def     CommandDef
+	args    []string
+	targets []string // absolute
+
+	measured bool
+	files    int
+	size     int64
+}
+
+func newCommandFacts(name string, def CommandDef, args, targets []string) *commandFacts {
+	f := &commandFacts{name: name, def: def, args: args}
+	for _, target := range targets {
+		if abs, err := filepath.Abs(target); err == nil {
+			f.targets = append(f.targets, abs)
+		}
+	}
+	return f
+}
+
+// matches reports whether the command meets every condition r sets. The
+// targets are only walked if r counts files or size and everything else
+// matches.
+func (f *commandFacts) matches(r config.Rule) bool {
+	if !r.MatchesCommand(f.name, f.def.RiskLevel) {
+		return false
+	}
+	for _, flag := range r.Flags {
+		if !hasFlag(f.args, flag) {
+			return false
+		}
+	}
+	if len(r.Paths) > 0 || r.MaxDepth > 0 {
+		matched := slices.ContainsFunc(f.targets, func(target string) bool {
+			return r.MatchesPath(target) && (r.MaxDepth == 0 || pathDepth(target) <= r.MaxDepth)
+		})
+		if !matched {
+			return false
+		}
+	}
+	if r.Measured() {
+		f.measure()
+		if f.files < r.MinFiles || f.size < int64(r.MinSizeMB)*1024*1024 {
+			return false
+		}
+	}
+	return true
+}
+
+// measure counts the files under the targets and their size, once
+func (f *commandFacts) measure() {
+	if f.measured {
+		return
+	}
+	f.measured = true
+	for _, target := range outermost(f.targets) {
+		filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			f.files++
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				f.size += info.Size()
+			}
+			return nil
+		})
+	}
+}
+
+// hasFlag reports whether args, up to --, give one of the flags in spec,
+// such as "-r|--recursive". A short flag may be combined with others, as
+// in -rf, and a long one may have a value, as in --exclude=x. Others, like
+// find's -delete, are matched whole.
+func hasFlag(args []string, spec string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		for _, flag := range strings.Split(spec, "|") {
+			switch {
+			case strings.HasPrefix(flag, "--"):
+				if arg == flag || strings.HasPrefix(arg, flag+"=") {
+					return true
+				}
+			case len(flag) == 2 && flag[0] == '-':
+				if len(arg) > 1 && arg[0] == '-' && arg[1] != '-' && strings.IndexByte(arg[1:], flag[1]) >= 0 {
+					return true
+				}
+			case arg == flag:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathDepth returns how many directories deep an absolute path is: / is 0,
+// /home 1 and /home/me 2
+func pathDepth(path string) int {
+	depth := 0
+	for _, segment := range strings.Split(filepath.ToSlash(path[len(filepath.VolumeName(path)):]), "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	return depth
+}
+
+// matchingRule returns the first of rules the command matches, and its name
+func matchingRule(rules []config.Rule, facts *commandFacts) (config.Rule, string, bool) {
+	for i, r := range rules {
+		if facts.matches(r) {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return r, name, true
+		}
+	}
+	return config.Rule{}, "", false
+}
+
+// checkRules applies the first of the rules a wrapped command about to run
+// matches: it returns ErrRefused if the rule says deny, or says confirm and
+// the user doesn't. It reports whether the rule requires a checkpoint.
+func checkRules(cmdName string, def CommandDef, args, targets []string) (bool, error) {
+	if len(targets) == 0 || !IsWrapped(cmdName) {
+		return false, nil
+	}
+	r, name, ok := matchingRule(wrapSettings().Rules, newCommandFacts(cmdName, def, args, targets))
+	if !ok {
+		return false, nil
+	}
+	message := r.Message
+	if message == "" {
+		message = i18n.T("wrap.rule_matched", cmdName)
+	}
+
+	switch r.Action {
+	case config.RuleDeny:
+		return false, fmt.Errorf("%w by rule %s: %s", ErrRefused, name, message)
+	case config.RuleConfirm:
+		fmt.Fprintln(os.Stderr, i18n.T("wrap.rule_confirm", name, message))
+		if !askToRun(cmdName) {
+			return false, fmt.Errorf("%w by rule %s: %s", ErrRefused, name, message)
+		}
+	case config.RuleRequireCheckpoint:
+		return true, nil
+	}
+	return false, nil
+}
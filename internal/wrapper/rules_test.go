@@ -0,0 +1,88 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qhkm/safeshell/internal/config"
+)
+
+func TestHasFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		spec     string
+		expected bool
+	}{
+		{[]string{"-rf", "dir"}, "-r", true},
+		{[]string{"-fR", "dir"}, "-r|-R", true},
+		{[]string{"--recursive", "dir"}, "-r|--recursive", true},
+		{[]string{"--exclude=x", "dir"}, "--exclude", true},
+		{[]string{"--recursive-ish", "dir"}, "--recursive", false},
+		{[]string{"-f", "--", "-r"}, "-r", false},
+		{[]string{".", "-delete"}, "-delete", true},
+		{[]string{"dir"}, "-r", false},
+	}
+	for _, tt := range tests {
+		if got := hasFlag(tt.args, tt.spec); got != tt.expected {
+			t.Errorf("hasFlag(%q, %q) = %v, want %v", tt.args, tt.spec, got, tt.expected)
+		}
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	root := string(filepath.Separator)
+	for path, expected := range map[string]int{
+		root:                              0,
+		filepath.Join(root, "home"):       1,
+		filepath.Join(root, "home", "me"): 2,
+		filepath.Join(root, "home", "me", "work"): 3,
+	} {
+		if got := pathDepth(path); got != expected {
+			t.Errorf("pathDepth(%q) = %d, want %d", path, got, expected)
+		}
+	}
+}
+
+func TestMatchingRule(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.txt", i)), []byte("data"), 0644)
+	}
+	rules := []config.Rule{
+		{Name: "shallow-rm", Commands: []string{"rm"}, Flags: []string{"-r|--recursive", "-f"}, MaxDepth: 2, Action: config.RuleDeny},
+		{Name: "keep-cp", Commands: []string{"cp"}, Action: config.RuleAllow},
+		{Risk: "MEDIUM", MinFiles: 5, Action: config.RuleConfirm},
+		{Paths: []string{dir + "/**"}, Action: config.RuleRequireCheckpoint},
+	}
+	rm, mv, cp := SupportedCommands["rm"], SupportedCommands["mv"], SupportedCommands["cp"]
+
+	tests := []struct {
+		cmd      string
+		def      CommandDef
+		args     []string
+		targets  []string
+		expected string // rule name, "" for none
+	}{
+		{"rm", rm, []string{"-rf", "/home/me"}, []string{"/home/me"}, "shallow-rm"},
+		{"rm", rm, []string{"-r", "/home/me"}, []string{"/home/me"}, ""},
+		{"rm", rm, []string{"-rf", "/home/me/work/tmp"}, []string{"/home/me/work/tmp"}, ""},
+		{"cp", cp, []string{"a", dir}, []string{dir}, "keep-cp"},
+		{"rm", rm, []string{"-r", dir}, []string{dir}, "#3"},
+		{"mv", mv, []string{dir, "/elsewhere"}, []string{dir}, "#3"},
+		{"rm", rm, []string{filepath.Join(dir, "f0.txt")}, []string{filepath.Join(dir, "f0.txt")}, "#4"},
+	}
+	for _, tt := range tests {
+		_, name, ok := matchingRule(rules, newCommandFacts(tt.cmd, tt.def, tt.args, tt.targets))
+		if name != tt.expected || ok != (tt.expected != "") {
+			t.Errorf("%s %q: expected rule %q, got %q", tt.cmd, tt.args, tt.expected, name)
+		}
+	}
+
+	// Sizes count too
+	big := []config.Rule{{MinSizeMB: 1, Action: config.RuleDeny}}
+	if _, _, ok := matchingRule(big, newCommandFacts("rm", rm, []string{dir}, []string{dir})); ok {
+		t.Error("Expected 20 bytes not to match min_size_mb: 1")
+	}
+}
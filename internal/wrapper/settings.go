@@ -0,0 +1,45 @@
+package wrapper
+
+import (
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/daemon"
+	"github.com/qhkm/safeshell/internal/hooks"
+	"github.com/qhkm/safeshell/internal/i18n"
+)
+
+// settings holds the config deciding whether wrapped commands run, once
+// known
+var settings *config.Config
+
+// wrapSettings returns the config deciding whether wrapped commands run:
+// wrapped_commands, custom_commands, protected_paths, rules,
+// confirm_high_risk_mb, rm_strategy and the policy's disabled_features. The daemon sends them if it's running, which spares loading
+// config; only otherwise is it loaded.
+func wrapSettings() *config.Config {
+	if settings != nil {
+		return settings
+	}
+	// Hooks run inside the daemon, so commands run by a hook must not wait on it
+	if !hooks.Active() {
+		if resp, err := daemon.Settings(daemon.SocketPath()); err == nil {
+			i18n.SetLocale(i18n.Detect(resp.Language))
+			useRealCommands(resp.RealCommands)
+			operationsLog = resp.OperationsLog
+			settings = &config.Config{
+				WrappedCommands:   resp.Wrapped,
+				CustomCommands:    resp.CustomCommands,
+				ProtectedPaths:    resp.ProtectedPaths,
+				Rules:             resp.Rules,
+				ConfirmHighRiskMB: resp.ConfirmHighRiskMB,
+				RmStrategy:        resp.RmStrategy,
+				Language:          resp.Language,
+			}
+			if len(resp.DisabledFeatures) > 0 {
+				settings.Policy = &config.Policy{DisabledFeatures: resp.DisabledFeatures}
+			}
+			return settings
+		}
+	}
+	settings = config.Get()
+	return settings
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// forwardSignals passes the signals that would stop safeshell on to the
+// command it runs instead, so the command alone decides how to stop and
+// safeshell lives to report how it did. The returned func stops it.
+func forwardSignals(cmd *exec.Cmd) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// raise ends safeshell by sig, the signal that killed the command, so the
+// shell sees the same death; a shell script interrupted with Ctrl-C stops
+// only if its command died of SIGINT. Signals the Go runtime doesn't die
+// of cleanly, such as SIGQUIT with its goroutine dump, are left to the
+// 128+n exit status, as are signals ignored since safeshell started, such
+// as SIGHUP under nohup or SIGINT in a background job: raise returns if it
+// doesn't die, for the caller to exit with that status.
+func raise(sig syscall.Signal) {
+	switch sig {
+	case syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP:
+		if signal.Ignored(sig) {
+			return
+		}
+		signal.Reset(sig)
+		syscall.Kill(os.Getpid(), sig)
+		// Give it time to be delivered
+		time.Sleep(raiseWait)
+	}
+}
+
+// raiseWait is how long raise waits to die of the signal it sent
+const raiseWait = 100 * time.Millisecond
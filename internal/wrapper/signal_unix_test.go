@@ -0,0 +1,50 @@
+//go:build !windows
+
+package wrapper
+
+import (
+	"errors"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		want   int
+	}{
+		{"exit 3", 3},
+		{"kill -TERM $$", 143},
+		{"kill -KILL $$", 137},
+	}
+	for _, tt := range tests {
+		err := exec.Command("sh", "-c", tt.script).Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("%q: expected an exit error, got %v", tt.script, err)
+		}
+		if got := ExitStatus(exitErr); got != tt.want {
+			t.Errorf("%q: ExitStatus() = %d, want %d", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestRaiseIgnored(t *testing.T) {
+	// As under nohup: the signal doesn't end safeshell, so raise returns
+	signal.Ignore(syscall.SIGHUP)
+	defer signal.Reset(syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		raise(syscall.SIGHUP)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("raise hung on an ignored signal")
+	}
+}
@@ -0,0 +1,20 @@
+package wrapper
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// forwardSignals keeps Ctrl-C from stopping safeshell while the command it
+// runs is going; the console sends it to the command too. The returned
+// func stops it.
+func forwardSignals(cmd *exec.Cmd) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	return func() { signal.Stop(sigs) }
+}
+
+// raise does nothing: commands on Windows don't die of signals
+func raise(sig syscall.Signal) {}
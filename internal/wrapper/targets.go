@@ -0,0 +1,114 @@
+package wrapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Targets of a custom_commands entry, among its arguments that aren't flags
+const (
+	TargetsAllPositional   = "all-positional"
+	TargetsFirstPositional = "first-positional"
+	TargetsLastPositional  = "last-positional"
+	TargetsAllButFirst     = "all-but-first"
+	TargetsAllButLast      = "all-but-last"
+)
+
+// targetParser returns the Parser for the command name, whose targets are
+// described by spec: one of the Targets constants, or a template of
+// space-separated picks. "$N" is the Nth argument that isn't a flag, "$N.."
+// that one and every one after it, and a flag such as "--dir" or "-C" the
+// value given to it. An invalid spec makes the Parser fail.
+func targetParser(name, spec string) func(args []string) ([]string, error) {
+	pick, err := parseTargets(spec)
+	if err != nil {
+		err = fmt.Errorf("custom_commands entry %s: %w", name, err)
+		return func([]string) ([]string, error) { return nil, err }
+	}
+	return func(args []string) ([]string, error) {
+		return pick(args), nil
+	}
+}
+
+// parseTargets turns spec into a function picking the targets from args
+func parseTargets(spec string) (func(args []string) []string, error) {
+	switch strings.TrimSpace(spec) {
+	case "", TargetsAllPositional:
+		return positional, nil
+	case TargetsFirstPositional:
+		return func(args []string) []string { return slice(positional(args), 0, 1) }, nil
+	case TargetsLastPositional:
+		return func(args []string) []string {
+			p := positional(args)
+			return slice(p, len(p)-1, len(p))
+		}, nil
+	case TargetsAllButFirst:
+		return func(args []string) []string {
+			p := positional(args)
+			return slice(p, 1, len(p))
+		}, nil
+	case TargetsAllButLast:
+		return func(args []string) []string {
+			p := positional(args)
+			return slice(p, 0, len(p)-1)
+		}, nil
+	}
+
+	var picks []func(args []string) []string
+	for _, field := range strings.Fields(spec) {
+		if strings.HasPrefix(field, "-") {
+			flag := field
+			picks = append(picks, func(args []string) []string { return flagValues(args, flag) })
+			continue
+		}
+		n, rest := strings.TrimSuffix(field, ".."), strings.HasSuffix(field, "..")
+		i, err := strconv.Atoi(strings.TrimPrefix(n, "$"))
+		if !strings.HasPrefix(n, "$") || err != nil || i < 1 {
+			return nil, fmt.Errorf("invalid targets %q: expected %s, %s, %s, %s, %s or picks like $1, $2.. or --dir",
+				spec, TargetsAllPositional, TargetsFirstPositional, TargetsLastPositional, TargetsAllButFirst, TargetsAllButLast)
+		}
+		picks = append(picks, func(args []string) []string {
+			p := positional(args)
+			if rest {
+				return slice(p, i-1, len(p))
+			}
+			return slice(p, i-1, i)
+		})
+	}
+	return func(args []string) []string {
+		var targets []string
+		for _, pick := range picks {
+			targets = append(targets, pick(args)...)
+		}
+		return targets
+	}, nil
+}
+
+// positional returns the arguments that aren't flags, those after "--"
+// included
+func positional(args []string) []string {
+	return optionSpec{}.parse(args).operands
+}
+
+// flagValues returns the values given to flag, as "flag value" or
+// "flag=value"
+func flagValues(args []string, flag string) []string {
+	var values []string
+	for i, arg := range args {
+		if arg == flag && i+1 < len(args) {
+			values = append(values, args[i+1])
+		} else if value, ok := strings.CutPrefix(arg, flag+"="); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// slice is s[from:to], empty where that is out of range
+func slice(s []string, from, to int) []string {
+	if from < 0 || to > len(s) || from >= to {
+		return nil
+	}
+	return s[from:to]
+}
@@ -0,0 +1,41 @@
+package wrapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTargetParser(t *testing.T) {
+	args := []string{"-f", "a", "--dir", "d", "b", "--out=o", "c"}
+	tests := []struct {
+		spec     string
+		expected []string
+	}{
+		{"", []string{"a", "d", "b", "c"}},
+		{TargetsAllPositional, []string{"a", "d", "b", "c"}},
+		{TargetsFirstPositional, []string{"a"}},
+		{TargetsLastPositional, []string{"c"}},
+		{TargetsAllButFirst, []string{"d", "b", "c"}},
+		{TargetsAllButLast, []string{"a", "d", "b"}},
+		{"$2", []string{"d"}},
+		{"$1 $3..", []string{"a", "b", "c"}},
+		{"$9", nil},
+		{"--dir --out", []string{"d", "o"}},
+	}
+	for _, tt := range tests {
+		got, err := targetParser("tool", tt.spec)(args)
+		if err != nil {
+			t.Errorf("targets %q: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("targets %q: expected %v, got %v", tt.spec, tt.expected, got)
+		}
+	}
+
+	for _, spec := range []string{"everything", "$0", "2"} {
+		if _, err := targetParser("tool", spec)(args); err == nil {
+			t.Errorf("Expected an error for targets %q", spec)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+)
+
+// How rm is checkpointed, the rm_strategy setting
+const (
+	RmCopy  = "copy"  // back the targets up, then run rm
+	RmTrash = "trash" // move the targets into the checkpoint instead of running rm
+)
+
+// trashable reports whether rm with args is to be done by moving its
+// targets into a checkpoint: rm_strategy is trash and it is a plain
+// deletion
+func trashable(cmdName string, args, targets []string) bool {
+	if cmdName != "rm" || len(targets) == 0 || !IsWrapped(cmdName) || wrapSettings().RmStrategy != RmTrash {
+		return false
+	}
+	return plainRemoval(args, targets)
+}
+
+// plainRemoval reports whether rm with args does nothing but delete its
+// targets, which moving them can do instead. rm asking first (-i, -I) or
+// given options moving has no equivalent for doesn't, nor does rm on a
+// directory without -r or a missing target without -f, which it refuses,
+// or on a write-protected file without -f, which it asks about.
+func plainRemoval(args, targets []string) bool {
+	var recursive, force bool
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		switch {
+		case arg == "--recursive":
+			recursive = true
+		case arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "--"):
+			return false
+		default:
+			for _, c := range arg[1:] {
+				switch c {
+				case 'r', 'R':
+					recursive = true
+				case 'f':
+					force = true
+				default:
+					return false
+				}
+			}
+		}
+	}
+
+	for _, target := range targets {
+		switch filepath.Base(target) {
+		case ".", "..", string(filepath.Separator):
+			return false
+		}
+		info, err := os.Lstat(target)
+		switch {
+		case os.IsNotExist(err):
+			if !force {
+				return false
+			}
+		case err != nil:
+			return false
+		case info.Mode()&os.ModeSymlink != 0:
+			// rm link/ is about what the link points to
+			if strings.HasSuffix(target, "/") {
+				return false
+			}
+		case info.IsDir():
+			if !recursive {
+				return false
+			}
+		case info.Mode().Perm()&0200 == 0 && !force:
+			return false
+		}
+	}
+	return true
+}
+
+// trash does rm by moving its targets into a checkpoint, first asking if
+// large says it should, and returns the checkpoint's ID. It reports false,
+// for rm to run as usual, if they can't be moved.
+func trash(cmdName string, args, targets []string, large *commandFacts) (string, bool, error) {
+	command := cmdName + " " + strings.Join(args, " ")
+	useMessages(config.Get().WrapperMessages, command)
+	logging.Setup(config.Get().LogLevel, config.Get().LogFile, config.Get().SafeShellDir)
+
+	var cp *checkpoint.Checkpoint
+	var err error
+	withMessages(func() { cp, err = checkpoint.Trash(command, targets) })
+	if err != nil {
+		logging.Debug("targets not moved, running rm", "error", err)
+		return "", false, nil
+	}
+	inform(i18n.T("wrap.checkpoint_created", cp.ID))
+	for _, e := range cp.Evicted {
+		inform(i18n.T("wrap.evicted_"+e.Action, len(e.IDs), e.Limit, e.Max))
+	}
+
+	// Declining puts everything back, as rm never ran
+	if large != nil && !confirmHighRisk(cmdName, args, large, cp.ID) {
+		if err := checkpoint.Untrash(cp); err != nil {
+			warn(i18n.T("wrap.trash_restore_failed", cp.ID, err))
+		} else if err := checkpoint.Delete(cp.ID); err != nil {
+			warn(i18n.T("wrap.checkpoint_delete_failed", cp.ID, err))
+		}
+		return "", true, fmt.Errorf("%w: %s", ErrRefused, i18n.T("wrap.declined", cmdName))
+	}
+
+	printSummary(cp.ID, cp.Dir, cmdName, args, targets, nil)
+	return cp.ID, true, nil
+}
@@ -0,0 +1,44 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlainRemoval(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	readOnly := filepath.Join(dir, "ro.txt")
+	sub := filepath.Join(dir, "sub")
+	missing := filepath.Join(dir, "missing")
+	os.WriteFile(file, []byte("a"), 0644)
+	os.WriteFile(readOnly, []byte("ro"), 0444)
+	os.Mkdir(sub, 0755)
+
+	tests := []struct {
+		args     []string
+		targets  []string
+		expected bool
+	}{
+		{[]string{file}, []string{file}, true},
+		{[]string{"-rf", sub, file}, []string{sub, file}, true},
+		{[]string{"--recursive", "--force", sub}, []string{sub}, true},
+		{[]string{sub}, []string{sub}, false},
+		{[]string{"-i", file}, []string{file}, false},
+		{[]string{"-rI", sub}, []string{sub}, false},
+		{[]string{"-v", file}, []string{file}, false},
+		{[]string{"--one-file-system", "-r", sub}, []string{sub}, false},
+		{[]string{missing}, []string{missing}, false},
+		{[]string{"-f", missing}, []string{missing}, true},
+		{[]string{readOnly}, []string{readOnly}, false},
+		{[]string{"-f", readOnly}, []string{readOnly}, true},
+		{[]string{"-rf", ".."}, []string{".."}, false},
+		{[]string{"--", "-i"}, []string{"-i"}, false},
+	}
+	for _, tt := range tests {
+		if got := plainRemoval(tt.args, tt.targets); got != tt.expected {
+			t.Errorf("plainRemoval(%q) = %v, want %v", tt.args, got, tt.expected)
+		}
+	}
+}
@@ -1,53 +1,306 @@
 package wrapper
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/qhkm/safeshell/internal/checkpoint"
-	"github.com/qhkm/safeshell/internal/util"
+	"github.com/qhkm/safeshell/internal/config"
+	"github.com/qhkm/safeshell/internal/daemon"
+	"github.com/qhkm/safeshell/internal/hooks"
+	"github.com/qhkm/safeshell/internal/i18n"
+	"github.com/qhkm/safeshell/internal/logging"
+	"github.com/qhkm/safeshell/internal/output"
 )
 
-// Wrap executes a command with automatic checkpoint creation
-func Wrap(cmdName string, args []string) error {
-	// Check if command is supported
-	cmdDef, ok := GetCommand(cmdName)
-	if !ok {
-		// Not a wrapped command, just execute it directly
-		return executeCommand(cmdName, args)
+// DisableEnv turns checkpoints off for wrapped commands when set to
+// anything but 0 or false, e.g. around a scripted bulk deletion
+const DisableEnv = "SAFESHELL_DISABLE"
+
+// YesEnv answers yes to the questions wrapped commands ask, like 'wrap
+// --yes', for scripts and agents without a terminal to answer on
+const YesEnv = "SAFESHELL_YES"
+
+// AssumeYes answers yes to the questions Wrap asks, from 'wrap --yes'
+var AssumeYes bool
+
+// Disabled reports whether DisableEnv is set
+func Disabled() bool {
+	return envSet(DisableEnv)
+}
+
+// envSet reports whether the environment variable name is set to
+// anything but 0 or false
+func envSet(name string) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "", "0", "false":
+		return false
 	}
+	return true
+}
 
-	// Parse arguments to get target paths
+// Run executes a command like Wrap, but without creating a checkpoint
+func Run(cmdName string, args []string) error {
+	start := time.Now()
+	err := run(cmdName, args)
+	audit(cmdName, args, "", start, err)
+	return err
+}
+
+// run is Run, without the operations log. protected_paths and rules may
+// still stop the command: only the checkpoint is skipped, even one they
+// require.
+func run(cmdName string, args []string) error {
+	cmdDef := commandFor(cmdName)
 	targets, err := cmdDef.Parser(args)
 	if err != nil {
 		return fmt.Errorf("failed to parse arguments: %w", err)
 	}
+	if _, err := checkProtected(cmdName, cmdDef, targets); err != nil {
+		return err
+	}
+	if _, err := checkRules(cmdName, cmdDef, args, targets); err != nil {
+		return err
+	}
+	return executeCommand(cmdName, args)
+}
+
+// BypassAllowed reports whether commands may run without a checkpoint, as
+// asked with 'wrap --no-checkpoint' or DisableEnv. Organization policy
+// forbids it by listing no_checkpoint in disabled_features, which it warns
+// of.
+func BypassAllowed() bool {
+	if wrapSettings().FeatureEnabled(config.FeatureNoCheckpoint) {
+		return true
+	}
+	i18n.SetLocale(i18n.Detect(wrapSettings().Language))
+	warn(i18n.T("wrap.bypass_forbidden"))
+	return false
+}
 
-	// Filter targets to only existing paths
-	var existingTargets []string
+// Wrap executes a command with automatic checkpoint creation, and records
+// it in the operations log, with how it went
+func Wrap(cmdName string, args []string) error {
+	start := time.Now()
+	id, err := wrap(cmdName, args)
+	audit(cmdName, args, id, start, err)
+	return err
+}
+
+// wrap is Wrap, returning the ID of the checkpoint created, if any
+func wrap(cmdName string, args []string) (string, error) {
+	// Parse arguments to get target paths. Whether the command is wrapped
+	// at all, and may run, is up to config, which the daemon sends if it's
+	// running.
+	cmdDef := commandFor(cmdName)
+	targets, err := cmdDef.Parser(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	}
+
+	// protected_paths and rules may stop the command here, or only let it
+	// run once checkpointed
+	protected, err := checkProtected(cmdName, cmdDef, targets)
+	if err != nil {
+		return "", err
+	}
+	required, err := checkRules(cmdName, cmdDef, args, targets)
+	if err != nil {
+		return "", err
+	}
+	large := largeHighRisk(cmdName, cmdDef, args, targets)
+
+	// With rm_strategy: trash, rm moves its targets into a checkpoint
+	// instead of running
+	if trashable(cmdName, args, targets) {
+		if id, done, err := trash(cmdName, args, targets, large); done {
+			return id, err
+		}
+	}
+
+	// Filter targets to only existing paths. Devices can't be backed up:
+	// copying one would read a whole disk, or block on a pipe.
+	var existingTargets, devices []string
 	for _, target := range targets {
-		if _, err := os.Stat(target); err == nil {
+		if info, err := os.Stat(target); err == nil && isSpecial(info) {
+			devices = append(devices, target)
+		} else if err == nil {
 			existingTargets = append(existingTargets, target)
 		}
 	}
 
 	// Create checkpoint if there are targets to backup
+	var id, dir string
+	wrapped := len(existingTargets) > 0 || (len(devices) > 0 && IsWrapped(cmdName))
 	if len(existingTargets) > 0 {
 		fullCommand := cmdName + " " + strings.Join(args, " ")
-		cp, err := checkpoint.Create(fullCommand, existingTargets)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to create checkpoint: %v\n", err)
+		var evicted []*checkpoint.Eviction
+		id, dir, evicted, err = createCheckpoint(cmdName, fullCommand, existingTargets, cmdDef.InPlace)
+		if errors.Is(err, errNotWrapped) {
+			// Not a wrapped command, just execute it
+			wrapped = false
+		} else if errors.Is(err, context.Canceled) {
+			return "", err
+		} else if err != nil && (protected || required) {
+			return "", fmt.Errorf("%w: %s", ErrRefused, i18n.T("wrap.checkpoint_required", err))
+		} else if err != nil {
+			warn(i18n.T("wrap.checkpoint_failed", err))
 		} else {
-			fmt.Fprintf(os.Stderr, "[safeshell] Checkpoint created: %s\n", cp.ID)
+			inform(i18n.T("wrap.checkpoint_created", id))
+			for _, e := range evicted {
+				inform(i18n.T("wrap.evicted_"+e.Action, len(e.IDs), e.Limit, e.Max))
+			}
+		}
+	}
+
+	if wrapped {
+		for _, device := range devices {
+			warn(i18n.T("wrap.device_skipped", device, describeSpecial(device)))
 		}
 	}
 
+	// Large high-risk commands ask, knowing the checkpoint they can undo with
+	if large != nil && !confirmHighRisk(cmdName, args, large, id) {
+		if id != "" {
+			if err := checkpoint.Delete(id); err != nil {
+				warn(i18n.T("wrap.checkpoint_delete_failed", id, err))
+			}
+		}
+		return "", fmt.Errorf("%w: %s", ErrRefused, i18n.T("wrap.declined", cmdName))
+	}
+
 	// Execute the actual command
-	return executeCommand(cmdName, args)
+	err = executeCommand(cmdName, args)
+	if id != "" {
+		printSummary(id, dir, cmdName, args, targets, err)
+	}
+	return id, err
+}
+
+// isSpecial reports whether info is of a device, named pipe or socket
+func isSpecial(info os.FileInfo) bool {
+	return info.Mode()&(os.ModeDevice|os.ModeNamedPipe|os.ModeSocket) != 0
+}
+
+// describeSpecial says what kind of special file path is, with the size of
+// a block device if it can be read
+func describeSpecial(path string) string {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return i18n.T("wrap.special_file")
+	case info.Mode()&os.ModeCharDevice != 0:
+		return i18n.T("wrap.char_device")
+	case info.Mode()&os.ModeDevice != 0:
+		// Seeking to the end of a block device gives its size
+		if f, err := os.Open(path); err == nil {
+			size, err := f.Seek(0, io.SeekEnd)
+			f.Close()
+			if err == nil && size > 0 {
+				return i18n.T("wrap.block_device_size", output.FormatBytes(size))
+			}
+		}
+		return i18n.T("wrap.block_device")
+	}
+	return i18n.T("wrap.special_file")
+}
+
+// printSummary records what the command, which returned runErr, did to the
+// files checkpointed before it in the checkpoint's manifest, and tells it
+// and how to get them back. Even a failed command may have done some.
+func printSummary(id, dir, cmdName string, args, targets []string, runErr error) {
+	outcome, err := checkpoint.RecordOutcome(dir, exitCode(runErr), moveDestinations(cmdName, args, targets))
+	if err != nil {
+		logging.Debug("outcome not recorded", "checkpoint", id, "error", err)
+		return
+	}
+	effect := outcome.Effect()
+	var parts []string
+	if effect.Deleted > 0 {
+		parts = append(parts, i18n.T("wrap.effect_deleted", effect.Deleted))
+	}
+	if effect.Moved > 0 {
+		parts = append(parts, i18n.T("wrap.effect_moved", effect.Moved))
+	}
+	if effect.Changed > 0 {
+		parts = append(parts, i18n.T("wrap.effect_changed", effect.Changed))
+	}
+	if len(parts) == 0 {
+		inform(i18n.T("wrap.summary_unchanged", id))
+		return
+	}
+	inform(i18n.T("wrap.summary", strings.Join(parts, ", "), id))
+}
+
+// createCheckpoint has the daemon create the checkpoint if one is running,
+// and creates it in-process otherwise. Config is only loaded for the latter.
+// It returns the checkpoint's ID and directory and what was evicted to make
+// room for it, and sets where messages go and how many there are. It
+// returns errNotWrapped if name is set but not in wrapped_commands. With
+// inPlace, the command writes to the targets in place, so they are backed
+// up as copies, which the writes can't reach.
+func createCheckpoint(name, command string, targets []string, inPlace bool) (string, string, []*checkpoint.Eviction, error) {
+	// Hooks run inside the daemon, so commands run by a hook must not wait on it
+	if !hooks.Active() {
+		if workingDir, err := os.Getwd(); err == nil {
+			resp, err := daemon.CreateCheckpoint(daemon.SocketPath(), name, command, targets, workingDir, checkpoint.GetSessionID(), inPlace)
+			if err != daemon.ErrNotRunning {
+				mode, level := "", ""
+				if resp != nil {
+					i18n.SetLocale(i18n.Detect(resp.Language))
+					useRealCommands(resp.RealCommands)
+					operationsLog = resp.OperationsLog
+					mode, level = resp.Messages, resp.LogLevel
+				}
+				useMessages(mode, command)
+				logging.Setup(level, false, "")
+				if err != nil {
+					return "", "", nil, err
+				}
+				if resp.NotWrapped {
+					return "", "", nil, errNotWrapped
+				}
+				return resp.CheckpointID, resp.CheckpointDir, resp.Evicted, nil
+			}
+		}
+	}
+
+	if name != "" && !IsWrapped(name) {
+		return "", "", nil, errNotWrapped
+	}
+	i18n.SetLocale(i18n.Detect(config.Get().Language))
+	useMessages(config.Get().WrapperMessages, command)
+	logging.Setup(config.Get().LogLevel, config.Get().LogFile, config.Get().SafeShellDir)
+	// Ctrl-C while copying stops before the command runs, removing the copies
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var cp *checkpoint.Checkpoint
+	var err error
+	withMessages(func() {
+		if !inPlace {
+			cp, err = checkpoint.CreateContext(ctx, command, targets, nil)
+			return
+		}
+		var workingDir string
+		if workingDir, err = os.Getwd(); err == nil {
+			origin := checkpoint.Origin{WorkingDir: workingDir, SessionID: checkpoint.GetSessionID()}
+			cp, err = checkpoint.CreateForWriter(ctx, origin, command, targets)
+		}
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return cp.ID, cp.Dir, cp.Evicted, nil
 }
 
 // WrapDryRun shows what would be backed up without creating checkpoint or executing command
@@ -55,22 +308,22 @@ func WrapDryRun(cmdName string, args []string) error {
 	fullCommand := cmdName + " " + strings.Join(args, " ")
 
 	fmt.Println()
-	color.New(color.FgCyan, color.Bold).Println("Dry Run - No changes will be made")
+	color.New(color.FgCyan, color.Bold).Println(i18n.T("wrap.dryrun_title"))
 	fmt.Println()
-	fmt.Printf("Command: %s\n", fullCommand)
+	fmt.Println(i18n.T("wrap.dryrun_command", fullCommand))
 	fmt.Println()
 
-	// Check if command is supported
-	cmdDef, ok := GetCommand(cmdName)
-	if !ok {
-		color.Yellow("⚠ Command '%s' is not wrapped by SafeShell\n", cmdName)
-		fmt.Println("  This command will execute without creating a checkpoint.")
+	// Check if command is wrapped
+	if !IsWrapped(cmdName) {
+		color.Yellow("%s", i18n.T("wrap.not_wrapped", cmdName))
+		fmt.Println(i18n.T("wrap.not_wrapped_detail"))
 		fmt.Println()
-		fmt.Println("Wrapped commands: rm, mv, cp, chmod, chown")
+		fmt.Println(i18n.T("wrap.wrapped_commands", strings.Join(config.Get().WrappedCommands, ", ")))
 		return nil
 	}
+	cmdDef := commandFor(cmdName)
 
-	fmt.Printf("Risk level: %s\n", cmdDef.RiskLevel)
+	fmt.Println(i18n.T("wrap.risk_level", cmdDef.RiskLevel))
 	fmt.Println()
 
 	// Parse arguments to get target paths
@@ -80,26 +333,37 @@ func WrapDryRun(cmdName string, args []string) error {
 	}
 
 	if len(targets) == 0 {
-		color.Yellow("⚠ No target files/directories detected\n")
-		fmt.Println("  No checkpoint would be created.")
+		color.Yellow("%s", i18n.T("wrap.no_targets"))
+		fmt.Println(i18n.T("wrap.no_checkpoint"))
 		return nil
 	}
 
-	color.New(color.FgWhite, color.Bold).Println("Files/directories to backup:")
+	color.New(color.FgWhite, color.Bold).Println(i18n.T("wrap.to_backup"))
 	fmt.Println()
 
 	var totalSize int64
 	var totalFiles int
 	existingCount := 0
 
+	workingDir, _ := os.Getwd()
 	for _, target := range targets {
+		if abs, err := filepath.Abs(target); err == nil {
+			if root := checkpoint.ProjectScope(abs, workingDir); root != abs {
+				color.Yellow("%s", i18n.T("wrap.scoped_target", abs, root))
+				target = root
+			}
+		}
 		info, err := os.Stat(target)
 		if os.IsNotExist(err) {
-			color.New(color.FgHiBlack).Printf("  ✗ %s (does not exist - will be skipped)\n", target)
+			color.New(color.FgHiBlack).Println(i18n.T("wrap.missing_target", target))
 			continue
 		}
 		if err != nil {
-			color.New(color.FgRed).Printf("  ✗ %s (error: %v)\n", target, err)
+			color.New(color.FgRed).Println(i18n.T("wrap.target_error", target, err))
+			continue
+		}
+		if isSpecial(info) {
+			color.Yellow("%s", i18n.T("wrap.target_device", target, describeSpecial(target)))
 			continue
 		}
 
@@ -119,38 +383,55 @@ func WrapDryRun(cmdName string, args []string) error {
 			})
 			totalFiles += dirFiles
 			totalSize += dirSize
-			color.Green("  ✓ %s/ (directory, %d files, %s)\n", target, dirFiles, util.FormatBytes(dirSize))
+			color.Green("%s", i18n.T("wrap.target_dir", target, dirFiles, output.FormatBytes(dirSize)))
 		} else {
 			totalFiles++
 			totalSize += info.Size()
-			color.Green("  ✓ %s (%s)\n", target, util.FormatBytes(info.Size()))
+			color.Green("%s", i18n.T("wrap.target_file", target, output.FormatBytes(info.Size())))
 		}
 	}
+	for _, p := range protectedTargets(config.Get(), cmdDef, targets) {
+		action := i18n.T("wrap.protected_action_" + p.entry.Action)
+		color.Yellow("%s", i18n.T("wrap.target_protected", p.target, p.entry.Path, action))
+	}
+	if r, name, ok := matchingRule(config.Get().Rules, newCommandFacts(cmdName, cmdDef, args, targets)); ok {
+		color.Yellow("%s", i18n.T("wrap.rule_dryrun", name, i18n.T("wrap.rule_action_"+r.Action)))
+	}
+	if trashable(cmdName, args, targets) {
+		color.Yellow("%s", i18n.T("wrap.trash_dryrun"))
+	}
 
 	fmt.Println()
-	color.New(color.FgWhite, color.Bold).Println("Summary:")
+	color.New(color.FgWhite, color.Bold).Println(i18n.T("diff.summary"))
 	if existingCount > 0 {
-		fmt.Printf("  • %d path(s) would be backed up\n", existingCount)
-		fmt.Printf("  • %d total file(s)\n", totalFiles)
-		fmt.Printf("  • %s total size\n", util.FormatBytes(totalSize))
+		fmt.Println(i18n.T("wrap.paths_backed_up", existingCount))
+		fmt.Println(i18n.T("wrap.total_files", totalFiles))
+		fmt.Println(i18n.T("wrap.total_size", output.FormatBytes(totalSize)))
 		fmt.Println()
-		color.Green("✓ A checkpoint would be created before executing this command\n")
+		color.Green("%s", i18n.T("wrap.would_checkpoint"))
 	} else {
-		color.Yellow("⚠ No existing files to backup - no checkpoint would be created\n")
+		color.Yellow("%s", i18n.T("wrap.nothing_to_backup"))
 	}
 
 	fmt.Println()
-	fmt.Println("To execute this command for real, run without --dry-run:")
+	fmt.Println(i18n.T("wrap.run_for_real"))
 	color.Cyan("  safeshell wrap %s\n", fullCommand)
 
 	return nil
 }
 
+// ErrCommandNotFound and ErrCannotExecute are returned when the command
+// can't be run at all, for safeshell to exit with 127 and 126 like a shell
+var (
+	ErrCommandNotFound = errors.New("command not found")
+	ErrCannotExecute   = errors.New("cannot execute")
+)
+
 func executeCommand(cmdName string, args []string) error {
 	// Find the real command (not our alias)
 	cmdPath, err := findRealCommand(cmdName)
 	if err != nil {
-		return fmt.Errorf("command not found: %s", cmdName)
+		return fmt.Errorf("%w: %v", ErrCommandNotFound, err)
 	}
 
 	cmd := exec.Command(cmdPath, args...)
@@ -158,25 +439,33 @@ func executeCommand(cmdName string, args []string) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	return cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCannotExecute, err)
+	}
+	stop := forwardSignals(cmd)
+	defer stop()
+	return cmd.Wait()
 }
 
-// findRealCommand finds the actual binary path, skipping any safeshell wrappers
-func findRealCommand(cmdName string) (string, error) {
-	// Common binary locations
-	searchPaths := []string{
-		"/bin/" + cmdName,
-		"/usr/bin/" + cmdName,
-		"/usr/local/bin/" + cmdName,
-		"/opt/homebrew/bin/" + cmdName,
+// ExitStatus returns the status a shell would report for a command that
+// failed with err, an *exec.ExitError: its exit code, or 128 plus the
+// signal that killed it
+func ExitStatus(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
 	}
+	return err.ExitCode()
+}
 
-	for _, path := range searchPaths {
-		if _, err := os.Stat(path); err == nil {
-			return path, nil
-		}
+// DieLikeCommand ends safeshell by the signal that killed the command, if
+// one did, so whoever ran it sees the same death. It returns otherwise, or
+// if safeshell doesn't die of it.
+func DieLikeCommand(err error) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		raise(status.Signal())
 	}
-
-	// Fall back to PATH lookup
-	return exec.LookPath(cmdName)
 }
@@ -1,12 +1,22 @@
 package wrapper
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+
+	"github.com/qhkm/safeshell/internal/checkpoint"
+	"github.com/qhkm/safeshell/internal/config"
 )
 
 func TestIsSupported(t *testing.T) {
-	supportedCommands := []string{"rm", "mv", "cp", "chmod", "chown"}
-	unsupportedCommands := []string{"ls", "cat", "echo", "grep", "find"}
+	supportedCommands := []string{"rm", "mv", "cp", "chmod", "chown", "find"}
+	unsupportedCommands := []string{"ls", "cat", "echo", "grep", "touch"}
 
 	for _, cmd := range supportedCommands {
 		if !IsSupported(cmd) {
@@ -65,6 +75,27 @@ func TestSupportedCommandsRiskLevels(t *testing.T) {
 	}
 }
 
+func TestCommandFor(t *testing.T) {
+	if def := commandFor("mv"); def.RiskLevel != "MEDIUM" {
+		t.Errorf("Expected the built-in mv, got %+v", def)
+	}
+	def := commandFor("shred")
+	targets, err := def.Parser([]string{"-u", "a.txt", "b.txt"})
+	if def.Name != "shred" || err != nil || !reflect.DeepEqual(targets, []string{"a.txt", "b.txt"}) {
+		t.Errorf("Expected every path argument of a custom command, got %v (%v)", targets, err)
+	}
+
+	// custom_commands come with the other settings, from the daemon if it's
+	// running
+	settings = &config.Config{CustomCommands: []config.CustomCommand{{Name: "tool", Risk: "low", Targets: TargetsLastPositional}}}
+	t.Cleanup(func() { settings = nil })
+	def = commandFor("tool")
+	targets, err = def.Parser([]string{"a.txt", "b.txt"})
+	if def.RiskLevel != "LOW" || err != nil || !reflect.DeepEqual(targets, []string{"b.txt"}) {
+		t.Errorf("Expected the custom_commands entry, got %+v: %v (%v)", def, targets, err)
+	}
+}
+
 func TestCommandParsers(t *testing.T) {
 	// Test that all supported commands have working parsers
 	for name, def := range SupportedCommands {
@@ -86,3 +117,181 @@ func TestCommandParsers(t *testing.T) {
 		}
 	}
 }
+
+// writeExecutable creates an executable script at dir/name
+func writeExecutable(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindRealCommandSkipsShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not executable on windows")
+	}
+	tmpDir := t.TempDir()
+	shimDir := filepath.Join(tmpDir, "shims")
+	realDir := filepath.Join(tmpDir, "real")
+	writeExecutable(t, shimDir, "rm", "#!/bin/sh\nexec safeshell wrap rm \"$@\"\n")
+	want := writeExecutable(t, realDir, "rm", "#!/bin/sh\nexit 0\n")
+
+	t.Setenv("PATH", strings.Join([]string{"relative", filepath.Join(tmpDir, "missing"), shimDir, realDir}, string(os.PathListSeparator)))
+	useRealCommands(nil)
+	t.Cleanup(func() { realCommands = nil })
+
+	got, err := findRealCommand("rm")
+	if err != nil {
+		t.Fatalf("findRealCommand failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestFindRealCommandOverride(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not executable on windows")
+	}
+	tmpDir := t.TempDir()
+	writeExecutable(t, filepath.Join(tmpDir, "path"), "rm", "#!/bin/sh\nexit 0\n")
+	override := writeExecutable(t, filepath.Join(tmpDir, "nix"), "rm", "#!/bin/sh\nexit 0\n")
+	t.Setenv("PATH", filepath.Join(tmpDir, "path"))
+	t.Cleanup(func() { realCommands = nil })
+
+	useRealCommands(map[string]string{"rm": override})
+	if got, err := findRealCommand("rm"); err != nil || got != override {
+		t.Errorf("Expected override %s, got %s (%v)", override, got, err)
+	}
+
+	useRealCommands(map[string]string{"rm": filepath.Join(tmpDir, "nope")})
+	if _, err := findRealCommand("rm"); err == nil || !strings.Contains(err.Error(), "real_commands.rm") {
+		t.Errorf("Expected an error naming the override, got %v", err)
+	}
+}
+
+func TestFindRealCommandListsAttempts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not executable on windows")
+	}
+	tmpDir := t.TempDir()
+	shimDir := filepath.Join(tmpDir, "shims")
+	shim := writeExecutable(t, shimDir, "rm", "#!/bin/sh\nsafeshell wrap rm \"$@\"\n")
+	missing := filepath.Join(tmpDir, "missing")
+	t.Setenv("PATH", strings.Join([]string{missing, shimDir}, string(os.PathListSeparator)))
+	useRealCommands(nil)
+	t.Cleanup(func() { realCommands = nil })
+
+	_, err := findRealCommand("rm")
+	if err == nil {
+		t.Fatal("Expected an error when only a shim is on PATH")
+	}
+	for _, want := range []string{filepath.Join(missing, "rm"), shim + " (safeshell shim)", "real_commands.rm"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestUseMessages(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(MessagesEnv, "")
+	t.Cleanup(func() { messages = os.Stderr })
+
+	// Without ~/.safeshell the log can't be opened
+	useMessages(MessagesLog, "rm a.txt")
+	if messages != os.Stderr {
+		t.Fatal("Expected messages on stderr when the log can't be opened")
+	}
+
+	os.MkdirAll(filepath.Join(home, ".safeshell"), 0755)
+	useMessages(MessagesLog, "rm a.txt")
+	stderr := os.Stderr
+	withMessages(func() { fmt.Fprintln(os.Stderr, "checkpoint warning") })
+	if os.Stderr != stderr {
+		t.Error("Expected stderr to be restored")
+	}
+	messages.Close()
+	data, _ := os.ReadFile(MessagesLogPath())
+	for _, want := range []string{"rm a.txt", "checkpoint warning"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected the log to contain %q, got:\n%s", want, data)
+		}
+	}
+
+	// The environment wins over config
+	t.Setenv(MessagesEnv, MessagesOff)
+	useMessages(MessagesLog, "rm b.txt")
+	if messages.Name() != os.DevNull {
+		t.Errorf("Expected messages to be dropped, got %s", messages.Name())
+	}
+	messages.Close()
+}
+
+func TestInPlaceWritersKeepBackups(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("truncate, shred and dd are not on windows")
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		args func(file string) []string
+	}{
+		{"truncate", func(file string) []string { return []string{"-s", "0", file} }},
+		{"shred", func(file string) []string { return []string{"-n", "1", file} }},
+		{"dd", func(file string) []string { return []string{"if=/dev/zero", "of=" + file, "bs=4", "count=1"} }},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, tt.name+".txt")
+		os.WriteFile(file, []byte("original"), 0644)
+
+		id, err := wrap(tt.name, tt.args(file))
+		if err != nil {
+			t.Fatalf("%s failed: %v", tt.name, err)
+		}
+		cp, err := checkpoint.Get(id)
+		if err != nil {
+			t.Fatalf("%s: checkpoint %q not found: %v", tt.name, id, err)
+		}
+		if len(cp.Manifest.Files) != 1 {
+			t.Fatalf("%s: expected 1 file backed up, got %d", tt.name, len(cp.Manifest.Files))
+		}
+		if data, _ := os.ReadFile(cp.Manifest.Files[0].BackupPath); string(data) != "original" {
+			t.Errorf("%s: expected the backup to hold the original, got %q", tt.name, data)
+		}
+	}
+}
+
+func TestRunKeepsChecks(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "keep.txt")
+	os.WriteFile(file, []byte("original"), 0644)
+	settings = &config.Config{
+		WrappedCommands: []string{"rm"},
+		ProtectedPaths:  []config.ProtectedPath{{Path: file, Action: config.ProtectDeny}},
+	}
+	t.Cleanup(func() { settings = nil })
+
+	// Without a checkpoint, protected_paths still refuses
+	if err := Run("rm", []string{file}); !errors.Is(err, ErrRefused) {
+		t.Errorf("Expected rm to be refused, got %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("Expected %s to be kept: %v", file, err)
+	}
+
+	if !BypassAllowed() {
+		t.Error("Expected the bypass to be allowed without a policy")
+	}
+	settings.Policy = &config.Policy{DisabledFeatures: []string{config.FeatureNoCheckpoint}}
+	if BypassAllowed() {
+		t.Error("Expected the policy to forbid the bypass")
+	}
+}
@@ -20,6 +20,7 @@ func setupIntegrationEnv(t *testing.T) (string, func()) {
 	}
 
 	os.Setenv("HOME", tmpDir)
+	config.Reset()
 	config.Init()
 
 	workDir := filepath.Join(tmpDir, "workspace")